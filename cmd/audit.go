@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/audit"
+	"github.com/illarion/lockenv/internal/logging"
+	"github.com/illarion/lockenv/internal/output"
+)
+
+// auditSources names password sources in the audit log
+var auditSources = map[PasswordSource]string{
+	SourcePrompt:         "prompt",
+	SourceEnv:            "env",
+	SourceKeyring:        "keyring",
+	SourceHashiCorpVault: "hashicorp-vault",
+	SourceCommand:        "password_command",
+}
+
+// recordUnlock logs the outcome of a password check in the audit log. The
+// log is best effort: failing to write it never fails the command.
+func recordUnlock(vaultID string, ok bool, source PasswordSource) {
+	if vaultID == "" {
+		return
+	}
+	if err := audit.RecordUnlock(vaultID, ok, auditSources[source]); err != nil {
+		logging.Debug("audit log not written", "error", err)
+	}
+}
+
+// printFailedUnlocks warns about failed unlock attempts on this machine
+// since the password was last accepted
+func printFailedUnlocks(vaultID string) {
+	if vaultID == "" {
+		return
+	}
+	events, err := audit.Events(vaultID)
+	if err != nil {
+		logging.Debug("audit log not read", "error", err)
+		return
+	}
+	failed, last := audit.FailedSinceSuccess(events)
+	if failed == 0 {
+		return
+	}
+	fmt.Printf("\n%s %d failed unlock attempt(s) since last success (last %s)\n",
+		output.Stdout().Yellow("Warning:"), failed, last.Local().Format("2006-01-02 15:04:05"))
+}
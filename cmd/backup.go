@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// Backup writes a timestamped snapshot of the vault and prunes old backups
+func Backup(dir string, keep int) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Snapshot stays encrypted (no password required)
+	result, err := lockenv.Backup(dir, keep)
+	if err != nil {
+		HandleError(err)
+	}
+
+	for _, path := range result.Pruned {
+		fmt.Printf("pruned: %s\n", path)
+	}
+	fmt.Printf("backup: %s (%s)\n", result.Path, core.FormatSize(result.Size))
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// CIExportGitHub appends the variables from the vault's dotenv files to the
+// $GITHUB_ENV file, making them available to later steps of a GitHub Actions
+// job, and masks their values in the job log. files and profile select the
+// dotenv files as for Env.
+func CIExportGitHub(ctx context.Context, profile string, files []string) {
+	envFile := os.Getenv("GITHUB_ENV")
+	if envFile == "" {
+		HandleError(fmt.Errorf("GITHUB_ENV is not set (not running in GitHub Actions?)"))
+	}
+
+	entries := envEntries(ctx, profile, files)
+
+	// Masks must reach the runner before any value can be printed
+	fmt.Print(core.GitHubMaskCommands(entries))
+
+	output, skipped, err := core.FormatGitHubEnv(entries)
+	if err != nil {
+		HandleError(err)
+	}
+	for _, name := range skipped {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s: not a valid variable name\n", name)
+	}
+
+	f, err := os.OpenFile(envFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, core.FilePermSecure)
+	if err != nil {
+		HandleError(err)
+	}
+	if _, err := f.WriteString(output); err != nil {
+		f.Close()
+		HandleError(err)
+	}
+	if err := f.Close(); err != nil {
+		HandleError(err)
+	}
+	fmt.Fprintf(os.Stderr, "exported: %d variables to $GITHUB_ENV\n", len(entries)-len(skipped))
+}
+
+// CIExportGitLab writes the variables from the vault's dotenv files as a
+// GitLab CI dotenv report to output, or stdout if output is empty. files and
+// profile select the dotenv files as for Env.
+func CIExportGitLab(ctx context.Context, output, profile string, files []string) {
+	entries := envEntries(ctx, profile, files)
+
+	report, skipped := core.FormatGitLabDotenv(entries)
+	for _, name := range skipped {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s: GitLab dotenv reports support only single-line values and names of letters, digits and underscores\n", name)
+	}
+
+	if output == "" {
+		fmt.Print(report)
+		return
+	}
+	if err := core.WriteSecretFile(output, []byte(report)); err != nil {
+		HandleError(err)
+	}
+	fmt.Fprintf(os.Stderr, "exported: %d variables to %s\n", len(entries)-len(skipped), output)
+}
+
+// envEntries decrypts the dotenv files selected as for Env. Prompts go to
+// stderr so stdout carries only the command's output.
+func envEntries(ctx context.Context, profile string, files []string) []core.DotenvEntry {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	entries, err := lockenv.Env(ctx, password, envProfile(profile), files)
+	if err != nil {
+		HandleError(err)
+	}
+	return entries
+}
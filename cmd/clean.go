@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+// Clean removes plaintext copies of tracked files that match the vault
+func Clean(ctx context.Context, force bool) {
+	cleanWorkingTree(ctx, force, false)
+}
+
+// Shred overwrites and removes plaintext copies of tracked files
+func Shred(ctx context.Context, force bool) {
+	cleanWorkingTree(ctx, force, true)
+}
+
+func cleanWorkingTree(ctx context.Context, force bool, shred bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	result, err := lockenv.Clean(ctx, force, shred)
+	if err != nil {
+		HandleError(err)
+	}
+
+	printEvents(result.Events)
+
+	// Print summary
+	if len(result.Removed) == 0 && len(result.Skipped) == 0 {
+		fmt.Println("No unlocked files to clean")
+		return
+	}
+	fmt.Printf("\n")
+	if len(result.Removed) > 0 {
+		fmt.Printf("removed: %d files\n", len(result.Removed))
+	}
+	if len(result.Skipped) > 0 {
+		fmt.Printf("skipped: %d files\n", len(result.Skipped))
+	}
+}
@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/illarion/lockenv/internal/config"
 	"github.com/illarion/lockenv/internal/core"
 	"github.com/illarion/lockenv/internal/crypto"
 	"github.com/illarion/lockenv/internal/keyring"
+	"github.com/illarion/lockenv/internal/logging"
+	"github.com/illarion/lockenv/internal/output"
 	"golang.org/x/term"
 )
 
@@ -19,22 +24,65 @@ const (
 	SourcePrompt PasswordSource = iota
 	SourceEnv
 	SourceKeyring
+	SourceSession
+	SourceSSH
+	SourceHashiCorpVault
+	SourceKMS
+	SourceCommand
 )
 
+// loadConfig resolves the settings for the project in the current directory
+// from the user and project config files
+func loadConfig() *config.Config {
+	settings, err := config.Load(".")
+	if err != nil {
+		HandleError(err)
+	}
+	return settings
+}
+
+// ApplyColor applies the color setting from the config files. --no-color
+// and NO_COLOR still turn colors off.
+func ApplyColor() {
+	settings, err := config.Load(".")
+	if err != nil {
+		return // Reported by the commands that read the config
+	}
+	switch settings.Color {
+	case config.ColorNever:
+		output.Disable()
+	case config.ColorAlways:
+		output.Force()
+	}
+}
+
+// passwordSource looks up the password in one place, returning nil if it has none.
+// The caller is responsible for calling crypto.ClearBytes on the returned password
+type passwordSource struct {
+	source PasswordSource
+	get    func(vaultID string, config *core.Config) []byte
+}
+
+// passwordSources are tried in order before prompting
+var passwordSources = []passwordSource{
+	{SourceEnv, func(string, *core.Config) []byte { return core.GetPasswordFromEnv() }},
+	{SourceCommand, GetPasswordFromCommand},
+	{SourceHashiCorpVault, GetPasswordFromHashiCorpVault},
+	{SourceKeyring, getPasswordFromKeyring},
+}
+
 // GetPasswordWithSource retrieves password and indicates where it came from
 func GetPasswordWithSource(prompt string, vaultID string) ([]byte, PasswordSource, error) {
-	// Try environment variable first
-	password := core.GetPasswordFromEnv()
-	if password != nil {
-		return password, SourceEnv, nil
+	vaultConfig := &core.Config{}
+	if settings, err := config.Load("."); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	} else {
+		vaultConfig = settings.Config
 	}
 
-	// Try keyring if vault ID is available
-	if vaultID != "" {
-		if pwd, err := keyring.GetPassword(vaultID); err == nil {
-			result := make([]byte, len(pwd))
-			copy(result, []byte(pwd))
-			return result, SourceKeyring, nil
+	for _, ps := range passwordSources {
+		if password := ps.get(vaultID, vaultConfig); password != nil {
+			return password, ps.source, nil
 		}
 	}
 
@@ -47,14 +95,58 @@ func GetPasswordWithSource(prompt string, vaultID string) ([]byte, PasswordSourc
 	return password, SourcePrompt, nil
 }
 
-// GetPasswordWithRetry gets password and retries on keyring failure
-func GetPasswordWithRetry(prompt string, vaultID string, verify func([]byte) error) ([]byte, PasswordSource, error) {
+// getPasswordFromKeyring returns the password saved in the OS keyring, if any
+func getPasswordFromKeyring(vaultID string, _ *core.Config) []byte {
+	if vaultID == "" {
+		return nil
+	}
+	pwd, err := keyring.GetPassword(vaultID)
+	if err != nil {
+		if errors.Is(err, keyring.ErrExpired) {
+			fmt.Fprintln(os.Stderr, "Keyring password expired, removed stale entry")
+		}
+		return nil
+	}
+	result := make([]byte, len(pwd))
+	copy(result, []byte(pwd))
+	return result
+}
+
+// sourceName describes a configured password source for warnings
+func sourceName(source PasswordSource) string {
+	if source == SourceCommand {
+		return "password_command"
+	}
+	return "HashiCorp Vault"
+}
+
+// GetPasswordWithRetry gets password and retries on keyring failure.
+// If a session is active for the vault, or the user's SSH key or cloud KMS
+// credentials can unwrap a vault recipient, the vault key is installed on
+// lockenv and an empty password is returned with SourceSession, SourceSSH or
+// SourceKMS.
+func GetPasswordWithRetry(prompt string, vaultID string, lockenv *core.LockEnv) ([]byte, PasswordSource, error) {
+	if useSession(lockenv, vaultID) {
+		return []byte{}, SourceSession, nil
+	}
+
+	// The password from the environment takes precedence over SSH identities
+	if os.Getenv("LOCKENV_PASSWORD") == "" && useSSHIdentity(lockenv) {
+		return []byte{}, SourceSSH, nil
+	}
+	if os.Getenv("LOCKENV_PASSWORD") == "" && useKMS(lockenv) {
+		return []byte{}, SourceKMS, nil
+	}
+
 	password, source, err := GetPasswordWithSource(prompt, vaultID)
 	if err != nil {
 		return nil, source, err
 	}
 
-	verifyErr := verify(password)
+	verifyErr := lockenv.VerifyPassword(password)
+	if verifyErr == nil || verifyErr == core.ErrWrongPassword {
+		recordUnlock(vaultID, verifyErr == nil, source)
+	}
 	if verifyErr == nil {
 		return password, source, nil
 	}
@@ -72,6 +164,18 @@ func GetPasswordWithRetry(prompt string, vaultID string, verify func([]byte) err
 		return password, SourcePrompt, nil
 	}
 
+	// A wrong password from a configured source must not lock the user out
+	if verifyErr == core.ErrWrongPassword && (source == SourceHashiCorpVault || source == SourceCommand) && IsTerminal() {
+		crypto.ClearBytes(password)
+		fmt.Fprintf(os.Stderr, "Warning: password from %s is incorrect\n", sourceName(source))
+
+		password, err = core.ReadPassword(prompt)
+		if err != nil {
+			return nil, SourcePrompt, err
+		}
+		return password, SourcePrompt, nil
+	}
+
 	crypto.ClearBytes(password)
 	return nil, source, verifyErr
 }
@@ -106,27 +210,70 @@ func boolToInt(b bool) int {
 
 // HandleError handles common errors consistently
 func HandleError(err error) {
+	logging.Error("command failed", "error", err)
+	label := output.Stderr().Red("Error:")
 	switch err {
 	case core.ErrNotInitialized:
-		fmt.Fprintf(os.Stderr, "Error: lockenv not initialized\n")
+		fmt.Fprintf(os.Stderr, "%s lockenv not initialized\n", label)
 		fmt.Fprintf(os.Stderr, "Run 'lockenv init' first\n")
 	case core.ErrAlreadyExists:
-		fmt.Fprintf(os.Stderr, "Error: .lockenv already exists in this directory\n")
+		fmt.Fprintf(os.Stderr, "%s .lockenv already exists in this directory\n", label)
 		fmt.Fprintf(os.Stderr, "Use 'lockenv status' to see current state\n")
 	case core.ErrPasswordRequired:
-		fmt.Fprintf(os.Stderr, "Error: password is required\n")
+		fmt.Fprintf(os.Stderr, "%s password is required\n", label)
 		fmt.Fprintf(os.Stderr, "Set LOCKENV_PASSWORD environment variable or run without it to be prompted\n")
 	case core.ErrWrongPassword:
-		fmt.Fprintf(os.Stderr, "Error: wrong password\n")
+		fmt.Fprintf(os.Stderr, "%s wrong password\n", label)
 	case core.ErrNoTrackedFiles:
-		fmt.Fprintf(os.Stderr, "Error: no files in vault\n")
+		fmt.Fprintf(os.Stderr, "%s no files in vault\n", label)
 		fmt.Fprintf(os.Stderr, "Use 'lockenv lock' to add files\n")
+	case core.ErrRecipientNotFound:
+		fmt.Fprintf(os.Stderr, "%s recipient not found\n", label)
+		fmt.Fprintf(os.Stderr, "Use 'lockenv recipient list' to see recipients\n")
 	default:
-		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		fmt.Fprintf(os.Stderr, "%s %s\n", label, err)
 	}
 	os.Exit(1)
 }
 
+// printEvents prints the per-file messages of an operation, one per line
+func printEvents(events core.EventLog) {
+	for _, event := range events {
+		fmt.Println(event)
+	}
+}
+
+// openLockEnv opens the vault of the project in the current directory,
+// printing the notices of its operations as they happen
+func openLockEnv() (*core.LockEnv, error) {
+	lockenv, err := core.New(".")
+	if err != nil {
+		return nil, err
+	}
+	lockenv.SetNotify(printNotice)
+	return lockenv, nil
+}
+
+// printNotice prints a notice passed to core.LockEnv.SetNotify. Warnings go
+// to stderr so they do not mix with the command's output.
+func printNotice(event core.FileEvent) {
+	printNoticeTo(os.Stdout, event)
+}
+
+// printNoticeTo prints a notice like printNotice, with everything but
+// warnings going to out
+func printNoticeTo(out io.Writer, event core.FileEvent) {
+	if event.Action != core.EventWarning {
+		fmt.Fprintln(out, event)
+		return
+	}
+	msg := event.Err.Error()
+	if event.Path != "" {
+		msg = event.Path + ": " + msg
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", output.Stderr().Yellow("Warning:"), msg)
+}
+
 // IsTerminal returns true if stdin is a terminal
 func IsTerminal() bool {
 	return term.IsTerminal(int(os.Stdin.Fd()))
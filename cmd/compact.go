@@ -10,7 +10,7 @@ import (
 
 // Compact compacts the .lockenv database to reclaim unused space
 func Compact(ctx context.Context) {
-	lockenv, err := core.New(".")
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
@@ -34,5 +34,16 @@ func Compact(ctx context.Context) {
 	}
 	sizeAfter := info.Size()
 
-	fmt.Printf("Compacted: %s -> %s\n", formatSize(sizeBefore), formatSize(sizeAfter))
+	fmt.Printf("Compacted: %s -> %s\n", core.FormatSize(sizeBefore), core.FormatSize(sizeAfter))
+}
+
+// autoCompact compacts the vault after a command rewrote it, unless
+// auto_compact is turned off
+func autoCompact(lockenv *core.LockEnv) {
+	if !loadConfig().AutoCompact {
+		return
+	}
+	if err := lockenv.Compact(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: compaction failed: %s\n", err)
+	}
 }
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// Complete prints completion candidates of the given kind, one per line,
+// quoted for shell. It backs the hidden `lockenv __complete` command used by
+// the completion scripts, which previously scraped `lockenv ls` output.
+// Kinds are files (vault paths), profiles (for --profile) and domains
+// (encryption domain names). None of them need the password.
+// Errors print nothing, so a missing vault just yields no candidates.
+func Complete(ctx context.Context, kind, shell, prefix string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		return
+	}
+	defer lockenv.Close()
+
+	prefix = core.UnquoteCompletionWord(prefix, shell)
+	var candidates []string
+	switch kind {
+	case "files":
+		candidates, err = lockenv.VaultFileCandidates(ctx, prefix)
+	case "profiles":
+		candidates, err = lockenv.ProfileCandidates(ctx, prefix)
+	case "domains":
+		candidates, err = lockenv.DomainCandidates(prefix)
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+	for _, c := range candidates {
+		fmt.Println(core.QuoteCompletion(c, shell))
+	}
+}
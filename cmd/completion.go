@@ -26,7 +26,7 @@ const bashCompletion = `_lockenv() {
     local cur prev words cword
     _init_completion || return
 
-    local commands="init lock unlock rm ls status passwd diff compact keyring help completion"
+    local commands="init lock track unlock deploy rm ls status which scan env render inject redact run ci show note require passwd recover diff merge link ws compact stats backup migrate push pull clean shred verify check-parity validate fsck reindex split stash guard keyring session recipient domain meta help completion shell-hook git-hooks selfupdate"
 
     if [[ $cword -eq 1 ]]; then
         COMPREPLY=($(compgen -W "$commands" -- "$cur"))
@@ -36,30 +36,326 @@ const bashCompletion = `_lockenv() {
     local cmd="${words[1]}"
     case "$cmd" in
         lock)
+            if [[ "$prev" == "--domain" ]]; then
+                _lockenv_domains
+            elif [[ "$prev" == "--as" ]]; then
+                _lockenv_vault_files
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "-r --remove -R --recursive --force --domain --no-content --allow-large --sign --strict -p --patch --show-secrets --stdin --as" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        track)
             if [[ "$cur" == -* ]]; then
-                COMPREPLY=($(compgen -W "-r --remove --force" -- "$cur"))
+                COMPREPLY=($(compgen -W "-R --recursive --allow-large" -- "$cur"))
             else
                 _filedir
             fi
             ;;
+        deploy)
+            if [[ "$prev" == "--domain" ]]; then
+                _lockenv_domains
+            elif [[ "$prev" == "--files" ]]; then
+                _lockenv_vault_files
+            elif [[ "$prev" == "--signers" ]]; then
+                _filedir
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--files --domain --preserve-mode --preserve-all --require-signature --signers" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _known_hosts_real -c -- "$cur"
+            else
+                _lockenv_vault_files
+            fi
+            ;;
         unlock)
+            if [[ "$prev" == "--domain" ]]; then
+                _lockenv_domains
+            elif [[ "$prev" == "--signers" || "$prev" == "--tar" ]]; then
+                _filedir
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--force --keep-local --keep-both --all --for --domain --preserve-mode --preserve-all --require-signature --signers --tar --strict" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        ls|status)
+            case "$prev" in
+                --filter)
+                    COMPREPLY=($(compgen -W "modified unchanged vault-only never-locked" -- "$cur"))
+                    return
+                    ;;
+                --sort)
+                    COMPREPLY=($(compgen -W "name size mtime" -- "$cur"))
+                    return
+                    ;;
+                --columns)
+                    COMPREPLY=($(compgen -W "size mtime mode hash note" -- "$cur"))
+                    return
+                    ;;
+            esac
             if [[ "$cur" == -* ]]; then
-                COMPREPLY=($(compgen -W "--force --keep-local --keep-both" -- "$cur"))
+                COMPREPLY=($(compgen -W "--full --filter --sort --columns -l --long --prompt --all --exit-code" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        env)
+            case "$prev" in
+                --format)
+                    COMPREPLY=($(compgen -W "sh fish powershell dotenv json" -- "$cur"))
+                    return
+                    ;;
+                --profile)
+                    _lockenv_profiles
+                    return
+                    ;;
+            esac
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--profile --format" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        render)
+            case "$prev" in
+                -o|--output)
+                    _filedir
+                    return
+                    ;;
+                --profile)
+                    _lockenv_profiles
+                    return
+                    ;;
+            esac
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "-o --output --profile" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _filedir
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        show)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--content" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        note)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--clear" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _lockenv_vault_files
+            fi
+            ;;
+        require)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--optional" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        inject)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--stdout" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        redact)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--stdout" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _filedir
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        run)
+            local i
+            for ((i = 2; i < cword; i++)); do
+                if [[ "${words[i]}" == "--" ]]; then
+                    COMPREPLY=($(compgen -c -- "$cur"))
+                    return
+                fi
+            done
+            if [[ "$prev" == "--profile" ]]; then
+                _lockenv_profiles
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--profile --redact --" -- "$cur"))
             else
-                # Complete with files from vault
-                local files
-                files=$(lockenv ls 2>/dev/null | grep -E '^\s+[.*]' | sed 's/^.*[.*] //' | sed 's/ (.*//')
-                COMPREPLY=($(compgen -W "$files" -- "$cur"))
+                _lockenv_vault_files
             fi
             ;;
         rm)
-            # Complete with files from vault
-            local files
-            files=$(lockenv ls 2>/dev/null | grep -E '^\s+[.*]' | sed 's/^.*[.*] //' | sed 's/ (.*//')
-            COMPREPLY=($(compgen -W "$files" -- "$cur"))
+            _lockenv_vault_files
+            ;;
+        diff)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--rev --stat --hexdump --show-secrets" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        merge)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--force --keep-local --keep-both" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        link)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--as" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _filedir -d
+            fi
+            ;;
+        ws)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "add rm list unlock" -- "$cur"))
+            elif [[ "${words[2]}" == "unlock" ]]; then
+                COMPREPLY=($(compgen -W "--force --keep-local --keep-both" -- "$cur"))
+            elif [[ "${words[2]}" == "add" || "${words[2]}" == "rm" ]]; then
+                _filedir -d
+            fi
+            ;;
+        clean|shred)
+            COMPREPLY=($(compgen -W "--force" -- "$cur"))
+            ;;
+        scan)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--include-ignored --ignore --unignore --list-ignored" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        verify)
+            if [[ "$prev" == "--signers" ]]; then
+                _filedir
+            else
+                COMPREPLY=($(compgen -W "--paths --strict --require-signature --signers" -- "$cur"))
+            fi
+            ;;
+        check-parity)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--local" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        validate)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--local" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        fsck)
+            COMPREPLY=($(compgen -W "--repair" -- "$cur"))
+            ;;
+        split)
+            if [[ "$prev" == "--output" ]]; then
+                _filedir
+            elif [[ "$prev" == "--files" ]]; then
+                _lockenv_vault_files
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--files --output --new-password" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        guard)
+            COMPREPLY=($(compgen -W "--interval" -- "$cur"))
+            ;;
+        push|pull)
+            COMPREPLY=($(compgen -W "--remote --force" -- "$cur"))
+            ;;
+        selfupdate)
+            COMPREPLY=($(compgen -W "--check" -- "$cur"))
+            ;;
+        passwd)
+            COMPREPLY=($(compgen -W "--rotate-salt-only" -- "$cur"))
+            ;;
+        recover)
+            COMPREPLY=($(compgen -W "--key --new-key" -- "$cur"))
+            ;;
+        init|migrate)
+            if [[ "$prev" == "--layout" ]]; then
+                COMPREPLY=($(compgen -W "file dir" -- "$cur"))
+            elif [[ "$cmd" == "init" ]]; then
+                COMPREPLY=($(compgen -W "--layout --recovery-key --import-existing" -- "$cur"))
+            else
+                COMPREPLY=($(compgen -W "--layout" -- "$cur"))
+            fi
+            ;;
+        backup)
+            if [[ "$prev" == "--dir" ]]; then
+                _filedir -d
+            else
+                COMPREPLY=($(compgen -W "--dir --keep" -- "$cur"))
+            fi
+            ;;
+        stash)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "pop show --snapshot" -- "$cur"))
+            elif [[ ${words[2]} == "pop" ]]; then
+                COMPREPLY=($(compgen -W "--force --snapshot" -- "$cur"))
+            fi
             ;;
         keyring)
-            COMPREPLY=($(compgen -W "save delete status" -- "$cur"))
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "save delete status" -- "$cur"))
+            elif [[ "${words[2]}" == "save" ]]; then
+                COMPREPLY=($(compgen -W "--ttl" -- "$cur"))
+            fi
+            ;;
+        session)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "start end status" -- "$cur"))
+            elif [[ "${words[2]}" == "start" ]]; then
+                COMPREPLY=($(compgen -W "--ttl" -- "$cur"))
+            fi
+            ;;
+        recipient)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "add-ssh add-kms list rm" -- "$cur"))
+            elif [[ "${words[2]}" == "add-ssh" ]]; then
+                _filedir pub
+            fi
+            ;;
+        domain)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "add list rm" -- "$cur"))
+            elif [[ $cword -eq 3 && "${words[2]}" == "rm" ]]; then
+                _lockenv_domains
+            fi
+            ;;
+        meta)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "show set unset" -- "$cur"))
+            elif [[ $cword -eq 3 && ("${words[2]}" == "set" || "${words[2]}" == "unset") ]]; then
+                COMPREPLY=($(compgen -W "description owner contact" -- "$cur"))
+            fi
+            ;;
+        ci)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "export-github export-gitlab" -- "$cur"))
+            elif [[ "$prev" == "-o" || "$prev" == "--output" ]]; then
+                _filedir
+            elif [[ "$prev" == "--profile" ]]; then
+                _lockenv_profiles
+            elif [[ "$cur" == -* ]]; then
+                if [[ "${words[2]}" == "export-gitlab" ]]; then
+                    COMPREPLY=($(compgen -W "-o --output --profile" -- "$cur"))
+                else
+                    COMPREPLY=($(compgen -W "--profile" -- "$cur"))
+                fi
+            else
+                _lockenv_vault_files
+            fi
             ;;
         help)
             COMPREPLY=($(compgen -W "$commands" -- "$cur"))
@@ -67,9 +363,34 @@ const bashCompletion = `_lockenv() {
         completion)
             COMPREPLY=($(compgen -W "bash zsh fish powershell" -- "$cur"))
             ;;
+        shell-hook)
+            COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+            ;;
+        git-hooks)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "install uninstall" -- "$cur"))
+            elif [[ "${words[2]}" == "install" ]]; then
+                COMPREPLY=($(compgen -W "--auto-unlock --stash" -- "$cur"))
+            fi
+            ;;
     esac
 }
 
+_lockenv_vault_files() {
+    local IFS=$'\n'
+    COMPREPLY=($(lockenv __complete files --shell bash "$cur" 2>/dev/null))
+}
+
+_lockenv_profiles() {
+    local IFS=$'\n'
+    COMPREPLY=($(lockenv __complete profiles --shell bash "$cur" 2>/dev/null))
+}
+
+_lockenv_domains() {
+    local IFS=$'\n'
+    COMPREPLY=($(lockenv __complete domains --shell bash "$cur" 2>/dev/null))
+}
+
 complete -F _lockenv lockenv
 `
 
@@ -80,16 +401,55 @@ _lockenv() {
     commands=(
         'init:Create a .lockenv vault in current directory'
         'lock:Encrypt and store files in the vault'
+        'track:Add files to the vault without encrypting them yet'
         'unlock:Decrypt and restore files from the vault'
+        'deploy:Unlock files onto a remote host over SSH'
         'rm:Remove files from the vault'
         'ls:Show comprehensive vault status'
         'status:Show comprehensive vault status'
+        'which:Find the dotenv files that define a variable'
+        'scan:List untracked files that look like secrets'
+        'env:Print vault variables as shell export statements'
+        'render:Render a Go template with vault variables'
+        'inject:Replace placeholders with vault values'
+        'redact:Replace vault values with placeholders'
         'passwd:Change vault password'
+        'recover:Reset a lost password with the recovery key'
         'diff:Compare vault contents with local files'
+        'merge:Merge another vault into this vault'
+        'link:Reference a file stored in another vault'
+        'ws:Unlock several vaults in one command'
         'compact:Compact vault to reclaim disk space'
+        'stats:Show how space in the vault is used'
+        'backup:Save a timestamped copy of the vault'
+        'migrate:Upgrade the vault to the current format'
+        'push:Upload the vault to a remote'
+        'pull:Download the vault from a remote'
+        'clean:Remove unlocked plaintext files that match the vault'
+        'shred:Overwrite and remove unlocked plaintext files'
+        'verify:Check vault integrity or local drift from the vault'
+        'check-parity:Report variables missing from some environment files'
+        'validate:Check secret files against the committed schema'
+        'fsck:Check the vault structure and repair it'
+        'reindex:Rebuild the plaintext index from the vault'
+        'split:Copy matching files into a new vault'
+        'stash:Lock and remove all plaintext secrets until stash pop'
+        'guard:Relock files when the unlock --for timer expires'
         'keyring:Manage password in OS keyring'
+        'session:Cache the vault key for the login session'
+        'recipient:Manage SSH and KMS keys that can unlock the vault'
+        'domain:Manage encryption domains with separate passwords'
+        'show:Show details of a file in the vault'
+        'note:Annotate a file in the vault'
+        'require:Mark files that unlock --strict must restore'
+        'meta:Describe the vault (description, owner, contact)'
+        'run:Run a command with vault variables in its environment'
+        'ci:Export vault variables to GitHub Actions or GitLab CI'
         'help:Show help for a command'
         'completion:Generate shell completions'
+        'shell-hook:Show unlocked secrets in the shell prompt'
+        'git-hooks:Remind to unlock after git checkouts and merges'
+        'selfupdate:Update lockenv to the latest release'
     )
 
     _arguments -C \
@@ -106,7 +466,26 @@ _lockenv() {
                     _arguments \
                         '-r[Remove original files after locking]' \
                         '--remove[Remove original files after locking]' \
-                        '--force[Lock without confirmation]' \
+                        '-R[Track directories and all files inside]' \
+                        '--recursive[Track directories and all files inside]' \
+                        '--force[Lock without confirmation and accept large binaries]' \
+                        '--domain[Encrypt with an encryption domain password]:domain:_lockenv_domains' \
+                        '--no-content[Track files without encrypting them]' \
+                        '--allow-large[Lock files larger than max_file_size]' \
+                        '--sign[Sign the manifest with the SSH identity]' \
+                        '--strict[Fail instead of warn on dotenv syntax issues]' \
+                        '-p[Pick the hunks of each file to lock]' \
+                        '--patch[Pick the hunks of each file to lock]' \
+                        '--show-secrets[Show values in the hunks offered by --patch]' \
+                        '--stdin[Read the contents from stdin]' \
+                        '--as[Vault path for the contents read with --stdin]:vault file:_lockenv_vault_files' \
+                        '*:file:_files'
+                    ;;
+                track)
+                    _arguments \
+                        '-R[Track directories and all files inside]' \
+                        '--recursive[Track directories and all files inside]' \
+                        '--allow-large[Track files larger than max_file_size]' \
                         '*:file:_files'
                     ;;
                 unlock)
@@ -114,13 +493,258 @@ _lockenv() {
                         '--force[Overwrite local files without asking]' \
                         '--keep-local[Skip all conflicts, keep local versions]' \
                         '--keep-both[Keep both local and vault versions]' \
+                        '--all[Unlock all files without the checklist]' \
+                        '--for[Lock the files again after this duration]:duration:' \
+                        '--domain[Also unlock files in this encryption domain]:domain:_lockenv_domains' \
+                        '--preserve-mode[Restore the exact stored mode]' \
+                        '--preserve-all[Restore the stored mode, owner and extended attributes]' \
+                        '--require-signature[Require a manifest signature from a trusted key]' \
+                        '--signers[File with the trusted signing keys]:file:_files' \
+                        '--tar[Write a tar archive to a file or - for stdout]:file:_files' \
+                        '--strict[Exit with status 1 if a required file is not restored]' \
                         '*:vault file:_lockenv_vault_files'
                     ;;
+                deploy)
+                    _arguments \
+                        '--files[Deploy only files matching the pattern]:vault file:_lockenv_vault_files' \
+                        '--domain[Also deploy files in this encryption domain]:domain:_lockenv_domains' \
+                        '--preserve-mode[Keep the exact stored mode]' \
+                        '--preserve-all[Keep the stored mode, owner and extended attributes]' \
+                        '--require-signature[Require a manifest signature from a trusted key]' \
+                        '--signers[File with the trusted signing keys]:file:_files' \
+                        '1:target:_hosts' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                ls|status)
+                    _arguments \
+                        '--full[Hash every file instead of trusting size and mtime]' \
+                        '--filter[Only list files with this status]:status:(modified unchanged vault-only never-locked)' \
+                        '--sort[Sort files]:key:(name size mtime)' \
+                        '--columns[Extra columns to show]:columns:_values -s , column size mtime mode hash note' \
+                        '(-l --long)'{-l,--long}'[Show size, mtime, mode and note columns]' \
+                        '--prompt[Print a short indicator of unlocked files for shell prompts]' \
+                        '--all[Summarize every vault under the monorepo root]' \
+                        '--exit-code[Exit with status 1 unless every file is unchanged]' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                env)
+                    _arguments \
+                        '--profile[Overlay .env.<profile> on .env]:profile:_lockenv_profiles' \
+                        '--format[Output format]:format:(sh fish powershell dotenv json)' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                render)
+                    _arguments \
+                        '(-o --output)'{-o,--output}'[Write to this file instead of stdout]:output file:_files' \
+                        '--profile[Overlay .env.<profile> on .env]:profile:_lockenv_profiles' \
+                        '1:template:_files' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                show)
+                    _arguments \
+                        '--content[Also print the decrypted content]' \
+                        '1:vault file:_lockenv_vault_files'
+                    ;;
+                note)
+                    _arguments \
+                        '--clear[Remove the note]' \
+                        '1:vault file:_lockenv_vault_files' \
+                        '*:note:'
+                    ;;
+                require)
+                    _arguments \
+                        '--optional[Mark the files optional again]' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                inject)
+                    _arguments \
+                        '--stdout[Print the result instead of rewriting the file]' \
+                        '1:file:_files'
+                    ;;
+                redact)
+                    _arguments \
+                        '--stdout[Print the result instead of rewriting the file]' \
+                        '1:file:_files' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                run)
+                    if (( ${words[(I)--]} )); then
+                        _normal
+                    else
+                        _arguments \
+                            '--profile[Overlay .env.<profile> on .env]:profile:_lockenv_profiles' \
+                            '--redact[Replace secret values in the output with ***]' \
+                            '*:vault file:_lockenv_vault_files'
+                    fi
+                    ;;
                 rm)
                     _arguments '*:vault file:_lockenv_vault_files'
                     ;;
+                diff)
+                    _arguments \
+                        '--rev[Compare the vault at a git revision]:revision:' \
+                        '--hexdump[Hexdump bytes from the first binary difference]:bytes:' \
+                        '--stat[Show changed line counts per file]' \
+                        '--show-secrets[Show secret values instead of masking them]' \
+                        '1:vault file:_lockenv_vault_files' \
+                        '2:vault file:_lockenv_vault_files'
+                    ;;
+                merge)
+                    _arguments \
+                        '--force[Use the other vault version for all conflicts]' \
+                        '--keep-local[Keep this vault version for all conflicts]' \
+                        '--keep-both[Keep both versions]' \
+                        '1:vault file:_files'
+                    ;;
+                link)
+                    _arguments \
+                        '--as[Path of the reference in this vault]:path:' \
+                        '1:vault directory:_directories' \
+                        '2:file:'
+                    ;;
+                ws)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' add rm list unlock
+                    elif [[ "${words[3]}" == "unlock" ]]; then
+                        _arguments \
+                            '(--keep-local --keep-both)--force[Overwrite local files]' \
+                            '(--force --keep-both)--keep-local[Keep local versions]' \
+                            '(--force --keep-local)--keep-both[Keep both versions]'
+                    elif [[ "${words[3]}" == "add" || "${words[3]}" == "rm" ]]; then
+                        _directories
+                    fi
+                    ;;
+                clean|shred)
+                    _arguments '--force[Also remove files that differ from the vault]'
+                    ;;
+                scan)
+                    _arguments \
+                        '--include-ignored[Also list files marked as not secret]' \
+                        '--ignore[Mark paths or globs as not secret]' \
+                        '--unignore[Remove the not-secret mark]' \
+                        '--list-ignored[Print the paths marked as not secret]' \
+                        '*:file:_files'
+                    ;;
+                verify)
+                    _arguments \
+                        '--paths[Compare local files with the vault without a password]' \
+                        '--strict[Also fail if tracked files are missing]' \
+                        '--require-signature[Require a manifest signature from a trusted key]' \
+                        '--signers[File with the trusted signing keys]:file:_files' \
+                        '*:file:_files'
+                    ;;
+                check-parity)
+                    _arguments \
+                        '--local[Compare the working-tree files without a password]' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                validate)
+                    _arguments \
+                        '--local[Check the working-tree files without a password]' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                fsck)
+                    _arguments \
+                        '--repair[Fix the issues found]'
+                    ;;
+                split)
+                    _arguments \
+                        '--files[Copy the files matching this pattern]:vault file:_lockenv_vault_files' \
+                        '--output[Path of the new vault]:file:_files' \
+                        '--new-password[Encrypt the new vault with a different password]' \
+                        '*:vault file:_lockenv_vault_files'
+                    ;;
+                guard)
+                    _arguments '--interval[How often to check the relock timer]:duration:'
+                    ;;
+                selfupdate)
+                    _arguments '--check[Only report whether a newer release is available]'
+                    ;;
+                passwd)
+                    _arguments '--rotate-salt-only[Keep the password and renew the salt and iterations]'
+                    ;;
+                recover)
+                    _arguments \
+                        '--key[Recovery key printed by init --recovery-key]:recovery key:' \
+                        '--new-key[Create a new recovery key with the current password]'
+                    ;;
+                init)
+                    _arguments \
+                        '--layout[Keep file contents in the vault file or in .lockenv.d]:layout:(file dir)' \
+                        '--recovery-key[Print a one-time recovery key that can reset a lost password]' \
+                        '--import-existing[Propose files that look like secrets and lock the selected ones]'
+                    ;;
+                migrate)
+                    _arguments '--layout[Keep file contents in the vault file or in .lockenv.d]:layout:(file dir)'
+                    ;;
+                backup)
+                    _arguments \
+                        '--dir[Directory to store backups in]:directory:_files -/' \
+                        '--keep[Number of backups to keep]:count:'
+                    ;;
+                push|pull)
+                    _arguments \
+                        '--remote[Remote URL]:url:' \
+                        '--force[Ignore changes on the other side]'
+                    ;;
+                stash)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' pop show
+                        _arguments '--snapshot[Snapshot local edits, leaving files and vault as they are]'
+                    elif [[ ${words[3]} == "pop" ]]; then
+                        _arguments \
+                            '--force[Overwrite local files without asking]' \
+                            '--snapshot[Restore the snapshot taken with --snapshot]'
+                    fi
+                    ;;
                 keyring)
-                    _values 'subcommand' save delete status
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' save delete status
+                    elif [[ "${words[3]}" == "save" ]]; then
+                        _arguments '--ttl[Invalidate the stored password after this duration]:duration:'
+                    fi
+                    ;;
+                session)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' start end status
+                    elif [[ "${words[3]}" == "start" ]]; then
+                        _arguments '--ttl[How long the session stays valid]:duration:'
+                    fi
+                    ;;
+                recipient)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' add-ssh add-kms list rm
+                    elif [[ "${words[3]}" == "add-ssh" ]]; then
+                        _files -g '*.pub'
+                    fi
+                    ;;
+                domain)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' add list rm
+                    elif (( CURRENT == 4 )) && [[ "${words[3]}" == "rm" ]]; then
+                        _lockenv_domains
+                    fi
+                    ;;
+                meta)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' show set unset
+                    elif (( CURRENT == 4 )) && [[ "${words[3]}" == (set|unset) ]]; then
+                        _values 'field' description owner contact
+                    fi
+                    ;;
+                ci)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' export-github export-gitlab
+                    elif [[ "${words[3]}" == "export-gitlab" ]]; then
+                        _arguments \
+                            '(-o --output)'{-o,--output}'[Write the report to this file]:output file:_files' \
+                            '--profile[Overlay .env.<profile> on .env]:profile:_lockenv_profiles' \
+                            '*:vault file:_lockenv_vault_files'
+                    else
+                        _arguments \
+                            '--profile[Overlay .env.<profile> on .env]:profile:_lockenv_profiles' \
+                            '*:vault file:_lockenv_vault_files'
+                    fi
                     ;;
                 help)
                     _describe -t commands 'lockenv commands' commands
@@ -128,6 +752,18 @@ _lockenv() {
                 completion)
                     _values 'shell' bash zsh fish powershell
                     ;;
+                shell-hook)
+                    _values 'shell' bash zsh fish
+                    ;;
+                git-hooks)
+                    if (( CURRENT == 3 )); then
+                        _values 'subcommand' install uninstall
+                    elif [[ "${words[3]}" == "install" ]]; then
+                        _arguments \
+                            '--auto-unlock[Unlock changed vaults while a session is active]' \
+                            '--stash[Snapshot local edits before rebases and automatic unlocks]'
+                    fi
+                    ;;
             esac
             ;;
     esac
@@ -135,8 +771,20 @@ _lockenv() {
 
 _lockenv_vault_files() {
     local -a files
-    files=(${(f)"$(lockenv ls 2>/dev/null | grep -E '^\s+[.*]' | sed 's/^.*[.*] //' | sed 's/ (.*//')"})
-    _describe -t files 'vault files' files
+    files=(${(f)"$(lockenv __complete files --shell zsh 2>/dev/null)"})
+    _wanted files expl 'vault file' compadd -a files
+}
+
+_lockenv_profiles() {
+    local -a profiles
+    profiles=(${(f)"$(lockenv __complete profiles --shell zsh 2>/dev/null)"})
+    _wanted profiles expl 'profile' compadd -a profiles
+}
+
+_lockenv_domains() {
+    local -a domains
+    domains=(${(f)"$(lockenv __complete domains --shell zsh 2>/dev/null)"})
+    _wanted domains expl 'domain' compadd -a domains
 }
 
 _lockenv "$@"
@@ -144,51 +792,309 @@ _lockenv "$@"
 
 const fishCompletion = `# lockenv fish completions
 
-set -l commands init lock unlock rm ls status passwd diff compact keyring help completion
+set -l commands init lock track unlock deploy rm ls status which scan env render inject redact run ci show note require passwd recover diff merge link ws compact stats backup migrate push pull clean shred verify check-parity validate fsck reindex split stash guard keyring session recipient domain meta help completion shell-hook git-hooks selfupdate
 
 complete -c lockenv -f
 
 # Commands
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a init -d 'Create a .lockenv vault'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a lock -d 'Encrypt and store files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a track -d 'Add files without encrypting'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a unlock -d 'Decrypt and restore files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a deploy -d 'Unlock files onto a remote host over SSH'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a rm -d 'Remove files from vault'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a ls -d 'Show vault status'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a status -d 'Show vault status'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a which -d 'Find files defining a variable'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a scan -d 'List files that look like secrets'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a env -d 'Print variables as export statements'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a render -d 'Render a template with vault variables'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a inject -d 'Replace placeholders with vault values'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a redact -d 'Replace vault values with placeholders'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a passwd -d 'Change vault password'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a recover -d 'Reset a lost password'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a diff -d 'Compare vault with local'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a merge -d 'Merge another vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a link -d 'Reference a file in another vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a ws -d 'Unlock several vaults in one command'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a compact -d 'Compact vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a stats -d 'Show vault space usage'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a backup -d 'Save a copy of the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a migrate -d 'Upgrade the vault format'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a push -d 'Upload vault to a remote'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a pull -d 'Download vault from a remote'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a clean -d 'Remove unlocked plaintext files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a shred -d 'Overwrite and remove plaintext files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a verify -d 'Check vault integrity or local drift'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a check-parity -d 'Report variables missing from some env files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a validate -d 'Check secret files against the schema'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a fsck -d 'Check the vault structure'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a reindex -d 'Rebuild the plaintext index'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a split -d 'Copy matching files into a new vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a stash -d 'Lock and remove plaintext secrets'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a guard -d 'Relock files when unlock timer expires'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a keyring -d 'Manage password in OS keyring'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a session -d 'Cache vault key for login session'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a recipient -d 'Manage SSH and KMS recipients'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a domain -d 'Manage encryption domains'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a show -d 'Show details of a file in the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a note -d 'Annotate a file in the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a require -d 'Mark files unlock --strict must restore'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a meta -d 'Describe the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a run -d 'Run a command with vault variables'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a ci -d 'Export variables to GitHub Actions or GitLab CI'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a help -d 'Show help'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a completion -d 'Generate completions'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a shell-hook -d 'Show unlocked secrets in prompt'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a git-hooks -d 'Remind to unlock after checkouts'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a selfupdate -d 'Update lockenv to the latest release'
 
 # lock flags and files
 complete -c lockenv -n "__fish_seen_subcommand_from lock" -s r -d 'Remove original files'
 complete -c lockenv -n "__fish_seen_subcommand_from lock" -l remove -d 'Remove original files'
-complete -c lockenv -n "__fish_seen_subcommand_from lock" -l force -d 'Lock without confirmation'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -s R -l recursive -d 'Track directories recursively'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l force -d 'Lock without confirmation, accept large binaries'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l domain -x -a "(lockenv __complete domains --shell fish 2>/dev/null)" -d 'Encrypt with a domain password'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l no-content -d 'Track files without encrypting'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l allow-large -d 'Lock files larger than max_file_size'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l sign -d 'Sign the manifest with the SSH identity'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l strict -d 'Fail instead of warn on dotenv syntax issues'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -s p -l patch -d 'Pick the hunks of each file to lock'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l show-secrets -d 'Show values in the hunks offered by --patch'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l stdin -d 'Read the contents from stdin'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l as -x -a "(lockenv __complete files --shell fish 2>/dev/null)" -d 'Vault path for the contents read with --stdin'
 complete -c lockenv -n "__fish_seen_subcommand_from lock" -F
 
+# track flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from track" -s R -l recursive -d 'Track directories recursively'
+complete -c lockenv -n "__fish_seen_subcommand_from track" -l allow-large -d 'Track files larger than max_file_size'
+complete -c lockenv -n "__fish_seen_subcommand_from track" -F
+
 # unlock flags
 complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l force -d 'Overwrite local files'
 complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l keep-local -d 'Keep local versions'
 complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l keep-both -d 'Keep both versions'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l all -d 'Unlock all files without the checklist'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l for -r -d 'Lock the files again after duration'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l domain -x -a "(lockenv __complete domains --shell fish 2>/dev/null)" -d 'Also unlock files in this domain'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l preserve-mode -d 'Restore the exact stored mode'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l preserve-all -d 'Restore the stored mode, owner and extended attributes'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l require-signature -d 'Require a trusted manifest signature'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l signers -r -F -d 'Trusted signing keys'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l tar -r -F -d 'Write a tar archive to a file or - for stdout'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l strict -d 'Fail if a required file is not restored'
+
+# deploy flags
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l files -x -a "(lockenv __complete files --shell fish 2>/dev/null)" -d 'Deploy only files matching the pattern'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l domain -x -a "(lockenv __complete domains --shell fish 2>/dev/null)" -d 'Also deploy files in this domain'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l preserve-mode -d 'Keep the exact stored mode'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l preserve-all -d 'Keep the stored mode, owner and extended attributes'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l require-signature -d 'Require a trusted manifest signature'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l signers -r -F -d 'Trusted signing keys'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -a "(__fish_print_hostnames)"
+
+# vault file arguments
+complete -c lockenv -n "__fish_seen_subcommand_from unlock rm env ls status; and not __fish_seen_subcommand_from keyring session recipient domain ws" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# status flags
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l full -d 'Hash every file'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l filter -x -a "modified unchanged vault-only never-locked" -d 'Only list files with this status'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l sort -x -a "name size mtime" -d 'Sort files'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l columns -x -a "size mtime mode hash note" -d 'Extra columns to show'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -s l -l long -d 'Show size, mtime, mode and note columns'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l prompt -d 'Short indicator for shell prompts'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l all -d 'Summarize every vault under the monorepo root'
+complete -c lockenv -n "__fish_seen_subcommand_from status; and not __fish_seen_subcommand_from keyring session" -l exit-code -d 'Exit with status 1 unless every file is unchanged'
+
+# env flags
+complete -c lockenv -n "__fish_seen_subcommand_from env" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from env" -l format -x -a "sh fish powershell dotenv json" -d 'Output format'
+
+# render flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from render" -s o -l output -r -F -d 'Write to this file instead of stdout'
+complete -c lockenv -n "__fish_seen_subcommand_from render" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from render" -F
+complete -c lockenv -n "__fish_seen_subcommand_from render" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# inject and redact flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from inject redact" -l stdout -d 'Print the result instead of rewriting the file'
+complete -c lockenv -n "__fish_seen_subcommand_from inject redact" -F
+complete -c lockenv -n "__fish_seen_subcommand_from redact" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# diff flags
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l rev -r -d 'Compare vault at a git revision'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l hexdump -r -d 'Hexdump bytes from the first binary difference'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l show-secrets -d 'Show secret values instead of masking them'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l stat -d 'Show changed line counts per file'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# merge flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -l force -d 'Use other vault version'
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -l keep-local -d 'Keep this vault version'
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -l keep-both -d 'Keep both versions'
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -F
+
+# link flags
+complete -c lockenv -n "__fish_seen_subcommand_from link" -l as -r -d 'Path of the reference in this vault'
+complete -c lockenv -n "__fish_seen_subcommand_from link" -a "(__fish_complete_directories)"
+
+# clean/shred flags
+complete -c lockenv -n "__fish_seen_subcommand_from clean shred" -l force -d 'Also remove modified files'
+
+# scan flags
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l include-ignored -d 'Also list files marked as not secret'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l ignore -d 'Mark paths as not secret'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l unignore -d 'Remove the not-secret mark'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l list-ignored -d 'Print paths marked as not secret'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -F
+
+# verify flags
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l paths -d 'Compare local files without a password'
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l strict -d 'Also fail if tracked files are missing'
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l require-signature -d 'Require a trusted manifest signature'
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l signers -r -F -d 'Trusted signing keys'
+
+# check-parity flags
+complete -c lockenv -n "__fish_seen_subcommand_from check-parity" -l local -d 'Compare the working-tree files'
+complete -c lockenv -n "__fish_seen_subcommand_from check-parity" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# validate flags
+complete -c lockenv -n "__fish_seen_subcommand_from validate" -l local -d 'Check the working-tree files'
+complete -c lockenv -n "__fish_seen_subcommand_from validate" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# fsck flags
+complete -c lockenv -n "__fish_seen_subcommand_from fsck" -l repair -d 'Fix the issues found'
+
+# split flags
+complete -c lockenv -n "__fish_seen_subcommand_from split" -l files -x -a "(lockenv __complete files --shell fish 2>/dev/null)" -d 'Copy the files matching this pattern'
+complete -c lockenv -n "__fish_seen_subcommand_from split" -l output -r -F -d 'Path of the new vault'
+complete -c lockenv -n "__fish_seen_subcommand_from split" -l new-password -d 'Use a different password'
+
+# init/migrate flags
+complete -c lockenv -n "__fish_seen_subcommand_from init migrate" -l layout -x -a "file dir" -d 'Keep file contents in the vault file or in .lockenv.d'
+complete -c lockenv -n "__fish_seen_subcommand_from init" -l import-existing -d 'Propose files that look like secrets and lock the selected ones'
+complete -c lockenv -n "__fish_seen_subcommand_from init" -l recovery-key -d 'Print a one-time recovery key'
+
+# passwd flags
+complete -c lockenv -n "__fish_seen_subcommand_from passwd" -l rotate-salt-only -d 'Keep the password, renew the salt'
+
+# recover flags
+complete -c lockenv -n "__fish_seen_subcommand_from recover" -l key -x -d 'Recovery key printed by init --recovery-key'
+complete -c lockenv -n "__fish_seen_subcommand_from recover" -l new-key -d 'Create a new recovery key'
+
+# backup flags
+complete -c lockenv -n "__fish_seen_subcommand_from backup" -l dir -r -a "(__fish_complete_directories)" -d 'Directory to store backups in'
+complete -c lockenv -n "__fish_seen_subcommand_from backup" -l keep -r -d 'Number of backups to keep'
+
+# selfupdate flags
+complete -c lockenv -n "__fish_seen_subcommand_from selfupdate" -l check -d 'Only check for a newer release'
+
+# push/pull flags
+complete -c lockenv -n "__fish_seen_subcommand_from push pull" -l remote -r -d 'Remote URL'
+complete -c lockenv -n "__fish_seen_subcommand_from push pull" -l force -d 'Ignore changes on the other side'
+
+# guard flags
+complete -c lockenv -n "__fish_seen_subcommand_from guard" -l interval -r -d 'How often to check the timer'
+
+# stash subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from stash; and not __fish_seen_subcommand_from pop show" -a "pop show"
+complete -c lockenv -n "__fish_seen_subcommand_from pop" -l force -d 'Overwrite local files without asking'
+complete -c lockenv -n "__fish_seen_subcommand_from stash" -l snapshot -d 'Snapshot local edits, or restore the snapshot with pop'
 
 # keyring subcommands
-complete -c lockenv -n "__fish_seen_subcommand_from keyring" -a "save delete status"
+complete -c lockenv -n "__fish_seen_subcommand_from keyring; and not __fish_seen_subcommand_from save delete status" -a "save delete status"
+complete -c lockenv -n "__fish_seen_subcommand_from save" -l ttl -r -d 'Invalidate password after duration'
+
+# session subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from session; and not __fish_seen_subcommand_from start end status" -a "start end status"
+complete -c lockenv -n "__fish_seen_subcommand_from start" -l ttl -r -d 'How long the session stays valid'
+
+# ws subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from ws; and not __fish_seen_subcommand_from add rm list unlock" -a "add rm list unlock"
+complete -c lockenv -n "__fish_seen_subcommand_from ws; and __fish_seen_subcommand_from add rm" -a "(__fish_complete_directories)"
+
+# recipient subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from recipient; and not __fish_seen_subcommand_from add-ssh add-kms list rm" -a "add-ssh add-kms list rm"
+complete -c lockenv -n "__fish_seen_subcommand_from add-ssh" -F
+
+# domain subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from domain; and not __fish_seen_subcommand_from add list rm" -a "add list rm"
+complete -c lockenv -n "__fish_seen_subcommand_from domain; and __fish_seen_subcommand_from rm" -a "(lockenv __complete domains --shell fish 2>/dev/null)"
+
+# run flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from run; and not contains -- -- (commandline -opc)" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from run; and not contains -- -- (commandline -opc)" -l redact -d 'Replace secret values in the output with ***'
+complete -c lockenv -n "__fish_seen_subcommand_from run; and not contains -- -- (commandline -opc)" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+complete -c lockenv -n "__fish_seen_subcommand_from run; and contains -- -- (commandline -opc)" -a "(__fish_complete_command)"
+
+# show flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from show" -l content -d 'Also print the decrypted content'
+complete -c lockenv -n "__fish_seen_subcommand_from show" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# note flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from note" -l clear -d 'Remove the note'
+complete -c lockenv -n "__fish_seen_subcommand_from note" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# require flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from require" -l optional -d 'Mark the files optional again'
+complete -c lockenv -n "__fish_seen_subcommand_from require" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# meta subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from meta; and not __fish_seen_subcommand_from show set unset" -a "show set unset"
+complete -c lockenv -n "__fish_seen_subcommand_from meta; and __fish_seen_subcommand_from set unset; and not __fish_seen_subcommand_from description owner contact" -a "description owner contact"
+
+# ci subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from ci; and not __fish_seen_subcommand_from export-github export-gitlab" -a "export-github export-gitlab"
+complete -c lockenv -n "__fish_seen_subcommand_from export-github export-gitlab" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from export-gitlab" -s o -l output -r -F -d 'Write the report to this file'
+complete -c lockenv -n "__fish_seen_subcommand_from export-github export-gitlab" -a "(lockenv __complete files --shell fish 2>/dev/null)"
 
 # help completions
 complete -c lockenv -n "__fish_seen_subcommand_from help" -a "$commands"
 
 # completion completions
 complete -c lockenv -n "__fish_seen_subcommand_from completion" -a "bash zsh fish powershell"
+
+# shell-hook completions
+complete -c lockenv -n "__fish_seen_subcommand_from shell-hook" -a "bash zsh fish"
+
+# git-hooks subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from git-hooks; and not __fish_seen_subcommand_from install uninstall" -a "install uninstall"
+complete -c lockenv -n "__fish_seen_subcommand_from git-hooks; and __fish_seen_subcommand_from install" -l auto-unlock -d 'Unlock changed vaults while a session is active'
+complete -c lockenv -n "__fish_seen_subcommand_from git-hooks; and __fish_seen_subcommand_from install" -l stash -d 'Snapshot local edits before rebases and automatic unlocks'
 `
 
 const powershellCompletion = `Register-ArgumentCompleter -Native -CommandName lockenv -ScriptBlock {
     param($wordToComplete, $commandAst, $cursorPosition)
 
-    $commands = @('init', 'lock', 'unlock', 'rm', 'ls', 'status', 'passwd', 'diff', 'compact', 'keyring', 'help', 'completion')
+    $commands = @('init', 'lock', 'track', 'unlock', 'deploy', 'rm', 'ls', 'status', 'which', 'scan', 'env', 'render', 'inject', 'redact', 'run', 'ci', 'show', 'note', 'require', 'passwd', 'recover', 'diff', 'merge', 'link', 'ws', 'compact', 'stats', 'backup', 'migrate', 'push', 'pull', 'clean', 'shred', 'verify', 'check-parity', 'validate', 'fsck', 'reindex', 'split', 'stash', 'guard', 'keyring', 'session', 'recipient', 'domain', 'meta', 'help', 'completion', 'shell-hook', 'git-hooks', 'selfupdate')
     $keyringCmds = @('save', 'delete', 'status')
+    $sessionCmds = @('start', 'end', 'status')
+    $wsCmds = @('add', 'rm', 'list', 'unlock')
+    $recipientCmds = @('add-ssh', 'add-kms', 'list', 'rm')
+    $domainCmds = @('add', 'list', 'rm')
+    $ciCmds = @('export-github', 'export-gitlab')
+    $metaCmds = @('show', 'set', 'unset')
+    $stashCmds = @('pop', 'show')
     $shells = @('bash', 'zsh', 'fish', 'powershell')
+    $vaultFiles = {
+        param($prefix)
+        lockenv __complete files --shell powershell $prefix 2>$null | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    }
+    $profiles = {
+        param($prefix)
+        lockenv __complete profiles --shell powershell $prefix 2>$null | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    }
+    $domains = {
+        param($prefix)
+        lockenv __complete domains --shell powershell $prefix 2>$null | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    }
 
     $tokens = $commandAst.ToString() -split '\s+'
 
@@ -202,24 +1108,382 @@ const powershellCompletion = `Register-ArgumentCompleter -Native -CommandName lo
     $cmd = $tokens[1]
     switch ($cmd) {
         'lock' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--domain') {
+                & $domains $wordToComplete
+            } elseif ($prev -eq '--as') {
+                & $vaultFiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('-r', '--remove', '-R', '--recursive', '--force', '--domain', '--no-content', '--allow-large', '--sign', '--strict', '-p', '--patch', '--show-secrets', '--stdin', '--as') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'track' {
             if ($wordToComplete -like '-*') {
-                @('-r', '--remove', '--force') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                @('-R', '--recursive', '--allow-large') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                     [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
                 }
             }
         }
         'unlock' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--domain') {
+                & $domains $wordToComplete
+            } elseif ($prev -eq '--signers' -or $prev -eq '--tar') {
+                return
+            } elseif ($wordToComplete -like '-*') {
+                @('--force', '--keep-local', '--keep-both', '--all', '--for', '--domain', '--preserve-mode', '--preserve-all', '--require-signature', '--signers', '--tar', '--strict') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'deploy' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--domain') {
+                & $domains $wordToComplete
+            } elseif ($prev -eq '--files') {
+                & $vaultFiles $wordToComplete
+            } elseif ($prev -eq '--signers') {
+                return
+            } elseif ($wordToComplete -like '-*') {
+                @('--files', '--domain', '--preserve-mode', '--preserve-all', '--require-signature', '--signers') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'rm' {
+            & $vaultFiles $wordToComplete
+        }
+        { $_ -in 'ls', 'status' } {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--filter') {
+                @('modified', 'unchanged', 'vault-only', 'never-locked') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--sort') {
+                @('name', 'size', 'mtime') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--columns') {
+                @('size', 'mtime', 'mode', 'hash', 'note') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($wordToComplete -like '-*') {
+                @('--full', '--filter', '--sort', '--columns', '-l', '--long', '--prompt', '--all', '--exit-code') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'env' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--format') {
+                @('sh', 'fish', 'powershell', 'dotenv', 'json') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('--profile', '--format') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'render' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('-o', '--output', '--profile') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'inject', 'redact' } {
+            if ($wordToComplete -like '-*') {
+                @('--stdout') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'diff' {
+            if ($wordToComplete -like '-*') {
+                @('--rev', '--stat', '--hexdump', '--show-secrets') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'merge' {
             if ($wordToComplete -like '-*') {
                 @('--force', '--keep-local', '--keep-both') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                     [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
                 }
             }
         }
+        'link' {
+            if ($wordToComplete -like '-*') {
+                @('--as') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'clean', 'shred' } {
+            if ($wordToComplete -like '-*') {
+                @('--force') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'scan' {
+            if ($wordToComplete -like '-*') {
+                @('--include-ignored', '--ignore', '--unignore', '--list-ignored') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'verify' {
+            if ($wordToComplete -like '-*') {
+                @('--paths', '--strict', '--require-signature', '--signers') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'check-parity' {
+            if ($wordToComplete -like '-*') {
+                @('--local') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'validate' {
+            if ($wordToComplete -like '-*') {
+                @('--local') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'split' {
+            if ($wordToComplete -like '-*') {
+                @('--files', '--output', '--new-password') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'fsck' {
+            if ($wordToComplete -like '-*') {
+                @('--repair') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'push', 'pull' } {
+            if ($wordToComplete -like '-*') {
+                @('--remote', '--force') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'init', 'migrate' } {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--layout') {
+                @('file', 'dir') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($wordToComplete -like '-*') {
+                $flags = if ($cmd -eq 'init') { @('--layout', '--recovery-key', '--import-existing') } else { @('--layout') }
+                $flags | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'passwd' {
+            if ($wordToComplete -like '-*') {
+                @('--rotate-salt-only') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'recover' {
+            if ($wordToComplete -like '-*') {
+                @('--key', '--new-key') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'backup' {
+            if ($wordToComplete -like '-*') {
+                @('--dir', '--keep') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'selfupdate' {
+            if ($wordToComplete -like '-*') {
+                @('--check') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'guard' {
+            if ($wordToComplete -like '-*') {
+                @('--interval') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'stash' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'pop' -and $wordToComplete -like '-*') {
+                @('--force', '--snapshot') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            if ($wordToComplete -like '-*') {
+                @('--snapshot') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            $stashCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
         'keyring' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'save' -and $wordToComplete -like '-*') {
+                @('--ttl') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
             $keyringCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
             }
         }
+        'session' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'start' -and $wordToComplete -like '-*') {
+                @('--ttl') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            $sessionCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'ws' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'unlock' -and $wordToComplete -like '-*') {
+                @('--force', '--keep-local', '--keep-both') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $wsCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            }
+        }
+        'recipient' {
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $recipientCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            }
+        }
+        'domain' {
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $domainCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($tokens[2] -eq 'rm' -and ($tokens.Count -eq 3 -or ($tokens.Count -eq 4 -and $wordToComplete -ne ''))) {
+                & $domains $wordToComplete
+            }
+        }
+        'run' {
+            if (($tokens | Select-Object -Skip 2) -contains '--') {
+                return
+            }
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('--profile', '--redact', '--') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'show' {
+            if ($wordToComplete -like '-*') {
+                @('--content') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'note' {
+            if ($wordToComplete -like '-*') {
+                @('--clear') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } elseif ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'require' {
+            if ($wordToComplete -like '-*') {
+                @('--optional') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'meta' {
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $metaCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($tokens[2] -in 'set', 'unset' -and ($tokens.Count -eq 3 -or ($tokens.Count -eq 4 -and $wordToComplete -ne ''))) {
+                @('description', 'owner', 'contact') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            }
+        }
+        'ci' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $ciCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                $flags = @('--profile')
+                if ($tokens[2] -eq 'export-gitlab') {
+                    $flags = @('-o', '--output', '--profile')
+                }
+                $flags | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
         'help' {
             $commands | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
@@ -230,6 +1494,22 @@ const powershellCompletion = `Register-ArgumentCompleter -Native -CommandName lo
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
             }
         }
+        'shell-hook' {
+            @('bash', 'zsh', 'fish') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'git-hooks' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'install' -and $wordToComplete -like '-*') {
+                @('--auto-unlock', '--stash') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            @('install', 'uninstall') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
     }
 }
 `
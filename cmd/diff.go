@@ -2,14 +2,21 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/illarion/lockenv/internal/core"
 	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/output"
 )
 
-// Diff compares .lockenv contents with local files
-func Diff(ctx context.Context) {
-	lockenv, err := core.New(".")
+// Diff compares .lockenv contents with local files.
+// If rev is set, compares the vault at that git revision with the current vault instead.
+// hexdump is the number of bytes to hexdump from the first difference in binary files.
+// showSecrets reveals values that are masked with **** by default.
+// stat prints a summary of changed lines per file instead of the diff.
+// With two files, compares those vault files with each other instead.
+func Diff(ctx context.Context, files []string, rev string, hexdump int, showSecrets bool, stat bool) {
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
@@ -19,14 +26,104 @@ func Diff(ctx context.Context) {
 	vaultID, _ := lockenv.GetVaultID()
 
 	// Get password with retry on stale keyring
-	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv.VerifyPassword)
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
 	if err != nil {
 		HandleError(err)
 	}
 	defer crypto.ClearBytes(password)
 
+	opts := core.DiffOptions{HexdumpBytes: hexdump, ShowSecrets: showSecrets, Stat: stat}
+
+	// Compare two files of the vault, e.g. two environments
+	if len(files) == 2 {
+		result, err := lockenv.DiffFiles(ctx, password, files[0], files[1], opts)
+		if err != nil {
+			HandleError(err)
+		}
+		printFilesDiff(files[0], files[1], result)
+		return
+	}
+
+	// Show diff against a historical vault
+	if rev != "" {
+		result, err := lockenv.DiffRevision(ctx, password, rev, opts)
+		if err != nil {
+			HandleError(err)
+		}
+		printDiff(result, "No changes since "+rev, func(change string) string {
+			if change == core.ChangeAdded {
+				return "Added since " + rev
+			}
+			return "Removed since " + rev
+		})
+		return
+	}
+
 	// Show diff
-	if err := lockenv.Diff(ctx, password); err != nil {
+	result, err := lockenv.Diff(ctx, password, opts)
+	if err != nil {
 		HandleError(err)
 	}
+	printDiff(result, "No changes detected", func(string) string {
+		return "File not in working directory"
+	})
+}
+
+// printDiff prints the outcome of Diff or DiffRevision: files that could not
+// be compared, then each changed file, then the stat summary. label names
+// files that were added, removed or missing rather than modified. none is
+// printed if nothing changed.
+func printDiff(result *core.DiffResult, none string, label func(change string) string) {
+	p := output.Stdout()
+	printEvents(result.Events)
+	for _, file := range result.Files {
+		if file.Change == core.ChangeModified {
+			fmt.Print(p.Diff(file.Diff))
+			continue
+		}
+		fmt.Printf("%s: %s\n", label(file.Change), file.Path)
+	}
+	if len(result.Stats) > 0 {
+		fmt.Print(core.FormatDiffStat(result.Stats, p))
+	}
+	if !result.Changed() {
+		fmt.Println(none)
+	}
+}
+
+// printFilesDiff prints the outcome of DiffFiles: the keys defined in only
+// one of the files, then their diff or stat summary
+func printFilesDiff(pathA, pathB string, result *core.DiffResult) {
+	if !result.Changed() {
+		fmt.Printf("%s and %s are identical\n", pathA, pathB)
+		return
+	}
+
+	p := output.Stdout()
+	if drift := result.Drift; drift != nil {
+		for _, side := range []struct {
+			path string
+			keys []string
+			sign string
+		}{{pathA, drift.OnlyA, "-"}, {pathB, drift.OnlyB, "+"}} {
+			if len(side.keys) == 0 {
+				continue
+			}
+			fmt.Printf("Keys only in %s (%d):\n", side.path, len(side.keys))
+			for _, key := range side.keys {
+				fmt.Printf("   %s\n", p.Diff(side.sign+" "+key))
+			}
+		}
+		if len(drift.OnlyA) == 0 && len(drift.OnlyB) == 0 {
+			fmt.Println("Both files define the same keys")
+		}
+		fmt.Println()
+	}
+
+	if len(result.Stats) > 0 {
+		fmt.Print(core.FormatDiffStat(result.Stats, p))
+	}
+	for _, file := range result.Files {
+		fmt.Print(p.Diff(file.Diff))
+	}
 }
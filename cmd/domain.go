@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// getDomainPassword reads a domain password from LOCKENV_DOMAIN_PASSWORD or
+// prompts for it. The caller is responsible for clearing the result.
+func getDomainPassword(name string) ([]byte, error) {
+	if password := os.Getenv("LOCKENV_DOMAIN_PASSWORD"); password != "" {
+		return []byte(password), nil
+	}
+	return core.ReadPassword(fmt.Sprintf("Enter password for domain %s: ", name))
+}
+
+// unlockDomain asks for the domain password and unlocks the domain on lockenv
+func unlockDomain(lockenv *core.LockEnv, name string) {
+	domainPassword, err := getDomainPassword(name)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(domainPassword)
+
+	if err := lockenv.UseDomain(name, domainPassword); err != nil {
+		handleDomainError(err)
+	}
+}
+
+// DomainAdd creates an encryption domain with its own password
+func DomainAdd(name string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	var domainPassword []byte
+	if env := os.Getenv("LOCKENV_DOMAIN_PASSWORD"); env != "" {
+		domainPassword = []byte(env)
+	} else {
+		fmt.Printf("New password for domain %s\n", name)
+		domainPassword, err = core.ReadPasswordConfirm()
+		if err != nil {
+			HandleError(err)
+		}
+	}
+	defer crypto.ClearBytes(domainPassword)
+
+	if crypto.ConstantTimeCompare(password, domainPassword) {
+		fmt.Fprintln(os.Stderr, "Error: domain password must differ from the vault password")
+		os.Exit(1)
+	}
+
+	lockenv.SetKDFTargetTime(loadConfig().KDFTargetTime)
+	if err := lockenv.AddDomain(password, name, domainPassword); err != nil {
+		handleDomainError(err)
+	}
+
+	fmt.Printf("added domain: %s\n", name)
+	fmt.Printf("Use 'lockenv lock --domain %s <file>' to move files into it\n", name)
+}
+
+// DomainList shows the vault's encryption domains
+func DomainList() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	domains, err := lockenv.ListDomains()
+	if err != nil {
+		HandleError(err)
+	}
+
+	if len(domains) == 0 {
+		fmt.Println("No domains")
+		return
+	}
+
+	for _, d := range domains {
+		fmt.Println(d.Name)
+	}
+}
+
+// DomainRemove deletes an encryption domain that has no files
+func DomainRemove(name string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if err := lockenv.RemoveDomain(password, name); err != nil {
+		handleDomainError(err)
+	}
+
+	fmt.Printf("removed domain: %s\n", name)
+}
+
+// handleDomainError prints domain errors with a hint, falling back to HandleError
+func handleDomainError(err error) {
+	switch {
+	case errors.Is(err, core.ErrDomainNotFound):
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		fmt.Fprintln(os.Stderr, "Use 'lockenv domain list' to see domains")
+	case errors.Is(err, core.ErrDomainInUse):
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		fmt.Fprintln(os.Stderr, "Remove the domain's files with 'lockenv rm' first")
+	default:
+		HandleError(err)
+	}
+	os.Exit(1)
+}
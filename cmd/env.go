@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Env prints the variables from the vault's dotenv files as statements for
+// the given shell format, ready for eval. files selects the dotenv files
+// explicitly; otherwise .env is used, overlaid with the profile's file.
+func Env(ctx context.Context, profile, format string, files []string) {
+	// Reject unknown formats before asking for the password
+	if _, _, err := core.FormatEnv(nil, format); err != nil {
+		HandleError(err)
+	}
+
+	// Prompts and warnings go to stderr so stdout can be passed to eval
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	entries, err := lockenv.Env(ctx, password, envProfile(profile), files)
+	if err != nil {
+		HandleError(err)
+	}
+
+	output, skipped, err := core.FormatEnv(entries, format)
+	if err != nil {
+		HandleError(err)
+	}
+	for _, name := range skipped {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s: not a valid %s variable name\n", name, format)
+	}
+
+	os.Stdout = stdout
+	fmt.Print(output)
+}
+
+// envProfile returns profile, or the profile setting from the config files
+// if no --profile was given
+func envProfile(profile string) string {
+	if profile == "" {
+		return loadConfig().Profile
+	}
+	return profile
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Fsck checks the structure of the vault and, with repair set, fixes what it
+// finds. Exits with status 1 if issues are found and not repaired.
+func Fsck(ctx context.Context, repair bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, _ := lockenv.GetVaultID()
+
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	result, err := lockenv.Fsck(ctx, password, repair)
+	if err != nil {
+		HandleError(err)
+	}
+
+	for _, issue := range result.Issues {
+		if issue.Path != "" {
+			fmt.Printf("%s: %s: %s\n", issue.Kind, issue.Path, issue.Detail)
+		} else {
+			fmt.Printf("%s: %s\n", issue.Kind, issue.Detail)
+		}
+	}
+
+	switch {
+	case len(result.Issues) == 0:
+		fmt.Println("fsck: no issues found")
+	case result.Repaired:
+		fmt.Printf("\nbackup: %s\n", result.Backup)
+		fmt.Printf("repaired: %d issues, vault rewritten\n", len(result.Issues))
+	default:
+		fmt.Printf("\nfsck failed: %d issues, run 'lockenv fsck --repair' to fix them\n", len(result.Issues))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/git"
+)
+
+// GitHooksInstall installs the post-checkout and post-merge hooks in the
+// git repository of the current directory, and the pre-rebase hook with stash
+func GitHooksInstall(autoUnlock, stash bool) {
+	written, err := core.InstallGitHooks(".", autoUnlock, stash)
+	for _, path := range written {
+		fmt.Printf("installed: %s\n", path)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+	if autoUnlock {
+		fmt.Println("Changed vaults are unlocked while a 'lockenv session' is active")
+	}
+	if stash {
+		fmt.Println("Local edits are snapshotted while a 'lockenv session' is active")
+	}
+}
+
+// GitHooksUninstall removes the hooks installed by GitHooksInstall
+func GitHooksUninstall() {
+	removed, err := core.UninstallGitHooks(".")
+	for _, path := range removed {
+		fmt.Printf("removed: %s\n", path)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+	if len(removed) == 0 {
+		fmt.Println("No lockenv git hooks installed")
+	}
+}
+
+// GitHookRun handles the git hook event: for every vault in the working tree
+// whose committed version changed, it prints a reminder to unlock. With
+// autoUnlock, a vault with an active session is unlocked instead, after
+// snapshotting its local edits with stash. Before a rebase, stash snapshots
+// the local edits of every vault with an active session. Errors only warn,
+// as git has already switched the working tree.
+func GitHookRun(ctx context.Context, event string, args []string, autoUnlock, stash bool) {
+	from, to, ok, err := core.GitHookRevisions(event, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: lockenv: %s\n", err)
+		return
+	}
+
+	root, err := git.TopLevel(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: lockenv: %s\n", err)
+		return
+	}
+
+	if event == core.GitHookPreRebase {
+		if stash {
+			snapshotVaults(ctx, root)
+		}
+		return
+	}
+	if !ok {
+		return
+	}
+
+	changed, err := core.ChangedVaults(ctx, root, from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: lockenv: %s\n", err)
+		return
+	}
+
+	for _, dir := range changed {
+		vault := filepath.Join(dir, core.LockEnvFile)
+		if autoUnlock {
+			unlocked, err := inSessionVault(root, dir, func(lockenv *core.LockEnv) error {
+				if stash {
+					snapshotVault(ctx, lockenv, vault)
+				}
+				openReferencedVaults(lockenv)
+				result, err := lockenv.Unlock(ctx, []byte{}, core.StrategyKeepBoth, nil)
+				if err != nil {
+					return err
+				}
+				printUnlockSummary(ctx, result)
+				return nil
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: lockenv: %s: %s\n", vault, err)
+			}
+			if unlocked {
+				continue
+			}
+		}
+		hint := "run 'lockenv unlock'"
+		if dir != "." {
+			hint += " in " + dir
+		}
+		fmt.Fprintf(os.Stderr, "lockenv: %s changed, %s to update your secrets\n", vault, hint)
+	}
+}
+
+// snapshotVaults snapshots the local edits of every vault under root that
+// has an active session, warning about vaults with edits but no session
+func snapshotVaults(ctx context.Context, root string) {
+	dirs, err := core.FindVaults(ctx, root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: lockenv: %s\n", err)
+		return
+	}
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			continue
+		}
+		vault := filepath.Join(rel, core.LockEnvFile)
+		edited, err := localEdits(ctx, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: lockenv: %s: %s\n", vault, err)
+			continue
+		}
+		if len(edited) == 0 {
+			continue
+		}
+		opened, err := inSessionVault(root, rel, func(lockenv *core.LockEnv) error {
+			snapshotVault(ctx, lockenv, vault)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: lockenv: %s: %s\n", vault, err)
+		} else if !opened {
+			fmt.Fprintf(os.Stderr, "Warning: lockenv: %s: no active session, %d edited files not snapshotted\n", vault, len(edited))
+		}
+	}
+}
+
+// localEdits returns the files with local edits in the vault in dir
+func localEdits(ctx context.Context, dir string) ([]string, error) {
+	lockenv, err := core.NewAt(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer lockenv.Close()
+	return lockenv.LocalEdits(ctx)
+}
+
+// snapshotVault snapshots the local edits of the vault, reporting the
+// outcome; having nothing to snapshot is not worth mentioning
+func snapshotVault(ctx context.Context, lockenv *core.LockEnv, vault string) {
+	stash, err := lockenv.StashSnapshot(ctx, []byte{})
+	switch err {
+	case nil:
+		fmt.Fprintf(os.Stderr, "lockenv: %s: snapshotted %d edited files (restore with 'lockenv stash pop --snapshot')\n", vault, len(stash.Files))
+	case core.ErrNothingToStash:
+	case core.ErrStashExists:
+		fmt.Fprintf(os.Stderr, "Warning: lockenv: %s: an older snapshot exists, restore it with 'lockenv stash pop --snapshot'\n", vault)
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: lockenv: %s: cannot snapshot local edits: %s\n", vault, err)
+	}
+}
+
+// inSessionVault runs fn on the vault in dir, relative to root, from within
+// dir if a session is active for it, since hooks have no terminal to ask for
+// a password. Reports whether fn ran.
+func inSessionVault(root, dir string, fn func(lockenv *core.LockEnv) error) (bool, error) {
+	defer func() {
+		if err := os.Chdir(root); err != nil {
+			HandleError(err)
+		}
+	}()
+	if err := os.Chdir(filepath.Join(root, dir)); err != nil {
+		return false, err
+	}
+	lockenv, err := core.NewAt(".")
+	if err != nil {
+		return false, err
+	}
+	defer lockenv.Close()
+
+	vaultID, err := lockenv.GetVaultID()
+	if err != nil || !useSession(lockenv, vaultID) {
+		return false, nil
+	}
+	return true, fn(lockenv)
+}
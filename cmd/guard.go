@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// suspendThreshold is how far the wall clock may run ahead of the monotonic
+// clock between checks before the machine is considered to have been suspended
+const suspendThreshold = time.Minute
+
+// Guard waits for the relock timer set by unlock --for and locks the files
+// again once it expires, the machine resumes from suspend or the screen is
+// locked (see screenLocked)
+func Guard(ctx context.Context, interval time.Duration) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	timer, err := lockenv.GetRelockTimer()
+	if err != nil {
+		HandleError(err)
+	}
+	if timer == nil {
+		fmt.Println("No relock timer set (use 'lockenv unlock --for <duration>')")
+		return
+	}
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, source, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+
+	// Hold only the derived key while waiting, not the password
+	if source != SourceSession && source != SourceSSH && source != SourceKMS {
+		key, err := lockenv.DeriveKey(ctx, password)
+		crypto.ClearBytes(password)
+		if err != nil {
+			HandleError(err)
+		}
+		lockenv.UseKey(key)
+		crypto.ClearBytes(key)
+	}
+
+	fmt.Printf("Guarding %d files until %s\n", len(timer.Files), timer.Expires.Local().Format("2006-01-02 15:04:05"))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("guard stopped, files were not relocked")
+			return
+		case <-ticker.C:
+		}
+
+		// The monotonic clock does not advance while the machine is suspended;
+		// Round(0) strips the monotonic reading, leaving only the wall clock
+		now := time.Now()
+		suspended := now.Round(0).Sub(last.Round(0))-now.Sub(last) > suspendThreshold
+		last = now
+
+		timer, err := lockenv.GetRelockTimer()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot read relock timer: %s\n", err)
+			continue
+		}
+		if timer == nil {
+			fmt.Println("Relock timer cleared, nothing to do")
+			return
+		}
+		locked := !suspended && screenLocked(ctx)
+		if !suspended && !locked && now.Before(timer.Expires) {
+			continue
+		}
+
+		switch {
+		case suspended:
+			fmt.Println("\nResumed from suspend, relocking")
+		case locked:
+			fmt.Println("\nScreen locked, relocking")
+		default:
+			fmt.Println("\nRelock timer expired, relocking")
+		}
+
+		setDeterministic(lockenv)
+		report, err := lockenv.Relock(ctx, []byte{})
+		if err != nil {
+			HandleError(err)
+		}
+		printLockReport(report, "encrypted")
+		fmt.Printf("\nrelocked: %d files\n", len(report.Locked)+len(report.Unchanged))
+		return
+	}
+}
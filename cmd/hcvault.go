@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/hcvault"
+)
+
+// hashiCorpVaultTimeout bounds the password lookup so an unreachable server
+// falls back to the prompt quickly
+const hashiCorpVaultTimeout = 10 * time.Second
+
+// GetPasswordFromHashiCorpVault fetches the password from HashiCorp Vault when
+// .lockenv.toml configures it. Returns nil if not configured or on failure,
+// after printing a warning, so other password sources are tried.
+// The caller is responsible for calling crypto.ClearBytes on the returned password
+func GetPasswordFromHashiCorpVault(_ string, config *core.Config) []byte {
+	hc := config.HashiCorpVault
+	if hc == nil {
+		return nil
+	}
+
+	client, err := hcvault.NewClient(hc.Address, hc.Namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hashiCorpVaultTimeout)
+	defer cancel()
+
+	password, err := client.ReadField(ctx, hc.Path, hc.Field)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+		return nil
+	}
+	return password
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// runHook runs the hook for event from the config files, if configured.
+// A failing pre- hook aborts the command; post- hook failures only warn,
+// since the operation has already happened.
+func runHook(ctx context.Context, event string, files []string) {
+	if err := core.RunHook(ctx, ".", loadConfig().Config, event, files); err != nil {
+		if strings.HasPrefix(event, "pre-") {
+			HandleError(err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	}
+}
@@ -1,16 +1,21 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/illarion/lockenv/internal/core"
 	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
 )
 
-// Init creates a new .lockenv file
-func Init() {
-	lockenv, err := core.New(".")
+// Init creates a new .lockenv file in the given layout. With recoveryKey, a
+// recovery key is created and printed. With importExisting, files that look
+// like secrets are proposed and the selected ones locked.
+func Init(ctx context.Context, layout string, recoveryKey, importExisting bool) {
+	lockenv, err := core.NewAt(".")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
@@ -26,11 +31,31 @@ func Init() {
 	defer crypto.ClearBytes(password)
 
 	// Initialize lockenv
+	lockenv.SetKDFTargetTime(loadConfig().KDFTargetTime)
 	if err := lockenv.Init(password); err != nil {
 		HandleError(err)
 	}
 
-	fmt.Println("initialized: .lockenv")
+	if layout == storage.LayoutDir {
+		if _, err := lockenv.SetLayout(ctx, layout); err != nil {
+			HandleError(err)
+		}
+		fmt.Printf("initialized: .lockenv (file contents in %s/)\n", storage.ObjectsDir)
+	} else {
+		fmt.Println("initialized: .lockenv")
+	}
+
+	if recoveryKey {
+		key, err := lockenv.CreateRecoveryKey(password)
+		if err != nil {
+			HandleError(err)
+		}
+		printRecoveryKey(key)
+	}
+
+	if importExisting {
+		importSecretFiles(ctx, lockenv, password)
+	}
 
 	// Offer to save password to keyring
 	vaultID, err := lockenv.GetOrCreateVaultID()
@@ -38,4 +63,62 @@ func Init() {
 		return
 	}
 	OfferToSavePassword(vaultID, password)
-}
\ No newline at end of file
+}
+
+// importSecretFiles proposes the files in the project that look like secrets
+// and locks the ones the user keeps selected (init --import-existing)
+func importSecretFiles(ctx context.Context, lockenv *core.LockEnv, password []byte) {
+	setScanIgnore(lockenv)
+	found, err := lockenv.DiscoverSecretFiles(ctx, false)
+	if err != nil {
+		HandleError(err)
+	}
+	if len(found) == 0 {
+		fmt.Println("No files that look like secrets found")
+		return
+	}
+
+	var toLock []string
+	if IsTerminal() {
+		candidates := make([]core.Candidate, 0, len(found))
+		for _, f := range found {
+			candidates = append(candidates, core.Candidate{Path: f.Path, Note: f.Reason, Selected: true})
+		}
+		fmt.Println()
+		selected, ok := chooseFiles("Select existing files to lock:", candidates)
+		if !ok {
+			return
+		}
+		toLock = selected
+	} else {
+		fmt.Println("\nFiles that look like secrets:")
+		for _, f := range found {
+			fmt.Printf("  %s (%s)\n", f.Path, f.Reason)
+			toLock = append(toLock, f.Path)
+		}
+		fmt.Printf("Lock %d file(s)? [Y/n]: ", len(found))
+
+		var response string
+		_, _ = fmt.Scanln(&response)
+		response = strings.ToLower(strings.TrimSpace(response))
+		if response == "n" || response == "no" {
+			fmt.Println("Cancelled")
+			return
+		}
+	}
+
+	setMaxFileSize(lockenv, false)
+	setDeterministic(lockenv)
+	runHook(ctx, core.HookPreLock, toLock)
+
+	added, err := lockenv.LockFiles(ctx, toLock, password, false)
+	if err != nil {
+		HandleError(err)
+	}
+	printLockReport(added, "locking")
+	if len(added.Locked) == 0 {
+		fmt.Println("No files to lock")
+		return
+	}
+	finalizeLock(ctx, lockenv, password, false, added.Locked, false)
+}
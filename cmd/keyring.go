@@ -3,15 +3,17 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/illarion/lockenv/internal/core"
 	"github.com/illarion/lockenv/internal/crypto"
 	"github.com/illarion/lockenv/internal/keyring"
 )
 
-// KeyringSave saves the password to the OS keyring
-func KeyringSave() {
-	lockenv, err := core.New(".")
+// KeyringSave saves the password to the OS keyring.
+// If ttl is non-zero, the stored password is invalidated after that duration.
+func KeyringSave(ttl time.Duration) {
+	lockenv, err := openLockEnv()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
@@ -38,17 +40,21 @@ func KeyringSave() {
 	}
 
 	// Save to keyring
-	if err := keyring.SavePassword(vaultID, string(password)); err != nil {
+	if err := keyring.SavePasswordWithTTL(vaultID, string(password), ttl); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to save to keyring: %s\n", err)
 		os.Exit(1)
 	}
 
+	if ttl > 0 {
+		fmt.Printf("Password saved to keyring (expires in %s)\n", ttl)
+		return
+	}
 	fmt.Println("Password saved to keyring")
 }
 
 // KeyringDelete removes the password from the OS keyring
 func KeyringDelete() {
-	lockenv, err := core.New(".")
+	lockenv, err := openLockEnv()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
@@ -73,7 +79,7 @@ func KeyringDelete() {
 
 // KeyringStatus checks if a password is stored in the keyring
 func KeyringStatus() {
-	lockenv, err := core.New(".")
+	lockenv, err := openLockEnv()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
@@ -87,9 +93,14 @@ func KeyringStatus() {
 		return
 	}
 
-	if keyring.HasPassword(vaultID) {
-		fmt.Println("Password: stored in keyring")
-	} else {
+	if !keyring.HasPassword(vaultID) {
 		fmt.Println("Password: not stored")
+		return
+	}
+
+	if expires, ok := keyring.GetExpiry(vaultID); ok {
+		fmt.Printf("Password: stored in keyring (expires %s)\n", expires.Local().Format("2006-01-02 15:04:05"))
+		return
 	}
+	fmt.Println("Password: stored in keyring")
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Link adds path as a reference to file target of the vault in vaultDir.
+// With as empty, the reference takes the target's path.
+func Link(ctx context.Context, vaultDir, target, as string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, _ := lockenv.GetVaultID()
+
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if as == "" {
+		as = target
+	}
+	if err := lockenv.AddReference(ctx, password, as, vaultDir, target); err != nil {
+		HandleError(err)
+	}
+	fmt.Printf("linked: %s -> %s in %s\n", as, target, vaultDir)
+	fmt.Println("Run 'lockenv unlock' to read it from that vault")
+}
+
+// openReferencedVaults makes lockenv open the vaults its references point
+// at when it first needs them, each with its own password, keyring entry or
+// session. A vault is looked up in the [vaults] config table by its ID, then
+// where it was when linked. A vault that cannot be opened is reported once
+// and its references are skipped.
+func openReferencedVaults(lockenv *core.LockEnv) {
+	dirs := loadConfig().Vaults
+	failed := make(map[string]error)
+	lockenv.SetVaultOpener(func(ref storage.Reference) error {
+		if err, ok := failed[ref.VaultID]; ok {
+			return err
+		}
+		dir := dirs[ref.VaultID]
+		if dir == "" {
+			dir = ref.Location
+		}
+		err := openVault(lockenv, ref.VaultID, dir)
+		if err != nil {
+			failed[ref.VaultID] = err
+			fmt.Fprintf(os.Stderr, "warning: cannot open vault %s in %s: %s\n", ref.VaultID, dir, err)
+		}
+		return err
+	})
+}
+
+// openVault opens the vault with ID vaultID in dir for lockenv
+func openVault(lockenv *core.LockEnv, vaultID, dir string) error {
+	other, err := core.NewAt(dir)
+	if err != nil {
+		return err
+	}
+	password, _, err := GetPasswordWithRetry(fmt.Sprintf("Enter password for %s: ", dir), vaultID, other)
+	if err != nil {
+		other.Close()
+		return err
+	}
+	defer crypto.ClearBytes(password)
+
+	if err := lockenv.UseVault(vaultID, other, password); err != nil {
+		other.Close()
+		return err
+	}
+	return nil
+}
@@ -1,17 +1,28 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/illarion/lockenv/internal/core"
 	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/output"
 )
 
-// Lock encrypts and stores files in the vault
-func Lock(ctx context.Context, patterns []string, remove bool) {
-	lockenv, err := core.New(".")
+// Lock encrypts and stores files in the vault.
+// With recursive set, directories are tracked together with all their files.
+// With domain set, the files are moved into that encryption domain.
+// allowLarge lifts the max_file_size limit; force accepts large binary files.
+// With sign set, the manifest is signed with the SSH identity afterwards.
+// With strict set, dotenv syntax issues fail the lock instead of warning.
+func Lock(ctx context.Context, patterns []string, remove bool, recursive bool, domain string, allowLarge bool, force bool, sign bool, strict bool) {
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
@@ -21,26 +32,200 @@ func Lock(ctx context.Context, patterns []string, remove bool) {
 	vaultID, _ := lockenv.GetVaultID()
 
 	// Get password with retry on stale keyring
-	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv.VerifyPassword)
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
 	if err != nil {
 		HandleError(err)
 	}
 	defer crypto.ClearBytes(password)
 
+	if domain != "" {
+		unlockDomain(lockenv, domain)
+	}
+
+	setMaxFileSize(lockenv, allowLarge)
+	setDeterministic(lockenv)
+	lockenv.SetStrictDotenv(strict)
+	lockenv.AllowBinary(force)
+	runHook(ctx, core.HookPreLock, patterns)
+
+	// Keep the vault open from adding the files until they are encrypted
+	if err := lockenv.Hold(); err != nil {
+		HandleError(err)
+	}
+
 	// Add files to vault
-	if err := lockenv.LockFiles(ctx, patterns, password); err != nil {
+	added, err := lockenv.LockFiles(ctx, patterns, password, recursive)
+	if err != nil {
 		HandleError(err)
 	}
+	printLockReport(added, "locking")
+	if len(added.Locked) == 0 {
+		fmt.Println("No files to lock")
+		return
+	}
+
+	if domain != "" {
+		if err := lockenv.AssignDomain(password, added.Locked, domain); err != nil {
+			HandleError(err)
+		}
+	}
+
+	// Encrypt only the files that were just added
+	finalizeLock(ctx, lockenv, password, remove, added.Locked, sign)
+}
+
+// LockPatch shows the changes between the vault and local version of each
+// file hunk by hunk and locks only the hunks the user picks, like
+// git add -p. The working copy is left as it is, so unpicked changes stay
+// local. Values in the hunks are masked unless showSecrets is set.
+func LockPatch(ctx context.Context, paths []string, showSecrets bool, sign bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
 
-	// Encrypt files
-	if err := lockenv.FinalizeLock(ctx, password, remove); err != nil {
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
 		HandleError(err)
 	}
+	defer crypto.ClearBytes(password)
+
+	setDeterministic(lockenv)
+	runHook(ctx, core.HookPreLock, paths)
+
+	var locked []string
+	for _, path := range paths {
+		path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+		if lockPatchFile(ctx, lockenv, password, path, showSecrets) {
+			locked = append(locked, path)
+		}
+	}
+	if len(locked) == 0 {
+		fmt.Println("No hunks selected")
+		return
+	}
+
+	if sign {
+		signManifest(ctx, lockenv)
+	}
+	runHook(ctx, core.HookPostLock, locked)
 }
 
-// LockAll locks all tracked files that have been modified
-func LockAll(ctx context.Context, remove bool, force bool) {
-	lockenv, err := core.New(".")
+// lockPatchFile asks which hunks of path to lock and stores the result.
+// Returns whether anything was locked.
+func lockPatchFile(ctx context.Context, lockenv *core.LockEnv, password []byte, path string, showSecrets bool) bool {
+	r, err := lockenv.OpenVaultFile(ctx, password, path)
+	if errors.Is(err, core.ErrFileNotInVault) {
+		fmt.Fprintf(os.Stderr, "%s is not in the vault; lock it without -p first\n", path)
+		return false
+	}
+	if err != nil {
+		HandleError(err)
+	}
+	vaultData, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(vaultData)
+
+	localData, err := os.ReadFile(path)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(localData)
+
+	content, chosen, err := selectHunks(path, vaultData, localData, showSecrets)
+	if err != nil {
+		HandleError(fmt.Errorf("%s: %w", path, err))
+	}
+	defer crypto.ClearBytes(content)
+	if chosen == 0 {
+		if bytes.Equal(vaultData, localData) {
+			fmt.Printf("%s: no changes\n", path)
+		}
+		return false
+	}
+
+	if err := lockenv.StoreVaultFile(ctx, password, path, bytes.NewReader(content)); err != nil {
+		HandleError(err)
+	}
+	if bytes.Equal(content, localData) {
+		fmt.Printf("locked: %s into %s\n", path, core.LockEnvFile)
+	} else {
+		fmt.Printf("locked: part of %s into %s (the rest stays in the working copy)\n", path, core.LockEnvFile)
+	}
+	return true
+}
+
+// selectHunks shows each change between the vault and local version of a
+// text file and asks whether to lock it, like git add -p. Returns the vault
+// version with the chosen hunks applied and the number of hunks chosen.
+func selectHunks(path string, vaultData, localData []byte, showSecrets bool) ([]byte, int, error) {
+	patch, err := core.NewPatch(path, vaultData, localData)
+	if err != nil || patch.Len() == 0 {
+		return nil, 0, err
+	}
+
+	p := output.Stdout()
+	fmt.Print(p.Diff(patch.Header()))
+
+	selected := make([]bool, patch.Len())
+	chosen := 0
+	for i := 0; i < patch.Len(); i++ {
+		fmt.Print(p.Diff(patch.Hunk(i, showSecrets)))
+
+	prompt:
+		for {
+			fmt.Printf("(%d/%d) Lock this hunk [y,n,a,d,q,?]? ", i+1, patch.Len())
+			choice, err := core.ReadChoice()
+			if err != nil {
+				return nil, 0, err
+			}
+			switch choice {
+			case "y":
+				selected[i] = true
+				chosen++
+				break prompt
+			case "n":
+				break prompt
+			case "a":
+				for j := i; j < patch.Len(); j++ {
+					selected[j] = true
+					chosen++
+				}
+				i = patch.Len()
+				break prompt
+			case "d", "q":
+				i = patch.Len()
+				break prompt
+			default:
+				fmt.Println("y - lock this hunk")
+				fmt.Println("n - do not lock this hunk")
+				fmt.Println("a - lock this hunk and all later hunks")
+				fmt.Println("d, q - do not lock this hunk or any later hunk")
+				fmt.Println("? - print help")
+			}
+		}
+	}
+
+	return patch.Apply(selected), chosen, nil
+}
+
+// LockStdin encrypts everything read from stdin into the vault as path, so
+// generated secrets never exist as a file in the working tree. Since stdin
+// carries the contents, the password has to come from LOCKENV_PASSWORD, the
+// keyring, a session or another non-interactive source unless stdin is a
+// terminal. With domain set, the file is moved into that encryption domain.
+func LockStdin(ctx context.Context, path string, domain string, allowLarge bool, sign bool) {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
@@ -50,19 +235,149 @@ func LockAll(ctx context.Context, remove bool, force bool) {
 	vaultID, _ := lockenv.GetVaultID()
 
 	// Get password with retry on stale keyring
-	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv.VerifyPassword)
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
 	if err != nil {
 		HandleError(err)
 	}
 	defer crypto.ClearBytes(password)
 
+	if domain != "" {
+		unlockDomain(lockenv, domain)
+	}
+
+	setMaxFileSize(lockenv, allowLarge)
+	setDeterministic(lockenv)
+	runHook(ctx, core.HookPreLock, []string{path})
+
+	if IsTerminal() {
+		fmt.Fprintf(os.Stderr, "Enter the contents of %s, then press Ctrl-D:\n", path)
+	}
+	if err := lockenv.StoreVaultFile(ctx, password, path, os.Stdin); err != nil {
+		HandleError(err)
+	}
+	if domain != "" {
+		if err := lockenv.AssignDomain(password, []string{path}, domain); err != nil {
+			HandleError(err)
+		}
+	}
+	fmt.Printf("locked: %s from stdin into %s\n", path, core.LockEnvFile)
+
+	if sign {
+		signManifest(ctx, lockenv)
+	}
+	runHook(ctx, core.HookPostLock, []string{path})
+}
+
+// finalizeLock encrypts the given vault paths (all tracked files if empty)
+// and prints the outcome, then signs the manifest if sign is set
+func finalizeLock(ctx context.Context, lockenv *core.LockEnv, password []byte, remove bool, paths []string, sign bool) {
+	report, err := lockenv.FinalizeLock(ctx, password, remove, paths)
+	if report != nil {
+		printLockReport(report, "encrypted")
+	}
+	if err != nil {
+		HandleError(err)
+	}
+	fmt.Println(lockSummary(report))
+	// Signing and the hook may run lockenv, which waits for a vault held open
+	if err := lockenv.Release(); err != nil && err != core.ErrNotHeld {
+		HandleError(err)
+	}
+	if sign {
+		signManifest(ctx, lockenv)
+	}
+	runHook(ctx, core.HookPostLock, report.Locked)
+}
+
+// setMaxFileSize applies max_file_size from the config files, or no limit
+// with allowLarge
+func setMaxFileSize(lockenv *core.LockEnv, allowLarge bool) {
+	if allowLarge {
+		lockenv.SetMaxFileSize(0)
+		return
+	}
+	lockenv.SetMaxFileSize(loadConfig().MaxFileSize)
+}
+
+// setDeterministic applies the deterministic setting from the config files
+func setDeterministic(lockenv *core.LockEnv) {
+	lockenv.SetDeterministic(loadConfig().Deterministic)
+}
+
+// lockSummary counts the files FinalizeLock encrypted and those it left
+// alone because the vault already held them
+func lockSummary(report *core.LockReport) string {
+	summary := fmt.Sprintf("locked: %d files into %s", len(report.Locked), core.LockEnvFile)
+	if n := len(report.Unchanged); n > 0 {
+		summary += fmt.Sprintf(" (%d unchanged)", n)
+	}
+	return summary
+}
+
+// printLockReport prints each file in report with action ("locking",
+// "tracking" or "encrypted"), the unchanged and removed files, and a warning for every
+// skipped or failed file and every issue found in a locked file
+func printLockReport(report *core.LockReport, action string) {
+	for _, path := range report.Locked {
+		fmt.Printf("%s: %s\n", action, path)
+	}
+	for _, path := range report.Unchanged {
+		fmt.Printf("unchanged, skipped: %s\n", path)
+	}
+	for _, path := range report.Removed {
+		fmt.Printf("removed: %s\n", path)
+	}
+	warning := output.Stdout().Yellow("warning:")
+	for _, f := range report.Skipped {
+		fmt.Printf("%s skipping %s\n", warning, f)
+	}
+	for _, f := range report.Warnings {
+		fmt.Printf("%s %s\n", warning, f)
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("%s %s\n", warning, f)
+	}
+}
+
+// LockAll locks all tracked files that have been modified.
+// Files in an encryption domain are only relocked if domain names it.
+// allowLarge lifts the max_file_size limit; force skips the confirmation (a
+// checklist of the files in a terminal) and accepts large binary files. With sign set, the manifest is signed even if
+// nothing changed. With strict set, dotenv syntax issues fail the lock.
+func LockAll(ctx context.Context, remove bool, force bool, domain string, allowLarge bool, sign bool, strict bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if domain != "" {
+		unlockDomain(lockenv, domain)
+	}
+
 	// Analyze all tracked files
 	result, err := lockenv.GetChangedFiles(ctx, password)
 	if err != nil {
 		HandleError(err)
 	}
 
-	totalFiles := len(result.Changed) + len(result.Unchanged) + len(result.Missing)
+	// Pick up files added to or deleted from tracked directories
+	dirs, err := lockenv.ScanTrackedDirs(ctx)
+	if err != nil {
+		HandleError(err)
+	}
+
+	totalFiles := len(result.Changed) + len(result.Unchanged) + len(result.Missing) + len(result.NeverLocked)
 
 	// Check if vault is empty
 	if totalFiles == 0 {
@@ -82,6 +397,13 @@ func LockAll(ctx context.Context, remove bool, force bool) {
 		}
 	}
 
+	if len(result.NeverLocked) > 0 {
+		fmt.Printf("  %d tracked, never locked:\n", len(result.NeverLocked))
+		for _, path := range result.NeverLocked {
+			fmt.Printf("    + %s\n", path)
+		}
+	}
+
 	if len(result.Unchanged) > 0 {
 		fmt.Printf("  %d unchanged\n", len(result.Unchanged))
 	}
@@ -93,18 +415,59 @@ func LockAll(ctx context.Context, remove bool, force bool) {
 		}
 	}
 
+	if len(dirs.New) > 0 {
+		fmt.Printf("  %d new in tracked directories:\n", len(dirs.New))
+		for _, path := range dirs.New {
+			fmt.Printf("    + %s\n", path)
+		}
+	}
+
+	if len(dirs.Deleted) > 0 {
+		fmt.Printf("  %d deleted from tracked directories:\n", len(dirs.Deleted))
+		for _, path := range dirs.Deleted {
+			fmt.Printf("    - %s\n", path)
+		}
+		fmt.Println("  Run 'lockenv rm <file>' to stop tracking deleted files")
+	}
+
+	toLock := append(append(result.Changed, result.NeverLocked...), dirs.New...)
+
 	// Check if there are any changes to lock
-	if len(result.Changed) == 0 {
+	if len(toLock) == 0 {
 		fmt.Println("\nNo changes to lock")
+		if sign {
+			signManifest(ctx, lockenv)
+		}
 		return
 	}
 
-	// Ask for confirmation unless --force
-	if !force {
+	// Ask for confirmation unless --force; terminals get a checklist instead
+	if !force && IsTerminal() {
+		title := "Select files to lock:"
+		if remove {
+			title = "Select files to lock (originals are removed):"
+		}
+		var candidates []core.Candidate
+		for _, path := range result.Changed {
+			candidates = append(candidates, core.Candidate{Path: path, Note: "modified", Selected: true})
+		}
+		for _, path := range result.NeverLocked {
+			candidates = append(candidates, core.Candidate{Path: path, Note: "never locked", Selected: true})
+		}
+		for _, path := range dirs.New {
+			candidates = append(candidates, core.Candidate{Path: path, Note: "new", Selected: true})
+		}
+		fmt.Println()
+		selected, ok := chooseFiles(title, candidates)
+		if !ok {
+			return
+		}
+		toLock = selected
+	} else if !force {
 		if remove {
-			fmt.Printf("\nLock %d modified file(s) and remove originals? [Y/n]: ", len(result.Changed))
+			fmt.Printf("\nLock %d modified file(s) and remove originals? [Y/n]: ", len(toLock))
 		} else {
-			fmt.Printf("\nLock %d modified file(s)? [Y/n]: ", len(result.Changed))
+			fmt.Printf("\nLock %d modified file(s)? [Y/n]: ", len(toLock))
 		}
 
 		var response string
@@ -118,12 +481,22 @@ func LockAll(ctx context.Context, remove bool, force bool) {
 		}
 	}
 
+	setMaxFileSize(lockenv, allowLarge)
+	setDeterministic(lockenv)
+	lockenv.SetStrictDotenv(strict)
+	lockenv.AllowBinary(force)
+	runHook(ctx, core.HookPreLock, toLock)
+
 	// Lock the changed files
-	if err := lockenv.LockFiles(ctx, result.Changed, password); err != nil {
+	added, err := lockenv.LockFiles(ctx, toLock, password, false)
+	if err != nil {
 		HandleError(err)
 	}
-
-	if err := lockenv.FinalizeLock(ctx, password, remove); err != nil {
-		HandleError(err)
+	printLockReport(added, "locking")
+	if len(added.Locked) == 0 {
+		fmt.Println("No files to lock")
+		return
 	}
+
+	finalizeLock(ctx, lockenv, password, remove, added.Locked, sign)
 }
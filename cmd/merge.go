@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Merge merges the files of another vault into .lockenv.
+// Conflicting files are resolved like unlock conflicts, with the other vault
+// as the "vault" side.
+func Merge(ctx context.Context, otherPath string, force bool, keepLocal bool, keepBoth bool) {
+	// Validate mutually exclusive flags
+	flagCount := boolToInt(force) + boolToInt(keepLocal) + boolToInt(keepBoth)
+	if flagCount > 1 {
+		fmt.Fprintf(os.Stderr, "error: --force, --keep-local, and --keep-both are mutually exclusive\n")
+		os.Exit(1)
+	}
+
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	// Determine merge strategy
+	var strategy core.MergeStrategy
+	switch {
+	case force:
+		strategy = core.StrategyUseVault
+	case keepLocal:
+		strategy = core.StrategyKeepLocal
+	case keepBoth:
+		strategy = core.StrategyKeepBoth
+	default:
+		strategy = loadConfig().Strategy
+	}
+	if strategy == core.StrategyAsk {
+		setMergeOptions(lockenv)
+	}
+
+	// Try the same password first, then ask for the other vault's password
+	result, err := lockenv.MergeVault(ctx, password, otherPath, nil, strategy)
+	if err == core.ErrOtherWrongPassword && IsTerminal() {
+		otherPassword, readErr := core.ReadPassword(fmt.Sprintf("Enter password for %s: ", otherPath))
+		if readErr != nil {
+			HandleError(readErr)
+		}
+		defer crypto.ClearBytes(otherPassword)
+		result, err = lockenv.MergeVault(ctx, password, otherPath, otherPassword, strategy)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+
+	// Print summary
+	printEvents(result.Events)
+	fmt.Printf("\n")
+	if result.Diverged {
+		fmt.Printf("warning: both vaults are at generation %d with different files; they were changed concurrently\n", result.OtherGeneration)
+	}
+	if len(result.Added)+len(result.Updated) == 0 {
+		fmt.Println("Nothing to merge")
+	}
+	if len(result.Added) > 0 {
+		fmt.Printf("added: %d files\n", len(result.Added))
+	}
+	if len(result.Updated) > 0 {
+		fmt.Printf("updated: %d files\n", len(result.Updated))
+	}
+	if len(result.Skipped) > 0 {
+		fmt.Printf("skipped: %d files\n", len(result.Skipped))
+	}
+	if len(result.Errors) > 0 {
+		fmt.Printf("error: %d errors occurred\n", len(result.Errors))
+	}
+	if len(result.Added)+len(result.Updated) > 0 {
+		fmt.Printf("generation: %d\n", result.Generation)
+		fmt.Println("Run 'lockenv unlock' to update local files")
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// MetaSet sets a descriptive field of the vault; an empty value removes it
+func MetaSet(field, value string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if err := lockenv.SetVaultInfo(password, field, value); err != nil {
+		HandleError(err)
+	}
+	if strings.TrimSpace(value) == "" {
+		fmt.Printf("Removed %s\n", field)
+	} else {
+		fmt.Printf("Set %s\n", field)
+	}
+}
+
+// MetaShow prints the vault's descriptive fields (no password required)
+func MetaShow() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	info, err := lockenv.GetVaultInfo()
+	if err != nil {
+		HandleError(err)
+	}
+	if len(info) == 0 {
+		fmt.Println("No vault metadata set")
+		fmt.Println("Use 'lockenv meta set description \"...\"' to describe this vault")
+		return
+	}
+	printVaultInfo(info, "")
+}
+
+// printVaultInfo prints the set fields in display order, labels aligned
+func printVaultInfo(info map[string]string, indent string) {
+	for _, field := range core.VaultInfoFields {
+		if value, ok := info[field]; ok {
+			label := strings.ToUpper(field[:1]) + field[1:] + ":"
+			fmt.Printf("%s%-13s%s\n", indent, label, value)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Migrate upgrades the vault to the current on-disk format
+func Migrate(ctx context.Context) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, _ := lockenv.GetVaultID()
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	result, err := lockenv.Migrate(ctx, password)
+	if errors.Is(err, core.ErrAlreadyMigrated) {
+		fmt.Println("vault is already up to date")
+		return
+	}
+	if err != nil {
+		HandleError(err)
+	}
+	fmt.Printf("backup: %s\n", result.Backup)
+
+	// Compact database after rewriting all data
+	autoCompact(lockenv)
+
+	fmt.Printf("migrated vault from format v%d to v%d (%d files re-encrypted)\n", result.From, result.To, result.Files)
+}
+
+// MigrateLayout converts the vault to layout
+func MigrateLayout(ctx context.Context, layout string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	changed, err := lockenv.SetLayout(ctx, layout)
+	if err != nil {
+		HandleError(err)
+	}
+	if !changed {
+		fmt.Printf("vault already uses the %s layout\n", layout)
+		return
+	}
+
+	// Reclaim the space of blobs moved out of the database
+	autoCompact(lockenv)
+
+	if layout == storage.LayoutDir {
+		fmt.Printf("converted vault to the dir layout (commit %s/ together with %s)\n", storage.ObjectsDir, core.LockEnvFile)
+	} else {
+		fmt.Printf("converted vault to the file layout (%s/ is no longer needed)\n", storage.ObjectsDir)
+	}
+}
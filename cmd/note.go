@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// NoteSet sets the note of a file in the vault; an empty note removes it
+func NoteSet(ctx context.Context, path, note string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if err := lockenv.SetNote(ctx, password, path, note); err != nil {
+		HandleError(err)
+	}
+	if note == "" {
+		fmt.Printf("Removed note from %s\n", path)
+	} else {
+		fmt.Printf("Noted %s\n", path)
+	}
+}
+
+// NoteShow prints the note of a file in the vault
+func NoteShow(ctx context.Context, path string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	note, err := lockenv.Note(ctx, password, path)
+	if err != nil {
+		HandleError(err)
+	}
+	if note == "" {
+		fmt.Printf("%s has no note\n", path)
+		return
+	}
+	fmt.Println(note)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// CheckParity reports the variables defined in some of the files but
+// missing from others, with values masked. With local set, the working-tree
+// copies are compared without a password. Exits with status 1 if any
+// variable is missing.
+func CheckParity(ctx context.Context, files []string, local bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	var password []byte
+	if !local {
+		vaultID, _ := lockenv.GetVaultID()
+
+		password, _, err = GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+		if err != nil {
+			HandleError(err)
+		}
+		defer crypto.ClearBytes(password)
+	}
+
+	result, err := lockenv.CheckParity(ctx, password, files, local)
+	if err != nil {
+		HandleError(err)
+	}
+
+	for _, gap := range result.Missing {
+		fmt.Printf("missing: %s: %s=**** (defined in %s)\n", gap.File, gap.Key, strings.Join(gap.DefinedIn, ", "))
+	}
+
+	if !result.OK() {
+		fmt.Printf("\nparity failed: %d variables missing across %d files\n", len(result.Missing), len(result.Files))
+		os.Exit(1)
+	}
+	fmt.Printf("parity ok: %d files define the same %d variables\n", len(result.Files), result.Keys)
+}
@@ -1,17 +1,25 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/illarion/lockenv/internal/core"
 	"github.com/illarion/lockenv/internal/crypto"
 	"github.com/illarion/lockenv/internal/keyring"
+	"github.com/illarion/lockenv/internal/session"
 )
 
-// Passwd changes the password for .lockenv
-func Passwd() {
-	lockenv, err := core.New(".")
+// Passwd changes the password for .lockenv. With rotateSaltOnly the
+// password stays and only the salt and iterations are renewed.
+func Passwd(ctx context.Context, rotateSaltOnly bool) {
+	if rotateSaltOnly {
+		rotateSalt(ctx)
+		return
+	}
+
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
@@ -21,7 +29,7 @@ func Passwd() {
 	vaultID, _ := lockenv.GetVaultID()
 
 	// Get current password with retry on stale keyring
-	currentPassword, _, err := GetPasswordWithRetry("Enter current password: ", vaultID, lockenv.VerifyPassword)
+	currentPassword, _, err := GetPasswordWithRetry("Enter current password: ", vaultID, lockenv)
 	if err != nil {
 		HandleError(err)
 	}
@@ -36,10 +44,16 @@ func Passwd() {
 	defer crypto.ClearBytes(newPassword)
 
 	// Change password
+	lockenv.SetKDFTargetTime(loadConfig().KDFTargetTime)
 	if err := lockenv.ChangePassword(currentPassword, newPassword); err != nil {
 		HandleError(err)
 	}
 
+	// Cached session keys are derived from the old password
+	if vaultID != "" {
+		_ = session.End(vaultID)
+	}
+
 	// Always try to update keyring if vault ID exists
 	// This handles both updating existing entry and cases where keyring was unavailable before
 	if vaultID != "" {
@@ -49,9 +63,45 @@ func Passwd() {
 	}
 
 	// Compact database after rewriting all data
-	if err := lockenv.Compact(); err != nil {
-		fmt.Fprintf(os.Stderr, "warning: compaction failed: %s\n", err)
-	}
+	autoCompact(lockenv)
 
 	fmt.Println("password changed successfully")
+	runHook(ctx, core.HookPostPasswd, nil)
+}
+
+// rotateSalt re-derives the vault key from the current password with a new
+// salt and the configured iterations (passwd --rotate-salt-only)
+func rotateSalt(ctx context.Context) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	lockenv.SetKDFTargetTime(loadConfig().KDFTargetTime)
+	before, after, err := lockenv.RotateSalt(password)
+	if err != nil {
+		HandleError(err)
+	}
+
+	// Cached session keys are derived from the old salt
+	if vaultID != "" {
+		_ = session.End(vaultID)
+	}
+
+	// Older formats re-encrypt every file
+	autoCompact(lockenv)
+
+	fmt.Printf("salt rotated (PBKDF2 iterations: %d -> %d)\n", before, after)
+	runHook(ctx, core.HookPostPasswd, nil)
 }
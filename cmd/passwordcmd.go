@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// GetPasswordFromCommand runs password_command from .lockenv.toml (e.g. a
+// 1Password or Bitwarden CLI call) and returns its stdout without the trailing
+// newline. Returns nil if not configured or on failure, after printing a
+// warning, so other password sources are tried.
+// The caller is responsible for calling crypto.ClearBytes on the returned password
+func GetPasswordFromCommand(_ string, config *core.Config) []byte {
+	if config.PasswordCommand == "" {
+		return nil
+	}
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", config.PasswordCommand)
+	} else {
+		c = exec.Command("sh", "-c", config.PasswordCommand)
+	}
+	// The command may need to ask for unlock or biometrics
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+
+	out, err := c.Output()
+	if err != nil {
+		crypto.ClearBytes(out)
+		fmt.Fprintf(os.Stderr, "Warning: password_command failed: %s\n", err)
+		return nil
+	}
+
+	password := bytes.TrimSuffix(out, []byte("\n"))
+	password = bytes.TrimSuffix(password, []byte("\r"))
+	if len(password) == 0 {
+		fmt.Fprintln(os.Stderr, "Warning: password_command printed no password")
+		return nil
+	}
+	return password
+}
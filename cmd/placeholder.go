@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Inject replaces {{lockenv:FILE:KEY}} placeholders in path with values from
+// the vault, rewriting path with owner-only permissions, or printing the
+// result if toStdout is set.
+func Inject(ctx context.Context, path string, toStdout bool) {
+	substitute(ctx, path, toStdout, "injected", true, func(lockenv *core.LockEnv, password, text []byte) ([]byte, int, error) {
+		return lockenv.Inject(ctx, password, text)
+	})
+}
+
+// Redact replaces values from the vault's dotenv files (or only those in
+// files) found in path with their placeholders, reversing Inject. path keeps
+// its permissions unless toStdout is set, in which case it is left alone.
+func Redact(ctx context.Context, path string, toStdout bool, files []string) {
+	substitute(ctx, path, toStdout, "redacted", false, func(lockenv *core.LockEnv, password, text []byte) ([]byte, int, error) {
+		return lockenv.Redact(ctx, password, text, files)
+	})
+}
+
+// substitute reads path, transforms it with fn and writes the result back or
+// to stdout. A rewritten file keeps its permissions unless secure is set,
+// which restricts it to the owner. Prompts go to stderr when writing to
+// stdout.
+func substitute(ctx context.Context, path string, toStdout bool, action string, secure bool, fn func(*core.LockEnv, []byte, []byte) ([]byte, int, error)) {
+	info, err := os.Stat(path)
+	if err != nil {
+		HandleError(err)
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		HandleError(err)
+	}
+
+	stdout := os.Stdout
+	if toStdout {
+		os.Stdout = os.Stderr
+	}
+
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	result, count, err := fn(lockenv, password, text)
+	if err != nil {
+		HandleError(err)
+	}
+
+	os.Stdout = stdout
+	if toStdout {
+		_, _ = os.Stdout.Write(result)
+		crypto.ClearBytes(result)
+		return
+	}
+	if count == 0 {
+		fmt.Printf("%s: nothing to replace\n", path)
+		return
+	}
+
+	perm := info.Mode().Perm()
+	if secure {
+		perm = core.FilePermSecure
+	}
+	if err := core.WriteFileAtomic(path, result, perm); err != nil {
+		HandleError(err)
+	}
+	crypto.ClearBytes(result)
+	fmt.Printf("%s: %s (%d replaced)\n", action, path, count)
+}
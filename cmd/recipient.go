@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"golang.org/x/crypto/ssh"
+)
+
+// RecipientAddSSH adds an ssh-ed25519 public key as a vault recipient
+func RecipientAddSSH(pubKeyPath string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	authorizedKey, err := os.ReadFile(pubKeyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot read %s: %s\n", pubKeyPath, err)
+		os.Exit(1)
+	}
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	recipient, err := lockenv.AddSSHRecipient(password, authorizedKey)
+	if err != nil {
+		HandleError(err)
+	}
+
+	fmt.Printf("added recipient: %s %s\n", recipient.Fingerprint, recipient.Comment)
+}
+
+// kmsTimeout bounds each KMS CLI call, so unreachable endpoints fall back to
+// the password quickly
+const kmsTimeout = 30 * time.Second
+
+// RecipientAddKMS adds a cloud KMS key as a vault recipient
+func RecipientAddKMS(ctx context.Context, keyID string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	ctx, cancel := context.WithTimeout(ctx, kmsTimeout)
+	defer cancel()
+
+	recipient, err := lockenv.AddKMSRecipient(ctx, password, keyID)
+	if err != nil {
+		HandleError(err)
+	}
+
+	fmt.Printf("added recipient: %s %s\n", recipient.Type, recipient.KeyID)
+}
+
+// RecipientList shows the vault's recipients
+func RecipientList() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	recipients, err := lockenv.ListRecipients()
+	if err != nil {
+		HandleError(err)
+	}
+
+	if len(recipients) == 0 {
+		fmt.Println("No recipients")
+		return
+	}
+
+	for _, r := range recipients {
+		fmt.Printf("%s  %s  %s\n", r.Type, r.Fingerprint, r.Comment)
+	}
+}
+
+// RecipientRemove removes a recipient by fingerprint or comment
+func RecipientRemove(query string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	recipient, err := lockenv.RemoveRecipient(password, query)
+	if err != nil {
+		HandleError(err)
+	}
+
+	fmt.Printf("removed recipient: %s %s\n", recipient.Fingerprint, recipient.Comment)
+	fmt.Println("Note: run 'lockenv passwd' to revoke access to the current vault key")
+}
+
+// sshIdentityPath returns the SSH private key used to unlock the vault
+func sshIdentityPath() string {
+	if path := os.Getenv("LOCKENV_SSH_IDENTITY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "id_ed25519")
+}
+
+// readSSHIdentity reads the ed25519 private key at path. If the key is
+// encrypted, the passphrase is prompted for when stdin is a terminal and
+// mayPrompt accepts the key's public half.
+func readSSHIdentity(path string, mayPrompt func(ssh.PublicKey) bool) (ed25519.PrivateKey, error) {
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rawKey, err := ssh.ParseRawPrivateKey(pemData)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if !IsTerminal() || !mayPrompt(missing.PublicKey) {
+			return nil, err
+		}
+		passphrase, perr := core.ReadPassword(fmt.Sprintf("Enter passphrase for %s: ", path))
+		if perr != nil {
+			return nil, perr
+		}
+		rawKey, err = ssh.ParseRawPrivateKeyWithPassphrase(pemData, passphrase)
+		crypto.ClearBytes(passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := rawKey.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *ed25519.PrivateKey:
+		return *k, nil
+	default:
+		return nil, crypto.ErrUnsupportedKey
+	}
+}
+
+// useSSHIdentity installs the vault key unwrapped with the user's SSH key, if
+// the vault has a matching recipient. Returns true on success.
+func useSSHIdentity(lockenv *core.LockEnv) bool {
+	recipients, err := lockenv.ListRecipients()
+	if err != nil || len(recipients) == 0 {
+		return false
+	}
+
+	path := sshIdentityPath()
+	// Only prompt for a passphrase if this key can actually unlock the vault
+	privateKey, err := readSSHIdentity(path, func(pub ssh.PublicKey) bool {
+		return hasRecipientFor(lockenv, pub)
+	})
+	if err != nil {
+		return false
+	}
+	defer crypto.ClearBytes(privateKey)
+
+	key, err := lockenv.KeyFromSSHIdentity(privateKey)
+	if err != nil {
+		if err != core.ErrNoMatchingKey {
+			fmt.Fprintf(os.Stderr, "Warning: cannot unlock with %s: %s\n", path, err)
+		}
+		return false
+	}
+	lockenv.UseKey(key)
+	crypto.ClearBytes(key)
+
+	if err := lockenv.VerifyPassword(nil); err != nil {
+		lockenv.UseKey(nil)
+		fmt.Fprintf(os.Stderr, "Warning: recipient key for %s is stale, ask a teammate to re-add it\n", path)
+		return false
+	}
+	return true
+}
+
+// useKMS installs the vault key unwrapped with a KMS recipient, if the cloud
+// CLI is installed and its credentials may decrypt. Returns true on success.
+// Set LOCKENV_KMS=0 to skip KMS recipients.
+func useKMS(lockenv *core.LockEnv) bool {
+	if os.Getenv("LOCKENV_KMS") == "0" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kmsTimeout)
+	defer cancel()
+
+	key, err := lockenv.KeyFromKMS(ctx)
+	if err != nil {
+		if errors.Is(err, core.ErrNoKMSAccess) && !IsTerminal() {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+		}
+		return false
+	}
+	lockenv.UseKey(key)
+	crypto.ClearBytes(key)
+
+	if err := lockenv.VerifyPassword(nil); err != nil {
+		lockenv.UseKey(nil)
+		fmt.Fprintln(os.Stderr, "Warning: KMS recipient key is stale, run 'lockenv recipient add-kms' again")
+		return false
+	}
+	return true
+}
+
+// hasRecipientFor reports whether the vault has a recipient for the public key
+func hasRecipientFor(lockenv *core.LockEnv, pub ssh.PublicKey) bool {
+	if pub == nil {
+		return false
+	}
+	recipients, err := lockenv.ListRecipients()
+	if err != nil {
+		return false
+	}
+	fingerprint := ssh.FingerprintSHA256(pub)
+	for _, r := range recipients {
+		if r.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
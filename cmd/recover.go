@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/keyring"
+	"github.com/illarion/lockenv/internal/session"
+)
+
+// printRecoveryKey shows a new recovery key and how to keep it
+func printRecoveryKey(key string) {
+	fmt.Println()
+	fmt.Println("Recovery key (shown only once; print it or store it offline):")
+	fmt.Println()
+	fmt.Printf("    %s\n", key)
+	fmt.Println()
+	fmt.Println("Anyone with this key can set a new vault password with 'lockenv recover'.")
+}
+
+// Recover sets a new vault password with the recovery key, for when the
+// password is lost. The key is prompted for if not given. A new recovery
+// key replaces the used one and is printed.
+func Recover(ctx context.Context, recoveryKey string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	if recoveryKey == "" {
+		key, err := core.ReadPassword("Enter recovery key: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		recoveryKey = string(key)
+		crypto.ClearBytes(key)
+	}
+
+	// Get new password (env var or prompt with confirmation)
+	newPassword, err := GetPasswordForInit()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	defer crypto.ClearBytes(newPassword)
+
+	lockenv.SetKDFTargetTime(loadConfig().KDFTargetTime)
+	next, err := lockenv.Recover(recoveryKey, newPassword)
+	if err != nil {
+		HandleError(err)
+	}
+
+	// Cached session keys and saved passwords belong to the old password
+	if vaultID, _ := lockenv.GetVaultID(); vaultID != "" {
+		_ = session.End(vaultID)
+		if err := keyring.SavePassword(vaultID, string(newPassword)); err == nil {
+			fmt.Println("Keyring updated with new password")
+		}
+	}
+
+	fmt.Println("password reset with the recovery key")
+	printRecoveryKey(next)
+	runHook(ctx, core.HookPostPasswd, nil)
+}
+
+// RecoverNewKey creates a recovery key for the vault, replacing any previous
+// one, and prints it
+func RecoverNewKey() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	key, err := lockenv.CreateRecoveryKey(password)
+	if err != nil {
+		HandleError(err)
+	}
+	printRecoveryKey(key)
+}
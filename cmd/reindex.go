@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Reindex regenerates the plaintext index from the encrypted vault
+func Reindex(ctx context.Context) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, _ := lockenv.GetVaultID()
+
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	result, err := lockenv.Reindex(ctx, password)
+	if err != nil {
+		HandleError(err)
+	}
+
+	for _, path := range result.Corrected {
+		fmt.Printf("corrected: %s\n", path)
+	}
+	for _, path := range result.Unreadable {
+		fmt.Printf("warning: %s: contents unreadable, indexed from metadata\n", path)
+	}
+	fmt.Printf("reindexed: %d files\n", len(result.Indexed)+len(result.Corrected)+len(result.Unreadable))
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Render executes templatePath as a Go text/template with the variables from
+// the vault's dotenv files and writes the result to output, or stdout if
+// output is empty. files and profile select the dotenv files as for Env.
+func Render(ctx context.Context, templatePath, output, profile string, files []string) {
+	text, err := os.ReadFile(templatePath)
+	if err != nil {
+		HandleError(err)
+	}
+
+	// Prompts go to stderr when the result is written to stdout
+	stdout := os.Stdout
+	if output == "" {
+		os.Stdout = os.Stderr
+	}
+
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	entries, err := lockenv.Env(ctx, password, envProfile(profile), files)
+	if err != nil {
+		HandleError(err)
+	}
+
+	rendered, err := core.RenderTemplate(filepath.Base(templatePath), text, entries)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(rendered)
+
+	os.Stdout = stdout
+	if output == "" {
+		_, _ = os.Stdout.Write(rendered)
+		return
+	}
+	if err := core.WriteSecretFile(output, rendered); err != nil {
+		HandleError(err)
+	}
+	fmt.Printf("rendered: %s -> %s\n", templatePath, output)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Require marks files in the vault as required, or optional again with
+// optional set, so 'unlock --strict' fails when they are not restored.
+// Without paths, the required files are listed.
+func Require(ctx context.Context, paths []string, optional bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if len(paths) == 0 {
+		required, err := lockenv.RequiredFiles(ctx, password)
+		if err != nil {
+			HandleError(err)
+		}
+		if len(required) == 0 {
+			fmt.Println("No required files")
+			fmt.Println("Run 'lockenv require <file>' to mark them")
+			return
+		}
+		for _, path := range required {
+			fmt.Println(path)
+		}
+		return
+	}
+
+	changed, err := lockenv.SetRequired(ctx, password, paths, !optional)
+	if err != nil {
+		HandleError(err)
+	}
+	state := "required"
+	if optional {
+		state = "optional"
+	}
+	if len(changed) == 0 {
+		fmt.Printf("unchanged: files already %s\n", state)
+		return
+	}
+	for _, path := range changed {
+		fmt.Printf("%s: %s\n", state, path)
+	}
+}
@@ -17,7 +17,7 @@ func Remove(ctx context.Context, patterns []string) {
 		os.Exit(1)
 	}
 
-	lockenv, err := core.New(".")
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
@@ -27,27 +27,35 @@ func Remove(ctx context.Context, patterns []string) {
 	vaultID, _ := lockenv.GetVaultID()
 
 	// Get password with retry on stale keyring
-	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv.VerifyPassword)
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
 	if err != nil {
 		HandleError(err)
 	}
 	defer crypto.ClearBytes(password)
 
 	// Remove files from vault
-	if err := lockenv.RemoveFiles(ctx, patterns, password); err != nil {
+	events, err := lockenv.RemoveFiles(ctx, patterns, password)
+	printEvents(events)
+	if err != nil {
 		HandleError(err)
 	}
+	if events.Count(core.EventRemoved) == 0 {
+		fmt.Println("No matching files found in vault")
+	}
 
 	// Re-encrypt to save updated state
-	if err := lockenv.FinalizeLock(ctx, password, false); err != nil {
+	setDeterministic(lockenv)
+	report, err := lockenv.FinalizeLock(ctx, password, false, nil)
+	switch err {
+	case nil:
+		printLockReport(report, "encrypted")
+		fmt.Println(lockSummary(report))
+	case core.ErrNoTrackedFiles:
 		// If there are no files left in vault, that's okay
-		if err != core.ErrNoTrackedFiles {
-			HandleError(err)
-		}
+	default:
+		HandleError(err)
 	}
 
 	// Compact database to reclaim space
-	if err := lockenv.Compact(); err != nil {
-		fmt.Fprintf(os.Stderr, "warning: compaction failed: %s\n", err)
-	}
+	autoCompact(lockenv)
 }
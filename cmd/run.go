@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// runWaitDelay is how long a command may take to exit after being
+// interrupted before it is killed
+const runWaitDelay = 10 * time.Second
+
+// Run executes command with the variables from the vault's dotenv files added
+// to its environment, then exits with the command's status. files and
+// profile select the dotenv files as for Env. With redact set, occurrences of
+// the values in the command's stdout and stderr are replaced with ***.
+func Run(ctx context.Context, profile string, files []string, redact bool, command []string) {
+	entries := envEntries(ctx, profile, files)
+
+	child := exec.CommandContext(ctx, command[0], command[1:]...)
+	child.Stdin = os.Stdin
+	child.Env = os.Environ()
+	values := make([]string, 0, len(entries))
+	for _, e := range entries {
+		child.Env = append(child.Env, e.Name+"="+e.Value)
+		values = append(values, e.Value)
+	}
+	// On Ctrl-C or SIGTERM, give the command a chance to shut down cleanly
+	child.Cancel = func() error { return child.Process.Signal(os.Interrupt) }
+	child.WaitDelay = runWaitDelay
+
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	var filters []*core.SecretFilter
+	if redact {
+		out, errOut := core.NewSecretFilter(os.Stdout, values), core.NewSecretFilter(os.Stderr, values)
+		stdout, stderr = out, errOut
+		filters = append(filters, out, errOut)
+	}
+	child.Stdout = stdout
+	child.Stderr = stderr
+
+	err := child.Run()
+	for _, f := range filters {
+		_ = f.Flush()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		os.Exit(0)
+	case errors.As(err, &exitErr):
+		if code := exitErr.ExitCode(); code >= 0 {
+			os.Exit(code)
+		}
+		// Terminated by a signal
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(127)
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/ignore"
+)
+
+// setScanIgnore applies the scan.ignore setting from the config files
+func setScanIgnore(lockenv *core.LockEnv) {
+	lockenv.SetScanIgnore(loadConfig().ScanIgnore)
+}
+
+// Scan lists the files in the project that look like secrets but are not in
+// the vault, with the reason each was reported. Exits with status 1 if any
+// are found, so it can guard CI or a pre-commit hook. Files marked as not
+// secret are only listed with includeIgnored and do not affect the exit
+// status. No password is required and the vault need not exist.
+func Scan(ctx context.Context, includeIgnored bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	setScanIgnore(lockenv)
+	found, err := lockenv.DiscoverSecretFiles(ctx, includeIgnored)
+	if err != nil {
+		HandleError(err)
+	}
+
+	reported := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range found {
+		if f.Ignored != "" {
+			fmt.Fprintf(w, "%s\t%s\t(ignored: %s)\n", f.Path, f.Reason, f.Ignored)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", f.Path, f.Reason)
+		reported++
+	}
+	w.Flush()
+
+	if reported == 0 {
+		fmt.Fprintln(os.Stderr, "No untracked files look like secrets")
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n%d untracked file(s) look like secrets; lock them with 'lockenv lock <file>',\n", reported)
+	fmt.Fprintf(os.Stderr, "mark them as not secret with 'lockenv scan --ignore <path>' or list them in %s\n", ignore.FileName)
+	os.Exit(1)
+}
+
+// ScanIgnore marks paths and globs as not secret, or with unignore removes
+// the marks, in the vault's list that scan and status consult
+func ScanIgnore(patterns []string, unignore bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if unignore {
+		removed, err := lockenv.UnmarkNonSecret(password, patterns)
+		if err != nil {
+			HandleError(err)
+		}
+		if len(removed) == 0 {
+			fmt.Println("No matching paths were marked as not secret")
+		}
+		for _, pattern := range removed {
+			fmt.Printf("unignored: %s\n", pattern)
+		}
+		return
+	}
+
+	added, err := lockenv.MarkNonSecret(password, patterns)
+	if err != nil {
+		HandleError(err)
+	}
+	if len(added) == 0 {
+		fmt.Println("Already marked as not secret")
+	}
+	for _, pattern := range added {
+		fmt.Printf("ignored: %s\n", pattern)
+	}
+}
+
+// ScanIgnored prints the paths and globs marked as not secret, from the
+// vault and the scan.ignore setting (no password required)
+func ScanIgnored() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	for _, pattern := range loadConfig().ScanIgnore {
+		fmt.Printf("%s (%s)\n", pattern, core.ConfigFile)
+	}
+	patterns, err := lockenv.NonSecretPatterns()
+	if err != nil && err != core.ErrNotInitialized {
+		HandleError(err)
+	}
+	for _, pattern := range patterns {
+		fmt.Println(pattern)
+	}
+}
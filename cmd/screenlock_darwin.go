@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+// screenLockedKey matches the console session's lock flag in ioreg output
+var screenLockedKey = regexp.MustCompile(`"CGSSessionScreenIsLocked"\s*=\s*Yes`)
+
+// screenLocked reports whether the console session's screen is locked, the
+// state com.apple.screenIsLocked announces, read from the I/O Registry
+func screenLocked(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ioreg", "-n", "Root", "-d1").Output()
+	return err == nil && screenLockedKey.Match(out)
+}
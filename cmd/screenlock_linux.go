@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// screenLocked reports whether the user's graphical session is locked, from
+// the LockedHint systemd-logind keeps for it. Desktops that do not set the
+// hint and systems without logind are never reported as locked.
+func screenLocked(ctx context.Context) bool {
+	session := os.Getenv("XDG_SESSION_ID")
+	if session == "" {
+		session = "self"
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "loginctl", "show-session", session, "--property=LockedHint", "--value").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "yes"
+}
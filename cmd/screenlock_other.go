@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package cmd
+
+import "context"
+
+// screenLocked always reports false: screen locks are only detected on
+// Linux and macOS
+func screenLocked(ctx context.Context) bool {
+	return false
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// chooseFiles lets the user pick files from candidates with an interactive
+// checklist. Returns false if the user cancelled or selected nothing.
+func chooseFiles(title string, candidates []core.Candidate) ([]string, bool) {
+	paths, err := core.SelectFiles(title, candidates)
+	if errors.Is(err, core.ErrSelectionCancelled) {
+		fmt.Println("Cancelled")
+		return nil, false
+	}
+	if err != nil {
+		HandleError(err)
+	}
+	if len(paths) == 0 {
+		fmt.Println("No files selected")
+		return nil, false
+	}
+	return paths, true
+}
+
+// chooseFilesToUnlock offers every file stored in the vault, all selected,
+// with its status in the working tree
+func chooseFilesToUnlock(ctx context.Context, lockenv *core.LockEnv) ([]string, bool) {
+	status, err := lockenv.Status(ctx, false)
+	if err != nil {
+		HandleError(err)
+	}
+
+	var candidates []core.Candidate
+	for _, f := range status.Files {
+		// Files that were never locked have nothing to unlock
+		if f.Status == "tracked, never locked" {
+			continue
+		}
+		candidates = append(candidates, core.Candidate{Path: f.Path, Note: f.Status, Selected: true})
+	}
+	if len(candidates) == 0 {
+		return nil, true
+	}
+	return chooseFiles("Select files to unlock:", candidates)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/illarion/lockenv/internal/selfupdate"
+)
+
+// SelfUpdate replaces the running binary with the latest release, or only
+// reports whether one is available with checkOnly
+func SelfUpdate(ctx context.Context, current string, checkOnly bool) {
+	updater := selfupdate.New()
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		HandleError(err)
+	}
+
+	if !selfupdate.Newer(release.Version, current) {
+		fmt.Printf("lockenv %s is up to date\n", current)
+		return
+	}
+	if checkOnly {
+		fmt.Printf("lockenv %s is available (current: %s)\n", release.Version, current)
+		fmt.Println("Run 'lockenv selfupdate' to install it")
+		return
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		HandleError(fmt.Errorf("cannot locate the lockenv binary: %w", err))
+	}
+
+	binary, err := updater.Download(ctx, release)
+	if err != nil {
+		HandleError(err)
+	}
+	if err := selfupdate.Install(exe, binary); err != nil {
+		HandleError(err)
+	}
+
+	fmt.Printf("updated %s: %s -> %s\n", exe, current, release.Version)
+}
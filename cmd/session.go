@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/session"
+)
+
+// SessionStart derives the vault key once and caches it for ttl
+func SessionStart(ctx context.Context, ttl time.Duration) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, err := lockenv.GetOrCreateVaultID()
+	if err != nil {
+		HandleError(err)
+	}
+
+	password, source, err := GetPasswordWithSource("Enter password: ", vaultID)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	key, err := lockenv.DeriveKey(ctx, password)
+	if err == nil || err == core.ErrWrongPassword {
+		recordUnlock(vaultID, err == nil, source)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(key)
+
+	expires, err := session.Start(vaultID, key, ttl)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to start session: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Session started (expires %s)\n", expires.Local().Format("2006-01-02 15:04:05"))
+}
+
+// SessionEnd removes the cached session key
+func SessionEnd() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, err := lockenv.GetVaultID()
+	if err != nil {
+		fmt.Println("No active session")
+		return
+	}
+
+	if err := session.End(vaultID); err != nil {
+		fmt.Println("No active session")
+		return
+	}
+
+	fmt.Println("Session ended")
+}
+
+// SessionStatus shows whether a session is active for the vault
+func SessionStatus() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, err := lockenv.GetVaultID()
+	if err != nil {
+		fmt.Println("Session: not active")
+		return
+	}
+
+	expires, err := session.Status(vaultID)
+	if err != nil {
+		fmt.Println("Session: not active")
+		return
+	}
+
+	fmt.Printf("Session: active (expires %s)\n", expires.Local().Format("2006-01-02 15:04:05"))
+}
+
+// useSession installs the cached session key on lockenv if a valid session exists.
+// Stale sessions (e.g. after a password change) are removed.
+func useSession(lockenv *core.LockEnv, vaultID string) bool {
+	key, err := session.Load(vaultID)
+	if err != nil {
+		if err == session.ErrExpired {
+			fmt.Fprintln(os.Stderr, "Session expired")
+		}
+		return false
+	}
+	lockenv.UseKey(key)
+	crypto.ClearBytes(key)
+
+	if err := lockenv.VerifyPassword(nil); err != nil {
+		lockenv.UseKey(nil)
+		_ = session.End(vaultID)
+		fmt.Fprintln(os.Stderr, "Warning: session key is no longer valid, removing session")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// PromptIcon prefixes the number of unlocked files in the prompt indicator
+const PromptIcon = "🔓"
+
+// ShellHook outputs a snippet that adds an unlocked-secrets indicator to the prompt
+func ShellHook(shell string) {
+	switch shell {
+	case "bash":
+		fmt.Print(bashHook)
+	case "zsh":
+		fmt.Print(zshHook)
+	case "fish":
+		fmt.Print(fishHook)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown shell: %s\nSupported: bash, zsh, fish\n", shell)
+		os.Exit(1)
+	}
+}
+
+// StatusPrompt prints the prompt indicator, or nothing if no secrets are
+// unlocked. Errors are swallowed so a broken vault never breaks the prompt.
+func StatusPrompt(ctx context.Context) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		return
+	}
+	defer lockenv.Close()
+
+	files, err := lockenv.UnlockedFiles(ctx)
+	if err != nil || len(files) == 0 {
+		return
+	}
+	fmt.Printf("%s%d\n", PromptIcon, len(files))
+}
+
+const bashHook = `_lockenv_prompt() {
+    local info
+    info=$(lockenv status --prompt 2>/dev/null)
+    LOCKENV_PROMPT=${info:+"$info "}
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_lockenv_prompt;"* ]]; then
+    PROMPT_COMMAND="_lockenv_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+if [[ $PS1 != *LOCKENV_PROMPT* ]]; then
+    PS1='${LOCKENV_PROMPT}'"$PS1"
+fi
+`
+
+const zshHook = `_lockenv_prompt() {
+    local info
+    info=$(lockenv status --prompt 2>/dev/null)
+    LOCKENV_PROMPT=${info:+"$info "}
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook precmd _lockenv_prompt
+setopt prompt_subst
+if [[ $PROMPT != *LOCKENV_PROMPT* ]]; then
+    PROMPT='${LOCKENV_PROMPT}'"$PROMPT"
+fi
+`
+
+const fishHook = `if not functions -q __lockenv_orig_fish_prompt
+    functions -c fish_prompt __lockenv_orig_fish_prompt
+    function fish_prompt
+        set -l info (lockenv status --prompt 2>/dev/null)
+        if test -n "$info"
+            echo -n "$info "
+        end
+        __lockenv_orig_fish_prompt
+    end
+end
+`
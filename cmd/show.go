@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/output"
+)
+
+// showTimeFormat is how show prints timestamps
+const showTimeFormat = "2006-01-02 15:04:05"
+
+// Show prints the details of a file in the vault. The content is printed
+// only if content is set.
+func Show(ctx context.Context, path string, content bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	details, err := lockenv.Show(ctx, password, path)
+	if err != nil {
+		HandleError(err)
+	}
+
+	p := output.Stdout()
+	fmt.Println(p.Bold(details.Path))
+	if details.Status != "" {
+		fmt.Printf("   Status:        %s\n", p.Status(details.Status, details.Status))
+	}
+	size := core.FormatSize(details.Size)
+	if details.EncryptedSize > 0 {
+		size += fmt.Sprintf(" (%s encrypted)", core.FormatSize(details.EncryptedSize))
+	}
+	fmt.Printf("   Size:          %s\n", size)
+	fmt.Printf("   Mode:          %s\n", os.FileMode(details.Mode).Perm())
+	if details.LocalMode != 0 && details.LocalMode != os.FileMode(details.Mode).Perm() {
+		fmt.Printf("   Local mode:    %s\n", details.LocalMode)
+	}
+	fmt.Printf("   Modified:      %s (when locked)\n", details.ModTime.Local().Format(showTimeFormat))
+	if !details.LocalModTime.IsZero() {
+		fmt.Printf("   Local mtime:   %s\n", details.LocalModTime.Local().Format(showTimeFormat))
+	}
+	fmt.Printf("   Hash:          %s\n", details.Hash)
+	if details.Domain != "" {
+		fmt.Printf("   Domain:        %s\n", details.Domain)
+	}
+	if details.Required {
+		fmt.Printf("   Required:      yes\n")
+	}
+	if details.Note != "" {
+		fmt.Printf("   Note:          %s\n", details.Note)
+	}
+	if len(details.History) > 0 {
+		fmt.Printf("   Lock history:\n")
+		for i := len(details.History) - 1; i >= 0; i-- {
+			event := details.History[i]
+			fmt.Printf("      %s  %s\n", event.Time.Local().Format(showTimeFormat), p.Dim(shortHash(event.Hash)))
+		}
+	}
+
+	if !content {
+		return
+	}
+	r, err := lockenv.OpenVaultFile(ctx, password, details.Path)
+	if err != nil {
+		HandleError(err)
+	}
+	defer r.Close()
+	fmt.Println()
+	if _, err := io.Copy(os.Stdout, r); err != nil {
+		HandleError(err)
+	}
+}
+
+// shortHash abbreviates a content hash for display
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
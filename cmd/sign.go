@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"golang.org/x/crypto/ssh"
+)
+
+// signManifest signs the vault manifest with the SSH identity
+// (LOCKENV_SSH_IDENTITY, default ~/.ssh/id_ed25519)
+func signManifest(ctx context.Context, lockenv *core.LockEnv) {
+	path := sshIdentityPath()
+	key, err := readSSHIdentity(path, func(ssh.PublicKey) bool { return true })
+	if err != nil {
+		HandleError(fmt.Errorf("cannot read signing key %s: %w", path, err))
+	}
+	defer crypto.ClearBytes(key)
+
+	signature, err := lockenv.SignManifest(ctx, key)
+	if err != nil {
+		HandleError(err)
+	}
+	fmt.Printf("signed: manifest with %s\n", signerFingerprint(signature.PublicKey))
+}
+
+// requireSignature verifies the manifest signature against the keys in
+// signersFile, exiting on failure, and makes later unlocks check it too.
+// The signer is reported on out.
+func requireSignature(ctx context.Context, out io.Writer, lockenv *core.LockEnv, signersFile string) {
+	signers, err := core.LoadSigners(signersFile)
+	if err != nil {
+		HandleError(fmt.Errorf("cannot load trusted signers: %w", err))
+	}
+	signature, err := lockenv.VerifySignature(ctx, signers)
+	if err != nil {
+		HandleError(err)
+	}
+	lockenv.RequireSignature(signers)
+	fmt.Fprintf(out, "signature: signed by %s on %s (generation %d)\n", signerFingerprint(signature.PublicKey),
+		signature.Signed.Local().Format("2006-01-02 15:04:05"), signature.Generation)
+}
+
+// signerFingerprint returns the SHA256 fingerprint of an authorized_keys line
+func signerFingerprint(publicKey string) string {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return publicKey
+	}
+	return ssh.FingerprintSHA256(key)
+}
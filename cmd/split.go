@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Split writes the files matching patterns into a new vault at output.
+// With newPassword set, or when no password was entered (session, SSH or
+// KMS unlock), the new vault's password is prompted for.
+func Split(ctx context.Context, patterns []string, output string, newPassword bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	vaultID, _ := lockenv.GetVaultID()
+
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	var outputPassword []byte
+	if newPassword || len(password) == 0 {
+		fmt.Fprintf(os.Stderr, "Password for %s\n", output)
+		outputPassword, err = core.ReadPasswordConfirm()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		defer crypto.ClearBytes(outputPassword)
+	}
+
+	lockenv.SetKDFTargetTime(loadConfig().KDFTargetTime)
+	result, err := lockenv.Split(ctx, password, patterns, output, outputPassword)
+	if err != nil {
+		HandleError(err)
+	}
+
+	printEvents(result.Events)
+	for _, path := range result.Copied {
+		fmt.Printf("copied: %s\n", path)
+	}
+	fmt.Printf("split: %d files into %s\n", len(result.Copied), output)
+	if len(result.Skipped) > 0 {
+		fmt.Printf("skipped: %d files\n", len(result.Skipped))
+	}
+}
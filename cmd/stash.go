@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Stash locks and removes all unlocked secrets so they can be restored later
+func Stash(ctx context.Context) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	setDeterministic(lockenv)
+	report, err := lockenv.Stash(ctx, password)
+	switch err {
+	case nil:
+		printLockReport(report, "encrypted")
+	case core.ErrNothingToStash:
+		fmt.Println("No unlocked files to stash")
+		return
+	case core.ErrStashExists:
+		fmt.Fprintf(os.Stderr, "Error: a stash already exists\n")
+		fmt.Fprintf(os.Stderr, "Run 'lockenv stash pop' to restore it first\n")
+		os.Exit(1)
+	default:
+		HandleError(err)
+	}
+
+	fmt.Printf("\nstashed: %d files (restore with 'lockenv stash pop')\n", len(report.Removed))
+}
+
+// StashPop restores the files removed by Stash
+func StashPop(ctx context.Context, force bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	stash, err := lockenv.GetStash()
+	if err != nil {
+		HandleError(err)
+	}
+	if stash == nil {
+		fmt.Println("No stash to pop")
+		return
+	}
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	strategy := loadConfig().Strategy
+	if force {
+		strategy = core.StrategyUseVault
+	}
+	if strategy == core.StrategyAsk {
+		setMergeOptions(lockenv)
+	}
+
+	result, err := lockenv.StashPop(ctx, password, strategy)
+	if result != nil {
+		printEvents(result.Events)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+
+	fmt.Printf("\nrestored: %d files from stash\n", len(result.Extracted))
+	if len(result.Skipped) > 0 {
+		fmt.Printf("skipped: %d files\n", len(result.Skipped))
+	}
+}
+
+// StashSnapshot copies the files with local edits into an encrypted snapshot
+func StashSnapshot(ctx context.Context) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	stash, err := lockenv.StashSnapshot(ctx, password)
+	switch err {
+	case nil:
+	case core.ErrNothingToStash:
+		fmt.Println("No local edits to snapshot")
+		return
+	case core.ErrStashExists:
+		fmt.Fprintf(os.Stderr, "Error: a snapshot already exists\n")
+		fmt.Fprintf(os.Stderr, "Run 'lockenv stash pop --snapshot' to restore it first\n")
+		os.Exit(1)
+	default:
+		HandleError(err)
+	}
+
+	for _, path := range stash.Files {
+		fmt.Printf("snapshotted: %s\n", path)
+	}
+	fmt.Printf("\nsnapshotted: %d files (restore with 'lockenv stash pop --snapshot')\n", len(stash.Files))
+}
+
+// PopSnapshot restores the files copied by StashSnapshot
+func PopSnapshot(ctx context.Context, force bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	stash, err := lockenv.GetSnapshot()
+	if err != nil {
+		HandleError(err)
+	}
+	if stash == nil {
+		fmt.Println("No snapshot to pop")
+		return
+	}
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	strategy := loadConfig().Strategy
+	if force {
+		strategy = core.StrategyUseVault
+	}
+	if strategy == core.StrategyAsk {
+		setMergeOptions(lockenv)
+	}
+
+	result, err := lockenv.PopSnapshot(ctx, password, strategy)
+	if result != nil {
+		printEvents(result.Events)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+
+	fmt.Printf("\nrestored: %d files from snapshot\n", len(result.Extracted))
+	if len(result.Skipped) > 0 {
+		fmt.Printf("skipped: %d files\n", len(result.Skipped))
+	}
+}
+
+// StashShow prints the files in the current stash and snapshot
+func StashShow() {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	stash, err := lockenv.GetStash()
+	if err != nil {
+		HandleError(err)
+	}
+	// Outside a git repository there is no snapshot
+	snapshot, _ := lockenv.GetSnapshot()
+	if stash == nil && snapshot == nil {
+		fmt.Println("No stash")
+		return
+	}
+
+	if stash != nil {
+		fmt.Printf("Stash created %s (%d files):\n", stash.Created.Format("2006-01-02 15:04:05"), len(stash.Files))
+		for _, path := range stash.Files {
+			fmt.Printf("   %s\n", path)
+		}
+	}
+	if snapshot != nil {
+		fmt.Printf("Snapshot created %s (%d files):\n", snapshot.Created.Format("2006-01-02 15:04:05"), len(snapshot.Files))
+		for _, path := range snapshot.Files {
+			fmt.Printf("   %s\n", path)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// Stats shows how space in the vault is used
+func Stats(ctx context.Context) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get stats (no password required)
+	stats, err := lockenv.Stats(ctx)
+	if err != nil {
+		HandleError(err)
+	}
+
+	fmt.Printf("\nVault Statistics\n")
+	fmt.Printf("===========================================\n\n")
+
+	fmt.Printf("Database:\n")
+	fmt.Printf("   File size:      %s\n", core.FormatSize(stats.FileSize))
+	fmt.Printf("   Page size:      %s\n", core.FormatSize(int64(stats.PageSize)))
+	fmt.Printf("   Free pages:     %d (%s reclaimable by 'lockenv compact')\n", stats.FreePages, core.FormatSize(stats.FreeBytes()))
+	if !stats.Created.IsZero() {
+		fmt.Printf("   Created:        %s\n", stats.Created.Format("2006-01-02 15:04:05"))
+	}
+	if !stats.LastLocked.IsZero() {
+		fmt.Printf("   Last locked:    %s\n", stats.LastLocked.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Println()
+
+	fmt.Printf("Files (largest first):\n")
+	if len(stats.Files) == 0 {
+		fmt.Println("   (no files in vault)")
+	} else {
+		for _, file := range stats.Files {
+			encrypted := core.FormatSize(file.EncryptedSize)
+			if file.EncryptedSize == 0 {
+				encrypted = "not encrypted yet"
+			}
+			fmt.Printf("   %s\n", file.Path)
+			fmt.Printf("      size: %s, encrypted: %s, modified: %s\n",
+				core.FormatSize(file.Size), encrypted, file.ModTime.Format("2006-01-02 15:04:05"))
+		}
+	}
+	fmt.Println()
+
+	fmt.Printf("Totals:\n")
+	fmt.Printf("   Plaintext:      %s\n", core.FormatSize(stats.TotalSize))
+	fmt.Printf("   Encrypted:      %s\n", core.FormatSize(stats.EncryptedSize))
+
+	// Only files that have been encrypted count towards the overhead
+	var sealedSize int64
+	for _, file := range stats.Files {
+		if file.EncryptedSize > 0 {
+			sealedSize += file.Size
+		}
+	}
+	if sealedSize > 0 {
+		fmt.Printf("   Overhead:       %.1f%% (nonce and auth tag per file, no compression)\n",
+			float64(stats.EncryptedSize-sealedSize)*100/float64(sealedSize))
+	}
+
+	fmt.Printf("\n===========================================\n")
+}
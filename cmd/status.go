@@ -4,31 +4,17 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
 
 	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
 	"github.com/illarion/lockenv/internal/git"
+	"github.com/illarion/lockenv/internal/output"
+	"github.com/illarion/lockenv/internal/storage"
 )
 
-// formatSize formats bytes into human-readable format
-func formatSize(bytes int64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-		GB = MB * 1024
-	)
-
-	switch {
-	case bytes >= GB:
-		return fmt.Sprintf("%.2f GB", float64(bytes)/GB)
-	case bytes >= MB:
-		return fmt.Sprintf("%.2f MB", float64(bytes)/MB)
-	case bytes >= KB:
-		return fmt.Sprintf("%.2f KB", float64(bytes)/KB)
-	default:
-		return fmt.Sprintf("%d bytes", bytes)
-	}
-}
-
 // getStatusIcon returns an icon for each status
 func getStatusIcon(status string) string {
 	switch status {
@@ -38,6 +24,8 @@ func getStatusIcon(status string) string {
 		return "*"
 	case "unchanged":
 		return "."
+	case "tracked, never locked":
+		return "+"
 	case "error":
 		return "!"
 	default:
@@ -45,9 +33,30 @@ func getStatusIcon(status string) string {
 	}
 }
 
-// Status shows the current state of lockenv
-func Status(ctx context.Context) {
-	lockenv, err := core.New(".")
+// StatusOptions controls which files ls/status lists and how
+type StatusOptions struct {
+	Full     bool     // Hash every tracked file instead of trusting size and mtime
+	Filter   string   // Only list files with this status (modified, unchanged, vault-only, never-locked)
+	Sort     string   // Sort files by name, size or mtime
+	Columns  string   // Comma-separated extra columns (size, mtime, mode, hash, note)
+	Long     bool     // Show size, mtime, mode and note columns
+	Patterns []string // Only list files matching these paths or globs
+	ExitCode bool     // Exit with status 1 unless every matching file is unchanged
+}
+
+// Status shows the current state of lockenv. With opts.ExitCode set, it
+// exits with status 1 if a file matching opts.Patterns is not unchanged.
+func Status(ctx context.Context, opts StatusOptions) {
+	spec := opts.Columns
+	if opts.Long {
+		spec = core.LongColumns + "," + spec
+	}
+	columns, err := core.ParseStatusColumns(spec)
+	if err != nil {
+		HandleError(err)
+	}
+
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
@@ -65,55 +74,242 @@ func Status(ctx context.Context) {
 	}
 
 	// Get status (no password required)
-	status, err := lockenv.Status(ctx)
+	status, err := lockenv.Status(ctx, opts.Full)
+	if err != nil {
+		HandleError(err)
+	}
+	matched := core.MatchFileStatuses(status.Files, opts.Patterns)
+	files, err := core.FilterFileStatuses(matched, opts.Filter)
 	if err != nil {
 		HandleError(err)
 	}
+	if err := core.SortFileStatuses(files, opts.Sort); err != nil {
+		HandleError(err)
+	}
+	if slices.Contains(columns, "note") && len(files) > 0 {
+		addNotes(ctx, lockenv, files)
+	}
 
 	// Show header
 	fmt.Printf("\nVault Status\n")
 	fmt.Printf("===========================================\n\n")
 
+	// Show what the vault protects, if recorded with meta set
+	if len(status.Info) > 0 {
+		fmt.Printf("About:\n")
+		printVaultInfo(status.Info, "   ")
+		fmt.Println()
+	}
+
 	// Show statistics
 	fmt.Printf("Statistics:\n")
 	fmt.Printf("   Files in vault: %d\n", status.TrackedCount)
-	fmt.Printf("   Total size:     %s\n", formatSize(status.TotalSize))
+	fmt.Printf("   Total size:     %s\n", core.FormatSize(status.TotalSize))
 	if !status.LastSealed.IsZero() {
 		fmt.Printf("   Last locked:    %s\n", status.LastSealed.Format("2006-01-02 15:04:05"))
 	}
 	fmt.Printf("   Encryption:     %s (PBKDF2 iterations: %d)\n", status.Algorithm, status.KDFIterations)
-	fmt.Printf("   Version:        %d\n\n", status.Version)
+	fmt.Printf("   Version:        %d\n", status.Version)
+	if status.Layout == storage.LayoutDir {
+		fmt.Printf("   Layout:         dir (file contents in %s/)\n", storage.ObjectsDir)
+	}
+	fmt.Printf("   Generation:     %d\n\n", status.Generation)
 
 	// Show file state summary
+	p := output.Stdout()
 	if status.TrackedCount > 0 {
 		fmt.Printf("Summary:\n")
 		if status.UnchangedCount > 0 {
-			fmt.Printf("   .  %d unchanged\n", status.UnchangedCount)
+			fmt.Printf("   %s\n", p.Status("unchanged", fmt.Sprintf(".  %d unchanged", status.UnchangedCount)))
 		}
 		if status.ModifiedCount > 0 {
-			fmt.Printf("   *  %d modified\n", status.ModifiedCount)
+			fmt.Printf("   %s\n", p.Status("modified", fmt.Sprintf("*  %d modified", status.ModifiedCount)))
 		}
 		if status.SealedCount > 0 {
-			fmt.Printf("   *  %d vault only\n", status.SealedCount)
+			fmt.Printf("   %s\n", p.Status("vault only", fmt.Sprintf("*  %d vault only", status.SealedCount)))
+		}
+		if status.NeverLockedCount > 0 {
+			fmt.Printf("   %s\n", p.Status("tracked, never locked", fmt.Sprintf("+  %d tracked, never locked", status.NeverLockedCount)))
 		}
 		fmt.Println()
 	}
 
 	// Show files in vault
 	fmt.Printf("Files:\n")
-	if len(status.Files) == 0 {
+	switch {
+	case len(status.Files) == 0:
 		fmt.Println("   (no files in vault)")
-	} else {
-		for _, file := range status.Files {
-			icon := getStatusIcon(file.Status)
-			fmt.Printf("   %s %s (%s)\n", icon, file.Path, file.Status)
+	case len(files) == 0:
+		fmt.Println("   (no matching files)")
+	case len(columns) > 0:
+		printFileTable(files, columns, p)
+	default:
+		for _, file := range files {
+			icon := p.Status(file.Status, getStatusIcon(file.Status))
+			fmt.Printf("   %s %s %s\n", icon, file.Path, p.Status(file.Status, "("+file.Status+")"))
 		}
 	}
 
+	// Show tracked directories
+	if len(status.Directories) > 0 {
+		fmt.Printf("\nDirectories (tracked recursively):\n")
+		for _, dir := range status.Directories {
+			fmt.Printf("   %s/\n", dir)
+		}
+	}
+
+	// Show pending relock timer
+	if status.Relock != nil {
+		fmt.Printf("\nRelock: %d files at %s", len(status.Relock.Files), status.Relock.Expires.Local().Format("2006-01-02 15:04:05"))
+		if time.Now().After(status.Relock.Expires) {
+			fmt.Printf(" (overdue, run 'lockenv guard')")
+		}
+		fmt.Println()
+	}
+
 	// Show git integration status
 	if status.GitStatus != nil {
 		fmt.Print(git.FormatGitStatus(status.GitStatus))
 	}
 
+	// Point out password guessing on this machine
+	vaultID, _ := lockenv.GetVaultID()
+	printFailedUnlocks(vaultID)
+
+	// Point out untracked files that look like secrets; a listing narrowed
+	// by patterns or --filter is about the vault only
+	if len(opts.Patterns) == 0 && opts.Filter == "" {
+		setScanIgnore(lockenv)
+		if found, err := lockenv.DiscoverSecretFiles(ctx, false); err == nil && len(found) > 0 {
+			fmt.Printf("\nHint: %d untracked file(s) look like secrets (run 'lockenv scan')\n", len(found))
+		}
+	}
+
+	fmt.Printf("\n===========================================\n")
+
+	if opts.ExitCode && core.HasChanges(matched) {
+		os.Exit(1)
+	}
+}
+
+// StatusAll summarizes every vault under the monorepo root: the top of the
+// git working tree, or the current directory outside git. With exitCode set,
+// it exits with status 1 if any vault has a file that is not unchanged.
+func StatusAll(ctx context.Context, full bool, exitCode bool) {
+	root := "."
+	if top, err := git.TopLevel("."); err == nil {
+		root = top
+	}
+
+	summaries, totals, err := core.StatusAll(ctx, root, full)
+	if err != nil {
+		HandleError(err)
+	}
+	if len(summaries) == 0 {
+		fmt.Printf("No .lockenv file found under %s\n", root)
+		fmt.Println("Run 'lockenv init' to create one")
+		return
+	}
+
+	fmt.Printf("\nVaults under %s\n", root)
+	fmt.Printf("===========================================\n\n")
+
+	p := output.Stdout()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, summary := range summaries {
+		if summary.Err != nil {
+			fmt.Fprintf(w, "   %s %s\t%s\n", p.Status("error", getStatusIcon("error")), summary.Dir, summary.Err)
+			continue
+		}
+		status := summary.Status
+		icon := p.Status("unchanged", getStatusIcon("unchanged"))
+		if status.ModifiedCount > 0 || status.NeverLockedCount > 0 {
+			icon = p.Status("modified", getStatusIcon("modified"))
+		}
+		fmt.Fprintf(w, "   %s %s\t%d files\t%d modified\t%d vault only\t%d never locked\t%s\n",
+			icon, summary.Dir, status.TrackedCount, status.ModifiedCount, status.SealedCount,
+			status.NeverLockedCount, core.FormatSize(status.TotalSize))
+	}
+	w.Flush()
+
+	fmt.Printf("\nTotal: %d vaults, %d files (%d unchanged, %d modified, %d vault only, %d never locked), %s\n",
+		totals.Vaults, totals.Files, totals.Unchanged, totals.Modified, totals.Sealed, totals.NeverLocked,
+		core.FormatSize(totals.Size))
 	fmt.Printf("\n===========================================\n")
+
+	if totals.Failed > 0 {
+		fmt.Fprintf(os.Stderr, "Error: status of %d vaults could not be read\n", totals.Failed)
+		os.Exit(1)
+	}
+	if exitCode && totals.Modified+totals.Sealed+totals.NeverLocked > 0 {
+		os.Exit(1)
+	}
+}
+
+// addNotes fills in the notes of files, asking for the password as they are
+// encrypted
+func addNotes(ctx context.Context, lockenv *core.LockEnv, files []core.FileStatus) {
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	notes, err := lockenv.Notes(ctx, password)
+	if err != nil {
+		HandleError(err)
+	}
+	for i := range files {
+		files[i].Note = notes[files[i].Path]
+	}
+}
+
+// printFileTable lists files with the selected extra columns, aligned
+func printFileTable(files []core.FileStatus, columns []string, p output.Palette) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, file := range files {
+		icon := p.Status(file.Status, getStatusIcon(file.Status))
+		fmt.Fprintf(w, "   %s %s\t%s", icon, file.Path, p.Status(file.Status, "("+file.Status+")"))
+		for _, col := range columns {
+			fmt.Fprintf(w, "\t%s", formatFileColumn(file, col))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+// formatFileColumn formats a single ls column for file
+func formatFileColumn(file core.FileStatus, column string) string {
+	switch column {
+	case "size":
+		return core.FormatSize(file.Size)
+	case "mtime":
+		if file.ModTime.IsZero() {
+			return "-"
+		}
+		return file.ModTime.Local().Format("2006-01-02 15:04:05")
+	case "mode":
+		if file.Mode == 0 {
+			return "-"
+		}
+		return file.Mode.String()
+	case "note":
+		if file.Note == "" {
+			return "-"
+		}
+		return file.Note
+	case "hash":
+		if len(file.Hash) > 12 {
+			return file.Hash[:12]
+		}
+		if file.Hash == "" {
+			return "-"
+		}
+		return file.Hash
+	}
+	return ""
 }
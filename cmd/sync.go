@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/remote"
+)
+
+// resolveRemote picks the remote URL: flag, then last synced remote, then LOCKENV_REMOTE
+func resolveRemote(lockenv *core.LockEnv, rawURL string) remote.Remote {
+	if rawURL == "" {
+		rawURL = lockenv.RemoteURL()
+	}
+	if rawURL == "" {
+		rawURL = os.Getenv("LOCKENV_REMOTE")
+	}
+	if rawURL == "" {
+		fmt.Fprintf(os.Stderr, "Error: no remote configured\n")
+		fmt.Fprintf(os.Stderr, "Use --remote <url> or set LOCKENV_REMOTE\n")
+		os.Exit(1)
+	}
+
+	rem, err := remote.New(rawURL)
+	if err != nil {
+		HandleError(err)
+	}
+	return rem
+}
+
+// Push uploads the vault to a remote
+func Push(ctx context.Context, rawURL string, force bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	rem := resolveRemote(lockenv, rawURL)
+
+	// Vault stays encrypted (no password required)
+	pushed, err := lockenv.Push(ctx, rem, force)
+	if errors.Is(err, remote.ErrConflict) {
+		fmt.Fprintf(os.Stderr, "Error: remote vault changed since last sync\n")
+		fmt.Fprintf(os.Stderr, "Run 'lockenv pull' first, or use --force to overwrite it\n")
+		os.Exit(1)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+
+	if !pushed {
+		fmt.Printf("%s is up to date\n", rem)
+		return
+	}
+	fmt.Printf("pushed: %s\n", rem)
+}
+
+// Pull replaces the vault with the one from a remote
+func Pull(ctx context.Context, rawURL string, force bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	rem := resolveRemote(lockenv, rawURL)
+
+	pulled, err := lockenv.Pull(ctx, rem, force)
+	switch {
+	case errors.Is(err, core.ErrLocalChanges):
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Run 'lockenv push' first, or use --force to overwrite them\n")
+		os.Exit(1)
+	case errors.Is(err, core.ErrStaleRemote):
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Run 'lockenv push --force' to restore it, or use --force to pull it anyway\n")
+		os.Exit(1)
+	case errors.Is(err, core.ErrVaultIDMismatch):
+		fmt.Fprintf(os.Stderr, "Error: %s holds a different vault\n", rem)
+		fmt.Fprintf(os.Stderr, "Use --force to replace the local vault anyway\n")
+		os.Exit(1)
+	case errors.Is(err, remote.ErrNotFound):
+		fmt.Fprintf(os.Stderr, "Error: no vault at %s\n", rem)
+		fmt.Fprintf(os.Stderr, "Use 'lockenv push' to upload one\n")
+		os.Exit(1)
+	case err != nil:
+		HandleError(err)
+	}
+
+	if !pulled {
+		fmt.Println("Vault is up to date")
+		return
+	}
+	fmt.Printf("pulled: %s\n", rem)
+	fmt.Println("Run 'lockenv unlock' to update local files")
+}
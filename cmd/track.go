@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Track adds files to the vault without encrypting their content.
+// The files are sealed by a later 'lockenv lock'. allowLarge lifts the
+// max_file_size limit.
+func Track(ctx context.Context, patterns []string, recursive bool, allowLarge bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	setMaxFileSize(lockenv, allowLarge)
+	report, err := lockenv.TrackFiles(ctx, patterns, password, recursive)
+	if err != nil {
+		HandleError(err)
+	}
+	printLockReport(report, "tracking")
+	if len(report.Locked) == 0 {
+		fmt.Println("No files to track")
+		return
+	}
+
+	fmt.Printf("tracked: %d files (run 'lockenv lock' to encrypt them)\n", len(report.Locked))
+}
@@ -4,13 +4,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/illarion/lockenv/internal/core"
 	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/output"
+	"golang.org/x/term"
 )
 
-// Unlock extracts files from .lockenv with smart conflict resolution
-func Unlock(ctx context.Context, patterns []string, force bool, keepLocal bool, keepBoth bool) {
+// Unlock extracts files from .lockenv with smart conflict resolution.
+// If relockAfter is positive, the unlocked files are put on a relock timer.
+// Files in an encryption domain are only unlocked if domain names it.
+// preserve selects which recorded attributes (mode, owner, xattrs) are restored.
+// Without patterns, a terminal user picks the files from a checklist unless all is set.
+// With strict set, exits with status 1 if a required file was not restored.
+func Unlock(ctx context.Context, patterns []string, force bool, keepLocal bool, keepBoth bool, all bool, relockAfter time.Duration, domain string, preserve core.PreserveLevel, signersFile string, strict bool) {
 	// Validate mutually exclusive flags
 	flagCount := boolToInt(force) + boolToInt(keepLocal) + boolToInt(keepBoth)
 	if flagCount > 1 {
@@ -18,23 +26,72 @@ func Unlock(ctx context.Context, patterns []string, force bool, keepLocal bool,
 		os.Exit(1)
 	}
 
-	lockenv, err := core.New(".")
+	lockenv, err := openLockEnv()
 	if err != nil {
 		HandleError(err)
 	}
 	defer lockenv.Close()
 
+	if len(patterns) == 0 && !all && IsTerminal() {
+		selected, ok := chooseFilesToUnlock(ctx, lockenv)
+		if !ok {
+			return
+		}
+		patterns = selected
+	}
+
 	// Get vault ID for keyring lookup
 	vaultID, _ := lockenv.GetVaultID()
 
 	// Get password with retry on stale keyring
-	password, source, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv.VerifyPassword)
+	password, source, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
 	if err != nil {
 		HandleError(err)
 	}
 	defer crypto.ClearBytes(password)
 
-	// Determine merge strategy
+	if domain != "" {
+		unlockDomain(lockenv, domain)
+	}
+	openReferencedVaults(lockenv)
+	lockenv.Preserve(preserve)
+	if signersFile != "" {
+		requireSignature(ctx, os.Stdout, lockenv, signersFile)
+	}
+
+	// Unlock files with smart merge
+	strategy := unlockStrategy(lockenv, force, keepLocal, keepBoth)
+	result, err := lockenv.Unlock(ctx, password, strategy, patterns)
+	if err != nil {
+		HandleError(err)
+	}
+	printUnlockSummary(ctx, result)
+
+	// Unchanged files skipped by unlock are plaintext too
+	if relockAfter > 0 {
+		files := append(append([]string{}, result.Extracted...), result.Skipped...)
+		timer, err := lockenv.SetRelockTimer(files, relockAfter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to set relock timer: %s\n", err)
+		} else {
+			fmt.Printf("relock: at %s (run 'lockenv guard' to enforce)\n", timer.Expires.Local().Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	// Offer to save password if it was entered manually
+	if source == SourcePrompt {
+		vaultID, err := lockenv.GetOrCreateVaultID()
+		if err != nil {
+			return
+		}
+		OfferToSavePassword(vaultID, password)
+	}
+	requireRestored(result, strict)
+}
+
+// unlockStrategy returns the merge strategy selected by the unlock flags,
+// or the configured one
+func unlockStrategy(lockenv *core.LockEnv, force, keepLocal, keepBoth bool) core.MergeStrategy {
 	var strategy core.MergeStrategy
 	switch {
 	case force:
@@ -44,16 +101,19 @@ func Unlock(ctx context.Context, patterns []string, force bool, keepLocal bool,
 	case keepBoth:
 		strategy = core.StrategyKeepBoth
 	default:
-		strategy = core.StrategyAsk
+		strategy = loadConfig().Strategy
 	}
-
-	// Unlock files with smart merge
-	result, err := lockenv.Unlock(ctx, password, strategy, patterns)
-	if err != nil {
-		HandleError(err)
+	if strategy == core.StrategyAsk {
+		setMergeOptions(lockenv)
 	}
+	return strategy
+}
 
-	// Print summary
+// printUnlockSummary prints what happened to each file, the counts of an
+// unlock and the required files it did not restore, and runs the
+// post-unlock hook for the extracted files
+func printUnlockSummary(ctx context.Context, result *core.UnlockResult) {
+	printEvents(result.Events)
 	fmt.Printf("\n")
 	if len(result.Extracted) > 0 {
 		fmt.Printf("unlocked: %d files\n", len(result.Extracted))
@@ -64,13 +124,169 @@ func Unlock(ctx context.Context, patterns []string, force bool, keepLocal bool,
 	if len(result.Errors) > 0 {
 		fmt.Printf("error: %d errors occurred\n", len(result.Errors))
 	}
+	warning := output.Stdout().Yellow("warning:")
+	for _, path := range result.MissingRequired {
+		fmt.Printf("%s required file not restored: %s\n", warning, path)
+	}
+	if len(result.Extracted) > 0 {
+		runHook(ctx, core.HookPostUnlock, result.Extracted)
+	}
+}
 
-	// Offer to save password if it was entered manually
-	if source == SourcePrompt {
-		vaultID, err := lockenv.GetOrCreateVaultID()
+// UnlockTar writes the decrypted files matching patterns as a tar archive to
+// dest, or to stdout if dest is "-", without touching the working tree.
+// Prompts, notices and the summary go to stderr so the archive can be piped
+// into tar -x; writing it to a terminal is refused. A file dest is created
+// readable by the owner only. Files in an encryption domain are only
+// included if domain names it. preserve selects which recorded attributes
+// the entries carry. With strict set, exits with status 1 if a required file
+// was left out.
+func UnlockTar(ctx context.Context, dest string, patterns []string, domain string, preserve core.PreserveLevel, signersFile string, strict bool) {
+	if dest == "-" && term.IsTerminal(int(os.Stdout.Fd())) {
+		HandleError(fmt.Errorf("refusing to write the archive to a terminal (redirect stdout or use --tar <file>)"))
+	}
+
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+	lockenv.SetNotify(func(event core.FileEvent) { printNoticeTo(os.Stderr, event) })
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if domain != "" {
+		unlockDomain(lockenv, domain)
+	}
+	openReferencedVaults(lockenv)
+	lockenv.Preserve(preserve)
+	if signersFile != "" {
+		requireSignature(ctx, os.Stderr, lockenv, signersFile)
+	}
+
+	w := os.Stdout
+	if dest != "-" {
+		if w, err = createSecure(dest); err != nil {
+			HandleError(err)
+		}
+	}
+	result, err := lockenv.UnlockTar(ctx, password, patterns, w)
+	if dest != "-" {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
 		if err != nil {
-			return
+			os.Remove(dest)
 		}
-		OfferToSavePassword(vaultID, password)
 	}
+	if err != nil {
+		HandleError(err)
+	}
+
+	for _, path := range result.Skipped {
+		fmt.Fprintf(os.Stderr, "skipped: %s\n", path)
+	}
+	for _, msg := range result.Errors {
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	}
+	for _, path := range result.MissingRequired {
+		fmt.Fprintf(os.Stderr, "warning: required file not archived: %s\n", path)
+	}
+	fmt.Fprintf(os.Stderr, "archived: %d files\n", len(result.Extracted))
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+	requireRestored(result, strict)
+}
+
+// createSecure creates or truncates the file at path for writing, readable
+// by the owner only even if it already existed with a wider mode
+func createSecure(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, core.FilePermSecure)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(core.FilePermSecure); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// requireRestored exits with status 1 if strict is set and the unlock in
+// result did not restore every required file
+func requireRestored(result *core.UnlockResult, strict bool) {
+	if !strict || len(result.MissingRequired) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: %d required files not restored (--strict)\n", len(result.MissingRequired))
+	os.Exit(1)
+}
+
+// Deploy unlocks the files matching patterns straight onto a remote host
+// over SSH, never writing plaintext locally. target is [user@]host:dir.
+// Files in an encryption domain are only deployed if domain names it;
+// preserve selects which recorded attributes they keep.
+func Deploy(ctx context.Context, target string, patterns []string, domain string, preserve core.PreserveLevel, signersFile string) {
+	dest, err := core.ParseDeployTarget(target)
+	if err != nil {
+		HandleError(err)
+	}
+
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	if domain != "" {
+		unlockDomain(lockenv, domain)
+	}
+	openReferencedVaults(lockenv)
+	lockenv.Preserve(preserve)
+	if signersFile != "" {
+		requireSignature(ctx, os.Stdout, lockenv, signersFile)
+	}
+
+	result, err := lockenv.Deploy(ctx, password, dest, patterns)
+	if err != nil {
+		HandleError(err)
+	}
+
+	for _, path := range result.Extracted {
+		fmt.Printf("deployed: %s\n", path)
+	}
+	for _, path := range result.Skipped {
+		fmt.Printf("skipped: %s\n", path)
+	}
+	for _, msg := range result.Errors {
+		fmt.Printf("error: %s\n", msg)
+	}
+	fmt.Printf("deployed: %d files to %s\n", len(result.Extracted), dest)
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// setMergeOptions applies the editor merge settings from the config files
+func setMergeOptions(lockenv *core.LockEnv) {
+	lockenv.SetMergeOptions(loadConfig().Merge)
 }
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Validate checks the vault files against the committed schema, printing
+// every missing key and malformed value without showing it. With local set,
+// the working-tree copies are checked without a password. Exits with status
+// 1 if anything violates the schema.
+func Validate(ctx context.Context, files []string, local bool) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	var password []byte
+	if !local {
+		vaultID, _ := lockenv.GetVaultID()
+
+		password, _, err = GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+		if err != nil {
+			HandleError(err)
+		}
+		defer crypto.ClearBytes(password)
+	}
+
+	result, err := lockenv.Validate(ctx, password, files, local)
+	if err != nil {
+		HandleError(err)
+	}
+
+	for _, v := range result.Violations {
+		fmt.Printf("invalid: %s\n", v)
+	}
+
+	if !result.OK() {
+		fmt.Printf("\nvalidate failed: %d problems in %d files\n", len(result.Violations), len(result.Files))
+		os.Exit(1)
+	}
+	fmt.Printf("valid: %d files match %s\n", len(result.Files), core.SchemaFile)
+}
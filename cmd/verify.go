@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Verify checks that the vault contents match the manifest.
+// With paths set, it instead compares local files against the manifest hashes
+// without a password. Exits with status 1 if anything drifted; when strict is
+// set, missing files also cause a failure. With signersFile set, the manifest
+// must also be signed by one of the keys listed in it.
+func Verify(ctx context.Context, paths bool, patterns []string, strict bool, signersFile string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	if signersFile != "" {
+		requireSignature(ctx, os.Stdout, lockenv, signersFile)
+	}
+
+	var result *core.VerifyResult
+	if paths {
+		result, err = lockenv.VerifyPaths(ctx, patterns)
+	} else {
+		vaultID, _ := lockenv.GetVaultID()
+
+		password, _, perr := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+		if perr != nil {
+			HandleError(perr)
+		}
+		defer crypto.ClearBytes(password)
+
+		result, err = lockenv.VerifyVault(ctx, password)
+	}
+	if err != nil {
+		HandleError(err)
+	}
+
+	driftLabel, missingLabel := "modified", "missing"
+	if !paths {
+		driftLabel, missingLabel = "corrupt", "unreadable"
+	}
+	printEvents(result.Events)
+	for _, path := range result.Drifted {
+		fmt.Printf("%s: %s\n", driftLabel, path)
+	}
+	for _, path := range result.Missing {
+		fmt.Printf("%s: %s\n", missingLabel, path)
+	}
+	for _, path := range result.Extra {
+		fmt.Printf("untracked: %s\n", path)
+	}
+
+	if !result.OK(strict) {
+		fmt.Printf("\nverify failed: %d %s, %d %s, %d untracked\n",
+			len(result.Drifted), driftLabel, len(result.Missing), missingLabel, len(result.Extra))
+		os.Exit(1)
+	}
+	fmt.Printf("verified: %d files OK\n", len(result.Unchanged))
+}
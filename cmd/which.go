@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Which prints the dotenv files in the vault that define the variable name,
+// as path:line. Exits with status 1 if no file defines it.
+func Which(ctx context.Context, name string) {
+	lockenv, err := openLockEnv()
+	if err != nil {
+		HandleError(err)
+	}
+	defer lockenv.Close()
+
+	// Get vault ID for keyring lookup
+	vaultID, _ := lockenv.GetVaultID()
+
+	// Get password with retry on stale keyring
+	password, _, err := GetPasswordWithRetry("Enter password: ", vaultID, lockenv)
+	if err != nil {
+		HandleError(err)
+	}
+	defer crypto.ClearBytes(password)
+
+	locations, events, err := lockenv.Which(ctx, password, name)
+	if err != nil {
+		HandleError(err)
+	}
+
+	// Keep stdout to the locations
+	for _, event := range events {
+		fmt.Fprintln(os.Stderr, event)
+	}
+
+	if len(locations) == 0 {
+		fmt.Fprintf(os.Stderr, "%s is not defined in any dotenv file in the vault\n", name)
+		os.Exit(1)
+	}
+	for _, loc := range locations {
+		fmt.Printf("%s:%d\n", loc.Path, loc.Line)
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// WorkspaceAdd registers vault directories in the workspace of the current
+// directory
+func WorkspaceAdd(dirs []string) {
+	added, err := core.AddWorkspaceVaults(".", dirs)
+	if err != nil {
+		HandleError(err)
+	}
+	if len(added) == 0 {
+		fmt.Println("workspace unchanged: vaults already registered")
+		return
+	}
+	for _, vault := range added {
+		fmt.Printf("added: %s\n", vault)
+	}
+}
+
+// WorkspaceRemove drops vault directories from the workspace of the current
+// directory
+func WorkspaceRemove(dirs []string) {
+	if err := core.RemoveWorkspaceVaults(".", dirs); err != nil {
+		HandleError(err)
+	}
+	for _, dir := range dirs {
+		fmt.Printf("removed: %s\n", dir)
+	}
+}
+
+// WorkspaceList prints the vaults registered in the workspace of the
+// current directory
+func WorkspaceList() {
+	vaults, err := core.ReadWorkspace(".")
+	if err != nil {
+		HandleError(err)
+	}
+	if len(vaults) == 0 {
+		fmt.Println("No vaults in the workspace")
+		fmt.Println("Run 'lockenv ws add <dir>...' to register them")
+		return
+	}
+	for _, vault := range vaults {
+		if _, err := os.Stat(filepath.Join(core.WorkspaceVaultDir(".", vault), core.LockEnvFile)); err != nil {
+			fmt.Printf("%s (missing)\n", vault)
+			continue
+		}
+		fmt.Println(vault)
+	}
+}
+
+// WorkspaceUnlock unlocks every file of every vault in the workspace,
+// asking once for the password of each distinct vault. Copies of a vault
+// (same vault ID) in other directories reuse its password.
+func WorkspaceUnlock(ctx context.Context, force, keepLocal, keepBoth bool) {
+	if boolToInt(force)+boolToInt(keepLocal)+boolToInt(keepBoth) > 1 {
+		fmt.Fprintf(os.Stderr, "error: --force, --keep-local, and --keep-both are mutually exclusive\n")
+		os.Exit(1)
+	}
+
+	vaults, err := core.ReadWorkspace(".")
+	if err != nil {
+		HandleError(err)
+	}
+	if len(vaults) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no vaults in the workspace (run 'lockenv ws add <dir>...')")
+		os.Exit(1)
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		HandleError(err)
+	}
+
+	passwords := make(map[string][]byte)
+	defer func() {
+		for _, password := range passwords {
+			crypto.ClearBytes(password)
+		}
+	}()
+
+	failed := 0
+	for _, vault := range vaults {
+		if err := ctx.Err(); err != nil {
+			HandleError(err)
+		}
+		fmt.Printf("\n== %s ==\n", vault)
+		err := unlockWorkspaceVault(ctx, core.WorkspaceVaultDir(root, vault), vault, force, keepLocal, keepBoth, passwords)
+		if chdirErr := os.Chdir(root); chdirErr != nil {
+			HandleError(chdirErr)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", vault, err)
+			failed++
+		}
+	}
+
+	fmt.Printf("\nworkspace: %d of %d vaults unlocked\n", len(vaults)-failed, len(vaults))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// unlockWorkspaceVault unlocks the vault in dir from within dir, so its
+// config, hooks and relative paths apply. passwords holds the passwords
+// entered so far by vault ID.
+func unlockWorkspaceVault(ctx context.Context, dir, name string, force, keepLocal, keepBoth bool, passwords map[string][]byte) error {
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	lockenv, err := core.NewAt(".")
+	if err != nil {
+		return err
+	}
+	defer lockenv.Close()
+
+	vaultID, err := lockenv.GetVaultID()
+	if err != nil {
+		return err
+	}
+
+	// A copy of a vault already unlocked takes the same password
+	password, known := passwords[vaultID]
+	prompted := false
+	if !known || vaultID == "" || lockenv.VerifyPassword(password) != nil {
+		var source PasswordSource
+		password, source, err = GetPasswordWithRetry(fmt.Sprintf("Enter password for %s: ", name), vaultID, lockenv)
+		if err != nil {
+			return err
+		}
+		prompted = source == SourcePrompt
+		if len(password) > 0 && vaultID != "" {
+			if old, ok := passwords[vaultID]; ok {
+				crypto.ClearBytes(old)
+			}
+			passwords[vaultID] = password
+		} else {
+			defer crypto.ClearBytes(password)
+		}
+	}
+
+	openReferencedVaults(lockenv)
+	strategy := unlockStrategy(lockenv, force, keepLocal, keepBoth)
+	result, err := lockenv.Unlock(ctx, password, strategy, nil)
+	if err != nil {
+		return err
+	}
+	printUnlockSummary(ctx, result)
+
+	if prompted {
+		if vaultID, err := lockenv.GetOrCreateVaultID(); err == nil {
+			OfferToSavePassword(vaultID, password)
+		}
+	}
+	return nil
+}
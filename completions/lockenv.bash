@@ -2,7 +2,7 @@ _lockenv() {
     local cur prev words cword
     _init_completion || return
 
-    local commands="init lock unlock rm ls status passwd diff compact keyring help completion"
+    local commands="init lock track unlock deploy rm ls status which scan env render inject redact run ci show note require passwd recover diff merge link ws compact stats backup migrate push pull clean shred verify check-parity validate fsck reindex split stash guard keyring session recipient domain meta help completion shell-hook git-hooks selfupdate"
 
     if [[ $cword -eq 1 ]]; then
         COMPREPLY=($(compgen -W "$commands" -- "$cur"))
@@ -12,30 +12,326 @@ _lockenv() {
     local cmd="${words[1]}"
     case "$cmd" in
         lock)
+            if [[ "$prev" == "--domain" ]]; then
+                _lockenv_domains
+            elif [[ "$prev" == "--as" ]]; then
+                _lockenv_vault_files
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "-r --remove -R --recursive --force --domain --no-content --allow-large --sign --strict -p --patch --show-secrets --stdin --as" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        track)
             if [[ "$cur" == -* ]]; then
-                COMPREPLY=($(compgen -W "-r --remove --force" -- "$cur"))
+                COMPREPLY=($(compgen -W "-R --recursive --allow-large" -- "$cur"))
             else
                 _filedir
             fi
             ;;
+        deploy)
+            if [[ "$prev" == "--domain" ]]; then
+                _lockenv_domains
+            elif [[ "$prev" == "--files" ]]; then
+                _lockenv_vault_files
+            elif [[ "$prev" == "--signers" ]]; then
+                _filedir
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--files --domain --preserve-mode --preserve-all --require-signature --signers" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _known_hosts_real -c -- "$cur"
+            else
+                _lockenv_vault_files
+            fi
+            ;;
         unlock)
+            if [[ "$prev" == "--domain" ]]; then
+                _lockenv_domains
+            elif [[ "$prev" == "--signers" || "$prev" == "--tar" ]]; then
+                _filedir
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--force --keep-local --keep-both --all --for --domain --preserve-mode --preserve-all --require-signature --signers --tar --strict" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        ls|status)
+            case "$prev" in
+                --filter)
+                    COMPREPLY=($(compgen -W "modified unchanged vault-only never-locked" -- "$cur"))
+                    return
+                    ;;
+                --sort)
+                    COMPREPLY=($(compgen -W "name size mtime" -- "$cur"))
+                    return
+                    ;;
+                --columns)
+                    COMPREPLY=($(compgen -W "size mtime mode hash note" -- "$cur"))
+                    return
+                    ;;
+            esac
             if [[ "$cur" == -* ]]; then
-                COMPREPLY=($(compgen -W "--force --keep-local --keep-both" -- "$cur"))
+                COMPREPLY=($(compgen -W "--full --filter --sort --columns -l --long --prompt --all --exit-code" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        env)
+            case "$prev" in
+                --format)
+                    COMPREPLY=($(compgen -W "sh fish powershell dotenv json" -- "$cur"))
+                    return
+                    ;;
+                --profile)
+                    _lockenv_profiles
+                    return
+                    ;;
+            esac
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--profile --format" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        render)
+            case "$prev" in
+                -o|--output)
+                    _filedir
+                    return
+                    ;;
+                --profile)
+                    _lockenv_profiles
+                    return
+                    ;;
+            esac
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "-o --output --profile" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _filedir
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        show)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--content" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        note)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--clear" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _lockenv_vault_files
+            fi
+            ;;
+        require)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--optional" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        inject)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--stdout" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        redact)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--stdout" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _filedir
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        run)
+            local i
+            for ((i = 2; i < cword; i++)); do
+                if [[ "${words[i]}" == "--" ]]; then
+                    COMPREPLY=($(compgen -c -- "$cur"))
+                    return
+                fi
+            done
+            if [[ "$prev" == "--profile" ]]; then
+                _lockenv_profiles
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--profile --redact --" -- "$cur"))
             else
-                # Complete with files from vault
-                local files
-                files=$(lockenv ls 2>/dev/null | grep -E '^\s+[.*]' | sed 's/^.*[.*] //' | sed 's/ (.*//')
-                COMPREPLY=($(compgen -W "$files" -- "$cur"))
+                _lockenv_vault_files
             fi
             ;;
         rm)
-            # Complete with files from vault
-            local files
-            files=$(lockenv ls 2>/dev/null | grep -E '^\s+[.*]' | sed 's/^.*[.*] //' | sed 's/ (.*//')
-            COMPREPLY=($(compgen -W "$files" -- "$cur"))
+            _lockenv_vault_files
+            ;;
+        diff)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--rev --stat --hexdump --show-secrets" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        merge)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--force --keep-local --keep-both" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        link)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--as" -- "$cur"))
+            elif [[ $cword -eq 2 ]]; then
+                _filedir -d
+            fi
+            ;;
+        ws)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "add rm list unlock" -- "$cur"))
+            elif [[ "${words[2]}" == "unlock" ]]; then
+                COMPREPLY=($(compgen -W "--force --keep-local --keep-both" -- "$cur"))
+            elif [[ "${words[2]}" == "add" || "${words[2]}" == "rm" ]]; then
+                _filedir -d
+            fi
+            ;;
+        clean|shred)
+            COMPREPLY=($(compgen -W "--force" -- "$cur"))
+            ;;
+        scan)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--include-ignored --ignore --unignore --list-ignored" -- "$cur"))
+            else
+                _filedir
+            fi
+            ;;
+        verify)
+            if [[ "$prev" == "--signers" ]]; then
+                _filedir
+            else
+                COMPREPLY=($(compgen -W "--paths --strict --require-signature --signers" -- "$cur"))
+            fi
+            ;;
+        check-parity)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--local" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        validate)
+            if [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--local" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        fsck)
+            COMPREPLY=($(compgen -W "--repair" -- "$cur"))
+            ;;
+        split)
+            if [[ "$prev" == "--output" ]]; then
+                _filedir
+            elif [[ "$prev" == "--files" ]]; then
+                _lockenv_vault_files
+            elif [[ "$cur" == -* ]]; then
+                COMPREPLY=($(compgen -W "--files --output --new-password" -- "$cur"))
+            else
+                _lockenv_vault_files
+            fi
+            ;;
+        guard)
+            COMPREPLY=($(compgen -W "--interval" -- "$cur"))
+            ;;
+        push|pull)
+            COMPREPLY=($(compgen -W "--remote --force" -- "$cur"))
+            ;;
+        selfupdate)
+            COMPREPLY=($(compgen -W "--check" -- "$cur"))
+            ;;
+        passwd)
+            COMPREPLY=($(compgen -W "--rotate-salt-only" -- "$cur"))
+            ;;
+        recover)
+            COMPREPLY=($(compgen -W "--key --new-key" -- "$cur"))
+            ;;
+        init|migrate)
+            if [[ "$prev" == "--layout" ]]; then
+                COMPREPLY=($(compgen -W "file dir" -- "$cur"))
+            elif [[ "$cmd" == "init" ]]; then
+                COMPREPLY=($(compgen -W "--layout --recovery-key --import-existing" -- "$cur"))
+            else
+                COMPREPLY=($(compgen -W "--layout" -- "$cur"))
+            fi
+            ;;
+        backup)
+            if [[ "$prev" == "--dir" ]]; then
+                _filedir -d
+            else
+                COMPREPLY=($(compgen -W "--dir --keep" -- "$cur"))
+            fi
+            ;;
+        stash)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "pop show --snapshot" -- "$cur"))
+            elif [[ ${words[2]} == "pop" ]]; then
+                COMPREPLY=($(compgen -W "--force --snapshot" -- "$cur"))
+            fi
             ;;
         keyring)
-            COMPREPLY=($(compgen -W "save delete status" -- "$cur"))
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "save delete status" -- "$cur"))
+            elif [[ "${words[2]}" == "save" ]]; then
+                COMPREPLY=($(compgen -W "--ttl" -- "$cur"))
+            fi
+            ;;
+        session)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "start end status" -- "$cur"))
+            elif [[ "${words[2]}" == "start" ]]; then
+                COMPREPLY=($(compgen -W "--ttl" -- "$cur"))
+            fi
+            ;;
+        recipient)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "add-ssh add-kms list rm" -- "$cur"))
+            elif [[ "${words[2]}" == "add-ssh" ]]; then
+                _filedir pub
+            fi
+            ;;
+        domain)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "add list rm" -- "$cur"))
+            elif [[ $cword -eq 3 && "${words[2]}" == "rm" ]]; then
+                _lockenv_domains
+            fi
+            ;;
+        meta)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "show set unset" -- "$cur"))
+            elif [[ $cword -eq 3 && ("${words[2]}" == "set" || "${words[2]}" == "unset") ]]; then
+                COMPREPLY=($(compgen -W "description owner contact" -- "$cur"))
+            fi
+            ;;
+        ci)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "export-github export-gitlab" -- "$cur"))
+            elif [[ "$prev" == "-o" || "$prev" == "--output" ]]; then
+                _filedir
+            elif [[ "$prev" == "--profile" ]]; then
+                _lockenv_profiles
+            elif [[ "$cur" == -* ]]; then
+                if [[ "${words[2]}" == "export-gitlab" ]]; then
+                    COMPREPLY=($(compgen -W "-o --output --profile" -- "$cur"))
+                else
+                    COMPREPLY=($(compgen -W "--profile" -- "$cur"))
+                fi
+            else
+                _lockenv_vault_files
+            fi
             ;;
         help)
             COMPREPLY=($(compgen -W "$commands" -- "$cur"))
@@ -43,7 +339,32 @@ _lockenv() {
         completion)
             COMPREPLY=($(compgen -W "bash zsh fish powershell" -- "$cur"))
             ;;
+        shell-hook)
+            COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+            ;;
+        git-hooks)
+            if [[ $cword -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "install uninstall" -- "$cur"))
+            elif [[ "${words[2]}" == "install" ]]; then
+                COMPREPLY=($(compgen -W "--auto-unlock --stash" -- "$cur"))
+            fi
+            ;;
     esac
 }
 
+_lockenv_vault_files() {
+    local IFS=$'\n'
+    COMPREPLY=($(lockenv __complete files --shell bash "$cur" 2>/dev/null))
+}
+
+_lockenv_profiles() {
+    local IFS=$'\n'
+    COMPREPLY=($(lockenv __complete profiles --shell bash "$cur" 2>/dev/null))
+}
+
+_lockenv_domains() {
+    local IFS=$'\n'
+    COMPREPLY=($(lockenv __complete domains --shell bash "$cur" 2>/dev/null))
+}
+
 complete -F _lockenv lockenv
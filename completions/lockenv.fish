@@ -1,39 +1,272 @@
 # lockenv fish completions
 
-set -l commands init lock unlock rm ls status passwd diff compact keyring help completion
+set -l commands init lock track unlock deploy rm ls status which scan env render inject redact run ci show note require passwd recover diff merge link ws compact stats backup migrate push pull clean shred verify check-parity validate fsck reindex split stash guard keyring session recipient domain meta help completion shell-hook git-hooks selfupdate
 
 complete -c lockenv -f
 
 # Commands
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a init -d 'Create a .lockenv vault'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a lock -d 'Encrypt and store files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a track -d 'Add files without encrypting'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a unlock -d 'Decrypt and restore files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a deploy -d 'Unlock files onto a remote host over SSH'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a rm -d 'Remove files from vault'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a ls -d 'Show vault status'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a status -d 'Show vault status'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a which -d 'Find files defining a variable'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a scan -d 'List files that look like secrets'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a env -d 'Print variables as export statements'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a render -d 'Render a template with vault variables'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a inject -d 'Replace placeholders with vault values'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a redact -d 'Replace vault values with placeholders'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a passwd -d 'Change vault password'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a recover -d 'Reset a lost password'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a diff -d 'Compare vault with local'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a merge -d 'Merge another vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a link -d 'Reference a file in another vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a ws -d 'Unlock several vaults in one command'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a compact -d 'Compact vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a stats -d 'Show vault space usage'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a backup -d 'Save a copy of the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a migrate -d 'Upgrade the vault format'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a push -d 'Upload vault to a remote'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a pull -d 'Download vault from a remote'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a clean -d 'Remove unlocked plaintext files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a shred -d 'Overwrite and remove plaintext files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a verify -d 'Check vault integrity or local drift'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a check-parity -d 'Report variables missing from some env files'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a validate -d 'Check secret files against the schema'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a fsck -d 'Check the vault structure'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a reindex -d 'Rebuild the plaintext index'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a split -d 'Copy matching files into a new vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a stash -d 'Lock and remove plaintext secrets'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a guard -d 'Relock files when unlock timer expires'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a keyring -d 'Manage password in OS keyring'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a session -d 'Cache vault key for login session'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a recipient -d 'Manage SSH and KMS recipients'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a domain -d 'Manage encryption domains'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a show -d 'Show details of a file in the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a note -d 'Annotate a file in the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a require -d 'Mark files unlock --strict must restore'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a meta -d 'Describe the vault'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a run -d 'Run a command with vault variables'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a ci -d 'Export variables to GitHub Actions or GitLab CI'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a help -d 'Show help'
 complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a completion -d 'Generate completions'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a shell-hook -d 'Show unlocked secrets in prompt'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a git-hooks -d 'Remind to unlock after checkouts'
+complete -c lockenv -n "not __fish_seen_subcommand_from $commands" -a selfupdate -d 'Update lockenv to the latest release'
 
 # lock flags and files
 complete -c lockenv -n "__fish_seen_subcommand_from lock" -s r -d 'Remove original files'
 complete -c lockenv -n "__fish_seen_subcommand_from lock" -l remove -d 'Remove original files'
-complete -c lockenv -n "__fish_seen_subcommand_from lock" -l force -d 'Lock without confirmation'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -s R -l recursive -d 'Track directories recursively'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l force -d 'Lock without confirmation, accept large binaries'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l domain -x -a "(lockenv __complete domains --shell fish 2>/dev/null)" -d 'Encrypt with a domain password'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l no-content -d 'Track files without encrypting'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l allow-large -d 'Lock files larger than max_file_size'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l sign -d 'Sign the manifest with the SSH identity'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l strict -d 'Fail instead of warn on dotenv syntax issues'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -s p -l patch -d 'Pick the hunks of each file to lock'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l show-secrets -d 'Show values in the hunks offered by --patch'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l stdin -d 'Read the contents from stdin'
+complete -c lockenv -n "__fish_seen_subcommand_from lock" -l as -x -a "(lockenv __complete files --shell fish 2>/dev/null)" -d 'Vault path for the contents read with --stdin'
 complete -c lockenv -n "__fish_seen_subcommand_from lock" -F
 
+# track flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from track" -s R -l recursive -d 'Track directories recursively'
+complete -c lockenv -n "__fish_seen_subcommand_from track" -l allow-large -d 'Track files larger than max_file_size'
+complete -c lockenv -n "__fish_seen_subcommand_from track" -F
+
 # unlock flags
 complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l force -d 'Overwrite local files'
 complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l keep-local -d 'Keep local versions'
 complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l keep-both -d 'Keep both versions'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l all -d 'Unlock all files without the checklist'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l for -r -d 'Lock the files again after duration'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l domain -x -a "(lockenv __complete domains --shell fish 2>/dev/null)" -d 'Also unlock files in this domain'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l preserve-mode -d 'Restore the exact stored mode'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l preserve-all -d 'Restore the stored mode, owner and extended attributes'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l require-signature -d 'Require a trusted manifest signature'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l signers -r -F -d 'Trusted signing keys'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l tar -r -F -d 'Write a tar archive to a file or - for stdout'
+complete -c lockenv -n "__fish_seen_subcommand_from unlock" -l strict -d 'Fail if a required file is not restored'
+
+# deploy flags
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l files -x -a "(lockenv __complete files --shell fish 2>/dev/null)" -d 'Deploy only files matching the pattern'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l domain -x -a "(lockenv __complete domains --shell fish 2>/dev/null)" -d 'Also deploy files in this domain'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l preserve-mode -d 'Keep the exact stored mode'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l preserve-all -d 'Keep the stored mode, owner and extended attributes'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l require-signature -d 'Require a trusted manifest signature'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -l signers -r -F -d 'Trusted signing keys'
+complete -c lockenv -n "__fish_seen_subcommand_from deploy" -a "(__fish_print_hostnames)"
+
+# vault file arguments
+complete -c lockenv -n "__fish_seen_subcommand_from unlock rm env ls status; and not __fish_seen_subcommand_from keyring session recipient domain ws" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# status flags
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l full -d 'Hash every file'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l filter -x -a "modified unchanged vault-only never-locked" -d 'Only list files with this status'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l sort -x -a "name size mtime" -d 'Sort files'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l columns -x -a "size mtime mode hash note" -d 'Extra columns to show'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -s l -l long -d 'Show size, mtime, mode and note columns'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l prompt -d 'Short indicator for shell prompts'
+complete -c lockenv -n "__fish_seen_subcommand_from ls status; and not __fish_seen_subcommand_from keyring session" -l all -d 'Summarize every vault under the monorepo root'
+complete -c lockenv -n "__fish_seen_subcommand_from status; and not __fish_seen_subcommand_from keyring session" -l exit-code -d 'Exit with status 1 unless every file is unchanged'
+
+# env flags
+complete -c lockenv -n "__fish_seen_subcommand_from env" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from env" -l format -x -a "sh fish powershell dotenv json" -d 'Output format'
+
+# render flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from render" -s o -l output -r -F -d 'Write to this file instead of stdout'
+complete -c lockenv -n "__fish_seen_subcommand_from render" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from render" -F
+complete -c lockenv -n "__fish_seen_subcommand_from render" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# inject and redact flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from inject redact" -l stdout -d 'Print the result instead of rewriting the file'
+complete -c lockenv -n "__fish_seen_subcommand_from inject redact" -F
+complete -c lockenv -n "__fish_seen_subcommand_from redact" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# diff flags
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l rev -r -d 'Compare vault at a git revision'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l hexdump -r -d 'Hexdump bytes from the first binary difference'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l show-secrets -d 'Show secret values instead of masking them'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -l stat -d 'Show changed line counts per file'
+complete -c lockenv -n "__fish_seen_subcommand_from diff" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# merge flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -l force -d 'Use other vault version'
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -l keep-local -d 'Keep this vault version'
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -l keep-both -d 'Keep both versions'
+complete -c lockenv -n "__fish_seen_subcommand_from merge" -F
+
+# link flags
+complete -c lockenv -n "__fish_seen_subcommand_from link" -l as -r -d 'Path of the reference in this vault'
+complete -c lockenv -n "__fish_seen_subcommand_from link" -a "(__fish_complete_directories)"
+
+# clean/shred flags
+complete -c lockenv -n "__fish_seen_subcommand_from clean shred" -l force -d 'Also remove modified files'
+
+# scan flags
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l include-ignored -d 'Also list files marked as not secret'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l ignore -d 'Mark paths as not secret'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l unignore -d 'Remove the not-secret mark'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -l list-ignored -d 'Print paths marked as not secret'
+complete -c lockenv -n "__fish_seen_subcommand_from scan" -F
+
+# verify flags
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l paths -d 'Compare local files without a password'
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l strict -d 'Also fail if tracked files are missing'
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l require-signature -d 'Require a trusted manifest signature'
+complete -c lockenv -n "__fish_seen_subcommand_from verify" -l signers -r -F -d 'Trusted signing keys'
+
+# check-parity flags
+complete -c lockenv -n "__fish_seen_subcommand_from check-parity" -l local -d 'Compare the working-tree files'
+complete -c lockenv -n "__fish_seen_subcommand_from check-parity" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# validate flags
+complete -c lockenv -n "__fish_seen_subcommand_from validate" -l local -d 'Check the working-tree files'
+complete -c lockenv -n "__fish_seen_subcommand_from validate" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# fsck flags
+complete -c lockenv -n "__fish_seen_subcommand_from fsck" -l repair -d 'Fix the issues found'
+
+# split flags
+complete -c lockenv -n "__fish_seen_subcommand_from split" -l files -x -a "(lockenv __complete files --shell fish 2>/dev/null)" -d 'Copy the files matching this pattern'
+complete -c lockenv -n "__fish_seen_subcommand_from split" -l output -r -F -d 'Path of the new vault'
+complete -c lockenv -n "__fish_seen_subcommand_from split" -l new-password -d 'Use a different password'
+
+# init/migrate flags
+complete -c lockenv -n "__fish_seen_subcommand_from init migrate" -l layout -x -a "file dir" -d 'Keep file contents in the vault file or in .lockenv.d'
+complete -c lockenv -n "__fish_seen_subcommand_from init" -l import-existing -d 'Propose files that look like secrets and lock the selected ones'
+complete -c lockenv -n "__fish_seen_subcommand_from init" -l recovery-key -d 'Print a one-time recovery key'
+
+# passwd flags
+complete -c lockenv -n "__fish_seen_subcommand_from passwd" -l rotate-salt-only -d 'Keep the password, renew the salt'
+
+# recover flags
+complete -c lockenv -n "__fish_seen_subcommand_from recover" -l key -x -d 'Recovery key printed by init --recovery-key'
+complete -c lockenv -n "__fish_seen_subcommand_from recover" -l new-key -d 'Create a new recovery key'
+
+# backup flags
+complete -c lockenv -n "__fish_seen_subcommand_from backup" -l dir -r -a "(__fish_complete_directories)" -d 'Directory to store backups in'
+complete -c lockenv -n "__fish_seen_subcommand_from backup" -l keep -r -d 'Number of backups to keep'
+
+# selfupdate flags
+complete -c lockenv -n "__fish_seen_subcommand_from selfupdate" -l check -d 'Only check for a newer release'
+
+# push/pull flags
+complete -c lockenv -n "__fish_seen_subcommand_from push pull" -l remote -r -d 'Remote URL'
+complete -c lockenv -n "__fish_seen_subcommand_from push pull" -l force -d 'Ignore changes on the other side'
+
+# guard flags
+complete -c lockenv -n "__fish_seen_subcommand_from guard" -l interval -r -d 'How often to check the timer'
+
+# stash subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from stash; and not __fish_seen_subcommand_from pop show" -a "pop show"
+complete -c lockenv -n "__fish_seen_subcommand_from pop" -l force -d 'Overwrite local files without asking'
+complete -c lockenv -n "__fish_seen_subcommand_from stash" -l snapshot -d 'Snapshot local edits, or restore the snapshot with pop'
 
 # keyring subcommands
-complete -c lockenv -n "__fish_seen_subcommand_from keyring" -a "save delete status"
+complete -c lockenv -n "__fish_seen_subcommand_from keyring; and not __fish_seen_subcommand_from save delete status" -a "save delete status"
+complete -c lockenv -n "__fish_seen_subcommand_from save" -l ttl -r -d 'Invalidate password after duration'
+
+# session subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from session; and not __fish_seen_subcommand_from start end status" -a "start end status"
+complete -c lockenv -n "__fish_seen_subcommand_from start" -l ttl -r -d 'How long the session stays valid'
+
+# ws subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from ws; and not __fish_seen_subcommand_from add rm list unlock" -a "add rm list unlock"
+complete -c lockenv -n "__fish_seen_subcommand_from ws; and __fish_seen_subcommand_from add rm" -a "(__fish_complete_directories)"
+
+# recipient subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from recipient; and not __fish_seen_subcommand_from add-ssh add-kms list rm" -a "add-ssh add-kms list rm"
+complete -c lockenv -n "__fish_seen_subcommand_from add-ssh" -F
+
+# domain subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from domain; and not __fish_seen_subcommand_from add list rm" -a "add list rm"
+complete -c lockenv -n "__fish_seen_subcommand_from domain; and __fish_seen_subcommand_from rm" -a "(lockenv __complete domains --shell fish 2>/dev/null)"
+
+# run flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from run; and not contains -- -- (commandline -opc)" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from run; and not contains -- -- (commandline -opc)" -l redact -d 'Replace secret values in the output with ***'
+complete -c lockenv -n "__fish_seen_subcommand_from run; and not contains -- -- (commandline -opc)" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+complete -c lockenv -n "__fish_seen_subcommand_from run; and contains -- -- (commandline -opc)" -a "(__fish_complete_command)"
+
+# show flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from show" -l content -d 'Also print the decrypted content'
+complete -c lockenv -n "__fish_seen_subcommand_from show" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# note flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from note" -l clear -d 'Remove the note'
+complete -c lockenv -n "__fish_seen_subcommand_from note" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# require flags and files
+complete -c lockenv -n "__fish_seen_subcommand_from require" -l optional -d 'Mark the files optional again'
+complete -c lockenv -n "__fish_seen_subcommand_from require" -a "(lockenv __complete files --shell fish 2>/dev/null)"
+
+# meta subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from meta; and not __fish_seen_subcommand_from show set unset" -a "show set unset"
+complete -c lockenv -n "__fish_seen_subcommand_from meta; and __fish_seen_subcommand_from set unset; and not __fish_seen_subcommand_from description owner contact" -a "description owner contact"
+
+# ci subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from ci; and not __fish_seen_subcommand_from export-github export-gitlab" -a "export-github export-gitlab"
+complete -c lockenv -n "__fish_seen_subcommand_from export-github export-gitlab" -l profile -x -a "(lockenv __complete profiles --shell fish 2>/dev/null)" -d 'Overlay .env.<profile> on .env'
+complete -c lockenv -n "__fish_seen_subcommand_from export-gitlab" -s o -l output -r -F -d 'Write the report to this file'
+complete -c lockenv -n "__fish_seen_subcommand_from export-github export-gitlab" -a "(lockenv __complete files --shell fish 2>/dev/null)"
 
 # help completions
 complete -c lockenv -n "__fish_seen_subcommand_from help" -a "$commands"
 
 # completion completions
 complete -c lockenv -n "__fish_seen_subcommand_from completion" -a "bash zsh fish powershell"
+
+# shell-hook completions
+complete -c lockenv -n "__fish_seen_subcommand_from shell-hook" -a "bash zsh fish"
+
+# git-hooks subcommands
+complete -c lockenv -n "__fish_seen_subcommand_from git-hooks; and not __fish_seen_subcommand_from install uninstall" -a "install uninstall"
+complete -c lockenv -n "__fish_seen_subcommand_from git-hooks; and __fish_seen_subcommand_from install" -l auto-unlock -d 'Unlock changed vaults while a session is active'
+complete -c lockenv -n "__fish_seen_subcommand_from git-hooks; and __fish_seen_subcommand_from install" -l stash -d 'Snapshot local edits before rebases and automatic unlocks'
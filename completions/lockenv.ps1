@@ -1,9 +1,34 @@
 Register-ArgumentCompleter -Native -CommandName lockenv -ScriptBlock {
     param($wordToComplete, $commandAst, $cursorPosition)
 
-    $commands = @('init', 'lock', 'unlock', 'rm', 'ls', 'status', 'passwd', 'diff', 'compact', 'keyring', 'help', 'completion')
+    $commands = @('init', 'lock', 'track', 'unlock', 'deploy', 'rm', 'ls', 'status', 'which', 'scan', 'env', 'render', 'inject', 'redact', 'run', 'ci', 'show', 'note', 'require', 'passwd', 'recover', 'diff', 'merge', 'link', 'ws', 'compact', 'stats', 'backup', 'migrate', 'push', 'pull', 'clean', 'shred', 'verify', 'check-parity', 'validate', 'fsck', 'reindex', 'split', 'stash', 'guard', 'keyring', 'session', 'recipient', 'domain', 'meta', 'help', 'completion', 'shell-hook', 'git-hooks', 'selfupdate')
     $keyringCmds = @('save', 'delete', 'status')
+    $sessionCmds = @('start', 'end', 'status')
+    $wsCmds = @('add', 'rm', 'list', 'unlock')
+    $recipientCmds = @('add-ssh', 'add-kms', 'list', 'rm')
+    $domainCmds = @('add', 'list', 'rm')
+    $ciCmds = @('export-github', 'export-gitlab')
+    $metaCmds = @('show', 'set', 'unset')
+    $stashCmds = @('pop', 'show')
     $shells = @('bash', 'zsh', 'fish', 'powershell')
+    $vaultFiles = {
+        param($prefix)
+        lockenv __complete files --shell powershell $prefix 2>$null | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    }
+    $profiles = {
+        param($prefix)
+        lockenv __complete profiles --shell powershell $prefix 2>$null | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    }
+    $domains = {
+        param($prefix)
+        lockenv __complete domains --shell powershell $prefix 2>$null | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    }
 
     $tokens = $commandAst.ToString() -split '\s+'
 
@@ -17,24 +42,382 @@ Register-ArgumentCompleter -Native -CommandName lockenv -ScriptBlock {
     $cmd = $tokens[1]
     switch ($cmd) {
         'lock' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--domain') {
+                & $domains $wordToComplete
+            } elseif ($prev -eq '--as') {
+                & $vaultFiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('-r', '--remove', '-R', '--recursive', '--force', '--domain', '--no-content', '--allow-large', '--sign', '--strict', '-p', '--patch', '--show-secrets', '--stdin', '--as') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'track' {
             if ($wordToComplete -like '-*') {
-                @('-r', '--remove', '--force') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                @('-R', '--recursive', '--allow-large') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                     [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
                 }
             }
         }
         'unlock' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--domain') {
+                & $domains $wordToComplete
+            } elseif ($prev -eq '--signers' -or $prev -eq '--tar') {
+                return
+            } elseif ($wordToComplete -like '-*') {
+                @('--force', '--keep-local', '--keep-both', '--all', '--for', '--domain', '--preserve-mode', '--preserve-all', '--require-signature', '--signers', '--tar', '--strict') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'deploy' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--domain') {
+                & $domains $wordToComplete
+            } elseif ($prev -eq '--files') {
+                & $vaultFiles $wordToComplete
+            } elseif ($prev -eq '--signers') {
+                return
+            } elseif ($wordToComplete -like '-*') {
+                @('--files', '--domain', '--preserve-mode', '--preserve-all', '--require-signature', '--signers') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'rm' {
+            & $vaultFiles $wordToComplete
+        }
+        { $_ -in 'ls', 'status' } {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--filter') {
+                @('modified', 'unchanged', 'vault-only', 'never-locked') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--sort') {
+                @('name', 'size', 'mtime') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--columns') {
+                @('size', 'mtime', 'mode', 'hash', 'note') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($wordToComplete -like '-*') {
+                @('--full', '--filter', '--sort', '--columns', '-l', '--long', '--prompt', '--all', '--exit-code') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'env' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--format') {
+                @('sh', 'fish', 'powershell', 'dotenv', 'json') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('--profile', '--format') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'render' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('-o', '--output', '--profile') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'inject', 'redact' } {
+            if ($wordToComplete -like '-*') {
+                @('--stdout') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'diff' {
+            if ($wordToComplete -like '-*') {
+                @('--rev', '--stat', '--hexdump', '--show-secrets') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'merge' {
             if ($wordToComplete -like '-*') {
                 @('--force', '--keep-local', '--keep-both') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                     [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
                 }
             }
         }
+        'link' {
+            if ($wordToComplete -like '-*') {
+                @('--as') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'clean', 'shred' } {
+            if ($wordToComplete -like '-*') {
+                @('--force') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'scan' {
+            if ($wordToComplete -like '-*') {
+                @('--include-ignored', '--ignore', '--unignore', '--list-ignored') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'verify' {
+            if ($wordToComplete -like '-*') {
+                @('--paths', '--strict', '--require-signature', '--signers') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'check-parity' {
+            if ($wordToComplete -like '-*') {
+                @('--local') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'validate' {
+            if ($wordToComplete -like '-*') {
+                @('--local') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'split' {
+            if ($wordToComplete -like '-*') {
+                @('--files', '--output', '--new-password') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'fsck' {
+            if ($wordToComplete -like '-*') {
+                @('--repair') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'push', 'pull' } {
+            if ($wordToComplete -like '-*') {
+                @('--remote', '--force') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        { $_ -in 'init', 'migrate' } {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--layout') {
+                @('file', 'dir') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($wordToComplete -like '-*') {
+                $flags = if ($cmd -eq 'init') { @('--layout', '--recovery-key', '--import-existing') } else { @('--layout') }
+                $flags | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'passwd' {
+            if ($wordToComplete -like '-*') {
+                @('--rotate-salt-only') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'recover' {
+            if ($wordToComplete -like '-*') {
+                @('--key', '--new-key') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'backup' {
+            if ($wordToComplete -like '-*') {
+                @('--dir', '--keep') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'selfupdate' {
+            if ($wordToComplete -like '-*') {
+                @('--check') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'guard' {
+            if ($wordToComplete -like '-*') {
+                @('--interval') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            }
+        }
+        'stash' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'pop' -and $wordToComplete -like '-*') {
+                @('--force', '--snapshot') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            if ($wordToComplete -like '-*') {
+                @('--snapshot') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            $stashCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
         'keyring' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'save' -and $wordToComplete -like '-*') {
+                @('--ttl') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
             $keyringCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
             }
         }
+        'session' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'start' -and $wordToComplete -like '-*') {
+                @('--ttl') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            $sessionCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'ws' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'unlock' -and $wordToComplete -like '-*') {
+                @('--force', '--keep-local', '--keep-both') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $wsCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            }
+        }
+        'recipient' {
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $recipientCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            }
+        }
+        'domain' {
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $domainCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($tokens[2] -eq 'rm' -and ($tokens.Count -eq 3 -or ($tokens.Count -eq 4 -and $wordToComplete -ne ''))) {
+                & $domains $wordToComplete
+            }
+        }
+        'run' {
+            if (($tokens | Select-Object -Skip 2) -contains '--') {
+                return
+            }
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                @('--profile', '--redact', '--') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'show' {
+            if ($wordToComplete -like '-*') {
+                @('--content') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'note' {
+            if ($wordToComplete -like '-*') {
+                @('--clear') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } elseif ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'require' {
+            if ($wordToComplete -like '-*') {
+                @('--optional') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
+        'meta' {
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $metaCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($tokens[2] -in 'set', 'unset' -and ($tokens.Count -eq 3 -or ($tokens.Count -eq 4 -and $wordToComplete -ne ''))) {
+                @('description', 'owner', 'contact') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            }
+        }
+        'ci' {
+            $prev = if ($wordToComplete -eq '') { $tokens[-1] } else { $tokens[-2] }
+            if ($tokens.Count -le 2 -or ($tokens.Count -eq 3 -and $wordToComplete -ne '')) {
+                $ciCmds | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            } elseif ($prev -eq '--profile') {
+                & $profiles $wordToComplete
+            } elseif ($wordToComplete -like '-*') {
+                $flags = @('--profile')
+                if ($tokens[2] -eq 'export-gitlab') {
+                    $flags = @('-o', '--output', '--profile')
+                }
+                $flags | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+            } else {
+                & $vaultFiles $wordToComplete
+            }
+        }
         'help' {
             $commands | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
@@ -45,5 +428,21 @@ Register-ArgumentCompleter -Native -CommandName lockenv -ScriptBlock {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
             }
         }
+        'shell-hook' {
+            @('bash', 'zsh', 'fish') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'git-hooks' {
+            if ($tokens.Count -gt 2 -and $tokens[2] -eq 'install' -and $wordToComplete -like '-*') {
+                @('--auto-unlock', '--stash') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+                }
+                return
+            }
+            @('install', 'uninstall') | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
     }
 }
@@ -0,0 +1,151 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	dirName   = "lockenv/audit"
+	logSuffix = ".log"
+
+	// MaxEvents is the number of most recent events a log keeps
+	MaxEvents = 1000
+)
+
+// Event kinds
+const (
+	UnlockFailed = "unlock-failed" // Wrong password
+	Unlocked     = "unlocked"      // Password accepted after failed attempts
+)
+
+// Event is one line of the log
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Source string    `json:"source,omitempty"` // Where the password came from, e.g. prompt or keyring
+}
+
+// Dir returns the directory where audit logs are stored
+func Dir() string {
+	if stateDir := os.Getenv("XDG_STATE_HOME"); stateDir != "" {
+		return filepath.Join(stateDir, dirName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("lockenv-audit-%d", os.Getuid()))
+	}
+	return filepath.Join(home, ".local", "state", dirName)
+}
+
+// logPath returns the log file path for a vault
+func logPath(vaultID string) string {
+	// Vault IDs are hex, but never let a tampered ID escape the directory
+	return filepath.Join(Dir(), filepath.Base(filepath.Clean(vaultID))+logSuffix)
+}
+
+// Events returns the logged events of a vault, oldest first. A vault
+// without a log has none.
+func Events(vaultID string) ([]Event, error) {
+	data, err := os.ReadFile(logPath(vaultID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event Event
+		// Skip lines cut short by a crash rather than losing the log
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// Record appends event to the log of a vault, dropping the oldest events
+// beyond MaxEvents
+func Record(vaultID string, event Event) error {
+	if vaultID == "" {
+		return fmt.Errorf("vault ID is required")
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	events, err := Events(vaultID)
+	if err != nil {
+		return err
+	}
+	events = append(events, event)
+	if len(events) > MaxEvents {
+		events = events[len(events)-MaxEvents:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode audit event: %w", err)
+		}
+	}
+
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".audit-*")
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return os.Rename(tmp.Name(), logPath(vaultID))
+}
+
+// RecordUnlock logs the outcome of a password check. A success is only
+// logged when it ends a run of failed attempts.
+func RecordUnlock(vaultID string, ok bool, source string) error {
+	if !ok {
+		return Record(vaultID, Event{Kind: UnlockFailed, Source: source})
+	}
+	events, err := Events(vaultID)
+	if err != nil {
+		return err
+	}
+	if failed, _ := FailedSinceSuccess(events); failed == 0 {
+		return nil
+	}
+	return Record(vaultID, Event{Kind: Unlocked, Source: source})
+}
+
+// FailedSinceSuccess returns the number of failed unlock attempts after the
+// last successful one and the time of the latest
+func FailedSinceSuccess(events []Event) (int, time.Time) {
+	count := 0
+	var last time.Time
+	for i := len(events) - 1; i >= 0 && events[i].Kind != Unlocked; i-- {
+		if events[i].Kind == UnlockFailed {
+			if count == 0 {
+				last = events[i].Time
+			}
+			count++
+		}
+	}
+	return count, last
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordUnlock(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	// Successes without failures before them are not logged
+	if err := RecordUnlock("abc123", true, "prompt"); err != nil {
+		t.Fatalf("RecordUnlock failed: %v", err)
+	}
+	if _, err := os.Stat(logPath("abc123")); !os.IsNotExist(err) {
+		t.Errorf("Expected no log after a plain success, got %v", err)
+	}
+
+	for range 3 {
+		if err := RecordUnlock("abc123", false, "prompt"); err != nil {
+			t.Fatalf("RecordUnlock failed: %v", err)
+		}
+	}
+	events, err := Events("abc123")
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if failed, last := FailedSinceSuccess(events); failed != 3 || !last.Equal(events[2].Time) {
+		t.Errorf("FailedSinceSuccess = %d, %v", failed, last)
+	}
+
+	if err := RecordUnlock("abc123", true, "keyring"); err != nil {
+		t.Fatalf("RecordUnlock failed: %v", err)
+	}
+	events, err = Events("abc123")
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if len(events) != 4 || events[3].Kind != Unlocked || events[3].Source != "keyring" {
+		t.Errorf("Expected an unlocked event after the failures, got %+v", events)
+	}
+	if failed, _ := FailedSinceSuccess(events); failed != 0 {
+		t.Errorf("Expected no failures since success, got %d", failed)
+	}
+
+	if info, err := os.Stat(logPath("abc123")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected log with mode 0600, got %v, %v", info, err)
+	}
+}
+
+func TestRecord_KeepsMaxEvents(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	for range MaxEvents + 5 {
+		if err := Record("abc123", Event{Kind: UnlockFailed}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	events, err := Events("abc123")
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if len(events) != MaxEvents {
+		t.Errorf("Expected %d events, got %d", MaxEvents, len(events))
+	}
+}
+
+func TestEvents_SkipsTruncatedLines(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"time":"2026-01-15T14:30:00Z","kind":"unlock-failed"}` + "\n" + `{"time":"2026-01-15T14:3`
+	if err := os.WriteFile(filepath.Join(Dir(), "abc123.log"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	events, err := Events("abc123")
+	if err != nil || len(events) != 1 || events[0].Kind != UnlockFailed {
+		t.Errorf("Events = %+v, %v", events, err)
+	}
+}
@@ -0,0 +1,12 @@
+// Package audit keeps a local log of unlock attempts for each vault, so
+// someone guessing the password of a vault on this machine is noticed.
+//
+// Events are appended as JSON lines to lockenv/audit/<vault-id>.log under
+// XDG_STATE_HOME (default ~/.local/state), readable only by the user:
+//   - unlock-failed: a wrong password, with where it came from
+//   - unlocked: the password was accepted after failed attempts; successes
+//     are only written then, so everyday use leaves the log alone
+//
+// The log keeps the last MaxEvents events, so repeated guessing can't grow
+// it without bound. Attacks on a copy of the vault elsewhere are not seen.
+package audit
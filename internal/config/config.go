@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// Color settings
+const (
+	ColorAuto   = "auto"   // Color terminals only
+	ColorAlways = "always" // Color pipes and files as well
+	ColorNever  = "never"  // No colors, like --no-color
+)
+
+// Config holds the resolved settings for one project
+type Config struct {
+	*core.Config                     // Vault settings shared with core
+	Strategy      core.MergeStrategy // Conflict strategy for unlock, merge and stash pop without a flag
+	AutoCompact   bool               // Compact the vault after rm, passwd and migrate
+	KDFTargetTime time.Duration      // Key derivation time for new passwords, 0 for the fixed default
+	Color         string             // ColorAuto, ColorAlways or ColorNever
+	Profile       string             // Default --profile for env, run, render and ci export
+}
+
+// envKeys maps environment variables to the config keys they override.
+// Every setting that has a config key is here. There is no LOCKENV_JOBS
+// because there is no jobs setting: lockenv processes files one at a time.
+var envKeys = map[string]string{
+	"LOCKENV_STRATEGY":        "strategy",
+	"LOCKENV_AUTO_COMPACT":    "auto_compact",
+	"LOCKENV_KDF_TARGET_TIME": "kdf_target_time",
+	"LOCKENV_COLOR":           "color",
+	"LOCKENV_PROFILE":         "profile",
+	"LOCKENV_MAX_FILE_SIZE":   "max_file_size",
+	"LOCKENV_DETERMINISTIC":   "deterministic",
+	"LOCKENV_MERGE_TEMP_DIR":  "merge_temp_dir",
+	"LOCKENV_EDITOR":          "merge.editor",
+}
+
+// userOnlyKeys make up the merge editor policy. The project .lockenv.toml
+// comes with every clone, so it may not choose or allow editors; its
+// merge.denied_editors only adds to the user's.
+var userOnlyKeys = []string{"merge.editor", "merge.allowed_editors", "merge.denied_editors"}
+
+// envFlags are boolean environment variables standing in for a flag, with
+// the config key and value they set when true
+var envFlags = map[string][2]string{
+	"LOCKENV_FORCE":    {"strategy", "use-vault"},
+	"LOCKENV_NO_COLOR": {"color", ColorNever},
+}
+
+// EnvBool reports whether the environment variable name is set to a true
+// value (1, t, true), e.g. LOCKENV_QUIET=1
+func EnvBool(name string) bool {
+	value, _ := strconv.ParseBool(os.Getenv(name))
+	return value
+}
+
+// VaultDir returns the project directory LOCKENV_VAULT points at, either
+// the directory itself or its .lockenv file, or "" if it is not set
+func VaultDir() string {
+	path := os.Getenv("LOCKENV_VAULT")
+	if filepath.Base(path) == core.LockEnvFile {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// envLayer returns the config keys set by LOCKENV_* variables. The
+// variables in envFlags are applied last, so LOCKENV_FORCE=1 wins over
+// LOCKENV_STRATEGY like --force does.
+func envLayer() (map[string]string, error) {
+	values := make(map[string]string)
+	for name, key := range envKeys {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		// Report a bad value against the variable it is in
+		if _, err := parse(map[string]string{key: value}); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values[key] = value
+	}
+	for name, set := range envFlags {
+		if os.Getenv(name) == "" {
+			continue
+		}
+		if _, err := strconv.ParseBool(os.Getenv(name)); err != nil {
+			return nil, fmt.Errorf("%s: expected true or false, got %q", name, os.Getenv(name))
+		}
+		if EnvBool(name) {
+			values[set[0]] = set[1]
+		}
+	}
+	return values, nil
+}
+
+// UserFile returns the path of the user config file
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lockenv", "config.toml"), nil
+}
+
+// Load resolves the settings for the project in dir from the user config,
+// dir's .lockenv.toml and LOCKENV_* environment variables, each overriding
+// the previous key by key, except for the userOnlyKeys of .lockenv.toml.
+// Missing files are skipped.
+func Load(dir string) (*Config, error) {
+	project := filepath.Join(dir, core.ConfigFile)
+	paths := []string{project}
+	if user, err := UserFile(); err == nil {
+		paths = append([]string{user}, paths...)
+	}
+
+	values := make(map[string]string)
+	var denied []string
+	for _, path := range paths {
+		layer, err := core.ReadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// Report a bad value against the file it is in
+		parsed, err := parse(layer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if path == project {
+			denied = parsed.Merge.DeniedEditors
+			for _, key := range userOnlyKeys {
+				delete(layer, key)
+			}
+		}
+		maps.Copy(values, layer)
+	}
+
+	env, err := envLayer()
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(values, env)
+	config, err := parse(values)
+	if err != nil {
+		return nil, err
+	}
+	for _, editor := range denied {
+		if !slices.Contains(config.Merge.DeniedEditors, editor) {
+			config.Merge.DeniedEditors = append(config.Merge.DeniedEditors, editor)
+		}
+	}
+	return config, nil
+}
+
+// parse builds a Config from flattened keys
+func parse(values map[string]string) (*Config, error) {
+	vault, err := core.ParseConfig(values)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{Config: vault, AutoCompact: true, Color: ColorAuto, Profile: values["profile"]}
+
+	if value, ok := values["strategy"]; ok {
+		if config.Strategy, err = parseStrategy(value); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := values["auto_compact"]; ok {
+		if config.AutoCompact, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("auto_compact: expected true or false, got %q", value)
+		}
+	}
+	if value, ok := values["kdf_target_time"]; ok {
+		config.KDFTargetTime, err = time.ParseDuration(value)
+		if err != nil || config.KDFTargetTime < 0 {
+			return nil, fmt.Errorf("kdf_target_time: invalid duration %q (use e.g. 500ms or 1s)", value)
+		}
+	}
+	if value, ok := values["color"]; ok {
+		switch value {
+		case ColorAuto, ColorAlways, ColorNever:
+			config.Color = value
+		default:
+			return nil, fmt.Errorf("color: expected %s, %s or %s, got %q", ColorAuto, ColorAlways, ColorNever, value)
+		}
+	}
+	return config, nil
+}
+
+// parseStrategy parses a conflict strategy by the name MergeStrategy.String
+// gives it, e.g. keep-local
+func parseStrategy(value string) (core.MergeStrategy, error) {
+	for s := core.StrategyAsk; s <= core.StrategyAbort; s++ {
+		if s.String() == value {
+			return s, nil
+		}
+	}
+	return core.StrategyAsk, fmt.Errorf("strategy: unknown strategy %q (use ask, keep-local, use-vault, keep-both or abort)", value)
+}
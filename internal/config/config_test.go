@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/illarion/lockenv/internal/core"
+)
+
+// writeUserConfig points the user config directory at a temp dir and writes
+// data to the user config file there
+func writeUserConfig(t *testing.T, data string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	path, err := UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	writeUserConfig(t, "")
+	config, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Strategy != core.StrategyAsk || !config.AutoCompact || config.KDFTargetTime != 0 ||
+		config.Color != ColorAuto || config.Profile != "" || config.MaxFileSize != core.DefaultMaxFileSize {
+		t.Errorf("Unexpected defaults: %+v", config)
+	}
+}
+
+func TestLoad_ProjectOverridesUser(t *testing.T) {
+	writeUserConfig(t, `strategy = "keep-local"
+color = "never"
+kdf_target_time = "1s"
+password_command = "pass show lockenv"
+
+[hooks]
+post-unlock = "echo user"
+post-lock = "echo user"
+`)
+	dir := t.TempDir()
+	project := `strategy = "use-vault"
+auto_compact = false
+profile = "dev"
+
+[hooks]
+post-unlock = "echo project"
+`
+	if err := os.WriteFile(filepath.Join(dir, core.ConfigFile), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Strategy != core.StrategyUseVault {
+		t.Errorf("Strategy = %v, want use-vault from the project", config.Strategy)
+	}
+	if config.Color != ColorNever || config.KDFTargetTime != time.Second || config.PasswordCommand != "pass show lockenv" {
+		t.Errorf("User settings not applied: %+v", config)
+	}
+	if config.AutoCompact || config.Profile != "dev" {
+		t.Errorf("Project settings not applied: %+v", config)
+	}
+	if config.Hooks[core.HookPostUnlock] != "echo project" || config.Hooks[core.HookPostLock] != "echo user" {
+		t.Errorf("Hooks = %v, want the project's post-unlock and the user's post-lock", config.Hooks)
+	}
+}
+
+func TestLoad_EditorPolicyFromUser(t *testing.T) {
+	writeUserConfig(t, `[merge]
+editor = "nvim"
+denied_editors = ["subl"]
+`)
+	dir := t.TempDir()
+	project := `[merge]
+editor = "code --wait"
+allowed_editors = ["code"]
+denied_editors = ["nano"]
+`
+	if err := os.WriteFile(filepath.Join(dir, core.ConfigFile), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cloned project can add to the denied editors, nothing else
+	config, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	m := config.Merge
+	if m.Editor != "nvim" || len(m.AllowedEditors) != 0 {
+		t.Errorf("Project overrode the user's editor policy: %+v", m)
+	}
+	if strings.Join(m.DeniedEditors, ",") != "subl,nano" {
+		t.Errorf("DeniedEditors = %v, want the user's and the project's", m.DeniedEditors)
+	}
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	for _, line := range []string{
+		`strategy = "merge"`,
+		`auto_compact = "sometimes"`,
+		`kdf_target_time = "fast"`,
+		`kdf_target_time = "-1s"`,
+		`color = "rainbow"`,
+		`max_file_size = "lots"`,
+	} {
+		writeUserConfig(t, line+"\n")
+		_, err := Load(t.TempDir())
+		if err == nil {
+			t.Errorf("%s: expected error", line)
+			continue
+		}
+		// The error names the file the bad value is in
+		if !strings.Contains(err.Error(), "config.toml") {
+			t.Errorf("%s: error %q does not name the user config", line, err)
+		}
+	}
+}
+
+func TestLoad_EnvironmentOverridesFiles(t *testing.T) {
+	writeUserConfig(t, "color = \"always\"\nauto_compact = false\n")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, core.ConfigFile), []byte("strategy = \"keep-local\"\nprofile = \"dev\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LOCKENV_STRATEGY", "keep-both")
+	t.Setenv("LOCKENV_PROFILE", "ci")
+	t.Setenv("LOCKENV_NO_COLOR", "1")
+	t.Setenv("LOCKENV_EDITOR", "nano")
+
+	config, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Strategy != core.StrategyKeepBoth || config.Profile != "ci" || config.Color != ColorNever || config.Merge.Editor != "nano" {
+		t.Errorf("Environment not applied: %+v", config)
+	}
+	if config.AutoCompact {
+		t.Error("auto_compact from the user config was lost")
+	}
+
+	// LOCKENV_FORCE acts like --force and wins over LOCKENV_STRATEGY
+	t.Setenv("LOCKENV_FORCE", "true")
+	if config, err = Load(dir); err != nil || config.Strategy != core.StrategyUseVault {
+		t.Errorf("LOCKENV_FORCE: strategy = %v, err = %v", config.Strategy, err)
+	}
+	t.Setenv("LOCKENV_FORCE", "0")
+	if config, err = Load(dir); err != nil || config.Strategy != core.StrategyKeepBoth {
+		t.Errorf("LOCKENV_FORCE=0: strategy = %v, err = %v", config.Strategy, err)
+	}
+}
+
+func TestLoad_InvalidEnvironment(t *testing.T) {
+	writeUserConfig(t, "")
+	for name, value := range map[string]string{
+		"LOCKENV_STRATEGY":        "merge",
+		"LOCKENV_KDF_TARGET_TIME": "fast",
+		"LOCKENV_FORCE":           "please",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := Load(t.TempDir()); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("%s=%s: expected error naming the variable, got %v", name, value, err)
+			}
+		})
+	}
+}
+
+func TestVaultDir(t *testing.T) {
+	for value, want := range map[string]string{
+		"":                  "",
+		"/srv/app":          "/srv/app",
+		"/srv/app/.lockenv": "/srv/app",
+		".lockenv":          ".",
+	} {
+		t.Setenv("LOCKENV_VAULT", value)
+		if got := VaultDir(); got != want {
+			t.Errorf("VaultDir() with LOCKENV_VAULT=%q = %q, want %q", value, got, want)
+		}
+	}
+}
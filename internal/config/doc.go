@@ -0,0 +1,17 @@
+// Package config resolves lockenv settings from the places they can be set.
+//
+// Settings are read from these layers, each key taken from the one with the
+// highest precedence:
+//   - user: config.toml in the lockenv directory of the user config
+//     directory (~/.config/lockenv/config.toml on Linux), for personal
+//     defaults across projects
+//   - project: .lockenv.toml next to .lockenv, usually committed
+//   - environment: LOCKENV_* variables such as LOCKENV_STRATEGY or
+//     LOCKENV_FORCE, so CI pipelines can configure lockenv without
+//     templating command lines
+//
+// Command line flags take precedence over all of them; commands apply them
+// on top of the loaded Config. Vault settings (password sources, hooks, size limit,
+// merge options) are parsed by core.ParseConfig; this package adds the
+// defaults commands fall back to when no flag is given.
+package config
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// PreserveLevel selects which recorded file attributes unlock restores
+type PreserveLevel int
+
+const (
+	// PreserveNone writes new files owner-only and keeps existing modes
+	PreserveNone PreserveLevel = iota
+	// PreserveMode restores the exact stored mode
+	PreserveMode
+	// PreserveAll also restores ownership and extended attributes
+	PreserveAll
+)
+
+// storedModeBits are the mode bits PreserveMode restores
+const storedModeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// Preserve sets which recorded attributes later unlocks restore. With
+// PreserveMode files get their exact stored mode, including group and other
+// bits, for consumers running as another user such as shared service
+// accounts. PreserveAll also restores the owner (recorded when locking as
+// root) and extended attributes, so system config files round-trip.
+func (l *LockEnv) Preserve(level PreserveLevel) {
+	l.preserve = level
+}
+
+// restoreAttributes applies the attributes selected with Preserve to an
+// unlocked file. Writing keeps the mode of an existing file and applies the
+// umask, so the mode is set explicitly; it is set last, as changing the owner
+// clears setuid and setgid bits.
+func (l *LockEnv) restoreAttributes(platformPath string, file storage.FileEntry) error {
+	if l.preserve == PreserveNone {
+		return nil
+	}
+	var errs []error
+	if l.preserve >= PreserveAll {
+		if file.Owner != nil {
+			if err := os.Chown(platformPath, file.Owner.UID, file.Owner.GID); err != nil {
+				errs = append(errs, fmt.Errorf("cannot set owner: %w", err))
+			}
+		}
+		if err := writeXattrs(platformPath, file.Xattrs); err != nil {
+			errs = append(errs, fmt.Errorf("cannot set extended attributes: %w", err))
+		}
+	}
+	if err := os.Chmod(platformPath, os.FileMode(file.Mode)&storedModeBits); err != nil {
+		errs = append(errs, fmt.Errorf("cannot set permissions: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package core
+
+import (
+	"os"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// fileOwner returns nil where files have no numeric owner
+func fileOwner(_ os.FileInfo) *storage.Ownership {
+	return nil
+}
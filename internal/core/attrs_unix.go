@@ -0,0 +1,22 @@
+//go:build unix
+
+package core
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// fileOwner returns the owner of a file when running as root, the only case
+// where unlock can restore it; otherwise nil
+func fileOwner(info os.FileInfo) *storage.Ownership {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return &storage.Ownership{UID: int(st.Uid), GID: int(st.Gid)}
+	}
+	return nil
+}
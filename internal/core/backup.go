@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+const (
+	DefaultBackupDir  = ".lockenv-backups" // Relative to the repository root
+	DefaultBackupKeep = 5                  // Backups kept by default; older ones are pruned
+	backupPrefix      = "lockenv-"
+	backupSuffix      = ".bak"
+	backupTimeFormat  = "20060102-150405.000000000"
+)
+
+// BackupResult contains the results of a backup
+type BackupResult struct {
+	Path   string   // Path of the new backup
+	Size   int64    // Size of the new backup
+	Pruned []string // Old backups removed to honor the retention limit
+}
+
+// Backup writes a timestamped snapshot of the vault into dir and prunes all but
+// the newest keep backups (implements `lockenv backup`). A relative dir is
+// resolved against the repository root; keep <= 0 disables pruning.
+// No password is required since the snapshot stays encrypted.
+func (l *LockEnv) Backup(dir string, keep int) (*BackupResult, error) {
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	return l.backup(db, dir, keep)
+}
+
+// backup snapshots an already open database, so destructive operations can
+// take a backup inside their own session
+func (l *LockEnv) backup(db storage.Backend, dir string, keep int) (*BackupResult, error) {
+	if dir == "" {
+		dir = DefaultBackupDir
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(l.path), dir)
+	}
+	if err := createBackupDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := backupPrefix + time.Now().UTC().Format(backupTimeFormat) + backupSuffix
+	path := filepath.Join(dir, name)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FilePermSecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+	size, err := db.WriteTo(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	result := &BackupResult{Path: path, Size: size}
+	if keep > 0 {
+		result.Pruned, err = pruneBackups(dir, keep)
+		if err != nil {
+			return result, fmt.Errorf("failed to prune old backups: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// createBackupDir creates dir if it does not exist yet, with a .gitignore
+// that keeps the backups out of git when dir is inside the repository
+func createBackupDir(dir string) error {
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(dir, DirPermSecure); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*\n"), 0600)
+}
+
+// ListBackups returns the backups in dir, oldest first
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	// Timestamps sort lexically
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// pruneBackups removes all but the newest keep backups in dir
+func pruneBackups(dir string, keep int) ([]string, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, backups[0])
+		backups = backups[1:]
+	}
+	return pruned, nil
+}
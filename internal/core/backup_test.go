@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestBackup_PrunesOldBackups(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	if err := lockenv.Init([]byte("test123")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	backupDir := filepath.Join(dir, "backups")
+	var paths []string
+	for i := 0; i < 4; i++ {
+		result, err := lockenv.Backup(backupDir, 2)
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		paths = append(paths, result.Path)
+	}
+
+	backups, err := ListBackups(backupDir)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0] != paths[2] || backups[1] != paths[3] {
+		t.Errorf("Expected the 2 newest backups %v, got %v", paths[2:], backups)
+	}
+
+	// The new directory ignores itself in git
+	if data, err := os.ReadFile(filepath.Join(backupDir, ".gitignore")); err != nil || string(data) != "*\n" {
+		t.Errorf("Backup directory .gitignore = %q, %v", data, err)
+	}
+
+	// A backup is a usable vault
+	db, err := storage.Open(backups[1])
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer db.Close()
+	if initialized, err := db.IsInitialized(); err != nil || !initialized {
+		t.Errorf("Backup should be an initialized vault: %v", err)
+	}
+}
+
+func TestChangePassword_BacksUpVault(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	testFile := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(testFile, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if err := lockenv.ChangePassword(password, []byte("newpass")); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	backups, err := ListBackups(filepath.Join(dir, DefaultBackupDir))
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", backups)
+	}
+
+	// The backup still opens with the old password
+	restored := filepath.Join(t.TempDir(), LockEnvFile)
+	data, err := os.ReadFile(backups[0])
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if err := os.WriteFile(restored, data, 0600); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	old, err := New(filepath.Dir(restored))
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer old.Close()
+	if err := old.VerifyPassword(password); err != nil {
+		t.Errorf("Backup should open with the old password: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const checklistHeight = 15 // Candidates shown at once; longer lists scroll
+
+// ErrSelectionCancelled is returned when the user quits a checklist
+var ErrSelectionCancelled = errors.New("selection cancelled")
+
+// Candidate is a file offered in an interactive checklist
+type Candidate struct {
+	Path     string
+	Note     string // Shown after the path, e.g. "modified"
+	Selected bool   // Initially selected
+}
+
+// SelectFiles shows candidates as a checklist on the terminal and returns the
+// paths the user selected, in order. Space toggles the file under the cursor,
+// a toggles all files, enter confirms and q or Esc cancels with
+// ErrSelectionCancelled. Fails if stdin is not a terminal.
+func SelectFiles(title string, candidates []Candidate) ([]string, error) {
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read keys from the terminal: %w", err)
+	}
+	defer func() { _ = term.Restore(fd, oldState) }()
+
+	return runChecklist(os.Stdin, os.Stdout, title, candidates)
+}
+
+// checklist is the state of an interactive checklist
+type checklist struct {
+	items  []Candidate
+	cursor int
+	top    int // First visible item
+	drawn  int // Lines written by the last draw
+}
+
+// Keys decoded from terminal input
+const (
+	keyNone = iota
+	keyUp
+	keyDown
+	keyToggle
+	keyToggleAll
+	keyConfirm
+	keyCancel
+)
+
+// runChecklist drives a checklist from raw terminal input in r, drawing it
+// to w. Lines end in \r\n because the terminal is in raw mode.
+func runChecklist(r io.Reader, w io.Writer, title string, candidates []Candidate) ([]string, error) {
+	c := &checklist{items: append([]Candidate(nil), candidates...)}
+	in := bufio.NewReader(r)
+
+	fmt.Fprintf(w, "%s\r\n", title)
+	fmt.Fprintf(w, "(space: toggle, a: all, enter: confirm, q: cancel)\r\n")
+	c.draw(w)
+
+	for {
+		key, err := readKey(in)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case keyUp:
+			c.move(-1)
+		case keyDown:
+			c.move(1)
+		case keyToggle:
+			if len(c.items) > 0 {
+				c.items[c.cursor].Selected = !c.items[c.cursor].Selected
+			}
+		case keyToggleAll:
+			c.toggleAll()
+		case keyConfirm:
+			return c.selected(), nil
+		case keyCancel:
+			return nil, ErrSelectionCancelled
+		default:
+			continue
+		}
+		c.draw(w)
+	}
+}
+
+// readKey reads one key press. Escape sequences for the arrow keys arrive in
+// a single read, so a lone Esc is told apart by nothing else being buffered.
+func readKey(in *bufio.Reader) (int, error) {
+	b, err := in.ReadByte()
+	if err != nil {
+		return keyNone, err
+	}
+	switch b {
+	case ' ', 'x':
+		return keyToggle, nil
+	case 'a':
+		return keyToggleAll, nil
+	case '\r', '\n':
+		return keyConfirm, nil
+	case 'q', 3: // Ctrl-C
+		return keyCancel, nil
+	case 'k':
+		return keyUp, nil
+	case 'j':
+		return keyDown, nil
+	case 27:
+		if in.Buffered() < 2 {
+			return keyCancel, nil
+		}
+		seq := make([]byte, 2)
+		if _, err := io.ReadFull(in, seq); err != nil {
+			return keyNone, err
+		}
+		if seq[0] == '[' || seq[0] == 'O' {
+			switch seq[1] {
+			case 'A':
+				return keyUp, nil
+			case 'B':
+				return keyDown, nil
+			}
+		}
+	}
+	return keyNone, nil
+}
+
+// move moves the cursor by delta, scrolling the visible window to follow it
+func (c *checklist) move(delta int) {
+	c.cursor = max(0, min(len(c.items)-1, c.cursor+delta))
+	if c.cursor < c.top {
+		c.top = c.cursor
+	}
+	if c.cursor >= c.top+checklistHeight {
+		c.top = c.cursor - checklistHeight + 1
+	}
+}
+
+// toggleAll selects all items, or none if all are already selected
+func (c *checklist) toggleAll() {
+	all := true
+	for _, item := range c.items {
+		all = all && item.Selected
+	}
+	for i := range c.items {
+		c.items[i].Selected = !all
+	}
+}
+
+// selected returns the paths of the selected items
+func (c *checklist) selected() []string {
+	var paths []string
+	for _, item := range c.items {
+		if item.Selected {
+			paths = append(paths, item.Path)
+		}
+	}
+	return paths
+}
+
+// draw renders the visible items and a status line, overwriting the
+// previous rendering
+func (c *checklist) draw(w io.Writer) {
+	var b strings.Builder
+	if c.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", c.drawn)
+	}
+
+	end := min(len(c.items), c.top+checklistHeight)
+	selected := 0
+	for _, item := range c.items {
+		if item.Selected {
+			selected++
+		}
+	}
+	for i := c.top; i < end; i++ {
+		item := c.items[i]
+		pointer, mark := " ", " "
+		if i == c.cursor {
+			pointer = ">"
+		}
+		if item.Selected {
+			mark = "x"
+		}
+		b.WriteString("\r\x1b[2K")
+		fmt.Fprintf(&b, "%s [%s] %s", pointer, mark, item.Path)
+		if item.Note != "" {
+			fmt.Fprintf(&b, " (%s)", item.Note)
+		}
+		b.WriteString("\r\n")
+	}
+
+	b.WriteString("\r\x1b[2K")
+	fmt.Fprintf(&b, "  %d of %d selected", selected, len(c.items))
+	if len(c.items) > checklistHeight {
+		fmt.Fprintf(&b, ", showing %d-%d", c.top+1, end)
+	}
+	b.WriteString("\r\n")
+
+	c.drawn = end - c.top + 1
+	_, _ = io.WriteString(w, b.String())
+}
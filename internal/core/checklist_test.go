@@ -0,0 +1,72 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func checklistCandidates(n int) []Candidate {
+	candidates := make([]Candidate, n)
+	for i := range candidates {
+		candidates[i] = Candidate{Path: fmt.Sprintf("f%d.env", i), Selected: true}
+	}
+	return candidates
+}
+
+func TestRunChecklist(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "confirm keeps the preselection", input: "\r", want: []string{"f0.env", "f1.env", "f2.env"}},
+		{name: "space toggles under the cursor", input: "j \r", want: []string{"f0.env", "f2.env"}},
+		{name: "arrow keys move the cursor", input: "\x1b[B\x1b[B\x1b[A \r", want: []string{"f0.env", "f2.env"}},
+		{name: "cursor stops at the ends", input: "kkk jjjjj \r", want: []string{"f1.env"}},
+		{name: "a deselects all", input: "aj \r", want: []string{"f1.env"}},
+		{name: "a selects all when some are not", input: " a\r", want: []string{"f0.env", "f1.env", "f2.env"}},
+		{name: "unknown keys are ignored", input: "zz\x1b[C \r", want: []string{"f1.env", "f2.env"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := runChecklist(strings.NewReader(tt.input), &out, "Pick:", checklistCandidates(3))
+			if err != nil {
+				t.Fatalf("runChecklist failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunChecklist_Cancel(t *testing.T) {
+	for _, input := range []string{"q", "\x03", "\x1b"} {
+		var out bytes.Buffer
+		_, err := runChecklist(strings.NewReader(input), &out, "Pick:", checklistCandidates(2))
+		if !errors.Is(err, ErrSelectionCancelled) {
+			t.Errorf("%q: expected ErrSelectionCancelled, got %v", input, err)
+		}
+	}
+}
+
+func TestRunChecklist_Scrolls(t *testing.T) {
+	var out bytes.Buffer
+	input := strings.Repeat("j", checklistHeight+2) + "a \r"
+	got, err := runChecklist(strings.NewReader(input), &out, "Pick:", checklistCandidates(checklistHeight+5))
+	if err != nil {
+		t.Fatalf("runChecklist failed: %v", err)
+	}
+	want := fmt.Sprintf("f%d.env", checklistHeight+2)
+	if !reflect.DeepEqual(got, []string{want}) {
+		t.Errorf("got %v, want [%s]", got, want)
+	}
+	if !strings.Contains(out.String(), fmt.Sprintf("showing 4-%d", checklistHeight+3)) {
+		t.Errorf("Expected the window to follow the cursor, got:\n%s", out.String())
+	}
+}
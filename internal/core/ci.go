@@ -0,0 +1,82 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// GitHubMaskCommands returns ::add-mask:: workflow commands for every
+// non-empty value, so GitHub Actions replaces them with *** in logs. The
+// runner masks line by line, so each line of a multiline value is masked
+// separately.
+func GitHubMaskCommands(entries []DotenvEntry) string {
+	escape := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	seen := make(map[string]bool)
+	var b strings.Builder
+	for _, e := range entries {
+		for _, line := range strings.Split(strings.ReplaceAll(e.Value, "\r\n", "\n"), "\n") {
+			if strings.TrimSpace(line) == "" || seen[line] {
+				continue
+			}
+			seen[line] = true
+			fmt.Fprintf(&b, "::add-mask::%s\n", escape.Replace(line))
+		}
+	}
+	return b.String()
+}
+
+// FormatGitHubEnv renders variables in the syntax of the $GITHUB_ENV file
+// (implements `lockenv ci export-github`). Multiline values use the
+// NAME<<DELIMITER form with a random delimiter that does not occur in the
+// value. Names the runner cannot set are returned as skipped.
+func FormatGitHubEnv(entries []DotenvEntry) (output string, skipped []string, err error) {
+	var b strings.Builder
+	for _, e := range entries {
+		if !shellNamePattern.MatchString(e.Name) {
+			skipped = append(skipped, e.Name)
+			continue
+		}
+		if !strings.ContainsAny(e.Value, "\r\n") {
+			fmt.Fprintf(&b, "%s=%s\n", e.Name, e.Value)
+			continue
+		}
+		delimiter, err := heredocDelimiter(e.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", e.Name, delimiter, strings.TrimSuffix(e.Value, "\n"), delimiter)
+	}
+	return b.String(), skipped, nil
+}
+
+// heredocDelimiter returns a random delimiter that does not occur in value
+func heredocDelimiter(value string) (string, error) {
+	for {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return "", fmt.Errorf("failed to generate delimiter: %w", err)
+		}
+		delimiter := "ghadelimiter_" + hex.EncodeToString(random)
+		if !strings.Contains(value, delimiter) {
+			return delimiter, nil
+		}
+	}
+}
+
+// FormatGitLabDotenv renders variables as a GitLab CI dotenv report
+// (implements `lockenv ci export-gitlab`). GitLab reads values verbatim and
+// does not support multiline values, so variables with newlines or names
+// other than letters, digits and underscores are returned as skipped.
+func FormatGitLabDotenv(entries []DotenvEntry) (output string, skipped []string) {
+	var b strings.Builder
+	for _, e := range entries {
+		if !shellNamePattern.MatchString(e.Name) || strings.ContainsAny(e.Value, "\r\n") {
+			skipped = append(skipped, e.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", e.Name, e.Value)
+	}
+	return b.String(), skipped
+}
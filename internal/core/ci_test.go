@@ -0,0 +1,60 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+var ciTestEntries = []DotenvEntry{
+	{Name: "TOKEN", Value: "abc%123"},
+	{Name: "EMPTY", Value: ""},
+	{Name: "PEM", Value: "-----BEGIN KEY-----\nMIIB\n-----END KEY-----\n"},
+	{Name: "app.name", Value: "dotted"},
+}
+
+func TestGitHubMaskCommands(t *testing.T) {
+	got := GitHubMaskCommands(ciTestEntries)
+	want := "::add-mask::abc%25123\n" +
+		"::add-mask::-----BEGIN KEY-----\n" +
+		"::add-mask::MIIB\n" +
+		"::add-mask::-----END KEY-----\n" +
+		"::add-mask::dotted\n"
+	if got != want {
+		t.Errorf("GitHubMaskCommands() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatGitHubEnv(t *testing.T) {
+	got, skipped, err := FormatGitHubEnv(ciTestEntries)
+	if err != nil {
+		t.Fatalf("FormatGitHubEnv failed: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0] != "app.name" {
+		t.Errorf("skipped = %v, want [app.name]", skipped)
+	}
+
+	lines := strings.Split(got, "\n")
+	if lines[0] != "TOKEN=abc%123" || lines[1] != "EMPTY=" {
+		t.Errorf("single-line values = %q", lines[:2])
+	}
+	delimiter, ok := strings.CutPrefix(lines[2], "PEM<<")
+	if !ok || !strings.HasPrefix(delimiter, "ghadelimiter_") {
+		t.Fatalf("multiline header = %q", lines[2])
+	}
+	if body := strings.Join(lines[3:6], "\n"); body != "-----BEGIN KEY-----\nMIIB\n-----END KEY-----" {
+		t.Errorf("multiline body = %q", body)
+	}
+	if lines[6] != delimiter {
+		t.Errorf("closing delimiter = %q, want %q", lines[6], delimiter)
+	}
+}
+
+func TestFormatGitLabDotenv(t *testing.T) {
+	got, skipped := FormatGitLabDotenv(ciTestEntries)
+	if want := "TOKEN=abc%123\nEMPTY=\n"; got != want {
+		t.Errorf("FormatGitLabDotenv() = %q, want %q", got, want)
+	}
+	if strings.Join(skipped, ",") != "PEM,app.name" {
+		t.Errorf("skipped = %v, want [PEM app.name]", skipped)
+	}
+}
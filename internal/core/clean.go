@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// CleanResult contains the results of a clean operation
+type CleanResult struct {
+	Removed []string // Plaintext files removed from the working tree
+	Skipped []string // Files kept because they differ from the vault
+
+	// Events describes what happened to each file, in order, for the
+	// caller to show
+	Events EventLog
+}
+
+// Clean removes plaintext copies of tracked files from the working tree (implements `lockenv clean`).
+// Files are only removed if their content matches the vault, unless force is set,
+// and never if the vault holds no data for them.
+// When shred is set, file contents are overwritten with random data before removal.
+// No password is required since verification uses the manifest hashes.
+func (l *LockEnv) Clean(ctx context.Context, force bool, shred bool) (*CleanResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	// Open database
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	entries, err := l.getManifestEntries(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	result := &CleanResult{
+		Removed: []string{},
+		Skipped: []string{},
+	}
+
+	repoRoot := filepath.Dir(l.path)
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Validate path to prevent path traversal from tampered manifest
+		validPath, err := l.validator.ValidateExistingPath(entry.Path)
+		if err != nil {
+			continue
+		}
+		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
+
+		info, err := os.Lstat(platformPath)
+		if err != nil {
+			// Nothing to clean
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			result.skip(validPath, "not a regular file", nil)
+			continue
+		}
+
+		// The manifest hash is written by track as well: without stored
+		// data, the working-tree copy is the only one, even with force
+		stored, err := db.HasFileData(validPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check stored data of %s: %w", validPath, err)
+		}
+		if !stored {
+			result.skip(validPath, "never locked, lock it first", nil)
+			continue
+		}
+
+		if !force {
+			content, err := os.ReadFile(platformPath)
+			if err != nil {
+				result.skip(validPath, "", fmt.Errorf("cannot read: %w", err))
+				continue
+			}
+			hash := sha256.Sum256(content)
+			crypto.ClearBytes(content)
+
+			if hex.EncodeToString(hash[:]) != entry.Hash {
+				result.skip(validPath, "modified, lock it first or use --force", nil)
+				continue
+			}
+		}
+
+		if shred {
+			if err := shredFile(platformPath, info.Size()); err != nil {
+				result.skip(validPath, "", fmt.Errorf("cannot shred: %w", err))
+				continue
+			}
+		}
+
+		if err := os.Remove(platformPath); err != nil {
+			result.skip(validPath, "", fmt.Errorf("cannot remove: %w", err))
+			continue
+		}
+
+		result.Removed = append(result.Removed, validPath)
+		if shred {
+			result.Events.add(EventShredded, validPath, "")
+		} else {
+			result.Events.add(EventRemoved, validPath, "")
+		}
+	}
+
+	return result, nil
+}
+
+// shredFile overwrites a file's contents with random data and flushes it to disk.
+// Note that journaling and copy-on-write filesystems may still retain old blocks.
+func shredFile(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	const chunkSize = 32 * 1024
+	buf := make([]byte, chunkSize)
+	for remaining := size; remaining > 0; {
+		n := int64(chunkSize)
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := rand.Read(buf[:n]); err != nil {
+			return fmt.Errorf("failed to generate random data: %w", err)
+		}
+		if _, err := f.Write(buf[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+
+	return f.Sync()
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClean_RemovesOnlyUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	unchanged := filepath.Join(dir, "unchanged.env")
+	modified := filepath.Join(dir, "modified.env")
+	if err := os.WriteFile(unchanged, []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(modified, []byte("B=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := lockenv.LockFiles(context.Background(), []string{unchanged, modified}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if err := os.WriteFile(modified, []byte("B=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	result, err := lockenv.Clean(context.Background(), false, false)
+	if err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+
+	if len(result.Removed) != 1 || result.Removed[0] != "unchanged.env" {
+		t.Errorf("Expected unchanged.env to be removed, got %v", result.Removed)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "modified.env" {
+		t.Errorf("Expected modified.env to be skipped, got %v", result.Skipped)
+	}
+	if _, err := os.Stat(unchanged); !os.IsNotExist(err) {
+		t.Error("unchanged.env should have been removed")
+	}
+	if _, err := os.Stat(modified); err != nil {
+		t.Errorf("modified.env should have been kept: %v", err)
+	}
+}
+
+func TestClean_KeepsNeverLockedFiles(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	tracked := filepath.Join(dir, ".env")
+	if err := os.WriteFile(tracked, []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.TrackFiles(context.Background(), []string{tracked}, password, false); err != nil {
+		t.Fatalf("TrackFiles failed: %v", err)
+	}
+
+	// The manifest holds the file's hash, but the vault holds no copy
+	for _, force := range []bool{false, true} {
+		result, err := lockenv.Clean(context.Background(), force, false)
+		if err != nil {
+			t.Fatalf("Clean failed: %v", err)
+		}
+		if len(result.Removed) != 0 {
+			t.Errorf("Expected nothing removed with force=%v, got %v", force, result.Removed)
+		}
+		if _, err := os.Stat(tracked); err != nil {
+			t.Fatalf("Never locked file removed with force=%v: %v", force, err)
+		}
+	}
+}
+
+func TestClean_ForceShred(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	testFile := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(testFile, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if err := os.WriteFile(testFile, []byte("SECRET=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	result, err := lockenv.Clean(context.Background(), true, true)
+	if err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if len(result.Removed) != 1 {
+		t.Errorf("Expected 1 removed file, got %v", result.Removed)
+	}
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Error("secret.env should have been shredded")
+	}
+}
+
+func TestShredFile_OverwritesContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	original := []byte("very secret content")
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := shredFile(path, int64(len(original))); err != nil {
+		t.Fatalf("shredFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read shredded file: %v", err)
+	}
+	if len(data) != len(original) {
+		t.Errorf("Expected size %d, got %d", len(original), len(data))
+	}
+	if string(data) == string(original) {
+		t.Error("File content should have been overwritten")
+	}
+}
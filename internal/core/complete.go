@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// VaultFileCandidates returns the vault paths starting with prefix, for shell
+// completion (implements `lockenv __complete files`). No password is needed;
+// paths come from the manifest. The prefix may use the platform's path
+// separator and keep an opening quote from the command line.
+func (l *LockEnv) VaultFileCandidates(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	entries, err := l.getManifestEntries(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	prefix = filepath.ToSlash(strings.TrimLeft(prefix, `'"`))
+	prefix = strings.TrimPrefix(prefix, "./")
+	var candidates []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Path, prefix) {
+			candidates = append(candidates, entry.Path)
+		}
+	}
+	return candidates, nil
+}
+
+// ProfileCandidates returns the profiles that --profile can select, taken
+// from .env.<profile> and <profile>.env at the top of the vault (implements
+// `lockenv __complete profiles`). No password is needed.
+func (l *LockEnv) ProfileCandidates(ctx context.Context, prefix string) ([]string, error) {
+	paths, err := l.VaultFileCandidates(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.TrimLeft(prefix, `'"`)
+	var candidates []string
+	for _, path := range paths {
+		var profile string
+		switch {
+		case strings.Contains(path, "/") || path == ".env":
+			continue
+		case strings.HasPrefix(path, ".env."):
+			profile = strings.TrimPrefix(path, ".env.")
+		case strings.HasSuffix(path, ".env"):
+			profile = strings.TrimSuffix(path, ".env")
+		}
+		if profile != "" && strings.HasPrefix(profile, prefix) && !slices.Contains(candidates, profile) {
+			candidates = append(candidates, profile)
+		}
+	}
+	slices.Sort(candidates)
+	return candidates, nil
+}
+
+// DomainCandidates returns the names of encryption domains starting with
+// prefix (implements `lockenv __complete domains`). No password is needed.
+func (l *LockEnv) DomainCandidates(prefix string) ([]string, error) {
+	domains, err := l.ListDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.TrimLeft(prefix, `'"`)
+	var candidates []string
+	for _, d := range domains {
+		if strings.HasPrefix(d.Name, prefix) {
+			candidates = append(candidates, d.Name)
+		}
+	}
+	return candidates, nil
+}
+
+// UnquoteCompletionWord undoes the shell quoting of a partially typed word,
+// so it can be matched against vault paths. Only bash passes the word with
+// backslash escapes intact.
+func UnquoteCompletionWord(word, shell string) string {
+	if shell != "bash" {
+		return word
+	}
+	var b strings.Builder
+	for i := 0; i < len(word); i++ {
+		if word[i] == '\\' && i+1 < len(word) {
+			i++
+		}
+		b.WriteByte(word[i])
+	}
+	return b.String()
+}
+
+// bashSpecialChars are escaped with a backslash in bash completions
+const bashSpecialChars = " \t\n'\"\\$`!&;|<>()*?[]#~{}="
+
+// powershellSpecialChars force a PowerShell completion into single quotes
+const powershellSpecialChars = " \t'\"`$();,{}@#&|<>‘’‚‛"
+
+// QuoteCompletion quotes a completion candidate for the given shell so that
+// paths with spaces or quotes are inserted as a single word. zsh and fish
+// quote candidates themselves and get them unchanged.
+func QuoteCompletion(candidate, shell string) string {
+	switch shell {
+	case "bash":
+		var b strings.Builder
+		for _, r := range candidate {
+			if strings.ContainsRune(bashSpecialChars, r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	case "powershell":
+		if strings.ContainsAny(candidate, powershellSpecialChars) {
+			return quotePowerShell(candidate)
+		}
+		return candidate
+	default:
+		return candidate
+	}
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestVaultFileCandidates(t *testing.T) {
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, []byte("test123"), map[string]string{
+		".env":           "A=1\n",
+		".env.prod":      "A=2\n",
+		"config/dev.env": "B=1\n",
+		"my secrets.txt": "s3cret",
+	})
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{".env", ".env.prod", "config/dev.env", "my secrets.txt"}},
+		{".env.", []string{".env.prod"}},
+		{"./config/", []string{"config/dev.env"}},
+		{"'my", []string{"my secrets.txt"}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		got, err := lockenv.VaultFileCandidates(ctx, tt.prefix)
+		if err != nil {
+			t.Fatalf("VaultFileCandidates(%q) failed: %v", tt.prefix, err)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("VaultFileCandidates(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestVaultFileCandidates_NotInitialized(t *testing.T) {
+	lockenv, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	if _, err := lockenv.VaultFileCandidates(context.Background(), ""); err != ErrNotInitialized {
+		t.Errorf("expected ErrNotInitialized, got %v", err)
+	}
+}
+
+func TestQuoteCompletion(t *testing.T) {
+	tests := []struct {
+		candidate, shell, want string
+	}{
+		{"config/dev.env", "bash", "config/dev.env"},
+		{"my secrets.txt", "bash", `my\ secrets.txt`},
+		{"it's $HOME.env", "bash", `it\'s\ \$HOME.env`},
+		{"my secrets.txt", "zsh", "my secrets.txt"},
+		{"my secrets.txt", "fish", "my secrets.txt"},
+		{"config/dev.env", "powershell", "config/dev.env"},
+		{"my secrets.txt", "powershell", "'my secrets.txt'"},
+		{"it's.env", "powershell", "'it''s.env'"},
+		{"a$b.env", "powershell", "'a$b.env'"},
+	}
+	for _, tt := range tests {
+		if got := QuoteCompletion(tt.candidate, tt.shell); got != tt.want {
+			t.Errorf("QuoteCompletion(%q, %q) = %q, want %q", tt.candidate, tt.shell, got, tt.want)
+		}
+	}
+}
+
+func TestUnquoteCompletionWord(t *testing.T) {
+	if got := UnquoteCompletionWord(`my\ sec`, "bash"); got != "my sec" {
+		t.Errorf("bash unquote = %q, want %q", got, "my sec")
+	}
+	if got := UnquoteCompletionWord(`dir\`, "bash"); got != `dir\` {
+		t.Errorf("trailing backslash = %q, want %q", got, `dir\`)
+	}
+	if got := UnquoteCompletionWord(`config\de`, "powershell"); got != `config\de` {
+		t.Errorf("powershell unquote = %q, want unchanged", got)
+	}
+}
+
+func TestProfileCandidates(t *testing.T) {
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, []byte("test123"), map[string]string{
+		".env":           "A=1\n",
+		".env.prod":      "A=2\n",
+		"staging.env":    "A=3\n",
+		"prod.env":       "A=4\n",
+		"config/dev.env": "B=1\n",
+	})
+
+	got, err := lockenv.ProfileCandidates(ctx, "")
+	if err != nil {
+		t.Fatalf("ProfileCandidates failed: %v", err)
+	}
+	if want := []string{"prod", "staging"}; !slices.Equal(got, want) {
+		t.Errorf("ProfileCandidates() = %q, want %q", got, want)
+	}
+
+	got, err = lockenv.ProfileCandidates(ctx, "st")
+	if err != nil || !slices.Equal(got, []string{"staging"}) {
+		t.Errorf("ProfileCandidates(\"st\") = %q, %v", got, err)
+	}
+}
+
+func TestDomainCandidates(t *testing.T) {
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1\n"})
+	for _, name := range []string{"prod", "preview", "dev"} {
+		if err := lockenv.AddDomain(password, name, []byte("domain-"+name)); err != nil {
+			t.Fatalf("AddDomain(%s) failed: %v", name, err)
+		}
+	}
+
+	got, err := lockenv.DomainCandidates("pr")
+	if err != nil {
+		t.Fatalf("DomainCandidates failed: %v", err)
+	}
+	slices.Sort(got)
+	if want := []string{"preview", "prod"}; !slices.Equal(got, want) {
+		t.Errorf("DomainCandidates(\"pr\") = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build windows
+
+package core
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// On Windows, users type paths with backslashes; they must still match the
+// slash-separated paths stored in the vault
+func TestVaultFileCandidates_WindowsSeparators(t *testing.T) {
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, []byte("test123"), map[string]string{
+		"config/dev.env":        "A=1\n",
+		"Program Files/app.env": "B=1\n",
+		"config/it's prod.env":  "C=1\n",
+	})
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{`config\de`, []string{"config/dev.env"}},
+		{`.\config\`, []string{"config/dev.env", "config/it's prod.env"}},
+		{`'Program Files\`, []string{"Program Files/app.env"}},
+	}
+	for _, tt := range tests {
+		got, err := lockenv.VaultFileCandidates(ctx, UnquoteCompletionWord(tt.prefix, "powershell"))
+		if err != nil {
+			t.Fatalf("VaultFileCandidates(%q) failed: %v", tt.prefix, err)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("VaultFileCandidates(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteCompletion_WindowsPaths(t *testing.T) {
+	tests := []struct {
+		candidate, want string
+	}{
+		{"Program Files/app.env", "'Program Files/app.env'"},
+		{"config/it's prod.env", "'config/it''s prod.env'"},
+		{"config/it’s.env", "'config/it’’s.env'"},
+		{"config/(old).env", "'config/(old).env'"},
+		{"config/dev.env", "config/dev.env"},
+	}
+	for _, tt := range tests {
+		if got := QuoteCompletion(tt.candidate, "powershell"); got != tt.want {
+			t.Errorf("QuoteCompletion(%q) = %q, want %q", tt.candidate, got, tt.want)
+		}
+	}
+}
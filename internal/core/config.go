@@ -0,0 +1,220 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConfigFile is the optional per-project configuration file next to .lockenv
+const ConfigFile = ".lockenv.toml"
+
+// Config holds the settings from .lockenv.toml
+type Config struct {
+	PasswordCommand string                // Command whose stdout is the password
+	HashiCorpVault  *HashiCorpVaultConfig // Fetch the password from HashiCorp Vault
+	Hooks           map[string]string     // Commands run on lifecycle events, by event name
+	MaxFileSize     int64                 // Largest file lock accepts in bytes, 0 for no limit
+	Deterministic   bool                  // Encrypt equal file contents to equal ciphertext
+	Merge           MergeOptions          // Editor merge settings: merge_temp_dir and the [merge] table
+	ScanIgnore      []string              // Paths and globs scan treats as not secret
+	Vaults          map[string]string     // Project directories of referenced vaults, by vault ID
+}
+
+// HashiCorpVaultConfig locates the vault password in a HashiCorp Vault KV engine
+type HashiCorpVaultConfig struct {
+	Address   string // Server address, defaults to VAULT_ADDR
+	Path      string // Secret path, e.g. secret/data/myapp for KV v2
+	Field     string // Field holding the password
+	Namespace string // Enterprise namespace, defaults to VAULT_NAMESPACE
+}
+
+// DefaultHashiCorpVaultField is the secret field read when none is configured
+const DefaultHashiCorpVaultField = "password"
+
+// LoadConfig reads .lockenv.toml from dir. A missing file yields an empty config.
+// The internal/config package layers it over the user config.
+func LoadConfig(dir string) (*Config, error) {
+	values, err := ReadConfigFile(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfig(values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	return config, nil
+}
+
+// ReadConfigFile reads a config file in the .lockenv.toml format into dotted
+// keys (hooks.post-unlock) and their values. A missing file yields no values.
+func ReadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	values, err := flattenTOML(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// ParseConfig builds the settings from values read with ReadConfigFile.
+// Keys it does not know are ignored.
+func ParseConfig(values map[string]string) (*Config, error) {
+	config := &Config{
+		PasswordCommand: values["password_command"],
+		MaxFileSize:     DefaultMaxFileSize,
+		Merge: MergeOptions{
+			TempDir: values["merge_temp_dir"],
+			Editor:  values["merge.editor"],
+		},
+	}
+	if value, ok := values["max_file_size"]; ok {
+		size, err := parseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("max_file_size: %w", err)
+		}
+		config.MaxFileSize = size
+	}
+	if value, ok := values["deterministic"]; ok {
+		deterministic, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("deterministic: expected true or false, got %q", value)
+		}
+		config.Deterministic = deterministic
+	}
+	for key, list := range map[string]*[]string{
+		"merge.allowed_editors": &config.Merge.AllowedEditors,
+		"merge.denied_editors":  &config.Merge.DeniedEditors,
+		"scan.ignore":           &config.ScanIgnore,
+	} {
+		if value, ok := values[key]; ok {
+			items, err := parseStringArray(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			*list = items
+		}
+	}
+	for i, pattern := range config.ScanIgnore {
+		normalized, err := normalizeNonSecret(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("scan.ignore: %w", err)
+		}
+		config.ScanIgnore[i] = normalized
+	}
+	for key, command := range values {
+		event, ok := strings.CutPrefix(key, "hooks.")
+		if !ok {
+			continue
+		}
+		if !slices.Contains(hookEvents, event) {
+			return nil, fmt.Errorf("unknown hook %q (use %s)", event, strings.Join(hookEvents, ", "))
+		}
+		if config.Hooks == nil {
+			config.Hooks = make(map[string]string)
+		}
+		config.Hooks[event] = command
+	}
+	for key, dir := range values {
+		id, ok := strings.CutPrefix(key, "vaults.")
+		if !ok {
+			continue
+		}
+		if id == "" || dir == "" {
+			return nil, fmt.Errorf("%s: expected a vault ID and directory", key)
+		}
+		if config.Vaults == nil {
+			config.Vaults = make(map[string]string)
+		}
+		config.Vaults[id] = dir
+	}
+	if addr, path := values["hashicorp_vault.address"], values["hashicorp_vault.path"]; addr != "" || path != "" {
+		if path == "" {
+			return nil, fmt.Errorf("hashicorp_vault.path is required")
+		}
+		config.HashiCorpVault = &HashiCorpVaultConfig{
+			Address:   addr,
+			Path:      path,
+			Field:     values["hashicorp_vault.field"],
+			Namespace: values["hashicorp_vault.namespace"],
+		}
+		if config.HashiCorpVault.Field == "" {
+			config.HashiCorpVault.Field = DefaultHashiCorpVaultField
+		}
+	}
+
+	return config, nil
+}
+
+// parseStringArray parses a single-line TOML array of strings such as
+// ["vim", "nano"]
+func parseStringArray(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("expected an array of strings, got %s", s)
+	}
+	var items []string
+	rest := strings.TrimSpace(s[1 : len(s)-1])
+	for rest != "" {
+		if rest[0] != '"' && rest[0] != '\'' {
+			return nil, fmt.Errorf("expected a quoted string, got %s", rest)
+		}
+		end := quotedEnd(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string %s", rest)
+		}
+		item, err := unquote(rest[:end])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		// Elements are separated by commas; one may trail the last
+		rest = strings.TrimSpace(rest[end:])
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, fmt.Errorf("expected a comma, got %s", rest)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return items, nil
+}
+
+// sizeUnits are the suffixes parseSize accepts, longest first
+var sizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseSize parses a byte count such as 1048576, 512KB, 100MB or 2GB
+// (binary units, case-insensitive). TOML digit separators are allowed.
+func parseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "_", ""))
+	scale := int64(1)
+	for _, unit := range sizeUnits {
+		if rest, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, scale = strings.TrimSpace(rest), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/scale {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 100MB)", s)
+	}
+	return n * scale, nil
+}
@@ -0,0 +1,252 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig without file failed: %v", err)
+	}
+	if config.HashiCorpVault != nil {
+		t.Errorf("Expected no HashiCorp Vault config, got %+v", config.HashiCorpVault)
+	}
+
+	data := `# lockenv settings
+password_command = "op read 'op://Dev Vault/lockenv/password'"
+
+[hashicorp_vault]
+address = "https://vault.example.com:8200"
+path = "secret/data/myapp/lockenv" # KV v2
+`
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err = LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.PasswordCommand != "op read 'op://Dev Vault/lockenv/password'" {
+		t.Errorf("Unexpected password_command: %q", config.PasswordCommand)
+	}
+	hc := config.HashiCorpVault
+	if hc == nil || hc.Address != "https://vault.example.com:8200" || hc.Path != "secret/data/myapp/lockenv" || hc.Field != DefaultHashiCorpVaultField {
+		t.Errorf("Unexpected HashiCorp Vault config: %+v", hc)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("[hashicorp_vault]\naddress = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(dir); err == nil {
+		t.Error("Expected error when path is missing")
+	}
+}
+
+func TestLoadConfig_Strings(t *testing.T) {
+	dir := t.TempDir()
+	data := `password_command = "op read \"op://Vault/my item/password\"" # 1Password
+merge_temp_dir = 'C:\Temp\#merge'
+
+[hooks]
+post-unlock = "echo \"a, b\" \\ done\t# not a comment"
+
+[scan]
+ignore = ["a,b.env", 'c\d', "e\"f#g", ]
+`
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if want := `op read "op://Vault/my item/password"`; config.PasswordCommand != want {
+		t.Errorf("password_command = %q, want %q", config.PasswordCommand, want)
+	}
+	if want := `C:\Temp\#merge`; config.Merge.TempDir != want {
+		t.Errorf("merge_temp_dir = %q, want %q", config.Merge.TempDir, want)
+	}
+	if want := "echo \"a, b\" \\ done\t# not a comment"; config.Hooks["post-unlock"] != want {
+		t.Errorf("post-unlock = %q, want %q", config.Hooks["post-unlock"], want)
+	}
+	if want := []string{"a,b.env", `c\d`, `e"f#g`}; !slices.Equal(config.ScanIgnore, want) {
+		t.Errorf("ScanIgnore = %q, want %q", config.ScanIgnore, want)
+	}
+
+	for _, value := range []string{`"a\qb"`, `"open`, `"a" "b"`, `["a" "b"]`} {
+		if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("[scan]\nignore = "+value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(dir); err == nil {
+			t.Errorf("ignore = %s: expected error", value)
+		}
+	}
+}
+
+func TestLoadConfig_Hooks(t *testing.T) {
+	dir := t.TempDir()
+	data := `[hooks]
+post-unlock = "docker compose restart app"
+pre-lock = "./scripts/check-secrets.sh"
+`
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Hooks[HookPostUnlock] != "docker compose restart app" || config.Hooks[HookPreLock] != "./scripts/check-secrets.sh" {
+		t.Errorf("Unexpected hooks: %v", config.Hooks)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("[hooks]\npost-lok = \"true\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "post-lok") {
+		t.Errorf("Expected error for unknown hook, got %v", err)
+	}
+}
+
+func TestLoadConfig_MaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig without file failed: %v", err)
+	}
+	if config.MaxFileSize != DefaultMaxFileSize {
+		t.Errorf("default MaxFileSize = %d, want %d", config.MaxFileSize, DefaultMaxFileSize)
+	}
+
+	for _, tt := range []struct {
+		value string
+		want  int64
+	}{
+		{`"500MB"`, 500 << 20},
+		{`"64 kb"`, 64 << 10},
+		{`"2G"`, 2 << 30},
+		{`1_048_576`, 1 << 20},
+		{`0`, 0},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("max_file_size = "+tt.value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadConfig(dir)
+		if err != nil {
+			t.Errorf("max_file_size = %s: %v", tt.value, err)
+			continue
+		}
+		if config.MaxFileSize != tt.want {
+			t.Errorf("max_file_size = %s: got %d, want %d", tt.value, config.MaxFileSize, tt.want)
+		}
+	}
+
+	for _, value := range []string{`"big"`, `"-1MB"`, `"1TB"`, `"99999999999GB"`} {
+		if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("max_file_size = "+value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "max_file_size") {
+			t.Errorf("max_file_size = %s: expected error, got %v", value, err)
+		}
+	}
+}
+
+func TestLoadConfig_Deterministic(t *testing.T) {
+	dir := t.TempDir()
+	for value, want := range map[string]bool{"true": true, "false": false} {
+		if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("deterministic = "+value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadConfig(dir)
+		if err != nil {
+			t.Fatalf("deterministic = %s: %v", value, err)
+		}
+		if config.Deterministic != want {
+			t.Errorf("deterministic = %s: got %v", value, config.Deterministic)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("deterministic = \"sometimes\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "deterministic") {
+		t.Errorf("Expected error for invalid value, got %v", err)
+	}
+}
+
+func TestLoadConfig_Merge(t *testing.T) {
+	dir := t.TempDir()
+	data := `merge_temp_dir = "/dev/shm"
+
+[merge]
+editor = "nvim"
+allowed_editors = ["nvim", 'vim',]
+denied_editors = []
+`
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	m := config.Merge
+	if m.TempDir != "/dev/shm" || m.Editor != "nvim" || !slices.Equal(m.AllowedEditors, []string{"nvim", "vim"}) || len(m.DeniedEditors) != 0 {
+		t.Errorf("Unexpected merge config: %+v", m)
+	}
+
+	for _, value := range []string{`"vim"`, `[vim]`, `["vim"`} {
+		if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("[merge]\nallowed_editors = "+value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "merge.allowed_editors") {
+			t.Errorf("allowed_editors = %s: expected error, got %v", value, err)
+		}
+	}
+}
+
+func TestLoadConfig_ScanIgnore(t *testing.T) {
+	dir := t.TempDir()
+	data := "[scan]\nignore = [\"testdata/\", \"docs/**/*.pem\"]\n"
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if want := []string{"testdata", "docs/**/*.pem"}; !slices.Equal(config.ScanIgnore, want) {
+		t.Errorf("ScanIgnore = %v, want %v", config.ScanIgnore, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte("[scan]\nignore = [\"../shared\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "scan.ignore") {
+		t.Errorf("Expected a scan.ignore error, got %v", err)
+	}
+}
+
+func TestLoadConfig_Vaults(t *testing.T) {
+	dir := t.TempDir()
+	data := "[vaults]\n3f9a2c = \"../org-secrets\"\n"
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(config.Vaults) != 1 || config.Vaults["3f9a2c"] != "../org-secrets" {
+		t.Errorf("Vaults = %v", config.Vaults)
+	}
+}
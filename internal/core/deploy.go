@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DeployTarget is where lockenv deploy puts files: a directory on an SSH host
+type DeployTarget struct {
+	Host string // [user@]host, or a Host alias from the SSH config
+	Dir  string // Remote directory; relative paths start at the remote home
+}
+
+// ParseDeployTarget parses an scp-style [user@]host:dir destination, with
+// IPv6 addresses in brackets. An empty dir means the remote home directory.
+func ParseDeployTarget(s string) (*DeployTarget, error) {
+	host, dir, ok := strings.Cut(s, ":")
+	if before, after, found := strings.Cut(s, "]:"); found && strings.Contains(before, "[") {
+		host, dir, ok = strings.Replace(before, "[", "", 1), after, true
+	}
+	if !ok || host == "" || strings.HasPrefix(host, "-") || strings.ContainsAny(host, " \t/") {
+		return nil, fmt.Errorf("invalid deploy target %q (use [user@]host:/dir)", s)
+	}
+	if dir == "" {
+		dir = "."
+	}
+	return &DeployTarget{Host: host, Dir: dir}, nil
+}
+
+func (t *DeployTarget) String() string {
+	return t.Host + ":" + t.Dir
+}
+
+// remoteCommand returns the shell command that unpacks the archive on the
+// host. umask 077 keeps the files and any directories created for them
+// owner-only unless the archive asks for more with --preserve-mode.
+func (t *DeployTarget) remoteCommand() string {
+	dir := quoteSh(t.Dir)
+	return "umask 077 && mkdir -p -- " + dir + " && tar -x -f - -C " + dir
+}
+
+// runSSH runs ssh with args and r as its stdin; replaced in tests. Its
+// output goes to stderr, and the terminal stays available for host key and
+// password prompts.
+var runSSH = func(ctx context.Context, r io.Reader, args ...string) error {
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ssh: %w", err)
+	}
+	return nil
+}
+
+// sshWriter streams to the stdin of ssh, starting it on the first write so
+// nothing connects to the host if the vault cannot be read
+type sshWriter struct {
+	ctx  context.Context
+	args []string
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *sshWriter) Write(p []byte) (int, error) {
+	if w.pw == nil {
+		pr, pw := io.Pipe()
+		w.pw = pw
+		w.done = make(chan error, 1)
+		go func() {
+			err := runSSH(w.ctx, pr, w.args...)
+			// Unblock writes if ssh exits before reading everything
+			if err != nil {
+				pr.CloseWithError(err)
+			} else {
+				pr.CloseWithError(io.ErrClosedPipe)
+			}
+			w.done <- err
+		}()
+	}
+	return w.pw.Write(p)
+}
+
+// Close ends the stream and waits for ssh to exit
+func (w *sshWriter) Close() error {
+	if w.pw == nil {
+		return nil
+	}
+	w.pw.Close()
+	return <-w.done
+}
+
+// Deploy streams the decrypted files matching patterns (all files if empty)
+// to target over SSH and unpacks them there (implements `lockenv deploy`).
+// The plaintext only exists in memory and on the remote host: it is written
+// as a tar archive, as with UnlockTar, to the stdin of the system ssh, so the
+// user's SSH config, agent and known hosts apply.
+func (l *LockEnv) Deploy(ctx context.Context, password []byte, target *DeployTarget, patterns []string) (*UnlockResult, error) {
+	w := &sshWriter{ctx: ctx, args: []string{target.Host, target.remoteCommand()}}
+	result, err := l.UnlockTar(ctx, password, patterns, w)
+	if closeErr := w.Close(); closeErr != nil {
+		// The ssh error explains a failed write better than the closed pipe
+		return nil, fmt.Errorf("failed to deploy to %s: %w", target, closeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseDeployTarget(t *testing.T) {
+	for in, want := range map[string]DeployTarget{
+		"deploy@web1:/etc/app/": {Host: "deploy@web1", Dir: "/etc/app/"},
+		"web1:app":              {Host: "web1", Dir: "app"},
+		"web1:":                 {Host: "web1", Dir: "."},
+		"[::1]:/srv":            {Host: "::1", Dir: "/srv"},
+		"root@[fe80::1]:/srv":   {Host: "root@fe80::1", Dir: "/srv"},
+	} {
+		got, err := ParseDeployTarget(in)
+		if err != nil || *got != want {
+			t.Errorf("ParseDeployTarget(%q) = %+v, %v, want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"web1", ":/etc", "-oProxyCommand=x:/etc", "a b:/etc", "./dir:/x"} {
+		if _, err := ParseDeployTarget(in); err == nil {
+			t.Errorf("ParseDeployTarget(%q) should fail", in)
+		}
+	}
+}
+
+// fakeSSH replaces runSSH for a test, recording the arguments and the names
+// and contents of the archive entries it receives
+func fakeSSH(t *testing.T, fail error) (args *[]string, files map[string]string) {
+	t.Helper()
+	files = make(map[string]string)
+	args = new([]string)
+	orig := runSSH
+	t.Cleanup(func() { runSSH = orig })
+	runSSH = func(ctx context.Context, r io.Reader, a ...string) error {
+		*args = a
+		if fail != nil {
+			return fail
+		}
+		tr := tar.NewReader(r)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			files[header.Name] = string(data)
+		}
+	}
+	return args, files
+}
+
+func TestDeploy(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1", "tls/server.pem": "PEM"})
+	target := &DeployTarget{Host: "deploy@web1", Dir: "/etc/it's"}
+
+	args, files := fakeSSH(t, nil)
+	result, err := lockenv.Deploy(ctx, password, target, []string{"tls/*.pem"})
+	if err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if len(result.Extracted) != 1 || len(files) != 1 || files["tls/server.pem"] != "PEM" {
+		t.Errorf("Deployed %v, remote got %v", result.Extracted, files)
+	}
+	want := `umask 077 && mkdir -p -- '/etc/it'\''s' && tar -x -f - -C '/etc/it'\''s'`
+	if len(*args) != 2 || (*args)[0] != "deploy@web1" || (*args)[1] != want {
+		t.Errorf("ssh called with %q", *args)
+	}
+
+	// Nothing connects to the host when the vault cannot be read
+	*args = nil
+	if _, err := lockenv.Deploy(ctx, []byte("wrong"), target, nil); err == nil {
+		t.Error("Expected error for a wrong password")
+	}
+	if *args != nil {
+		t.Errorf("ssh should not run for a wrong password, got %q", *args)
+	}
+}
+
+func TestDeploy_SSHFailure(t *testing.T) {
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": strings.Repeat("A=1\n", 10000)})
+	sshErr := errors.New("ssh: exit status 255")
+	fakeSSH(t, sshErr)
+
+	_, err := lockenv.Deploy(context.Background(), password, &DeployTarget{Host: "web1", Dir: "."}, nil)
+	if !errors.Is(err, sshErr) {
+		t.Errorf("Expected the ssh error, got %v", err)
+	}
+}
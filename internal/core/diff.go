@@ -0,0 +1,467 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/git"
+	"github.com/illarion/lockenv/internal/logging"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+const hexdumpRowSize = 16 // Bytes per hexdump row
+
+// DiffOptions controls how differences are rendered
+type DiffOptions struct {
+	HexdumpBytes int  // Bytes to hexdump from the first difference in binary files (0 disables)
+	ShowSecrets  bool // Reveal values instead of masking them with ****
+	Stat         bool // Summarize changed lines per file instead of showing them
+}
+
+// File changes in a DiffResult
+const (
+	ChangeModified = "modified" // Both versions exist and differ
+	ChangeAdded    = "added"    // Only in the current vault (DiffRevision)
+	ChangeRemoved  = "removed"  // Only in the vault at the revision (DiffRevision)
+	ChangeMissing  = "missing"  // In the vault, not in the working directory (Diff)
+)
+
+// FileDiff is the change of one file in a DiffResult
+type FileDiff struct {
+	Path   string
+	Change string // One of the Change constants
+	Diff   string // Unified diff for ChangeModified without colors; empty with DiffOptions.Stat
+}
+
+// DiffResult is the outcome of Diff, DiffRevision and DiffFiles, for the
+// caller to show
+type DiffResult struct {
+	Files  []FileDiff     // Changed files, in order
+	Stats  []FileDiffStat // Changed lines per file, with DiffOptions.Stat
+	Drift  *KeyDrift      // Keys in only one of the files DiffFiles compared, nil if they have none to compare
+	Events EventLog       // Files that could not be compared
+}
+
+// Changed reports whether any file differs
+func (r *DiffResult) Changed() bool {
+	return len(r.Files) > 0 || len(r.Stats) > 0
+}
+
+// DiffRevision compares the vault at a git revision with the current vault,
+// showing how secrets changed over time (implements `lockenv diff --rev`).
+// The historical vault is decrypted with the same password or key.
+func (l *LockEnv) DiffRevision(ctx context.Context, password []byte, rev string, opts DiffOptions) (*DiffResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	repoRoot := filepath.Dir(l.path)
+	oldVault, err := git.ShowFile(repoRoot, rev, LockEnvFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// bbolt needs a real file, keep it private while it exists. It gets a
+	// directory of its own for the objects of a dir layout vault.
+	tmpDir, err := os.MkdirTemp("", "lockenv-rev-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPath := filepath.Join(tmpDir, LockEnvFile)
+	if err := os.WriteFile(tmpPath, oldVault, FilePermSecure); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	oldDB, err := l.openAt(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vault at %s: %w", rev, err)
+	}
+	defer oldDB.Close()
+	if storage.LayoutOf(oldDB) == storage.LayoutDir {
+		if err := showObjects(oldDB, repoRoot, rev, filepath.Join(tmpDir, storage.ObjectsDir)); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DiffResult{}
+	oldFiles, err := l.decryptAllFiles(ctx, oldDB, password, &result.Events)
+	if err != nil {
+		if err == ErrWrongPassword {
+			return nil, fmt.Errorf("cannot decrypt vault at %s (password changed since?): %w", rev, err)
+		}
+		return nil, err
+	}
+	defer clearFileMap(oldFiles)
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	newFiles, err := l.decryptAllFiles(ctx, db, password, &result.Events)
+	if err != nil {
+		return nil, err
+	}
+	defer clearFileMap(newFiles)
+
+	// Union of paths in stable order
+	paths := make([]string, 0, len(oldFiles)+len(newFiles))
+	for path := range oldFiles {
+		paths = append(paths, path)
+	}
+	for path := range newFiles {
+		if _, ok := oldFiles[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		oldData, inOld := oldFiles[path]
+		newData, inNew := newFiles[path]
+		if opts.Stat {
+			if stat := diffStat(path, oldData, newData); stat != nil {
+				result.Stats = append(result.Stats, *stat)
+			}
+			continue
+		}
+		switch {
+		case !inOld:
+			result.Files = append(result.Files, FileDiff{Path: path, Change: ChangeAdded})
+			continue
+		case !inNew:
+			result.Files = append(result.Files, FileDiff{Path: path, Change: ChangeRemoved})
+			continue
+		}
+
+		diff, err := GenerateUnifiedDiff(path, oldData, newData, opts)
+		if err != nil {
+			result.Events.addErr(EventError, path, fmt.Errorf("cannot generate diff: %w", err))
+			continue
+		}
+		if diff != "" {
+			result.Files = append(result.Files, FileDiff{Path: path, Change: ChangeModified, Diff: diff})
+		}
+	}
+
+	return result, nil
+}
+
+// showObjects writes the objects of the dir layout vault db as of rev into
+// dir. Files that were only tracked at rev have no object and are skipped.
+func showObjects(db storage.Backend, repoRoot, rev, dir string) error {
+	paths, err := db.GetTrackedFiles()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, DirPermSecure); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	for _, path := range paths {
+		name := storage.ObjectName(path)
+		data, err := git.ShowFile(repoRoot, rev, storage.ObjectsDir+"/"+name)
+		if err != nil {
+			logging.Debug("no object at revision", "path", path, "rev", rev, "error", err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, FilePermSecure); err != nil {
+			return fmt.Errorf("failed to write temp file: %w", err)
+		}
+	}
+	return nil
+}
+
+// decryptAllFiles decrypts every file in the vault currently open in db.
+// Files whose blobs are missing or cannot be decrypted, or whose encryption
+// domain is locked, are recorded in events as warnings and skipped.
+// The caller is responsible for clearing the returned data with clearFileMap.
+func (l *LockEnv) decryptAllFiles(ctx context.Context, db storage.Backend, password []byte, events *EventLog) (map[string][]byte, error) {
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	files := make(map[string][]byte, len(metadata.Files))
+	for _, file := range metadata.Files {
+		if err := ctx.Err(); err != nil {
+			clearFileMap(files)
+			return nil, err
+		}
+
+		if file.Ref != nil {
+			plain, err := l.resolveReference(ctx, &file)
+			if err != nil {
+				events.addErr(EventWarning, "", err)
+				continue
+			}
+			files[file.Path] = bytes.Clone(plain.Borrow())
+			plain.Close()
+			continue
+		}
+		fileEnc, err := l.fileEncryptor(&file, enc)
+		if err != nil {
+			events.addErr(EventWarning, file.Path, fmt.Errorf("domain %s is locked", file.Domain))
+			continue
+		}
+		encryptedData, err := db.GetFileData(ctx, file.Path)
+		if err != nil {
+			events.addErr(EventWarning, file.Path, errors.New("not stored in vault"))
+			continue
+		}
+		data, err := fileEnc.Decrypt(encryptedData)
+		if err != nil {
+			events.addErr(EventWarning, file.Path, fmt.Errorf("cannot decrypt: %w", err))
+			continue
+		}
+		files[file.Path] = data
+	}
+
+	return files, nil
+}
+
+// clearFileMap zeroes all file contents in the map
+func clearFileMap(files map[string][]byte) {
+	for _, data := range files {
+		crypto.ClearBytes(data)
+	}
+}
+
+// formatBinaryDiff summarizes a binary file change with sizes, hashes and
+// detected content types, optionally followed by a hexdump of both versions
+// starting at the first differing byte.
+func formatBinaryDiff(path string, oldData, newData []byte, hexdumpBytes int) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Binary file %s has changed\n", path))
+
+	result.WriteString(fmt.Sprintf("   size:   %d -> %d bytes\n", len(oldData), len(newData)))
+
+	oldHash := sha256.Sum256(oldData)
+	newHash := sha256.Sum256(newData)
+	result.WriteString(fmt.Sprintf("   sha256: %s -> %s\n", hex.EncodeToString(oldHash[:]), hex.EncodeToString(newHash[:])))
+
+	oldType := contentType(oldData)
+	newType := contentType(newData)
+	if oldType == newType {
+		result.WriteString(fmt.Sprintf("   type:   %s\n", oldType))
+	} else {
+		result.WriteString(fmt.Sprintf("   type:   %s -> %s\n", oldType, newType))
+	}
+
+	if hexdumpBytes <= 0 {
+		return result.String()
+	}
+
+	offset := firstDifference(oldData, newData)
+	result.WriteString(fmt.Sprintf("   first difference at offset 0x%x\n", offset))
+
+	// Align to row boundary so offsets line up between versions
+	start := offset - offset%hexdumpRowSize
+	end := start + hexdumpBytes
+	for row := start; row < end; row += hexdumpRowSize {
+		rowEnd := min(row+hexdumpRowSize, end)
+		oldRow := sliceRange(oldData, row, rowEnd)
+		newRow := sliceRange(newData, row, rowEnd)
+		if len(oldRow) == 0 && len(newRow) == 0 {
+			break
+		}
+		if string(oldRow) == string(newRow) {
+			result.WriteString("     " + hexdumpRow(row, oldRow) + "\n")
+			continue
+		}
+		if len(oldRow) > 0 {
+			result.WriteString("   - " + hexdumpRow(row, oldRow) + "\n")
+		}
+		if len(newRow) > 0 {
+			result.WriteString("   + " + hexdumpRow(row, newRow) + "\n")
+		}
+	}
+
+	return result.String()
+}
+
+// contentType describes data for binary diffs. Sniffing calls zero bytes
+// text, so empty content is named as such.
+func contentType(data []byte) string {
+	if len(data) == 0 {
+		return "empty"
+	}
+	return http.DetectContentType(data)
+}
+
+// firstDifference returns the offset of the first byte that differs
+func firstDifference(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// sliceRange returns data[start:end] clamped to the data length
+func sliceRange(data []byte, start, end int) []byte {
+	if start >= len(data) {
+		return nil
+	}
+	return data[start:min(end, len(data))]
+}
+
+// hexdumpRow formats one row as "offset  hex bytes  |ascii|"
+func hexdumpRow(offset int, data []byte) string {
+	var hexPart, asciiPart strings.Builder
+	for i := 0; i < hexdumpRowSize; i++ {
+		if i < len(data) {
+			hexPart.WriteString(fmt.Sprintf("%02x ", data[i]))
+			if data[i] >= 32 && data[i] < 127 {
+				asciiPart.WriteByte(data[i])
+			} else {
+				asciiPart.WriteByte('.')
+			}
+		} else {
+			hexPart.WriteString("   ")
+		}
+	}
+	return fmt.Sprintf("%08x  %s |%s|", offset, hexPart.String(), asciiPart.String())
+}
+
+// KeyDrift lists the keys defined in only one of two files compared with
+// DiffFiles: dotenv variables, or the dotted key paths of structured files
+type KeyDrift struct {
+	OnlyA []string // Keys of the first file missing from the second, in file order
+	OnlyB []string // Keys of the second file missing from the first, in file order
+}
+
+// CompareKeys returns the keys defined in only one of two versions of the
+// file at path. ok is false if the file has no keys to compare: it is neither
+// a dotenv nor a structured file, or does not parse.
+func CompareKeys(path string, a, b []byte) (drift KeyDrift, ok bool) {
+	keysA, okA := fileKeys(path, a)
+	keysB, okB := fileKeys(path, b)
+	if !okA || !okB {
+		return drift, false
+	}
+	for _, key := range keysA {
+		if !slices.Contains(keysB, key) {
+			drift.OnlyA = append(drift.OnlyA, key)
+		}
+	}
+	for _, key := range keysB {
+		if !slices.Contains(keysA, key) {
+			drift.OnlyB = append(drift.OnlyB, key)
+		}
+	}
+	return drift, true
+}
+
+// fileKeys returns the keys defined in data, in file order for dotenv files
+// and sorted for structured files
+func fileKeys(path string, data []byte) ([]string, bool) {
+	if format := DetectStructuredFormat(path); format != "" {
+		flat, err := FlattenStructured(format, data)
+		if err != nil {
+			return nil, false
+		}
+		keys := make([]string, 0, len(flat))
+		for key := range flat {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys, true
+	}
+	if !IsDotenvFile(path) {
+		return nil, false
+	}
+	var keys []string
+	for _, key := range DotenvKeys(data) {
+		if !slices.Contains(keys, key.Name) {
+			keys = append(keys, key.Name)
+		}
+	}
+	return keys, true
+}
+
+// DiffFiles compares two files of the vault with each other, e.g.
+// .env.staging with .env.prod (implements `lockenv diff <file-a> <file-b>`).
+// The keys defined in only one of them are listed first, then the unified
+// diff from pathA to pathB. The working tree is not read. Identical files
+// yield a result without changes.
+func (l *LockEnv) DiffFiles(ctx context.Context, password []byte, pathA, pathB string, opts DiffOptions) (*DiffResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+	pathA = strings.TrimPrefix(filepath.ToSlash(pathA), "./")
+	pathB = strings.TrimPrefix(filepath.ToSlash(pathB), "./")
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	var contents [2]*crypto.SecureBuffer
+	for i, path := range []string{pathA, pathB} {
+		file := metadata.FindFile(path)
+		if file == nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+		}
+		plain, err := l.decryptEntry(ctx, db, file, enc)
+		if err != nil {
+			return nil, err
+		}
+		defer plain.Close()
+		contents[i] = plain
+	}
+	dataA, dataB := contents[0].Borrow(), contents[1].Borrow()
+
+	result := &DiffResult{}
+	if CompareFiles(dataA, dataB) {
+		return result, nil
+	}
+	if drift, ok := CompareKeys(pathB, dataA, dataB); ok {
+		result.Drift = &drift
+	}
+
+	if opts.Stat {
+		if stat := diffStat(pathA+" => "+pathB, dataA, dataB); stat != nil {
+			result.Stats = append(result.Stats, *stat)
+		}
+		return result, nil
+	}
+	diff, err := generateUnifiedDiff(pathA, pathB, dataA, dataB, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Files = append(result.Files, FileDiff{Path: pathB, Change: ChangeModified, Diff: diff})
+	return result, nil
+}
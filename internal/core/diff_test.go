@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// runGit runs a git command in dir, failing the test on error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	args = append([]string{"-c", "user.email=test@example.com", "-c", "user.name=test", "-c", "commit.gpgsign=false"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
+
+func TestDiffRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	testFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(testFile, []byte("KEY=old\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", LockEnvFile)
+	runGit(t, dir, "commit", "-q", "-m", "vault")
+
+	if err := os.WriteFile(testFile, []byte("KEY=new\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	result, err := lockenv.DiffRevision(context.Background(), password, "HEAD", DiffOptions{ShowSecrets: true})
+	if err != nil {
+		t.Fatalf("DiffRevision failed: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Change != ChangeModified || !strings.Contains(result.Files[0].Diff, "+KEY=new") {
+		t.Errorf("Expected .env modified, got %+v", result.Files)
+	}
+
+	if _, err := lockenv.DiffRevision(context.Background(), password, "no-such-rev", DiffOptions{}); err == nil {
+		t.Error("Expected error for unknown revision")
+	}
+
+	if _, err := lockenv.DiffRevision(context.Background(), []byte("wrong"), "HEAD", DiffOptions{}); err == nil {
+		t.Error("Expected error for wrong password")
+	}
+}
+
+func TestCompareKeys(t *testing.T) {
+	drift, ok := CompareKeys(".env.prod", []byte("A=1\nDEBUG=true\nURL=x\n"), []byte("URL=y\nA=2\nSENTRY_DSN=abc\n"))
+	if !ok {
+		t.Fatal("Expected dotenv files to be compared by key")
+	}
+	if !slices.Equal(drift.OnlyA, []string{"DEBUG"}) || !slices.Equal(drift.OnlyB, []string{"SENTRY_DSN"}) {
+		t.Errorf("Unexpected drift: %+v", drift)
+	}
+
+	drift, ok = CompareKeys("config.json", []byte(`{"db":{"host":"a","port":1}}`), []byte(`{"db":{"host":"b"},"debug":true}`))
+	if !ok || !slices.Equal(drift.OnlyA, []string{"db.port"}) || !slices.Equal(drift.OnlyB, []string{"debug"}) {
+		t.Errorf("Unexpected drift of structured files: %+v, %v", drift, ok)
+	}
+
+	if _, ok := CompareKeys("key.pem", []byte("a"), []byte("b")); ok {
+		t.Error("Expected files without keys not to be compared by key")
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{
+		".env.staging": "A=1\nDEBUG=true\n",
+		".env.prod":    "A=2\nSENTRY_DSN=abc\n",
+	})
+
+	result, err := lockenv.DiffFiles(ctx, password, ".env.staging", "./.env.prod", DiffOptions{})
+	if err != nil {
+		t.Fatalf("DiffFiles failed: %v", err)
+	}
+	drift := result.Drift
+	if drift == nil || !slices.Equal(drift.OnlyA, []string{"DEBUG"}) || !slices.Equal(drift.OnlyB, []string{"SENTRY_DSN"}) {
+		t.Errorf("Unexpected drift: %+v", drift)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].Diff == "" {
+		t.Errorf("Expected a diff, got %+v", result.Files)
+	}
+	if result, err := lockenv.DiffFiles(ctx, password, ".env.staging", ".env.staging", DiffOptions{}); err != nil || result.Changed() {
+		t.Errorf("Expected identical files unchanged, got %+v, %v", result, err)
+	}
+
+	if _, err := lockenv.DiffFiles(ctx, password, ".env.staging", ".env.missing", DiffOptions{}); !errors.Is(err, ErrFileNotInVault) {
+		t.Errorf("Expected ErrFileNotInVault, got %v", err)
+	}
+	if _, err := lockenv.DiffFiles(ctx, []byte("wrong"), ".env.staging", ".env.prod", DiffOptions{}); err == nil {
+		t.Error("Expected error for wrong password")
+	}
+}
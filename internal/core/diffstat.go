@@ -0,0 +1,109 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/output"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const diffStatBarWidth = 40 // Widest +/- bar in lockenv diff --stat
+
+// FileDiffStat summarizes the change to one file without its contents
+type FileDiffStat struct {
+	Path       string
+	Insertions int  // Added lines
+	Deletions  int  // Removed lines
+	Binary     bool // Either version is binary; only sizes are compared
+	OldSize    int
+	NewSize    int
+}
+
+// diffStat counts the lines changed between oldData and newData.
+// Returns nil if they are identical.
+func diffStat(path string, oldData, newData []byte) *FileDiffStat {
+	if CompareFiles(oldData, newData) {
+		return nil
+	}
+
+	stat := &FileDiffStat{Path: path, OldSize: len(oldData), NewSize: len(newData)}
+	if !DetectFileType(oldData) || !DetectFileType(newData) {
+		stat.Binary = true
+		return stat
+	}
+
+	dmp := diffmatchpatch.New()
+	a, b, lineArray := dmp.DiffLinesToChars(string(oldData), string(newData))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+	for _, d := range diffs {
+		lines := strings.Count(d.Text, "\n")
+		if d.Text != "" && !strings.HasSuffix(d.Text, "\n") {
+			lines++
+		}
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			stat.Insertions += lines
+		case diffmatchpatch.DiffDelete:
+			stat.Deletions += lines
+		}
+	}
+	return stat
+}
+
+// FormatDiffStat renders stats like git diff --stat: one line per file with
+// a +/- bar scaled to diffStatBarWidth, then the totals
+func FormatDiffStat(stats []FileDiffStat, p output.Palette) string {
+	pathWidth, maxChanges := 0, 0
+	for _, s := range stats {
+		pathWidth = max(pathWidth, len(s.Path))
+		maxChanges = max(maxChanges, s.Insertions+s.Deletions)
+	}
+	countWidth := len(fmt.Sprint(maxChanges))
+
+	var result strings.Builder
+	insertions, deletions := 0, 0
+	for _, s := range stats {
+		if s.Binary {
+			fmt.Fprintf(&result, " %-*s | Bin %d -> %d bytes\n", pathWidth, s.Path, s.OldSize, s.NewSize)
+			continue
+		}
+		insertions += s.Insertions
+		deletions += s.Deletions
+
+		plus, minus := s.Insertions, s.Deletions
+		if maxChanges > diffStatBarWidth {
+			plus = scaleDiffStat(plus, maxChanges)
+			minus = scaleDiffStat(minus, maxChanges)
+		}
+		fmt.Fprintf(&result, " %-*s | %*d %s%s\n", pathWidth, s.Path, countWidth, s.Insertions+s.Deletions,
+			p.Green(strings.Repeat("+", plus)), p.Red(strings.Repeat("-", minus)))
+	}
+
+	fmt.Fprintf(&result, " %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if insertions > 0 {
+		fmt.Fprintf(&result, ", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+	if deletions > 0 {
+		fmt.Fprintf(&result, ", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+	result.WriteString("\n")
+	return result.String()
+}
+
+// scaleDiffStat scales a line count to the bar width, keeping at least one
+// character for any change
+func scaleDiffStat(n, maxChanges int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(1, n*diffStatBarWidth/maxChanges)
+}
+
+// plural returns singular for n == 1 and pluralForm otherwise
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
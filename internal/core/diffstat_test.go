@@ -0,0 +1,56 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/output"
+)
+
+func TestDiffStat(t *testing.T) {
+	if stat := diffStat(".env", []byte("A=1\n"), []byte("A=1\n")); stat != nil {
+		t.Errorf("Expected nil for identical files, got %+v", stat)
+	}
+
+	stat := diffStat(".env", []byte("A=1\nB=2\nC=3\n"), []byte("A=1\nB=changed\nC=3\nD=4"))
+	if stat == nil || stat.Insertions != 2 || stat.Deletions != 1 || stat.Binary {
+		t.Errorf("Expected 2 insertions and 1 deletion, got %+v", stat)
+	}
+
+	stat = diffStat("new.env", nil, []byte("A=1\nB=2\n"))
+	if stat == nil || stat.Insertions != 2 || stat.Deletions != 0 {
+		t.Errorf("Expected an added file to count all lines, got %+v", stat)
+	}
+
+	stat = diffStat("cert.p12", []byte{0, 1, 2}, []byte{0, 1, 2, 3})
+	if stat == nil || !stat.Binary || stat.OldSize != 3 || stat.NewSize != 4 {
+		t.Errorf("Expected a binary change of 3 -> 4 bytes, got %+v", stat)
+	}
+}
+
+func TestFormatDiffStat(t *testing.T) {
+	got := FormatDiffStat([]FileDiffStat{
+		{Path: ".env", Insertions: 2, Deletions: 1},
+		{Path: "config/cert.p12", Binary: true, OldSize: 3, NewSize: 4},
+	}, output.Palette{})
+	want := "" +
+		" .env            | 3 ++-\n" +
+		" config/cert.p12 | Bin 3 -> 4 bytes\n" +
+		" 2 files changed, 2 insertions(+), 1 deletion(-)\n"
+	if got != want {
+		t.Errorf("FormatDiffStat() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Large changes are scaled to the bar width
+	got = FormatDiffStat([]FileDiffStat{{Path: "big.env", Insertions: 400}, {Path: "small.env", Deletions: 1}}, output.Palette{})
+	lines := strings.Split(got, "\n")
+	if n := strings.Count(lines[0], "+"); n != diffStatBarWidth {
+		t.Errorf("Expected a bar of %d, got %d: %q", diffStatBarWidth, n, lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "  1 -") {
+		t.Errorf("Expected small changes to keep one character, got %q", lines[1])
+	}
+	if !strings.Contains(got, "2 files changed, 400 insertions(+), 1 deletion(-)") {
+		t.Errorf("Unexpected totals: %q", got)
+	}
+}
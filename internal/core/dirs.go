@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/ignore"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// DirScanResult reports how tracked directories differ from the vault
+type DirScanResult struct {
+	New     []string // Files in tracked directories that are not yet in the vault
+	Deleted []string // Vault files whose tracked directory exists but the file doesn't
+}
+
+// getTrackedDirs returns the directories tracked with lock --recursive
+func getTrackedDirs(db storage.ConfigStore) ([]string, error) {
+	var dirs []string
+	_, err := getRecord(db, recordDirs, &dirs)
+	return dirs, err
+}
+
+// addTrackedDir records a directory tracked with lock --recursive
+func addTrackedDir(db storage.ConfigStore, dir string) error {
+	dirs, err := getTrackedDirs(db)
+	if err != nil || slices.Contains(dirs, dir) {
+		return err
+	}
+	return putRecord(db, recordDirs, append(dirs, dir))
+}
+
+// removeTrackedDir stops tracking a directory; returns false if it was not
+// tracked
+func removeTrackedDir(db storage.ConfigStore, dir string) (bool, error) {
+	dirs, err := getTrackedDirs(db)
+	if err != nil {
+		return false, err
+	}
+	i := slices.Index(dirs, dir)
+	if i < 0 {
+		return false, nil
+	}
+	return true, putRecord(db, recordDirs, slices.Delete(dirs, i, i+1))
+}
+
+// walkDirectory returns all regular files under dir (absolute paths), skipping
+// the vault itself, its backups, .git and anything matched by .lockenvignore
+func (l *LockEnv) walkDirectory(dir string, matcher *ignore.Matcher) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := l.normalizeToRelative(path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if d.IsDir() {
+			if d.Name() == ".git" || relPath == DefaultBackupDir || relPath == storage.ObjectsDir || (path != dir && matcher.Match(relPath, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Symlinks and special files are not followed
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if relPath == LockEnvFile || matcher.Match(relPath, false) {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// lockDirectory tracks every file under dir, recording them in report, and
+// records dir for later scans. sealing is passed on to lockSingleFile.
+func (l *LockEnv) lockDirectory(db storage.Backend, dir string, metadata *storage.Metadata, report *LockReport, sealing bool) error {
+	relDir, err := l.normalizeToRelative(dir)
+	if err != nil {
+		report.fail(dir, err)
+		return nil
+	}
+	validDir, err := l.validator.ValidateAndNormalize(relDir)
+	if err != nil {
+		report.fail(dir, fmt.Errorf("invalid path: %w", err))
+		return nil
+	}
+
+	matcher, err := ignore.Load(filepath.Dir(l.path))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", ignore.FileName, err)
+	}
+
+	files, err := l.walkDirectory(dir, matcher)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", validDir, err)
+	}
+
+	if err := addTrackedDir(db, validDir); err != nil {
+		return fmt.Errorf("failed to track directory %s: %w", validDir, err)
+	}
+
+	for _, file := range files {
+		if err := l.lockSingleFile(db, file, metadata, report, sealing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanTrackedDirs compares directories tracked with lock --recursive against
+// the vault, finding new files to add and tracked files that were deleted.
+// Directories that don't exist locally (e.g. after lock --remove) are skipped.
+// No password is required.
+func (l *LockEnv) ScanTrackedDirs(ctx context.Context) (*DirScanResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	// Open database
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	result := &DirScanResult{
+		New:     []string{},
+		Deleted: []string{},
+	}
+
+	dirs, err := getTrackedDirs(db)
+	if err != nil || len(dirs) == 0 {
+		return result, nil
+	}
+
+	entries, err := l.getManifestEntries(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	tracked := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		tracked[entry.Path] = true
+	}
+
+	matcher, err := ignore.Load(filepath.Dir(l.path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignore.FileName, err)
+	}
+
+	repoRoot := filepath.Dir(l.path)
+	seen := make(map[string]bool)
+
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		validDir, err := l.validator.ValidateExistingPath(dir)
+		if err != nil {
+			continue
+		}
+		absDir := filepath.Join(repoRoot, filepath.FromSlash(validDir))
+		if info, err := os.Stat(absDir); err != nil || !info.IsDir() {
+			continue
+		}
+
+		files, err := l.walkDirectory(absDir, matcher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", validDir, err)
+		}
+		for _, file := range files {
+			relPath, err := l.normalizeToRelative(file)
+			if err != nil {
+				continue
+			}
+			relPath = filepath.ToSlash(relPath)
+			if !tracked[relPath] && !seen[relPath] {
+				seen[relPath] = true
+				result.New = append(result.New, relPath)
+			}
+		}
+
+		prefix := validDir + "/"
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Path, prefix) || seen[entry.Path] {
+				continue
+			}
+			platformPath := filepath.Join(repoRoot, filepath.FromSlash(entry.Path))
+			if _, err := os.Lstat(platformPath); os.IsNotExist(err) {
+				seen[entry.Path] = true
+				result.Deleted = append(result.Deleted, entry.Path)
+			}
+		}
+	}
+
+	sort.Strings(result.New)
+	sort.Strings(result.Deleted)
+	return result, nil
+}
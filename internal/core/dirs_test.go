@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/ignore"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestTrackedDirs(t *testing.T) {
+	db := storage.NewMemory()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	// Duplicates are ignored
+	for _, d := range []string{"secrets", "config/keys", "secrets"} {
+		if err := addTrackedDir(db, d); err != nil {
+			t.Fatalf("Failed to add tracked dir: %v", err)
+		}
+	}
+	if dirs, err := getTrackedDirs(db); err != nil || len(dirs) != 2 {
+		t.Fatalf("Expected 2 dirs, got %v, %v", dirs, err)
+	}
+
+	if removed, err := removeTrackedDir(db, "secrets"); err != nil || !removed {
+		t.Errorf("Removing secrets = %v, %v", removed, err)
+	}
+	if removed, err := removeTrackedDir(db, "secrets"); err != nil || removed {
+		t.Errorf("Removing an untracked dir = %v, %v, want false", removed, err)
+	}
+	if dirs, _ := getTrackedDirs(db); len(dirs) != 1 || dirs[0] != "config/keys" {
+		t.Errorf("Expected [config/keys], got %v", dirs)
+	}
+}
+
+func TestLockFiles_Recursive(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	files := map[string]string{
+		"secrets/api.key":        "key",
+		"secrets/tls/server.pem": "pem",
+		"secrets/scratch.tmp":    "tmp",
+		"secrets/cache/data":     "cache",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ignore.FileName), []byte("*.tmp\ncache/\n"), 0644); err != nil {
+		t.Fatalf("Failed to create ignore file: %v", err)
+	}
+
+	// Without --recursive, directories are skipped
+	report, err := lockenv.LockFiles(context.Background(), []string{"secrets"}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if len(report.Locked) != 0 {
+		t.Errorf("Expected directory to be skipped, got %v", report.Locked)
+	}
+	if len(report.Skipped) != 1 || !errors.Is(report.Skipped[0], ErrIsDirectory) {
+		t.Errorf("Expected directory to be reported as skipped, got %v", report.Skipped)
+	}
+
+	report, err = lockenv.LockFiles(context.Background(), []string{"secrets/"}, password, true)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	locked := report.Locked
+	sort.Strings(locked)
+	if len(locked) != 2 || locked[0] != "secrets/api.key" || locked[1] != "secrets/tls/server.pem" {
+		t.Fatalf("Expected api.key and tls/server.pem, got %v", locked)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, locked); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	status, err := lockenv.Status(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status.Directories) != 1 || status.Directories[0] != "secrets" {
+		t.Errorf("Expected secrets to be tracked, got %v", status.Directories)
+	}
+
+	// New and deleted files are picked up by a scan
+	if err := os.WriteFile(filepath.Join(dir, "secrets", "new.key"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "secrets", "api.key")); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+
+	scan, err := lockenv.ScanTrackedDirs(context.Background())
+	if err != nil {
+		t.Fatalf("ScanTrackedDirs failed: %v", err)
+	}
+	if len(scan.New) != 1 || scan.New[0] != "secrets/new.key" {
+		t.Errorf("Expected secrets/new.key to be new, got %v", scan.New)
+	}
+	if len(scan.Deleted) != 1 || scan.Deleted[0] != "secrets/api.key" {
+		t.Errorf("Expected secrets/api.key to be deleted, got %v", scan.Deleted)
+	}
+
+	// Removing the directory untracks it and its files
+	if _, err := lockenv.RemoveFiles(context.Background(), []string{"secrets/"}, password); err != nil {
+		t.Fatalf("RemoveFiles failed: %v", err)
+	}
+	list, err := lockenv.List(context.Background())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("Expected no tracked files, got %v", list)
+	}
+	scan, err = lockenv.ScanTrackedDirs(context.Background())
+	if err != nil {
+		t.Fatalf("ScanTrackedDirs failed: %v", err)
+	}
+	if len(scan.New) != 0 {
+		t.Errorf("Expected no tracked directories, got new files %v", scan.New)
+	}
+}
+
+func TestScanTrackedDirs_SkipsMissingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "secrets", "api.key")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("key"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	report, err := lockenv.LockFiles(context.Background(), []string{"secrets"}, password, true)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	locked := report.Locked
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, locked); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "secrets")); err != nil {
+		t.Fatalf("Failed to remove dir: %v", err)
+	}
+
+	scan, err := lockenv.ScanTrackedDirs(context.Background())
+	if err != nil {
+		t.Fatalf("ScanTrackedDirs failed: %v", err)
+	}
+	if len(scan.Deleted) != 0 {
+		t.Errorf("Locked-and-removed files should not be flagged, got %v", scan.Deleted)
+	}
+}
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/detect"
+	"github.com/illarion/lockenv/internal/glob"
+	"github.com/illarion/lockenv/internal/ignore"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// SecretFile is an untracked file that looks like it holds secrets
+type SecretFile struct {
+	Path    string // Slash-separated, relative to the project root
+	Reason  string // Why it was reported, e.g. "name matches .env"
+	Ignored string // Pattern marking the file as not secret, if any
+}
+
+// SetScanIgnore sets paths and globs that DiscoverSecretFiles treats as not
+// secret in addition to the vault's list (the scan.ignore config setting)
+func (l *LockEnv) SetScanIgnore(patterns []string) {
+	l.nonSecret = patterns
+}
+
+// normalizeNonSecret cleans a path or glob marked as not secret; it must be
+// relative to the project root and stay inside it
+func normalizeNonSecret(pattern string) (string, error) {
+	cleaned := path.Clean(filepath.ToSlash(strings.TrimSpace(pattern)))
+	if pattern == "" || path.IsAbs(cleaned) || filepath.IsAbs(pattern) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid pattern %q: use a path or glob relative to the project root", pattern)
+	}
+	return cleaned, nil
+}
+
+// matchNonSecret returns the first of patterns matching rel, as a path, a
+// directory holding it or a glob, or ""
+func matchNonSecret(patterns []string, rel string) string {
+	for _, pattern := range patterns {
+		if rel == pattern || strings.HasPrefix(rel, pattern+"/") || glob.Match(pattern, rel) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// getNonSecretPatterns returns the paths and globs marked as not secret
+func getNonSecretPatterns(db storage.ConfigStore) ([]string, error) {
+	var patterns []string
+	_, err := getRecord(db, recordNonSecret, &patterns)
+	return patterns, err
+}
+
+// NonSecretPatterns returns the paths and globs marked as not secret with
+// MarkNonSecret (no password required)
+func (l *LockEnv) NonSecretPatterns() ([]string, error) {
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	return getNonSecretPatterns(db)
+}
+
+// MarkNonSecret records paths and globs as known not to be secret, so
+// DiscoverSecretFiles stops reporting them (implements `lockenv scan
+// --ignore`). The list is kept unencrypted in the vault and shared with it.
+// Returns the patterns that were not listed yet.
+func (l *LockEnv) MarkNonSecret(password []byte, patterns []string) ([]string, error) {
+	return l.updateNonSecret(password, patterns, true)
+}
+
+// UnmarkNonSecret removes paths and globs from the list kept by
+// MarkNonSecret (implements `lockenv scan --unignore`). Returns the patterns
+// that were listed.
+func (l *LockEnv) UnmarkNonSecret(password []byte, patterns []string) ([]string, error) {
+	return l.updateNonSecret(password, patterns, false)
+}
+
+// updateNonSecret adds patterns to or removes them from the vault's list of
+// non-secret paths, returning the patterns that changed it
+func (l *LockEnv) updateNonSecret(password []byte, patterns []string, add bool) ([]string, error) {
+	normalized := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		cleaned, err := normalizeNonSecret(pattern)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, cleaned)
+	}
+	if err := l.VerifyPassword(password); err != nil {
+		return nil, err
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	listed, err := getNonSecretPatterns(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read non-secret paths: %w", err)
+	}
+	var changed []string
+	for _, pattern := range normalized {
+		i := slices.Index(listed, pattern)
+		switch {
+		case add && i < 0:
+			listed = append(listed, pattern)
+		case !add && i >= 0:
+			listed = slices.Delete(listed, i, i+1)
+		default:
+			continue
+		}
+		changed = append(changed, pattern)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if len(listed) == 0 {
+		err = db.DeleteConfig(recordNonSecret)
+	} else {
+		err = putRecord(db, recordNonSecret, listed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return changed, db.UpdateModified()
+}
+
+// readHead returns at most detect.MaxScanSize bytes of the file at path
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, detect.MaxScanSize))
+}
+
+// DiscoverSecretFiles lists files in the project that look like they hold
+// secrets and that the vault does not hold yet, sorted by path (implements
+// `lockenv scan` and `lockenv init --import-existing`). Files are judged by
+// name (.env, *.pem, id_rsa, ...) and, for configuration files, by contents
+// (known token formats, high-entropy values); see package detect. Paths
+// matched by .lockenvignore are left out. Files marked as not secret, with
+// MarkNonSecret or SetScanIgnore, are only listed if includeIgnored is set,
+// with Ignored naming the pattern. No password is required.
+func (l *LockEnv) DiscoverSecretFiles(ctx context.Context, includeIgnored bool) ([]SecretFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]bool)
+	nonSecret := slices.Clone(l.nonSecret)
+	if l.exists() {
+		db, err := l.open()
+		if err != nil {
+			return nil, openError(err)
+		}
+		entries, err := l.getManifestEntries(db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		listed, err := getNonSecretPatterns(db)
+		db.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read non-secret paths: %w", err)
+		}
+		for _, entry := range entries {
+			tracked[entry.Path] = true
+		}
+		nonSecret = append(nonSecret, listed...)
+	}
+
+	repoRoot := filepath.Dir(l.path)
+	matcher, err := ignore.Load(repoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignore.FileName, err)
+	}
+
+	var found []SecretFile
+	err = filepath.WalkDir(repoRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(repoRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if p == repoRoot {
+				return nil
+			}
+			if slices.Contains(detect.SkipDirs, d.Name()) || rel == DefaultBackupDir || rel == storage.ObjectsDir || matcher.Match(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Symlinks and special files are not followed
+		if !d.Type().IsRegular() || rel == LockEnvFile || tracked[rel] || matcher.Match(rel, false) {
+			return nil
+		}
+		ignored := matchNonSecret(nonSecret, rel)
+		if ignored != "" && !includeIgnored {
+			return nil
+		}
+		reason, ok, err := detect.File(rel, func() ([]byte, error) { return readHead(p) })
+		if err != nil {
+			return err
+		}
+		if ok {
+			found = append(found, SecretFile{Path: rel, Reason: reason, Ignored: ignored})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", repoRoot, err)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	return found, nil
+}
@@ -3,9 +3,17 @@
 // Core operations include:
 //   - Init: Create new vault with password-derived encryption key
 //   - LockFiles/FinalizeLock: Track and encrypt files into the vault
+//     (skipped and failed files are returned in a LockReport, not printed)
 //   - Unlock: Decrypt and restore files with conflict resolution
 //   - RemoveFiles: Remove files from vault tracking
 //   - ChangePassword: Re-encrypt vault with new password
+//   - OpenVaultFile/StoreVaultFile: Read or write a single vault file
+//     through io.Reader, without touching the working tree
+//
+// Operations share one open handle of the vault and pass it down instead of
+// keeping it in the LockEnv, so once configured, a LockEnv can run
+// operations from several goroutines. The vault is closed when the last
+// operation returns, unless Hold keeps it open until the matching Release.
 //
 // Conflict resolution during unlock supports multiple strategies:
 //   - Keep local version
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+var (
+	ErrDomainNotFound      = errors.New("domain not found")
+	ErrDomainExists        = errors.New("domain already exists")
+	ErrDomainLocked        = errors.New("domain is locked")
+	ErrDomainInUse         = errors.New("domain still has files")
+	ErrWrongDomainPassword = errors.New("wrong domain password")
+)
+
+// validDomainName keeps domain names usable in flags and environment variables
+var validDomainName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// AddDomain creates an encryption domain with its own password. Files locked
+// into the domain cannot be decrypted with the vault password alone.
+func (l *LockEnv) AddDomain(password []byte, name string, domainPassword []byte) error {
+	if !validDomainName.MatchString(name) {
+		return fmt.Errorf("invalid domain name %q (use lowercase letters, digits, - and _)", name)
+	}
+	if err := l.VerifyPassword(password); err != nil {
+		return err
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	existing, err := db.GetDomain(name)
+	if err != nil {
+		return fmt.Errorf("failed to read domain: %w", err)
+	}
+	if existing != nil {
+		return ErrDomainExists
+	}
+
+	kdf, err := l.newKDF()
+	if err != nil {
+		return fmt.Errorf("failed to create KDF: %w", err)
+	}
+	enc := crypto.NewEncryptor(kdf.DeriveKey(domainPassword))
+	defer enc.Destroy()
+
+	check, err := enc.Encrypt(passwordChecksum())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt checksum: %w", err)
+	}
+
+	if err := db.StoreDomain(storage.Domain{
+		Name:       name,
+		Salt:       kdf.Salt,
+		Iterations: uint32(kdf.Iterations),
+		Check:      check,
+	}); err != nil {
+		return err
+	}
+	return db.UpdateModified()
+}
+
+// ListDomains returns the vault's encryption domains (no password required)
+func (l *LockEnv) ListDomains() ([]storage.Domain, error) {
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	return db.GetDomains()
+}
+
+// RemoveDomain deletes an encryption domain that no longer has files
+func (l *LockEnv) RemoveDomain(password []byte, name string) error {
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(context.Background(), db, password)
+	if err != nil {
+		return err
+	}
+	defer enc.Destroy()
+
+	domain, err := db.GetDomain(name)
+	if err != nil {
+		return fmt.Errorf("failed to read domain: %w", err)
+	}
+	if domain == nil {
+		return ErrDomainNotFound
+	}
+	for _, file := range metadata.Files {
+		if file.Domain == name {
+			return fmt.Errorf("%w: %s", ErrDomainInUse, file.Path)
+		}
+	}
+
+	if err := db.RemoveDomain(name); err != nil {
+		return err
+	}
+	return db.UpdateModified()
+}
+
+// UseDomain unlocks an encryption domain for this LockEnv, so its files are
+// encrypted and decrypted by later operations. Files of domains that were not
+// unlocked are skipped.
+func (l *LockEnv) UseDomain(name string, domainPassword []byte) error {
+	if !l.exists() {
+		return ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	domain, err := db.GetDomain(name)
+	if err != nil {
+		return fmt.Errorf("failed to read domain: %w", err)
+	}
+	if domain == nil {
+		return fmt.Errorf("%w: %s", ErrDomainNotFound, name)
+	}
+
+	kdf := &crypto.KDF{Salt: domain.Salt, Iterations: int(domain.Iterations)}
+	enc := crypto.NewEncryptor(kdf.DeriveKey(domainPassword))
+
+	if !crypto.ConstantTimeCompare(decryptChecksum(enc, domain.Check), passwordChecksum()) {
+		enc.Destroy()
+		return ErrWrongDomainPassword
+	}
+
+	if l.domains == nil {
+		l.domains = make(map[string]*crypto.Encryptor)
+	}
+	if old := l.domains[name]; old != nil {
+		old.Destroy()
+	}
+	l.domains[name] = enc
+	return nil
+}
+
+// AssignDomain moves tracked files into an unlocked encryption domain. The
+// files are re-encrypted with the domain key by the next FinalizeLock.
+func (l *LockEnv) AssignDomain(password []byte, paths []string, name string) error {
+	if l.domains[name] == nil {
+		return fmt.Errorf("%w: %s", ErrDomainLocked, name)
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(context.Background(), db, password)
+	if err != nil {
+		return err
+	}
+	defer enc.Destroy()
+
+	for _, path := range paths {
+		file := metadata.FindFile(path)
+		if file == nil {
+			return fmt.Errorf("%s is not in the vault", path)
+		}
+		file.Domain = name
+	}
+
+	return l.saveMetadata(db, metadata, enc)
+}
+
+// fileEncryptor returns the encryptor for a file's blob: enc's blob subkey
+// for files under the vault password, or the domain's encryptor. Returns ErrDomainLocked if
+// the file's domain was not unlocked with UseDomain.
+func (l *LockEnv) fileEncryptor(file *storage.FileEntry, enc *crypto.Encryptor) (*crypto.Encryptor, error) {
+	if file.Domain == "" {
+		return enc.For(crypto.PurposeBlobs), nil
+	}
+	if domainEnc := l.domains[file.Domain]; domainEnc != nil {
+		return domainEnc, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrDomainLocked, file.Domain)
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newDomainTestVault creates a vault holding dev.env and prod.env, with
+// prod.env locked into the "prod" domain
+func newDomainTestVault(t *testing.T, password, prodPassword []byte) *LockEnv {
+	t.Helper()
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, password, map[string]string{"dev.env": "DEV=1"})
+	dir := filepath.Dir(lockenv.path)
+
+	if err := lockenv.AddDomain(password, "prod", prodPassword); err != nil {
+		t.Fatalf("AddDomain failed: %v", err)
+	}
+	if err := lockenv.UseDomain("prod", prodPassword); err != nil {
+		t.Fatalf("UseDomain failed: %v", err)
+	}
+
+	prodPath := filepath.Join(dir, "prod.env")
+	if err := os.WriteFile(prodPath, []byte("PROD=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	report, err := lockenv.LockFiles(ctx, []string{prodPath}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	locked := report.Locked
+	if err := lockenv.AssignDomain(password, locked, "prod"); err != nil {
+		t.Fatalf("AssignDomain failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, locked); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	return lockenv
+}
+
+// reopen returns a fresh LockEnv for the vault, with no domains unlocked
+func reopen(t *testing.T, lockenv *LockEnv) *LockEnv {
+	t.Helper()
+	reopened, err := New(filepath.Dir(lockenv.path))
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	t.Cleanup(func() { reopened.Close() })
+	return reopened
+}
+
+func TestDomain_UnlockRequiresDomainPassword(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("vault123")
+	prodPassword := []byte("prod123")
+	lockenv := newDomainTestVault(t, password, prodPassword)
+	dir := filepath.Dir(lockenv.path)
+
+	everyday := reopen(t, lockenv)
+	result, err := everyday.Unlock(ctx, password, StrategyUseVault, nil)
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "prod.env" {
+		t.Errorf("Expected prod.env to be skipped, got %v", result.Skipped)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prod.env")); !os.IsNotExist(err) {
+		t.Error("prod.env should not be unlocked without the domain password")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "dev.env")); err != nil || string(data) != "DEV=1" {
+		t.Errorf("dev.env not unlocked: %q, %v", data, err)
+	}
+
+	if err := everyday.UseDomain("prod", []byte("wrong")); err != ErrWrongDomainPassword {
+		t.Fatalf("Expected ErrWrongDomainPassword, got %v", err)
+	}
+	if err := everyday.UseDomain("prod", prodPassword); err != nil {
+		t.Fatalf("UseDomain failed: %v", err)
+	}
+	if _, err := everyday.Unlock(ctx, password, StrategyUseVault, []string{"prod.env"}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "prod.env")); err != nil || string(data) != "PROD=1" {
+		t.Errorf("prod.env not unlocked: %q, %v", data, err)
+	}
+}
+
+func TestDomain_VaultPasswordCannotDecrypt(t *testing.T) {
+	password := []byte("vault123")
+	lockenv := newDomainTestVault(t, password, []byte("prod123"))
+
+	contents := vaultContents(t, reopen(t, lockenv), password)
+	if _, ok := contents["prod.env"]; ok {
+		t.Error("prod.env decrypted without the domain password")
+	}
+	if contents["dev.env"] != "DEV=1" {
+		t.Errorf("Expected dev.env to decrypt, got %q", contents["dev.env"])
+	}
+}
+
+func TestDomain_ChangePasswordKeepsDomainFiles(t *testing.T) {
+	password := []byte("vault123")
+	newPassword := []byte("vault456")
+	prodPassword := []byte("prod123")
+	lockenv := newDomainTestVault(t, password, prodPassword)
+
+	if err := reopen(t, lockenv).ChangePassword(password, newPassword); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	reopened := reopen(t, lockenv)
+	if err := reopened.UseDomain("prod", prodPassword); err != nil {
+		t.Fatalf("UseDomain failed: %v", err)
+	}
+	contents := vaultContents(t, reopened, newPassword)
+	if contents["prod.env"] != "PROD=1" || contents["dev.env"] != "DEV=1" {
+		t.Errorf("Unexpected vault contents after password change: %v", contents)
+	}
+}
+
+func TestDomain_AddAndRemove(t *testing.T) {
+	password := []byte("vault123")
+	lockenv := newDomainTestVault(t, password, []byte("prod123"))
+
+	if err := lockenv.AddDomain(password, "prod", []byte("other")); err != ErrDomainExists {
+		t.Errorf("Expected ErrDomainExists, got %v", err)
+	}
+	if err := lockenv.AddDomain(password, "Bad Name", []byte("other")); err == nil {
+		t.Error("Expected error for invalid domain name")
+	}
+	if err := lockenv.AddDomain(password, "staging", []byte("staging123")); err != nil {
+		t.Fatalf("AddDomain failed: %v", err)
+	}
+
+	domains, err := lockenv.ListDomains()
+	if err != nil {
+		t.Fatalf("ListDomains failed: %v", err)
+	}
+	if len(domains) != 2 {
+		t.Errorf("Expected 2 domains, got %d", len(domains))
+	}
+
+	if err := lockenv.RemoveDomain(password, "prod"); !errors.Is(err, ErrDomainInUse) {
+		t.Errorf("Expected ErrDomainInUse, got %v", err)
+	}
+	if err := lockenv.RemoveDomain(password, "staging"); err != nil {
+		t.Fatalf("RemoveDomain failed: %v", err)
+	}
+	if err := lockenv.RemoveDomain(password, "staging"); err != ErrDomainNotFound {
+		t.Errorf("Expected ErrDomainNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// dotenvKeyPattern matches the start of KEY=VALUE and export KEY=VALUE,
+// capturing the key
+var dotenvKeyPattern = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_.\-]*)\s*=[ \t]*`)
+
+// DotenvKey is a variable defined in a dotenv file
+type DotenvKey struct {
+	Name string
+	Line int // 1-based line number
+}
+
+// DotenvEntry is a variable and its value parsed from a dotenv file
+type DotenvEntry struct {
+	Name  string
+	Value string
+	Line  int // 1-based line number where the assignment starts
+}
+
+// FormatDotenv is the format recorded for dotenv files, next to the
+// structured formats
+const FormatDotenv = "dotenv"
+
+// DotenvIssue is a syntax problem found by ValidateDotenv
+type DotenvIssue struct {
+	Line    int // 1-based line number
+	Message string
+}
+
+func (i DotenvIssue) String() string {
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// DetectFormat returns the format of the file at path judging by its name:
+// FormatDotenv, one of the structured formats, or "" for anything else
+func DetectFormat(path string) string {
+	if IsDotenvFile(path) {
+		return FormatDotenv
+	}
+	return DetectStructuredFormat(path)
+}
+
+// IsDotenvFile reports whether path looks like a dotenv file: .env,
+// .env.<suffix> or <name>.env
+func IsDotenvFile(path string) bool {
+	base := filepath.Base(filepath.FromSlash(path))
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// DotenvKeys returns the variables defined in dotenv data, in file order.
+// Entries before a syntax error are still returned.
+func DotenvKeys(data []byte) []DotenvKey {
+	entries, _ := ParseDotenv(data)
+	keys := make([]DotenvKey, len(entries))
+	for i, e := range entries {
+		keys[i] = DotenvKey{Name: e.Name, Line: e.Line}
+	}
+	return keys
+}
+
+// ParseDotenv parses dotenv data into its assignments, in file order.
+// Values may be unquoted (trailing " #" comments are stripped), single-quoted
+// (taken literally) or double-quoted (supporting \n, \r, \t, \", \\ and \$
+// escapes); quoted values may span several lines. Comments, blank lines and
+// lines that are not assignments are ignored. Returns the entries parsed so
+// far and an error on an unterminated quote.
+func ParseDotenv(data []byte) ([]DotenvEntry, error) {
+	return parseDotenv(data, nil)
+}
+
+// ValidateDotenv checks the syntax of dotenv data: unterminated quotes,
+// keys assigned more than once and lines that are not a valid KEY=VALUE
+// assignment, such as a variable name with spaces or a leading digit.
+// Returns the issues in line order, nil if there are none.
+func ValidateDotenv(data []byte) []DotenvIssue {
+	var issues []DotenvIssue
+	entries, err := parseDotenv(data, func(line int, text string) {
+		msg := "not a KEY=VALUE assignment"
+		if name, _, ok := strings.Cut(text, "="); ok {
+			msg = fmt.Sprintf("invalid variable name %q", strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "export ")))
+		}
+		issues = append(issues, DotenvIssue{Line: line, Message: msg})
+	})
+
+	first := make(map[string]int, len(entries))
+	for _, e := range entries {
+		if line, ok := first[e.Name]; ok {
+			issues = append(issues, DotenvIssue{Line: e.Line, Message: fmt.Sprintf("duplicate key %s (first set on line %d)", e.Name, line)})
+			continue
+		}
+		first[e.Name] = e.Line
+	}
+
+	var syntaxErr *dotenvSyntaxError
+	if errors.As(err, &syntaxErr) {
+		issues = append(issues, DotenvIssue{Line: syntaxErr.line, Message: fmt.Sprintf("%s: %s", syntaxErr.name, syntaxErr.err)})
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
+
+// dotenvSyntaxError is an unterminated quote found by parseDotenv
+type dotenvSyntaxError struct {
+	line int
+	name string
+	err  error
+}
+
+func (e *dotenvSyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.line, e.name, e.err)
+}
+
+func (e *dotenvSyntaxError) Unwrap() error {
+	return e.err
+}
+
+// parseDotenv implements ParseDotenv, calling invalid (if set) with every
+// line that is neither blank, a comment nor an assignment
+func parseDotenv(data []byte, invalid func(line int, text string)) ([]DotenvEntry, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, utf8BOM)), "\r\n", "\n")
+	var entries []DotenvEntry
+	line := 1
+	for len(text) > 0 {
+		end := strings.IndexByte(text, '\n')
+		if end < 0 {
+			end = len(text)
+		}
+		current := text[:end]
+
+		m := dotenvKeyPattern.FindStringSubmatchIndex(current)
+		if m == nil {
+			if trimmed := strings.TrimSpace(current); invalid != nil && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				invalid(line, trimmed)
+			}
+			text = text[min(end+1, len(text)):]
+			line++
+			continue
+		}
+
+		name := current[m[2]:m[3]]
+		rest := text[m[1]:]
+		var value string
+		var consumed int
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			v, n, err := parseDoubleQuoted(rest)
+			if err != nil {
+				return entries, &dotenvSyntaxError{line: line, name: name, err: err}
+			}
+			value, consumed = v, n
+		case strings.HasPrefix(rest, "'"):
+			closing := strings.IndexByte(rest[1:], '\'')
+			if closing < 0 {
+				return entries, &dotenvSyntaxError{line: line, name: name, err: errors.New("unterminated single quote")}
+			}
+			value, consumed = rest[1:closing+1], closing+2
+		default:
+			raw := current[m[1]:]
+			if i := strings.Index(raw, " #"); i >= 0 {
+				raw = raw[:i]
+			} else if i := strings.Index(raw, "\t#"); i >= 0 {
+				raw = raw[:i]
+			}
+			value, consumed = strings.TrimSpace(raw), end-m[1]
+		}
+
+		entries = append(entries, DotenvEntry{Name: name, Value: value, Line: line})
+
+		// Skip past the value, which may span lines, and the rest of the
+		// line it ends on
+		line += strings.Count(rest[:consumed], "\n")
+		rest = rest[consumed:]
+		if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+			text = rest[nl+1:]
+			line++
+		} else {
+			text = ""
+		}
+	}
+	return entries, nil
+}
+
+// parseDoubleQuoted parses a double-quoted dotenv value at the start of s,
+// returning the unescaped value and the number of bytes consumed
+func parseDoubleQuoted(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated double quote")
+}
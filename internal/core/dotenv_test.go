@@ -0,0 +1,100 @@
+package core
+
+import "testing"
+
+func TestIsDotenvFile(t *testing.T) {
+	tests := map[string]bool{
+		".env":            true,
+		".env.local":      true,
+		"config/prod.env": true,
+		"config/.env":     true,
+		"config.json":     false,
+		"environment.txt": false,
+		"secrets/.envrc":  false,
+	}
+	for path, want := range tests {
+		if got := IsDotenvFile(path); got != want {
+			t.Errorf("IsDotenvFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestDotenvKeys(t *testing.T) {
+	data := []byte("# comment\nDB_HOST=localhost\n\nexport API_KEY = abc\n  PORT=5432\nnot an assignment\n1BAD=x\n")
+	keys := DotenvKeys(data)
+	want := []DotenvKey{
+		{Name: "DB_HOST", Line: 2},
+		{Name: "API_KEY", Line: 4},
+		{Name: "PORT", Line: 5},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("DotenvKeys() = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("key %d = %v, want %v", i, keys[i], want[i])
+		}
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	data := []byte("PLAIN=value # comment\r\n" +
+		"EMPTY=\n" +
+		"SINGLE='it''s $HOME \\n'\n" +
+		"DOUBLE=\"line1\\nline2 \\\"q\\\" \\$HOME\" # trailing\n" +
+		"MULTI=\"-----BEGIN KEY-----\n" +
+		"abc\n" +
+		"-----END KEY-----\"\n" +
+		"AFTER=1\n")
+	entries, err := ParseDotenv(data)
+	if err != nil {
+		t.Fatalf("ParseDotenv failed: %v", err)
+	}
+	want := []DotenvEntry{
+		{Name: "PLAIN", Value: "value", Line: 1},
+		{Name: "EMPTY", Value: "", Line: 2},
+		{Name: "SINGLE", Value: "it", Line: 3},
+		{Name: "DOUBLE", Value: "line1\nline2 \"q\" $HOME", Line: 4},
+		{Name: "MULTI", Value: "-----BEGIN KEY-----\nabc\n-----END KEY-----", Line: 5},
+		{Name: "AFTER", Value: "1", Line: 8},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("ParseDotenv() = %q, want %q", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, entries[i], want[i])
+		}
+	}
+
+	if _, err := ParseDotenv([]byte("A=1\nB=\"open\n")); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
+
+func TestValidateDotenv(t *testing.T) {
+	if issues := ValidateDotenv([]byte("# comment\nexport A=1\nB=\"x y\"\n\nC=\n")); len(issues) != 0 {
+		t.Errorf("Expected no issues in a valid file, got %v", issues)
+	}
+
+	data := []byte("DB_HOST=a\n" +
+		"not an assignment\n" +
+		"2FA_SECRET=x\n" +
+		"DB_HOST=b\n" +
+		"KEY=\"open\n")
+	want := []string{
+		`line 2: not a KEY=VALUE assignment`,
+		`line 3: invalid variable name "2FA_SECRET"`,
+		`line 4: duplicate key DB_HOST (first set on line 1)`,
+		`line 5: KEY: unterminated double quote`,
+	}
+	issues := ValidateDotenv(data)
+	if len(issues) != len(want) {
+		t.Fatalf("ValidateDotenv() = %v, want %v", issues, want)
+	}
+	for i := range want {
+		if issues[i].String() != want[i] {
+			t.Errorf("issue %d = %q, want %q", i, issues[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// utf8BOM is the byte order mark some Windows editors put at the start of
+// UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// textEncoding is the byte-level layout of a text file that the line-based
+// merge and diff code works around: it sees LF-only text without a BOM
+type textEncoding struct {
+	bom  bool // Starts with a UTF-8 byte order mark
+	crlf bool // Every line ends in \r\n
+}
+
+// normalizeText strips a UTF-8 BOM and turns CRLF line endings into LF,
+// returning the encoding to restore with apply. Files mixing CRLF and LF
+// keep their endings, so they survive a round trip unchanged.
+func normalizeText(data []byte) ([]byte, textEncoding) {
+	var enc textEncoding
+	if bytes.HasPrefix(data, utf8BOM) {
+		enc.bom = true
+		data = data[len(utf8BOM):]
+	}
+	if crlf := bytes.Count(data, []byte("\r\n")); crlf > 0 && crlf == bytes.Count(data, []byte("\n")) {
+		enc.crlf = true
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	return data, enc
+}
+
+// apply converts text back to this encoding. Lines that already end in
+// \r\n and an existing BOM are left alone, so text an editor saved in the
+// encoding comes back unchanged.
+func (e textEncoding) apply(data []byte) []byte {
+	if e.crlf {
+		data = bytes.ReplaceAll(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+	}
+	if e.bom && !bytes.HasPrefix(data, utf8BOM) {
+		data = append(append([]byte(nil), utf8BOM...), data...)
+	}
+	return data
+}
+
+// lineEndings names the line endings of the encoding for diff output
+func (e textEncoding) lineEndings() string {
+	if e.crlf {
+		return "CRLF"
+	}
+	return "LF"
+}
+
+// encodingChanges describes how the encoding changed from old to new as
+// "\ "-prefixed diff notes, or "" if it did not
+func encodingChanges(old, new textEncoding) string {
+	var notes string
+	if old.crlf != new.crlf {
+		notes += fmt.Sprintf("\\ Line endings changed from %s to %s\n", old.lineEndings(), new.lineEndings())
+	}
+	if old.bom && !new.bom {
+		notes += "\\ UTF-8 byte order mark removed\n"
+	}
+	if !old.bom && new.bom {
+		notes += "\\ UTF-8 byte order mark added\n"
+	}
+	return notes
+}
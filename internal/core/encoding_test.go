@@ -0,0 +1,83 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeText_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+		enc  textEncoding
+	}{
+		{"lf", "A=1\nB=2\n", "A=1\nB=2\n", textEncoding{}},
+		{"crlf", "A=1\r\nB=2\r\n", "A=1\nB=2\n", textEncoding{crlf: true}},
+		{"bom crlf", "\xEF\xBB\xBFA=1\r\nB=2", "A=1\nB=2", textEncoding{bom: true, crlf: true}},
+		{"mixed", "A=1\r\nB=2\n", "A=1\r\nB=2\n", textEncoding{}},
+	}
+	for _, tt := range tests {
+		got, enc := normalizeText([]byte(tt.data))
+		if string(got) != tt.want || enc != tt.enc {
+			t.Errorf("%s: normalizeText() = %q, %+v, want %q, %+v", tt.name, got, enc, tt.want, tt.enc)
+		}
+		if back := enc.apply(got); string(back) != tt.data {
+			t.Errorf("%s: apply() = %q, want %q", tt.name, back, tt.data)
+		}
+		// Text already in the encoding is not converted twice
+		if again := enc.apply([]byte(tt.data)); string(again) != tt.data {
+			t.Errorf("%s: apply() on encoded text = %q, want %q", tt.name, again, tt.data)
+		}
+	}
+}
+
+func TestCreateConflictFile_KeepsLocalEncoding(t *testing.T) {
+	local := []byte("\xEF\xBB\xBFA=1\r\nB=local\r\n")
+	vault := []byte("A=1\nB=vault\n")
+
+	name, err := createConflictFile(t.TempDir(), ".env", local, vault)
+	if err != nil {
+		t.Fatalf("createConflictFile failed: %v", err)
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\xEF\xBB\xBFA=1\r\n<<<<<<< local\r\nB=local\r\n=======\r\nB=vault\r\n>>>>>>> vault\r\n"
+	if string(content) != want {
+		t.Errorf("Conflict file = %q, want %q", content, want)
+	}
+}
+
+func TestGenerateUnifiedDiff_LineEndings(t *testing.T) {
+	diff, err := GenerateUnifiedDiff(".env", []byte("A=1\nB=2\n"), []byte("A=1\r\nB=2\r\n"), DiffOptions{})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	want := "--- a/.env\n+++ b/.env\n\\ Line endings changed from LF to CRLF\n"
+	if diff != want {
+		t.Errorf("diff = %q, want %q", diff, want)
+	}
+
+	// Content changes are diffed line by line, ignoring the endings
+	diff, err = GenerateUnifiedDiff(".env", []byte("\xEF\xBB\xBFA=1\r\nB=2\r\n"), []byte("A=1\r\nB=3\r\n"), DiffOptions{ShowSecrets: true})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	if !strings.Contains(diff, "\\ UTF-8 byte order mark removed\n") || strings.Contains(diff, "-A=1") || strings.Contains(diff, "\r") {
+		t.Errorf("Unexpected diff: %q", diff)
+	}
+	if !strings.Contains(diff, "-B=2\n+B=3\n") {
+		t.Errorf("Expected the changed line, got %q", diff)
+	}
+}
+
+func TestParseDotenv_BOM(t *testing.T) {
+	entries, err := ParseDotenv([]byte("\xEF\xBB\xBFAPI_KEY=abc\r\n"))
+	if err != nil || len(entries) != 1 || entries[0].Name != "API_KEY" || entries[0].Value != "abc" {
+		t.Errorf("ParseDotenv() = %+v, %v", entries, err)
+	}
+}
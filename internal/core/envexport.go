@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Output formats for `lockenv env`
+const (
+	EnvFormatSh         = "sh"
+	EnvFormatFish       = "fish"
+	EnvFormatPowerShell = "powershell"
+	EnvFormatDotenv     = "dotenv"
+	EnvFormatJSON       = "json"
+)
+
+// shellNamePattern matches variable names that sh and fish accept
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Env decrypts dotenv files from the vault and returns their variables,
+// later files overriding earlier ones (implements `lockenv env`). If files is
+// empty, the root .env is used, followed by .env.<profile> and <profile>.env
+// when a profile is given. Variables keep the position of their first
+// definition.
+func (l *LockEnv) Env(ctx context.Context, password []byte, profile string, files []string) ([]DotenvEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	var selected []*storage.FileEntry
+	if len(files) > 0 {
+		for _, path := range files {
+			file := metadata.FindFile(strings.TrimPrefix(path, "./"))
+			if file == nil {
+				return nil, fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+			}
+			selected = append(selected, file)
+		}
+	} else {
+		if file := metadata.FindFile(".env"); file != nil {
+			selected = append(selected, file)
+		}
+		if profile != "" {
+			found := false
+			for _, path := range []string{".env." + profile, profile + ".env"} {
+				if file := metadata.FindFile(path); file != nil {
+					selected = append(selected, file)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no .env.%s or %s.env in vault", profile, profile)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf(".env: %w", ErrFileNotInVault)
+		}
+	}
+
+	var entries []DotenvEntry
+	index := make(map[string]int)
+	for _, file := range selected {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		plain, err := l.decryptEntry(ctx, db, file, enc)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := ParseDotenv(plain.Borrow())
+		plain.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Path, err)
+		}
+		for _, e := range parsed {
+			if i, ok := index[e.Name]; ok {
+				entries[i].Value = e.Value
+				continue
+			}
+			index[e.Name] = len(entries)
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// FormatEnv renders variables for the given format (sh, fish, powershell,
+// dotenv or json), quoting values so they survive special characters and
+// newlines. Variables whose names the target shell cannot represent are left
+// out and returned as skipped.
+func FormatEnv(entries []DotenvEntry, format string) (output string, skipped []string, err error) {
+	var b strings.Builder
+	switch format {
+	case EnvFormatSh, "":
+		for _, e := range entries {
+			if !shellNamePattern.MatchString(e.Name) {
+				skipped = append(skipped, e.Name)
+				continue
+			}
+			fmt.Fprintf(&b, "export %s=%s\n", e.Name, quoteSh(e.Value))
+		}
+	case EnvFormatFish:
+		for _, e := range entries {
+			if !shellNamePattern.MatchString(e.Name) {
+				skipped = append(skipped, e.Name)
+				continue
+			}
+			fmt.Fprintf(&b, "set -gx %s %s\n", e.Name, quoteFish(e.Value))
+		}
+	case EnvFormatPowerShell:
+		for _, e := range entries {
+			name := "$env:" + e.Name
+			if !shellNamePattern.MatchString(e.Name) {
+				name = "${env:" + strings.ReplaceAll(e.Name, "}", "`}") + "}"
+			}
+			fmt.Fprintf(&b, "%s = %s\n", name, quotePowerShell(e.Value))
+		}
+	case EnvFormatDotenv:
+		for _, e := range entries {
+			fmt.Fprintf(&b, "%s=%s\n", e.Name, quoteDotenv(e.Value))
+		}
+	case EnvFormatJSON:
+		b.WriteString("{")
+		for i, e := range entries {
+			name, _ := json.Marshal(e.Name)
+			value, _ := json.Marshal(e.Value)
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "\n  %s: %s", name, value)
+		}
+		if len(entries) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n")
+	default:
+		return "", nil, fmt.Errorf("unknown format %q (use sh, fish, powershell, dotenv or json)", format)
+	}
+	return b.String(), skipped, nil
+}
+
+// quoteSh single-quotes a value for POSIX shells; nothing inside single
+// quotes is special except the quote itself
+func quoteSh(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteFish single-quotes a value for fish, where only \ and ' are escaped
+func quoteFish(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// quotePowerShell single-quotes a value for PowerShell, where quotes are
+// doubled. Typographic single quotes also end a string, so they are doubled
+// too.
+func quotePowerShell(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// quoteDotenv double-quotes a value in the syntax ParseDotenv reads back
+func quoteDotenv(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+var envTestEntries = []DotenvEntry{
+	{Name: "SIMPLE", Value: "value"},
+	{Name: "QUOTES", Value: `it's "quoted"`},
+	{Name: "SPECIAL", Value: "$HOME `cmd` \\ ; & | * !"},
+	{Name: "MULTI", Value: "line1\nline2\n"},
+	{Name: "app.name", Value: "dotted"},
+}
+
+func TestFormatEnv_Sh(t *testing.T) {
+	out, skipped, err := FormatEnv(envTestEntries, EnvFormatSh)
+	if err != nil {
+		t.Fatalf("FormatEnv failed: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0] != "app.name" {
+		t.Errorf("skipped = %v, want [app.name]", skipped)
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	for _, e := range envTestEntries[:4] {
+		got, err := exec.Command(sh, "-c", out+`printf %s "$`+e.Name+`"`).Output()
+		if err != nil {
+			t.Fatalf("sh failed: %v", err)
+		}
+		if string(got) != e.Value {
+			t.Errorf("%s = %q, want %q", e.Name, got, e.Value)
+		}
+	}
+}
+
+func TestFormatEnv_Quoting(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{EnvFormatFish, `set -gx QUOTES 'it\'s "quoted"'`},
+		{EnvFormatPowerShell, `$env:QUOTES = 'it''s "quoted"'`},
+		{EnvFormatDotenv, `QUOTES="it's \"quoted\""`},
+	}
+	for _, tt := range tests {
+		out, _, err := FormatEnv(envTestEntries, tt.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if !strings.Contains(out, tt.want+"\n") {
+			t.Errorf("%s output missing %q:\n%s", tt.format, tt.want, out)
+		}
+	}
+
+	out, _, _ := FormatEnv(envTestEntries, EnvFormatPowerShell)
+	if !strings.Contains(out, "${env:app.name} = 'dotted'") {
+		t.Errorf("powershell output should keep dotted names:\n%s", out)
+	}
+	if _, _, err := FormatEnv(envTestEntries, "cmd"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestFormatEnv_RoundTrip(t *testing.T) {
+	out, _, err := FormatEnv(envTestEntries, EnvFormatDotenv)
+	if err != nil {
+		t.Fatalf("FormatEnv failed: %v", err)
+	}
+	parsed, err := ParseDotenv([]byte(out))
+	if err != nil {
+		t.Fatalf("ParseDotenv failed: %v", err)
+	}
+	if len(parsed) != len(envTestEntries) {
+		t.Fatalf("got %d entries, want %d", len(parsed), len(envTestEntries))
+	}
+	for i, e := range envTestEntries {
+		if parsed[i].Name != e.Name || parsed[i].Value != e.Value {
+			t.Errorf("entry %d = %q=%q, want %q=%q", i, parsed[i].Name, parsed[i].Value, e.Name, e.Value)
+		}
+	}
+
+	out, _, err = FormatEnv(envTestEntries, EnvFormatJSON)
+	if err != nil {
+		t.Fatalf("FormatEnv failed: %v", err)
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(out), &values); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	for _, e := range envTestEntries {
+		if values[e.Name] != e.Value {
+			t.Errorf("%s = %q, want %q", e.Name, values[e.Name], e.Value)
+		}
+	}
+}
+
+func TestEnv_Profiles(t *testing.T) {
+	ctx := context.Background()
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{
+		".env":      "A=base\nB=base\n",
+		".env.prod": "B=prod\nC=prod\n",
+		"other.env": "D=other\n",
+	})
+
+	entries, err := lockenv.Env(ctx, pw, "", nil)
+	if err != nil {
+		t.Fatalf("Env failed: %v", err)
+	}
+	if got := envString(entries); got != "A=base B=base" {
+		t.Errorf("default = %q", got)
+	}
+
+	entries, err = lockenv.Env(ctx, pw, "prod", nil)
+	if err != nil {
+		t.Fatalf("Env with profile failed: %v", err)
+	}
+	if got := envString(entries); got != "A=base B=prod C=prod" {
+		t.Errorf("profile prod = %q", got)
+	}
+
+	if _, err := lockenv.Env(ctx, pw, "staging", nil); err == nil {
+		t.Error("expected error for missing profile")
+	}
+
+	entries, err = lockenv.Env(ctx, pw, "", []string{"other.env", ".env"})
+	if err != nil {
+		t.Fatalf("Env with files failed: %v", err)
+	}
+	if got := envString(entries); got != "D=other A=base B=base" {
+		t.Errorf("files = %q", got)
+	}
+}
+
+func envString(entries []DotenvEntry) string {
+	parts := make([]string, len(entries))
+	for i, e := range entries {
+		parts[i] = e.Name + "=" + e.Value
+	}
+	return strings.Join(parts, " ")
+}
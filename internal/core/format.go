@@ -0,0 +1,362 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Private records added by format v2
+const (
+	dataKeyRecord  = "datakey"   // Data key wrapped by the vault key
+	indexMACRecord = "index_mac" // MAC over the plaintext manifest
+)
+
+// Password check records. Format v3 replaced the encrypted checksum with an
+// HMAC under the metadata subkey.
+const (
+	checksumRecord = "checksum" // Hash of passwordCheckString, encrypted
+	verifierRecord = "verifier" // HMAC of passwordCheckString
+)
+
+// ErrAlreadyMigrated is returned by Migrate for a vault in the current format
+var ErrAlreadyMigrated = errors.New("vault already uses the current format")
+
+// MigrateResult describes a completed migration
+type MigrateResult struct {
+	From   int    // Format version before the migration
+	To     int    // Format version after the migration
+	Files  int    // Number of re-encrypted files
+	Backup string // Path of the backup taken before migrating
+}
+
+// openError maps a failure to open the vault to ErrNotInitialized, except
+// for vaults that require a newer lockenv
+func openError(err error) error {
+	if errors.Is(err, storage.ErrVaultTooNew) {
+		return err
+	}
+	return ErrNotInitialized
+}
+
+// wrapDataKey stores the data key encrypted with the vault key
+func wrapDataKey(db storage.PrivateStore, vaultEnc *crypto.Encryptor, dataKey []byte) error {
+	wrapped, err := vaultEnc.Encrypt(dataKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	if err := db.StoreMetadataBytes(dataKeyRecord, wrapped); err != nil {
+		return fmt.Errorf("failed to store data key: %w", err)
+	}
+	return nil
+}
+
+// dataEncryptor returns the encryptor for a vault's data key. From format v3
+// on, metadata, blobs and the index MAC each use their own subkey of it.
+func dataEncryptor(format int, dataKey []byte) (*crypto.Encryptor, error) {
+	if format >= storage.FormatV3 {
+		return crypto.NewSubkeyEncryptor(dataKey)
+	}
+	return crypto.NewEncryptor(dataKey), nil
+}
+
+// unwrapDataKey decrypts the data key with the vault key. A key that fails
+// to authenticate means the password is wrong.
+func unwrapDataKey(db storage.PrivateStore, vaultEnc *crypto.Encryptor) ([]byte, error) {
+	wrapped, err := db.GetMetadataBytes(dataKeyRecord)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data key: %w", err)
+	}
+	dataKey, err := vaultEnc.Decrypt(wrapped)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return dataKey, nil
+}
+
+// passwordChecksum is the plaintext of the checksum record
+func passwordChecksum() []byte {
+	checksum := sha256.Sum256([]byte(passwordCheckString))
+	return []byte(hex.EncodeToString(checksum[:]))
+}
+
+// passwordVerifier returns the record that verifyPassword checks a key
+// against in a vault of the given format, and its value under enc
+func passwordVerifier(format int, enc *crypto.Encryptor) (string, []byte, error) {
+	enc = enc.For(crypto.PurposeMetadata)
+	if format >= storage.FormatV3 {
+		return verifierRecord, enc.MAC([]byte(passwordCheckString)), nil
+	}
+	checksum, err := enc.Encrypt(passwordChecksum())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encrypt checksum: %w", err)
+	}
+	return checksumRecord, checksum, nil
+}
+
+// storePasswordVerifier stores the password verifier for enc
+func storePasswordVerifier(db storage.PrivateStore, format int, enc *crypto.Encryptor) error {
+	record, value, err := passwordVerifier(format, enc)
+	if err != nil {
+		return err
+	}
+	if err := db.StoreMetadataBytes(record, value); err != nil {
+		return fmt.Errorf("failed to store password verifier: %w", err)
+	}
+	return nil
+}
+
+// verifyPassword checks that enc was derived from the vault password. A
+// missing record, a checksum that does not decrypt and a mismatch all end
+// in the same constant-time comparison and return ErrWrongPassword.
+func verifyPassword(db storage.PrivateStore, format int, enc *crypto.Encryptor) error {
+	var got, want []byte
+	if format >= storage.FormatV3 {
+		got, _ = db.GetMetadataBytes(verifierRecord)
+		want = enc.For(crypto.PurposeMetadata).MAC([]byte(passwordCheckString))
+	} else {
+		stored, _ := db.GetMetadataBytes(checksumRecord)
+		got = decryptChecksum(enc.For(crypto.PurposeMetadata), stored)
+		want = passwordChecksum()
+	}
+	if !crypto.ConstantTimeCompare(got, want) {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// decryptChecksum decrypts an encrypted checksum, returning nil if it is
+// missing or does not authenticate
+func decryptChecksum(enc *crypto.Encryptor, stored []byte) []byte {
+	checksum, err := enc.Decrypt(stored)
+	if err != nil {
+		return nil
+	}
+	return checksum
+}
+
+// indexMAC computes the MAC of the manifest, which is stored in plaintext
+// so status works without a password
+func indexMAC(db storage.ManifestStore, enc *crypto.Encryptor) ([]byte, error) {
+	entries, err := db.GetManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return enc.For(crypto.PurposeIndexMAC).MAC(data), nil
+}
+
+// storeIndexMAC authenticates the current manifest
+func storeIndexMAC(db storage.Backend, enc *crypto.Encryptor) error {
+	mac, err := indexMAC(db, enc)
+	if err != nil {
+		return err
+	}
+	if err := db.StoreMetadataBytes(indexMACRecord, mac); err != nil {
+		return fmt.Errorf("failed to store index MAC: %w", err)
+	}
+	return nil
+}
+
+// verifyIndex checks the manifest against its stored MAC
+func verifyIndex(db storage.Backend, enc *crypto.Encryptor) error {
+	stored, err := db.GetMetadataBytes(indexMACRecord)
+	if err != nil {
+		return fmt.Errorf("index MAC missing: %w", err)
+	}
+	mac, err := indexMAC(db, enc)
+	if err != nil {
+		return err
+	}
+	if !crypto.ConstantTimeCompare(stored, mac) {
+		return fmt.Errorf("vault index does not match its MAC; it was modified outside lockenv or by an interrupted operation")
+	}
+	return nil
+}
+
+// rewrapDataKey changes the password of a v2 vault by wrapping the data key
+// with a key derived from the new password, with at least minIters
+// iterations. File data is left untouched.
+func (l *LockEnv) rewrapDataKey(ctx context.Context, db storage.Backend, currentPassword, newPassword []byte, minIters int) error {
+	key, err := l.passwordKey(ctx, db, currentPassword)
+	if err != nil {
+		return err
+	}
+	currentEnc := crypto.NewEncryptor(key)
+	defer currentEnc.Destroy()
+
+	dataKey, err := unwrapDataKey(db, currentEnc)
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(dataKey)
+
+	newKDF, err := l.newKDF()
+	if err != nil {
+		return fmt.Errorf("failed to create new KDF: %w", err)
+	}
+	newKDF.Iterations = max(newKDF.Iterations, minIters)
+
+	newKey := crypto.AdoptSecure(newKDF.DeriveKey(newPassword))
+	defer newKey.Close()
+
+	newEnc := crypto.NewEncryptor(newKey.Borrow())
+	defer newEnc.Destroy()
+
+	if err := db.SetSalt(newKDF.Salt); err != nil {
+		return fmt.Errorf("failed to update salt: %w", err)
+	}
+	if err := db.SetIterations(uint32(newKDF.Iterations)); err != nil {
+		return fmt.Errorf("failed to update iterations: %w", err)
+	}
+	if err := wrapDataKey(db, newEnc, dataKey); err != nil {
+		return err
+	}
+
+	// Recipients must be able to unwrap the new key
+	if err := l.rewrapRecipients(context.Background(), db, newKey.Borrow()); err != nil {
+		return err
+	}
+	return db.UpdateModified()
+}
+
+// Migrate upgrades the vault to the current format. All files are
+// re-encrypted under a new random data key, which is wrapped by the vault key
+// and split into per-purpose subkeys, blobs are re-chunked and the index is
+// authenticated. The vault is backed up
+// first and rewritten in a single transaction.
+func (l *LockEnv) Migrate(ctx context.Context, password []byte) (*MigrateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	from, err := db.GetFormatVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read format version: %w", err)
+	}
+	if from >= storage.CurrentFormat {
+		return nil, ErrAlreadyMigrated
+	}
+
+	metadata, oldEnc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer oldEnc.Destroy()
+
+	vaultKey, err := l.passwordKey(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	vaultEnc := crypto.NewEncryptor(vaultKey)
+	defer vaultEnc.Destroy()
+
+	dataKey, err := crypto.GenerateRandom(crypto.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	dataEnc, err := dataEncryptor(storage.CurrentFormat, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	defer dataEnc.Destroy()
+
+	migration := &storage.Migration{
+		Format:  storage.CurrentFormat,
+		Files:   make(map[string][]byte),
+		Private: make(map[string][]byte),
+	}
+
+	for _, entry := range metadata.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if sealed, err := db.HasFileData(entry.Path); err == nil && !sealed {
+			continue
+		}
+		encData, err := db.GetFileData(ctx, entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("file %s not sealed in vault: %w", entry.Path, err)
+		}
+
+		// Files in an encryption domain keep their own key and are only re-chunked
+		if entry.Domain != "" {
+			migration.Files[entry.Path] = encData
+			continue
+		}
+
+		data, err := oldEnc.For(crypto.PurposeBlobs).DecryptSecure(encData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt file %s: %w", entry.Path, err)
+		}
+		encData, err = dataEnc.For(crypto.PurposeBlobs).Encrypt(data.Borrow())
+		data.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt file %s: %w", entry.Path, err)
+		}
+		migration.Files[entry.Path] = encData
+	}
+
+	wrapped, err := vaultEnc.Encrypt(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	migration.Private[dataKeyRecord] = wrapped
+
+	record, verifier, err := passwordVerifier(storage.CurrentFormat, dataEnc)
+	if err != nil {
+		return nil, err
+	}
+	migration.Private[record] = verifier
+	if record != checksumRecord {
+		migration.Private[checksumRecord] = nil
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	migration.Private["files"], err = dataEnc.For(crypto.PurposeMetadata).Encrypt(metadataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt metadata: %w", err)
+	}
+
+	migration.Private[indexMACRecord], err = indexMAC(db, dataEnc)
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := l.backup(db, DefaultBackupDir, DefaultBackupKeep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up vault: %w", err)
+	}
+
+	if err := db.ApplyMigration(ctx, migration); err != nil {
+		return nil, fmt.Errorf("failed to migrate vault: %w", err)
+	}
+
+	return &MigrateResult{
+		From:   from,
+		To:     storage.CurrentFormat,
+		Files:  len(migration.Files),
+		Backup: backup.Path,
+	}, nil
+}
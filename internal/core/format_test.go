@@ -0,0 +1,296 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestMigrate_V1ToV2(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	dir := t.TempDir()
+
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+	if err := lockenv.initFormat(password, storage.FormatV1); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	files := map[string]string{
+		"a.env":   "A=1\n",
+		"big.env": strings.Repeat("KEY=value\n", 10000), // Spans several chunks
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	if _, err := lockenv.LockFiles(ctx, paths, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	if _, err := lockenv.Migrate(ctx, []byte("wrong")); err != ErrWrongPassword {
+		t.Fatalf("Expected ErrWrongPassword, got %v", err)
+	}
+
+	result, err := lockenv.Migrate(ctx, password)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.From != storage.FormatV1 || result.To != storage.CurrentFormat || result.Files != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if _, err := os.Stat(result.Backup); err != nil {
+		t.Errorf("Backup not written: %v", err)
+	}
+
+	status, err := lockenv.Status(ctx, false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Version != storage.CurrentFormat {
+		t.Errorf("Expected format v%d, got v%d", storage.CurrentFormat, status.Version)
+	}
+
+	got := vaultContents(t, lockenv, password)
+	for name, content := range files {
+		if got[name] != content {
+			t.Errorf("%s: content changed by migration", name)
+		}
+	}
+
+	if _, err := lockenv.Migrate(ctx, password); err != ErrAlreadyMigrated {
+		t.Errorf("Expected ErrAlreadyMigrated, got %v", err)
+	}
+}
+
+func TestMigrate_V2ToV3SeparatesSubkeys(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	dir := t.TempDir()
+
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+	if err := lockenv.initFormat(password, storage.FormatV2); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	path := filepath.Join(dir, "a.env")
+	if err := os.WriteFile(path, []byte("A=1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	result, err := lockenv.Migrate(ctx, password)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.From != storage.FormatV2 || result.To != storage.FormatV3 || result.Files != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	defer db.Close()
+	key, err := lockenv.passwordKey(context.Background(), db, password)
+	if err != nil {
+		t.Fatalf("passwordKey failed: %v", err)
+	}
+	vaultEnc := crypto.NewEncryptor(key)
+	defer vaultEnc.Destroy()
+	dataKey, err := unwrapDataKey(db, vaultEnc)
+	if err != nil {
+		t.Fatalf("unwrapDataKey failed: %v", err)
+	}
+	rawEnc := crypto.NewEncryptor(dataKey)
+	defer rawEnc.Destroy()
+
+	blob, err := db.GetFileData(context.Background(), "a.env")
+	if err != nil {
+		t.Fatalf("GetFileData failed: %v", err)
+	}
+	files, err := db.GetMetadataBytes("files")
+	if err != nil {
+		t.Fatalf("GetMetadataBytes failed: %v", err)
+	}
+	if _, err := rawEnc.Decrypt(blob); err == nil {
+		t.Error("Expected blob not to decrypt with the data key itself")
+	}
+	if _, err := rawEnc.Decrypt(files); err == nil {
+		t.Error("Expected metadata not to decrypt with the data key itself")
+	}
+
+	subkeys, err := crypto.NewSubkeyEncryptor(dataKey)
+	if err != nil {
+		t.Fatalf("NewSubkeyEncryptor failed: %v", err)
+	}
+	defer subkeys.Destroy()
+	blobEnc := subkeys.For(crypto.PurposeBlobs)
+	if data, err := blobEnc.Decrypt(blob); err != nil || string(data) != "A=1\n" {
+		t.Errorf("Expected blob to decrypt with the blob subkey, got %q, %v", data, err)
+	}
+	if _, err := blobEnc.Decrypt(files); err == nil {
+		t.Error("Expected metadata not to decrypt with the blob subkey")
+	}
+
+	_, enc, err := lockenv.readMetadata(context.Background(), db, password)
+	if err != nil {
+		t.Fatalf("readMetadata failed: %v", err)
+	}
+	defer enc.Destroy()
+	if err := verifyIndex(db, enc); err != nil {
+		t.Errorf("Expected index to verify, got %v", err)
+	}
+	if _, err := db.GetMetadataBytes(checksumRecord); err == nil {
+		t.Error("Expected the v2 checksum to be removed by migration")
+	}
+}
+
+func TestVerifyPassword_WrongPasswordPaths(t *testing.T) {
+	password := []byte("test123")
+	for _, format := range []int{storage.FormatV1, storage.FormatV2, storage.FormatV3} {
+		dir := t.TempDir()
+		lockenv, err := New(dir)
+		if err != nil {
+			t.Fatalf("Failed to create LockEnv: %v", err)
+		}
+		defer lockenv.Close()
+		if err := lockenv.initFormat(password, format); err != nil {
+			t.Fatalf("v%d: Init failed: %v", format, err)
+		}
+
+		if err := lockenv.VerifyPassword(password); err != nil {
+			t.Errorf("v%d: Expected password to verify, got %v", format, err)
+		}
+		if err := lockenv.VerifyPassword([]byte("wrong")); err != ErrWrongPassword {
+			t.Errorf("v%d: Expected ErrWrongPassword, got %v", format, err)
+		}
+
+		record := verifierRecord
+		if format < storage.FormatV3 {
+			record = checksumRecord
+		}
+		db, err := storage.Open(lockenv.path)
+		if err != nil {
+			t.Fatalf("Failed to open vault: %v", err)
+		}
+		stored, err := db.GetMetadataBytes(record)
+		if err != nil {
+			t.Fatalf("v%d: %s record missing: %v", format, record, err)
+		}
+
+		// A corrupted or missing record looks like a wrong password
+		stored[len(stored)-1] ^= 1
+		if err := db.StoreMetadataBytes(record, stored); err != nil {
+			t.Fatalf("StoreMetadataBytes failed: %v", err)
+		}
+		db.Close()
+		if err := lockenv.VerifyPassword(password); err != ErrWrongPassword {
+			t.Errorf("v%d: corrupted %s: expected ErrWrongPassword, got %v", format, record, err)
+		}
+
+		db, err = storage.Open(lockenv.path)
+		if err != nil {
+			t.Fatalf("Failed to open vault: %v", err)
+		}
+		if err := db.ApplyMigration(context.Background(), &storage.Migration{Format: format, Private: map[string][]byte{record: nil}}); err != nil {
+			t.Fatalf("ApplyMigration failed: %v", err)
+		}
+		db.Close()
+		if err := lockenv.VerifyPassword(password); err != ErrWrongPassword {
+			t.Errorf("v%d: missing %s: expected ErrWrongPassword, got %v", format, record, err)
+		}
+	}
+}
+
+func TestChangePassword_V2RewrapsDataKey(t *testing.T) {
+	oldPassword := []byte("old123")
+	newPassword := []byte("new123")
+	lockenv := newMergeTestVault(t, oldPassword, map[string]string{"a.env": "A=1"})
+
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	blob, err := db.GetFileData(context.Background(), "a.env")
+	db.Close()
+	if err != nil {
+		t.Fatalf("GetFileData failed: %v", err)
+	}
+
+	if err := lockenv.ChangePassword(oldPassword, newPassword); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	db, err = storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	after, err := db.GetFileData(context.Background(), "a.env")
+	db.Close()
+	if err != nil {
+		t.Fatalf("GetFileData failed: %v", err)
+	}
+	if !crypto.ConstantTimeCompare(blob, after) {
+		t.Error("Expected file data to be left untouched")
+	}
+
+	if err := lockenv.VerifyPassword(oldPassword); err != ErrWrongPassword {
+		t.Errorf("Expected old password to be rejected, got %v", err)
+	}
+	if got := vaultContents(t, lockenv, newPassword); got["a.env"] != "A=1" {
+		t.Errorf("Unexpected contents after password change: %v", got)
+	}
+}
+
+func TestVerifyIndex(t *testing.T) {
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	defer db.Close()
+
+	_, enc, err := lockenv.readMetadata(context.Background(), db, password)
+	if err != nil {
+		t.Fatalf("readMetadata failed: %v", err)
+	}
+	defer enc.Destroy()
+
+	if err := verifyIndex(db, enc); err != nil {
+		t.Fatalf("Expected index to verify, got %v", err)
+	}
+	if err := db.UpdateManifest("a.env", 999, time.Now(), "forged"); err != nil {
+		t.Fatalf("UpdateManifest failed: %v", err)
+	}
+	if err := verifyIndex(db, enc); err == nil {
+		t.Error("Expected modified index to fail verification")
+	}
+}
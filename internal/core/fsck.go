@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Kinds of FsckIssue
+const (
+	FsckIndex     = "index"     // Index entry missing, stale or different from the metadata
+	FsckOrphan    = "orphan"    // Stored data of a file no longer in the vault
+	FsckTimestamp = "timestamp" // Creation or modification time unreadable or inconsistent
+	FsckIndexMAC  = "index-mac" // Index MAC missing or not matching the index
+)
+
+// FsckIssue is a structural problem found by Fsck
+type FsckIssue struct {
+	Kind   string // One of the Fsck kinds
+	Path   string // Affected file, empty for problems of the whole vault
+	Detail string
+}
+
+// FsckResult lists the problems Fsck found and whether they were repaired
+type FsckResult struct {
+	Issues   []FsckIssue
+	Repaired bool   // The issues were fixed and the vault rewritten
+	Backup   string // Backup taken before repairing
+}
+
+// Fsck checks the structure of the vault beyond the file contents `lockenv
+// verify` covers (implements `lockenv fsck`): the plaintext index against the
+// encrypted metadata, stored data of files no longer in the vault, the vault
+// timestamps and the index MAC. With repair set and issues found, the vault
+// is backed up, the index is rebuilt from the metadata, orphaned data is
+// dropped, the timestamps are fixed and the vault is rewritten into a fresh
+// database.
+func (l *LockEnv) Fsck(ctx context.Context, password []byte, repair bool) (*FsckResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	result := &FsckResult{}
+	issue := func(kind, path, detail string) {
+		result.Issues = append(result.Issues, FsckIssue{Kind: kind, Path: path, Detail: detail})
+	}
+
+	// The index must hold exactly the metadata's files
+	inVault := make(map[string]bool, len(metadata.Files))
+	entries, indexErr := db.GetManifest()
+	if indexErr != nil {
+		issue(FsckIndex, "", fmt.Sprintf("index unreadable: %v", indexErr))
+	}
+	index := make(map[string]storage.ManifestEntry, len(entries))
+	for _, entry := range entries {
+		index[entry.Path] = entry
+	}
+	for _, file := range metadata.Files {
+		inVault[file.Path] = true
+		entry, ok := index[file.Path]
+		switch {
+		case indexErr != nil:
+		case !ok:
+			issue(FsckIndex, file.Path, "missing from the index")
+		case entry.Size != file.Size || entry.Hash != file.Hash || !entry.ModTime.Equal(file.ModTime):
+			issue(FsckIndex, file.Path, "index entry differs from the metadata")
+		}
+	}
+	for _, entry := range entries {
+		if !inVault[entry.Path] {
+			issue(FsckIndex, entry.Path, "in the index but not in the vault")
+		}
+	}
+
+	// Stored data is only reachable through the metadata
+	blobs, err := db.GetBlobPaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored files: %w", err)
+	}
+	var orphans []string
+	for _, path := range blobs {
+		if !inVault[path] {
+			orphans = append(orphans, path)
+			issue(FsckOrphan, path, "stored data of a file not in the vault")
+		}
+	}
+
+	now := time.Now()
+	created, createdErr := db.GetCreated()
+	modified, modifiedErr := db.GetModified()
+	fixCreated := true
+	switch {
+	case createdErr != nil:
+		issue(FsckTimestamp, "", fmt.Sprintf("creation time unreadable: %v", createdErr))
+	case created.IsZero() || created.After(now):
+		issue(FsckTimestamp, "", fmt.Sprintf("creation time %s is invalid", created.Format(time.RFC3339)))
+	default:
+		fixCreated = false
+	}
+	switch {
+	case modifiedErr != nil:
+		issue(FsckTimestamp, "", fmt.Sprintf("modification time unreadable: %v", modifiedErr))
+	case modified.After(now):
+		issue(FsckTimestamp, "", fmt.Sprintf("modification time %s is in the future", modified.Format(time.RFC3339)))
+	case !fixCreated && modified.Before(created):
+		issue(FsckTimestamp, "", "modification time is before the creation time")
+	}
+
+	format, err := db.GetFormatVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read format version: %w", err)
+	}
+	if format >= storage.FormatV2 {
+		if err := verifyIndex(db, enc); err != nil {
+			issue(FsckIndexMAC, "", "index MAC does not match the index")
+		}
+	}
+
+	if !repair || len(result.Issues) == 0 {
+		return result, nil
+	}
+
+	backup, err := l.backup(db, DefaultBackupDir, DefaultBackupKeep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up vault: %w", err)
+	}
+	result.Backup = backup.Path
+
+	rebuilt := make([]storage.ManifestEntry, 0, len(metadata.Files))
+	for _, file := range metadata.Files {
+		rebuilt = append(rebuilt, storage.ManifestEntry{Path: file.Path, Size: file.Size, ModTime: file.ModTime, Hash: file.Hash})
+	}
+	if err := db.ReplaceManifest(rebuilt); err != nil {
+		return nil, fmt.Errorf("failed to rebuild index: %w", err)
+	}
+	for _, path := range orphans {
+		if err := db.RemoveFile(path); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	if fixCreated {
+		// The metadata records its own creation time, authenticated
+		created = metadata.Created
+		if created.IsZero() || created.After(now) {
+			created = now
+		}
+		if err := db.SetCreated(created); err != nil {
+			return nil, fmt.Errorf("failed to fix creation time: %w", err)
+		}
+	}
+
+	// Saving the metadata stores a fresh index MAC and modification time
+	if err := l.saveMetadata(db, metadata, enc); err != nil {
+		return nil, err
+	}
+	if err := db.Compact(); err != nil {
+		return nil, fmt.Errorf("failed to rewrite vault: %w", err)
+	}
+	result.Repaired = true
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestFsck_Repair(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "b.env": "B=2"})
+
+	result, err := lockenv.Fsck(ctx, password, false)
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if len(result.Issues) != 0 {
+		t.Fatalf("Expected a fresh vault to pass, got %+v", result.Issues)
+	}
+
+	// Damage the index, leave an orphaned blob and break the creation time
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	steps := []error{
+		db.UpdateManifest("a.env", 999, time.Now(), "forged"),
+		db.RemoveFromManifest("b.env"),
+		db.UpdateManifest("ghost.env", 1, time.Now(), "ghost"),
+		db.StoreFileData(context.Background(), "old.env", []byte("stale")),
+		db.SetCreated(time.Now().Add(24 * time.Hour)),
+	}
+	db.Close()
+	for _, err := range steps {
+		if err != nil {
+			t.Fatalf("Failed to damage vault: %v", err)
+		}
+	}
+
+	result, err = lockenv.Fsck(ctx, password, false)
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	found := make(map[string]int)
+	for _, issue := range result.Issues {
+		found[issue.Kind+":"+issue.Path]++
+	}
+	for _, want := range []string{"index:a.env", "index:b.env", "index:ghost.env", "orphan:old.env", "timestamp:", "index-mac:"} {
+		if found[want] != 1 {
+			t.Errorf("Expected one %s issue, got %+v", want, result.Issues)
+		}
+	}
+	if result.Repaired {
+		t.Error("Expected no repair without repair set")
+	}
+
+	result, err = lockenv.Fsck(ctx, password, true)
+	if err != nil {
+		t.Fatalf("Fsck --repair failed: %v", err)
+	}
+	if !result.Repaired {
+		t.Error("Expected the vault to be repaired")
+	}
+	if _, err := os.Stat(result.Backup); err != nil {
+		t.Errorf("Backup not written: %v", err)
+	}
+
+	result, err = lockenv.Fsck(ctx, password, false)
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if len(result.Issues) != 0 {
+		t.Errorf("Expected no issues after repair, got %+v", result.Issues)
+	}
+	got := vaultContents(t, lockenv, password)
+	if len(got) != 2 || got["a.env"] != "A=1" || got["b.env"] != "B=2" {
+		t.Errorf("Unexpected contents after repair: %v", got)
+	}
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/git"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Git hooks installed by `lockenv git-hooks install`
+const (
+	GitHookPostCheckout = "post-checkout" // After a branch switch
+	GitHookPostMerge    = "post-merge"    // After a merge or pull
+	GitHookPreRebase    = "pre-rebase"    // Before a rebase, installed with stash
+)
+
+// gitHookEvents lists the git hooks lockenv installs
+var gitHookEvents = []string{GitHookPostCheckout, GitHookPostMerge, GitHookPreRebase}
+
+// gitHookMarker identifies hook scripts written by lockenv, so they can be
+// updated and removed without touching hooks of other tools
+const gitHookMarker = "# Installed by lockenv git-hooks"
+
+// ErrForeignGitHook is returned when a git hook not written by lockenv is
+// in the way
+var ErrForeignGitHook = errors.New("hook exists and was not installed by lockenv")
+
+// gitHookScript returns the script for the git hook event. It never fails,
+// so a broken vault does not make git report a failed checkout or abort a
+// rebase.
+func gitHookScript(event string, autoUnlock, stash bool) string {
+	run := "lockenv git-hooks run"
+	if autoUnlock {
+		run += " --auto-unlock"
+	}
+	if stash {
+		run += " --stash"
+	}
+	return fmt.Sprintf("#!/bin/sh\n%s\n%s %s \"$@\" || true\n", gitHookMarker, run, event)
+}
+
+// InstallGitHooks writes the post-checkout and post-merge hooks of the git
+// repository dir is in (implements `lockenv git-hooks install`). The hooks
+// run `lockenv git-hooks run`, which reminds to unlock when a committed vault
+// changed, or unlocks it with autoUnlock. With stash, a pre-rebase hook is
+// added and local edits are snapshotted (see StashSnapshot) before a rebase
+// or an automatic unlock. Hooks lockenv installed before are replaced; other
+// hooks are left alone and reported with ErrForeignGitHook. Returns the paths
+// of the hooks written.
+func InstallGitHooks(dir string, autoUnlock, stash bool) ([]string, error) {
+	hooksDir, err := git.HooksDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+
+	events := []string{GitHookPostCheckout, GitHookPostMerge}
+	if stash {
+		events = append(events, GitHookPreRebase)
+	}
+
+	// Check every hook first so a foreign one leaves nothing half installed
+	for _, event := range events {
+		if _, err := ownGitHook(filepath.Join(hooksDir, event)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reinstalling without stash drops lockenv's pre-rebase hook
+	if !stash {
+		path := filepath.Join(hooksDir, GitHookPreRebase)
+		if exists, err := ownGitHook(path); err == nil && exists {
+			if err := os.Remove(path); err != nil {
+				return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+	}
+
+	var written []string
+	for _, event := range events {
+		path := filepath.Join(hooksDir, event)
+		if err := os.WriteFile(path, []byte(gitHookScript(event, autoUnlock, stash)), 0755); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		// WriteFile keeps the mode of an existing file
+		if err := os.Chmod(path, 0755); err != nil {
+			return written, fmt.Errorf("failed to make %s executable: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// UninstallGitHooks removes the hooks InstallGitHooks wrote from the git
+// repository dir is in, leaving hooks of other tools. Returns the paths of
+// the hooks removed.
+func UninstallGitHooks(dir string) ([]string, error) {
+	hooksDir, err := git.HooksDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, event := range gitHookEvents {
+		path := filepath.Join(hooksDir, event)
+		exists, err := ownGitHook(path)
+		if errors.Is(err, ErrForeignGitHook) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if !exists {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// ownGitHook reports whether a hook exists at path, returning
+// ErrForeignGitHook if it was not written by lockenv
+func ownGitHook(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Contains(data, []byte(gitHookMarker)) {
+		return true, fmt.Errorf("%s: %w", path, ErrForeignGitHook)
+	}
+	return true, nil
+}
+
+// GitHookRevisions returns the revisions a git hook compares, from the
+// arguments git passed to it. ok is false when there is nothing to compare:
+// post-checkout of single files, a checkout that stays on the same commit,
+// or pre-rebase, which runs before anything changed.
+func GitHookRevisions(event string, args []string) (from, to string, ok bool, err error) {
+	switch event {
+	case GitHookPostCheckout:
+		// <previous HEAD> <new HEAD> <1 for a branch checkout, 0 for files>
+		if len(args) != 3 {
+			return "", "", false, fmt.Errorf("%s hook expects 3 arguments, got %d", event, len(args))
+		}
+		if args[2] != "1" || args[0] == args[1] {
+			return "", "", false, nil
+		}
+		return args[0], args[1], true, nil
+	case GitHookPostMerge:
+		// <1 for a squash merge>; the merge moved HEAD away from ORIG_HEAD
+		return "ORIG_HEAD", "HEAD", true, nil
+	case GitHookPreRebase:
+		return "", "", false, nil
+	}
+	return "", "", false, fmt.Errorf("unknown git hook %q (use %s)", event, strings.Join(gitHookEvents, ", "))
+}
+
+// ChangedVaults returns the directories of the vaults under root, relative
+// to it, whose committed vault file or objects differ between the revisions
+// from and to. root is the top of the git working tree.
+func ChangedVaults(ctx context.Context, root, from, to string) ([]string, error) {
+	dirs, err := FindVaults(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		paths := []string{
+			filepath.ToSlash(filepath.Join(rel, LockEnvFile)),
+			filepath.ToSlash(filepath.Join(rel, storage.ObjectsDir)),
+		}
+		differs, err := git.Changed(root, from, to, paths...)
+		if err != nil {
+			return nil, err
+		}
+		if differs {
+			changed = append(changed, rel)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGitHookRevisions(t *testing.T) {
+	tests := []struct {
+		event    string
+		args     []string
+		from, to string
+		ok       bool
+		wantErr  bool
+	}{
+		{GitHookPostCheckout, []string{"aaa", "bbb", "1"}, "aaa", "bbb", true, false},
+		{GitHookPostCheckout, []string{"aaa", "bbb", "0"}, "", "", false, false},
+		{GitHookPostCheckout, []string{"aaa", "aaa", "1"}, "", "", false, false},
+		{GitHookPostCheckout, []string{"aaa"}, "", "", false, true},
+		{GitHookPostMerge, []string{"0"}, "ORIG_HEAD", "HEAD", true, false},
+		{GitHookPreRebase, []string{"main"}, "", "", false, false},
+		{"pre-commit", nil, "", "", false, true},
+	}
+	for _, tt := range tests {
+		from, to, ok, err := GitHookRevisions(tt.event, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s %v: err = %v, wantErr %v", tt.event, tt.args, err, tt.wantErr)
+			continue
+		}
+		if from != tt.from || to != tt.to || ok != tt.ok {
+			t.Errorf("%s %v = %q, %q, %v, want %q, %q, %v", tt.event, tt.args, from, to, ok, tt.from, tt.to, tt.ok)
+		}
+	}
+}
+
+func TestInstallGitHooks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	hooksDir := filepath.Join(dir, ".git", "hooks")
+
+	written, err := InstallGitHooks(dir, false, true)
+	if err != nil {
+		t.Fatalf("InstallGitHooks failed: %v", err)
+	}
+	if len(written) != 3 {
+		t.Fatalf("Expected 3 hooks written, got %v", written)
+	}
+	for _, event := range []string{GitHookPostCheckout, GitHookPostMerge, GitHookPreRebase} {
+		info, err := os.Stat(filepath.Join(hooksDir, event))
+		if err != nil {
+			t.Fatalf("Hook %s not written: %v", event, err)
+		}
+		if info.Mode().Perm()&0100 == 0 {
+			t.Errorf("Hook %s is not executable: %v", event, info.Mode())
+		}
+	}
+
+	// Reinstalling replaces lockenv's own hooks, dropping pre-rebase without stash
+	if _, err := InstallGitHooks(dir, true, false); err != nil {
+		t.Fatalf("Reinstalling failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(hooksDir, GitHookPreRebase)); !os.IsNotExist(err) {
+		t.Errorf("Expected the pre-rebase hook removed, got %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(hooksDir, GitHookPostMerge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "--auto-unlock post-merge") {
+		t.Errorf("Expected the auto-unlock hook, got:\n%s", data)
+	}
+
+	// A hook of another tool is neither replaced nor removed
+	foreign := filepath.Join(hooksDir, GitHookPostCheckout)
+	if err := os.WriteFile(foreign, []byte("#!/bin/sh\necho other\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstallGitHooks(dir, false, false); !errors.Is(err, ErrForeignGitHook) {
+		t.Errorf("Expected ErrForeignGitHook, got %v", err)
+	}
+	removed, err := UninstallGitHooks(dir)
+	if err != nil {
+		t.Fatalf("UninstallGitHooks failed: %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != GitHookPostMerge {
+		t.Errorf("Expected only post-merge removed, got %v", removed)
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Errorf("Foreign hook was removed: %v", err)
+	}
+}
+
+func TestChangedVaults(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	password := []byte("test123")
+	root := t.TempDir()
+	for _, dir := range []string{root, filepath.Join(root, "svc")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		lockenv, err := NewAt(dir)
+		if err != nil {
+			t.Fatalf("Failed to create LockEnv: %v", err)
+		}
+		if err := lockenv.Init(password); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+		lockenv.Close()
+	}
+	runGit(t, root, "init", "-q")
+	runGit(t, root, "add", ".")
+	runGit(t, root, "commit", "-q", "-m", "vaults")
+
+	// Lock a file into the service vault only
+	lockenv, err := NewAt(filepath.Join(root, "svc"))
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+	if err := os.WriteFile(filepath.Join(root, "svc", ".env"), []byte("A=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{".env"}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, false, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	runGit(t, root, "add", filepath.Join("svc", LockEnvFile))
+	runGit(t, root, "commit", "-q", "-m", "lock")
+
+	changed, err := ChangedVaults(ctx, root, "HEAD~1", "HEAD")
+	if err != nil {
+		t.Fatalf("ChangedVaults failed: %v", err)
+	}
+	if !slices.Equal(changed, []string{"svc"}) {
+		t.Errorf("Expected only svc changed, got %v", changed)
+	}
+
+	changed, err = ChangedVaults(ctx, root, "HEAD", "HEAD")
+	if err != nil {
+		t.Fatalf("ChangedVaults failed: %v", err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+}
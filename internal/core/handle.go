@@ -0,0 +1,132 @@
+package core
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// ErrNotHeld is returned by Release without a matching Hold
+var ErrNotHeld = errors.New("release without hold")
+
+// sharedBackend is the vault handle as returned by open: operations share
+// one open handle, and Close ends the operation's use of it instead of
+// closing the vault. Operations pass the handle they opened down to the
+// helpers they call, so concurrent operations never see each other's.
+type sharedBackend struct {
+	storage.Backend
+	l    *LockEnv
+	once sync.Once
+}
+
+// Close ends the use of the shared handle; the vault is closed once no
+// operation uses it and no Hold keeps it open
+func (s *sharedBackend) Close() error {
+	var err error
+	s.once.Do(func() {
+		s.l.mu.Lock()
+		defer s.l.mu.Unlock()
+		s.l.uses--
+		err = s.l.closeIdle()
+	})
+	return err
+}
+
+// Layout and SetLayout pass through to the backend, which need not
+// implement storage.Layouts
+func (s *sharedBackend) Layout() string {
+	return storage.LayoutOf(s.Backend)
+}
+
+func (s *sharedBackend) SetLayout(layout string) error {
+	return storage.ConvertLayout(s.Backend, layout)
+}
+
+// open returns the handle of the vault, opening it with the selected
+// storage backend unless an operation or Hold already did. Nested and
+// concurrent operations share the handle, so the vault file is opened and
+// locked once instead of per operation.
+func (l *LockEnv) open() (storage.Backend, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.handle == nil {
+		db, err := l.openAt(l.path)
+		if err != nil {
+			return nil, err
+		}
+		l.handle = db
+	}
+	l.uses++
+	return &sharedBackend{Backend: l.handle, l: l}, nil
+}
+
+// Hold opens the vault and keeps it open across the following operations
+// until the matching Release, so a command running several of them opens
+// and locks the vault file once. Other processes wait for the vault until
+// then, so Hold after prompting for a password, not before. Hold and
+// Release pairs nest. This is not a transaction: every operation still
+// commits its own writes to the vault before it returns, since the working
+// tree files it writes could not be rolled back with the vault anyway.
+func (l *LockEnv) Hold() error {
+	if !l.exists() {
+		return ErrNotInitialized
+	}
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	l.mu.Lock()
+	l.holds++
+	l.mu.Unlock()
+	return db.Close()
+}
+
+// Release ends the Hold it matches, closing the vault once no operation
+// uses it, so other processes can open it again
+func (l *LockEnv) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holds == 0 {
+		return ErrNotHeld
+	}
+	l.holds--
+	return l.closeIdle()
+}
+
+// closeIdle closes the handle if no operation uses it and no Hold keeps
+// it open. Must be called with l.mu held.
+func (l *LockEnv) closeIdle() error {
+	if l.handle == nil || l.uses > 0 || l.holds > 0 {
+		return nil
+	}
+	err := l.handle.Close()
+	l.handle = nil
+	return err
+}
+
+// reopen closes the handle so the next operation opens the vault file
+// again, as needed after the file was replaced. Holds are kept.
+func (l *LockEnv) reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.handle == nil || l.uses > 0 {
+		return nil
+	}
+	err := l.handle.Close()
+	l.handle = nil
+	return err
+}
+
+// closeHandle closes the handle regardless of holds, for Close
+func (l *LockEnv) closeHandle() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.holds = 0
+	if l.handle == nil {
+		return nil
+	}
+	err := l.handle.Close()
+	l.handle = nil
+	return err
+}
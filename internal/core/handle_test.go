@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+func TestHoldRelease(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	if err := lockenv.Hold(); err != ErrNotInitialized {
+		t.Errorf("Expected ErrNotInitialized before init, got %v", err)
+	}
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if lockenv.handle != nil {
+		t.Error("Expected the vault closed after Init returned")
+	}
+
+	if err := lockenv.Hold(); err != nil {
+		t.Fatalf("Hold failed: %v", err)
+	}
+	held := lockenv.handle
+	if held == nil {
+		t.Fatal("Expected Hold to open the vault")
+	}
+
+	// Operations share the handle Hold opened and leave it open
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{".env"}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if lockenv.handle != held {
+		t.Error("Expected operations to reuse the handle opened by Hold")
+	}
+
+	if err := lockenv.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if lockenv.handle != nil {
+		t.Error("Expected Release to close the vault")
+	}
+	if err := lockenv.Release(); err != ErrNotHeld {
+		t.Errorf("Expected ErrNotHeld, got %v", err)
+	}
+
+	files, err := lockenv.List(context.Background())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected the locked file after Release, got %v", files)
+	}
+}
+
+func TestOpen_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	if err := lockenv.Init([]byte("test123")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	// A second handle on the vault file would wait for the first one's lock
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, err := lockenv.open()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer db.Close()
+			initialized, err := db.IsInitialized()
+			if err == nil && !initialized {
+				t.Error("Expected an initialized vault")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent open failed: %v", err)
+		}
+	}
+	if lockenv.handle != nil {
+		t.Error("Expected the vault closed after the last operation returned")
+	}
+}
+
+func TestOperations_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{".env"}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, false, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	// Operations reading metadata used to share the handle through the
+	// LockEnv, and one finishing early left the others without it
+	ops := []func() error{
+		func() error { _, err := lockenv.GetChangedFiles(ctx, password); return err },
+		func() error { _, err := lockenv.VerifyVault(ctx, password); return err },
+		func() error { _, err := lockenv.Status(ctx, false); return err },
+		func() error { _, err := lockenv.List(ctx); return err },
+		func() error {
+			key, err := lockenv.DeriveKey(ctx, password)
+			crypto.ClearBytes(key)
+			return err
+		},
+		func() error {
+			if _, err := lockenv.DeriveKey(ctx, []byte("wrong")); err != ErrWrongPassword {
+				return fmt.Errorf("DeriveKey with a wrong password: expected ErrWrongPassword, got %v", err)
+			}
+			return nil
+		},
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*len(ops))
+	for range 4 {
+		for _, op := range ops {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- op()
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent operation failed: %v", err)
+		}
+	}
+}
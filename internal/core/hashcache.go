@@ -0,0 +1,121 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// hashCacheEntry records the content hash of a local file together with the
+// stat data it was computed for. The entry is stale once any of them change.
+type hashCacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Inode   uint64    `json:"inode,omitempty"`
+	Hash    string    `json:"hash"`
+}
+
+// hashCache hashes local files, reusing hashes stored in the user's cache
+// directory while a file's size, mtime and inode are unchanged. The cache
+// describes the working tree, not the vault, so it is kept out of the
+// committed vault file: commands that only read the vault leave it
+// untouched. New hashes are collected and written in one go by save.
+type hashCache struct {
+	path    string // Cache file, empty if there is no user cache directory
+	entries map[string]hashCacheEntry
+	updates map[string]hashCacheEntry
+	refresh bool // Ignore stored hashes and hash every file again
+}
+
+// newHashCache loads the hash cache of the vault at vaultPath. A missing or
+// unreadable cache is empty.
+func newHashCache(vaultPath string) *hashCache {
+	c := &hashCache{entries: make(map[string]hashCacheEntry), updates: make(map[string]hashCacheEntry)}
+	c.path = hashCachePath(vaultPath)
+	if c.path == "" {
+		return c
+	}
+	if data, err := os.ReadFile(c.path); err == nil {
+		json.Unmarshal(data, &c.entries)
+	}
+	return c
+}
+
+// hashCachePath returns the cache file of the vault at vaultPath, named
+// after the vault's absolute path, or "" without a user cache directory
+func hashCachePath(vaultPath string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(vaultPath)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "lockenv", "hashes-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// hash returns the SHA-256 hex digest of the file at platformPath, stored
+// under path. info must be the file's current stat data.
+func (c *hashCache) hash(path, platformPath string, info os.FileInfo) (string, error) {
+	inode := fileInode(info)
+	if cached, ok := c.entries[path]; ok && !c.refresh &&
+		cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) && cached.Inode == inode {
+		return cached.Hash, nil
+	}
+
+	content, err := os.ReadFile(platformPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	crypto.ClearBytes(content)
+	hash := hex.EncodeToString(sum[:])
+
+	c.updates[path] = hashCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Inode:   inode,
+		Hash:    hash,
+	}
+	return hash, nil
+}
+
+// save writes the hashes computed since the cache was loaded. Failing to
+// update the cache only costs speed, so callers may ignore the error.
+func (c *hashCache) save() error {
+	if len(c.updates) == 0 || c.path == "" {
+		return nil
+	}
+	for path, entry := range c.updates {
+		c.entries[path] = entry
+	}
+	c.updates = make(map[string]hashCacheEntry)
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), DirPermSecure); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
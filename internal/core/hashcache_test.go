@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashCache_ReusesHashUntilStatChanges(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+	if err := lockenv.Init([]byte("test123")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	file := filepath.Join(dir, ".env")
+	if err := os.WriteFile(file, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+
+	hashOf := func(cache *hashCache) string {
+		t.Helper()
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Failed to stat test file: %v", err)
+		}
+		hash, err := cache.hash(".env", file, info)
+		if err != nil {
+			t.Fatalf("hash failed: %v", err)
+		}
+		return hash
+	}
+	expected := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	cache := newHashCache(lockenv.path)
+	if got := hashOf(cache); got != expected("SECRET=1") {
+		t.Fatalf("Unexpected hash %s", got)
+	}
+	if err := cache.save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	// Rewrite in place with the same size and mtime: the cached hash is used
+	if err := os.WriteFile(file, []byte("SECRET=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+	if got := hashOf(newHashCache(lockenv.path)); got != expected("SECRET=1") {
+		t.Errorf("Expected cached hash, got %s", got)
+	}
+
+	// refresh ignores the cache
+	refreshed := newHashCache(lockenv.path)
+	refreshed.refresh = true
+	if got := hashOf(refreshed); got != expected("SECRET=2") {
+		t.Errorf("Expected fresh hash with refresh, got %s", got)
+	}
+
+	// A new mtime invalidates the entry
+	later := mtime.Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+	if got := hashOf(newHashCache(lockenv.path)); got != expected("SECRET=2") {
+		t.Errorf("Expected stale entry to be rehashed, got %s", got)
+	}
+}
+
+func TestStatus_LeavesVaultUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	file := filepath.Join(dir, ".env")
+	if err := os.WriteFile(file, []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{file}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	vault, err := os.ReadFile(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to read vault: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	for _, change := range []func() error{
+		func() error { return os.WriteFile(file, []byte("A=2"), 0644) },
+		func() error { return os.Chtimes(file, later, later) },
+	} {
+		if err := change(); err != nil {
+			t.Fatalf("Failed to change test file: %v", err)
+		}
+		if _, err := lockenv.Status(context.Background(), false); err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		after, err := os.ReadFile(lockenv.path)
+		if err != nil {
+			t.Fatalf("Failed to read vault: %v", err)
+		}
+		if !bytes.Equal(after, vault) {
+			t.Fatal("Status changed the vault file")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Lifecycle events that can run a hook from the [hooks] table of .lockenv.toml
+const (
+	HookPreLock    = "pre-lock"    // Before lock encrypts files; failing aborts the lock
+	HookPostLock   = "post-lock"   // After lock encrypted files
+	HookPostUnlock = "post-unlock" // After unlock restored files
+	HookPostPasswd = "post-passwd" // After passwd changed the password
+)
+
+// hookEvents lists the valid hook names
+var hookEvents = []string{HookPreLock, HookPostLock, HookPostUnlock, HookPostPasswd}
+
+// hookEnvDenylist lists variables never passed to hooks
+var hookEnvDenylist = []string{"LOCKENV_PASSWORD"}
+
+// RunHook runs the command configured for event, if any, with the shell in
+// dir. The hook gets the event context in LOCKENV_EVENT, LOCKENV_DIR and
+// LOCKENV_FILES (vault paths, one per line), but not LOCKENV_PASSWORD. Its
+// output goes to stderr so it never mixes with lockenv's own output.
+func RunHook(ctx context.Context, dir string, config *Config, event string, files []string) error {
+	command := config.Hooks[event]
+	if command == "" {
+		return nil
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("%s hook: %w", event, err)
+	}
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	c.Dir = absDir
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	c.Env = append(hookEnviron(),
+		"LOCKENV_EVENT="+event,
+		"LOCKENV_DIR="+absDir,
+		"LOCKENV_FILES="+strings.Join(files, "\n"),
+	)
+
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", event, err)
+	}
+	return nil
+}
+
+// hookEnviron returns the environment without the denylisted variables
+func hookEnviron() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		denied := false
+		for _, d := range hookEnvDenylist {
+			if strings.EqualFold(name, d) {
+				denied = true
+				break
+			}
+		}
+		if !denied {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCKENV_PASSWORD", "secret")
+	out := filepath.Join(dir, "hook.out")
+	config := &Config{Hooks: map[string]string{
+		HookPostUnlock: `printf '%s|%s|%s|%s' "$LOCKENV_EVENT" "$LOCKENV_FILES" "$LOCKENV_PASSWORD" "$(pwd)" > hook.out`,
+		HookPreLock:    "exit 3",
+	}}
+
+	if err := RunHook(context.Background(), dir, config, HookPostUnlock, []string{".env", "config/app.env"}); err != nil {
+		t.Fatalf("RunHook failed: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Hook did not run: %v", err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+	parts := strings.Split(string(data), "|")
+	if len(parts) != 4 || parts[0] != HookPostUnlock || parts[1] != ".env\nconfig/app.env" || parts[2] != "" {
+		t.Errorf("Unexpected hook context: %q", data)
+	}
+	if got, _ := filepath.EvalSymlinks(parts[len(parts)-1]); got != realDir {
+		t.Errorf("Hook ran in %q, want %q", parts[len(parts)-1], realDir)
+	}
+
+	if err := RunHook(context.Background(), dir, config, HookPreLock, nil); err == nil || !strings.Contains(err.Error(), "pre-lock hook failed") {
+		t.Errorf("Expected pre-lock failure, got %v", err)
+	}
+
+	// Events without a hook do nothing
+	if err := RunHook(context.Background(), dir, config, HookPostPasswd, nil); err != nil {
+		t.Errorf("RunHook without hook failed: %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// ErrBinaryPatch is returned by NewPatch for binary files
+var ErrBinaryPatch = errors.New("binary files cannot be locked by hunk")
+
+// patchLine is one line of a line-mode diff, keeping its line ending so the
+// selected version can be rebuilt byte for byte
+type patchLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// hunk is a run of changed lines in a patch, lines[start:end]
+type hunk struct {
+	start, end         int
+	oldStart, newStart int // 1-based line numbers before the hunk's first line
+}
+
+// patchLines diffs oldData and newData by line
+func patchLines(oldData, newData []byte) []patchLine {
+	dmp := diffmatchpatch.New()
+	a, b, lineArray := dmp.DiffLinesToChars(string(oldData), string(newData))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+
+	var lines []patchLine
+	for _, d := range diffs {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text != "" {
+				lines = append(lines, patchLine{op: op, text: text})
+			}
+		}
+	}
+	return lines
+}
+
+// splitHunks groups consecutive changed lines into hunks. Unlike the hunks
+// of lockenv diff, hunks are never merged, so each change can be picked on
+// its own.
+func splitHunks(lines []patchLine) []hunk {
+	var hunks []hunk
+	oldNo, newNo := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			oldNo++
+			newNo++
+			i++
+			continue
+		}
+		h := hunk{start: i, oldStart: oldNo, newStart: newNo}
+		for ; i < len(lines) && lines[i].op != ' '; i++ {
+			if lines[i].op == '-' {
+				oldNo++
+			} else {
+				newNo++
+			}
+		}
+		h.end = i
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// applyHunks rebuilds the old version with the selected hunks applied
+func applyHunks(lines []patchLine, hunks []hunk, selected []bool) []byte {
+	var result strings.Builder
+	h := 0
+	for i, line := range lines {
+		for h < len(hunks) && hunks[h].end <= i {
+			h++
+		}
+		switch {
+		case line.op == ' ':
+			result.WriteString(line.text)
+		case line.op == '+' && selected[h]:
+			result.WriteString(line.text)
+		case line.op == '-' && !selected[h]:
+			result.WriteString(line.text)
+		}
+	}
+	return []byte(result.String())
+}
+
+// formatHunk renders a hunk with up to diffContextLines unchanged lines on
+// each side, masking values unless showSecrets is set
+func formatHunk(lines []patchLine, h hunk, showSecrets bool) string {
+	start := h.start
+	for start > 0 && h.start-start < diffContextLines && lines[start-1].op == ' ' {
+		start--
+	}
+	end := h.end
+	for end < len(lines) && end-h.end < diffContextLines && lines[end].op == ' ' {
+		end++
+	}
+
+	oldCount, newCount := 0, 0
+	for _, line := range lines[start:end] {
+		if line.op != '+' {
+			oldCount++
+		}
+		if line.op != '-' {
+			newCount++
+		}
+	}
+	context := h.start - start
+
+	var result strings.Builder
+	fmt.Fprintf(&result, "@@ -%d,%d +%d,%d @@\n",
+		hunkStart(h.oldStart-context, oldCount), oldCount, hunkStart(h.newStart-context, newCount), newCount)
+	for _, line := range lines[start:end] {
+		text := strings.TrimRight(line.text, "\r\n")
+		if !showSecrets {
+			text = RedactLine(text)
+		}
+		result.WriteString(string(line.op) + text + "\n")
+	}
+	return result.String()
+}
+
+// Patch is the changes between the vault and local version of a text file,
+// split into hunks that can be locked one at a time like git add -p
+// (implements `lockenv lock -p`; the caller asks about each hunk)
+type Patch struct {
+	path  string
+	lines []patchLine
+	hunks []hunk
+}
+
+// NewPatch diffs the vault and local version of the file at path
+func NewPatch(path string, vaultData, localData []byte) (*Patch, error) {
+	if !DetectFileType(vaultData) || !DetectFileType(localData) {
+		return nil, ErrBinaryPatch
+	}
+	lines := patchLines(vaultData, localData)
+	return &Patch{path: path, lines: lines, hunks: splitHunks(lines)}, nil
+}
+
+// Len returns the number of hunks, 0 if the versions are equal
+func (p *Patch) Len() int {
+	return len(p.hunks)
+}
+
+// Header returns the diff header naming the file, shown before the hunks
+func (p *Patch) Header() string {
+	return fmt.Sprintf("--- a/%s\n+++ b/%s\n", p.path, p.path)
+}
+
+// Hunk renders hunk i with its context lines. Values are masked unless
+// showSecrets is set.
+func (p *Patch) Hunk(i int, showSecrets bool) string {
+	return formatHunk(p.lines, p.hunks[i], showSecrets)
+}
+
+// Apply returns the vault version with the hunks marked in selected, one
+// entry per hunk, applied; the other changes are left out
+func (p *Patch) Apply(selected []bool) []byte {
+	return applyHunks(p.lines, p.hunks, selected)
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyHunks(t *testing.T) {
+	vault := "A=1\nB=2\nC=3\nD=4\n"
+	local := "A=changed\nB=2\nC=3\nD=4\nE=5\n"
+	lines := patchLines([]byte(vault), []byte(local))
+	hunks := splitHunks(lines)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(hunks))
+	}
+
+	tests := []struct {
+		name     string
+		selected []bool
+		want     string
+	}{
+		{"none", []bool{false, false}, vault},
+		{"all", []bool{true, true}, local},
+		{"first", []bool{true, false}, "A=changed\nB=2\nC=3\nD=4\n"},
+		{"second", []bool{false, true}, "A=1\nB=2\nC=3\nD=4\nE=5\n"},
+	}
+	for _, tt := range tests {
+		if got := string(applyHunks(lines, hunks, tt.selected)); got != tt.want {
+			t.Errorf("%s: applyHunks() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyHunks_LineEndings(t *testing.T) {
+	// A missing final newline and CRLF endings survive either choice
+	vault := "A=1\r\nB=2"
+	local := "A=1\r\nB=3"
+	lines := patchLines([]byte(vault), []byte(local))
+	hunks := splitHunks(lines)
+	if got := string(applyHunks(lines, hunks, []bool{false})); got != vault {
+		t.Errorf("Unselected: got %q, want %q", got, vault)
+	}
+	if got := string(applyHunks(lines, hunks, []bool{true})); got != local {
+		t.Errorf("Selected: got %q, want %q", got, local)
+	}
+}
+
+func TestFormatHunk(t *testing.T) {
+	lines := patchLines([]byte("A=1\nB=2\nC=3\nD=4\nE=5\n"), []byte("A=1\nB=2\nC=3\nD=4\nE=changed\n"))
+	hunks := splitHunks(lines)
+	got := formatHunk(lines, hunks[0], true)
+	want := "@@ -2,4 +2,4 @@\n B=2\n C=3\n D=4\n-E=5\n+E=changed\n"
+	if got != want {
+		t.Errorf("formatHunk() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNewPatch(t *testing.T) {
+	patch, err := NewPatch(".env", []byte("A=1\nB=2\n"), []byte("A=1\nB=3\n"))
+	if err != nil {
+		t.Fatalf("NewPatch failed: %v", err)
+	}
+	if patch.Len() != 1 {
+		t.Fatalf("Expected 1 hunk, got %d", patch.Len())
+	}
+	if got := string(patch.Apply([]bool{true})); got != "A=1\nB=3\n" {
+		t.Errorf("Apply() = %q", got)
+	}
+
+	if patch, err := NewPatch(".env", []byte("A=1\n"), []byte("A=1\n")); err != nil || patch.Len() != 0 {
+		t.Errorf("Expected no hunks for equal versions, got %v", err)
+	}
+	if _, err := NewPatch("key.bin", []byte{0, 1, 2}, []byte{0, 1, 3}); !errors.Is(err, ErrBinaryPatch) {
+		t.Errorf("Expected ErrBinaryPatch, got %v", err)
+	}
+}
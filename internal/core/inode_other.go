@@ -0,0 +1,11 @@
+//go:build !unix
+
+package core
+
+import "os"
+
+// fileInode returns 0 where os.FileInfo carries no inode number; the hash
+// cache then relies on size and mtime alone
+func fileInode(_ os.FileInfo) uint64 {
+	return 0
+}
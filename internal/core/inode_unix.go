@@ -0,0 +1,17 @@
+//go:build unix
+
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode number of a file, so the hash cache notices
+// files replaced by a rename with the same size and mtime
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
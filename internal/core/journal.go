@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/logging"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// LockJournal records a lock in progress. It is written before the first
+// file's data is replaced and removed once the metadata is saved, so a lock
+// interrupted in between is found on the next open and the metadata is
+// brought in line with the data actually stored.
+type LockJournal struct {
+	Started  time.Time      `json:"started"`
+	Files    []JournalEntry `json:"files"`
+	Metadata []byte         `json:"metadata"` // Encrypted metadata as saved once every file is stored
+}
+
+// JournalEntry is a file being stored by the lock in a LockJournal
+type JournalEntry struct {
+	Path    string    `json:"path"`
+	Blob    string    `json:"blob"` // SHA-256 of the encrypted data being stored
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"`
+}
+
+// getLockJournal returns the journal of an interrupted lock, or nil if
+// there is none
+func getLockJournal(db storage.ConfigStore) (*LockJournal, error) {
+	journal := &LockJournal{}
+	if found, err := getRecord(db, recordJournal, journal); !found {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// recoverLock completes or rolls back a lock interrupted while storing its
+// files, as recorded by the lock journal FinalizeLock writes. A file whose
+// stored data is the one the lock was writing gets its new metadata entry
+// (rolled forward); any other file keeps its previous entry, matching the
+// data still stored (rolled back). metadata is updated in place and saved,
+// the journal is removed and the recovery is passed to SetNotify.
+func (l *LockEnv) recoverLock(ctx context.Context, db storage.Backend, metadata *storage.Metadata, enc *crypto.Encryptor) error {
+	journal, err := getLockJournal(db)
+	if err != nil {
+		return fmt.Errorf("failed to read lock journal: %w", err)
+	}
+	if journal == nil {
+		return nil
+	}
+
+	plain, err := enc.For(crypto.PurposeMetadata).Decrypt(journal.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt lock journal: %w", err)
+	}
+	var locked storage.Metadata
+	if err := json.Unmarshal(plain, &locked); err != nil {
+		return fmt.Errorf("failed to unmarshal lock journal: %w", err)
+	}
+
+	var forward, back int
+	for _, entry := range journal.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		digest, err := blobDigest(ctx, db, entry.Path)
+		if err != nil || digest != entry.Blob {
+			logging.Debug("interrupted lock rolled back", "path", entry.Path)
+			back++
+			continue
+		}
+		next := locked.FindFile(entry.Path)
+		file := metadata.FindFile(entry.Path)
+		if next == nil || file == nil {
+			back++
+			continue
+		}
+		*file = *next
+		if err := l.updateManifestEntry(db, entry.Path, entry.Size, entry.ModTime, entry.Hash); err != nil {
+			return fmt.Errorf("failed to update manifest for %s: %w", entry.Path, err)
+		}
+		logging.Debug("interrupted lock rolled forward", "path", entry.Path)
+		forward++
+	}
+
+	if forward > 0 {
+		if err := l.saveMetadata(db, metadata, enc); err != nil {
+			return err
+		}
+	}
+	if err := db.DeleteConfig(recordJournal); err != nil {
+		return fmt.Errorf("failed to clear lock journal: %w", err)
+	}
+	l.notice(FileEvent{Action: EventWarning, Err: fmt.Errorf("recovered a lock interrupted on %s: %d files locked, %d kept their previous version",
+		journal.Started.Local().Format("2006-01-02 15:04:05"), forward, back)})
+	return nil
+}
+
+// blobDigest returns the SHA-256 of the encrypted data stored for path
+func blobDigest(ctx context.Context, db storage.BlobStore, path string) (string, error) {
+	h := sha256.New()
+	err := db.ReadFileData(ctx, path, func(piece []byte) error {
+		h.Write(piece)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
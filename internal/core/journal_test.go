@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoverLock_Interrupted(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	stored := filepath.Join(dir, "stored.env")
+	kept := filepath.Join(dir, "kept.env")
+	lock := func(content string) {
+		for _, path := range []string{stored, kept} {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+		}
+		if _, err := lockenv.LockFiles(ctx, []string{stored, kept}, password, false); err != nil {
+			t.Fatalf("LockFiles failed: %v", err)
+		}
+		if _, err := lockenv.FinalizeLock(ctx, password, false, nil); err != nil {
+			t.Fatalf("FinalizeLock failed: %v", err)
+		}
+	}
+
+	lock("A=1")
+	db, err := lockenv.open()
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer db.Close()
+	oldMetadata, err := db.GetMetadataBytes("files")
+	if err != nil {
+		t.Fatalf("GetMetadataBytes failed: %v", err)
+	}
+	oldBlob, err := db.GetFileData(ctx, "kept.env")
+	if err != nil {
+		t.Fatalf("GetFileData failed: %v", err)
+	}
+	oldEntry, err := db.GetManifestEntry("kept.env")
+	if err != nil {
+		t.Fatalf("GetManifestEntry failed: %v", err)
+	}
+
+	lock("A=2")
+	if journal, err := getLockJournal(db); err != nil || journal != nil {
+		t.Fatalf("Expected no journal after a completed lock, got %v, %v", journal, err)
+	}
+
+	// Rewind to a lock stopped after storing stored.env: the journal and
+	// its data are in place, the metadata and kept.env are not
+	journal := &LockJournal{Started: time.Now()}
+	journal.Metadata, err = db.GetMetadataBytes("files")
+	if err != nil {
+		t.Fatalf("GetMetadataBytes failed: %v", err)
+	}
+	for _, path := range []string{"stored.env", "kept.env"} {
+		digest, err := blobDigest(ctx, db, path)
+		if err != nil {
+			t.Fatalf("blobDigest failed: %v", err)
+		}
+		entry, err := db.GetManifestEntry(path)
+		if err != nil {
+			t.Fatalf("GetManifestEntry failed: %v", err)
+		}
+		journal.Files = append(journal.Files, JournalEntry{
+			Path: path, Blob: digest, Size: entry.Size, ModTime: entry.ModTime, Hash: entry.Hash,
+		})
+	}
+	if err := putRecord(db, recordJournal, journal); err != nil {
+		t.Fatalf("SetLockJournal failed: %v", err)
+	}
+	if err := db.StoreMetadataBytes("files", oldMetadata); err != nil {
+		t.Fatalf("StoreMetadataBytes failed: %v", err)
+	}
+	if err := db.StoreFileData(ctx, "kept.env", oldBlob); err != nil {
+		t.Fatalf("StoreFileData failed: %v", err)
+	}
+	if err := db.UpdateManifest("kept.env", oldEntry.Size, oldEntry.ModTime, oldEntry.Hash); err != nil {
+		t.Fatalf("UpdateManifest failed: %v", err)
+	}
+	db.Close()
+
+	// The next operation reading the metadata recovers the lock
+	var notices []FileEvent
+	lockenv.SetNotify(func(event FileEvent) { notices = append(notices, event) })
+	result, err := lockenv.Fsck(ctx, password, false)
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	for _, issue := range result.Issues {
+		t.Errorf("Unexpected issue after recovery: %+v", issue)
+	}
+	if len(notices) != 1 || notices[0].Action != EventWarning || !strings.Contains(notices[0].Err.Error(), "1 files locked, 1 kept") {
+		t.Errorf("Expected the recovery reported once, got %v", notices)
+	}
+
+	for _, path := range []string{stored, kept} {
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("Failed to remove test file: %v", err)
+		}
+	}
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if content, _ := os.ReadFile(stored); string(content) != "A=2" {
+		t.Errorf("Expected the stored file rolled forward, got %q", content)
+	}
+	if content, _ := os.ReadFile(kept); string(content) != "A=1" {
+		t.Errorf("Expected the unstored file rolled back, got %q", content)
+	}
+
+	db, err = lockenv.open()
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer db.Close()
+	if journal, err := getLockJournal(db); err != nil || journal != nil {
+		t.Errorf("Expected the journal removed, got %v, %v", journal, err)
+	}
+}
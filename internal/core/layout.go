@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Layout returns the vault layout, storage.LayoutFile or storage.LayoutDir
+func (l *LockEnv) Layout() (string, error) {
+	if !l.exists() {
+		return "", ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return "", openError(err)
+	}
+	defer db.Close()
+
+	return storage.LayoutOf(db), nil
+}
+
+// SetLayout converts the vault to layout (implements `lockenv init --layout`
+// and `lockenv migrate --layout`). In the dir layout each file's encrypted
+// contents are kept in their own object under storage.ObjectsDir, so git
+// diffs and merges of the vault are per file. The blobs are moved as they
+// are, so no password is required. Returns false if the vault already had
+// the layout.
+func (l *LockEnv) SetLayout(ctx context.Context, layout string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if !l.exists() {
+		return false, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return false, openError(err)
+	}
+	defer db.Close()
+
+	if storage.LayoutOf(db) == layout {
+		return false, nil
+	}
+	if err := storage.ConvertLayout(db, layout); err != nil {
+		return false, fmt.Errorf("failed to convert vault to the %s layout: %w", layout, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestSetLayout(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "config/b.env": "B=1"})
+	dir := filepath.Dir(lockenv.path)
+
+	changed, err := lockenv.SetLayout(ctx, storage.LayoutDir)
+	if err != nil || !changed {
+		t.Fatalf("SetLayout(dir) = %v, %v", changed, err)
+	}
+	if layout, _ := lockenv.Layout(); layout != storage.LayoutDir {
+		t.Errorf("Layout() = %s, want dir", layout)
+	}
+	if changed, err := lockenv.SetLayout(ctx, storage.LayoutDir); err != nil || changed {
+		t.Errorf("Second SetLayout(dir) = %v, %v, want no change", changed, err)
+	}
+	for _, name := range []string{"a.env.enc", "config%2Fb.env.enc"} {
+		if _, err := os.Stat(filepath.Join(dir, storage.ObjectsDir, name)); err != nil {
+			t.Errorf("Expected object %s: %v", name, err)
+		}
+	}
+
+	// Locking a change rewrites only that file's object
+	objectB := filepath.Join(dir, storage.ObjectsDir, "config%2Fb.env.enc")
+	before, err := os.ReadFile(objectB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "a.env")
+	if err := os.WriteFile(path, []byte("A=2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if after, err := os.ReadFile(objectB); err != nil || !bytes.Equal(before, after) {
+		t.Errorf("Object of an unchanged file was rewritten: %v", err)
+	}
+	contents := vaultContents(t, lockenv, password)
+	if contents["a.env"] != "A=2" || contents["config/b.env"] != "B=1" {
+		t.Errorf("Unexpected vault contents: %v", contents)
+	}
+
+	if changed, err := lockenv.SetLayout(ctx, storage.LayoutFile); err != nil || !changed {
+		t.Fatalf("SetLayout(file) = %v, %v", changed, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, storage.ObjectsDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", storage.ObjectsDir, err)
+	}
+	contents = vaultContents(t, lockenv, password)
+	if contents["a.env"] != "A=2" || contents["config/b.env"] != "B=1" {
+		t.Errorf("Unexpected vault contents after converting back: %v", contents)
+	}
+}
+
+func TestShowObjects(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	dir := filepath.Dir(lockenv.path)
+	if _, err := lockenv.SetLayout(ctx, storage.LayoutDir); err != nil {
+		t.Fatalf("SetLayout failed: %v", err)
+	}
+	committed, err := os.ReadFile(filepath.Join(dir, storage.ObjectsDir, "a.env.enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", LockEnvFile, storage.ObjectsDir)
+	runGit(t, dir, "commit", "-q", "-m", "vault")
+
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	defer db.Close()
+	target := filepath.Join(t.TempDir(), storage.ObjectsDir)
+	if err := showObjects(db, dir, "HEAD", target); err != nil {
+		t.Fatalf("showObjects failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "a.env.enc")); err != nil || !bytes.Equal(data, committed) {
+		t.Errorf("Expected the committed object, got %v", err)
+	}
+}
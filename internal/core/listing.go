@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/glob"
+)
+
+// statusFilters maps ls --filter values to file statuses
+var statusFilters = map[string]string{
+	"modified":     "modified",
+	"unchanged":    "unchanged",
+	"vault-only":   "vault only",
+	"never-locked": "tracked, never locked",
+}
+
+// StatusColumns lists the extra columns ls can show, in display order. The
+// note column needs the password, as notes are encrypted.
+var StatusColumns = []string{"size", "mtime", "mode", "hash", "note"}
+
+// LongColumns are the columns shown by ls --long
+const LongColumns = "size,mtime,mode,note"
+
+// FilterFileStatuses returns the files whose status matches filter
+// (modified, unchanged, vault-only or never-locked). An empty filter keeps
+// every file.
+func FilterFileStatuses(files []FileStatus, filter string) ([]FileStatus, error) {
+	if filter == "" {
+		return files, nil
+	}
+	status, ok := statusFilters[filter]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q (use modified, unchanged, vault-only or never-locked)", filter)
+	}
+
+	result := make([]FileStatus, 0, len(files))
+	for _, f := range files {
+		if f.Status == status {
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// SortFileStatuses sorts files in place by name, size (largest first) or
+// mtime (most recent first). Ties are broken by name.
+func SortFileStatuses(files []FileStatus, by string) error {
+	var less func(a, b FileStatus) bool
+	switch by {
+	case "", "name":
+		less = func(a, b FileStatus) bool { return a.Path < b.Path }
+	case "size":
+		less = func(a, b FileStatus) bool {
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+			return a.Path < b.Path
+		}
+	case "mtime":
+		less = func(a, b FileStatus) bool {
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.After(b.ModTime)
+			}
+			return a.Path < b.Path
+		}
+	default:
+		return fmt.Errorf("unknown sort key %q (use name, size or mtime)", by)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return less(files[i], files[j]) })
+	return nil
+}
+
+// ParseStatusColumns parses a comma-separated list of ls columns, returning
+// them in display order without duplicates
+func ParseStatusColumns(spec string) ([]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		valid := false
+		for _, col := range StatusColumns {
+			if name == col {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown column %q (use %s)", name, strings.Join(StatusColumns, ", "))
+		}
+		selected[name] = true
+	}
+
+	var columns []string
+	for _, col := range StatusColumns {
+		if selected[col] {
+			columns = append(columns, col)
+		}
+	}
+	return columns, nil
+}
+
+// MatchFileStatuses returns the files matching any of patterns, by exact
+// path or glob (supports ** and {a,b}). No patterns keeps every file.
+func MatchFileStatuses(files []FileStatus, patterns []string) []FileStatus {
+	if len(patterns) == 0 {
+		return files
+	}
+
+	result := make([]FileStatus, 0, len(files))
+	for _, f := range files {
+		for _, pattern := range patterns {
+			normalized := filepath.ToSlash(pattern)
+			if f.Path == normalized || glob.Match(normalized, f.Path) {
+				result = append(result, f)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// HasChanges reports whether any of files is not unchanged: modified,
+// missing from the working tree (vault only) or tracked but never locked.
+// `lockenv status --exit-code` exits with status 1 when it does.
+func HasChanges(files []FileStatus) bool {
+	for _, f := range files {
+		if f.Status != "unchanged" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func listingFixture() []FileStatus {
+	now := time.Now()
+	return []FileStatus{
+		{Path: "b.env", Status: "modified", Size: 10, ModTime: now.Add(-time.Hour)},
+		{Path: "a.env", Status: "unchanged", Size: 300, ModTime: now.Add(-2 * time.Hour)},
+		{Path: "c.env", Status: "vault only", Size: 10, ModTime: now},
+		{Path: "d.env", Status: "tracked, never locked", Size: 5, ModTime: now.Add(-3 * time.Hour)},
+	}
+}
+
+func statusPaths(files []FileStatus) []string {
+	result := make([]string, len(files))
+	for i, f := range files {
+		result[i] = f.Path
+	}
+	return result
+}
+
+func TestFilterFileStatuses(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"", []string{"b.env", "a.env", "c.env", "d.env"}},
+		{"modified", []string{"b.env"}},
+		{"unchanged", []string{"a.env"}},
+		{"vault-only", []string{"c.env"}},
+		{"never-locked", []string{"d.env"}},
+	}
+	for _, tt := range tests {
+		got, err := FilterFileStatuses(listingFixture(), tt.filter)
+		if err != nil {
+			t.Fatalf("filter %q: %v", tt.filter, err)
+		}
+		if g := statusPaths(got); !slices.Equal(g, tt.want) {
+			t.Errorf("filter %q = %v, want %v", tt.filter, g, tt.want)
+		}
+	}
+
+	if _, err := FilterFileStatuses(listingFixture(), "deleted"); err == nil {
+		t.Error("expected error for unknown filter")
+	}
+}
+
+func TestSortFileStatuses(t *testing.T) {
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{"name", []string{"a.env", "b.env", "c.env", "d.env"}},
+		{"size", []string{"a.env", "b.env", "c.env", "d.env"}},
+		{"mtime", []string{"c.env", "b.env", "a.env", "d.env"}},
+	}
+	for _, tt := range tests {
+		files := listingFixture()
+		if err := SortFileStatuses(files, tt.by); err != nil {
+			t.Fatalf("sort %q: %v", tt.by, err)
+		}
+		if g := statusPaths(files); !slices.Equal(g, tt.want) {
+			t.Errorf("sort %q = %v, want %v", tt.by, g, tt.want)
+		}
+	}
+
+	if err := SortFileStatuses(listingFixture(), "hash"); err == nil {
+		t.Error("expected error for unknown sort key")
+	}
+}
+
+func TestParseStatusColumns(t *testing.T) {
+	got, err := ParseStatusColumns("hash, MTIME,hash,size")
+	if err != nil {
+		t.Fatalf("ParseStatusColumns failed: %v", err)
+	}
+	if want := []string{"size", "mtime", "hash"}; !slices.Equal(got, want) {
+		t.Errorf("columns = %v, want %v", got, want)
+	}
+
+	if _, err := ParseStatusColumns("size,owner"); err == nil {
+		t.Error("expected error for unknown column")
+	}
+}
+
+func TestMatchFileStatuses(t *testing.T) {
+	files := []FileStatus{
+		{Path: ".env"},
+		{Path: "config/dev.env"},
+		{Path: "config/nested/app.json"},
+		{Path: "certs/server.pem"},
+	}
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{nil, []string{".env", "config/dev.env", "config/nested/app.json", "certs/server.pem"}},
+		{[]string{"config/**"}, []string{"config/dev.env", "config/nested/app.json"}},
+		{[]string{".env", "**/*.pem"}, []string{".env", "certs/server.pem"}},
+		{[]string{"*.{env,json}"}, []string{".env"}},
+		{[]string{"missing/*"}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := statusPaths(MatchFileStatuses(files, tt.patterns)); !slices.Equal(got, tt.want) {
+			t.Errorf("patterns %v = %v, want %v", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestHasChanges(t *testing.T) {
+	files := listingFixture()
+	if !HasChanges(files) {
+		t.Error("expected changes in the fixture")
+	}
+	for _, pattern := range []string{"b.env", "c.env", "d.env"} {
+		if !HasChanges(MatchFileStatuses(files, []string{pattern})) {
+			t.Errorf("expected %s to count as a change", pattern)
+		}
+	}
+	if HasChanges(MatchFileStatuses(files, []string{"a.env"})) {
+		t.Error("expected an unchanged file not to count as a change")
+	}
+	if HasChanges(nil) {
+		t.Error("expected no changes without files")
+	}
+}
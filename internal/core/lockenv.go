@@ -1,21 +1,29 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/illarion/lockenv/internal/crypto"
 	"github.com/illarion/lockenv/internal/git"
+	"github.com/illarion/lockenv/internal/glob"
+	"github.com/illarion/lockenv/internal/logging"
 	"github.com/illarion/lockenv/internal/security"
 	"github.com/illarion/lockenv/internal/storage"
+	"golang.org/x/crypto/ssh"
 )
 
 const (
@@ -24,6 +32,14 @@ const (
 	FilePermSecure      = 0600 // File: owner rw only
 	MaxVaultCopies      = 100  // Max numbered .from-vault.N backups
 	passwordCheckString = "lockenv-password-check"
+
+	// DefaultMaxFileSize is the largest file lock and track accept unless
+	// max_file_size or --allow-large says otherwise
+	DefaultMaxFileSize = 100 << 20
+
+	// LargeBinarySize is the size above which LockFiles refuses binary files
+	// unless AllowBinary is set
+	LargeBinarySize = 1 << 20
 )
 
 var (
@@ -32,17 +48,47 @@ var (
 	ErrWrongPassword    = errors.New("wrong password")
 	ErrPasswordRequired = errors.New("password required")
 	ErrNoTrackedFiles   = errors.New("no files in vault")
+	ErrFileTooLarge     = errors.New("file too large")
+	ErrLargeBinary      = errors.New("large binary file")
+	ErrDotenvSyntax     = errors.New("invalid dotenv syntax")
 )
 
 // LockEnv manages encrypted file storage
 type LockEnv struct {
 	path      string
-	db        *storage.Storage
+	backend   string // Storage backend name, set with UseBackend; empty for storage.DefaultBackend
 	validator *security.PathValidator
+	key       *crypto.SecureBuffer          // Pre-derived vault key (from a session); skips password derivation
+	domains   map[string]*crypto.Encryptor  // Encryption domains unlocked with UseDomain
+	vaults    map[string]*LockEnv           // Referenced vaults opened with UseVault, by vault ID
+	opener    func(storage.Reference) error // Opens a referenced vault on first use, set with SetVaultOpener
+	preserve  PreserveLevel                 // File attributes unlock restores, set with Preserve
+	maxSize   int64                         // Largest file lock and track accept, 0 for no limit
+	binaries  bool                          // Lock binaries over LargeBinarySize, set with AllowBinary
+	siv       bool                          // Derive file nonces from the contents, set with SetDeterministic
+	strictEnv bool                          // Refuse to lock dotenv files with syntax issues, set with SetStrictDotenv
+	signers   []ssh.PublicKey               // Keys Unlock requires a manifest signature from, set with RequireSignature
+	merge     MergeOptions                  // Editor merge settings, set with SetMergeOptions
+	kdfTime   time.Duration                 // Key derivation time new KDFs are calibrated to, set with SetKDFTargetTime
+	nonSecret []string                      // Paths DiscoverSecretFiles treats as not secret, set with SetScanIgnore
+	notify    func(FileEvent)               // Receives notices outside operation results, set with SetNotify
+
+	mu     sync.Mutex      // Guards the fields below
+	handle storage.Backend // Open vault shared by operations, nil when closed
+	uses   int             // Operations using handle
+	holds  int             // Hold calls without a matching Release
 }
 
-// New creates a new LockEnv instance
+// New creates a new LockEnv instance for the vault nearest to path, found
+// with FindVaultDir. Without a vault in path or its parents, the instance is
+// for path itself, which is what Init needs.
 func New(path string) (*LockEnv, error) {
+	return NewAt(FindVaultDir(path))
+}
+
+// NewAt creates a new LockEnv instance for the vault in exactly path,
+// without looking in the parent directories
+func NewAt(path string) (*LockEnv, error) {
 	validator, err := security.New(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize path validator: %w", err)
@@ -51,19 +97,141 @@ func New(path string) (*LockEnv, error) {
 	return &LockEnv{
 		path:      filepath.Join(path, LockEnvFile),
 		validator: validator,
+		maxSize:   DefaultMaxFileSize,
 	}, nil
 }
 
+// AllowBinary makes later LockFiles calls accept binary files over
+// LargeBinarySize instead of skipping them
+func (l *LockEnv) AllowBinary(allow bool) {
+	l.binaries = allow
+}
+
+// SetKDFTargetTime makes later Init, ChangePassword and AddDomain calls
+// pick the PBKDF2 iterations so that deriving the key takes about d on this
+// machine, never fewer than crypto.DefaultIters. 0 keeps the default.
+func (l *LockEnv) SetKDFTargetTime(d time.Duration) {
+	l.kdfTime = d
+}
+
+// newKDF creates a KDF with a fresh salt and the iterations selected with
+// SetKDFTargetTime
+func (l *LockEnv) newKDF() (*crypto.KDF, error) {
+	kdf, err := crypto.NewKDF()
+	if err != nil || l.kdfTime <= 0 {
+		return kdf, err
+	}
+	kdf.Iterations = crypto.CalibrateIterations(l.kdfTime)
+	return kdf, nil
+}
+
+// SetMergeOptions configures the [e] option of interactive conflict
+// resolution in later Unlock, MergeVault and StashPop calls. TempDir may be
+// MergeTempRepo for the project root; relative paths start there.
+func (l *LockEnv) SetMergeOptions(opts MergeOptions) {
+	l.merge = opts
+}
+
+// mergeOptions returns the options set with SetMergeOptions with TempDir
+// resolved against the project root, reporting to the SetNotify function
+func (l *LockEnv) mergeOptions() MergeOptions {
+	opts := l.merge
+	opts.notice = l.notice
+	if opts.TempDir == MergeTempRepo {
+		opts.TempDir = filepath.Dir(l.path)
+	} else if opts.TempDir != "" && !filepath.IsAbs(opts.TempDir) {
+		opts.TempDir = filepath.Join(filepath.Dir(l.path), opts.TempDir)
+	}
+	return opts
+}
+
+// SetMaxFileSize sets the largest file later LockFiles, TrackFiles and
+// StoreVaultFile calls accept; larger files are reported as failed. 0
+// removes the limit.
+func (l *LockEnv) SetMaxFileSize(size int64) {
+	l.maxSize = size
+}
+
+// SetDeterministic makes later locks derive each file's nonce from its
+// contents, so relocking content the vault held before yields the same
+// ciphertext. It leaks only whether two versions of a file are equal.
+func (l *LockEnv) SetDeterministic(deterministic bool) {
+	l.siv = deterministic
+}
+
+// SetStrictDotenv makes later FinalizeLock calls fail instead of warn when
+// a dotenv file being locked has syntax issues
+func (l *LockEnv) SetStrictDotenv(strict bool) {
+	l.strictEnv = strict
+}
+
+// SetNotify makes later operations pass notify the notices that belong to
+// no single result, such as the backup made before rewriting the vault or
+// a warning found while reading the metadata. Without it they are dropped.
+func (l *LockEnv) SetNotify(notify func(FileEvent)) {
+	l.notify = notify
+}
+
+// notice hands event to the function set with SetNotify
+func (l *LockEnv) notice(event FileEvent) {
+	if l.notify != nil {
+		l.notify(event)
+	}
+}
+
+// encryptFile encrypts file contents with enc, deterministically if
+// SetDeterministic is on, stopping early if ctx is done
+func (l *LockEnv) encryptFile(ctx context.Context, enc *crypto.Encryptor, data []byte) ([]byte, error) {
+	if l.siv {
+		return enc.EncryptDeterministicContext(ctx, data)
+	}
+	return enc.EncryptContext(ctx, data)
+}
+
+// UseBackend makes the LockEnv open its vault with the named storage backend
+// instead of storage.DefaultBackend
+func (l *LockEnv) UseBackend(name string) error {
+	if !slices.Contains(storage.Backends(), name) {
+		return fmt.Errorf("unknown storage backend %q (available: %s)", name, strings.Join(storage.Backends(), ", "))
+	}
+	l.backend = name
+	return nil
+}
+
+// exists reports whether the selected storage backend has a vault at l.path
+func (l *LockEnv) exists() bool {
+	return storage.Exists(l.backend, l.path)
+}
+
+// openAt opens the vault at path, such as a temporary copy, with the
+// selected storage backend
+func (l *LockEnv) openAt(path string) (storage.Backend, error) {
+	return storage.OpenBackend(l.backend, path)
+}
+
 // Close releases resources held by the LockEnv instance
 func (l *LockEnv) Close() error {
+	closeErr := l.closeHandle()
+	l.key.Close()
+	l.key = nil
+	for _, enc := range l.domains {
+		enc.Destroy()
+	}
+	l.domains = nil
+	for _, vault := range l.vaults {
+		vault.Close()
+	}
+	l.vaults = nil
 	if l.validator != nil {
-		return l.validator.Close()
+		if err := l.validator.Close(); err != nil {
+			return err
+		}
 	}
-	return nil
+	return closeErr
 }
 
 // updateManifestEntry updates a manifest entry
-func (l *LockEnv) updateManifestEntry(db *storage.Storage, path string, size int64, modTime time.Time, hash string) error {
+func (l *LockEnv) updateManifestEntry(db storage.Backend, path string, size int64, modTime time.Time, hash string) error {
 	return db.UpdateManifest(path, size, modTime, hash)
 }
 
@@ -91,20 +259,27 @@ func secureFileMode(mode uint32) os.FileMode {
 	return secure
 }
 
-// lockSingleFile validates and adds one file to the vault.
-// Prints warnings for skipped files, returns error only for fatal failures.
-func (l *LockEnv) lockSingleFile(db *storage.Storage, file string, metadata *storage.Metadata) error {
+// lockSingleFile validates and adds one file to the vault, recording it in
+// report as locked, skipped or failed. With sealing set (lock rather than
+// track), large binaries are skipped unless AllowBinary was called. Returns an
+// error only for fatal failures.
+func (l *LockEnv) lockSingleFile(db storage.Backend, file string, metadata *storage.Metadata, report *LockReport, sealing bool) error {
 	// Convert absolute paths to relative
 	inputPath, err := l.normalizeToRelative(file)
 	if err != nil {
-		fmt.Printf("error: %v\n", err)
+		report.fail(file, err)
 		return nil
 	}
 
 	// Validate path to ensure it's within repository
 	validPath, err := l.validator.ValidateAndNormalize(inputPath)
 	if err != nil {
-		fmt.Printf("error: invalid path %s: %v\n", file, err)
+		report.fail(file, fmt.Errorf("invalid path: %w", err))
+		return nil
+	}
+
+	if existing := metadata.FindFile(validPath); existing != nil && existing.Ref != nil {
+		report.skip(validPath, ErrReference)
 		return nil
 	}
 
@@ -113,24 +288,43 @@ func (l *LockEnv) lockSingleFile(db *storage.Storage, file string, metadata *sto
 	platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
 	info, err := os.Stat(platformPath)
 	if err != nil {
-		fmt.Printf("warning: cannot access %s: %v\n", validPath, err)
+		report.fail(validPath, fmt.Errorf("cannot access: %w", err))
 		return nil
 	}
 
 	if info.IsDir() {
-		fmt.Printf("warning: skipping directory %s\n", validPath)
+		report.skip(validPath, ErrIsDirectory)
+		return nil
+	}
+	if l.maxSize > 0 && info.Size() > l.maxSize {
+		report.fail(validPath, fmt.Errorf("%w: %s exceeds the %s limit (use --allow-large or raise max_file_size in %s)",
+			ErrFileTooLarge, FormatSize(info.Size()), FormatSize(l.maxSize), ConfigFile))
 		return nil
 	}
 
 	// Read and hash file content for accurate change detection
-	content, err := os.ReadFile(platformPath)
+	content, err := readSecureFile(platformPath)
 	if err != nil {
-		fmt.Printf("warning: cannot read %s: %v\n", validPath, err)
+		report.fail(validPath, fmt.Errorf("cannot read: %w", err))
+		return nil
+	}
+	// Every change to a binary adds a full encrypted copy to git history,
+	// which cannot be delta-compressed
+	if sealing && !l.binaries && info.Size() > LargeBinarySize && !DetectFileType(content.Borrow()) {
+		content.Close()
+		report.skip(validPath, fmt.Errorf("%w (%s): each change adds a full copy to git history; consider external storage such as Git LFS, or use --force",
+			ErrLargeBinary, FormatSize(info.Size())))
 		return nil
 	}
-	hashBytes := sha256.Sum256(content)
+	hashBytes := sha256.Sum256(content.Borrow())
 	hashStr := hex.EncodeToString(hashBytes[:])
-	crypto.ClearBytes(content)
+	content.Close()
+
+	// Identical content keeps its entry; FinalizeLock skips sealing it again
+	if existing := metadata.FindFile(validPath); existing != nil && existing.Hash == hashStr && existing.Mode == uint32(info.Mode()) {
+		report.Locked = append(report.Locked, validPath)
+		return nil
+	}
 
 	// Add to metadata
 	metadata.AddFile(storage.FileEntry{
@@ -146,24 +340,29 @@ func (l *LockEnv) lockSingleFile(db *storage.Storage, file string, metadata *sto
 		return fmt.Errorf("failed to update manifest: %w", err)
 	}
 
-	fmt.Printf("locking: %s\n", validPath)
+	report.Locked = append(report.Locked, validPath)
 	return nil
 }
 
 // getManifestEntries retrieves manifest entries
-func (l *LockEnv) getManifestEntries(db *storage.Storage) ([]storage.ManifestEntry, error) {
+func (l *LockEnv) getManifestEntries(db storage.Backend) ([]storage.ManifestEntry, error) {
 	return db.GetManifest()
 }
 
 // Init initializes a new .lockenv file
 func (l *LockEnv) Init(password []byte) error {
+	return l.initFormat(password, storage.CurrentFormat)
+}
+
+// initFormat initializes a new .lockenv file in the given format version
+func (l *LockEnv) initFormat(password []byte, format int) error {
 	// Check if already exists
-	if _, err := os.Stat(l.path); err == nil {
+	if l.exists() {
 		return ErrAlreadyExists
 	}
 
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
 		return fmt.Errorf("failed to create database: %w", err)
 	}
@@ -173,9 +372,14 @@ func (l *LockEnv) Init(password []byte) error {
 	if err := db.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
+	if format != storage.CurrentFormat {
+		if err := db.SetFormatVersion(format); err != nil {
+			return fmt.Errorf("failed to store format version: %w", err)
+		}
+	}
 
 	// Create KDF
-	kdf, err := crypto.NewKDF()
+	kdf, err := l.newKDF()
 	if err != nil {
 		return fmt.Errorf("failed to create KDF: %w", err)
 	}
@@ -189,22 +393,33 @@ func (l *LockEnv) Init(password []byte) error {
 	}
 
 	// Derive key
-	key := kdf.DeriveKey(password)
-	defer crypto.ClearBytes(key)
+	key := crypto.AdoptSecure(kdf.DeriveKey(password))
+	defer key.Close()
 
 	// Create encryptor
-	enc := crypto.NewEncryptor(key)
+	enc := crypto.NewEncryptor(key.Borrow())
 	defer enc.Destroy()
 
-	// Create and store password verification checksum
-	checksum := sha256.Sum256([]byte(passwordCheckString))
-	checksumData, err := enc.Encrypt([]byte(hex.EncodeToString(checksum[:])))
-	if err != nil {
-		return fmt.Errorf("failed to encrypt checksum: %w", err)
+	// Generate the data key that encrypts everything below
+	if format >= storage.FormatV2 {
+		dataKey, err := crypto.GenerateRandom(crypto.KeySize)
+		if err != nil {
+			return err
+		}
+		if err := wrapDataKey(db, enc, dataKey); err != nil {
+			crypto.ClearBytes(dataKey)
+			return err
+		}
+		enc, err = dataEncryptor(format, dataKey)
+		if err != nil {
+			return err
+		}
+		defer enc.Destroy()
 	}
 
-	if err := db.StoreMetadataBytes("checksum", checksumData); err != nil {
-		return fmt.Errorf("failed to store checksum: %w", err)
+	// Create and store password verifier
+	if err := storePasswordVerifier(db, format, enc); err != nil {
+		return err
 	}
 
 	// Create empty metadata
@@ -214,7 +429,7 @@ func (l *LockEnv) Init(password []byte) error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	encryptedMetadata, err := enc.Encrypt(metadataJSON)
+	encryptedMetadata, err := enc.For(crypto.PurposeMetadata).Encrypt(metadataJSON)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt metadata: %w", err)
 	}
@@ -223,36 +438,59 @@ func (l *LockEnv) Init(password []byte) error {
 		return fmt.Errorf("failed to store metadata: %w", err)
 	}
 
+	if format >= storage.FormatV2 {
+		return storeIndexMAC(db, enc)
+	}
 	return nil
 }
 
 // LockFiles adds files to the tracking list using the CLI "lock" terminology.
-func (l *LockEnv) LockFiles(ctx context.Context, patterns []string, password []byte) error {
+// The report's Locked lists the stored paths of the files that were added, so
+// callers can pass them to FinalizeLock to encrypt only those entries.
+// With recursive set, directories are tracked with all their files (honoring
+// .lockenvignore) and remembered so later scans pick up new files.
+func (l *LockEnv) LockFiles(ctx context.Context, patterns []string, password []byte, recursive bool) (*LockReport, error) {
+	return l.addFiles(ctx, patterns, password, recursive, true)
+}
+
+// TrackFiles adds files to the vault's metadata and manifest without
+// encrypting their content (implements `lockenv track`). Status reports them
+// as never locked until a later lock or FinalizeLock seals them.
+func (l *LockEnv) TrackFiles(ctx context.Context, patterns []string, password []byte, recursive bool) (*LockReport, error) {
+	return l.addFiles(ctx, patterns, password, recursive, false)
+}
+
+// addFiles implements LockFiles and TrackFiles
+func (l *LockEnv) addFiles(ctx context.Context, patterns []string, password []byte, recursive bool, sealing bool) (*LockReport, error) {
 	if err := ctx.Err(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return ErrNotInitialized
+		return nil, openError(err)
 	}
 	defer db.Close()
-	l.db = db
 
 	// Read current metadata
-	metadata, enc, err := l.readMetadata(password)
+	metadata, enc, err := l.readMetadata(ctx, db, password)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer enc.Destroy()
 
 	repoRoot := filepath.Dir(l.path)
+	report := &LockReport{}
+	before, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
 
 	// Track new files
 	for _, pattern := range patterns {
 		if err := ctx.Err(); err != nil {
-			return err
+			return nil, err
 		}
 
 		// Glob relative to repo root, not CWD
@@ -261,9 +499,9 @@ func (l *LockEnv) LockFiles(ctx context.Context, patterns []string, password []b
 			absPattern = filepath.Join(repoRoot, pattern)
 		}
 
-		matches, err := filepath.Glob(absPattern)
+		matches, err := glob.Glob(absPattern)
 		if err != nil {
-			return fmt.Errorf("invalid pattern %s: %w", pattern, err)
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
 		}
 
 		if len(matches) == 0 {
@@ -276,38 +514,70 @@ func (l *LockEnv) LockFiles(ctx context.Context, patterns []string, password []b
 		}
 
 		for _, file := range matches {
-			if err := l.lockSingleFile(db, file, metadata); err != nil {
-				return err
+			if recursive {
+				if info, err := os.Stat(file); err == nil && info.IsDir() {
+					if err := l.lockDirectory(db, file, metadata, report, sealing); err != nil {
+						return nil, err
+					}
+					continue
+				}
+			}
+
+			if err := l.lockSingleFile(db, file, metadata, report, sealing); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	// Save updated metadata
-	return l.saveMetadata(metadata, enc)
+	// Save updated metadata, unless only identical files were given: saving
+	// re-encrypts it and would change the committed vault for nothing
+	if after, err := json.Marshal(metadata); err == nil && bytes.Equal(before, after) {
+		return report, nil
+	}
+	if err := l.saveMetadata(db, metadata, enc); err != nil {
+		return nil, err
+	}
+	return report, nil
 }
 
-func (l *LockEnv) FinalizeLock(ctx context.Context, password []byte, remove bool) error {
+// FinalizeLock encrypts tracked files into the vault.
+// If paths is non-empty, only those entries are re-encrypted; blobs and hashes
+// of all other tracked files are left untouched. Files that cannot be read
+// are reported as failed and left as they were in the vault. Files whose
+// content and mode match what the vault already holds are not encrypted
+// again, so an unchanged vault stays byte for byte the same; they are
+// reported as unchanged (and still removed with remove set).
+func (l *LockEnv) FinalizeLock(ctx context.Context, password []byte, remove bool, paths []string) (*LockReport, error) {
 	if err := ctx.Err(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return ErrNotInitialized
+		return nil, openError(err)
 	}
 	defer db.Close()
-	l.db = db
 
 	// Read metadata with password
-	metadata, enc, err := l.readMetadata(password)
+	metadata, enc, err := l.readMetadata(ctx, db, password)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer enc.Destroy()
 
 	if len(metadata.Files) == 0 {
-		return ErrNoTrackedFiles
+		return nil, ErrNoTrackedFiles
+	}
+	report := &LockReport{}
+
+	// Restrict to the requested entries when paths are given
+	var selected map[string]bool
+	if len(paths) > 0 {
+		selected = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			selected[p] = true
+		}
 	}
 
 	// Two-phase approach for atomicity:
@@ -318,133 +588,235 @@ func (l *LockEnv) FinalizeLock(ctx context.Context, password []byte, remove bool
 		index     int
 		path      string
 		absPath   string
-		encrypted []byte
+		encrypted *crypto.SecureBuffer
 		hash      string
 		size      int64
 		mode      uint32
 		modTime   time.Time
+		owner     *storage.Ownership
+		xattrs    map[string][]byte
+		format    string
 	}
 
 	repoRoot := filepath.Dir(l.path)
 	var pending []pendingFile
+	var unchanged []pendingFile
+	var rejected int // Dotenv files with syntax issues, with strictEnv set
+	// Wipe pending encrypted data on all exit paths
+	defer func() {
+		for _, p := range pending {
+			p.encrypted.Close()
+		}
+	}()
 
 	// Phase 1: Read and encrypt all files
 	for i := range metadata.Files {
 		if err := ctx.Err(); err != nil {
-			// Clear any pending encrypted data
-			for _, p := range pending {
-				crypto.ClearBytes(p.encrypted)
-			}
-			return err
+			return nil, err
 		}
 
 		file := &metadata.Files[i]
+		if selected != nil && !selected[file.Path] {
+			continue
+		}
+		// The content of a reference is sealed in its own vault
+		if file.Ref != nil {
+			continue
+		}
+		fileEnc, err := l.fileEncryptor(file, enc)
+		if err != nil {
+			report.skip(file.Path, err)
+			continue
+		}
 		absPath := filepath.Join(repoRoot, filepath.FromSlash(file.Path))
 
 		// Read file
-		data, err := os.ReadFile(absPath)
+		data, err := readSecureFile(absPath)
 		if err != nil {
-			fmt.Printf("warning: cannot read %s: %v\n", file.Path, err)
+			report.fail(file.Path, fmt.Errorf("cannot read: %w", err))
 			continue
 		}
 
 		// Get file info
 		info, err := os.Stat(absPath)
 		if err != nil {
-			crypto.ClearBytes(data)
-			fmt.Printf("warning: cannot stat %s: %v\n", file.Path, err)
+			data.Close()
+			report.fail(file.Path, fmt.Errorf("cannot stat: %w", err))
 			continue
 		}
 
 		// Calculate hash
-		hashBytes := sha256.Sum256(data)
+		hashBytes := sha256.Sum256(data.Borrow())
 		hashStr := hex.EncodeToString(hashBytes[:])
 
-		// Encrypt
-		encryptedData, err := enc.Encrypt(data)
-		crypto.ClearBytes(data)
+		xattrs, err := readXattrs(absPath)
 		if err != nil {
-			// Clear any pending encrypted data on failure
-			for _, p := range pending {
-				crypto.ClearBytes(p.encrypted)
+			logging.Debug("cannot read extended attributes", "path", file.Path, "error", err)
+		}
+		owner := fileOwner(info)
+
+		// Already sealed with the same attributes: a new nonce would only
+		// churn the committed vault
+		if hashStr == file.Hash && uint32(info.Mode()) == file.Mode && sameOwner(owner, file.Owner) &&
+			maps.EqualFunc(xattrs, file.Xattrs, bytes.Equal) && l.isSealed(ctx, db, file, enc) {
+			data.Close()
+			logging.Debug("unchanged, skipped", "path", file.Path)
+			report.Unchanged = append(report.Unchanged, file.Path)
+			unchanged = append(unchanged, pendingFile{path: file.Path, absPath: absPath})
+			continue
+		}
+
+		// Dotenv syntax issues are warnings, or stop the lock if strict
+		format := DetectFormat(file.Path)
+		if format == FormatDotenv {
+			issues := ValidateDotenv(data.Borrow())
+			for _, issue := range issues {
+				if l.strictEnv {
+					report.fail(file.Path, fmt.Errorf("%w: %s", ErrDotenvSyntax, issue))
+				} else {
+					report.warn(file.Path, fmt.Errorf("%w: %s", ErrDotenvSyntax, issue))
+				}
 			}
-			return fmt.Errorf("failed to encrypt %s: %w", file.Path, err)
+			if l.strictEnv && len(issues) > 0 {
+				data.Close()
+				rejected++
+				continue
+			}
+		}
+
+		// Encrypt
+		encryptedData, err := l.encryptFile(ctx, fileEnc, data.Borrow())
+		data.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", file.Path, err)
 		}
 
 		pending = append(pending, pendingFile{
 			index:     i,
 			path:      file.Path,
 			absPath:   absPath,
-			encrypted: encryptedData,
+			encrypted: crypto.AdoptSecure(encryptedData),
 			hash:      hashStr,
 			size:      info.Size(),
 			mode:      uint32(info.Mode()),
 			modTime:   info.ModTime(),
+			owner:     owner,
+			xattrs:    xattrs,
+			format:    format,
 		})
 	}
 
-	if len(pending) == 0 {
-		return fmt.Errorf("no files could be processed")
+	if rejected > 0 {
+		return report, fmt.Errorf("%w in %d files: fix them or lock without --strict", ErrDotenvSyntax, rejected)
+	}
+
+	if len(pending) == 0 && len(unchanged) == 0 {
+		if err := report.Err(); err != nil {
+			return report, fmt.Errorf("no files could be processed: %w", err)
+		}
+		return report, fmt.Errorf("no files could be processed")
+	}
+
+	// Phase 2: Store to DB and update metadata (only after all encryptions succeed).
+	// The metadata is updated first and journaled with the stored data's
+	// digests: if storing stops halfway, the next open keeps the new
+	// metadata entries of the files whose data was replaced.
+	journal := &LockJournal{Started: time.Now()}
+	for _, p := range pending {
+		file := &metadata.Files[p.index]
+		file.Hash = p.hash
+		file.Size = p.size
+		file.Mode = p.mode
+		file.ModTime = p.modTime
+		file.Owner = p.owner
+		file.Xattrs = p.xattrs
+		file.Format = p.format
+		file.RecordLock(p.hash, journal.Started)
+
+		blob := sha256.Sum256(p.encrypted.Borrow())
+		journal.Files = append(journal.Files, JournalEntry{
+			Path:    p.path,
+			Blob:    hex.EncodeToString(blob[:]),
+			Size:    p.size,
+			ModTime: p.modTime,
+			Hash:    p.hash,
+		})
+	}
+	if len(pending) > 0 {
+		metadata.Modified = journal.Started
+		if journal.Metadata, err = sealMetadata(metadata, enc); err != nil {
+			return nil, err
+		}
+		if err := putRecord(db, recordJournal, journal); err != nil {
+			return nil, fmt.Errorf("failed to write lock journal: %w", err)
+		}
 	}
 
-	// Phase 2: Store to DB and update metadata (only after all encryptions succeed)
-	var processedFiles []string
+	var processedFiles []pendingFile
 	for _, p := range pending {
 		if err := ctx.Err(); err != nil {
-			// Clear remaining encrypted data
-			for j := range pending {
-				crypto.ClearBytes(pending[j].encrypted)
-			}
-			return err
+			return nil, err
 		}
 
 		// Store encrypted data
-		if err := db.StoreFileData(p.path, p.encrypted); err != nil {
-			crypto.ClearBytes(p.encrypted)
-			return fmt.Errorf("failed to store %s: %w", p.path, err)
+		if err := db.StoreFileData(ctx, p.path, p.encrypted.Borrow()); err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", p.path, err)
 		}
 
 		// Update manifest (fail fast instead of warning)
 		if err := l.updateManifestEntry(db, p.path, p.size, p.modTime, p.hash); err != nil {
-			return fmt.Errorf("failed to update manifest for %s: %w", p.path, err)
+			return nil, fmt.Errorf("failed to update manifest for %s: %w", p.path, err)
 		}
+		logging.Info("locked file", "path", p.path, "size", p.size)
 
-		// Now update metadata
-		file := &metadata.Files[p.index]
-		file.Hash = p.hash
-		file.Size = p.size
-		file.Mode = p.mode
-		file.ModTime = p.modTime
-
-		crypto.ClearBytes(p.encrypted)
-		processedFiles = append(processedFiles, p.absPath)
-		fmt.Printf("encrypted: %s\n", p.path)
-	}
-
-	// Save updated metadata
-	metadata.Modified = time.Now()
-	if err := l.saveMetadata(metadata, enc); err != nil {
-		return err
+		p.encrypted.Close()
+		processedFiles = append(processedFiles, p)
+		report.Locked = append(report.Locked, p.path)
 	}
 
-	// Update modification time
-	if err := db.UpdateModified(); err != nil {
-		fmt.Printf("warning: failed to update modification time: %v\n", err)
+	// Save updated metadata, which also records the change
+	if len(processedFiles) > 0 {
+		if err := l.saveMetadata(db, metadata, enc); err != nil {
+			return nil, err
+		}
+		if err := db.DeleteConfig(recordJournal); err != nil {
+			return nil, fmt.Errorf("failed to clear lock journal: %w", err)
+		}
 	}
 
 	// Remove original files if requested
 	if remove {
-		for _, file := range processedFiles {
-			if err := os.Remove(file); err != nil {
-				fmt.Printf("warning: cannot remove %s: %v\n", file, err)
+		for _, p := range append(processedFiles, unchanged...) {
+			if err := os.Remove(p.absPath); err != nil {
+				report.fail(p.path, fmt.Errorf("cannot remove: %w", err))
 			} else {
-				fmt.Printf("removed: %s\n", file)
+				report.Removed = append(report.Removed, p.path)
 			}
 		}
 	}
 
-	fmt.Printf("locked: %d files into %s\n", len(processedFiles), LockEnvFile)
-	return nil
+	return report, nil
+}
+
+// sameOwner reports whether two recorded owners are equal; nil means none
+// was recorded
+func sameOwner(a, b *storage.Ownership) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// isSealed reports whether the vault holds file's content under its current
+// key. A file moved into another domain still has a blob under the old key
+// and must be sealed again.
+func (l *LockEnv) isSealed(ctx context.Context, db storage.Backend, file *storage.FileEntry, enc *crypto.Encryptor) bool {
+	plain, err := l.decryptEntry(ctx, db, file, enc)
+	if err != nil {
+		return false
+	}
+	plain.Close()
+	return true
 }
 
 // Unlock extracts files with smart conflict resolution (implements `lockenv unlock`).
@@ -454,29 +826,34 @@ func (l *LockEnv) Unlock(ctx context.Context, password []byte, strategy MergeStr
 		return nil, err
 	}
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return nil, ErrNotInitialized
+		return nil, openError(err)
 	}
 	defer db.Close()
-	l.db = db
 
 	// Read metadata with password
-	metadata, enc, err := l.readMetadata(password)
+	metadata, enc, err := l.readMetadata(ctx, db, password)
 	if err != nil {
 		return nil, err
 	}
 	defer enc.Destroy()
 
+	// unlockFile checks contents against the stored hash, so requiring it to
+	// match the signed manifest extends the signature to the contents
+	var signed map[string]string
+	if l.signers != nil {
+		if signed, err = l.signedHashes(db); err != nil {
+			return nil, err
+		}
+	}
+
 	result := &UnlockResult{
 		Extracted: []string{},
 		Skipped:   []string{},
 		Errors:    []string{},
 	}
 
-	// Get repository root for path operations
-	repoRoot := filepath.Dir(l.path)
-
 	// Filter files if patterns provided
 	filesToUnlock := metadata.Files
 	if len(patterns) > 0 {
@@ -487,212 +864,262 @@ func (l *LockEnv) Unlock(ctx context.Context, password []byte, strategy MergeStr
 	}
 
 	// Extract each file
+	refsChanged := false
 	for _, file := range filesToUnlock {
 		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
-		// Read encrypted file data
-		encryptedData, err := db.GetFileData(file.Path)
-		if err != nil {
-			msg := fmt.Sprintf("%s: cannot read from storage: %v", file.Path, err)
-			result.Errors = append(result.Errors, msg)
-			fmt.Printf("error: %s\n", msg)
+		if file.Ref != nil {
+			if l.unlockReference(ctx, db, metadata, file, strategy, result) {
+				refsChanged = true
+			}
 			continue
 		}
-
-		// Decrypt file
-		sealedData, err := enc.Decrypt(encryptedData)
+		fileEnc, err := l.fileEncryptor(&file, enc)
 		if err != nil {
-			msg := fmt.Sprintf("%s: cannot decrypt: %v", file.Path, err)
-			result.Errors = append(result.Errors, msg)
-			fmt.Printf("error: %s\n", msg)
+			result.skip(file.Path, "domain "+file.Domain+" is locked")
 			continue
 		}
-
-		// Verify hash
-		hash := sha256.Sum256(sealedData)
-		if hex.EncodeToString(hash[:]) != file.Hash {
-			crypto.ClearBytes(sealedData)
-			msg := fmt.Sprintf("%s: failed integrity check", file.Path)
-			result.Errors = append(result.Errors, msg)
-			fmt.Printf("error: %s\n", msg)
+		if sealed, err := db.HasFileData(file.Path); err == nil && !sealed {
+			result.skip(file.Path, "tracked, never locked")
 			continue
 		}
-
-		// Validate path from vault to prevent path traversal attacks
-		validPath, err := l.validator.ValidateExistingPath(file.Path)
-		if err != nil {
-			crypto.ClearBytes(sealedData)
-			msg := fmt.Sprintf("%s: invalid path from vault: %v", file.Path, err)
-			result.Errors = append(result.Errors, msg)
-			fmt.Printf("error: %s\n", msg)
+		if signed != nil && signed[file.Path] != file.Hash {
+			result.fail(file.Path, errors.New("not covered by the manifest signature"))
 			continue
 		}
 
-		// Check if local file exists (use absolute path with repoRoot)
-		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
-		localData, err := os.ReadFile(platformPath)
-		fileExists := err == nil
-
-		if fileExists {
-			// Compare files
-			if CompareFiles(localData, sealedData) {
-				// Files are identical, skip
-				crypto.ClearBytes(sealedData)
-				crypto.ClearBytes(localData)
-				result.Skipped = append(result.Skipped, validPath)
-				fmt.Printf("skipped: %s (unchanged)\n", validPath)
-				continue
-			}
+		l.unlockFile(ctx, db, file, fileEnc, strategy, result)
+	}
+	result.MissingRequired = l.missingRequired(metadata.Files, result, true)
 
-			// Files differ - handle conflict
-			conflictResult, err := HandleConflict(validPath, localData, sealedData, strategy)
-			if err != nil {
-				crypto.ClearBytes(sealedData)
-				crypto.ClearBytes(localData)
-				result.Errors = append(result.Errors, err.Error())
-				fmt.Printf("error: %s\n", err.Error())
-				continue
-			}
+	if refsChanged {
+		if err := l.saveMetadata(db, metadata, enc); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
 
-			switch conflictResult.Resolution {
-			case ResolutionKeepLocal:
-				crypto.ClearBytes(sealedData)
-				crypto.ClearBytes(localData)
-				result.Skipped = append(result.Skipped, validPath)
-				fmt.Printf("skipped: %s (kept local version)\n", validPath)
-				continue
-			case ResolutionSkip:
-				crypto.ClearBytes(sealedData)
-				crypto.ClearBytes(localData)
-				result.Skipped = append(result.Skipped, validPath)
-				fmt.Printf("skipped: %s\n", validPath)
-				continue
-			case ResolutionEditMerged:
-				// Clear original decrypted data before using merged data
-				crypto.ClearBytes(sealedData)
-				crypto.ClearBytes(localData)
-				// Use the merged data from editor
-				sealedData = conflictResult.MergedData
-			case ResolutionKeepBoth:
-				// Save vault version with .from-vault suffix
-				vaultPath := validPath + ".from-vault"
-
-				// Check if .from-vault file already exists
-				vaultPlatformPath := filepath.Join(repoRoot, filepath.FromSlash(vaultPath))
-				foundSlot := true
-				if _, err := os.Stat(vaultPlatformPath); err == nil {
-					// File exists, find available numbered suffix
-					foundSlot = false
-					for i := 1; i < MaxVaultCopies; i++ {
-						vaultPath = fmt.Sprintf("%s.from-vault.%d", validPath, i)
-						vaultPlatformPath = filepath.Join(repoRoot, filepath.FromSlash(vaultPath))
-						if _, err := os.Stat(vaultPlatformPath); os.IsNotExist(err) {
-							foundSlot = true
-							break
-						}
-					}
-				}
+// unlockReference reads a reference from its vault and writes it to the
+// working tree like unlockFile. Content changed in the other vault since it
+// was recorded updates the entry in metadata; reports whether it did.
+func (l *LockEnv) unlockReference(ctx context.Context, db storage.Backend, metadata *storage.Metadata, file storage.FileEntry, strategy MergeStrategy, result *UnlockResult) bool {
+	plain, err := l.resolveReference(ctx, &file)
+	if errors.Is(err, ErrVaultNotOpened) {
+		result.skip(file.Path, "vault "+file.Ref.VaultID+" is not opened")
+		return false
+	}
+	if err != nil {
+		result.fail("", err)
+		return false
+	}
+	defer plain.Close()
+	l.extractFile(file, plain.Borrow(), strategy, result)
 
-				// Error if all backup slots exhausted
-				if !foundSlot {
-					crypto.ClearBytes(sealedData)
-					crypto.ClearBytes(localData)
-					msg := fmt.Sprintf("%s: too many backup copies (max %d)", validPath, MaxVaultCopies)
-					result.Errors = append(result.Errors, msg)
-					fmt.Printf("error: %s\n", msg)
-					continue
-				}
+	hash := sha256.Sum256(plain.Borrow())
+	hashStr := hex.EncodeToString(hash[:])
+	if hashStr == file.Hash {
+		return false
+	}
+	entry := metadata.FindFile(file.Path)
+	entry.Hash, entry.Size = hashStr, int64(plain.Len())
+	if err := l.updateManifestEntry(db, entry.Path, entry.Size, entry.ModTime, entry.Hash); err != nil {
+		result.Events.addErr(EventWarning, file.Path, fmt.Errorf("cannot update manifest: %w", err))
+	}
+	return true
+}
 
-				// Validate the vault copy path
-				if _, err := l.validator.ValidateAndNormalize(vaultPath); err != nil {
-					crypto.ClearBytes(sealedData)
-					crypto.ClearBytes(localData)
-					msg := fmt.Sprintf("%s: invalid vault copy path: %v", vaultPath, err)
-					result.Errors = append(result.Errors, msg)
-					fmt.Printf("error: %s\n", msg)
-					continue
-				}
+// unlockFile decrypts a single vault entry and writes it to the working
+// tree, resolving conflicts with strategy and recording the outcome in result.
+// Decrypted, local and merged contents are held in SecureBuffers and wiped on
+// return.
+func (l *LockEnv) unlockFile(ctx context.Context, db storage.Backend, file storage.FileEntry, fileEnc *crypto.Encryptor, strategy MergeStrategy, result *UnlockResult) {
+	// Read encrypted file data
+	encryptedData, err := db.GetFileData(ctx, file.Path)
+	if err != nil {
+		result.fail(file.Path, fmt.Errorf("cannot read from storage: %w", err))
+		return
+	}
 
-				// Create directory for vault copy if needed using secure operation
-				vaultDir := filepath.Dir(vaultPath)
-				if vaultDir != "." && vaultDir != "/" {
-					if err := l.validator.MkdirAllInRoot(vaultDir, DirPermSecure); err != nil {
-						crypto.ClearBytes(sealedData)
-						crypto.ClearBytes(localData)
-						msg := fmt.Sprintf("%s: cannot create directory for vault copy: %v", vaultPath, err)
-						result.Errors = append(result.Errors, msg)
-						fmt.Printf("error: %s\n", msg)
-						continue
-					}
-				}
+	// Decrypt file into locked memory
+	plain, err := fileEnc.DecryptSecureContext(ctx, encryptedData)
+	if err != nil {
+		result.fail(file.Path, fmt.Errorf("cannot decrypt: %w", err))
+		return
+	}
+	defer plain.Close()
+	sealedData := plain.Borrow()
 
-				// Write vault version to alternate path using secure operation
-				if err := l.validator.WriteFileInRoot(vaultPath, sealedData, secureFileMode(file.Mode)); err != nil {
-					msg := fmt.Sprintf("%s: cannot write vault copy: %v", vaultPath, err)
-					result.Errors = append(result.Errors, msg)
-					fmt.Printf("error: %s\n", msg)
-				} else {
-					result.Extracted = append(result.Extracted, vaultPath)
-					fmt.Printf("saved: %s (vault version)\n", vaultPath)
+	// Verify hash
+	hash := sha256.Sum256(sealedData)
+	if hex.EncodeToString(hash[:]) != file.Hash {
+		result.fail(file.Path, errors.New("failed integrity check"))
+		return
+	}
+
+	l.extractFile(file, sealedData, strategy, result)
+}
+
+// extractFile writes the verified contents of a vault entry to the working
+// tree, resolving conflicts with strategy and recording the outcome in result
+func (l *LockEnv) extractFile(file storage.FileEntry, sealedData []byte, strategy MergeStrategy, result *UnlockResult) {
+	repoRoot := filepath.Dir(l.path)
+
+	// Validate path from vault to prevent path traversal attacks
+	validPath, err := l.validator.ValidateExistingPath(file.Path)
+	if err != nil {
+		result.fail(file.Path, fmt.Errorf("invalid path from vault: %w", err))
+		return
+	}
+
+	// Check if local file exists (use absolute path with repoRoot)
+	platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
+	local, err := readSecureFile(platformPath)
+	if err == nil {
+		defer local.Close()
+		localData := local.Borrow()
+
+		// Compare files
+		if CompareFiles(localData, sealedData) {
+			// Files are identical, skip
+			result.skip(validPath, "unchanged")
+			return
+		}
+
+		// Files differ - handle conflict
+		conflictResult, err := HandleConflict(validPath, localData, sealedData, strategy, l.mergeOptions())
+		if err != nil {
+			logging.Debug("conflict", "path", validPath, "strategy", strategy, "error", err)
+			result.fail("", err)
+			return
+		}
+		logging.Debug("conflict", "path", validPath, "strategy", strategy, "resolution", conflictResult.Resolution)
+
+		switch conflictResult.Resolution {
+		case ResolutionKeepLocal:
+			result.skip(validPath, "kept local version")
+			return
+		case ResolutionSkip:
+			result.skip(validPath, "")
+			return
+		case ResolutionEditMerged:
+			// Use the merged data from editor
+			merged := crypto.AdoptSecure(conflictResult.MergedData)
+			defer merged.Close()
+			sealedData = merged.Borrow()
+		case ResolutionKeepBoth:
+			// Save vault version with .from-vault suffix
+			vaultPath := validPath + ".from-vault"
+
+			// Check if .from-vault file already exists
+			vaultPlatformPath := filepath.Join(repoRoot, filepath.FromSlash(vaultPath))
+			foundSlot := true
+			if _, err := os.Stat(vaultPlatformPath); err == nil {
+				// File exists, find available numbered suffix
+				foundSlot = false
+				for i := 1; i < MaxVaultCopies; i++ {
+					vaultPath = fmt.Sprintf("%s.from-vault.%d", validPath, i)
+					vaultPlatformPath = filepath.Join(repoRoot, filepath.FromSlash(vaultPath))
+					if _, err := os.Stat(vaultPlatformPath); os.IsNotExist(err) {
+						foundSlot = true
+						break
+					}
 				}
+			}
 
-				// Clear sensitive data from memory
-				crypto.ClearBytes(sealedData)
-				crypto.ClearBytes(localData)
+			// Error if all backup slots exhausted
+			if !foundSlot {
+				result.fail(validPath, fmt.Errorf("too many backup copies (max %d)", MaxVaultCopies))
+				return
+			}
 
-				// Keep local file unchanged
-				result.Skipped = append(result.Skipped, validPath)
-				fmt.Printf("skipped: %s (kept local version)\n", validPath)
-				continue
-			case ResolutionUseVault:
-				// Continue to write vault version
+			// Validate the vault copy path
+			if _, err := l.validator.ValidateAndNormalize(vaultPath); err != nil {
+				result.fail(vaultPath, fmt.Errorf("invalid vault copy path: %w", err))
+				return
 			}
-		}
 
-		// Create directory if needed using secure operation
-		dir := filepath.Dir(validPath)
-		if dir != "." && dir != "/" {
-			if err := l.validator.MkdirAllInRoot(dir, DirPermSecure); err != nil {
-				crypto.ClearBytes(sealedData)
-				if fileExists {
-					crypto.ClearBytes(localData)
+			// Create directory for vault copy if needed using secure operation
+			vaultDir := filepath.Dir(vaultPath)
+			if vaultDir != "." && vaultDir != "/" {
+				if err := l.validator.MkdirAllInRoot(vaultDir, DirPermSecure); err != nil {
+					result.fail(vaultPath, fmt.Errorf("cannot create directory for vault copy: %w", err))
+					return
 				}
-				msg := fmt.Sprintf("%s: cannot create directory: %v", validPath, err)
-				result.Errors = append(result.Errors, msg)
-				fmt.Printf("error: %s\n", msg)
-				continue
 			}
-		}
 
-		// Write file using secure operation
-		if err := l.validator.WriteFileInRoot(validPath, sealedData, secureFileMode(file.Mode)); err != nil {
-			crypto.ClearBytes(sealedData)
-			if fileExists {
-				crypto.ClearBytes(localData)
+			// Write vault version to alternate path using secure operation
+			if err := l.validator.WriteFileInRoot(vaultPath, sealedData, secureFileMode(file.Mode)); err != nil {
+				result.fail(vaultPath, fmt.Errorf("cannot write vault copy: %w", err))
+			} else {
+				if err := l.restoreAttributes(vaultPlatformPath, file); err != nil {
+					result.Events.addErr(EventWarning, vaultPath, err)
+				}
+				result.Extracted = append(result.Extracted, vaultPath)
+				result.Events.add(EventSaved, vaultPath, "vault version")
 			}
-			msg := fmt.Sprintf("%s: cannot write file: %v", validPath, err)
-			result.Errors = append(result.Errors, msg)
-			fmt.Printf("error: %s\n", msg)
-			continue
-		}
 
-		// Set modification time
-		if err := os.Chtimes(platformPath, time.Now(), file.ModTime); err != nil {
-			fmt.Printf("warning: %s: cannot set modification time: %v\n", validPath, err)
+			// Keep local file unchanged
+			result.skip(validPath, "kept local version")
+			return
+		case ResolutionUseVault:
+			// Continue to write vault version
 		}
+	}
 
-		// Clear sensitive data from memory
-		crypto.ClearBytes(sealedData)
-		if fileExists {
-			crypto.ClearBytes(localData)
+	// Create directory if needed using secure operation
+	dir := filepath.Dir(validPath)
+	if dir != "." && dir != "/" {
+		if err := l.validator.MkdirAllInRoot(dir, DirPermSecure); err != nil {
+			result.fail(validPath, fmt.Errorf("cannot create directory: %w", err))
+			return
 		}
+	}
 
-		result.Extracted = append(result.Extracted, validPath)
-		fmt.Printf("unlocked: %s\n", validPath)
+	// Write file using secure operation
+	if err := l.validator.WriteFileInRoot(validPath, sealedData, secureFileMode(file.Mode)); err != nil {
+		result.fail(validPath, fmt.Errorf("cannot write file: %w", err))
+		return
 	}
 
-	return result, nil
+	if err := l.restoreAttributes(platformPath, file); err != nil {
+		result.Events.addErr(EventWarning, validPath, err)
+	}
+
+	// Set modification time
+	if err := os.Chtimes(platformPath, time.Now(), file.ModTime); err != nil {
+		result.Events.addErr(EventWarning, validPath, fmt.Errorf("cannot set modification time: %w", err))
+	}
+
+	result.Extracted = append(result.Extracted, validPath)
+	logging.Info("unlocked file", "path", validPath, "size", len(sealedData))
+	result.Events.add(EventUnlocked, validPath, "")
+}
+
+// readSecureFile reads a file into a SecureBuffer in locked memory.
+// The caller must Close the returned buffer.
+func readSecureFile(path string) (*crypto.SecureBuffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	buf := crypto.NewSecureBuffer(int(info.Size()))
+	if _, err := io.ReadFull(f, buf.Borrow()); err != nil {
+		buf.Close()
+		return nil, err
+	}
+	return buf, nil
 }
 
 // filterFilesByPatterns filters files by patterns (exact match or glob)
@@ -706,8 +1133,8 @@ func filterFilesByPatterns(files []storage.FileEntry, patterns []string) []stora
 				result = append(result, file)
 				break
 			}
-			// Glob match
-			if matched, _ := filepath.Match(normalizedPattern, file.Path); matched {
+			// Glob match (supports ** and {a,b})
+			if glob.Match(normalizedPattern, file.Path) {
 				result = append(result, file)
 				break
 			}
@@ -717,32 +1144,33 @@ func filterFilesByPatterns(files []storage.FileEntry, patterns []string) []stora
 }
 
 // RemoveFiles removes files from tracking with a password (implements `lockenv rm`).
-func (l *LockEnv) RemoveFiles(ctx context.Context, patterns []string, password []byte) error {
+// The returned events list each removed file and any warnings; without an
+// EventRemoved event no file matched.
+func (l *LockEnv) RemoveFiles(ctx context.Context, patterns []string, password []byte) (EventLog, error) {
 	if err := ctx.Err(); err != nil {
-		return err
+		return nil, err
 	}
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return ErrNotInitialized
+		return nil, openError(err)
 	}
 	defer db.Close()
-	l.db = db
 
 	// Read current metadata
-	metadata, enc, err := l.readMetadata(password)
+	metadata, enc, err := l.readMetadata(ctx, db, password)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer enc.Destroy()
 
 	repoRoot := filepath.Dir(l.path)
 
 	// Remove files
-	removed := 0
+	var events EventLog
 	for _, pattern := range patterns {
 		if err := ctx.Err(); err != nil {
-			return err
+			return nil, err
 		}
 
 		// Glob relative to repo root, not CWD
@@ -751,9 +1179,9 @@ func (l *LockEnv) RemoveFiles(ctx context.Context, patterns []string, password [
 			absPattern = filepath.Join(repoRoot, pattern)
 		}
 
-		matches, err := filepath.Glob(absPattern)
+		matches, err := glob.Glob(absPattern)
 		if err != nil {
-			return fmt.Errorf("invalid pattern %s: %w", pattern, err)
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
 		}
 
 		if len(matches) == 0 {
@@ -769,40 +1197,59 @@ func (l *LockEnv) RemoveFiles(ctx context.Context, patterns []string, password [
 			// Convert absolute paths to relative
 			inputPath, err := l.normalizeToRelative(file)
 			if err != nil {
-				fmt.Printf("warning: %v\n", err)
+				events.addErr(EventWarning, "", err)
 				continue
 			}
 
 			// Validate and normalize path to match stored format
 			storedPath, err := l.validator.ValidateAndNormalize(inputPath)
 			if err != nil {
-				fmt.Printf("warning: invalid path %s: %v\n", file, err)
+				events.addErr(EventWarning, file, fmt.Errorf("invalid path: %w", err))
+				continue
+			}
+
+			// Untrack a directory tracked with lock --recursive along with its files
+			if untracked, err := removeTrackedDir(db, storedPath); err != nil {
+				return nil, fmt.Errorf("failed to untrack directory %s: %w", storedPath, err)
+			} else if untracked {
+				events.add(EventRemoved, storedPath+"/", "from tracked directories")
+				prefix := storedPath + "/"
+				for _, file := range append([]storage.FileEntry(nil), metadata.Files...) {
+					if !strings.HasPrefix(file.Path, prefix) || !metadata.RemoveFile(file.Path) {
+						continue
+					}
+					if err := db.RemoveFromManifest(file.Path); err != nil {
+						events.addErr(EventWarning, file.Path, fmt.Errorf("failed to remove from manifest: %w", err))
+					}
+					if err := db.RemoveFile(file.Path); err != nil {
+						events.addErr(EventWarning, file.Path, fmt.Errorf("failed to remove from vault: %w", err))
+					}
+					events.add(EventRemoved, file.Path, "from vault")
+				}
 				continue
 			}
 
 			if metadata.RemoveFile(storedPath) {
 				// Remove from manifest
 				if err := db.RemoveFromManifest(storedPath); err != nil {
-					fmt.Printf("warning: failed to remove %s from manifest: %v\n", storedPath, err)
+					events.addErr(EventWarning, storedPath, fmt.Errorf("failed to remove from manifest: %w", err))
 				}
 				// Remove encrypted file data
 				if err := db.RemoveFile(storedPath); err != nil {
 					// Ignore error - file might not be sealed yet
-					fmt.Printf("warning: failed to remove %s from vault: %v\n", storedPath, err)
+					events.addErr(EventWarning, storedPath, fmt.Errorf("failed to remove from vault: %w", err))
 				}
-				removed++
-				fmt.Printf("removed: %s from vault\n", storedPath)
+				events.add(EventRemoved, storedPath, "from vault")
 			}
 		}
 	}
 
-	if removed == 0 {
-		fmt.Println("No matching files found in vault")
-		return nil
+	if events.Count(EventRemoved) == 0 {
+		return events, nil
 	}
 
 	// Save updated metadata
-	return l.saveMetadata(metadata, enc)
+	return events, l.saveMetadata(db, metadata, enc)
 }
 
 // List returns tracked files from the manifest (no password required)
@@ -811,12 +1258,12 @@ func (l *LockEnv) List(ctx context.Context) ([]storage.FileEntry, error) {
 		return nil, err
 	}
 	// Check if exists
-	if _, err := os.Stat(l.path); err != nil {
+	if !l.exists() {
 		return nil, ErrNotInitialized
 	}
 
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -853,41 +1300,106 @@ func (l *LockEnv) List(ctx context.Context) ([]storage.FileEntry, error) {
 
 // ChangePassword changes the password for the .lockenv file
 func (l *LockEnv) ChangePassword(currentPassword, newPassword []byte) error {
+	return l.changePassword(context.Background(), currentPassword, newPassword, 0)
+}
+
+// RotateSalt re-derives the vault key from the unchanged password with a
+// new salt (implements `lockenv passwd --rotate-salt-only`), e.g. after
+// raising kdf_target_time or upgrading to a release with a higher default.
+// The iterations are picked as for ChangePassword but never lowered. Format
+// v2 vaults only re-wrap their data key; older vaults re-encrypt every file.
+// Encryption domains keep their own salts. Returns the iterations before
+// and after.
+func (l *LockEnv) RotateSalt(password []byte) (int, int, error) {
+	before, err := l.kdfIterations()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := l.changePassword(context.Background(), password, password, before); err != nil {
+		return 0, 0, err
+	}
+	after, err := l.kdfIterations()
+	if err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+// kdfIterations returns the PBKDF2 iterations of the vault password
+func (l *LockEnv) kdfIterations() (int, error) {
+	if !l.exists() {
+		return 0, ErrNotInitialized
+	}
+	db, err := l.open()
+	if err != nil {
+		return 0, openError(err)
+	}
+	defer db.Close()
+
+	iterations, err := db.GetIterations()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get iterations: %w", err)
+	}
+	return int(iterations), nil
+}
+
+// changePassword re-keys the vault for newPassword with a fresh salt and at
+// least minIters iterations
+func (l *LockEnv) changePassword(ctx context.Context, currentPassword, newPassword []byte, minIters int) error {
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return ErrNotInitialized
+		return openError(err)
 	}
 	defer db.Close()
-	l.db = db
 
 	// Read metadata with current password
-	metadata, currentEnc, err := l.readMetadata(currentPassword)
+	metadata, currentEnc, err := l.readMetadata(ctx, db, currentPassword)
 	if err != nil {
 		return err
 	}
 	defer currentEnc.Destroy()
 
+	// Keep a copy of the vault in case re-encryption is interrupted
+	backup, err := l.backup(db, DefaultBackupDir, DefaultBackupKeep)
+	if err != nil {
+		return fmt.Errorf("failed to back up vault: %w", err)
+	}
+	l.notice(FileEvent{Action: EventBackup, Path: backup.Path})
+
+	// From v2 on, only the data key is re-wrapped
+	if format, err := db.GetFormatVersion(); err == nil && format >= storage.FormatV2 {
+		return l.rewrapDataKey(ctx, db, currentPassword, newPassword, minIters)
+	}
+
 	// Read all file data with current password
 	type fileData struct {
 		path string
-		data []byte
+		data *crypto.SecureBuffer
 	}
 	var files []fileData
-	// Ensure all decrypted file data is cleared from memory on all exit paths
+	// Ensure all decrypted file data is wiped on all exit paths
 	defer func() {
 		for i := range files {
-			crypto.ClearBytes(files[i].data)
+			files[i].data.Close()
 		}
 	}()
 
 	for _, entry := range metadata.Files {
-		encData, err := db.GetFileData(entry.Path)
+		// Files in an encryption domain keep their own key, and tracked
+		// files that were never locked have nothing to re-encrypt
+		if entry.Domain != "" {
+			continue
+		}
+		if sealed, err := db.HasFileData(entry.Path); err == nil && !sealed {
+			continue
+		}
+		encData, err := db.GetFileData(ctx, entry.Path)
 		if err != nil {
 			return fmt.Errorf("file %s not sealed in vault: %w", entry.Path, err)
 		}
 
-		data, err := currentEnc.Decrypt(encData)
+		data, err := currentEnc.DecryptSecure(encData)
 		if err != nil {
 			return fmt.Errorf("failed to decrypt file %s: %w", entry.Path, err)
 		}
@@ -895,15 +1407,16 @@ func (l *LockEnv) ChangePassword(currentPassword, newPassword []byte) error {
 	}
 
 	// Create new KDF with new password
-	newKDF, err := crypto.NewKDF()
+	newKDF, err := l.newKDF()
 	if err != nil {
 		return fmt.Errorf("failed to create new KDF: %w", err)
 	}
+	newKDF.Iterations = max(newKDF.Iterations, minIters)
 
-	newKey := newKDF.DeriveKey(newPassword)
-	defer crypto.ClearBytes(newKey)
+	newKey := crypto.AdoptSecure(newKDF.DeriveKey(newPassword))
+	defer newKey.Close()
 
-	newEnc := crypto.NewEncryptor(newKey)
+	newEnc := crypto.NewEncryptor(newKey.Borrow())
 	defer newEnc.Destroy()
 
 	// Update salt and iterations
@@ -916,25 +1429,20 @@ func (l *LockEnv) ChangePassword(currentPassword, newPassword []byte) error {
 
 	// Re-encrypt all files with new key
 	for _, file := range files {
-		encData, err := newEnc.Encrypt(file.data)
+		encData, err := newEnc.Encrypt(file.data.Borrow())
 		if err != nil {
 			return fmt.Errorf("failed to re-encrypt file %s: %w", file.path, err)
 		}
-		if err := db.StoreFileData(file.path, encData); err != nil {
+		if err := db.StoreFileData(ctx, file.path, encData); err != nil {
 			return fmt.Errorf("failed to store re-encrypted file %s: %w", file.path, err)
 		}
-		// Clear file data from memory
-		crypto.ClearBytes(file.data)
+		// Wipe file data from memory
+		file.data.Close()
 	}
 
 	// Re-encrypt checksum
-	checksum := sha256.Sum256([]byte(passwordCheckString))
-	checksumData, err := newEnc.Encrypt([]byte(hex.EncodeToString(checksum[:])))
-	if err != nil {
-		return fmt.Errorf("failed to encrypt checksum: %w", err)
-	}
-	if err := db.StoreMetadataBytes("checksum", checksumData); err != nil {
-		return fmt.Errorf("failed to store checksum: %w", err)
+	if err := storePasswordVerifier(db, storage.FormatV1, newEnc); err != nil {
+		return err
 	}
 
 	// Re-encrypt metadata
@@ -950,36 +1458,42 @@ func (l *LockEnv) ChangePassword(currentPassword, newPassword []byte) error {
 		return fmt.Errorf("failed to store metadata: %w", err)
 	}
 
-	return nil
+	// Recipients must be able to unwrap the new key
+	if err := l.rewrapRecipients(context.Background(), db, newKey.Borrow()); err != nil {
+		return err
+	}
+
+	return db.UpdateModified()
 }
 
 // Diff compares .lockenv contents with local files showing actual content differences
-func (l *LockEnv) Diff(ctx context.Context, password []byte) error {
+func (l *LockEnv) Diff(ctx context.Context, password []byte, opts DiffOptions) (*DiffResult, error) {
 	if err := ctx.Err(); err != nil {
-		return err
+		return nil, err
 	}
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return ErrNotInitialized
+		return nil, openError(err)
 	}
 	defer db.Close()
-	l.db = db
 
 	// Read metadata
-	metadata, enc, err := l.readMetadata(password)
+	metadata, enc, err := l.readMetadata(ctx, db, password)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer enc.Destroy()
 
-	hasChanges := false
+	result := &DiffResult{}
 	repoRoot := filepath.Dir(l.path)
+	cache := newHashCache(l.path)
+	defer cache.save()
 
 	// Compare each file
 	for _, file := range metadata.Files {
 		if err := ctx.Err(); err != nil {
-			return err
+			return nil, err
 		}
 
 		// Validate path to prevent path traversal
@@ -990,92 +1504,120 @@ func (l *LockEnv) Diff(ctx context.Context, password []byte) error {
 		}
 		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
 
-		// Check if file exists locally
-		localData, err := os.ReadFile(platformPath)
+		fileEnc, err := l.fileEncryptor(&file, enc)
 		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Printf("File not in working directory: %s\n", validPath)
-			} else {
-				fmt.Printf("error: cannot read %s: %v\n", validPath, err)
-			}
+			result.Events.add(EventSkipped, validPath, "domain "+file.Domain+" is locked")
 			continue
 		}
 
-		// Read encrypted file data from vault (use original path for db key)
-		encryptedData, err := db.GetFileData(file.Path)
-		if err != nil {
-			crypto.ClearBytes(localData)
-			fmt.Printf("error: cannot read %s from vault: %v\n", validPath, err)
-			continue
+		// Files whose hash matches the vault have no diff; skip decrypting them
+		if info, err := os.Stat(platformPath); err == nil {
+			if hash, err := cache.hash(file.Path, platformPath, info); err == nil && hash == file.Hash {
+				continue
+			}
 		}
 
-		// Decrypt file
-		vaultData, err := enc.Decrypt(encryptedData)
-		if err != nil {
-			crypto.ClearBytes(localData)
-			fmt.Printf("error: cannot decrypt %s: %v\n", validPath, err)
-			continue
-		}
+		l.diffFile(ctx, db, file.Path, validPath, platformPath, fileEnc, opts, result)
+	}
 
-		// Generate diff
-		diff, err := GenerateUnifiedDiff(validPath, vaultData, localData)
-		if err != nil {
-			crypto.ClearBytes(vaultData)
-			crypto.ClearBytes(localData)
-			fmt.Printf("error: cannot generate diff for %s: %v\n", validPath, err)
-			continue
-		}
+	return result, nil
+}
 
-		if diff != "" {
-			fmt.Print(diff)
-			hasChanges = true
+// diffFile records the diff between the vault version of a file and its
+// local copy in result, or with opts.Stat its changed lines. Both versions
+// are held in SecureBuffers and wiped on return.
+func (l *LockEnv) diffFile(ctx context.Context, db storage.Backend, path, validPath, platformPath string, fileEnc *crypto.Encryptor, opts DiffOptions, result *DiffResult) {
+	// Check if file exists locally
+	local, err := readSecureFile(platformPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			result.Files = append(result.Files, FileDiff{Path: validPath, Change: ChangeMissing})
+		} else {
+			result.Events.addErr(EventError, validPath, fmt.Errorf("cannot read: %w", err))
 		}
+		return
+	}
+	defer local.Close()
+
+	// Read encrypted file data from vault (use original path for db key)
+	encryptedData, err := db.GetFileData(ctx, path)
+	if err != nil {
+		result.Events.addErr(EventError, validPath, fmt.Errorf("cannot read from vault: %w", err))
+		return
+	}
 
-		// Clear sensitive data from memory
-		crypto.ClearBytes(vaultData)
-		crypto.ClearBytes(localData)
+	// Decrypt file
+	vault, err := fileEnc.DecryptSecure(encryptedData)
+	if err != nil {
+		result.Events.addErr(EventError, validPath, fmt.Errorf("cannot decrypt: %w", err))
+		return
 	}
+	defer vault.Close()
 
-	if !hasChanges {
-		fmt.Println("No changes detected")
+	if opts.Stat {
+		if stat := diffStat(validPath, vault.Borrow(), local.Borrow()); stat != nil {
+			result.Stats = append(result.Stats, *stat)
+		}
+		return
 	}
 
-	return nil
+	// Generate diff
+	diff, err := GenerateUnifiedDiff(validPath, vault.Borrow(), local.Borrow(), opts)
+	if err != nil {
+		result.Events.addErr(EventError, validPath, fmt.Errorf("cannot generate diff: %w", err))
+		return
+	}
+	if diff != "" {
+		result.Files = append(result.Files, FileDiff{Path: validPath, Change: ChangeModified, Diff: diff})
+	}
 }
 
 // FileStatus represents the status of a tracked file
 type FileStatus struct {
-	Path   string
-	Status string
+	Path    string
+	Status  string
+	Size    int64       // Size when last locked or tracked
+	ModTime time.Time   // Modification time when last locked or tracked
+	Hash    string      // Content hash recorded in the manifest
+	Mode    os.FileMode // Permissions of the working-tree copy, 0 if unknown
+	Note    string      // Annotation from lockenv note, filled in by callers holding the password
 }
 
 // StatusInfo contains status information
 type StatusInfo struct {
-	Files          []FileStatus
-	LastSealed     time.Time
-	TrackedCount   int
-	SealedCount    int
-	ModifiedCount  int
-	UnchangedCount int
-	TotalSize      int64
-	Algorithm      string
-	KDFIterations  uint32
-	Version        int
-	GitStatus      *git.GitStatus
+	Files            []FileStatus
+	LastSealed       time.Time
+	TrackedCount     int
+	SealedCount      int
+	ModifiedCount    int
+	UnchangedCount   int
+	NeverLockedCount int // Files added with track and not locked yet
+	TotalSize        int64
+	Algorithm        string
+	KDFIterations    uint32
+	Version          int
+	Layout           string            // storage.LayoutFile or storage.LayoutDir
+	Generation       uint64            // Number of changes made to the vault
+	Directories      []string          // Directories tracked with lock --recursive
+	Relock           *RelockTimer      // Pending unlock --for timer, if any
+	Info             map[string]string // Description, owner and contact set with meta set
+	GitStatus        *git.GitStatus
 }
 
-// Status returns the current status (no password required)
-func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
+// Status returns the current status (no password required).
+// Files whose size and modification time match the manifest are reported as
+// unchanged without being read; with full set, every file is hashed.
+func (l *LockEnv) Status(ctx context.Context, full bool) (*StatusInfo, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 	// Check if exists
-	if _, err := os.Stat(l.path); err != nil {
+	if !l.exists() {
 		return nil, ErrNotInitialized
 	}
 
 	// Open database
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -1094,12 +1636,24 @@ func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
 		iterations = 0
 	}
 
+	format, err := db.GetFormatVersion()
+	if err != nil {
+		format = 0
+	}
+
+	generation, err := db.GetGeneration()
+	if err != nil {
+		generation = 0
+	}
+
 	status := &StatusInfo{
 		LastSealed:     lastModified,
 		Files:          make([]FileStatus, 0),
 		Algorithm:      "AES-256-GCM",
 		KDFIterations:  iterations,
-		Version:        1,
+		Version:        format,
+		Layout:         storage.LayoutOf(db),
+		Generation:     generation,
 		TotalSize:      0,
 		TrackedCount:   0,
 		SealedCount:    0,
@@ -1107,6 +1661,11 @@ func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
 		UnchangedCount: 0,
 	}
 
+	// Not critical
+	status.Directories, _ = getTrackedDirs(db)
+	status.Relock, _ = getRelockTimer(db)
+	status.Info, _ = getVaultInfo(db)
+
 	// Get manifest entries
 	entries, err := l.getManifestEntries(db)
 	if err != nil {
@@ -1114,6 +1673,9 @@ func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
 	}
 
 	repoRoot := filepath.Dir(l.path)
+	cache := newHashCache(l.path)
+	cache.refresh = full
+	defer cache.save()
 
 	// Check each file
 	for _, entry := range entries {
@@ -1128,13 +1690,26 @@ func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
 			continue
 		}
 
-		fs := FileStatus{Path: validPath}
+		fs := FileStatus{
+			Path:    validPath,
+			Size:    entry.Size,
+			ModTime: entry.ModTime,
+			Hash:    entry.Hash,
+		}
 		status.TrackedCount++
 		status.TotalSize += entry.Size
 
+		// Files added with track have no encrypted content yet
+		if sealed, err := db.HasFileData(entry.Path); err == nil && !sealed {
+			fs.Status = "tracked, never locked"
+			status.NeverLockedCount++
+			status.Files = append(status.Files, fs)
+			continue
+		}
+
 		// Check if file exists locally using validated path
 		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
-		_, err = os.Stat(platformPath)
+		info, err := os.Stat(platformPath)
 
 		if os.IsNotExist(err) {
 			fs.Status = "vault only"
@@ -1147,9 +1722,27 @@ func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
 			status.Files = append(status.Files, fs)
 			continue
 		}
+		fs.Mode = info.Mode().Perm()
+
+		// Fast path: a different size means modified, and matching size and
+		// mtime (restored by unlock) mean unchanged
+		if !full {
+			if info.Size() != entry.Size {
+				fs.Status = "modified"
+				status.ModifiedCount++
+				status.Files = append(status.Files, fs)
+				continue
+			}
+			if info.ModTime().Equal(entry.ModTime) {
+				fs.Status = "unchanged"
+				status.UnchangedCount++
+				status.Files = append(status.Files, fs)
+				continue
+			}
+		}
 
 		// Hash-based comparison
-		content, err := os.ReadFile(platformPath)
+		localHashStr, err := cache.hash(entry.Path, platformPath, info)
 		if err != nil {
 			fs.Status = "modified"
 			status.ModifiedCount++
@@ -1157,10 +1750,6 @@ func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
 			continue
 		}
 
-		localHash := sha256.Sum256(content)
-		localHashStr := hex.EncodeToString(localHash[:])
-		crypto.ClearBytes(content)
-
 		if localHashStr != entry.Hash {
 			fs.Status = "modified"
 			status.ModifiedCount++
@@ -1188,11 +1777,51 @@ func (l *LockEnv) Status(ctx context.Context) (*StatusInfo, error) {
 	return status, nil
 }
 
+// UnlockedFiles returns tracked files that exist as plaintext in the working
+// tree, whether modified or not. Only stats files, so it is cheap enough to run
+// on every shell prompt (no password required).
+func (l *LockEnv) UnlockedFiles(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	entries, err := l.getManifestEntries(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	repoRoot := filepath.Dir(l.path)
+	var present []string
+	for _, entry := range entries {
+		// Validate path to prevent path traversal from tampered manifest
+		validPath, err := l.validator.ValidateExistingPath(entry.Path)
+		if err != nil {
+			continue
+		}
+		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
+		if info, err := os.Lstat(platformPath); err == nil && info.Mode().IsRegular() {
+			present = append(present, validPath)
+		}
+	}
+	return present, nil
+}
+
 // ChangedFilesResult contains the result of analyzing tracked files
 type ChangedFilesResult struct {
-	Changed   []string // Files that have been modified
-	Unchanged []string // Files that are the same
-	Missing   []string // Files that don't exist locally (vault only)
+	Changed     []string // Files that have been modified
+	Unchanged   []string // Files that are the same
+	Missing     []string // Files that don't exist locally (vault only)
+	NeverLocked []string // Files added with track that have no encrypted content yet
 }
 
 // GetChangedFiles analyzes all tracked files and determines which have changed
@@ -1202,35 +1831,34 @@ func (l *LockEnv) GetChangedFiles(ctx context.Context, password []byte) (*Change
 		return nil, err
 	}
 	// Check if exists
-	if _, err := os.Stat(l.path); err != nil {
+	if !l.exists() {
 		return nil, ErrNotInitialized
 	}
 
 	// Open database temporarily
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
-	// Set l.db temporarily for readMetadata
-	l.db = db
-	defer func() { l.db = nil }()
-
 	// Read metadata (requires password)
-	metadata, enc, err := l.readMetadata(password)
+	metadata, enc, err := l.readMetadata(ctx, db, password)
 	if err != nil {
 		return nil, err
 	}
 	defer enc.Destroy() // Clear derived key from memory
 
 	result := &ChangedFilesResult{
-		Changed:   make([]string, 0),
-		Unchanged: make([]string, 0),
-		Missing:   make([]string, 0),
+		Changed:     make([]string, 0),
+		Unchanged:   make([]string, 0),
+		Missing:     make([]string, 0),
+		NeverLocked: make([]string, 0),
 	}
 
 	repoRoot := filepath.Dir(l.path)
+	cache := newHashCache(l.path)
+	defer cache.save()
 
 	// Check each tracked file
 	for _, file := range metadata.Files {
@@ -1247,7 +1875,8 @@ func (l *LockEnv) GetChangedFiles(ctx context.Context, password []byte) (*Change
 		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
 
 		// Check if file exists
-		if _, err := os.Stat(platformPath); err != nil {
+		info, err := os.Stat(platformPath)
+		if err != nil {
 			if os.IsNotExist(err) {
 				result.Missing = append(result.Missing, validPath)
 				continue
@@ -1257,20 +1886,18 @@ func (l *LockEnv) GetChangedFiles(ctx context.Context, password []byte) (*Change
 			continue
 		}
 
-		// Read file content
-		content, err := os.ReadFile(platformPath)
+		// Calculate hash, reusing the cached one if the file is unchanged
+		currentHash, err := cache.hash(file.Path, platformPath, info)
 		if err != nil {
 			// Can't read - treat as missing
 			result.Missing = append(result.Missing, validPath)
 			continue
 		}
 
-		// Calculate hash
-		hash := sha256.Sum256(content)
-		currentHash := hex.EncodeToString(hash[:])
-
 		// Compare with stored hash
-		if currentHash != file.Hash {
+		if sealed, err := db.HasFileData(file.Path); err == nil && !sealed {
+			result.NeverLocked = append(result.NeverLocked, validPath)
+		} else if currentHash != file.Hash {
 			result.Changed = append(result.Changed, validPath)
 		} else {
 			result.Unchanged = append(result.Unchanged, validPath)
@@ -1280,69 +1907,90 @@ func (l *LockEnv) GetChangedFiles(ctx context.Context, password []byte) (*Change
 	return result, nil
 }
 
-// readMetadata reads and decrypts metadata
-func (l *LockEnv) readMetadata(password []byte) (*storage.Metadata, *crypto.Encryptor, error) {
-	if l.db == nil {
-		return nil, nil, fmt.Errorf("database not open")
+// passwordKey returns a copy of the pre-derived vault key if one is set,
+// otherwise derives the vault key from the password without verifying it
+func (l *LockEnv) passwordKey(ctx context.Context, db storage.Backend, password []byte) ([]byte, error) {
+	if l.key != nil {
+		// Use a copy of the pre-derived key, since the encryptor clears it on Destroy
+		return append([]byte(nil), l.key.Borrow()...), nil
+	}
+
+	// If no password provided, prompt for it
+	if password == nil {
+		// This will be handled by the command layer
+		return nil, ErrPasswordRequired
 	}
 
 	// Get salt and iterations
-	salt, err := l.db.GetSalt()
+	salt, err := db.GetSalt()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get salt: %w", err)
+		return nil, fmt.Errorf("failed to get salt: %w", err)
 	}
 
-	iterations, err := l.db.GetIterations()
+	iterations, err := db.GetIterations()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get iterations: %w", err)
+		return nil, fmt.Errorf("failed to get iterations: %w", err)
 	}
 
-	// Create KDF
 	kdf := &crypto.KDF{
 		Salt:       salt,
 		Iterations: int(iterations),
 	}
+	return kdf.DeriveKeyContext(ctx, password)
+}
 
-	// If no password provided, prompt for it
-	if password == nil {
-		// This will be handled by the command layer
-		return nil, nil, ErrPasswordRequired
+// readMetadata reads and decrypts metadata
+func (l *LockEnv) readMetadata(ctx context.Context, db storage.Backend, password []byte) (*storage.Metadata, *crypto.Encryptor, error) {
+	if db == nil {
+		return nil, nil, fmt.Errorf("database not open")
 	}
 
-	// Derive key
-	key := kdf.DeriveKey(password)
-	// Don't clear the key here - it's still needed by the encryptor
-
-	// Create encryptor
-	enc := crypto.NewEncryptor(key)
-
-	// Verify password with checksum
-	encChecksum, err := l.db.GetMetadataBytes("checksum")
+	key, err := l.passwordKey(ctx, db, password)
 	if err != nil {
-		enc.Destroy()
-		return nil, nil, ErrWrongPassword
+		return nil, nil, err
 	}
+	return l.readMetadataWithKey(ctx, db, key)
+}
 
-	checksumData, err := enc.Decrypt(encChecksum)
+// readMetadataWithKey reads and decrypts metadata with the vault key,
+// which the returned encryptor takes over
+func (l *LockEnv) readMetadataWithKey(ctx context.Context, db storage.Backend, key []byte) (*storage.Metadata, *crypto.Encryptor, error) {
+	format, err := db.GetFormatVersion()
 	if err != nil {
+		crypto.ClearBytes(key)
+		return nil, nil, fmt.Errorf("failed to read format version: %w", err)
+	}
+
+	// Create encryptor
+	enc := crypto.NewEncryptor(key)
+
+	// From v2 on, the vault key only wraps the random data key
+	if format >= storage.FormatV2 {
+		dataKey, err := unwrapDataKey(db, enc)
 		enc.Destroy()
-		return nil, nil, ErrWrongPassword
+		if err != nil {
+			return nil, nil, err
+		}
+		if enc, err = dataEncryptor(format, dataKey); err != nil {
+			crypto.ClearBytes(dataKey)
+			return nil, nil, err
+		}
 	}
 
-	checksum := sha256.Sum256([]byte(passwordCheckString))
-	if string(checksumData) != hex.EncodeToString(checksum[:]) {
+	// Verify password
+	if err := verifyPassword(db, format, enc); err != nil {
 		enc.Destroy()
-		return nil, nil, ErrWrongPassword
+		return nil, nil, err
 	}
 
 	// Read encrypted metadata
-	encMetadata, err := l.db.GetMetadataBytes("files")
+	encMetadata, err := db.GetMetadataBytes("files")
 	if err != nil {
 		enc.Destroy()
 		return nil, nil, fmt.Errorf("failed to read metadata: %w", err)
 	}
 
-	metadataData, err := enc.Decrypt(encMetadata)
+	metadataData, err := enc.For(crypto.PurposeMetadata).Decrypt(encMetadata)
 	if err != nil {
 		enc.Destroy()
 		return nil, nil, fmt.Errorf("failed to decrypt metadata: %w", err)
@@ -1354,62 +2002,85 @@ func (l *LockEnv) readMetadata(password []byte) (*storage.Metadata, *crypto.Encr
 		return nil, nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
 
+	// A lock interrupted while storing files left a journal
+	if err := l.recoverLock(ctx, db, &metadata, enc); err != nil {
+		enc.Destroy()
+		return nil, nil, err
+	}
+
+	// A stale MAC is also left by an operation interrupted between updating
+	// the index and saving metadata, so it is not treated as fatal
+	if format >= storage.FormatV2 {
+		if err := verifyIndex(db, enc); err != nil {
+			l.notice(FileEvent{Action: EventWarning, Err: err})
+		}
+	}
+
 	return &metadata, enc, nil
 }
 
 // saveMetadata saves updated metadata
-func (l *LockEnv) saveMetadata(metadata *storage.Metadata, enc *crypto.Encryptor) error {
-	if l.db == nil {
+func (l *LockEnv) saveMetadata(db storage.Backend, metadata *storage.Metadata, enc *crypto.Encryptor) error {
+	if db == nil {
 		return fmt.Errorf("database not open")
 	}
 
 	metadata.Modified = time.Now()
 
-	// Marshal metadata
-	metadataJSON, err := json.Marshal(metadata)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
-	}
-
-	// Encrypt metadata
-	encryptedMetadata, err := enc.Encrypt(metadataJSON)
+	encryptedMetadata, err := sealMetadata(metadata, enc)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt metadata: %w", err)
+		return err
 	}
 
 	// Store metadata
-	if err := l.db.StoreMetadataBytes("files", encryptedMetadata); err != nil {
+	if err := db.StoreMetadataBytes("files", encryptedMetadata); err != nil {
 		return fmt.Errorf("failed to store metadata: %w", err)
 	}
 
+	if format, err := db.GetFormatVersion(); err == nil && format >= storage.FormatV2 {
+		if err := storeIndexMAC(db, enc); err != nil {
+			return err
+		}
+	}
+
 	// Update modification time
-	return l.db.UpdateModified()
+	return db.UpdateModified()
+}
+
+// sealMetadata marshals and encrypts metadata as stored in the vault
+func sealMetadata(metadata *storage.Metadata, enc *crypto.Encryptor) ([]byte, error) {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	encryptedMetadata, err := enc.For(crypto.PurposeMetadata).Encrypt(metadataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt metadata: %w", err)
+	}
+	return encryptedMetadata, nil
 }
 
 // Compact compacts the database to reclaim unused space.
-// This is useful after removing files from the vault.
+// This is useful after removing files from the vault. It opens the vault
+// itself, since operations close theirs when they return.
 func (l *LockEnv) Compact() error {
-	// Open database if not already open
-	if l.db == nil {
-		db, err := storage.Open(l.path)
-		if err != nil {
-			return ErrNotInitialized
-		}
-		defer db.Close()
-		return db.Compact()
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
 	}
-	return l.db.Compact()
+	defer db.Close()
+	return db.Compact()
 }
 
 // GetVaultID retrieves the vault ID from storage
 func (l *LockEnv) GetVaultID() (string, error) {
-	if _, err := os.Stat(l.path); err != nil {
+	if !l.exists() {
 		return "", ErrNotInitialized
 	}
 
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return "", ErrNotInitialized
+		return "", openError(err)
 	}
 	defer db.Close()
 
@@ -1418,33 +2089,99 @@ func (l *LockEnv) GetVaultID() (string, error) {
 
 // GetOrCreateVaultID retrieves existing vault ID or generates a new one
 func (l *LockEnv) GetOrCreateVaultID() (string, error) {
-	if _, err := os.Stat(l.path); err != nil {
+	if !l.exists() {
 		return "", ErrNotInitialized
 	}
 
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return "", ErrNotInitialized
+		return "", openError(err)
 	}
 	defer db.Close()
 
 	return db.GetOrCreateVaultID()
 }
 
+// UseKey makes subsequent operations use a pre-derived vault key instead of
+// deriving one from the password. Passing nil reverts to password derivation.
+// The LockEnv keeps its own copy of the key and clears it on Close.
+func (l *LockEnv) UseKey(key []byte) {
+	l.key.Close()
+	l.key = nil
+	if key != nil {
+		l.key = crypto.SecureCopy(key)
+	}
+}
+
+// vaultKey returns a copy of the pre-derived key if one is set,
+// otherwise derives and verifies the key from the password.
+func (l *LockEnv) vaultKey(password []byte) ([]byte, error) {
+	if l.key != nil {
+		if err := l.VerifyPassword(nil); err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), l.key.Borrow()...), nil
+	}
+	return l.DeriveKey(context.Background(), password)
+}
+
+// DeriveKey derives the vault key from the password and verifies it.
+// The caller is responsible for calling crypto.ClearBytes on the returned key.
+func (l *LockEnv) DeriveKey(ctx context.Context, password []byte) ([]byte, error) {
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	if password == nil {
+		return nil, ErrPasswordRequired
+	}
+
+	salt, err := db.GetSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get salt: %w", err)
+	}
+	iterations, err := db.GetIterations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get iterations: %w", err)
+	}
+
+	kdf := &crypto.KDF{Salt: salt, Iterations: int(iterations)}
+	key, err := kdf.DeriveKeyContext(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the key by decrypting metadata with a copy of it, leaving any
+	// key set with UseKey alone
+	_, enc, err := l.readMetadataWithKey(ctx, db, append([]byte(nil), key...))
+	if err != nil {
+		crypto.ClearBytes(key)
+		return nil, err
+	}
+	enc.Destroy()
+
+	return key, nil
+}
+
 // VerifyPassword checks if the password is correct for this vault
 func (l *LockEnv) VerifyPassword(password []byte) error {
-	if _, err := os.Stat(l.path); err != nil {
+	if !l.exists() {
 		return ErrNotInitialized
 	}
 
-	db, err := storage.Open(l.path)
+	db, err := l.open()
 	if err != nil {
-		return ErrNotInitialized
+		return openError(err)
 	}
 	defer db.Close()
-	l.db = db
 
-	_, enc, err := l.readMetadata(password)
+	_, enc, err := l.readMetadata(context.Background(), db, password)
 	if err != nil {
 		return err
 	}
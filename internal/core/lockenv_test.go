@@ -1,12 +1,17 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/illarion/lockenv/internal/crypto"
 	"github.com/illarion/lockenv/internal/storage"
 )
 
@@ -63,7 +68,7 @@ func TestTrackSealUnseal(t *testing.T) {
 	}
 
 	// Track files
-	if err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
@@ -77,7 +82,7 @@ func TestTrackSealUnseal(t *testing.T) {
 	}
 
 	// Seal files
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -140,7 +145,7 @@ func TestForget(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
@@ -154,7 +159,7 @@ func TestForget(t *testing.T) {
 	}
 
 	// Forget file
-	if err := lockenv.RemoveFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.RemoveFiles(context.Background(), []string{testFile}, password); err != nil {
 		t.Fatalf("Forget failed: %v", err)
 	}
 
@@ -189,7 +194,7 @@ func TestForget_AbsolutePath(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
@@ -203,7 +208,7 @@ func TestForget_AbsolutePath(t *testing.T) {
 	}
 
 	// Forget using absolute path (bug: this used to fail)
-	if err := lockenv.RemoveFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.RemoveFiles(context.Background(), []string{testFile}, password); err != nil {
 		t.Fatalf("Forget with absolute path failed: %v", err)
 	}
 
@@ -244,7 +249,7 @@ func TestForget_DotSlashPrefix(t *testing.T) {
 	}
 
 	// Track using absolute path (normalized to relative internally)
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
@@ -269,7 +274,7 @@ func TestForget_DotSlashPrefix(t *testing.T) {
 	}
 
 	// Forget using ./ prefix (bug: this used to fail)
-	if err := lockenv.RemoveFiles(context.Background(), []string{"./config/database.yml"}, password); err != nil {
+	if _, err := lockenv.RemoveFiles(context.Background(), []string{"./config/database.yml"}, password); err != nil {
 		t.Fatalf("Forget with ./ prefix failed: %v", err)
 	}
 
@@ -314,7 +319,7 @@ func TestForget_MixedPathFormats(t *testing.T) {
 	}
 
 	// Track all files
-	if err := lockenv.LockFiles(context.Background(), []string{file1, file2, file3}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{file1, file2, file3}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
@@ -331,7 +336,7 @@ func TestForget_MixedPathFormats(t *testing.T) {
 	// - file1: absolute path
 	// - file2: ./ prefix
 	// - file3: regular relative path
-	if err := lockenv.RemoveFiles(context.Background(), []string{
+	if _, err := lockenv.RemoveFiles(context.Background(), []string{
 		file1,         // absolute
 		"./file2.txt", // ./ prefix
 		"file3.txt",   // relative
@@ -374,17 +379,17 @@ func TestWrongPassword(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
 	// Try to seal with wrong password
-	if err := lockenv.FinalizeLock(context.Background(), wrongPassword, false); err != ErrWrongPassword {
+	if _, err := lockenv.FinalizeLock(context.Background(), wrongPassword, false, nil); err != ErrWrongPassword {
 		t.Errorf("Expected ErrWrongPassword, got %v", err)
 	}
 
 	// Seal with correct password
-	if err := lockenv.FinalizeLock(context.Background(), password, false); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -418,12 +423,12 @@ func TestChangePassword(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, oldPassword); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, oldPassword, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
 	// Seal with old password
-	if err := lockenv.FinalizeLock(context.Background(), oldPassword, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), oldPassword, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -457,6 +462,71 @@ func TestChangePassword(t *testing.T) {
 	}
 }
 
+func TestRotateSalt(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("samepass")
+	lockenv.SetKDFTargetTime(20 * time.Millisecond)
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	testFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(testFile, []byte("KEY=value"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{testFile}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	salt := func() []byte {
+		db, err := lockenv.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		salt, err := db.GetSalt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return salt
+	}
+	oldSalt := salt()
+
+	if _, _, err := lockenv.RotateSalt([]byte("wrong")); err != ErrWrongPassword {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+
+	// Without a target time the calibrated iterations are kept, not lowered
+	lockenv.SetKDFTargetTime(0)
+	before, after, err := lockenv.RotateSalt(password)
+	if err != nil {
+		t.Fatalf("RotateSalt failed: %v", err)
+	}
+	if before < crypto.DefaultIters || after < before {
+		t.Errorf("RotateSalt iterations %d -> %d, want no decrease", before, after)
+	}
+	if bytes.Equal(salt(), oldSalt) {
+		t.Error("Expected a new salt")
+	}
+
+	result, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil)
+	if err != nil {
+		t.Fatalf("Unlock after RotateSalt failed: %v", err)
+	}
+	if len(result.Extracted) != 1 {
+		t.Errorf("Expected 1 extracted file, got %d", len(result.Extracted))
+	}
+}
+
 // Security Tests - Path Traversal Prevention
 
 func TestTrack_RejectsPathTraversal(t *testing.T) {
@@ -492,7 +562,7 @@ func TestTrack_RejectsPathTraversal(t *testing.T) {
 	for _, malPath := range maliciousPaths {
 		t.Run("reject_"+malPath, func(t *testing.T) {
 			// Track should not add the malicious path
-			_ = lockenv.LockFiles(context.Background(), []string{malPath}, password)
+			_, _ = lockenv.LockFiles(context.Background(), []string{malPath}, password, false)
 
 			// It should either error or silently skip (due to validation)
 			// Either way, verify it's NOT in the vault
@@ -532,11 +602,11 @@ func TestUnlock_RejectsPathTraversal(t *testing.T) {
 		t.Fatalf("Failed to create legit file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{legitFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{legitFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -588,7 +658,7 @@ func TestTrack_AllowsValidRelativePaths(t *testing.T) {
 	}
 
 	// Track all files
-	if err := lockenv.LockFiles(context.Background(), validPaths, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), validPaths, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
@@ -603,7 +673,7 @@ func TestTrack_AllowsValidRelativePaths(t *testing.T) {
 	}
 
 	// Seal and unlock to verify the full cycle works
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -656,11 +726,11 @@ func TestUnlock_EnforcesSecureDirectoryPermissions(t *testing.T) {
 	}
 
 	// Track and seal
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -720,11 +790,11 @@ func TestUnlock_MasksNonExecutableFilePermissions(t *testing.T) {
 	}
 
 	// Track and seal
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -772,11 +842,11 @@ func TestUnlock_PreservesExecutableBitForOwnerOnly(t *testing.T) {
 	}
 
 	// Track and seal
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -836,11 +906,11 @@ func TestUnlock_VaultCopyHasSecurePermissions(t *testing.T) {
 	}
 
 	// Track and seal
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -907,7 +977,7 @@ func TestStandardMode_ManifestKeysPlaintext(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
 
@@ -933,3 +1003,856 @@ func TestStandardMode_ManifestKeysPlaintext(t *testing.T) {
 		t.Errorf("Expected path 'config.yml', got '%s'", entries[0].Path)
 	}
 }
+
+func TestFinalizeLock_OnlySelectedPaths(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	fileA := filepath.Join(dir, "a.env")
+	fileB := filepath.Join(dir, "b.env")
+	if err := os.WriteFile(fileA, []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(fileB, []byte("B=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := lockenv.LockFiles(context.Background(), []string{fileA, fileB}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	db, err := storage.Open(filepath.Join(dir, LockEnvFile))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	blobBefore, err := db.GetFileData(context.Background(), "b.env")
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	db.Close()
+
+	// Modify both files, but lock only a.env
+	if err := os.WriteFile(fileA, []byte("A=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if err := os.WriteFile(fileB, []byte("B=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	report, err := lockenv.LockFiles(context.Background(), []string{"a.env"}, password, false)
+	if err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	locked := report.Locked
+	if len(locked) != 1 || locked[0] != "a.env" {
+		t.Fatalf("Expected [a.env] to be locked, got %v", locked)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, locked); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	db, err = storage.Open(filepath.Join(dir, LockEnvFile))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	blobAfter, err := db.GetFileData(context.Background(), "b.env")
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	db.Close()
+	if string(blobBefore) != string(blobAfter) {
+		t.Error("b.env blob should not be re-encrypted when only a.env is locked")
+	}
+
+	// b.env should still be reported as modified
+	result, err := lockenv.GetChangedFiles(context.Background(), password)
+	if err != nil {
+		t.Fatalf("GetChangedFiles failed: %v", err)
+	}
+	if len(result.Changed) != 1 || result.Changed[0] != "b.env" {
+		t.Errorf("Expected only b.env to be changed, got %v", result.Changed)
+	}
+}
+
+func TestDeriveKeyAndUseKey(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if _, err := lockenv.DeriveKey(context.Background(), []byte("wrong")); err != ErrWrongPassword {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+
+	key, err := lockenv.DeriveKey(context.Background(), password)
+	if err != nil {
+		t.Fatalf("DeriveKey failed: %v", err)
+	}
+
+	// A second instance using only the key should work without a password
+	other, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer other.Close()
+
+	if err := other.VerifyPassword(nil); err != ErrPasswordRequired {
+		t.Errorf("Expected ErrPasswordRequired without key, got %v", err)
+	}
+
+	other.UseKey(key)
+	if err := other.VerifyPassword(nil); err != nil {
+		t.Errorf("VerifyPassword with key failed: %v", err)
+	}
+
+	testFile := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(testFile, []byte("KEY=value"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := other.LockFiles(context.Background(), []string{testFile}, nil, false); err != nil {
+		t.Fatalf("LockFiles with key failed: %v", err)
+	}
+	if _, err := other.FinalizeLock(context.Background(), nil, false, nil); err != nil {
+		t.Fatalf("FinalizeLock with key failed: %v", err)
+	}
+
+	// Files locked with the key must be readable with the password
+	result, err := lockenv.GetChangedFiles(context.Background(), password)
+	if err != nil {
+		t.Fatalf("GetChangedFiles failed: %v", err)
+	}
+	if len(result.Unchanged) != 1 {
+		t.Errorf("Expected 1 unchanged file, got %v", result)
+	}
+}
+
+func TestLockFiles_DoubleStarAndBraces(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	for _, name := range []string{"config/a.secret", "config/x/y/b.secret", "config/x/plain.txt", "dev.env", "prod.env"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	report, err := lockenv.LockFiles(context.Background(), []string{"config/**/*.secret", "{dev,prod}.env"}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	locked := report.Locked
+	want := []string{"config/a.secret", "config/x/y/b.secret", "dev.env", "prod.env"}
+	if len(locked) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, locked)
+	}
+	for i := range want {
+		if locked[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, locked)
+			break
+		}
+	}
+
+	files := []storage.FileEntry{{Path: "config/a.secret"}, {Path: "config/x/y/b.secret"}, {Path: "dev.env"}}
+	filtered := filterFilesByPatterns(files, []string{"config/**/*.secret"})
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 files to match config/**/*.secret, got %v", filtered)
+	}
+	filtered = filterFilesByPatterns(files, []string{"{dev,prod}.env"})
+	if len(filtered) != 1 || filtered[0].Path != "dev.env" {
+		t.Errorf("Expected dev.env to match braces, got %v", filtered)
+	}
+}
+
+func TestUnlockedFiles_NoPasswordRequired(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	for _, name := range []string{"a.env", "b.env", "c.env"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{filepath.Join(dir, "*.env")}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	// One locked away, one modified, one unchanged
+	if err := os.Remove(filepath.Join(dir, "a.env")); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.env"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	files, err := lockenv.UnlockedFiles(context.Background())
+	if err != nil {
+		t.Fatalf("UnlockedFiles failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 unlocked files, got %v", files)
+	}
+	for _, f := range files {
+		if f == "a.env" {
+			t.Errorf("a.env is not unlocked, got %v", files)
+		}
+	}
+}
+
+func TestTrackFiles_NeverLocked(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	file := filepath.Join(dir, ".env")
+	if err := os.WriteFile(file, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.TrackFiles(ctx, []string{".env"}, password, false); err != nil {
+		t.Fatalf("TrackFiles failed: %v", err)
+	}
+
+	status, err := lockenv.Status(ctx, false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.NeverLockedCount != 1 || status.Files[0].Status != "tracked, never locked" {
+		t.Errorf("Expected .env to be tracked but never locked, got %+v", status.Files)
+	}
+
+	result, err := lockenv.GetChangedFiles(ctx, password)
+	if err != nil {
+		t.Fatalf("GetChangedFiles failed: %v", err)
+	}
+	if len(result.NeverLocked) != 1 || len(result.Unchanged) != 0 {
+		t.Errorf("Expected .env as never locked, got %+v", result)
+	}
+
+	// Changing the password must not fail on files without content
+	newPassword := []byte("test456")
+	if err := lockenv.ChangePassword(password, newPassword); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	if _, err := lockenv.FinalizeLock(ctx, newPassword, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	status, err = lockenv.Status(ctx, false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.NeverLockedCount != 0 || status.Files[0].Status != "vault only" {
+		t.Errorf("Expected .env to be locked, got %+v", status.Files)
+	}
+}
+
+func TestStatus_SizeAndMtimeFastPath(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	file := filepath.Join(dir, ".env")
+	if err := os.WriteFile(file, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{".env"}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, false, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Failed to stat test file: %v", err)
+	}
+
+	// Same size and restored mtime: only a full status sees the change
+	if err := os.WriteFile(file, []byte("SECRET=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if err := os.Chtimes(file, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+
+	status, err := lockenv.Status(ctx, false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.UnchangedCount != 1 {
+		t.Errorf("Expected fast path to report unchanged, got %+v", status.Files)
+	}
+	status, err = lockenv.Status(ctx, true)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.ModifiedCount != 1 {
+		t.Errorf("Expected full status to report modified, got %+v", status.Files)
+	}
+
+	// A new mtime with the same content is hashed and still unchanged
+	later := info.ModTime().Add(time.Hour)
+	if err := os.WriteFile(file, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to restore test file: %v", err)
+	}
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+	status, err = lockenv.Status(ctx, false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.UnchangedCount != 1 {
+		t.Errorf("Expected unchanged after hashing, got %+v", status.Files)
+	}
+}
+
+func TestEmptyFileRoundTrip(t *testing.T) {
+	password := []byte("test-password")
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, password, map[string]string{"empty.env": "", "full.env": "A=1\n"})
+	path := filepath.Join(filepath.Dir(lockenv.path), "empty.env")
+
+	result, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil)
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if len(result.Extracted) != 2 || len(result.Errors) > 0 {
+		t.Fatalf("Unlock extracted %v, errors %v", result.Extracted, result.Errors)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("empty file not unlocked: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("unlocked size = %d, want 0", info.Size())
+	}
+
+	status, err := lockenv.Status(ctx, false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, f := range status.Files {
+		if f.Status != "unchanged" {
+			t.Errorf("%s: status %q, want unchanged", f.Path, f.Status)
+		}
+	}
+
+	verify, err := lockenv.VerifyVault(ctx, password)
+	if err != nil {
+		t.Fatalf("VerifyVault failed: %v", err)
+	}
+	if len(verify.Unchanged) != 2 {
+		t.Errorf("VerifyVault unchanged = %v, want both files", verify.Unchanged)
+	}
+
+	r, err := lockenv.OpenVaultFile(ctx, password, "empty.env")
+	if err != nil {
+		t.Fatalf("OpenVaultFile failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || len(data) != 0 {
+		t.Errorf("OpenVaultFile read %q, %v; want no content", data, err)
+	}
+
+	// Content written to the empty file is a conflict, resolved like any other
+	if err := os.WriteFile(path, []byte("B=2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, []string{"empty.env"}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("vault version not restored, got %q", data)
+	}
+
+	// Emptying a file with content and locking it stores zero bytes
+	fullPath := filepath.Join(filepath.Dir(lockenv.path), "full.env")
+	if err := os.WriteFile(fullPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{fullPath}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if got := vaultContents(t, lockenv, password)["full.env"]; got != "" {
+		t.Errorf("vault content = %q, want empty", got)
+	}
+}
+
+func TestLockFiles_MaxFileSize(t *testing.T) {
+	password := []byte("test-password")
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1\n"})
+	dir := filepath.Dir(lockenv.path)
+
+	big := filepath.Join(dir, "vendor.tar")
+	if err := os.WriteFile(big, make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	small := filepath.Join(dir, "small.env")
+	if err := os.WriteFile(small, []byte("B=2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockenv.SetMaxFileSize(1024)
+	report, err := lockenv.LockFiles(ctx, []string{big, small}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if len(report.Locked) != 1 || report.Locked[0] != "small.env" {
+		t.Errorf("Locked = %v, want only small.env", report.Locked)
+	}
+	if len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, ErrFileTooLarge) {
+		t.Fatalf("Failed = %v, want vendor.tar too large", report.Failed)
+	}
+	if !strings.Contains(report.Failed[0].Error(), "--allow-large") {
+		t.Errorf("error should mention --allow-large: %v", report.Failed[0])
+	}
+
+	lockenv.SetMaxFileSize(0)
+	report, err = lockenv.LockFiles(ctx, []string{big}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if len(report.Locked) != 1 || len(report.Failed) != 0 {
+		t.Errorf("without a limit: Locked = %v, Failed = %v", report.Locked, report.Failed)
+	}
+}
+
+func TestLockFiles_LargeBinary(t *testing.T) {
+	password := []byte("test-password")
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1\n"})
+	dir := filepath.Dir(lockenv.path)
+
+	blob := make([]byte, LargeBinarySize+1)
+	blob[10] = 0xff
+	binPath := filepath.Join(dir, "model.bin")
+	if err := os.WriteFile(binPath, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	textPath := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(textPath, []byte(strings.Repeat("KEY=value\n", LargeBinarySize/10+1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	smallBin := filepath.Join(dir, "key.der")
+	if err := os.WriteFile(smallBin, []byte{0x30, 0x82, 0x00, 0x01}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := lockenv.LockFiles(ctx, []string{binPath, textPath, smallBin}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if len(report.Locked) != 2 {
+		t.Errorf("Locked = %v, want big.txt and key.der", report.Locked)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Path != "model.bin" || !errors.Is(report.Skipped[0].Err, ErrLargeBinary) {
+		t.Fatalf("Skipped = %v, want model.bin as a large binary", report.Skipped)
+	}
+
+	// Tracking only stages the file, so it is not checked
+	report, err = lockenv.TrackFiles(ctx, []string{binPath}, password, false)
+	if err != nil {
+		t.Fatalf("TrackFiles failed: %v", err)
+	}
+	if len(report.Locked) != 1 {
+		t.Errorf("TrackFiles Locked = %v, want model.bin", report.Locked)
+	}
+
+	lockenv.AllowBinary(true)
+	report, err = lockenv.LockFiles(ctx, []string{binPath}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if len(report.Locked) != 1 || len(report.Skipped) != 0 {
+		t.Errorf("with AllowBinary: Locked = %v, Skipped = %v", report.Locked, report.Skipped)
+	}
+}
+
+func TestFinalizeLock_SkipsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "b.env": "B=1"})
+	dir := filepath.Dir(lockenv.path)
+	writeFile := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	relock := func(paths ...string) *LockReport {
+		t.Helper()
+		if _, err := lockenv.LockFiles(ctx, paths, password, false); err != nil {
+			t.Fatalf("LockFiles failed: %v", err)
+		}
+		report, err := lockenv.FinalizeLock(ctx, password, true, []string{"a.env", "b.env"})
+		if err != nil {
+			t.Fatalf("FinalizeLock failed: %v", err)
+		}
+		return report
+	}
+
+	// Relocking identical content leaves the vault byte for byte the same
+	before, err := os.ReadFile(lockenv.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aPath := writeFile("a.env", "A=1")
+	report := relock(aPath, writeFile("b.env", "B=1"))
+	if len(report.Locked) != 0 || len(report.Unchanged) != 2 {
+		t.Errorf("Expected 2 unchanged files, got %+v", report)
+	}
+	if len(report.Removed) != 2 {
+		t.Errorf("Unchanged files should still be removed, got %v", report.Removed)
+	}
+	after, err := os.ReadFile(lockenv.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("Vault changed although no file did")
+	}
+
+	// Only the changed file is encrypted again
+	report = relock(writeFile("a.env", "A=2"), writeFile("b.env", "B=1"))
+	if len(report.Locked) != 1 || report.Locked[0] != "a.env" || len(report.Unchanged) != 1 {
+		t.Errorf("Expected a.env locked and b.env unchanged, got %+v", report)
+	}
+	contents := vaultContents(t, lockenv, password)
+	if contents["a.env"] != "A=2" || contents["b.env"] != "B=1" {
+		t.Errorf("Unexpected vault contents: %v", contents)
+	}
+}
+
+func TestFinalizeLock_ResealsAfterDomainChange(t *testing.T) {
+	ctx := context.Background()
+	password, prodPassword := []byte("test123"), []byte("prod456")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	if err := lockenv.AddDomain(password, "prod", prodPassword); err != nil {
+		t.Fatalf("AddDomain failed: %v", err)
+	}
+	if err := lockenv.UseDomain("prod", prodPassword); err != nil {
+		t.Fatalf("UseDomain failed: %v", err)
+	}
+
+	// Same content, but the blob is still under the vault key
+	path := filepath.Join(filepath.Dir(lockenv.path), "a.env")
+	if err := os.WriteFile(path, []byte("A=1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if err := lockenv.AssignDomain(password, []string{"a.env"}, "prod"); err != nil {
+		t.Fatalf("AssignDomain failed: %v", err)
+	}
+	report, err := lockenv.FinalizeLock(ctx, password, true, []string{"a.env"})
+	if err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if len(report.Locked) != 1 || len(report.Unchanged) != 0 {
+		t.Errorf("Expected a.env to be sealed under the domain key, got %+v", report)
+	}
+	if contents := vaultContents(t, lockenv, password); contents["a.env"] != "A=1" {
+		t.Errorf("Unexpected vault contents: %v", contents)
+	}
+}
+
+func TestFinalizeLock_Deterministic(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	lockenv.SetDeterministic(true)
+	path := filepath.Join(filepath.Dir(lockenv.path), "a.env")
+
+	// relock locks content as a.env and returns the stored ciphertext
+	relock := func(content string) []byte {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+			t.Fatalf("LockFiles failed: %v", err)
+		}
+		if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+			t.Fatalf("FinalizeLock failed: %v", err)
+		}
+		db, err := storage.Open(lockenv.path)
+		if err != nil {
+			t.Fatalf("Failed to open vault: %v", err)
+		}
+		defer db.Close()
+		blob, err := db.GetFileData(context.Background(), "a.env")
+		if err != nil {
+			t.Fatalf("GetFileData failed: %v", err)
+		}
+		return blob
+	}
+
+	first := relock("A=2")
+	other := relock("A=3")
+	again := relock("A=2")
+	if !bytes.Equal(first, again) {
+		t.Error("Relocking the same content should give the same ciphertext")
+	}
+	if bytes.Equal(first, other) {
+		t.Error("Different content should give different ciphertext")
+	}
+	if contents := vaultContents(t, lockenv, password); contents["a.env"] != "A=2" {
+		t.Errorf("Unexpected vault contents: %v", contents)
+	}
+
+	// Without the option the same content gets a fresh nonce
+	lockenv.SetDeterministic(false)
+	relock("A=3")
+	if bytes.Equal(first, relock("A=2")) {
+		t.Error("Random nonces should give new ciphertext")
+	}
+}
+
+func TestFinalizeLock_DotenvSyntax(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	root := filepath.Dir(lockenv.path)
+
+	lock := func(name, content string) (*LockReport, error) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := lockenv.LockFiles(ctx, []string{name}, password, false); err != nil {
+			t.Fatalf("LockFiles failed: %v", err)
+		}
+		return lockenv.FinalizeLock(ctx, password, false, []string{name})
+	}
+
+	// Issues are warnings by default
+	report, err := lock("a.env", "A=1\nA=2\n")
+	if err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if len(report.Warnings) != 1 || !errors.Is(report.Warnings[0], ErrDotenvSyntax) || len(report.Locked) != 1 {
+		t.Errorf("Expected a.env locked with one warning, got %+v", report)
+	}
+
+	// With strict set, nothing is stored
+	lockenv.SetStrictDotenv(true)
+	report, err = lock("a.env", "A=3\nB='open\n")
+	if !errors.Is(err, ErrDotenvSyntax) {
+		t.Fatalf("Expected ErrDotenvSyntax, got %v", err)
+	}
+	if len(report.Failed) != 1 || len(report.Locked) != 0 {
+		t.Errorf("Expected a.env rejected, got %+v", report)
+	}
+	if contents := vaultContents(t, lockenv, password); contents["a.env"] != "A=1\nA=2\n" {
+		t.Errorf("Expected the vault to keep the earlier a.env, got %q", contents["a.env"])
+	}
+
+	// Files other than dotenv are not checked, and the format is recorded
+	if _, err := lock("config.json", "A=1\nA=2\n"); err != nil {
+		t.Fatalf("FinalizeLock failed for a JSON file: %v", err)
+	}
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	defer db.Close()
+	metadata, enc, err := lockenv.readMetadata(context.Background(), db, password)
+	if err != nil {
+		t.Fatalf("readMetadata failed: %v", err)
+	}
+	defer enc.Destroy()
+	if got := metadata.FindFile("a.env").Format; got != FormatDotenv {
+		t.Errorf("a.env format = %q, want %q", got, FormatDotenv)
+	}
+	if got := metadata.FindFile("config.json").Format; got != FormatJSON {
+		t.Errorf("config.json format = %q, want %q", got, FormatJSON)
+	}
+}
+
+func TestUseBackend(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	var opens int
+	storage.Register("test-counting", func(path string) (storage.Backend, error) {
+		opens++
+		return storage.Open(path)
+	})
+
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+	if err := lockenv.UseBackend("no-such-backend"); err == nil {
+		t.Error("Expected error for an unknown backend")
+	}
+	if err := lockenv.UseBackend("test-counting"); err != nil {
+		t.Fatalf("UseBackend failed: %v", err)
+	}
+
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	path := filepath.Join(dir, ".env")
+	if err := os.WriteFile(path, []byte("KEY=value"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if opens < 3 {
+		t.Errorf("Expected every operation to open the selected backend, got %d opens", opens)
+	}
+	if contents := vaultContents(t, lockenv, password); contents[".env"] != "KEY=value" {
+		t.Errorf("Unexpected vault contents: %v", contents)
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+	if err := lockenv.UseBackend(storage.MemoryBackend); err != nil {
+		t.Fatalf("UseBackend failed: %v", err)
+	}
+	t.Cleanup(func() { storage.DiscardMemory(lockenv.path) })
+
+	if _, err := lockenv.Status(ctx, false); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Expected ErrNotInitialized before Init, got %v", err)
+	}
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := lockenv.Init(password); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for a second Init, got %v", err)
+	}
+
+	path := filepath.Join(dir, ".env")
+	if err := os.WriteFile(path, []byte("KEY=value"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if _, err := os.Stat(lockenv.path); !os.IsNotExist(err) {
+		t.Errorf("Expected no vault file on disk, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed by lock, got %v", path, err)
+	}
+
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "KEY=value" {
+		t.Errorf("Unlocked %q, %v", data, err)
+	}
+}
+
+func TestSetKDFTargetTime(t *testing.T) {
+	lockenv, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	// A target below the time of the default iterations keeps the default
+	lockenv.SetKDFTargetTime(time.Microsecond)
+	if err := lockenv.Init([]byte("test123")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	status, err := lockenv.Status(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.KDFIterations != crypto.DefaultIters {
+		t.Errorf("KDFIterations = %d, want %d", status.KDFIterations, crypto.DefaultIters)
+	}
+}
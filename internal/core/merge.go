@@ -3,14 +3,17 @@ package core
 import (
 	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"unicode/utf8"
 
+	"github.com/illarion/lockenv/internal/output"
 	"github.com/sergi/go-diff/diffmatchpatch"
 	"golang.org/x/term"
 )
@@ -18,8 +21,35 @@ import (
 const (
 	BinarySampleSize   = 8192 // Bytes to sample for text/binary detection
 	BinaryThresholdPct = 10   // Max % non-printable chars for text files
+	diffContextLines   = 3    // Unchanged lines shown around each change
 )
 
+// MergeTempRepo as merge_temp_dir puts the conflict files of editor merges
+// in the project root instead of the world-readable system temp dir
+const MergeTempRepo = "repo"
+
+// MergeOptions configures the [e] option of interactive conflict resolution,
+// which hands decrypted content to an editor
+type MergeOptions struct {
+	TempDir        string   // Directory for the conflict file, empty for the system temp dir
+	Editor         string   // Editor command, overriding VISUAL and EDITOR
+	AllowedEditors []string // If set, the only editors allowed; listed editors are allowed even if denied
+	DeniedEditors  []string // Editors refused in addition to DeniedEditors
+
+	notice func(FileEvent) // Reports merge cleanup, set by LockEnv from SetNotify
+}
+
+// report hands event to the notice function of a LockEnv, if any
+func (o MergeOptions) report(event FileEvent) {
+	if o.notice != nil {
+		o.notice(event)
+	}
+}
+
+// DeniedEditors are refused for merges unless listed in AllowedEditors:
+// their extensions and built-in assistants can sync or upload open buffers
+var DeniedEditors = []string{"code", "code-insiders", "cursor", "windsurf"}
+
 // MergeStrategy defines how to handle file conflicts during unlock
 type MergeStrategy int
 
@@ -42,6 +72,40 @@ const (
 	ResolutionSkip
 )
 
+// String returns the strategy name used in debug logs
+func (s MergeStrategy) String() string {
+	switch s {
+	case StrategyAsk:
+		return "ask"
+	case StrategyKeepLocal:
+		return "keep-local"
+	case StrategyUseVault:
+		return "use-vault"
+	case StrategyKeepBoth:
+		return "keep-both"
+	case StrategyAbort:
+		return "abort"
+	}
+	return fmt.Sprintf("MergeStrategy(%d)", int(s))
+}
+
+// String returns the resolution name used in debug logs
+func (r ConflictResolution) String() string {
+	switch r {
+	case ResolutionKeepLocal:
+		return "keep-local"
+	case ResolutionUseVault:
+		return "use-vault"
+	case ResolutionEditMerged:
+		return "edit-merged"
+	case ResolutionKeepBoth:
+		return "keep-both"
+	case ResolutionSkip:
+		return "skip"
+	}
+	return fmt.Sprintf("ConflictResolution(%d)", int(r))
+}
+
 // ConflictResult contains the resolution and optionally merged data
 type ConflictResult struct {
 	Resolution ConflictResolution
@@ -53,6 +117,14 @@ type UnlockResult struct {
 	Extracted []string // Successfully extracted files
 	Skipped   []string // Files skipped due to conflicts or user choice
 	Errors    []string // Files with errors
+
+	// Events describes what happened to each file, in order, for the
+	// caller to show
+	Events EventLog
+
+	// MissingRequired lists the required files (see SetRequired) the unlock
+	// did not restore: excluded by the patterns, skipped or failed
+	MissingRequired []string
 }
 
 // DetectFileType determines if a file is likely text or binary.
@@ -109,8 +181,9 @@ func CompareFiles(local, vaultData []byte) bool {
 	return bytes.Equal(localHash[:], vaultHash[:])
 }
 
-// HandleConflict manages interactive conflict resolution for a file
-func HandleConflict(path string, localData, vaultData []byte, strategy MergeStrategy) (*ConflictResult, error) {
+// HandleConflict manages interactive conflict resolution for a file, with
+// editor merges configured by opts
+func HandleConflict(path string, localData, vaultData []byte, strategy MergeStrategy, opts MergeOptions) (*ConflictResult, error) {
 	switch strategy {
 	case StrategyKeepLocal:
 		return &ConflictResult{Resolution: ResolutionKeepLocal}, nil
@@ -125,7 +198,7 @@ func HandleConflict(path string, localData, vaultData []byte, strategy MergeStra
 	// Strategy is StrategyAsk - prompt user
 	isText := DetectFileType(localData) && DetectFileType(vaultData)
 
-	fmt.Printf("\nwarning: conflict detected: %s\n", path)
+	fmt.Printf("\n%s %s\n", output.Stdout().Yellow("warning: conflict detected:"), path)
 	fmt.Printf("   Local file exists and differs from vault version\n")
 
 	fileType := "binary"
@@ -140,11 +213,14 @@ func HandleConflict(path string, localData, vaultData []byte, strategy MergeStra
 		fmt.Printf("  [e] Edit merged (opens in $EDITOR)\n")
 	}
 	fmt.Printf("  [b] Keep both (save vault as .from-vault)\n")
+	if isText {
+		fmt.Printf("  [d] Show diff (values masked)\n")
+	}
 	fmt.Printf("  [x] Skip this file\n")
 
 	for {
 		fmt.Printf("\nYour choice: ")
-		choice, err := readChoice()
+		choice, err := ReadChoice()
 		if err != nil {
 			return &ConflictResult{Resolution: ResolutionSkip}, err
 		}
@@ -159,28 +235,40 @@ func HandleConflict(path string, localData, vaultData []byte, strategy MergeStra
 				fmt.Printf("Cannot edit merge for binary files\n")
 				continue
 			}
-			mergedData, err := handleEditMerge(path, localData, vaultData)
+			mergedData, err := handleEditMerge(opts, path, localData, vaultData)
 			if err != nil {
-				fmt.Printf("Error during merge: %v\n", err)
+				opts.report(FileEvent{Action: EventWarning, Path: path, Err: fmt.Errorf("editor merge failed: %w", err)})
 				continue
 			}
 			return &ConflictResult{Resolution: ResolutionEditMerged, MergedData: mergedData}, nil
 		case "b":
 			return &ConflictResult{Resolution: ResolutionKeepBoth}, nil
+		case "d":
+			if !isText {
+				fmt.Printf("Cannot show diff for binary files\n")
+				continue
+			}
+			diff, err := GenerateUnifiedDiff(path, vaultData, localData, DiffOptions{})
+			if err != nil {
+				fmt.Printf("Error generating diff: %v\n", err)
+				continue
+			}
+			fmt.Printf("\n%s", output.Stdout().Diff(diff))
 		case "x":
 			return &ConflictResult{Resolution: ResolutionSkip}, nil
 		default:
 			validOptions := "l, v, b, x"
 			if isText {
-				validOptions = "l, v, e, b, x"
+				validOptions = "l, v, e, b, d, x"
 			}
 			fmt.Printf("Invalid choice. Please enter %s\n", validOptions)
 		}
 	}
 }
 
-// readChoice reads a single character choice from the terminal
-func readChoice() (string, error) {
+// ReadChoice reads a single character choice from the terminal, lowercased
+// and echoed
+func ReadChoice() (string, error) {
 	// Try to use raw mode for single-key input
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err != nil {
@@ -205,8 +293,12 @@ func readChoice() (string, error) {
 	return choice, nil
 }
 
-// getEditor returns the editor to use, checking environment variables with fallback
-func getEditor() string {
+// getEditor returns the editor to use: the configured one if set, otherwise
+// checking environment variables with fallback
+func getEditor(configured string) string {
+	if configured != "" {
+		return configured
+	}
 	// Check VISUAL first (modern best practice)
 	if editor := os.Getenv("VISUAL"); editor != "" {
 		return editor
@@ -288,49 +380,167 @@ func buildConflictFromDiffs(diffs []diffmatchpatch.Diff) []byte {
 	return buf.Bytes()
 }
 
-// createConflictFile creates a temporary file with git-style conflict markers.
-// For text files, uses line-level diff to show only differences.
-// For binary files, falls back to whole-file markers.
-func createConflictFile(path string, localData, vaultData []byte) (*os.File, error) {
-	// Preserve original file extension for syntax highlighting
-	ext := filepath.Ext(path)
-	pattern := "lockenv-merge-*" + ext
+// createMergeDir creates an owner-only directory under base (the system temp
+// dir if empty) for one editor merge. Swap and backup files the editor writes
+// next to the conflict file end up there as well, and removeMergeDir shreds
+// them together with it.
+func createMergeDir(base string) (string, error) {
+	dir, err := os.MkdirTemp(base, ".lockenv-merge-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create merge directory: %w", err)
+	}
+	// Keep the directory out of git when base is inside the repository
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*\n"), 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to create merge directory: %w", err)
+	}
+	return dir, nil
+}
 
-	tmpFile, err := os.CreateTemp("", pattern)
+// removeMergeDir shreds the files in a merge directory and removes it,
+// reporting files other than the conflict file to opts: backups (file~,
+// #file#) and swap files (.file.swp) the editor left behind
+func removeMergeDir(opts MergeOptions, dir, conflictFile string) error {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return fmt.Errorf("failed to read merge directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to shred %s: %w", name, err)
+		}
+		if info.Mode().IsRegular() {
+			if err := shredFile(name, info.Size()); err != nil {
+				return fmt.Errorf("failed to shred %s: %w", name, err)
+			}
+		}
+		if name != conflictFile && entry.Name() != ".gitignore" {
+			opts.report(FileEvent{Action: EventShredded, Path: entry.Name(), Note: "editor leftover"})
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove merge directory: %w", err)
 	}
-	if err := os.Chmod(tmpFile.Name(), 0600); err != nil {
-		os.Remove(tmpFile.Name())
-		return nil, fmt.Errorf("failed to set temp file permissions: %w", err)
+	return nil
+}
+
+// createConflictFile creates a file with git-style conflict markers in dir,
+// named like path so the editor picks the right syntax highlighting.
+// For text files, uses line-level diff to show only differences.
+// For binary files, falls back to whole-file markers.
+func createConflictFile(dir, path string, localData, vaultData []byte) (string, error) {
+	name := filepath.Join(dir, filepath.Base(filepath.FromSlash(path)))
+	tmpFile, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
 	}
 
-	// Use line-level diff to show only differences
-	content := createLineDiff(localData, vaultData)
+	// Use line-level diff to show only differences, in the line endings and
+	// BOM of the local file so the editor keeps them
+	local, enc := normalizeText(localData)
+	vault, _ := normalizeText(vaultData)
+	content := enc.apply(createLineDiff(local, vault))
 
 	if _, err := tmpFile.Write(content); err != nil {
-		os.Remove(tmpFile.Name())
-		return nil, fmt.Errorf("failed to write conflict content: %w", err)
+		tmpFile.Close()
+		return "", fmt.Errorf("failed to write conflict content: %w", err)
 	}
 
 	if err := tmpFile.Close(); err != nil {
-		os.Remove(tmpFile.Name())
-		return nil, fmt.Errorf("failed to close temp file: %w", err)
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	return name, nil
+}
+
+// editorName returns the program name of an editor command, e.g. code for
+// /usr/bin/code or Code.exe
+func editorName(editor string) string {
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := strings.ToLower(filepath.Base(fields[0]))
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// checkEditor refuses an editor program that opts does not allow to see
+// decrypted content
+func checkEditor(editor string, opts MergeOptions) error {
+	name := editorName(editor)
+	if len(opts.AllowedEditors) > 0 {
+		for _, allowed := range opts.AllowedEditors {
+			if editorName(allowed) == name {
+				return nil
+			}
+		}
+		return fmt.Errorf("editor '%s' is not in merge.allowed_editors (%s)", name, strings.Join(opts.AllowedEditors, ", "))
+	}
+	if slices.Contains(DeniedEditors, name) {
+		return fmt.Errorf("editor '%s' may sync or upload open files; set merge.editor, or list it in merge.allowed_editors to use it anyway", name)
 	}
+	for _, denied := range opts.DeniedEditors {
+		if editorName(denied) == name {
+			return fmt.Errorf("editor '%s' is denied by merge.denied_editors", name)
+		}
+	}
+	return nil
+}
 
-	return tmpFile, nil
+// editorStateDirs returns the directories where editors commonly keep swap,
+// undo and backup files away from the edited file; replaced in tests
+var editorStateDirs = func() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" {
+		state = filepath.Join(home, ".local", "state")
+	}
+	return []string{
+		filepath.Join(home, ".vim", "swap"),
+		filepath.Join(home, ".vim", "undo"),
+		filepath.Join(home, ".vim", "backup"),
+		filepath.Join(state, "nvim", "swap"),
+		filepath.Join(state, "nvim", "undo"),
+		filepath.Join(state, "nvim", "backup"),
+		filepath.Join(home, ".emacs.d", "backups"),
+		filepath.Join(home, ".emacs.d", "auto-save-list"),
+	}
 }
 
-// invokeEditor opens the specified editor and waits for user to finish
-func invokeEditor(filename string) error {
-	editor := getEditor()
+// findEditorLeftovers returns files in editorStateDirs named after the merge
+// directory, such as vim's %tmp%.lockenv-merge-123%.env.swp. They may hold
+// decrypted content that removeMergeDir cannot reach.
+func findEditorLeftovers(mergeDir string) []string {
+	marker := filepath.Base(mergeDir)
+	var found []string
+	for _, dir := range editorStateDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), marker) {
+				found = append(found, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return found
+}
 
+// invokeEditor runs the editor command, a program and its arguments, on
+// filename and waits for the user to finish
+func invokeEditor(command []string, filename string) error {
 	// Check if editor is available
-	if _, err := exec.LookPath(editor); err != nil {
-		return fmt.Errorf("editor '%s' not found: %w\nPlease set VISUAL or EDITOR environment variable", editor, err)
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return fmt.Errorf("editor '%s' not found: %w\nPlease set merge.editor in the user config or the VISUAL or EDITOR environment variable", command[0], err)
 	}
 
-	cmd := exec.Command(editor, filename)
+	cmd := exec.Command(command[0], append(command[1:], filename)...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -346,33 +556,63 @@ func invokeEditor(filename string) error {
 	return err
 }
 
-// handleEditMerge orchestrates the editor-based merge workflow
-func handleEditMerge(path string, localData, vaultData []byte) ([]byte, error) {
+// handleEditMerge orchestrates the editor-based merge workflow. The editor
+// is checked against opts before it sees any content. The decrypted conflict
+// file lives in a private directory under opts.TempDir and is shredded, along
+// with any editor leftovers, once the editor exits; leftovers elsewhere are
+// reported.
+func handleEditMerge(opts MergeOptions, path string, localData, vaultData []byte) ([]byte, error) {
+	// Split once, so the program checked is the one that runs
+	command := strings.Fields(getEditor(opts.Editor))
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no editor configured")
+	}
+	if err := checkEditor(command[0], opts); err != nil {
+		return nil, err
+	}
+
+	dir, err := createMergeDir(opts.TempDir)
+	if err != nil {
+		return nil, err
+	}
+	var conflictFile string
+	defer func() {
+		for _, leftover := range findEditorLeftovers(dir) {
+			opts.report(FileEvent{Action: EventWarning, Path: leftover, Err: errors.New("editor wrote this file outside the merge directory; it may contain decrypted content")})
+		}
+		if err := removeMergeDir(opts, dir, conflictFile); err != nil {
+			opts.report(FileEvent{Action: EventWarning, Err: err})
+		}
+	}()
+
 	// Create temp file with conflict markers
-	tmpFile, err := createConflictFile(path, localData, vaultData)
+	conflictFile, err = createConflictFile(dir, path, localData, vaultData)
 	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(tmpFile.Name())
 
 	fmt.Printf("\nopening editor for merge...\n")
 
 	// Invoke editor
-	if err := invokeEditor(tmpFile.Name()); err != nil {
+	if err := invokeEditor(command, conflictFile); err != nil {
 		return nil, err
 	}
 
 	// Read the edited result
-	mergedData, err := os.ReadFile(tmpFile.Name())
+	mergedData, err := os.ReadFile(conflictFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read edited file: %w", err)
 	}
+	if len(mergedData) > 0 {
+		_, enc := normalizeText(localData)
+		mergedData = enc.apply(mergedData)
+	}
 
 	// Warn if file is empty
 	if len(mergedData) == 0 {
 		fmt.Printf("\nwarning: edited file is empty\n")
 		fmt.Printf("Use this empty content? [y/N]: ")
-		choice, err := readChoice()
+		choice, err := ReadChoice()
 		if err != nil {
 			return nil, err
 		}
@@ -385,7 +625,7 @@ func handleEditMerge(path string, localData, vaultData []byte) ([]byte, error) {
 	if hasConflictMarkers(mergedData) {
 		fmt.Printf("\nwarning: conflict markers still present in file\n")
 		fmt.Printf("Continue anyway? [y/N]: ")
-		choice, err := readChoice()
+		choice, err := ReadChoice()
 		if err != nil {
 			return nil, err
 		}
@@ -405,36 +645,134 @@ func hasConflictMarkers(data []byte) bool {
 }
 
 // GenerateUnifiedDiff generates a unified diff using go-diff library
-// Returns the diff output, or empty string if files are identical
-func GenerateUnifiedDiff(path string, vaultData, localData []byte) (string, error) {
+// Returns the diff output, or empty string if files are identical.
+// Values are masked unless opts.ShowSecrets is set.
+func GenerateUnifiedDiff(path string, vaultData, localData []byte, opts DiffOptions) (string, error) {
+	return generateUnifiedDiff(path, path, vaultData, localData, opts)
+}
+
+// generateUnifiedDiff is GenerateUnifiedDiff with separate paths for the
+// old and new side, as when comparing two files of the vault
+func generateUnifiedDiff(oldPath, path string, vaultData, localData []byte, opts DiffOptions) (string, error) {
 	if CompareFiles(vaultData, localData) {
 		return "", nil
 	}
 
 	// Check if binary
 	if !DetectFileType(vaultData) || !DetectFileType(localData) {
-		return fmt.Sprintf("Binary file %s has changed\n", path), nil
+		label := path
+		if oldPath != path {
+			label = oldPath + " => " + path
+		}
+		return formatBinaryDiff(label, vaultData, localData, opts.HexdumpBytes), nil
+	}
+
+	// Compare the text itself; a change of line endings or BOM is noted
+	// after the file headers instead of marking every line as changed
+	vaultText, vaultEnc := normalizeText(vaultData)
+	localText, localEnc := normalizeText(localData)
+	notes := encodingChanges(vaultEnc, localEnc)
+	header := fmt.Sprintf("--- a/%s\n+++ b/%s\n", oldPath, path)
+
+	// Key-level diff for structured configs, line diff if they don't parse
+	if format := DetectStructuredFormat(path); format != "" {
+		if diff, err := GenerateStructuredDiff(path, format, vaultText, localText, opts.ShowSecrets); err == nil {
+			return header + notes + strings.TrimPrefix(diff, fmt.Sprintf("--- a/%s\n+++ b/%s\n", path, path)), nil
+		}
 	}
 
 	dmp := diffmatchpatch.New()
 
 	// Line-mode diff for better output
-	vaultStr, localStr := string(vaultData), string(localData)
-	a, b, lineArray := dmp.DiffLinesToChars(vaultStr, localStr)
+	a, b, lineArray := dmp.DiffLinesToChars(string(vaultText), string(localText))
 	diffs := dmp.DiffMain(a, b, false)
 	diffs = dmp.DiffCharsToLines(diffs, lineArray)
 
-	// Create patches and format
-	patches := dmp.PatchMake(vaultStr, diffs)
-	if len(patches) == 0 {
+	hunks := formatHunks(diffs, opts.ShowSecrets)
+	if hunks == "" && notes == "" {
 		return "", nil
 	}
 
-	// Add file headers and format output
+	return header + notes + hunks, nil
+}
+
+// diffLine is a single line of a line-mode diff
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// formatHunks renders line-mode diffs as unified diff hunks with
+// diffContextLines lines of context, masking values unless showSecrets is set
+func formatHunks(diffs []diffmatchpatch.Diff, showSecrets bool) string {
+	var lines []diffLine
+	for _, d := range diffs {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text != "" {
+				lines = append(lines, diffLine{op: op, text: strings.TrimSuffix(text, "\n")})
+			}
+		}
+	}
+
+	// Line numbers in the old and new file before each diff line
+	oldNo := make([]int, len(lines)+1)
+	newNo := make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldNo[i+1], newNo[i+1] = oldNo[i], newNo[i]
+		if line.op != '+' {
+			oldNo[i+1]++
+		}
+		if line.op != '-' {
+			newNo[i+1]++
+		}
+	}
+
 	var result strings.Builder
-	result.WriteString(fmt.Sprintf("--- a/%s\n", path))
-	result.WriteString(fmt.Sprintf("+++ b/%s\n", path))
-	result.WriteString(dmp.PatchToText(patches))
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		last := i
+		for j := i; j < len(lines) && j-last <= 2*diffContextLines; j++ {
+			if lines[j].op != ' ' {
+				last = j
+			}
+		}
+		start := max(0, i-diffContextLines)
+		end := min(len(lines), last+diffContextLines+1)
+
+		oldCount := oldNo[end] - oldNo[start]
+		newCount := newNo[end] - newNo[start]
+		result.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", hunkStart(oldNo[start], oldCount), oldCount, hunkStart(newNo[start], newCount), newCount))
+		for _, line := range lines[start:end] {
+			text := line.text
+			if !showSecrets {
+				text = RedactLine(text)
+			}
+			result.WriteString(string(line.op) + text + "\n")
+		}
+
+		i = end
+	}
 
-	return result.String(), nil
+	return result.String()
+}
+
+// hunkStart returns the 1-based start line of a hunk range; empty ranges
+// point at the line before, as in GNU diff
+func hunkStart(before, count int) int {
+	if count == 0 {
+		return before
+	}
+	return before + 1
 }
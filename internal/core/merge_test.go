@@ -1,6 +1,9 @@
 package core
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -273,6 +276,91 @@ func TestCreateLineDiff_RemovedLines(t *testing.T) {
 	}
 }
 
+func TestGenerateUnifiedDiff_BinarySummary(t *testing.T) {
+	oldData := []byte{0x00, 0x01, 0x02, 0x03, 0x04}
+	newData := []byte{0x00, 0x01, 0xff, 0x03, 0x04, 0x05}
+
+	diff, err := GenerateUnifiedDiff("keystore.jks", oldData, newData, DiffOptions{})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+
+	if !contains(diff, "Binary file keystore.jks has changed") {
+		t.Error("Diff should report binary change")
+	}
+	if !contains(diff, "5 -> 6 bytes") {
+		t.Errorf("Diff should report sizes, got:\n%s", diff)
+	}
+	if !contains(diff, "sha256:") {
+		t.Error("Diff should report hashes")
+	}
+	if !contains(diff, "application/octet-stream") {
+		t.Error("Diff should report detected type")
+	}
+	if contains(diff, "first difference") {
+		t.Error("Hexdump should be disabled by default")
+	}
+}
+
+func TestGenerateUnifiedDiff_BinaryHexdump(t *testing.T) {
+	oldData := make([]byte, 40)
+	newData := make([]byte, 40)
+	copy(newData, oldData)
+	newData[20] = 0xab
+
+	diff, err := GenerateUnifiedDiff("keystore.jks", oldData, newData, DiffOptions{HexdumpBytes: 16})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+
+	if !contains(diff, "first difference at offset 0x14") {
+		t.Errorf("Diff should report first differing offset, got:\n%s", diff)
+	}
+	if !contains(diff, "- 00000010") || !contains(diff, "+ 00000010") {
+		t.Errorf("Diff should hexdump the row containing the difference, got:\n%s", diff)
+	}
+	if !contains(diff, " ab ") {
+		t.Error("Hexdump should contain the changed byte")
+	}
+	if contains(diff, "00000020") {
+		t.Error("Hexdump should be limited to the requested number of bytes")
+	}
+}
+
+func TestGenerateUnifiedDiff_EmptyFiles(t *testing.T) {
+	diff, err := GenerateUnifiedDiff(".env", nil, []byte{}, DiffOptions{})
+	if err != nil || diff != "" {
+		t.Errorf("empty vs empty: got %q, %v; want no diff", diff, err)
+	}
+
+	diff, err = GenerateUnifiedDiff("notes.txt", nil, []byte("line1\n"), DiffOptions{ShowSecrets: true})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	if !contains(diff, "@@ -0,0 +1,1 @@") || !contains(diff, "+line1") {
+		t.Errorf("empty vault version should diff as an addition, got:\n%s", diff)
+	}
+
+	diff, err = GenerateUnifiedDiff("notes.txt", []byte("line1\n"), nil, DiffOptions{ShowSecrets: true})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	if !contains(diff, "@@ -1,1 +0,0 @@") || !contains(diff, "-line1") {
+		t.Errorf("emptied local file should diff as a removal, got:\n%s", diff)
+	}
+
+	diff, err = GenerateUnifiedDiff("keystore.jks", []byte{0x00, 0x01}, nil, DiffOptions{HexdumpBytes: 16})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	if !contains(diff, "2 -> 0 bytes") || !contains(diff, "application/octet-stream -> empty") {
+		t.Errorf("binary diff against empty content, got:\n%s", diff)
+	}
+	if !contains(diff, "- 00000000") || contains(diff, "+ 00000000") {
+		t.Errorf("hexdump should only show the old row, got:\n%s", diff)
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
 		(len(s) > 0 && len(substr) > 0 && findSubstring(s, substr)))
@@ -296,3 +384,147 @@ func countOccurrences(s, substr string) int {
 	}
 	return count
 }
+
+func TestHandleEditMerge_ShredsTempFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake editor is a shell script")
+	}
+	tempDir := t.TempDir()
+	seen := filepath.Join(t.TempDir(), "seen")
+	editor := filepath.Join(t.TempDir(), "editor")
+	// Resolve the conflict and leave a backup and a swap file behind, as vim
+	// and emacs do in the directory of the edited file
+	script := "#!/bin/sh\n" +
+		"echo \"$1\" > " + seen + "\n" +
+		"cp \"$1\" \"$1~\"\n" +
+		"touch \"$(dirname \"$1\")/.$(basename \"$1\").swp\"\n" +
+		"printf 'A=1\\nB=merged\\n' > \"$1\"\n"
+	if err := os.WriteFile(editor, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", editor)
+
+	var events EventLog
+	opts := MergeOptions{TempDir: tempDir, notice: func(e FileEvent) { events = append(events, e) }}
+	merged, err := handleEditMerge(opts, "config/.env", []byte("A=1\nB=local\n"), []byte("A=1\nB=vault\n"))
+	if err != nil {
+		t.Fatalf("handleEditMerge failed: %v", err)
+	}
+	if string(merged) != "A=1\nB=merged\n" {
+		t.Errorf("merged = %q", merged)
+	}
+	// The backup and the swap file are reported, not printed
+	if n := events.Count(EventShredded); n != 2 {
+		t.Errorf("Expected 2 shredded leftovers reported, got %d: %v", n, events)
+	}
+
+	edited, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := filepath.Dir(string(edited[:len(edited)-1])); filepath.Dir(dir) != tempDir {
+		t.Errorf("conflict file in %s, want a directory under %s", dir, tempDir)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("temp dir not cleaned up: %v", entries)
+	}
+}
+
+func TestHandleEditMerge_EditorArguments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake editor is a shell script")
+	}
+	seen := filepath.Join(t.TempDir(), "seen")
+	editor := filepath.Join(t.TempDir(), "editor")
+	script := "#!/bin/sh\n" +
+		"echo \"$1 $2\" > " + seen + "\n" +
+		"printf 'A=merged\\n' > \"$3\"\n"
+	if err := os.WriteFile(editor, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := MergeOptions{TempDir: t.TempDir(), Editor: editor + " -u NONE"}
+	merged, err := handleEditMerge(opts, ".env", []byte("A=1\n"), []byte("A=2\n"))
+	if err != nil {
+		t.Fatalf("handleEditMerge failed: %v", err)
+	}
+	if string(merged) != "A=merged\n" {
+		t.Errorf("merged = %q", merged)
+	}
+	if args, _ := os.ReadFile(seen); string(args) != "-u NONE\n" {
+		t.Errorf("editor arguments = %q, want -u NONE", args)
+	}
+}
+
+func TestCheckEditor(t *testing.T) {
+	tests := []struct {
+		editor string
+		opts   MergeOptions
+		ok     bool
+	}{
+		{"vim", MergeOptions{}, true},
+		{"/usr/bin/code", MergeOptions{}, false},
+		{"Cursor.exe", MergeOptions{}, false},
+		{"code --wait", MergeOptions{AllowedEditors: []string{"code"}}, true},
+		{"nano", MergeOptions{AllowedEditors: []string{"vim", "/usr/bin/nvim"}}, false},
+		{"/opt/nvim/bin/nvim", MergeOptions{AllowedEditors: []string{"vim", "/usr/bin/nvim"}}, true},
+		{"subl", MergeOptions{DeniedEditors: []string{"subl"}}, false},
+		{"nano", MergeOptions{DeniedEditors: []string{"subl"}}, true},
+	}
+	for _, tt := range tests {
+		err := checkEditor(tt.editor, tt.opts)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkEditor(%q, %+v) = %v, want ok=%v", tt.editor, tt.opts, err, tt.ok)
+		}
+	}
+}
+
+func TestHandleEditMerge_DeniedEditorSeesNothing(t *testing.T) {
+	tempDir := t.TempDir()
+	_, err := handleEditMerge(MergeOptions{TempDir: tempDir, Editor: "cursor"}, ".env", []byte("A=1\n"), []byte("A=2\n"))
+	if err == nil {
+		t.Fatal("Expected denied editor to be refused")
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("conflict file written for a denied editor: %v", entries)
+	}
+}
+
+func TestFindEditorLeftovers(t *testing.T) {
+	swap := t.TempDir()
+	orig := editorStateDirs
+	editorStateDirs = func() []string { return []string{swap, filepath.Join(swap, "missing")} }
+	t.Cleanup(func() { editorStateDirs = orig })
+
+	mergeDir := filepath.Join(t.TempDir(), ".lockenv-merge-123")
+	leftover := filepath.Join(swap, "%tmp%.lockenv-merge-123%.env.swp")
+	for _, name := range []string{leftover, filepath.Join(swap, "%home%notes.txt.swp")} {
+		if err := os.WriteFile(name, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := findEditorLeftovers(mergeDir)
+	if len(found) != 1 || found[0] != leftover {
+		t.Errorf("findEditorLeftovers = %v, want [%s]", found, leftover)
+	}
+}
+
+func TestMergeOptions(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for setting, want := range map[string]string{
+		"":            "",
+		MergeTempRepo: dir,
+		"/dev/shm":    "/dev/shm",
+		"tmp/merge":   filepath.Join(dir, "tmp", "merge"),
+	} {
+		l.SetMergeOptions(MergeOptions{TempDir: setting})
+		if got := l.mergeOptions().TempDir; got != want {
+			t.Errorf("mergeOptions() with TempDir %q = %q, want %q", setting, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindVaultDir returns the directory of the vault nearest to dir, looking in
+// dir and then in each parent up to the root of its git working tree, like
+// git looks for .git. The result is dir joined with the path up to the
+// vault ("../.."), or dir itself when no directory up to the working tree
+// root holds a vault. Outside a git working tree only dir is considered, so
+// a stray vault in a parent directory is never picked up.
+func FindVaultDir(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	var found string
+	for current := abs; ; {
+		if found == "" {
+			if _, err := os.Stat(filepath.Join(current, LockEnvFile)); err == nil {
+				found = current
+			}
+		}
+		if _, err := os.Lstat(filepath.Join(current, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			// Not in a git working tree
+			return dir
+		}
+		current = parent
+	}
+	if found == "" {
+		return dir
+	}
+	rel, err := filepath.Rel(abs, found)
+	if err != nil {
+		return dir
+	}
+	return filepath.Join(dir, rel)
+}
+
+// skippedVaultSearchDirs are not searched for vaults by FindVaults
+var skippedVaultSearchDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// FindVaults returns the directories under root holding a vault, root
+// included, in lexical order. Hidden directories (.git, .lockenv.d and the
+// like), node_modules and vendor are not searched.
+func FindVaults(ctx context.Context, root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || skippedVaultSearchDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == LockEnvFile {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+// VaultSummary is the status of one vault found by StatusAll
+type VaultSummary struct {
+	Dir    string      // Vault directory relative to the searched root
+	Status *StatusInfo // Nil when Err is set
+	Err    error
+}
+
+// StatusTotals adds up the file counts of the vaults StatusAll summarized
+type StatusTotals struct {
+	Vaults      int
+	Failed      int // Vaults whose status could not be read
+	Files       int
+	Unchanged   int
+	Modified    int
+	Sealed      int
+	NeverLocked int
+	Size        int64
+}
+
+// StatusAll finds every vault under root with FindVaults and returns the
+// status of each (implements `lockenv status --all`), along with the totals
+// over all of them. A vault whose status cannot be read is reported in its
+// VaultSummary and does not stop the others from being summarized.
+func StatusAll(ctx context.Context, root string, full bool) ([]VaultSummary, StatusTotals, error) {
+	var totals StatusTotals
+	dirs, err := FindVaults(ctx, root)
+	if err != nil {
+		return nil, totals, err
+	}
+
+	summaries := make([]VaultSummary, 0, len(dirs))
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			rel = dir
+		}
+		summary := VaultSummary{Dir: rel}
+		summary.Status, summary.Err = vaultStatus(ctx, dir, full)
+		if err := ctx.Err(); err != nil {
+			return nil, totals, err
+		}
+
+		totals.Vaults++
+		if summary.Err != nil {
+			totals.Failed++
+		} else {
+			totals.Files += summary.Status.TrackedCount
+			totals.Unchanged += summary.Status.UnchangedCount
+			totals.Modified += summary.Status.ModifiedCount
+			totals.Sealed += summary.Status.SealedCount
+			totals.NeverLocked += summary.Status.NeverLockedCount
+			totals.Size += summary.Status.TotalSize
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, totals, nil
+}
+
+// vaultStatus returns the status of the vault in exactly dir
+func vaultStatus(ctx context.Context, dir string, full bool) (*StatusInfo, error) {
+	l, err := NewAt(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	return l.Status(ctx, full)
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindVaultDir(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git", "web/src", "svc/api/config"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+	}
+	for _, dir := range []string{".", "svc/api"} {
+		if err := os.WriteFile(filepath.Join(root, dir, LockEnvFile), nil, 0644); err != nil {
+			t.Fatalf("Failed to create test vault: %v", err)
+		}
+	}
+
+	tests := map[string]string{
+		".":              ".",
+		"web/src":        ".",
+		"svc":            ".",
+		"svc/api":        "svc/api",
+		"svc/api/config": "svc/api",
+	}
+	for dir, want := range tests {
+		got := FindVaultDir(filepath.Join(root, dir))
+		if got != filepath.Join(root, want) {
+			t.Errorf("FindVaultDir(%s) = %s, want %s", dir, got, filepath.Join(root, want))
+		}
+	}
+
+	// Relative directories stay relative
+	t.Chdir(filepath.Join(root, "svc/api/config"))
+	if got := FindVaultDir("."); got != ".." {
+		t.Errorf("FindVaultDir(.) = %s, want ..", got)
+	}
+
+	// The search stops at the root of the git working tree
+	outer := t.TempDir()
+	repo := filepath.Join(outer, "repo", "sub")
+	if err := os.MkdirAll(filepath.Join(outer, "repo", ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outer, LockEnvFile), nil, 0644); err != nil {
+		t.Fatalf("Failed to create test vault: %v", err)
+	}
+	if got := FindVaultDir(repo); got != repo {
+		t.Errorf("FindVaultDir picked a vault above the working tree: %s", got)
+	}
+
+	// Outside a working tree, parents are not searched
+	plain := filepath.Join(outer, "plain")
+	if err := os.MkdirAll(plain, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if got := FindVaultDir(plain); got != plain {
+		t.Errorf("FindVaultDir searched parents outside a working tree: %s", got)
+	}
+}
+
+func TestStatusAll(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	root := t.TempDir()
+
+	// A vault at the root with one file, a nested one with a modified file,
+	// and vaults in directories that are not searched
+	vaults := map[string]map[string]string{
+		".":                 {"root.env": "A=1"},
+		"svc/api":           {"api.env": "B=2", "config/key.pem": "KEY"},
+		"node_modules/pkg":  {"pkg.env": "C=3"},
+		".cache/vault":      {"cache.env": "D=4"},
+		"vendor/lib/nested": {"lib.env": "E=5"},
+	}
+	for _, dir := range []string{"node_modules/pkg", ".cache/vault", "vendor/lib/nested", "svc/api", "."} {
+		vaultDir := filepath.Join(root, dir)
+		if err := os.MkdirAll(vaultDir, 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+		lockenv, err := NewAt(vaultDir)
+		if err != nil {
+			t.Fatalf("Failed to create LockEnv: %v", err)
+		}
+		if err := lockenv.Init(password); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+		var paths []string
+		for name, content := range vaults[dir] {
+			path := filepath.Join(vaultDir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create test directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			paths = append(paths, path)
+		}
+		if _, err := lockenv.LockFiles(ctx, paths, password, false); err != nil {
+			t.Fatalf("LockFiles failed: %v", err)
+		}
+		if _, err := lockenv.FinalizeLock(ctx, password, false, nil); err != nil {
+			t.Fatalf("FinalizeLock failed: %v", err)
+		}
+		lockenv.Close()
+	}
+	if err := os.WriteFile(filepath.Join(root, "svc/api/api.env"), []byte("B=3"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	summaries, totals, err := StatusAll(ctx, root, true)
+	if err != nil {
+		t.Fatalf("StatusAll failed: %v", err)
+	}
+	var dirs []string
+	for _, summary := range summaries {
+		if summary.Err != nil {
+			t.Errorf("Status of %s failed: %v", summary.Dir, summary.Err)
+		}
+		dirs = append(dirs, summary.Dir)
+	}
+	if !slices.Equal(dirs, []string{".", filepath.FromSlash("svc/api")}) {
+		t.Fatalf("Expected the root and svc/api vaults, got %v", dirs)
+	}
+	if summaries[1].Status.ModifiedCount != 1 || summaries[1].Status.UnchangedCount != 1 {
+		t.Errorf("Expected one modified and one unchanged file in svc/api, got %+v", summaries[1].Status)
+	}
+
+	want := StatusTotals{Vaults: 2, Files: 3, Unchanged: 2, Modified: 1, Size: 9}
+	if totals != want {
+		t.Errorf("Totals = %+v, want %+v", totals, want)
+	}
+}
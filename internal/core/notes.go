@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SetNote sets the annotation of a file in the vault, encrypted with the
+// rest of its details (implements `lockenv note`). An empty note removes it.
+func (l *LockEnv) SetNote(ctx context.Context, password []byte, path, note string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !l.exists() {
+		return ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return err
+	}
+	defer enc.Destroy()
+
+	file := metadata.FindFile(strings.TrimPrefix(path, "./"))
+	if file == nil {
+		return fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+	}
+	file.Note = strings.TrimSpace(note)
+	metadata.Modified = time.Now()
+	return l.saveMetadata(db, metadata, enc)
+}
+
+// Notes returns the annotations of the files in the vault, by path. Files
+// without a note are left out.
+func (l *LockEnv) Notes(ctx context.Context, password []byte) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	notes := make(map[string]string)
+	for _, file := range metadata.Files {
+		if file.Note != "" {
+			notes[file.Path] = file.Note
+		}
+	}
+	return notes, nil
+}
+
+// Note returns the annotation of a file in the vault, empty if it has none
+func (l *LockEnv) Note(ctx context.Context, password []byte, path string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !l.exists() {
+		return "", ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return "", openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return "", err
+	}
+	defer enc.Destroy()
+
+	file := metadata.FindFile(strings.TrimPrefix(path, "./"))
+	if file == nil {
+		return "", fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+	}
+	return file.Note, nil
+}
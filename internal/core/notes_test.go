@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNotes(t *testing.T) {
+	password := []byte("test-password")
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1", "b.env": "B=1"})
+
+	if err := lockenv.SetNote(ctx, password, "./.env", " rotate quarterly; owned by SRE "); err != nil {
+		t.Fatalf("SetNote failed: %v", err)
+	}
+	note, err := lockenv.Note(ctx, password, ".env")
+	if err != nil {
+		t.Fatalf("Note failed: %v", err)
+	}
+	if note != "rotate quarterly; owned by SRE" {
+		t.Errorf("Note() = %q", note)
+	}
+
+	// Relocking keeps the note
+	path := filepath.Join(filepath.Dir(lockenv.path), ".env")
+	if err := os.WriteFile(path, []byte("A=2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	notes, err := lockenv.Notes(ctx, password)
+	if err != nil {
+		t.Fatalf("Notes failed: %v", err)
+	}
+	if len(notes) != 1 || notes[".env"] != "rotate quarterly; owned by SRE" {
+		t.Errorf("Notes() after relock = %v", notes)
+	}
+
+	if err := lockenv.SetNote(ctx, password, ".env", ""); err != nil {
+		t.Fatalf("SetNote failed: %v", err)
+	}
+	if notes, _ := lockenv.Notes(ctx, password); len(notes) != 0 {
+		t.Errorf("Notes() after clearing = %v", notes)
+	}
+}
+
+func TestSetNote_FileNotInVault(t *testing.T) {
+	password := []byte("test-password")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1"})
+
+	err := lockenv.SetNote(context.Background(), password, "missing.env", "x")
+	if !errors.Is(err, ErrFileNotInVault) {
+		t.Errorf("SetNote() error = %v, want ErrFileNotInVault", err)
+	}
+}
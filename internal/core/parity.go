@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// ParityGap is a key defined in some of the files compared by CheckParity
+// but missing from File
+type ParityGap struct {
+	File      string   // File missing the key
+	Key       string   // Dotenv variable, or key path of a structured file
+	DefinedIn []string // Files defining the key
+}
+
+// ParityResult is the outcome of CheckParity
+type ParityResult struct {
+	Files   []string    // Compared files, as given
+	Keys    int         // Distinct keys defined across the files
+	Missing []ParityGap // Keys missing from a file, by file then key
+}
+
+// OK reports whether every file defines the same keys
+func (r *ParityResult) OK() bool {
+	return len(r.Missing) == 0
+}
+
+// CompareParity returns the keys defined in some of the files but missing
+// from others. contents holds the data of each file in paths; each must be
+// a dotenv or structured file.
+func CompareParity(paths []string, contents [][]byte) (*ParityResult, error) {
+	result := &ParityResult{Files: paths}
+	fileKeySets := make([][]string, len(paths))
+	var allKeys []string
+	for i, path := range paths {
+		keys, ok := fileKeys(path, contents[i])
+		if !ok {
+			return nil, fmt.Errorf("%s: not a dotenv, JSON, YAML or TOML file", path)
+		}
+		fileKeySets[i] = keys
+		for _, key := range keys {
+			if !slices.Contains(allKeys, key) {
+				allKeys = append(allKeys, key)
+			}
+		}
+	}
+	result.Keys = len(allKeys)
+
+	for i, path := range paths {
+		for _, key := range allKeys {
+			if slices.Contains(fileKeySets[i], key) {
+				continue
+			}
+			gap := ParityGap{File: path, Key: key}
+			for j, other := range paths {
+				if slices.Contains(fileKeySets[j], key) {
+					gap.DefinedIn = append(gap.DefinedIn, other)
+				}
+			}
+			result.Missing = append(result.Missing, gap)
+		}
+	}
+	return result, nil
+}
+
+// CheckParity compares the keys defined in the vault files at paths
+// (implements `lockenv check-parity`), reporting the keys some of them
+// define and others lack, e.g. a variable set in .env.dev but missing from
+// .env.prod. Values are never compared or returned. With local set, the
+// working-tree copies are compared instead and password is not used.
+func (l *LockEnv) CheckParity(ctx context.Context, password []byte, paths []string, local bool) (*ParityResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(paths) < 2 {
+		return nil, fmt.Errorf("check-parity needs at least two files")
+	}
+	paths = slices.Clone(paths)
+	for i, path := range paths {
+		paths[i] = strings.TrimPrefix(filepath.ToSlash(path), "./")
+	}
+
+	contents := make([][]byte, len(paths))
+	defer func() {
+		for _, data := range contents {
+			crypto.ClearBytes(data)
+		}
+	}()
+
+	if local {
+		for i, path := range paths {
+			data, err := l.validator.ReadFileInRoot(path)
+			if err != nil {
+				return nil, err
+			}
+			contents[i] = data
+		}
+		return CompareParity(paths, contents)
+	}
+
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	for i, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file := metadata.FindFile(path)
+		if file == nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+		}
+		plain, err := l.decryptEntry(ctx, db, file, enc)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = slices.Clone(plain.Borrow())
+		plain.Close()
+	}
+	return CompareParity(paths, contents)
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCompareParity(t *testing.T) {
+	paths := []string{".env.dev", ".env.staging", ".env.prod"}
+	contents := [][]byte{
+		[]byte("A=1\nDEBUG=true\n"),
+		[]byte("A=2\nDEBUG=false\nSENTRY_DSN=x\n"),
+		[]byte("export A=3\nSENTRY_DSN=y\n"),
+	}
+	result, err := CompareParity(paths, contents)
+	if err != nil {
+		t.Fatalf("CompareParity failed: %v", err)
+	}
+	if result.OK() || result.Keys != 3 {
+		t.Errorf("Expected missing keys out of 3, got %+v", result)
+	}
+	want := []ParityGap{
+		{File: ".env.dev", Key: "SENTRY_DSN", DefinedIn: []string{".env.staging", ".env.prod"}},
+		{File: ".env.prod", Key: "DEBUG", DefinedIn: []string{".env.dev", ".env.staging"}},
+	}
+	if !slices.EqualFunc(result.Missing, want, func(a, b ParityGap) bool {
+		return a.File == b.File && a.Key == b.Key && slices.Equal(a.DefinedIn, b.DefinedIn)
+	}) {
+		t.Errorf("Missing = %+v, want %+v", result.Missing, want)
+	}
+
+	result, err = CompareParity([]string{"a.env", "b.json"}, [][]byte{[]byte("DB=1\n"), []byte(`{"DB": 2}`)})
+	if err != nil || !result.OK() {
+		t.Errorf("Expected dotenv and JSON files with the same keys to match, got %+v, %v", result, err)
+	}
+
+	if _, err := CompareParity([]string{".env", "key.pem"}, [][]byte{[]byte("A=1"), []byte("KEY")}); err == nil {
+		t.Error("Expected error for a file without keys")
+	}
+}
+
+func TestCheckParity(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{
+		".env.dev":  "A=1\nDEBUG=true\n",
+		".env.prod": "A=2\n",
+	})
+
+	result, err := lockenv.CheckParity(ctx, password, []string{".env.dev", "./.env.prod"}, false)
+	if err != nil {
+		t.Fatalf("CheckParity failed: %v", err)
+	}
+	if len(result.Missing) != 1 || result.Missing[0].File != ".env.prod" || result.Missing[0].Key != "DEBUG" {
+		t.Errorf("Expected DEBUG missing from .env.prod, got %+v", result.Missing)
+	}
+
+	// The working tree is only read with local set
+	root := filepath.Dir(lockenv.path)
+	for name, content := range map[string]string{".env.dev": "A=1\nDEBUG=true\n", ".env.prod": "A=2\nDEBUG=false\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+	if result, err := lockenv.CheckParity(ctx, nil, []string{".env.dev", ".env.prod"}, true); err != nil || !result.OK() {
+		t.Errorf("Expected the working-tree copies to match, got %+v, %v", result, err)
+	}
+
+	if _, err := lockenv.CheckParity(ctx, password, []string{".env.dev", ".env.missing"}, false); !errors.Is(err, ErrFileNotInVault) {
+		t.Errorf("Expected ErrFileNotInVault, got %v", err)
+	}
+}
@@ -9,13 +9,14 @@ import (
 	"golang.org/x/term"
 )
 
-// ReadPassword reads a password from the terminal without echoing
+// ReadPassword reads a password from the terminal without echoing. The
+// prompt goes to stderr so it never ends up in a command's output.
 func ReadPassword(prompt string) ([]byte, error) {
-	fmt.Print(prompt)
+	fmt.Fprint(os.Stderr, prompt)
 	
 	// Read password without echo
 	password, err := term.ReadPassword(int(syscall.Stdin))
-	fmt.Println() // New line after password
+	fmt.Fprintln(os.Stderr) // New line after password
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to read password: %w", err)
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPattern matches {{lockenv:FILE:KEY}}, capturing the vault file
+// and the variable name. The file is everything up to the last colon.
+var placeholderPattern = regexp.MustCompile(`\{\{lockenv:([^{}]+):([A-Za-z_][A-Za-z0-9_.\-]*)\}\}`)
+
+// minRedactLength is the shortest value Redact replaces; shorter values such
+// as "1" or "on" would match unrelated text
+const minRedactLength = 4
+
+// Placeholder returns the placeholder for variable key of vault file path
+func Placeholder(path, key string) string {
+	return "{{lockenv:" + path + ":" + key + "}}"
+}
+
+// Inject replaces every {{lockenv:FILE:KEY}} placeholder in text with the
+// value of KEY from the dotenv file FILE in the vault (implements
+// `lockenv inject`). Returns the result and the number of placeholders
+// replaced. A placeholder naming a file or variable not in the vault is an
+// error.
+func (l *LockEnv) Inject(ctx context.Context, password []byte, text []byte) ([]byte, int, error) {
+	matches := placeholderPattern.FindAllSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0, nil
+	}
+
+	var paths []string
+	for _, m := range matches {
+		paths = append(paths, strings.TrimPrefix(string(text[m[2]:m[3]]), "./"))
+	}
+	parsed, err := l.readDotenvFiles(ctx, password, paths)
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make(map[string]map[string]string, len(parsed))
+	for path, entries := range parsed {
+		values[path] = make(map[string]string, len(entries))
+		for _, e := range entries {
+			values[path][e.Name] = e.Value
+		}
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for i, m := range matches {
+		key := string(text[m[4]:m[5]])
+		value, ok := values[paths[i]][key]
+		if !ok {
+			return nil, 0, fmt.Errorf("%s: variable %s is not defined", paths[i], key)
+		}
+		out.Write(text[last:m[0]])
+		out.WriteString(value)
+		last = m[1]
+	}
+	out.Write(text[last:])
+	return out.Bytes(), len(matches), nil
+}
+
+// Redact replaces values from the vault's dotenv files found in text with
+// their {{lockenv:FILE:KEY}} placeholders, reversing Inject (implements
+// `lockenv redact`). files limits the dotenv files whose values are used;
+// by default every dotenv file in the vault is. Longer values are matched
+// first, a value defined more than once maps to its first definition, and
+// values shorter than four bytes are left alone. Returns the result and the
+// number of values replaced.
+func (l *LockEnv) Redact(ctx context.Context, password []byte, text []byte, files []string) ([]byte, int, error) {
+	var paths []string
+	for _, path := range files {
+		paths = append(paths, strings.TrimPrefix(path, "./"))
+	}
+	if len(paths) == 0 {
+		all, err := l.dotenvPaths(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		paths = all
+	}
+	if len(paths) == 0 {
+		return text, 0, nil
+	}
+
+	parsed, err := l.readDotenvFiles(ctx, password, paths)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pairs [][2]string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		for _, e := range parsed[path] {
+			if len(e.Value) < minRedactLength || seen[e.Value] {
+				continue
+			}
+			seen[e.Value] = true
+			pairs = append(pairs, [2]string{e.Value, Placeholder(path, e.Name)})
+		}
+	}
+	// The earliest match wins; of values matching at the same position, the
+	// longest does
+	sort.SliceStable(pairs, func(i, j int) bool { return len(pairs[i][0]) > len(pairs[j][0]) })
+
+	count := 0
+	var out bytes.Buffer
+	rest := string(text)
+	for len(rest) > 0 {
+		pos, match := -1, -1
+		for i, p := range pairs {
+			if k := strings.Index(rest, p[0]); k >= 0 && (pos < 0 || k < pos) {
+				pos, match = k, i
+			}
+		}
+		if match < 0 {
+			out.WriteString(rest)
+			break
+		}
+		out.WriteString(rest[:pos])
+		out.WriteString(pairs[match][1])
+		rest = rest[pos+len(pairs[match][0]):]
+		count++
+	}
+	return out.Bytes(), count, nil
+}
+
+// dotenvPaths returns the dotenv files in the vault, sorted, from the
+// manifest
+func (l *LockEnv) dotenvPaths(ctx context.Context) ([]string, error) {
+	candidates, err := l.VaultFileCandidates(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, path := range candidates {
+		if IsDotenvFile(path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// readDotenvFiles decrypts and parses the given dotenv files from the vault,
+// returning their entries by path. Duplicate paths are read once.
+func (l *LockEnv) readDotenvFiles(ctx context.Context, password []byte, paths []string) (map[string][]DotenvEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	result := make(map[string][]DotenvEntry, len(paths))
+	for _, path := range paths {
+		if _, ok := result[path]; ok {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file := metadata.FindFile(path)
+		if file == nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+		}
+		plain, err := l.decryptEntry(ctx, db, file, enc)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := ParseDotenv(plain.Borrow())
+		plain.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		result[path] = entries
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInjectRedact_RoundTrip(t *testing.T) {
+	password := []byte("test-password")
+	lockenv := newMergeTestVault(t, password, map[string]string{
+		".env":            "DB_PASSWORD=hunter22\nDB_USER=app\nPORT=8080\n",
+		"config/prod.env": "API_KEY=sk-live-123\nAPI_KEY_ID=sk-live-123456\n",
+	})
+	ctx := context.Background()
+
+	template := "db:\n  user: {{lockenv:.env:DB_USER}}\n  password: {{lockenv:.env:DB_PASSWORD}}\n" +
+		"api: {{lockenv:config/prod.env:API_KEY}} {{lockenv:config/prod.env:API_KEY_ID}}\n"
+
+	injected, n, err := lockenv.Inject(ctx, password, []byte(template))
+	if err != nil {
+		t.Fatalf("Inject failed: %v", err)
+	}
+	want := "db:\n  user: app\n  password: hunter22\napi: sk-live-123 sk-live-123456\n"
+	if string(injected) != want {
+		t.Errorf("Inject() = %q, want %q", injected, want)
+	}
+	if n != 4 {
+		t.Errorf("Inject() replaced %d, want 4", n)
+	}
+
+	redacted, n, err := lockenv.Redact(ctx, password, injected, nil)
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	// DB_USER's value is too short to redact safely
+	want = strings.Replace(template, "{{lockenv:.env:DB_USER}}", "app", 1)
+	if string(redacted) != want {
+		t.Errorf("Redact() = %q, want %q", redacted, want)
+	}
+	if n != 3 {
+		t.Errorf("Redact() replaced %d, want 3", n)
+	}
+}
+
+func TestRedact_LimitsToFiles(t *testing.T) {
+	password := []byte("test-password")
+	lockenv := newMergeTestVault(t, password, map[string]string{
+		".env":      "SECRET=from-dev\n",
+		".env.prod": "SECRET=from-prod\n",
+	})
+
+	redacted, _, err := lockenv.Redact(context.Background(), password, []byte("a=from-dev b=from-prod"), []string{".env.prod"})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	if want := "a=from-dev b={{lockenv:.env.prod:SECRET}}"; string(redacted) != want {
+		t.Errorf("Redact() = %q, want %q", redacted, want)
+	}
+}
+
+func TestInject_Errors(t *testing.T) {
+	password := []byte("test-password")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1\n"})
+	ctx := context.Background()
+
+	if _, _, err := lockenv.Inject(ctx, password, []byte("{{lockenv:other.env:A}}")); !errors.Is(err, ErrFileNotInVault) {
+		t.Errorf("unknown file: error = %v, want ErrFileNotInVault", err)
+	}
+	if _, _, err := lockenv.Inject(ctx, password, []byte("{{lockenv:.env:B}}")); err == nil || !strings.Contains(err.Error(), "B is not defined") {
+		t.Errorf("unknown key: error = %v", err)
+	}
+
+	text := []byte("no placeholders {{lockenv:}}")
+	out, n, err := lockenv.Inject(ctx, password, text)
+	if err != nil || n != 0 || string(out) != string(text) {
+		t.Errorf("Inject() = %q, %d, %v; want text unchanged", out, n, err)
+	}
+}
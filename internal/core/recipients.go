@@ -0,0 +1,261 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/kms"
+	"github.com/illarion/lockenv/internal/storage"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrNoMatchingKey     = errors.New("no recipient matches this SSH key")
+	ErrNoKMSAccess       = errors.New("no KMS recipient could unwrap the vault key")
+)
+
+// AddSSHRecipient wraps the vault key for an ssh-ed25519 public key, so the
+// matching private key can unlock the vault without the password.
+func (l *LockEnv) AddSSHRecipient(password []byte, authorizedKey []byte) (*storage.Recipient, error) {
+	recipient, err := crypto.ParseSSHRecipient(authorizedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := l.vaultKey(password)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(key)
+
+	ephemeral, wrapped, err := recipient.WrapKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap vault key: %w", err)
+	}
+
+	entry := storage.Recipient{
+		Type:        recipient.PublicKey.Type(),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(recipient.PublicKey))),
+		Comment:     recipient.Comment,
+		Fingerprint: recipient.Fingerprint,
+		Ephemeral:   ephemeral,
+		WrappedKey:  wrapped,
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	if err := db.StoreRecipient(entry); err != nil {
+		return nil, fmt.Errorf("failed to store recipient: %w", err)
+	}
+	if err := db.UpdateModified(); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// AddKMSRecipient wraps the vault key with a cloud KMS key, so anyone allowed
+// to decrypt with that key (e.g. a CI role) can unlock the vault without the password.
+func (l *LockEnv) AddKMSRecipient(ctx context.Context, password []byte, keyID string) (*storage.Recipient, error) {
+	kmsKey, err := kms.Parse(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := l.vaultKey(password)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(key)
+
+	wrapped, err := kmsKey.Wrap(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap vault key: %w", err)
+	}
+
+	entry := storage.Recipient{
+		Type:        kmsKey.Provider,
+		Fingerprint: kmsKey.ID,
+		KeyID:       kmsKey.ID,
+		WrappedKey:  wrapped,
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	if err := db.StoreRecipient(entry); err != nil {
+		return nil, fmt.Errorf("failed to store recipient: %w", err)
+	}
+	if err := db.UpdateModified(); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// ListRecipients returns the vault's recipients (no password required)
+func (l *LockEnv) ListRecipients() ([]storage.Recipient, error) {
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	return db.GetRecipients()
+}
+
+// RemoveRecipient removes the recipient matching the fingerprint or comment.
+// Note that a removed recipient may still hold the vault key; change the
+// password to revoke access fully.
+func (l *LockEnv) RemoveRecipient(password []byte, query string) (*storage.Recipient, error) {
+	if err := l.VerifyPassword(password); err != nil {
+		return nil, err
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	recipients, err := db.GetRecipients()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %w", err)
+	}
+
+	for _, r := range recipients {
+		if r.Fingerprint == query || r.Comment == query {
+			if err := db.RemoveRecipient(r.Fingerprint); err != nil {
+				return nil, err
+			}
+			if err := db.UpdateModified(); err != nil {
+				return nil, err
+			}
+			return &r, nil
+		}
+	}
+
+	return nil, ErrRecipientNotFound
+}
+
+// KeyFromSSHIdentity unwraps the vault key using an ed25519 private key.
+// Returns ErrNoMatchingKey if no recipient was added for its public key.
+// The caller is responsible for calling crypto.ClearBytes on the returned key.
+func (l *LockEnv) KeyFromSSHIdentity(privateKey ed25519.PrivateKey) ([]byte, error) {
+	recipients, err := l.ListRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	sshPub, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH key: %w", err)
+	}
+	wire := sshPub.Marshal()
+
+	for _, r := range recipients {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.PublicKey))
+		if err != nil || !bytes.Equal(pub.Marshal(), wire) {
+			continue
+		}
+		key, err := crypto.UnwrapSSHKey(privateKey, r.Ephemeral, r.WrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap vault key: %w", err)
+		}
+		return key, nil
+	}
+
+	return nil, ErrNoMatchingKey
+}
+
+// KeyFromKMS unwraps the vault key with the first KMS recipient whose CLI is
+// installed and whose key the current credentials may decrypt with.
+// Returns ErrNoMatchingKey if the vault has no usable KMS recipients.
+// The caller is responsible for calling crypto.ClearBytes on the returned key.
+func (l *LockEnv) KeyFromKMS(ctx context.Context) ([]byte, error) {
+	recipients, err := l.ListRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, r := range recipients {
+		if r.KeyID == "" {
+			continue
+		}
+		kmsKey, err := kms.Parse(r.KeyID)
+		if err != nil || !kmsKey.Available() {
+			continue
+		}
+		key, err := kmsKey.Unwrap(ctx, r.WrappedKey)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return key, nil
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoKMSAccess, lastErr)
+	}
+	return nil, ErrNoMatchingKey
+}
+
+// rewrapRecipients re-encrypts the vault key for all recipients (after a password change).
+// A KMS recipient that cannot be reached is passed to SetNotify as a warning.
+func (l *LockEnv) rewrapRecipients(ctx context.Context, db storage.Backend, key []byte) error {
+	recipients, err := db.GetRecipients()
+	if err != nil {
+		return fmt.Errorf("failed to read recipients: %w", err)
+	}
+
+	for _, r := range recipients {
+		if r.KeyID != "" {
+			kmsKey, err := kms.Parse(r.KeyID)
+			if err != nil {
+				return fmt.Errorf("invalid recipient %s: %w", r.Fingerprint, err)
+			}
+			// The vault is already re-encrypted, an unreachable KMS must not fail the change
+			if r.WrappedKey, err = kmsKey.Wrap(ctx, key); err != nil {
+				l.notice(FileEvent{Action: EventWarning, Path: r.Fingerprint,
+					Err: fmt.Errorf("cannot wrap vault key: %w (run 'lockenv recipient add-kms' again)", err)})
+				continue
+			}
+			if err := db.StoreRecipient(r); err != nil {
+				return fmt.Errorf("failed to store recipient %s: %w", r.Fingerprint, err)
+			}
+			continue
+		}
+
+		recipient, err := crypto.ParseSSHRecipient([]byte(r.PublicKey))
+		if err != nil {
+			return fmt.Errorf("invalid recipient %s: %w", r.Fingerprint, err)
+		}
+		r.Ephemeral, r.WrappedKey, err = recipient.WrapKey(key)
+		if err != nil {
+			return fmt.Errorf("failed to wrap vault key for %s: %w", r.Fingerprint, err)
+		}
+		if err := db.StoreRecipient(r); err != nil {
+			return fmt.Errorf("failed to store recipient %s: %w", r.Fingerprint, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"golang.org/x/crypto/ssh"
+)
+
+func newSSHKey(t *testing.T, comment string) (ed25519.PrivateKey, []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	line := ssh.MarshalAuthorizedKey(sshPub)
+	line = append(line[:len(line)-1], []byte(" "+comment+"\n")...)
+	return priv, line
+}
+
+func TestSSHRecipient_UnlocksVault(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	priv, authorizedKey := newSSHKey(t, "alice@laptop")
+	recipient, err := lockenv.AddSSHRecipient(password, authorizedKey)
+	if err != nil {
+		t.Fatalf("AddSSHRecipient failed: %v", err)
+	}
+	if recipient.Comment != "alice@laptop" {
+		t.Errorf("Expected comment alice@laptop, got %q", recipient.Comment)
+	}
+
+	recipients, err := lockenv.ListRecipients()
+	if err != nil {
+		t.Fatalf("ListRecipients failed: %v", err)
+	}
+	if len(recipients) != 1 {
+		t.Fatalf("Expected 1 recipient, got %d", len(recipients))
+	}
+
+	key, err := lockenv.KeyFromSSHIdentity(priv)
+	if err != nil {
+		t.Fatalf("KeyFromSSHIdentity failed: %v", err)
+	}
+	defer crypto.ClearBytes(key)
+
+	other, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer other.Close()
+	other.UseKey(key)
+	if err := other.VerifyPassword(nil); err != nil {
+		t.Errorf("Unwrapped key should unlock the vault: %v", err)
+	}
+
+	// A different key must not match
+	stranger, _ := newSSHKey(t, "mallory@evil")
+	if _, err := lockenv.KeyFromSSHIdentity(stranger); err != ErrNoMatchingKey {
+		t.Errorf("Expected ErrNoMatchingKey, got %v", err)
+	}
+}
+
+func TestSSHRecipient_SurvivesPasswordChange(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("old")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	priv, authorizedKey := newSSHKey(t, "bob@desktop")
+	if _, err := lockenv.AddSSHRecipient(password, authorizedKey); err != nil {
+		t.Fatalf("AddSSHRecipient failed: %v", err)
+	}
+
+	if err := lockenv.ChangePassword(password, []byte("new")); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	key, err := lockenv.KeyFromSSHIdentity(priv)
+	if err != nil {
+		t.Fatalf("KeyFromSSHIdentity failed: %v", err)
+	}
+	defer crypto.ClearBytes(key)
+
+	lockenv.UseKey(key)
+	if err := lockenv.VerifyPassword(nil); err != nil {
+		t.Errorf("Recipient should be re-wrapped with the new key: %v", err)
+	}
+}
+
+func TestRemoveRecipient(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	_, authorizedKey := newSSHKey(t, "carol@ci")
+	if _, err := lockenv.AddSSHRecipient(password, authorizedKey); err != nil {
+		t.Fatalf("AddSSHRecipient failed: %v", err)
+	}
+
+	if _, err := lockenv.RemoveRecipient(password, "nobody"); err != ErrRecipientNotFound {
+		t.Errorf("Expected ErrRecipientNotFound, got %v", err)
+	}
+	if _, err := lockenv.RemoveRecipient(password, "carol@ci"); err != nil {
+		t.Fatalf("RemoveRecipient failed: %v", err)
+	}
+
+	recipients, err := lockenv.ListRecipients()
+	if err != nil {
+		t.Fatalf("ListRecipients failed: %v", err)
+	}
+	if len(recipients) != 0 {
+		t.Errorf("Expected no recipients, got %d", len(recipients))
+	}
+}
+
+func TestAddSSHRecipient_RejectsNonEd25519(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	rsaKey := []byte("ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDGV5g user@host\n")
+	if _, err := lockenv.AddSSHRecipient(password, rsaKey); err == nil {
+		t.Error("Expected error for non-ed25519 key")
+	}
+}
+
+func TestKMSRecipient_UnlocksVaultAndSurvivesPasswordChange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gcloud CLI is a shell script")
+	}
+	// Fake gcloud that "encrypts" by copying stdin to stdout
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "gcloud"), []byte("#!/bin/sh\ncat\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake gcloud: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("old")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	ctx := context.Background()
+	keyName := "projects/p/locations/global/keyRings/ci/cryptoKeys/lockenv"
+	if _, err := lockenv.AddKMSRecipient(ctx, password, "arn:aws:s3:::bucket"); err == nil {
+		t.Error("Expected error for a non-KMS ARN")
+	}
+	recipient, err := lockenv.AddKMSRecipient(ctx, password, keyName)
+	if err != nil {
+		t.Fatalf("AddKMSRecipient failed: %v", err)
+	}
+	if recipient.Type != "gcp-kms" || recipient.KeyID != keyName {
+		t.Errorf("Unexpected recipient: %+v", recipient)
+	}
+
+	if err := lockenv.ChangePassword(password, []byte("new")); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	key, err := lockenv.KeyFromKMS(ctx)
+	if err != nil {
+		t.Fatalf("KeyFromKMS failed: %v", err)
+	}
+	defer crypto.ClearBytes(key)
+
+	lockenv.UseKey(key)
+	if err := lockenv.VerifyPassword(nil); err != nil {
+		t.Errorf("KMS recipient should be re-wrapped with the new key: %v", err)
+	}
+}
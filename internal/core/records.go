@@ -0,0 +1,43 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// Config record keys of the state commands keep in the vault between runs.
+// The records are JSON in the unencrypted config bucket.
+const (
+	recordDirs      = "dirs"       // Directories tracked with lock --recursive
+	recordStash     = "stash"      // Files removed by lockenv stash
+	recordRelock    = "relock"     // Expiry of files unlocked with unlock --for
+	recordJournal   = "journal"    // Lock in progress, see LockJournal
+	recordSync      = "sync"       // Remote and version of the last push/pull
+	recordInfo      = "info"       // Vault description, owner and contact
+	recordSignature = "signature"  // Maintainer signature over the manifest
+	recordNonSecret = "non_secret" // Paths marked as not secret with scan --ignore
+)
+
+// getRecord decodes the config record under key into v, returning false if
+// there is none
+func getRecord(db storage.ConfigStore, key string, v any) (bool, error) {
+	data, err := db.GetConfig(key)
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid %s record: %w", key, err)
+	}
+	return true, nil
+}
+
+// putRecord stores v encoded as JSON as the config record under key
+func putRecord(db storage.ConfigStore, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return db.PutConfig(key, data)
+}
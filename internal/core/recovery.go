@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// recoveryRecord is the private record holding the data key wrapped by the
+// recovery key
+const recoveryRecord = "recovery"
+
+// recoveryKeySize is the number of random bytes in a recovery key. 160 bits
+// print as 32 base32 characters, so the key needs no stretching.
+const recoveryKeySize = 20
+
+// recoveryKeyInfo separates the recovery wrapping key from other uses of the
+// same bytes
+const recoveryKeyInfo = "lockenv-recovery-key"
+
+var (
+	ErrNoRecoveryKey    = errors.New("vault has no recovery key")
+	ErrWrongRecoveryKey = errors.New("wrong recovery key")
+)
+
+// recoveryEncoding writes recovery keys in upper case without padding
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// formatRecoveryKey prints a recovery key in groups of four characters,
+// e.g. ABCD-EFGH-...
+func formatRecoveryKey(raw []byte) string {
+	encoded := recoveryEncoding.EncodeToString(raw)
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:min(i+4, len(encoded))])
+	}
+	return strings.Join(groups, "-")
+}
+
+// parseRecoveryKey reads a recovery key as printed by formatRecoveryKey,
+// ignoring case, dashes and spaces
+func parseRecoveryKey(key string) ([]byte, error) {
+	cleaned := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(key)))
+	raw, err := recoveryEncoding.DecodeString(cleaned)
+	if err != nil || len(raw) != recoveryKeySize {
+		return nil, fmt.Errorf("invalid recovery key: expected %d characters in groups like ABCD-EFGH", recoveryEncoding.EncodedLen(recoveryKeySize))
+	}
+	return raw, nil
+}
+
+// recoveryEncryptor returns the encryptor the data key is wrapped with for
+// the recovery key raw
+func recoveryEncryptor(raw []byte) *crypto.Encryptor {
+	key := sha256.Sum256(append([]byte(recoveryKeyInfo), raw...))
+	return crypto.NewEncryptor(key[:])
+}
+
+// storeRecoveryKey wraps the data key with a new random recovery key,
+// replacing any previous one, and returns the key to show the user
+func storeRecoveryKey(db storage.PrivateStore, dataKey []byte) (string, error) {
+	raw, err := crypto.GenerateRandom(recoveryKeySize)
+	if err != nil {
+		return "", err
+	}
+	defer crypto.ClearBytes(raw)
+
+	enc := recoveryEncryptor(raw)
+	defer enc.Destroy()
+	wrapped, err := enc.Encrypt(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	if err := db.StoreMetadataBytes(recoveryRecord, wrapped); err != nil {
+		return "", fmt.Errorf("failed to store recovery key: %w", err)
+	}
+	return formatRecoveryKey(raw), nil
+}
+
+// openForRecovery opens the vault as db and checks that its format keeps
+// a data key the recovery key can wrap. The caller closes the returned
+// backend.
+func (l *LockEnv) openForRecovery() (storage.Backend, error) {
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	format, err := db.GetFormatVersion()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read format version: %w", err)
+	}
+	if format < storage.FormatV2 {
+		db.Close()
+		return nil, fmt.Errorf("recovery keys need format v%d (run 'lockenv migrate' first)", storage.FormatV2)
+	}
+	return db, nil
+}
+
+// CreateRecoveryKey wraps the vault's data key with a new random recovery
+// key and returns it for the user to print and store offline (implements
+// `lockenv init --recovery-key` and `lockenv recover --new-key`). It is not
+// kept anywhere else; a previous recovery key stops working.
+func (l *LockEnv) CreateRecoveryKey(password []byte) (string, error) {
+	db, err := l.openForRecovery()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	key, err := l.passwordKey(context.Background(), db, password)
+	if err != nil {
+		return "", err
+	}
+	vaultEnc := crypto.NewEncryptor(key)
+	defer vaultEnc.Destroy()
+
+	dataKey, err := unwrapDataKey(db, vaultEnc)
+	if err != nil {
+		return "", err
+	}
+	defer crypto.ClearBytes(dataKey)
+
+	recoveryKey, err := storeRecoveryKey(db, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return recoveryKey, db.UpdateModified()
+}
+
+// Recover sets a new password on a vault whose password is lost, using the
+// recovery key created with CreateRecoveryKey (implements `lockenv
+// recover`). Recipients are rewrapped for the new password as by
+// ChangePassword. The used recovery key is replaced by a new one, which is
+// returned. The vault is backed up first.
+func (l *LockEnv) Recover(recoveryKey string, newPassword []byte) (string, error) {
+	raw, err := parseRecoveryKey(recoveryKey)
+	if err != nil {
+		return "", err
+	}
+	defer crypto.ClearBytes(raw)
+
+	db, err := l.openForRecovery()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	wrapped, err := db.GetMetadataBytes(recoveryRecord)
+	if err != nil {
+		return "", ErrNoRecoveryKey
+	}
+	recoveryEnc := recoveryEncryptor(raw)
+	defer recoveryEnc.Destroy()
+	dataKey, err := recoveryEnc.Decrypt(wrapped)
+	if err != nil {
+		return "", ErrWrongRecoveryKey
+	}
+	defer crypto.ClearBytes(dataKey)
+
+	// Keep a copy of the vault in case the rewrap is interrupted
+	backup, err := l.backup(db, DefaultBackupDir, DefaultBackupKeep)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up vault: %w", err)
+	}
+	l.notice(FileEvent{Action: EventBackup, Path: backup.Path})
+
+	newKDF, err := l.newKDF()
+	if err != nil {
+		return "", fmt.Errorf("failed to create new KDF: %w", err)
+	}
+	newKey := crypto.AdoptSecure(newKDF.DeriveKey(newPassword))
+	defer newKey.Close()
+	newEnc := crypto.NewEncryptor(newKey.Borrow())
+	defer newEnc.Destroy()
+
+	if err := db.SetSalt(newKDF.Salt); err != nil {
+		return "", fmt.Errorf("failed to update salt: %w", err)
+	}
+	if err := db.SetIterations(uint32(newKDF.Iterations)); err != nil {
+		return "", fmt.Errorf("failed to update iterations: %w", err)
+	}
+	if err := wrapDataKey(db, newEnc, dataKey); err != nil {
+		return "", err
+	}
+	if err := l.rewrapRecipients(context.Background(), db, newKey.Borrow()); err != nil {
+		return "", err
+	}
+
+	next, err := storeRecoveryKey(db, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return next, db.UpdateModified()
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestRecoveryKeyFormat(t *testing.T) {
+	raw := make([]byte, recoveryKeySize)
+	for i := range raw {
+		raw[i] = byte(i * 13)
+	}
+	key := formatRecoveryKey(raw)
+	if !regexp.MustCompile(`^([A-Z2-7]{4}-){7}[A-Z2-7]{4}$`).MatchString(key) {
+		t.Fatalf("formatRecoveryKey() = %q, want 8 groups of 4", key)
+	}
+
+	for _, input := range []string{key, " " + key + "\n", regexp.MustCompile(`-`).ReplaceAllString(key, " ")} {
+		parsed, err := parseRecoveryKey(input)
+		if err != nil {
+			t.Fatalf("parseRecoveryKey(%q) failed: %v", input, err)
+		}
+		if string(parsed) != string(raw) {
+			t.Errorf("parseRecoveryKey(%q) did not round-trip", input)
+		}
+	}
+	for _, input := range []string{"", "ABCD-EFGH", key + "-ABCD", key[:len(key)-1] + "1"} {
+		if _, err := parseRecoveryKey(input); err == nil {
+			t.Errorf("parseRecoveryKey(%q): expected an error", input)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	oldPassword := []byte("forgotten")
+	newPassword := []byte("newpass")
+	if err := lockenv.Init(oldPassword); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	// A vault without a recovery key cannot be recovered
+	if _, err := lockenv.Recover("AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA", newPassword); err != ErrNoRecoveryKey {
+		t.Errorf("Expected ErrNoRecoveryKey, got %v", err)
+	}
+	if _, err := lockenv.CreateRecoveryKey([]byte("wrong")); err != ErrWrongPassword {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+
+	recoveryKey, err := lockenv.CreateRecoveryKey(oldPassword)
+	if err != nil {
+		t.Fatalf("CreateRecoveryKey failed: %v", err)
+	}
+
+	testFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(testFile, []byte("KEY=secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{testFile}, oldPassword, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, oldPassword, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	if _, err := lockenv.Recover("AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA", newPassword); err != ErrWrongRecoveryKey {
+		t.Errorf("Expected ErrWrongRecoveryKey, got %v", err)
+	}
+	next, err := lockenv.Recover(recoveryKey, newPassword)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if next == recoveryKey {
+		t.Error("Expected a new recovery key after recovering")
+	}
+
+	if err := lockenv.VerifyPassword(oldPassword); err != ErrWrongPassword {
+		t.Errorf("Expected the old password to stop working, got %v", err)
+	}
+	result, err := lockenv.Unlock(ctx, newPassword, StrategyUseVault, nil)
+	if err != nil {
+		t.Fatalf("Unlock with the new password failed: %v", err)
+	}
+	if len(result.Extracted) != 1 {
+		t.Errorf("Expected 1 extracted file, got %d", len(result.Extracted))
+	}
+	content, err := os.ReadFile(testFile)
+	if err != nil || string(content) != "KEY=secret" {
+		t.Errorf("Unexpected content %q, %v", content, err)
+	}
+
+	// The used recovery key is replaced
+	if _, err := lockenv.Recover(recoveryKey, oldPassword); err != ErrWrongRecoveryKey {
+		t.Errorf("Expected the used recovery key to stop working, got %v", err)
+	}
+	if _, err := lockenv.Recover(next, oldPassword); err != nil {
+		t.Errorf("Recover with the new recovery key failed: %v", err)
+	}
+}
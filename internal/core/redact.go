@@ -0,0 +1,59 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// assignmentPattern matches KEY=VALUE, export KEY=VALUE, key: value,
+	// key = value and "key": "value", capturing the key part, the value and
+	// a trailing comma
+	assignmentPattern = regexp.MustCompile(`^(\s*(?:export\s+)?["']?[\w.\-]+["']?\s*[:=]\s*)(.*?)(,?\s*)$`)
+
+	// listItemPattern matches YAML sequence items
+	listItemPattern = regexp.MustCompile(`^(\s*-\s+)(.+)$`)
+)
+
+// RedactLine masks the secret part of a single line of a config file.
+// Keys, comments, section headers and structural punctuation are kept;
+// values are replaced with ****. Lines that don't look like key/value
+// pairs (e.g. PEM bodies) are masked entirely.
+func RedactLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || isCommentLine(trimmed) || isStructuralLine(trimmed) {
+		return line
+	}
+
+	if m := assignmentPattern.FindStringSubmatch(line); m != nil {
+		value := strings.TrimSpace(m[2])
+		// Value opens a nested block on this line
+		if value == "" || value == "{" || value == "[" {
+			return line
+		}
+		return m[1] + maskedValue + m[3]
+	}
+
+	if m := listItemPattern.FindStringSubmatch(line); m != nil {
+		return m[1] + maskedValue
+	}
+
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	return indent + maskedValue
+}
+
+// isCommentLine reports whether a trimmed line is a comment
+func isCommentLine(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "#") ||
+		strings.HasPrefix(trimmed, "//") ||
+		strings.HasPrefix(trimmed, ";")
+}
+
+// isStructuralLine reports whether a trimmed line carries no value:
+// braces, brackets, YAML document markers or INI/TOML section headers
+func isStructuralLine(trimmed string) bool {
+	if strings.Trim(trimmed, "{}[](),-") == "" {
+		return true
+	}
+	return strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") && !strings.ContainsAny(trimmed, "=:\"'")
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedactLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"API_KEY=secret123", "API_KEY=****"},
+		{"export TOKEN=abc", "export TOKEN=****"},
+		{"  password: hunter2", "  password: ****"},
+		{`  "token": "abc",`, `  "token": ****,`},
+		{"port = 5432", "port = ****"},
+		{"  - item", "  - ****"},
+		{"# API_KEY=example", "# API_KEY=example"},
+		{"[database]", "[database]"},
+		{"database:", "database:"},
+		{`"db": {`, `"db": {`},
+		{"},", "},"},
+		{"", ""},
+		{"MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8A", "****"},
+	}
+
+	for _, tt := range tests {
+		if got := RedactLine(tt.line); got != tt.want {
+			t.Errorf("RedactLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateUnifiedDiff_MasksSecrets(t *testing.T) {
+	vaultData := []byte("A=1\nB=old-secret\nC=3\n")
+	localData := []byte("A=1\nB=new-secret\nC=3\n")
+
+	diff, err := GenerateUnifiedDiff(".env", vaultData, localData, DiffOptions{})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+
+	if strings.Contains(diff, "secret") {
+		t.Errorf("Diff should mask values, got:\n%s", diff)
+	}
+	for _, want := range []string{"@@ -1,3 +1,3 @@", " A=****", "-B=****", "+B=****"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Diff missing %q, got:\n%s", want, diff)
+		}
+	}
+
+	diff, err = GenerateUnifiedDiff(".env", vaultData, localData, DiffOptions{ShowSecrets: true})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	if !strings.Contains(diff, "-B=old-secret") || !strings.Contains(diff, "+B=new-secret") {
+		t.Errorf("Diff should show values with ShowSecrets, got:\n%s", diff)
+	}
+}
+
+func TestGenerateUnifiedDiff_SeparateHunks(t *testing.T) {
+	var vault, local strings.Builder
+	for i := 1; i <= 20; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		vault.WriteString(line)
+		if i == 2 || i == 18 {
+			line = "changed\n"
+		}
+		local.WriteString(line)
+	}
+
+	diff, err := GenerateUnifiedDiff("notes.txt", []byte(vault.String()), []byte(local.String()), DiffOptions{ShowSecrets: true})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+
+	if got := strings.Count(diff, "@@ -"); got != 2 {
+		t.Errorf("Expected 2 hunks, got %d:\n%s", got, diff)
+	}
+	if !strings.Contains(diff, "@@ -1,5 +1,5 @@") || !strings.Contains(diff, "@@ -15,6 +15,6 @@") {
+		t.Errorf("Unexpected hunk headers:\n%s", diff)
+	}
+}
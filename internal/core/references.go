@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+var (
+	ErrReference      = errors.New("is a reference to another vault (change it there)")
+	ErrVaultNotOpened = errors.New("referenced vault is not opened")
+)
+
+// AddReference adds path to the vault as a reference to file target of the
+// vault in the project directory vaultDir (implements `lockenv link`), so a
+// secret shared by several repositories is stored in one vault only. The
+// content stays in the other vault and is read from it on unlock, with that
+// vault's password. An existing reference at path is replaced; a file stored
+// in this vault must be removed first.
+func (l *LockEnv) AddReference(ctx context.Context, password []byte, path, vaultDir, target string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !l.exists() {
+		return ErrNotInitialized
+	}
+	validPath, err := l.validator.ValidateAndNormalize(path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	other, err := NewAt(vaultDir)
+	if err != nil {
+		return err
+	}
+	defer other.Close()
+	other.backend = l.backend
+	if !other.exists() {
+		return fmt.Errorf("%s: %w", vaultDir, ErrNotInitialized)
+	}
+	if same, _ := sameFile(l.path, other.path); same {
+		return fmt.Errorf("cannot reference a file of the same vault")
+	}
+
+	// The other vault's index is enough to record the reference; unlock
+	// checks the content against its encrypted metadata
+	vaultID, err := other.GetOrCreateVaultID()
+	if err != nil {
+		return fmt.Errorf("%s: %w", vaultDir, err)
+	}
+	otherDB, err := other.open()
+	if err != nil {
+		return openError(err)
+	}
+	entries, err := otherDB.GetManifest()
+	otherDB.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read the index of %s: %w", vaultDir, err)
+	}
+	target = filepath.ToSlash(filepath.Clean(target))
+	var found *storage.ManifestEntry
+	for i := range entries {
+		if entries[i].Path == target {
+			found = &entries[i]
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("%s is not in the vault in %s", target, vaultDir)
+	}
+
+	location, err := filepath.Abs(vaultDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(filepath.Dir(l.path), location); err == nil {
+		location = rel
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return err
+	}
+	defer enc.Destroy()
+
+	if existing := metadata.FindFile(validPath); existing != nil && existing.Ref == nil {
+		return fmt.Errorf("%s is stored in this vault, remove it first", validPath)
+	}
+	// An empty blob makes the reference count as sealed, e.g. for status
+	placeholder, err := enc.For(crypto.PurposeBlobs).Encrypt(nil)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", validPath, err)
+	}
+	if err := db.StoreFileData(ctx, validPath, placeholder); err != nil {
+		return fmt.Errorf("failed to store %s: %w", validPath, err)
+	}
+	metadata.RemoveFile(validPath)
+	metadata.AddFile(storage.FileEntry{
+		Path:    validPath,
+		Size:    found.Size,
+		Mode:    FilePermSecure,
+		ModTime: found.ModTime,
+		Hash:    found.Hash,
+		Ref:     &storage.Reference{VaultID: vaultID, Path: target, Location: filepath.ToSlash(location)},
+	})
+	if err := l.updateManifestEntry(db, validPath, found.Size, found.ModTime, found.Hash); err != nil {
+		return fmt.Errorf("failed to update manifest: %w", err)
+	}
+	return l.saveMetadata(db, metadata, enc)
+}
+
+// UseVault opens the referenced vault with ID vaultID for this LockEnv, so
+// later operations read referenced files from it. password may be empty if
+// a key was installed on vault with UseKey. The LockEnv takes ownership of
+// vault and closes it on Close.
+func (l *LockEnv) UseVault(vaultID string, vault *LockEnv, password []byte) error {
+	id, err := vault.GetVaultID()
+	if err != nil {
+		return err
+	}
+	if id != vaultID {
+		return fmt.Errorf("the vault in %s has ID %s, not %s", filepath.Dir(vault.path), id, vaultID)
+	}
+
+	// Derive the key once instead of for every referenced file
+	key, err := vault.vaultKey(password)
+	if err != nil {
+		return err
+	}
+	vault.UseKey(key)
+	crypto.ClearBytes(key)
+
+	if l.vaults == nil {
+		l.vaults = make(map[string]*LockEnv)
+	}
+	if old := l.vaults[vaultID]; old != nil && old != vault {
+		old.Close()
+	}
+	l.vaults[vaultID] = vault
+	return nil
+}
+
+// SetVaultOpener makes later operations call open for the first reference
+// to a vault that was not opened with UseVault. open is expected to find the
+// vault, get its password and call UseVault; if it fails, the references to
+// that vault are skipped.
+func (l *LockEnv) SetVaultOpener(open func(ref storage.Reference) error) {
+	l.opener = open
+}
+
+// resolveReference reads the content of the reference file from the vault
+// opened with UseVault, checked against that vault's metadata
+func (l *LockEnv) resolveReference(ctx context.Context, file *storage.FileEntry) (*crypto.SecureBuffer, error) {
+	ref := file.Ref
+	vault := l.vaults[ref.VaultID]
+	if vault == nil && l.opener != nil {
+		if err := l.opener(*ref); err != nil {
+			return nil, fmt.Errorf("%s: %w: %s: %v", file.Path, ErrVaultNotOpened, ref.VaultID, err)
+		}
+		vault = l.vaults[ref.VaultID]
+	}
+	if vault == nil {
+		return nil, fmt.Errorf("%s: %w: %s", file.Path, ErrVaultNotOpened, ref.VaultID)
+	}
+
+	db, err := vault.open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Path, openError(err))
+	}
+	defer db.Close()
+
+	metadata, enc, err := vault.readMetadata(ctx, db, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Path, err)
+	}
+	defer enc.Destroy()
+
+	target := metadata.FindFile(ref.Path)
+	if target == nil {
+		return nil, fmt.Errorf("%s: %s is no longer in vault %s", file.Path, ref.Path, ref.VaultID)
+	}
+	if target.Ref != nil {
+		return nil, fmt.Errorf("%s: %s in vault %s is itself a reference", file.Path, ref.Path, ref.VaultID)
+	}
+	return vault.decryptEntry(ctx, db, target, enc)
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestReferences_Unlock(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	orgPassword := []byte("org-secret")
+	org := newMergeTestVault(t, orgPassword, map[string]string{"shared/db.env": "DB=org"})
+	lockenv := newMergeTestVault(t, password, map[string]string{"app.env": "APP=1"})
+	orgDir, dir := filepath.Dir(org.path), filepath.Dir(lockenv.path)
+
+	if err := lockenv.AddReference(ctx, password, "db.env", orgDir, "shared/db.env"); err != nil {
+		t.Fatalf("AddReference failed: %v", err)
+	}
+	if err := lockenv.AddReference(ctx, password, "app.env", orgDir, "shared/db.env"); err == nil {
+		t.Error("Expected a stored file not to be replaced by a reference")
+	}
+	if err := lockenv.AddReference(ctx, password, "x.env", orgDir, "missing.env"); err == nil {
+		t.Error("Expected a file not in the other vault to be refused")
+	}
+
+	orgID, _ := org.GetVaultID()
+
+	// The placeholder blob keeps the vault consistent
+	if checked, err := lockenv.Fsck(ctx, password, false); err != nil || len(checked.Issues) != 0 {
+		t.Errorf("Fsck = %+v, %v", checked, err)
+	}
+	if reindexed, err := lockenv.Reindex(ctx, password); err != nil || len(reindexed.Indexed) != 2 {
+		t.Errorf("Reindex = %+v, %v", reindexed, err)
+	}
+
+	// Without the other vault the reference is skipped
+	result, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil)
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if len(result.Extracted) != 1 || len(result.Skipped) != 1 || result.Skipped[0] != "db.env" {
+		t.Errorf("Expected db.env to be skipped, got %+v", result)
+	}
+
+	other, err := New(orgDir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	if err := lockenv.UseVault(orgID, other, password); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+
+	// The opener is asked for the vault on first use
+	var opened []storage.Reference
+	lockenv.SetVaultOpener(func(ref storage.Reference) error {
+		opened = append(opened, ref)
+		return lockenv.UseVault(ref.VaultID, other, orgPassword)
+	})
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, []string{"db.env"}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "db.env")); err != nil || string(data) != "DB=org" {
+		t.Errorf("db.env = %q, %v", data, err)
+	}
+	if len(opened) != 1 || opened[0].VaultID != orgID || opened[0].Path != "shared/db.env" {
+		t.Errorf("Unexpected vaults opened: %+v", opened)
+	}
+
+	// A rotated secret reaches the project on the next unlock
+	if err := os.WriteFile(filepath.Join(orgDir, "shared", "db.env"), []byte("DB=rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := org.LockFiles(ctx, []string{"shared/db.env"}, orgPassword, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := org.FinalizeLock(ctx, orgPassword, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, []string{"db.env"}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "db.env")); err != nil || string(data) != "DB=rotated" {
+		t.Errorf("db.env = %q, %v", data, err)
+	}
+	verified, err := lockenv.VerifyPaths(ctx, nil)
+	if err != nil {
+		t.Fatalf("VerifyPaths failed: %v", err)
+	}
+	if len(verified.Drifted) != 0 {
+		t.Errorf("Expected the recorded hash to follow the other vault, got %+v", verified)
+	}
+
+	// References are changed in their own vault
+	report, err := lockenv.LockFiles(ctx, []string{"db.env"}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if len(report.Skipped) != 1 || !errors.Is(report.Skipped[0], ErrReference) {
+		t.Errorf("Expected the reference to be skipped, got %+v", report)
+	}
+}
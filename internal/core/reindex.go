@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// ReindexResult lists the files Reindex wrote to the index
+type ReindexResult struct {
+	Indexed    []string // Entries computed from the stored contents
+	Corrected  []string // Contents differed from the metadata, which was updated to match
+	Unreadable []string // Contents could not be decrypted, entries taken from the metadata
+}
+
+// Reindex regenerates the plaintext index from the encrypted metadata and
+// the decrypted file contents (implements `lockenv reindex`), for when the
+// index was deleted or mangled, e.g. by a bad merge. The size and hash of
+// each entry are computed from the stored contents; its path and modification
+// time come from the metadata.
+func (l *LockEnv) Reindex(ctx context.Context, password []byte) (*ReindexResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	result := &ReindexResult{}
+	entries := make([]storage.ManifestEntry, 0, len(metadata.Files))
+	for i := range metadata.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file := &metadata.Files[i]
+
+		size, hash, err := l.storedContentHash(ctx, db, file, enc)
+		switch {
+		case file.Ref != nil:
+			// The content of a reference is checked against its own vault
+			result.Indexed = append(result.Indexed, file.Path)
+		case err != nil:
+			result.Unreadable = append(result.Unreadable, file.Path)
+		case size != file.Size || hash != file.Hash:
+			file.Size, file.Hash = size, hash
+			result.Corrected = append(result.Corrected, file.Path)
+		default:
+			result.Indexed = append(result.Indexed, file.Path)
+		}
+		entries = append(entries, storage.ManifestEntry{Path: file.Path, Size: file.Size, ModTime: file.ModTime, Hash: file.Hash})
+	}
+
+	if err := db.ReplaceManifest(entries); err != nil {
+		return nil, fmt.Errorf("failed to rebuild index: %w", err)
+	}
+	// Saving the metadata also stores a fresh index MAC
+	if err := l.saveMetadata(db, metadata, enc); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// storedContentHash decrypts the stored contents of file and returns their
+// size and hex SHA-256 hash
+func (l *LockEnv) storedContentHash(ctx context.Context, db storage.Backend, file *storage.FileEntry, enc *crypto.Encryptor) (int64, string, error) {
+	fileEnc, err := l.fileEncryptor(file, enc)
+	if err != nil {
+		return 0, "", err
+	}
+	encryptedData, err := db.GetFileData(ctx, file.Path)
+	if err != nil {
+		return 0, "", err
+	}
+	data, err := fileEnc.DecryptSecureContext(ctx, encryptedData)
+	if err != nil {
+		return 0, "", err
+	}
+	defer data.Close()
+
+	hash := sha256.Sum256(data.Borrow())
+	return int64(data.Len()), hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestReindex(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "b.env": "B=2"})
+
+	// Erase the index, then leave a mangled entry behind
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	steps := []error{
+		db.ReplaceManifest(nil),
+		db.UpdateManifest("a.env", 999, time.Now(), "mangled"),
+	}
+	db.Close()
+	for _, err := range steps {
+		if err != nil {
+			t.Fatalf("Failed to damage index: %v", err)
+		}
+	}
+
+	result, err := lockenv.Reindex(ctx, password)
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if len(result.Indexed) != 2 || len(result.Corrected) != 0 || len(result.Unreadable) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	verified, err := lockenv.VerifyVault(ctx, password)
+	if err != nil {
+		t.Fatalf("VerifyVault failed: %v", err)
+	}
+	if len(verified.Unchanged) != 2 || !verified.OK(true) {
+		t.Errorf("Expected both files to verify, got %+v", verified)
+	}
+	checked, err := lockenv.Fsck(ctx, password, false)
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if len(checked.Issues) != 0 {
+		t.Errorf("Expected no issues after reindex, got %+v", checked.Issues)
+	}
+}
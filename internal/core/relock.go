@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// RelockTimer records files unlocked with unlock --for and when they expire
+type RelockTimer struct {
+	Expires time.Time `json:"expires"`
+	Files   []string  `json:"files"`
+}
+
+// getRelockTimer returns the vault's relock timer, or nil if there is none
+func getRelockTimer(db storage.ConfigStore) (*RelockTimer, error) {
+	timer := &RelockTimer{}
+	if found, err := getRecord(db, recordRelock, timer); !found {
+		return nil, err
+	}
+	return timer, nil
+}
+
+// SetRelockTimer records that files must be locked again after d (implements `lockenv unlock --for`).
+// Files already on a pending timer are kept, and the later expiry wins.
+func (l *LockEnv) SetRelockTimer(files []string, d time.Duration) (*RelockTimer, error) {
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	timer := &RelockTimer{Expires: time.Now().Add(d).UTC().Truncate(time.Second)}
+
+	existing, err := getRelockTimer(db)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	if existing != nil {
+		if existing.Expires.After(timer.Expires) {
+			timer.Expires = existing.Expires
+		}
+		for _, f := range existing.Files {
+			seen[f] = true
+			timer.Files = append(timer.Files, f)
+		}
+	}
+	for _, f := range files {
+		if !seen[f] {
+			seen[f] = true
+			timer.Files = append(timer.Files, f)
+		}
+	}
+
+	if err := putRecord(db, recordRelock, timer); err != nil {
+		return nil, fmt.Errorf("failed to record relock timer: %w", err)
+	}
+	return timer, nil
+}
+
+// GetRelockTimer returns the pending relock timer, or nil if there is none (no password required)
+func (l *LockEnv) GetRelockTimer() (*RelockTimer, error) {
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	return getRelockTimer(db)
+}
+
+// Relock locks the files on the relock timer, removes the plaintext copies and
+// clears the timer (used by `lockenv guard`). Local changes are encrypted into
+// the vault before removal so nothing is lost. The report's Locked lists the
+// relocked paths.
+func (l *LockEnv) Relock(ctx context.Context, password []byte) (*LockReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	timer, err := l.GetRelockTimer()
+	if err != nil {
+		return nil, err
+	}
+	report := &LockReport{}
+	if timer == nil {
+		return report, nil
+	}
+
+	repoRoot := filepath.Dir(l.path)
+	var present []string
+	for _, path := range timer.Files {
+		validPath, err := l.validator.ValidateExistingPath(path)
+		if err != nil {
+			continue
+		}
+		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
+		if info, err := os.Lstat(platformPath); err == nil && info.Mode().IsRegular() {
+			present = append(present, validPath)
+		}
+	}
+
+	if len(present) > 0 {
+		if report, err = l.FinalizeLock(ctx, password, true, present); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return report, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.DeleteConfig(recordRelock); err != nil {
+		return report, fmt.Errorf("failed to clear relock timer: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetRelockTimer_MergesFilesAndKeepsLaterExpiry(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	if err := lockenv.Init([]byte("test123")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	first, err := lockenv.SetRelockTimer([]string{"a.env"}, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("SetRelockTimer failed: %v", err)
+	}
+	timer, err := lockenv.SetRelockTimer([]string{"a.env", "b.env"}, time.Hour)
+	if err != nil {
+		t.Fatalf("SetRelockTimer failed: %v", err)
+	}
+
+	if !timer.Expires.Equal(first.Expires) {
+		t.Errorf("Expected later expiry %v to be kept, got %v", first.Expires, timer.Expires)
+	}
+	if len(timer.Files) != 2 || timer.Files[0] != "a.env" || timer.Files[1] != "b.env" {
+		t.Errorf("Expected [a.env b.env], got %v", timer.Files)
+	}
+}
+
+func TestRelock_LocksAndRemovesFiles(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	testFile := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(testFile, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if _, err := lockenv.SetRelockTimer([]string{"secret.env"}, time.Hour); err != nil {
+		t.Fatalf("SetRelockTimer failed: %v", err)
+	}
+
+	// Changes made while unlocked must survive the relock
+	if err := os.WriteFile(testFile, []byte("SECRET=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	relocked, err := lockenv.Relock(context.Background(), password)
+	if err != nil {
+		t.Fatalf("Relock failed: %v", err)
+	}
+	if len(relocked.Locked) != 1 || relocked.Locked[0] != "secret.env" {
+		t.Errorf("Expected secret.env to be relocked, got %v", relocked.Locked)
+	}
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Error("secret.env should have been removed")
+	}
+
+	timer, err := lockenv.GetRelockTimer()
+	if err != nil {
+		t.Fatalf("GetRelockTimer failed: %v", err)
+	}
+	if timer != nil {
+		t.Errorf("Expected relock timer to be cleared, got %+v", timer)
+	}
+
+	if _, err := lockenv.Unlock(context.Background(), password, StrategyUseVault, nil); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to read unlocked file: %v", err)
+	}
+	if string(content) != "SECRET=2" {
+		t.Errorf("Expected SECRET=2, got %q", content)
+	}
+}
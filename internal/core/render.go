@@ -0,0 +1,82 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// RenderTemplate executes text as a Go text/template with the variables from
+// entries as data (implements `lockenv render`), so {{.DB_PASSWORD}} inserts
+// a value. Names that are not template identifiers are read with
+// {{env "my.key"}}, and {{json .X}} quotes a value as a JSON string.
+// Referencing an undefined variable is an error instead of rendering
+// "<no value>".
+func RenderTemplate(name string, text []byte, entries []DotenvEntry) ([]byte, error) {
+	data := make(map[string]string, len(entries))
+	for _, e := range entries {
+		data[e.Name] = e.Value
+	}
+
+	funcs := template.FuncMap{
+		"env": func(key string) (string, error) {
+			value, ok := data[key]
+			if !ok {
+				return "", fmt.Errorf("variable %s is not defined", key)
+			}
+			return value, nil
+		},
+		"json": func(value string) (string, error) {
+			quoted, err := json.Marshal(value)
+			return string(quoted), err
+		},
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// WriteSecretFile atomically replaces path with data, readable by the owner
+// only
+func WriteSecretFile(path string, data []byte) error {
+	return WriteFileAtomic(path, data, FilePermSecure)
+}
+
+// WriteFileAtomic replaces path with data and the given permissions. The data
+// is written to a temporary file in the same directory and renamed over path,
+// so readers never see a partial file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
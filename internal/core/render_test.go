@@ -0,0 +1,81 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	entries := []DotenvEntry{
+		{Name: "DB_PASSWORD", Value: `p"a$s`},
+		{Name: "app.name", Value: "demo"},
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{name: "field", text: "pw={{.DB_PASSWORD}}", want: `pw=p"a$s`},
+		{name: "env", text: `name={{env "app.name"}}`, want: "name=demo"},
+		{name: "json", text: "pw: {{json .DB_PASSWORD}}", want: `pw: "p\"a$s"`},
+		{name: "missing field", text: "{{.MISSING}}", wantErr: "MISSING"},
+		{name: "missing env", text: `{{env "missing"}}`, wantErr: "variable missing is not defined"},
+		{name: "parse error", text: "{{.DB_PASSWORD", wantErr: "unclosed action"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderTemplate("test.tmpl", []byte(tt.text), entries)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RenderTemplate() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderTemplate() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("RenderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteSecretFile(path, []byte("new")); err != nil {
+		t.Fatalf("WriteSecretFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("content = %q, want %q", data, "new")
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != FilePermSecure {
+			t.Errorf("mode = %o, want %o", perm, FilePermSecure)
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temp file left behind: %v", entries)
+	}
+}
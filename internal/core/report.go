@@ -0,0 +1,178 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIsDirectory is reported for directories passed to lock or track
+// without --recursive
+var ErrIsDirectory = errors.New("is a directory (use --recursive to track it)")
+
+// FileError records why a file was skipped or could not be processed
+type FileError struct {
+	Path string // Path as given by the caller or stored in the vault
+	Err  error
+}
+
+func (e FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+// LockReport describes the outcome of LockFiles, TrackFiles, FinalizeLock,
+// Stash and Relock. Skipped and failed files do not make the operation fail;
+// the caller decides how to present them.
+type LockReport struct {
+	Locked    []string    // Vault paths added (LockFiles, TrackFiles) or encrypted (FinalizeLock)
+	Removed   []string    // Working-tree files removed after encrypting
+	Unchanged []string    // Files FinalizeLock left alone because the vault already holds them
+	Skipped   []FileError // Files deliberately left out, e.g. directories or files in a locked domain
+	Failed    []FileError // Files that could not be read, validated or removed
+	Warnings  []FileError // Problems in files that were processed anyway, e.g. dotenv syntax issues
+}
+
+// skip records a file left out of the operation
+func (r *LockReport) skip(path string, err error) {
+	r.Skipped = append(r.Skipped, FileError{Path: path, Err: err})
+}
+
+// warn records a problem in a file that was processed anyway
+func (r *LockReport) warn(path string, err error) {
+	r.Warnings = append(r.Warnings, FileError{Path: path, Err: err})
+}
+
+// fail records a file that could not be processed
+func (r *LockReport) fail(path string, err error) {
+	r.Failed = append(r.Failed, FileError{Path: path, Err: err})
+}
+
+// Err returns the failed files joined into a single error, or nil if every
+// file was processed or deliberately skipped
+func (r *LockReport) Err() error {
+	if r == nil || len(r.Failed) == 0 {
+		return nil
+	}
+	errs := make([]error, len(r.Failed))
+	for i, f := range r.Failed {
+		errs[i] = f
+	}
+	return errors.Join(errs...)
+}
+
+// FileEvent is a per-file message of an operation: what was done to a file,
+// or why it was left alone. Operations that may ask the user about a file
+// record these instead of printing, in the order they happened, so the
+// caller can present them.
+type FileEvent struct {
+	Action string // What happened, e.g. "unlocked", "skipped" or "warning"
+	Path   string // Empty if Err names the file itself
+	Note   string // Short reason, shown in parentheses
+	Err    error  // Problem with the file, for warnings and errors
+}
+
+// Event actions used by several operations
+const (
+	EventUnlocked = "unlocked"
+	EventSaved    = "saved"
+	EventSkipped  = "skipped"
+	EventRemoved  = "removed"
+	EventShredded = "shredded"
+	EventBackup   = "backup"
+	EventMerged   = "merged"
+	EventWarning  = "warning"
+	EventError    = "error"
+)
+
+// String formats the event as a line of command output, such as
+// "skipped: .env (unchanged)" or "warning: .env: permission denied"
+func (e FileEvent) String() string {
+	s := e.Action + ": " + e.Path
+	if e.Path == "" {
+		s = e.Action + ": "
+	}
+	if e.Note != "" {
+		s += " (" + e.Note + ")"
+	}
+	if e.Err != nil {
+		if e.Path != "" {
+			s += ": "
+		}
+		s += e.Err.Error()
+	}
+	return s
+}
+
+// EventLog collects the FileEvents of an operation in order
+type EventLog []FileEvent
+
+// add records that action was taken for path, with an optional note
+func (l *EventLog) add(action, path, note string) {
+	*l = append(*l, FileEvent{Action: action, Path: path, Note: note})
+}
+
+// addErr records a problem with path
+func (l *EventLog) addErr(action, path string, err error) {
+	*l = append(*l, FileEvent{Action: action, Path: path, Err: err})
+}
+
+// Count returns the number of events with action
+func (l EventLog) Count(action string) int {
+	n := 0
+	for _, event := range l {
+		if event.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// skip records a conflicting file the merge left alone, with a reason
+func (r *MergeResult) skip(path, note string) {
+	r.Skipped = append(r.Skipped, path)
+	r.Events.add(EventSkipped, path, note)
+}
+
+// fail records a file the merge could not resolve. path is empty if err
+// already names the file.
+func (r *MergeResult) fail(path string, err error) {
+	r.Errors = append(r.Errors, joinPath(path, err))
+	r.Events.addErr(EventError, path, err)
+}
+
+// skip records a file clean kept, with the reason
+func (r *CleanResult) skip(path, note string, err error) {
+	r.Skipped = append(r.Skipped, path)
+	r.Events = append(r.Events, FileEvent{Action: EventSkipped, Path: path, Note: note, Err: err})
+}
+
+// skip records a matching file left out of the new vault, warning why
+func (r *SplitResult) skip(path string, err error) {
+	r.Skipped = append(r.Skipped, path)
+	r.Events.addErr(EventWarning, path, err)
+}
+
+// skip records a file the unlock left alone, with an optional reason
+func (r *UnlockResult) skip(path, note string) {
+	r.Skipped = append(r.Skipped, path)
+	r.Events.add(EventSkipped, path, note)
+}
+
+// fail records a file the unlock could not restore. path is empty if err
+// already names the file.
+func (r *UnlockResult) fail(path string, err error) {
+	r.Errors = append(r.Errors, joinPath(path, err))
+	r.Events.addErr(EventError, path, err)
+}
+
+// joinPath formats err for the Errors of a result, prefixed by path unless
+// it is empty
+func joinPath(path string, err error) string {
+	if path == "" {
+		return err.Error()
+	}
+	return path + ": " + err.Error()
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockReport(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	dir := filepath.Dir(lockenv.path)
+
+	if err := os.WriteFile(filepath.Join(dir, "b.env"), []byte("B=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	report, err := lockenv.LockFiles(ctx, []string{"b.env", "missing.env"}, password, false)
+	if err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if len(report.Locked) != 1 || report.Locked[0] != "b.env" {
+		t.Errorf("Locked = %v, want [b.env]", report.Locked)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Path != "missing.env" || !errors.Is(report.Failed[0], fs.ErrNotExist) {
+		t.Errorf("Failed = %v, want missing.env not found", report.Failed)
+	}
+	if err := report.Err(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Err() = %v, want it to wrap fs.ErrNotExist", err)
+	}
+
+	report, err = lockenv.FinalizeLock(ctx, password, true, report.Locked)
+	if err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if len(report.Locked) != 1 || len(report.Removed) != 1 || report.Removed[0] != "b.env" {
+		t.Errorf("FinalizeLock report = %+v, want b.env locked and removed", report)
+	}
+	if report.Err() != nil {
+		t.Errorf("Err() = %v, want nil", report.Err())
+	}
+}
+
+func TestFinalizeLock_ReportsUnreadableFiles(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "b.env": "B=1"})
+	dir := filepath.Dir(lockenv.path)
+
+	// a.env is back in the working tree, b.env is still removed
+	if err := os.WriteFile(filepath.Join(dir, "a.env"), []byte("A=2"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	report, err := lockenv.FinalizeLock(ctx, password, false, nil)
+	if err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if len(report.Locked) != 1 || report.Locked[0] != "a.env" {
+		t.Errorf("Locked = %v, want [a.env]", report.Locked)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Path != "b.env" {
+		t.Errorf("Failed = %v, want b.env", report.Failed)
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// SetRequired marks files in the vault as required, or optional again
+// (implements `lockenv require`). `lockenv unlock --strict` fails when a
+// required file is not restored, so a deployment never proceeds with only
+// part of its secrets. Returns the paths whose flag changed.
+func (l *LockEnv) SetRequired(ctx context.Context, password []byte, paths []string, required bool) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	var changed []string
+	for _, path := range paths {
+		path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+		file := metadata.FindFile(path)
+		if file == nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+		}
+		if file.Required != required {
+			file.Required = required
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	metadata.Modified = time.Now()
+	return changed, l.saveMetadata(db, metadata, enc)
+}
+
+// RequiredFiles returns the paths of the files marked required with
+// SetRequired
+func (l *LockEnv) RequiredFiles(ctx context.Context, password []byte) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	var required []string
+	for _, file := range metadata.Files {
+		if file.Required {
+			required = append(required, file.Path)
+		}
+	}
+	return required, nil
+}
+
+// missingRequired returns the required files among files that the unlock
+// recorded in result did not restore. With inTree set, a required file the
+// working tree already holds unchanged counts as restored.
+func (l *LockEnv) missingRequired(files []storage.FileEntry, result *UnlockResult, inTree bool) []string {
+	var missing []string
+	for _, file := range files {
+		if !file.Required || slices.Contains(result.Extracted, file.Path) {
+			continue
+		}
+		if inTree && l.holdsVaultContent(file) {
+			continue
+		}
+		missing = append(missing, file.Path)
+	}
+	return missing
+}
+
+// holdsVaultContent reports whether the working-tree copy of file matches
+// the content hash recorded in the vault
+func (l *LockEnv) holdsVaultContent(file storage.FileEntry) bool {
+	local, err := readSecureFile(filepath.Join(filepath.Dir(l.path), filepath.FromSlash(file.Path)))
+	if err != nil {
+		return false
+	}
+	defer local.Close()
+	hash := sha256.Sum256(local.Borrow())
+	return hex.EncodeToString(hash[:]) == file.Hash
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSetRequired(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "b.env": "B=1"})
+
+	changed, err := lockenv.SetRequired(ctx, password, []string{"./a.env", "b.env"}, true)
+	if err != nil {
+		t.Fatalf("SetRequired failed: %v", err)
+	}
+	if !slices.Equal(changed, []string{"a.env", "b.env"}) {
+		t.Errorf("Expected both files changed, got %v", changed)
+	}
+	if changed, err := lockenv.SetRequired(ctx, password, []string{"a.env"}, true); err != nil || len(changed) != 0 {
+		t.Errorf("Expected no change for a required file, got %v, %v", changed, err)
+	}
+	if _, err := lockenv.SetRequired(ctx, password, []string{"missing.env"}, true); !errors.Is(err, ErrFileNotInVault) {
+		t.Errorf("Expected ErrFileNotInVault, got %v", err)
+	}
+
+	// Relocking keeps the mark
+	root := filepath.Dir(lockenv.path)
+	if err := os.WriteFile(filepath.Join(root, "a.env"), []byte("A=2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{"a.env"}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	if _, err := lockenv.SetRequired(ctx, password, []string{"b.env"}, false); err != nil {
+		t.Fatalf("SetRequired failed: %v", err)
+	}
+	required, err := lockenv.RequiredFiles(ctx, password)
+	if err != nil {
+		t.Fatalf("RequiredFiles failed: %v", err)
+	}
+	if !slices.Equal(required, []string{"a.env"}) {
+		t.Errorf("Expected only a.env required, got %v", required)
+	}
+}
+
+func TestUnlock_MissingRequired(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "b.env": "B=1", "c.env": "C=1"})
+	if _, err := lockenv.SetRequired(ctx, password, []string{"a.env", "b.env"}, true); err != nil {
+		t.Fatalf("SetRequired failed: %v", err)
+	}
+
+	// Required files left out by the patterns are reported
+	result, err := lockenv.Unlock(ctx, password, StrategyUseVault, []string{"c.env"})
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if !slices.Equal(slices.Sorted(slices.Values(result.MissingRequired)), []string{"a.env", "b.env"}) {
+		t.Errorf("Expected a.env and b.env missing, got %v", result.MissingRequired)
+	}
+
+	// A required file already unlocked and unchanged counts as restored
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, []string{"a.env"}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	result, err = lockenv.Unlock(ctx, password, StrategyKeepLocal, []string{"*.env"})
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if len(result.MissingRequired) != 0 {
+		t.Errorf("Expected every required file restored, got %v", result.MissingRequired)
+	}
+
+	// A local edit kept over the vault version is not restored
+	if err := os.WriteFile(filepath.Join(filepath.Dir(lockenv.path), "b.env"), []byte("B=local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = lockenv.Unlock(ctx, password, StrategyKeepLocal, nil)
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if !slices.Equal(result.MissingRequired, []string{"b.env"}) {
+		t.Errorf("Expected b.env missing, got %v", result.MissingRequired)
+	}
+
+	// UnlockTar reports the required files it left out
+	result, err = lockenv.UnlockTar(ctx, password, []string{"a.env"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("UnlockTar failed: %v", err)
+	}
+	if !slices.Equal(result.MissingRequired, []string{"b.env"}) {
+		t.Errorf("Expected b.env missing from the archive, got %v", result.MissingRequired)
+	}
+}
@@ -0,0 +1,364 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/glob"
+)
+
+// SchemaFile is the optional committed file next to .lockenv describing the
+// keys each secret file must define
+const SchemaFile = ".lockenv.schema.json"
+
+// ErrNoSchema is returned by Validate when the project has no SchemaFile
+var ErrNoSchema = errors.New("no schema found (create " + SchemaFile + ")")
+
+// Schema holds the rules of SchemaFile by vault path or glob pattern, e.g.
+//
+//	{"files": {".env.*": {"required": ["DATABASE_URL"], "keys": {"PORT": {"format": "port"}}}}}
+type Schema struct {
+	Files map[string]FileSchema `json:"files"`
+}
+
+// FileSchema lists the rules for the files matching one schema entry. Keys
+// are dotenv variables, or dotted key paths to the scalar values of a
+// structured file (database.host, servers[0]).
+type FileSchema struct {
+	Required []string           `json:"required,omitempty"` // Keys that must be set to a non-empty value
+	Keys     map[string]KeyRule `json:"keys,omitempty"`     // Rules for the value of a key, checked when it is set
+}
+
+// KeyRule constrains the value of a key
+type KeyRule struct {
+	Format  string `json:"format,omitempty"`  // One of the names in schemaFormats, e.g. url or port
+	Pattern string `json:"pattern,omitempty"` // Regular expression the whole value must match
+
+	pattern *regexp.Regexp
+}
+
+// schemaFormats checks values against the formats a KeyRule can name
+var schemaFormats = map[string]func(string) bool{
+	"int": func(v string) bool {
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err == nil
+	},
+	"port": func(v string) bool {
+		n, err := strconv.Atoi(v)
+		return err == nil && n > 0 && n <= 65535
+	},
+	"bool": func(v string) bool {
+		switch strings.ToLower(v) {
+		case "true", "false", "1", "0", "yes", "no", "on", "off":
+			return true
+		}
+		return false
+	},
+	"url": func(v string) bool {
+		u, err := url.Parse(v)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	},
+	"email": func(v string) bool {
+		addr, err := mail.ParseAddress(v)
+		return err == nil && addr.Address == v
+	},
+	"uuid": regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`).MatchString,
+	"hex": func(v string) bool {
+		_, err := hex.DecodeString(v)
+		return err == nil && v != ""
+	},
+	"base64": func(v string) bool {
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if _, err := enc.DecodeString(v); err == nil {
+				return v != ""
+			}
+		}
+		return false
+	},
+}
+
+// SchemaViolation is a key of File that does not satisfy the schema. Key is
+// empty when the file itself is missing.
+type SchemaViolation struct {
+	File    string
+	Key     string
+	Message string // Never includes the value
+}
+
+func (v SchemaViolation) String() string {
+	if v.Key == "" {
+		return fmt.Sprintf("%s: %s", v.File, v.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", v.File, v.Key, v.Message)
+}
+
+// SchemaResult is the outcome of Validate
+type SchemaResult struct {
+	Files      []string          // Files checked against the schema
+	Violations []SchemaViolation // Problems found, by file then key
+}
+
+// OK reports whether every file satisfies the schema
+func (r *SchemaResult) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// LoadSchema reads SchemaFile from dir. Returns ErrNoSchema if there is none.
+func LoadSchema(dir string) (*Schema, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SchemaFile))
+	if os.IsNotExist(err) {
+		return nil, ErrNoSchema
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", SchemaFile, err)
+	}
+	schema, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", SchemaFile, err)
+	}
+	return schema, nil
+}
+
+// ParseSchema parses the contents of a SchemaFile, refusing unknown fields
+// and formats so that a typo does not silently disable a rule
+func ParseSchema(data []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var schema Schema
+	if err := dec.Decode(&schema); err != nil {
+		return nil, err
+	}
+	for pattern, file := range schema.Files {
+		for key, rule := range file.Keys {
+			if rule.Format != "" && schemaFormats[rule.Format] == nil {
+				return nil, fmt.Errorf("%s: %s: unknown format %q", pattern, key, rule.Format)
+			}
+			if rule.Pattern != "" {
+				re, err := regexp.Compile(`^(?:` + rule.Pattern + `)$`)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s: invalid pattern: %w", pattern, key, err)
+				}
+				rule.pattern = re
+				file.Keys[key] = rule
+			}
+		}
+	}
+	return &schema, nil
+}
+
+// rulesFor returns the entries of the schema matching path
+func (s *Schema) rulesFor(path string) []FileSchema {
+	var patterns []string
+	for pattern := range s.Files {
+		if matchesAnyPattern(path, []string{pattern}) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+	rules := make([]FileSchema, len(patterns))
+	for i, pattern := range patterns {
+		rules[i] = s.Files[pattern]
+	}
+	return rules
+}
+
+// Check validates the contents of the file at path against the schema
+// entries matching path. Returns an error if the file is neither a dotenv
+// nor a structured file.
+func (s *Schema) Check(path string, data []byte) ([]SchemaViolation, error) {
+	values, err := fileValues(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []SchemaViolation
+	for _, rules := range s.rulesFor(path) {
+		for _, key := range rules.Required {
+			value, ok := values[key]
+			switch {
+			case !ok:
+				violations = append(violations, SchemaViolation{File: path, Key: key, Message: "required key is missing"})
+			case value == "":
+				violations = append(violations, SchemaViolation{File: path, Key: key, Message: "required key is empty"})
+			}
+		}
+
+		keys := make([]string, 0, len(rules.Keys))
+		for key := range rules.Keys {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, ok := values[key]
+			if !ok || value == "" {
+				continue
+			}
+			rule := rules.Keys[key]
+			if rule.Format != "" && !schemaFormats[rule.Format](value) {
+				violations = append(violations, SchemaViolation{File: path, Key: key, Message: "not a valid " + rule.Format})
+			}
+			if rule.pattern != nil && !rule.pattern.MatchString(value) {
+				violations = append(violations, SchemaViolation{File: path, Key: key, Message: fmt.Sprintf("does not match %s", rule.Pattern)})
+			}
+		}
+	}
+	return violations, nil
+}
+
+// fileValues returns the values defined in a dotenv or structured file by
+// key. The last assignment of a dotenv variable wins.
+func fileValues(path string, data []byte) (map[string]string, error) {
+	if format := DetectStructuredFormat(path); format != "" {
+		values, err := FlattenStructured(format, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return values, nil
+	}
+	if !IsDotenvFile(path) {
+		return nil, fmt.Errorf("%s: not a dotenv, JSON, YAML or TOML file", path)
+	}
+	entries, err := ParseDotenv(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := make(map[string]string, len(entries))
+	for _, e := range entries {
+		values[e.Name] = e.Value
+	}
+	return values, nil
+}
+
+// Validate checks the vault files against SchemaFile (implements
+// `lockenv validate`), reporting required keys that are missing or empty
+// and values of the wrong format. Without paths, every vault file matched by
+// the schema is checked, as well as every file the schema names exactly.
+// With local set, the working-tree copies are checked instead and password
+// is not used. Values are never returned.
+func (l *LockEnv) Validate(ctx context.Context, password []byte, paths []string, local bool) (*SchemaResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+	schema, err := LoadSchema(filepath.Dir(l.path))
+	if err != nil {
+		return nil, err
+	}
+
+	// read returns the contents of a file and whether it exists
+	var read func(path string) ([]byte, bool, error)
+	var candidates []string
+	if local {
+		files, err := l.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			candidates = append(candidates, file.Path)
+		}
+		read = func(path string) ([]byte, bool, error) {
+			data, err := l.validator.ReadFileInRoot(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, false, nil
+			}
+			return data, err == nil, err
+		}
+	} else {
+		db, err := l.open()
+		if err != nil {
+			return nil, openError(err)
+		}
+		defer db.Close()
+
+		metadata, enc, err := l.readMetadata(ctx, db, password)
+		if err != nil {
+			return nil, err
+		}
+		defer enc.Destroy()
+
+		for _, file := range metadata.Files {
+			candidates = append(candidates, file.Path)
+		}
+		read = func(path string) ([]byte, bool, error) {
+			file := metadata.FindFile(path)
+			if file == nil {
+				return nil, false, nil
+			}
+			plain, err := l.decryptEntry(ctx, db, file, enc)
+			if err != nil {
+				return nil, false, err
+			}
+			defer plain.Close()
+			return slices.Clone(plain.Borrow()), true, nil
+		}
+	}
+
+	var targets []string
+	if len(paths) > 0 {
+		for _, path := range paths {
+			path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+			if len(schema.rulesFor(path)) == 0 {
+				return nil, fmt.Errorf("%s: no rules in %s", path, SchemaFile)
+			}
+			if !slices.Contains(targets, path) {
+				targets = append(targets, path)
+			}
+		}
+	} else {
+		for _, path := range candidates {
+			if len(schema.rulesFor(path)) > 0 {
+				targets = append(targets, path)
+			}
+		}
+		for pattern := range schema.Files {
+			if !glob.HasMeta(pattern) && !slices.Contains(targets, pattern) {
+				targets = append(targets, pattern)
+			}
+		}
+		sort.Strings(targets)
+	}
+
+	result := &SchemaResult{Files: targets}
+	for _, path := range targets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, found, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			msg := "not in vault"
+			if local {
+				msg = "file is missing"
+			}
+			result.Violations = append(result.Violations, SchemaViolation{File: path, Message: msg})
+			continue
+		}
+		violations, err := schema.Check(path, data)
+		crypto.ClearBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		result.Violations = append(result.Violations, violations...)
+	}
+	return result, nil
+}
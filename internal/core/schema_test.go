@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSchema(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{"unknown field", `{"files": {".env": {"requird": ["A"]}}}`, "unknown field"},
+		{"unknown format", `{"files": {".env": {"keys": {"A": {"format": "ipv9"}}}}}`, "unknown format"},
+		{"invalid pattern", `{"files": {".env": {"keys": {"A": {"pattern": "("}}}}}`, "invalid pattern"},
+	} {
+		if _, err := ParseSchema([]byte(tc.schema)); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected %q error, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"files": {
+		".env.*": {
+			"required": ["DATABASE_URL", "API_KEY", "EMPTY"],
+			"keys": {
+				"DATABASE_URL": {"format": "url"},
+				"PORT": {"format": "port"},
+				"API_KEY": {"pattern": "sk_[a-z]+"},
+				"OPTIONAL": {"format": "int"}
+			}
+		},
+		"config.json": {"required": ["db.host"], "keys": {"db.port": {"format": "int"}}}
+	}}`))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	violations, err := schema.Check(".env.prod", []byte("DATABASE_URL=localhost\nPORT=99999\nAPI_KEY=sk_live1\nEMPTY=\n"))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	want := []string{
+		".env.prod: EMPTY: required key is empty",
+		".env.prod: API_KEY: does not match sk_[a-z]+",
+		".env.prod: DATABASE_URL: not a valid url",
+		".env.prod: PORT: not a valid port",
+	}
+	got := make([]string, len(violations))
+	for i, v := range violations {
+		got[i] = v.String()
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Check() = %q, want %q", got, want)
+	}
+
+	if violations, err := schema.Check(".env.dev", []byte("DATABASE_URL=postgres://db:5432/app\nAPI_KEY=sk_test\nEMPTY=x\n")); err != nil || len(violations) != 0 {
+		t.Errorf("Expected a valid file to pass, got %v, %v", violations, err)
+	}
+
+	violations, err = schema.Check("config.json", []byte(`{"db": {"port": "x"}}`))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(violations) != 2 || violations[0].Key != "db.host" || violations[1].Key != "db.port" {
+		t.Errorf("Expected db.host missing and db.port invalid, got %v", violations)
+	}
+}
+
+func TestSchemaFormats(t *testing.T) {
+	for _, tc := range []struct {
+		format, value string
+		valid         bool
+	}{
+		{"int", "-42", true},
+		{"int", "4.2", false},
+		{"port", "8080", true},
+		{"port", "0", false},
+		{"bool", "Yes", true},
+		{"bool", "maybe", false},
+		{"url", "https://example.com/x", true},
+		{"url", "example.com", false},
+		{"email", "ops@example.com", true},
+		{"email", "Ops <ops@example.com>", false},
+		{"uuid", "123E4567-e89b-12d3-a456-426614174000", true},
+		{"uuid", "123e4567", false},
+		{"hex", "deadBEEF", true},
+		{"hex", "xyz", false},
+		{"base64", "c2VjcmV0", true},
+		{"base64", "not base64!", false},
+	} {
+		if got := schemaFormats[tc.format](tc.value); got != tc.valid {
+			t.Errorf("%s(%q) = %v, want %v", tc.format, tc.value, got, tc.valid)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{
+		".env.dev":  "PORT=8080\n",
+		".env.prod": "PORT=http\n",
+	})
+	root := filepath.Dir(lockenv.path)
+
+	if _, err := lockenv.Validate(ctx, password, nil, false); !errors.Is(err, ErrNoSchema) {
+		t.Fatalf("Expected ErrNoSchema, got %v", err)
+	}
+
+	schema := `{"files": {".env.*": {"keys": {"PORT": {"format": "port"}}}, ".env.ci": {}}}`
+	if err := os.WriteFile(filepath.Join(root, SchemaFile), []byte(schema), 0644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+
+	result, err := lockenv.Validate(ctx, password, nil, false)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if !slices.Equal(result.Files, []string{".env.ci", ".env.dev", ".env.prod"}) {
+		t.Errorf("Unexpected files checked: %v", result.Files)
+	}
+	want := []SchemaViolation{
+		{File: ".env.ci", Message: "not in vault"},
+		{File: ".env.prod", Key: "PORT", Message: "not a valid port"},
+	}
+	if !slices.Equal(result.Violations, want) {
+		t.Errorf("Violations = %v, want %v", result.Violations, want)
+	}
+
+	if result, err := lockenv.Validate(ctx, password, []string{"./.env.dev"}, false); err != nil || !result.OK() {
+		t.Errorf("Expected .env.dev to be valid, got %+v, %v", result, err)
+	}
+	if _, err := lockenv.Validate(ctx, password, []string{"notes.txt"}, false); err == nil {
+		t.Error("Expected an error for a file without rules")
+	}
+
+	// The working tree is only read with local set
+	if err := os.WriteFile(filepath.Join(root, ".env.prod"), []byte("PORT=443\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result, err = lockenv.Validate(ctx, nil, []string{".env.prod", ".env.ci"}, true)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if want := []SchemaViolation{{File: ".env.ci", Message: "file is missing"}}; !slices.Equal(result.Violations, want) {
+		t.Errorf("Violations = %v, want %v", result.Violations, want)
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactedValue replaces secrets in output filtered by a SecretFilter
+const RedactedValue = "***"
+
+// SecretFilter is an io.Writer that replaces occurrences of secret values
+// with *** before passing output on (implements `lockenv run --redact`).
+// Secrets split across writes are still caught: bytes that could start a
+// secret are held back until the next write or Flush. Safe for concurrent
+// use.
+type SecretFilter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets [][]byte // longest first
+	pending []byte
+}
+
+// NewSecretFilter returns a SecretFilter writing to w. Each line of a
+// multiline value is also treated as a secret. Values shorter than four
+// bytes are ignored, as they would match unrelated output.
+func NewSecretFilter(w io.Writer, values []string) *SecretFilter {
+	seen := make(map[string]bool)
+	var secrets [][]byte
+	add := func(s string) {
+		if len(s) >= minRedactLength && !seen[s] {
+			seen[s] = true
+			secrets = append(secrets, []byte(s))
+		}
+	}
+	for _, v := range values {
+		add(v)
+		if strings.ContainsAny(v, "\r\n") {
+			for _, line := range strings.Split(strings.ReplaceAll(v, "\r\n", "\n"), "\n") {
+				add(strings.TrimSpace(line))
+			}
+		}
+	}
+	sort.SliceStable(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return &SecretFilter{w: w, secrets: secrets}
+}
+
+// Write filters p and writes everything that can no longer be part of a
+// secret. It reports len(p) on success.
+func (f *SecretFilter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pending = append(f.pending, p...)
+	out, held := f.filter(false)
+	f.pending = append(f.pending[:0], held...)
+	if _, err := f.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any held-back output, e.g. once the stream has ended
+func (f *SecretFilter) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out, _ := f.filter(true)
+	f.pending = f.pending[:0]
+	_, err := f.w.Write(out)
+	return err
+}
+
+// filter replaces secrets in the pending output. Unless final is set, a
+// trailing part that is a prefix of some secret is returned as held instead.
+func (f *SecretFilter) filter(final bool) (out, held []byte) {
+	var b bytes.Buffer
+	data := f.pending
+	i := 0
+scan:
+	for i < len(data) {
+		for _, s := range f.secrets {
+			if bytes.HasPrefix(data[i:], s) {
+				b.WriteString(RedactedValue)
+				i += len(s)
+				continue scan
+			}
+		}
+		if !final {
+			for _, s := range f.secrets {
+				if len(data)-i < len(s) && bytes.HasPrefix(s, data[i:]) {
+					held = data[i:]
+					break scan
+				}
+			}
+		}
+		b.WriteByte(data[i])
+		i++
+	}
+	return b.Bytes(), held
+}
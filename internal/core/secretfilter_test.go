@@ -0,0 +1,55 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSecretFilter(t *testing.T) {
+	values := []string{"hunter22", "hunter2222", "abc", "-----BEGIN-----\nline-two\n"}
+
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{name: "single write", writes: []string{"pw=hunter22 ok\n"}, want: "pw=*** ok\n"},
+		{name: "longest match wins", writes: []string{"hunter2222"}, want: "***"},
+		{name: "split across writes", writes: []string{"pw=hun", "ter", "22!"}, want: "pw=***!"},
+		{name: "prefix at end of stream", writes: []string{"hunt"}, want: "hunt"},
+		{name: "short values ignored", writes: []string{"abc"}, want: "abc"},
+		{name: "multiline value lines", writes: []string{"got line-two\n"}, want: "got ***\n"},
+		{name: "no secrets", writes: []string{"hello ", "world"}, want: "hello world"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			f := NewSecretFilter(&out, values)
+			for _, w := range tt.writes {
+				n, err := f.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if err := f.Flush(); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretFilter_HoldsOnlyPossiblePrefixes(t *testing.T) {
+	var out bytes.Buffer
+	f := NewSecretFilter(&out, []string{"secret-value"})
+
+	if _, err := f.Write([]byte("line one\nsec")); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "line one\n" {
+		t.Errorf("output before flush = %q, want %q", got, "line one\n")
+	}
+}
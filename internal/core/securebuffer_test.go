@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// leaks collects the allocation sites of SecureBuffers that were garbage
+// collected without being closed
+var leaks struct {
+	sync.Mutex
+	sites []string
+}
+
+func recordLeak(site string) {
+	leaks.Lock()
+	leaks.sites = append(leaks.sites, site)
+	leaks.Unlock()
+}
+
+// collectGarbage runs the garbage collector and gives pending finalizers a
+// chance to run
+func collectGarbage() {
+	for range 3 {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestMain fails the test run if any SecureBuffer leaks, i.e. is reclaimed
+// without having been wiped by Close. The hash caches and signed
+// generations of test vaults are kept out of the user's directories.
+func TestMain(m *testing.M) {
+	crypto.SetLeakHandler(recordLeak)
+	cache, err := os.MkdirTemp("", "lockenv-cache-*")
+	if err == nil {
+		os.Setenv("XDG_CACHE_HOME", cache)
+		os.Setenv("XDG_CONFIG_HOME", cache)
+	}
+	code := m.Run()
+	os.RemoveAll(cache)
+
+	collectGarbage()
+	leaks.Lock()
+	defer leaks.Unlock()
+	if len(leaks.sites) > 0 {
+		fmt.Fprintf(os.Stderr, "FAIL: %d SecureBuffer(s) were never closed:\n", len(leaks.sites))
+		for _, site := range leaks.sites {
+			fmt.Fprintf(os.Stderr, "\tallocated at %s\n", site)
+		}
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func TestSecureBuffer_LeakDetection(t *testing.T) {
+	var mu sync.Mutex
+	var found []string
+	crypto.SetLeakHandler(func(site string) {
+		mu.Lock()
+		found = append(found, site)
+		mu.Unlock()
+	})
+	defer crypto.SetLeakHandler(recordLeak)
+
+	func() {
+		closed := crypto.SecureCopy([]byte("wiped"))
+		closed.Close()
+		crypto.SecureCopy([]byte("leaked"))
+	}()
+	collectGarbage()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(found) != 1 {
+		t.Fatalf("expected 1 leak, got %d: %v", len(found), found)
+	}
+}
+
+func TestSecureBuffer_CloseWipes(t *testing.T) {
+	data := []byte("secret")
+	buf := crypto.AdoptSecure(data)
+	if string(buf.Borrow()) != "secret" {
+		t.Fatalf("Borrow() = %q, want %q", buf.Borrow(), "secret")
+	}
+	buf.Close()
+	buf.Close() // Idempotent
+	for _, b := range data {
+		if b != 0 {
+			t.Fatalf("adopted data not wiped: %q", data)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Len() after Close = %d, want 0", buf.Len())
+	}
+}
+
+func TestSecureBuffer_NoLeaksAcrossOperations(t *testing.T) {
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{".env": "A=1\n"})
+
+	ctx := context.Background()
+	local := filepath.Join(filepath.Dir(lockenv.path), ".env")
+	if err := os.WriteFile(local, []byte("A=2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := lockenv.Diff(ctx, pw, DiffOptions{}); err != nil || !result.Changed() {
+		t.Fatalf("Expected .env changed, got %+v, %v", result, err)
+	}
+	if _, err := lockenv.Unlock(ctx, pw, StrategyUseVault, nil); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := lockenv.ChangePassword(pw, []byte("new123")); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	collectGarbage()
+	leaks.Lock()
+	defer leaks.Unlock()
+	if len(leaks.sites) > 0 {
+		t.Fatalf("SecureBuffers never closed: %v", leaks.sites)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// FileDetails describes one file in the vault
+type FileDetails struct {
+	storage.FileEntry             // Recorded at the last lock, with domain, note and lock history
+	Status            string      // As in FileStatus: unchanged, modified, vault only, ...
+	EncryptedSize     int64       // Size of the stored blob, 0 if never locked
+	LocalModTime      time.Time   // Modification time of the working-tree copy, zero if absent
+	LocalMode         os.FileMode // Permissions of the working-tree copy, 0 if absent
+}
+
+// Show returns the details of a file in the vault without decrypting its
+// content (implements `lockenv show`)
+func (l *LockEnv) Show(ctx context.Context, password []byte, path string) (*FileDetails, error) {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+
+	status, err := l.Status(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	details := &FileDetails{}
+	for _, f := range status.Files {
+		if f.Path == path {
+			details.Status = f.Status
+			break
+		}
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	file := metadata.FindFile(path)
+	if file == nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+	}
+	details.FileEntry = *file
+
+	if sealed, err := db.HasFileData(path); err == nil && sealed {
+		if data, err := db.GetFileData(ctx, path); err == nil {
+			details.EncryptedSize = int64(len(data))
+		}
+	}
+	if info, err := os.Stat(filepath.Join(filepath.Dir(l.path), filepath.FromSlash(path))); err == nil {
+		details.LocalModTime = info.ModTime()
+		details.LocalMode = info.Mode().Perm()
+	}
+	return details, nil
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+func TestShow(t *testing.T) {
+	password := []byte("test-password")
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1"})
+	if err := lockenv.SetNote(ctx, password, ".env", "owned by SRE"); err != nil {
+		t.Fatalf("SetNote failed: %v", err)
+	}
+
+	details, err := lockenv.Show(ctx, password, "./.env")
+	if err != nil {
+		t.Fatalf("Show failed: %v", err)
+	}
+	if details.Path != ".env" || details.Size != 3 || details.Note != "owned by SRE" {
+		t.Errorf("details = %+v", details)
+	}
+	if details.Status != "vault only" {
+		t.Errorf("Status = %q, want %q", details.Status, "vault only")
+	}
+	if details.EncryptedSize <= details.Size {
+		t.Errorf("EncryptedSize = %d, want more than the plaintext size", details.EncryptedSize)
+	}
+	if !details.LocalModTime.IsZero() {
+		t.Errorf("LocalModTime = %v, want zero for a removed file", details.LocalModTime)
+	}
+	if len(details.History) != 1 || details.History[0].Hash != details.Hash {
+		t.Errorf("History = %+v, want one lock of the current content", details.History)
+	}
+
+	if _, err := lockenv.Show(ctx, password, "missing.env"); !errors.Is(err, ErrFileNotInVault) {
+		t.Errorf("Show(missing) error = %v, want ErrFileNotInVault", err)
+	}
+}
+
+func TestLockHistory(t *testing.T) {
+	password := []byte("test-password")
+	ctx := context.Background()
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1"})
+	path := filepath.Join(filepath.Dir(lockenv.path), ".env")
+
+	relock := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+			t.Fatalf("LockFiles failed: %v", err)
+		}
+		if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+			t.Fatalf("FinalizeLock failed: %v", err)
+		}
+	}
+	relock("A=2")
+	relock("A=2") // same content is not a new event
+
+	details, err := lockenv.Show(ctx, password, ".env")
+	if err != nil {
+		t.Fatalf("Show failed: %v", err)
+	}
+	if len(details.History) != 2 {
+		t.Fatalf("History has %d events, want 2", len(details.History))
+	}
+	if details.History[1].Hash != details.Hash || details.History[0].Hash == details.Hash {
+		t.Errorf("History = %+v, want the old content then the current", details.History)
+	}
+}
+
+func TestRecordLock_CapsHistory(t *testing.T) {
+	var entry storage.FileEntry
+	for i := 0; i < storage.MaxLockHistory+5; i++ {
+		entry.RecordLock(string(rune('a'+i)), time.Now())
+	}
+	if len(entry.History) != storage.MaxLockHistory {
+		t.Fatalf("History has %d events, want %d", len(entry.History), storage.MaxLockHistory)
+	}
+	if last := entry.History[len(entry.History)-1].Hash; last != string(rune('a'+storage.MaxLockHistory+4)) {
+		t.Errorf("newest event = %q, want the last recorded", last)
+	}
+}
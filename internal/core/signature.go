@@ -0,0 +1,299 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/illarion/lockenv/internal/storage"
+	"golang.org/x/crypto/ssh"
+)
+
+// SignersFile lists the SSH keys trusted to sign the manifest, in
+// authorized_keys format. It lives next to .lockenv and is committed, so
+// changes to it go through code review.
+const SignersFile = ".lockenv-signers"
+
+// manifestSigContext separates manifest signatures from other uses of the key
+const manifestSigContext = "lockenv-manifest-v2\n"
+
+var (
+	ErrNotSigned       = errors.New("vault manifest is not signed")
+	ErrUntrustedSigner = errors.New("manifest signed by a key not in " + SignersFile)
+	ErrBadSignature    = errors.New("manifest signature does not match; the vault changed since it was signed")
+	ErrStaleSignature  = errors.New("manifest signature is older than one verified before; the vault may have been rolled back")
+)
+
+// ManifestSignature is a maintainer's signature over the manifest
+type ManifestSignature struct {
+	PublicKey string    `json:"publicKey"` // Signer's SSH key in authorized_keys format
+	Signature []byte    `json:"signature"` // SSH wire-format signature
+	Signed    time.Time `json:"signed"`
+
+	// Generation is the vault generation the signature covers, higher for
+	// each new signature
+	Generation uint64 `json:"generation,omitempty"`
+}
+
+// signedManifest is what a manifest signature covers. The vault ID keeps a
+// signature from being moved to another vault with the same files, and the
+// generation lets verify refuse an older signed vault put back in place of
+// a newer one.
+type signedManifest struct {
+	VaultID    string                  `json:"vaultId"`
+	Generation uint64                  `json:"generation"`
+	Files      []storage.ManifestEntry `json:"files"`
+}
+
+// manifestPayload returns the signed form of the manifest of vault vaultID
+// at generation, which commits to the path, size and content hash of every
+// file
+func manifestPayload(db storage.ManifestStore, vaultID string, generation uint64) ([]byte, error) {
+	entries, err := db.GetManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	data, err := json.Marshal(signedManifest{VaultID: vaultID, Generation: generation, Files: entries})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return append([]byte(manifestSigContext), data...), nil
+}
+
+// SignManifest signs the current manifest with an ed25519 SSH key
+// (implements `lockenv lock --sign`). No password is required, since the
+// manifest is stored in plaintext; unlock checks decrypted files against it.
+func (l *LockEnv) SignManifest(ctx context.Context, key ed25519.PrivateKey) (*ManifestSignature, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	vaultID, err := db.GetOrCreateVaultID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vault ID: %w", err)
+	}
+	// Signing changes the vault, so every signature gets a higher generation
+	// than the one before
+	generation, err := db.GetGeneration()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generation: %w", err)
+	}
+	generation++
+	payload, err := manifestPayload(db, vaultID, generation)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(rand.Reader, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign manifest: %w", err)
+	}
+
+	signature := &ManifestSignature{
+		PublicKey:  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+		Signature:  ssh.Marshal(sig),
+		Signed:     time.Now(),
+		Generation: generation,
+	}
+	if err := putRecord(db, recordSignature, signature); err != nil {
+		return nil, fmt.Errorf("failed to store signature: %w", err)
+	}
+	if err := db.UpdateModified(); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// LoadSigners reads trusted signing keys in authorized_keys format. Blank
+// lines and # comments are skipped.
+func LoadSigners(path string) ([]ssh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []ssh.PublicKey
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys", path)
+	}
+	return keys, nil
+}
+
+// VerifySignature checks that the manifest is signed by one of signers
+// (implements `lockenv verify --require-signature`)
+func (l *LockEnv) VerifySignature(ctx context.Context, signers []ssh.PublicKey) (*ManifestSignature, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	return verifyManifestSignature(db, signers)
+}
+
+// verifyManifestSignature checks the stored signature against the current
+// manifest and the trusted keys, and that it is not older than the newest
+// signature verified for this vault on this machine, which it then records
+func verifyManifestSignature(db storage.Backend, signers []ssh.PublicKey) (*ManifestSignature, error) {
+	signature := &ManifestSignature{}
+	found, err := getRecord(db, recordSignature, signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+	if !found {
+		return nil, ErrNotSigned
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signature.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signer key: %w", err)
+	}
+	trusted := false
+	for _, s := range signers {
+		if bytes.Equal(s.Marshal(), key.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return nil, fmt.Errorf("%w (%s)", ErrUntrustedSigner, ssh.FingerprintSHA256(key))
+	}
+
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(signature.Signature, &sig); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	// Signing gives the vault an ID, so a vault without one was not signed
+	vaultID, err := db.GetVaultID()
+	if err != nil {
+		return nil, ErrBadSignature
+	}
+	payload, err := manifestPayload(db, vaultID, signature.Generation)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.Verify(payload, &sig); err != nil {
+		return nil, ErrBadSignature
+	}
+
+	seen := signedGenerationPath(vaultID)
+	last := readSignedGeneration(seen)
+	if signature.Generation < last {
+		return nil, fmt.Errorf("%w (generation %d, verified %d)", ErrStaleSignature, signature.Generation, last)
+	}
+	if signature.Generation > last {
+		if err := writeSignedGeneration(seen, signature.Generation); err != nil {
+			return nil, fmt.Errorf("failed to record signed generation: %w", err)
+		}
+	}
+	return signature, nil
+}
+
+// signedGenerationPath returns the file recording the newest signed
+// generation verified for the vault, or "" without a user config
+// directory. It is kept outside the vault, where replacing the vault
+// cannot roll it back.
+func signedGenerationPath(vaultID string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(vaultID))
+	return filepath.Join(dir, "lockenv", "signed", hex.EncodeToString(sum[:8]))
+}
+
+// readSignedGeneration returns the generation recorded at path, 0 if none
+func readSignedGeneration(path string) uint64 {
+	if path == "" {
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	generation, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return generation
+}
+
+// writeSignedGeneration records generation at path
+func writeSignedGeneration(path string, generation uint64) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DirPermSecure); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.FormatUint(generation, 10)+"\n"), FilePermSecure)
+}
+
+// RequireSignature makes later Unlock calls refuse to run unless the
+// manifest is signed by one of signers, and refuse files whose stored hash
+// differs from the signed manifest. Passing nil removes the requirement.
+func (l *LockEnv) RequireSignature(signers []ssh.PublicKey) {
+	l.signers = signers
+}
+
+// signedHashes verifies the manifest signature and returns the signed
+// content hash of each file
+func (l *LockEnv) signedHashes(db storage.Backend) (map[string]string, error) {
+	if _, err := verifyManifestSignature(db, l.signers); err != nil {
+		return nil, err
+	}
+	return manifestHashes(db)
+}
+
+// manifestHashes returns the content hash of each file in the manifest
+func manifestHashes(db storage.ManifestStore) (map[string]string, error) {
+	entries, err := db.GetManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	hashes := make(map[string]string, len(entries))
+	for _, e := range entries {
+		hashes[e.Path] = e.Hash
+	}
+	return hashes, nil
+}
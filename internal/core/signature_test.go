@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigningKey(t *testing.T) (ed25519.PrivateKey, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	return priv, sshPub
+}
+
+func TestSignManifest_Verify(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1", "b.env": "B=2"})
+	key, pub := newSigningKey(t)
+	_, other := newSigningKey(t)
+
+	if _, err := lockenv.VerifySignature(ctx, []ssh.PublicKey{pub}); !errors.Is(err, ErrNotSigned) {
+		t.Fatalf("Expected ErrNotSigned, got %v", err)
+	}
+
+	signature, err := lockenv.SignManifest(ctx, key)
+	if err != nil {
+		t.Fatalf("SignManifest failed: %v", err)
+	}
+	if signature.Signed.IsZero() {
+		t.Error("Signing time not recorded")
+	}
+
+	if _, err := lockenv.VerifySignature(ctx, []ssh.PublicKey{other, pub}); err != nil {
+		t.Errorf("VerifySignature failed: %v", err)
+	}
+	if _, err := lockenv.VerifySignature(ctx, []ssh.PublicKey{other}); !errors.Is(err, ErrUntrustedSigner) {
+		t.Errorf("Expected ErrUntrustedSigner, got %v", err)
+	}
+
+	// Relocking a file changes the manifest, which invalidates the signature
+	path := filepath.Join(filepath.Dir(lockenv.path), "a.env")
+	if err := os.WriteFile(path, []byte("A=changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+	if _, err := lockenv.VerifySignature(ctx, []ssh.PublicKey{pub}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+}
+
+func TestVerifySignature_Rollback(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	key, pub := newSigningKey(t)
+
+	first, err := lockenv.SignManifest(ctx, key)
+	if err != nil {
+		t.Fatalf("SignManifest failed: %v", err)
+	}
+	old, err := os.ReadFile(lockenv.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := lockenv.SignManifest(ctx, key)
+	if err != nil {
+		t.Fatalf("SignManifest failed: %v", err)
+	}
+	if second.Generation <= first.Generation {
+		t.Fatalf("Expected a higher generation, got %d after %d", second.Generation, first.Generation)
+	}
+	if _, err := lockenv.VerifySignature(ctx, []ssh.PublicKey{pub}); err != nil {
+		t.Fatalf("VerifySignature failed: %v", err)
+	}
+
+	// The older vault is validly signed, but verify has seen a newer one
+	lockenv.Close()
+	if err := os.WriteFile(lockenv.path, old, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockenv.VerifySignature(ctx, []ssh.PublicKey{pub}); !errors.Is(err, ErrStaleSignature) {
+		t.Errorf("Expected ErrStaleSignature, got %v", err)
+	}
+}
+
+func TestUnlock_RequireSignature(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	key, pub := newSigningKey(t)
+	path := filepath.Join(filepath.Dir(lockenv.path), "a.env")
+
+	lockenv.RequireSignature([]ssh.PublicKey{pub})
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil); !errors.Is(err, ErrNotSigned) {
+		t.Fatalf("Expected ErrNotSigned, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("File written despite missing signature: %v", err)
+	}
+
+	if _, err := lockenv.SignManifest(ctx, key); err != nil {
+		t.Fatalf("SignManifest failed: %v", err)
+	}
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "A=1" {
+		t.Errorf("Unexpected content %q: %v", data, err)
+	}
+}
+
+func TestLoadSigners(t *testing.T) {
+	_, pub := newSigningKey(t)
+	path := filepath.Join(t.TempDir(), SignersFile)
+	content := "# release team\n\n" + string(ssh.MarshalAuthorizedKey(pub))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadSigners(path)
+	if err != nil {
+		t.Fatalf("LoadSigners failed: %v", err)
+	}
+	if len(keys) != 1 || ssh.FingerprintSHA256(keys[0]) != ssh.FingerprintSHA256(pub) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	if err := os.WriteFile(path, []byte("# nobody\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSigners(path); err == nil {
+		t.Error("Expected an error for a file without keys")
+	}
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/git"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// snapshotDir is the directory in the git directory holding the snapshots
+// of StashSnapshot
+const snapshotDir = "lockenv"
+
+// snapshotRecord is a snapshot as stored on disk: the stash record with the
+// paths in plaintext, as in the vault's own stash, and the files encrypted
+type snapshotRecord struct {
+	Stash
+	Data []byte `json:"data"` // Encrypted JSON of the snapshotEntry list
+}
+
+// snapshotEntry is one file in a snapshot
+type snapshotEntry struct {
+	Path    string    `json:"path"`
+	Mode    uint32    `json:"mode"`
+	ModTime time.Time `json:"modTime"`
+	Content []byte    `json:"content"`
+}
+
+// snapshotPath returns where the snapshot of this vault is kept: in the git
+// directory, so it is never committed and survives checkouts and rebases,
+// named after the vault directory within the working tree
+func (l *LockEnv) snapshotPath() (string, error) {
+	dir := filepath.Dir(l.path)
+	gitDir, err := git.GitDir(dir)
+	if err != nil {
+		return "", err
+	}
+	top, err := git.TopLevel(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(top, dir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel)))
+	return filepath.Join(gitDir, snapshotDir, "stash-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// StashSnapshot copies the unlocked files with local edits - modified since
+// they were locked, or never locked - into an encrypted snapshot in the git
+// directory (implements `lockenv stash --snapshot`). Unlike Stash, neither
+// the working tree nor the vault changes, so it can run before git
+// operations. Returns the stash record listing the copied paths.
+func (l *LockEnv) StashSnapshot(ctx context.Context, password []byte) (*Stash, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	path, err := l.snapshotPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, ErrStashExists
+	}
+
+	edited, err := l.LocalEdits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(edited) == 0 {
+		return nil, ErrNothingToStash
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	_, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	repoRoot := filepath.Dir(l.path)
+	var entries []snapshotEntry
+	defer func() {
+		for _, entry := range entries {
+			crypto.ClearBytes(entry.Content)
+		}
+	}()
+	for _, path := range edited {
+		validPath, err := l.validator.ValidateExistingPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
+		info, err := os.Stat(platformPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", validPath, err)
+		}
+		content, err := os.ReadFile(platformPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", validPath, err)
+		}
+		entries = append(entries, snapshotEntry{
+			Path:    validPath,
+			Mode:    uint32(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+			Content: content,
+		})
+	}
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	defer crypto.ClearBytes(plain)
+	data, err := enc.For(crypto.PurposeBlobs).Encrypt(plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt snapshot: %w", err)
+	}
+
+	record := snapshotRecord{Stash: Stash{Created: time.Now()}, Data: data}
+	for _, entry := range entries {
+		record.Files = append(record.Files, entry.Path)
+	}
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DirPermSecure); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, FilePermSecure); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return &record.Stash, nil
+}
+
+// LocalEdits returns the tracked files whose working-tree copy was modified
+// since it was locked, or was never locked: the edits only the working tree
+// holds (no password required)
+func (l *LockEnv) LocalEdits(ctx context.Context) ([]string, error) {
+	status, err := l.Status(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	var edited []string
+	for _, file := range status.Files {
+		if file.Status == "modified" || file.Status == "tracked, never locked" {
+			edited = append(edited, file.Path)
+		}
+	}
+	return edited, nil
+}
+
+// GetSnapshot returns the stash record of the snapshot taken with
+// StashSnapshot, or nil if there is none (no password required)
+func (l *LockEnv) GetSnapshot() (*Stash, error) {
+	record, _, err := l.readSnapshot()
+	if err != nil || record == nil {
+		return nil, err
+	}
+	return &record.Stash, nil
+}
+
+// readSnapshot reads the snapshot of this vault and where it is kept,
+// returning a nil record if there is none
+func (l *LockEnv) readSnapshot() (*snapshotRecord, string, error) {
+	if !l.exists() {
+		return nil, "", ErrNotInitialized
+	}
+	path, err := l.snapshotPath()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, path, nil
+	}
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var record snapshotRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, path, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return &record, path, nil
+}
+
+// PopSnapshot restores the files copied by StashSnapshot, resolving
+// conflicts with local files like unlock does, and removes the snapshot
+// (implements `lockenv stash pop --snapshot`). The snapshot is kept if any
+// file could not be restored.
+func (l *LockEnv) PopSnapshot(ctx context.Context, password []byte, strategy MergeStrategy) (*UnlockResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	record, path, err := l.readSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrNoStash
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	_, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	plain, err := enc.For(crypto.PurposeBlobs).DecryptSecure(record.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt snapshot: %w", err)
+	}
+	defer plain.Close()
+	var entries []snapshotEntry
+	if err := json.Unmarshal(plain.Borrow(), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	result := &UnlockResult{}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		file := storage.FileEntry{Path: entry.Path, Mode: entry.Mode, ModTime: entry.ModTime}
+		l.extractFile(file, entry.Content, strategy, result)
+		crypto.ClearBytes(entry.Content)
+	}
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("snapshot kept: %d files could not be restored", len(result.Errors))
+	}
+
+	if err := os.Remove(path); err != nil {
+		return result, fmt.Errorf("failed to remove snapshot: %w", err)
+	}
+	return result, nil
+}
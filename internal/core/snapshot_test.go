@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStashSnapshot_RoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	edited := filepath.Join(dir, "edited.env")
+	clean := filepath.Join(dir, "clean.env")
+	for _, path := range []string{edited, clean} {
+		if err := os.WriteFile(path, []byte("A=1"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{edited, clean}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, false, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	if _, err := lockenv.StashSnapshot(ctx, password); err != ErrNothingToStash {
+		t.Errorf("Expected ErrNothingToStash without edits, got %v", err)
+	}
+
+	if err := os.WriteFile(edited, []byte("A=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	stash, err := lockenv.StashSnapshot(ctx, password)
+	if err != nil {
+		t.Fatalf("StashSnapshot failed: %v", err)
+	}
+	if !slices.Equal(stash.Files, []string{"edited.env"}) {
+		t.Errorf("Expected only edited.env snapshotted, got %v", stash.Files)
+	}
+	// The snapshot leaves the working tree alone
+	if content, _ := os.ReadFile(edited); string(content) != "A=2" {
+		t.Errorf("edited.env changed by the snapshot: %q", content)
+	}
+	if _, err := lockenv.StashSnapshot(ctx, password); err != ErrStashExists {
+		t.Errorf("Expected ErrStashExists, got %v", err)
+	}
+
+	snapshot, err := lockenv.GetSnapshot()
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+	if snapshot == nil || !slices.Equal(snapshot.Files, []string{"edited.env"}) {
+		t.Errorf("Expected the snapshot listing edited.env, got %v", snapshot)
+	}
+
+	// A git operation overwrites the edit
+	if err := os.WriteFile(edited, []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to overwrite test file: %v", err)
+	}
+	result, err := lockenv.PopSnapshot(ctx, password, StrategyUseVault)
+	if err != nil {
+		t.Fatalf("PopSnapshot failed: %v", err)
+	}
+	if !slices.Equal(result.Extracted, []string{"edited.env"}) {
+		t.Errorf("Expected edited.env restored, got %v", result.Extracted)
+	}
+	if content, _ := os.ReadFile(edited); string(content) != "A=2" {
+		t.Errorf("Expected the snapshotted edit restored, got %q", content)
+	}
+
+	if snapshot, err := lockenv.GetSnapshot(); err != nil || snapshot != nil {
+		t.Errorf("Expected the snapshot removed, got %v, %v", snapshot, err)
+	}
+	if _, err := lockenv.PopSnapshot(ctx, password, StrategyUseVault); err != ErrNoStash {
+		t.Errorf("Expected ErrNoStash, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// SplitResult contains the results of a vault split
+type SplitResult struct {
+	Copied  []string // Files written to the new vault
+	Skipped []string // Matching files left out, e.g. in an encryption domain
+
+	// Events gives the reason each skipped file was left out, for the caller to show
+	Events EventLog
+}
+
+// Split creates a new vault at outputPath holding the files of this vault
+// that match patterns (implements `lockenv split`), e.g. to hand a subset of
+// secrets to a contractor or another repository. The new vault is encrypted
+// with newPassword, or with password when newPassword is nil, under its own
+// salt and data key. Files in an encryption domain are left out. This vault
+// is not changed.
+func (l *LockEnv) Split(ctx context.Context, password []byte, patterns []string, outputPath string, newPassword []byte) (*SplitResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no file patterns given")
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		return nil, fmt.Errorf("%s already exists", outputPath)
+	}
+	if newPassword == nil {
+		newPassword = password
+	}
+	if len(newPassword) == 0 {
+		return nil, ErrPasswordRequired
+	}
+
+	files, result, err := l.readMatchingFiles(ctx, password, patterns)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			crypto.ClearBytes(f.data)
+		}
+	}()
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files in the vault match %v", patterns)
+	}
+
+	out := &LockEnv{path: outputPath, backend: l.backend, kdfTime: l.kdfTime}
+	if err := out.Init(newPassword); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	if err := out.writeFiles(ctx, files, newPassword); err != nil {
+		os.Remove(outputPath)
+		return nil, err
+	}
+	for _, f := range files {
+		result.Copied = append(result.Copied, f.entry.Path)
+	}
+	return result, nil
+}
+
+// readMatchingFiles decrypts the files of this vault that match patterns.
+// Files that cannot be copied are reported and skipped.
+func (l *LockEnv) readMatchingFiles(ctx context.Context, password []byte, patterns []string) ([]mergedFile, *SplitResult, error) {
+	db, err := l.open()
+	if err != nil {
+		return nil, nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer enc.Destroy()
+
+	result := &SplitResult{Copied: []string{}, Skipped: []string{}}
+	var files []mergedFile
+	for _, entry := range metadata.Files {
+		if err := ctx.Err(); err != nil {
+			for _, f := range files {
+				crypto.ClearBytes(f.data)
+			}
+			return nil, nil, err
+		}
+		if !matchesAnyPattern(entry.Path, patterns) {
+			continue
+		}
+
+		if entry.Domain != "" {
+			result.skip(entry.Path, fmt.Errorf("in domain %s, not copied", entry.Domain))
+			continue
+		}
+		// References are copied as references, with an empty placeholder blob
+		if entry.Ref != nil {
+			files = append(files, mergedFile{entry: entry})
+			continue
+		}
+		encryptedData, err := db.GetFileData(ctx, entry.Path)
+		if err != nil {
+			result.skip(entry.Path, errors.New("not stored in vault"))
+			continue
+		}
+		data, err := enc.For(crypto.PurposeBlobs).Decrypt(encryptedData)
+		if err != nil {
+			result.skip(entry.Path, fmt.Errorf("cannot decrypt: %w", err))
+			continue
+		}
+		hash := sha256.Sum256(data)
+		if hex.EncodeToString(hash[:]) != entry.Hash {
+			crypto.ClearBytes(data)
+			result.skip(entry.Path, errors.New("failed integrity check"))
+			continue
+		}
+		files = append(files, mergedFile{entry: entry, data: data})
+	}
+	return files, result, nil
+}
+
+// writeFiles encrypts files into this freshly initialized vault
+func (l *LockEnv) writeFiles(ctx context.Context, files []mergedFile, password []byte) error {
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return err
+	}
+	defer enc.Destroy()
+
+	for _, f := range files {
+		encryptedData, err := enc.For(crypto.PurposeBlobs).Encrypt(f.data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", f.entry.Path, err)
+		}
+		err = db.StoreFileData(ctx, f.entry.Path, encryptedData)
+		crypto.ClearBytes(encryptedData)
+		if err != nil {
+			return fmt.Errorf("failed to store %s: %w", f.entry.Path, err)
+		}
+		if err := l.updateManifestEntry(db, f.entry.Path, f.entry.Size, f.entry.ModTime, f.entry.Hash); err != nil {
+			return fmt.Errorf("failed to update manifest for %s: %w", f.entry.Path, err)
+		}
+		metadata.AddFile(f.entry)
+	}
+	return l.saveMetadata(db, metadata, enc)
+}
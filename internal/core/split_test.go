@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{
+		"payments/stripe.env": "STRIPE=1",
+		"payments/paypal.env": "PAYPAL=2",
+		"db.env":              "DB=3",
+	})
+	output := filepath.Join(t.TempDir(), "payments.lockenv")
+
+	newPassword := []byte("contractor")
+	result, err := lockenv.Split(ctx, password, []string{"payments/*"}, output, newPassword)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(result.Copied) != 2 || len(result.Skipped) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	split := &LockEnv{path: output}
+	got := vaultContents(t, split, newPassword)
+	if len(got) != 2 || got["payments/stripe.env"] != "STRIPE=1" || got["payments/paypal.env"] != "PAYPAL=2" {
+		t.Errorf("Unexpected split contents: %v", got)
+	}
+	if err := split.VerifyPassword(password); err != ErrWrongPassword {
+		t.Errorf("Expected the old password to be rejected, got %v", err)
+	}
+	if verified, err := split.VerifyVault(ctx, newPassword); err != nil || !verified.OK(true) {
+		t.Errorf("VerifyVault = %+v, %v", verified, err)
+	}
+
+	// The source vault is unchanged and the output is never overwritten
+	if got := vaultContents(t, lockenv, password); len(got) != 3 {
+		t.Errorf("Expected the source vault to keep 3 files, got %v", got)
+	}
+	if _, err := lockenv.Split(ctx, password, []string{"db.env"}, output, nil); err == nil {
+		t.Error("Expected an existing output to be refused")
+	}
+	if _, err := lockenv.Split(ctx, password, []string{"nothing/*"}, filepath.Join(t.TempDir(), "x.lockenv"), nil); err == nil {
+		t.Error("Expected patterns matching nothing to fail")
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrStashExists    = errors.New("stash already exists")
+	ErrNoStash        = errors.New("no stash")
+	ErrNothingToStash = errors.New("no unlocked files to stash")
+)
+
+// Stash records plaintext files removed by lockenv stash
+type Stash struct {
+	Created time.Time `json:"created"`
+	Files   []string  `json:"files"`
+}
+
+// Stash locks every unlocked tracked file, removes the plaintext copies and
+// records them so StashPop can restore them (implements `lockenv stash`).
+// The report's Removed lists the stashed paths.
+func (l *LockEnv) Stash(ctx context.Context, password []byte) (*LockReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	stash, err := l.GetStash()
+	if err != nil {
+		return nil, err
+	}
+	if stash != nil {
+		return nil, ErrStashExists
+	}
+
+	present, err := l.UnlockedFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(present) == 0 {
+		return nil, ErrNothingToStash
+	}
+
+	// Encrypt current contents and remove plaintext
+	report, err := l.FinalizeLock(ctx, password, true, present)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return report, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := putRecord(db, recordStash, &Stash{Created: time.Now(), Files: report.Removed}); err != nil {
+		return report, fmt.Errorf("failed to record stash: %w", err)
+	}
+	return report, nil
+}
+
+// StashPop restores the files removed by Stash and clears the stash (implements `lockenv stash pop`).
+// The stash is kept if any file could not be restored.
+func (l *LockEnv) StashPop(ctx context.Context, password []byte, strategy MergeStrategy) (*UnlockResult, error) {
+	stash, err := l.GetStash()
+	if err != nil {
+		return nil, err
+	}
+	if stash == nil {
+		return nil, ErrNoStash
+	}
+
+	result, err := l.Unlock(ctx, password, strategy, stash.Files)
+	if err != nil {
+		return result, err
+	}
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("stash kept: %d files could not be restored", len(result.Errors))
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return result, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.DeleteConfig(recordStash); err != nil {
+		return result, fmt.Errorf("failed to clear stash: %w", err)
+	}
+	return result, nil
+}
+
+// GetStash returns the current stash, or nil if there is none (no password required)
+func (l *LockEnv) GetStash() (*Stash, error) {
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	stash := &Stash{}
+	if found, err := getRecord(db, recordStash, stash); !found {
+		return nil, err
+	}
+	return stash, nil
+}
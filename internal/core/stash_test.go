@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStash_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	unlocked := filepath.Join(dir, "unlocked.env")
+	sealed := filepath.Join(dir, "sealed.env")
+	if err := os.WriteFile(unlocked, []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(sealed, []byte("B=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{unlocked, sealed}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	// sealed.env is only in the vault and must not be restored by pop
+	if err := os.Remove(sealed); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	// Unlocked changes are locked into the vault before removal
+	if err := os.WriteFile(unlocked, []byte("A=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	stashed, err := lockenv.Stash(context.Background(), password)
+	if err != nil {
+		t.Fatalf("Stash failed: %v", err)
+	}
+	if len(stashed.Removed) != 1 || stashed.Removed[0] != "unlocked.env" {
+		t.Errorf("Expected unlocked.env to be stashed, got %v", stashed.Removed)
+	}
+	if _, err := os.Stat(unlocked); !os.IsNotExist(err) {
+		t.Error("unlocked.env should have been removed")
+	}
+
+	stash, err := lockenv.GetStash()
+	if err != nil {
+		t.Fatalf("GetStash failed: %v", err)
+	}
+	if stash == nil || len(stash.Files) != 1 {
+		t.Fatalf("Expected stash with 1 file, got %+v", stash)
+	}
+
+	if _, err := lockenv.StashPop(context.Background(), password, StrategyUseVault); err != nil {
+		t.Fatalf("StashPop failed: %v", err)
+	}
+
+	content, err := os.ReadFile(unlocked)
+	if err != nil {
+		t.Fatalf("unlocked.env should have been restored: %v", err)
+	}
+	if string(content) != "A=2" {
+		t.Errorf("Expected A=2, got %q", content)
+	}
+	if _, err := os.Stat(sealed); !os.IsNotExist(err) {
+		t.Error("sealed.env should not have been restored")
+	}
+
+	stash, err = lockenv.GetStash()
+	if err != nil {
+		t.Fatalf("GetStash failed: %v", err)
+	}
+	if stash != nil {
+		t.Errorf("Expected stash to be cleared, got %+v", stash)
+	}
+}
+
+func TestStash_RefusesSecondStash(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	testFile := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(testFile, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+
+	if _, err := lockenv.Stash(context.Background(), password); err != nil {
+		t.Fatalf("Stash failed: %v", err)
+	}
+
+	// Nothing is unlocked now, but an existing stash takes precedence
+	if err := os.WriteFile(testFile, []byte("SECRET=2"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.Stash(context.Background(), password); err != ErrStashExists {
+		t.Errorf("Expected ErrStashExists, got %v", err)
+	}
+}
+
+func TestStash_NothingToStash(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if _, err := lockenv.Stash(context.Background(), password); err != ErrNothingToStash {
+		t.Errorf("Expected ErrNothingToStash, got %v", err)
+	}
+	if _, err := lockenv.StashPop(context.Background(), password, StrategyUseVault); err != ErrNoStash {
+		t.Errorf("Expected ErrNoStash, got %v", err)
+	}
+}
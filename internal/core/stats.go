@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FileStats describes the space used by one file in the vault
+type FileStats struct {
+	Path          string
+	Size          int64     // Plaintext size
+	EncryptedSize int64     // Size of the stored blob, 0 if not yet encrypted
+	ModTime       time.Time // Modification time of the file when it was locked
+}
+
+// VaultStats contains a usage breakdown of the vault
+type VaultStats struct {
+	Files         []FileStats // Sorted by encrypted size, largest first
+	TotalSize     int64
+	EncryptedSize int64
+	FileSize      int64 // Size of the .lockenv file
+	PageSize      int
+	FreePages     int // Pages reclaimable by compact
+	Created       time.Time
+	LastLocked    time.Time
+}
+
+// FreeBytes returns the space compact can reclaim
+func (s *VaultStats) FreeBytes() int64 {
+	return int64(s.FreePages) * int64(s.PageSize)
+}
+
+// Stats returns a usage breakdown of the vault (no password required)
+func (l *LockEnv) Stats(ctx context.Context) (*VaultStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	// Open database
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	space, err := db.SpaceStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database stats: %w", err)
+	}
+
+	entries, err := l.getManifestEntries(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	stats := &VaultStats{
+		Files:     make([]FileStats, 0, len(entries)),
+		FileSize:  space.FileSize,
+		PageSize:  space.PageSize,
+		FreePages: space.FreePages,
+	}
+
+	// Not critical
+	stats.Created, _ = db.GetCreated()
+	stats.LastLocked, _ = db.GetModified()
+
+	for _, entry := range entries {
+		// Validate path to filter out tampered entries
+		validPath, err := l.validator.ValidateExistingPath(entry.Path)
+		if err != nil {
+			continue
+		}
+
+		fs := FileStats{
+			Path:          validPath,
+			Size:          entry.Size,
+			EncryptedSize: space.BlobSizes[entry.Path],
+			ModTime:       entry.ModTime,
+		}
+		stats.TotalSize += fs.Size
+		stats.EncryptedSize += fs.EncryptedSize
+		stats.Files = append(stats.Files, fs)
+	}
+
+	sort.SliceStable(stats.Files, func(i, j int) bool {
+		return stats.Files[i].EncryptedSize > stats.Files[j].EncryptedSize
+	})
+
+	return stats, nil
+}
+
+// FormatSize formats bytes into human-readable format
+func FormatSize(bytes int64) string {
+	const (
+		KB = 1024
+		MB = KB * 1024
+		GB = MB * 1024
+	)
+
+	switch {
+	case bytes >= GB:
+		return fmt.Sprintf("%.2f GB", float64(bytes)/GB)
+	case bytes >= MB:
+		return fmt.Sprintf("%.2f MB", float64(bytes)/MB)
+	case bytes >= KB:
+		return fmt.Sprintf("%.2f KB", float64(bytes)/KB)
+	default:
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStats_ReportsEncryptedSizes(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	small := filepath.Join(dir, "small.env")
+	large := filepath.Join(dir, "large.key")
+	if err := os.WriteFile(small, []byte("A=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(large, make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{small, large}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, []string{"large.key"}); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	stats, err := lockenv.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+
+	if len(stats.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(stats.Files))
+	}
+	if stats.Files[0].Path != "large.key" {
+		t.Errorf("Expected largest file first, got %s", stats.Files[0].Path)
+	}
+	if stats.Files[0].EncryptedSize <= stats.Files[0].Size {
+		t.Errorf("Encrypted size %d should exceed plaintext size %d", stats.Files[0].EncryptedSize, stats.Files[0].Size)
+	}
+	if stats.Files[1].EncryptedSize != 0 {
+		t.Errorf("small.env was never encrypted, got encrypted size %d", stats.Files[1].EncryptedSize)
+	}
+	if stats.TotalSize != 4096+3 {
+		t.Errorf("Expected total size %d, got %d", 4096+3, stats.TotalSize)
+	}
+	if stats.FileSize <= 0 || stats.PageSize <= 0 {
+		t.Errorf("Expected database sizes, got file %d page %d", stats.FileSize, stats.PageSize)
+	}
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+var ErrFileNotInVault = errors.New("file not in vault")
+
+// vaultFileReader reads decrypted vault contents held in a SecureBuffer
+type vaultFileReader struct {
+	*bytes.Reader
+	buf *crypto.SecureBuffer
+}
+
+// Close wipes the decrypted contents
+func (r *vaultFileReader) Close() error {
+	r.Reader.Reset(nil)
+	r.buf.Close()
+	return nil
+}
+
+// OpenVaultFile returns a reader over the decrypted contents of a file in the
+// vault, without writing it to the working tree. Contents are decrypted as
+// they are read, one segment at a time, and hashed on the way: the read
+// that reaches the end fails if they differ from the stored hash. The vault
+// stays open until the reader is closed, so close it before running another
+// operation. References and files in encryption domains are decrypted
+// whole into locked memory instead; closing the reader wipes them.
+func (l *LockEnv) OpenVaultFile(ctx context.Context, password []byte, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	r, err := l.openVaultFile(ctx, db, password, path)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openVaultFile implements OpenVaultFile on the vault open in db. A
+// streaming reader takes over db and closes it; otherwise db is closed
+// before returning.
+func (l *LockEnv) openVaultFile(ctx context.Context, db storage.Backend, password []byte, path string) (io.ReadCloser, error) {
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	file := metadata.FindFile(filepath.ToSlash(path))
+	if file == nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrFileNotInVault)
+	}
+	if file.Ref == nil {
+		fileEnc, err := l.fileEncryptor(file, enc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Path, err)
+		}
+		if fileEnc.Segmented() {
+			if sealed, err := db.HasFileData(file.Path); err == nil && !sealed {
+				return nil, fmt.Errorf("%s: tracked, never locked", file.Path)
+			}
+			return newBlobReader(ctx, db, file, fileEnc)
+		}
+	}
+
+	plain, err := l.decryptEntry(ctx, db, file, enc)
+	if err != nil {
+		return nil, err
+	}
+	db.Close()
+	return &vaultFileReader{Reader: bytes.NewReader(plain.Borrow()), buf: plain}, nil
+}
+
+// blobReader decrypts the stored data of a file as it is read. The storage
+// backend feeds the encrypted pieces through a pipe from a goroutine, and
+// the decrypting reader opens one segment at a time, so neither the
+// ciphertext nor the plaintext is ever held whole.
+type blobReader struct {
+	path  string
+	hash  string
+	sum   hash.Hash
+	plain io.ReadCloser
+	pipe  *io.PipeReader
+	done  chan struct{} // Closed once the backend stopped feeding the pipe
+	db    storage.Backend
+}
+
+// newBlobReader starts reading the stored data of file from db, decrypted
+// with enc. Closing the reader closes db.
+func newBlobReader(ctx context.Context, db storage.Backend, file *storage.FileEntry, enc *crypto.Encryptor) (*blobReader, error) {
+	pr, pw := io.Pipe()
+	r := &blobReader{path: file.Path, hash: file.Hash, sum: sha256.New(), pipe: pr, done: make(chan struct{}), db: db}
+	go func() {
+		defer close(r.done)
+		err := db.ReadFileData(ctx, file.Path, func(piece []byte) error {
+			_, err := pw.Write(piece)
+			return err
+		})
+		if err != nil {
+			err = fmt.Errorf("cannot read from storage: %w", err)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	plain, err := enc.NewDecryptReader(ctx, pr)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s: cannot decrypt: %w", file.Path, err)
+	}
+	r.plain = plain
+	return r, nil
+}
+
+// Read implements io.Reader, checking the stored hash at the end
+func (r *blobReader) Read(p []byte) (int, error) {
+	n, err := r.plain.Read(p)
+	r.sum.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.sum.Sum(nil)) != r.hash {
+		return n, fmt.Errorf("%s: failed integrity check", r.path)
+	}
+	if err != nil && err != io.EOF {
+		return n, fmt.Errorf("%s: %w", r.path, err)
+	}
+	return n, err
+}
+
+// Close wipes the buffered contents, stops the backend and closes the vault
+func (r *blobReader) Close() error {
+	if r.plain != nil {
+		r.plain.Close()
+	}
+	r.pipe.Close()
+	<-r.done
+	return r.db.Close()
+}
+
+// decryptEntry decrypts a file from the vault open in db into a SecureBuffer
+// and verifies it against the stored hash. References are read from their
+// vault and verified there. The caller must Close the returned buffer.
+func (l *LockEnv) decryptEntry(ctx context.Context, db storage.Backend, file *storage.FileEntry, enc *crypto.Encryptor) (*crypto.SecureBuffer, error) {
+	if file.Ref != nil {
+		return l.resolveReference(ctx, file)
+	}
+	fileEnc, err := l.fileEncryptor(file, enc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Path, err)
+	}
+	if sealed, err := db.HasFileData(file.Path); err == nil && !sealed {
+		return nil, fmt.Errorf("%s: tracked, never locked", file.Path)
+	}
+
+	encryptedData, err := db.GetFileData(ctx, file.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot read from storage: %w", file.Path, err)
+	}
+	plain, err := fileEnc.DecryptSecureContext(ctx, encryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot decrypt: %w", file.Path, err)
+	}
+	hash := sha256.Sum256(plain.Borrow())
+	if hex.EncodeToString(hash[:]) != file.Hash {
+		plain.Close()
+		return nil, fmt.Errorf("%s: failed integrity check", file.Path)
+	}
+	return plain, nil
+}
+
+// StoreVaultFile encrypts everything read from r and stores it in the vault
+// as path, without reading or touching the working tree. Files not yet in the
+// vault are added; existing files keep their mode and encryption domain.
+// The input is encrypted and stored as it is read, one segment at a time;
+// SetDeterministic, which derives the nonce from the whole contents, and
+// files in encryption domains buffer it in locked memory instead, wiped
+// before returning. Input over the SetMaxFileSize limit is refused with
+// ErrFileTooLarge.
+func (l *LockEnv) StoreVaultFile(ctx context.Context, password []byte, path string, r io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !l.exists() {
+		return ErrNotInitialized
+	}
+
+	validPath, err := l.validator.ValidateAndNormalize(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %s: %w", path, err)
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return err
+	}
+	defer enc.Destroy()
+
+	entry := storage.FileEntry{Path: validPath, Mode: FilePermSecure}
+	if existing := metadata.FindFile(validPath); existing != nil {
+		entry = *existing
+	}
+	fileEnc, err := l.fileEncryptor(&entry, enc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", validPath, err)
+	}
+
+	input := &plainInput{r: r, sum: sha256.New(), max: l.maxSize}
+	if fileEnc.Segmented() && !l.siv {
+		err = writeStream(ctx, db, validPath, fileEnc, input)
+	} else {
+		err = l.writeBuffered(ctx, db, validPath, fileEnc, input)
+	}
+	if errors.Is(err, ErrFileTooLarge) {
+		return fmt.Errorf("%s: %w: input exceeds the %s limit (use --allow-large or raise max_file_size in %s)",
+			validPath, ErrFileTooLarge, FormatSize(l.maxSize), ConfigFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	entry.Hash = hex.EncodeToString(input.sum.Sum(nil))
+	entry.Size = input.n
+	entry.ModTime = time.Now()
+	entry.Format = DetectFormat(validPath)
+	entry.RecordLock(entry.Hash, entry.ModTime)
+
+	if err := l.updateManifestEntry(db, validPath, entry.Size, entry.ModTime, entry.Hash); err != nil {
+		return fmt.Errorf("failed to update manifest for %s: %w", validPath, err)
+	}
+	metadata.AddFile(entry)
+	metadata.Modified = time.Now()
+	return l.saveMetadata(db, metadata, enc)
+}
+
+// plainInput reads the plaintext passed to StoreVaultFile, hashing and
+// counting it on the way and failing with ErrFileTooLarge past max bytes
+type plainInput struct {
+	r   io.Reader
+	sum hash.Hash
+	n   int64
+	max int64 // 0 for no limit
+}
+
+// Read implements io.Reader
+func (p *plainInput) Read(b []byte) (int, error) {
+	if p.max > 0 && p.n > p.max {
+		return 0, ErrFileTooLarge
+	}
+	n, err := p.r.Read(b)
+	p.sum.Write(b[:n])
+	p.n += int64(n)
+	if p.max > 0 && p.n > p.max {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// writeStream encrypts input as it is read and stores it as path
+func writeStream(ctx context.Context, db storage.BlobStore, path string, enc *crypto.Encryptor, input io.Reader) error {
+	sealed, err := enc.NewEncryptReader(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	defer sealed.Close()
+	if err := db.WriteFileData(ctx, path, sealed); err != nil {
+		return fmt.Errorf("failed to store %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeBuffered reads input whole into locked memory, encrypts it and
+// stores it as path
+func (l *LockEnv) writeBuffered(ctx context.Context, db storage.Backend, path string, enc *crypto.Encryptor, input io.Reader) error {
+	plain, err := readAllSecure(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer plain.Close()
+
+	encryptedData, err := l.encryptFile(ctx, enc, plain.Borrow())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	if err := db.StoreFileData(ctx, path, encryptedData); err != nil {
+		return fmt.Errorf("failed to store %s: %w", path, err)
+	}
+	return nil
+}
+
+// readAllSecure reads r to EOF into a SecureBuffer, growing it as needed and
+// wiping every intermediate copy. The caller must Close the returned buffer.
+func readAllSecure(ctx context.Context, r io.Reader) (*crypto.SecureBuffer, error) {
+	buf := crypto.NewSecureBuffer(4096)
+	n := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			buf.Close()
+			return nil, err
+		}
+		if n == buf.Len() {
+			grown := crypto.NewSecureBuffer(2 * buf.Len())
+			copy(grown.Borrow(), buf.Borrow())
+			buf.Close()
+			buf = grown
+		}
+		m, err := r.Read(buf.Borrow()[n:])
+		n += m
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			buf.Close()
+			return nil, err
+		}
+	}
+
+	out := crypto.SecureCopy(buf.Borrow()[:n])
+	buf.Close()
+	return out, nil
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOpenVaultFile(t *testing.T) {
+	ctx := context.Background()
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{".env": "A=1\n"})
+
+	r, err := lockenv.OpenVaultFile(ctx, pw, ".env")
+	if err != nil {
+		t.Fatalf("OpenVaultFile failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != "A=1\n" {
+		t.Errorf("got %q, want %q", data, "A=1\n")
+	}
+
+	if _, err := lockenv.OpenVaultFile(ctx, pw, "missing.env"); !errors.Is(err, ErrFileNotInVault) {
+		t.Errorf("expected ErrFileNotInVault, got %v", err)
+	}
+	if _, err := lockenv.OpenVaultFile(ctx, []byte("wrong"), ".env"); err != ErrWrongPassword {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+}
+
+func TestStoreVaultFile(t *testing.T) {
+	ctx := context.Background()
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{".env": "A=1\n"})
+
+	// Larger than the initial read buffer, to exercise growing it
+	large := strings.Repeat("KEY=value\n", 1000)
+	if err := lockenv.StoreVaultFile(ctx, pw, ".env", strings.NewReader("A=2\n")); err != nil {
+		t.Fatalf("StoreVaultFile (update) failed: %v", err)
+	}
+	if err := lockenv.StoreVaultFile(ctx, pw, "config/large.env", strings.NewReader(large)); err != nil {
+		t.Fatalf("StoreVaultFile (add) failed: %v", err)
+	}
+
+	contents := vaultContents(t, lockenv, pw)
+	if contents[".env"] != "A=2\n" {
+		t.Errorf(".env = %q, want %q", contents[".env"], "A=2\n")
+	}
+	if contents["config/large.env"] != large {
+		t.Errorf("config/large.env has %d bytes, want %d", len(contents["config/large.env"]), len(large))
+	}
+
+	// Stored files pass the integrity check on unlock
+	result, err := lockenv.Unlock(ctx, pw, StrategyUseVault, nil)
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("unexpected unlock errors: %v", result.Errors)
+	}
+	if len(result.Extracted) != 2 {
+		t.Errorf("expected 2 extracted files, got %v", result.Extracted)
+	}
+}
+
+func TestStoreVaultFile_MaxFileSize(t *testing.T) {
+	ctx := context.Background()
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{".env": "A=1\n"})
+
+	lockenv.SetMaxFileSize(8)
+	err := lockenv.StoreVaultFile(ctx, pw, "token", strings.NewReader("123456789"))
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if err := lockenv.StoreVaultFile(ctx, pw, "token", strings.NewReader("12345678")); err != nil {
+		t.Fatalf("StoreVaultFile at the limit failed: %v", err)
+	}
+	if contents := vaultContents(t, lockenv, pw); contents["token"] != "12345678" {
+		t.Errorf("token = %q", contents["token"])
+	}
+}
+
+// patternReader yields size bytes of a repeating pattern without holding them
+type patternReader struct {
+	size, off int64
+}
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.off >= p.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(b)), p.size-p.off))
+	for i := range n {
+		b[i] = byte((p.off + int64(i)) % 251)
+	}
+	p.off += int64(n)
+	return n, nil
+}
+
+func TestVaultFile_Streams(t *testing.T) {
+	ctx := context.Background()
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{".env": "A=1\n"})
+
+	const size = 16 << 20
+	if err := lockenv.StoreVaultFile(ctx, pw, "model.bin", &patternReader{size: size}); err != nil {
+		t.Fatalf("StoreVaultFile failed: %v", err)
+	}
+	want := sha256.New()
+	io.Copy(want, &patternReader{size: size})
+
+	r, err := lockenv.OpenVaultFile(ctx, pw, "model.bin")
+	if err != nil {
+		t.Fatalf("OpenVaultFile failed: %v", err)
+	}
+	defer r.Close()
+
+	// Reading allocates per segment, not per file
+	got := sha256.New()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	n, err := io.Copy(got, r)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if n != size || string(got.Sum(nil)) != string(want.Sum(nil)) {
+		t.Errorf("Read %d bytes with a different hash, want %d", n, size)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/8 {
+		t.Errorf("Reading %d bytes allocated %d bytes", size, allocated)
+	}
+}
+
+func TestStoreVaultFile_MaxFileSizeStreamed(t *testing.T) {
+	ctx := context.Background()
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{".env": "A=1\n"})
+
+	// The limit stops the input while it is being stored
+	lockenv.SetMaxFileSize(1 << 20)
+	input := &patternReader{size: 8 << 20}
+	if err := lockenv.StoreVaultFile(ctx, pw, "model.bin", input); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if input.off >= input.size {
+		t.Error("Expected the input not to be read to the end")
+	}
+	if _, ok := vaultContents(t, lockenv, pw)["model.bin"]; ok {
+		t.Error("Expected nothing stored")
+	}
+}
@@ -0,0 +1,360 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Structured file formats understood by the semantic diff
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// maskedValue replaces secret values in structured diff output
+const maskedValue = "****"
+
+// errUnsupportedSyntax is returned when a file uses syntax the flattening
+// parsers do not handle; callers fall back to a line diff
+var errUnsupportedSyntax = errors.New("unsupported syntax")
+
+// DetectStructuredFormat returns the structured format of a file based on its
+// extension, or an empty string if the file is not structured
+func DetectStructuredFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return ""
+	}
+}
+
+// FlattenStructured parses a structured file into a map of dotted key paths
+// to scalar values (e.g. "database.host" or "servers[0]")
+func FlattenStructured(format string, data []byte) (map[string]string, error) {
+	switch format {
+	case FormatJSON:
+		return flattenJSON(data)
+	case FormatYAML:
+		return flattenYAML(data)
+	case FormatTOML:
+		return flattenTOML(data)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// GenerateStructuredDiff produces a key-level diff of two versions of a
+// structured file. Values are masked unless showValues is set.
+// Returns an error if either version cannot be parsed.
+func GenerateStructuredDiff(path, format string, oldData, newData []byte, showValues bool) (string, error) {
+	oldKeys, err := FlattenStructured(format, oldData)
+	if err != nil {
+		return "", err
+	}
+	newKeys, err := FlattenStructured(format, newData)
+	if err != nil {
+		return "", err
+	}
+
+	keys := make(map[string]struct{}, len(oldKeys)+len(newKeys))
+	for k := range oldKeys {
+		keys[k] = struct{}{}
+	}
+	for k := range newKeys {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	show := func(v string) string {
+		if showValues {
+			return v
+		}
+		return maskedValue
+	}
+
+	var lines []string
+	for _, k := range sorted {
+		oldVal, inOld := oldKeys[k]
+		newVal, inNew := newKeys[k]
+		switch {
+		case !inOld:
+			lines = append(lines, fmt.Sprintf("+ %s = %s", k, show(newVal)))
+		case !inNew:
+			lines = append(lines, fmt.Sprintf("- %s = %s", k, show(oldVal)))
+		case oldVal != newVal:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", k, show(oldVal), show(newVal)))
+		}
+	}
+
+	// Formatting-only change (whitespace, comments, key order)
+	if len(lines) == 0 {
+		return fmt.Sprintf("--- a/%s\n+++ b/%s\n(%s: formatting changed, no key changes)\n", path, path, format), nil
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("--- a/%s\n", path))
+	result.WriteString(fmt.Sprintf("+++ b/%s\n", path))
+	result.WriteString(fmt.Sprintf("(%s: %d key(s) changed)\n", format, len(lines)))
+	for _, line := range lines {
+		result.WriteString(line + "\n")
+	}
+	return result.String(), nil
+}
+
+// flattenJSON flattens a JSON document into dotted key paths
+func flattenJSON(data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	flattenValue("", doc, result)
+	return result, nil
+}
+
+// flattenValue walks a decoded JSON value, recording each scalar under its key path
+func flattenValue(prefix string, value any, result map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			flattenValue(joinKey(prefix, k), child, result)
+		}
+	case []any:
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", prefix, i), child, result)
+		}
+	case nil:
+		result[prefix] = "null"
+	case string:
+		result[prefix] = v
+	default:
+		result[prefix] = fmt.Sprint(v)
+	}
+}
+
+// yamlFrame tracks an open mapping or sequence while flattening YAML
+type yamlFrame struct {
+	indent int
+	prefix string
+	items  int
+}
+
+// flattenYAML flattens a YAML document made of block mappings and scalar
+// sequences. Anchors, multi-line scalars and mappings inside sequences are
+// rejected with errUnsupportedSyntax.
+func flattenYAML(data []byte) (map[string]string, error) {
+	result := make(map[string]string)
+	stack := []yamlFrame{{indent: -1}}
+
+	for i, rawLine := range strings.Split(string(data), "\n") {
+		line := strings.TrimRight(stripComment(rawLine), " \r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		indent := len(line) - len(trimmed)
+
+		// Sequence item: belongs to the closest key at the same or lower indent
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			for len(stack) > 1 && stack[len(stack)-1].indent > indent {
+				stack = stack[:len(stack)-1]
+			}
+			item := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if _, _, isMap := splitYAMLKey(item); isMap || item == "" {
+				return nil, fmt.Errorf("line %d: %w: mapping in sequence", i+1, errUnsupportedSyntax)
+			}
+			value, err := yamlScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			top := &stack[len(stack)-1]
+			result[fmt.Sprintf("%s[%d]", top.prefix, top.items)] = value
+			top.items++
+			continue
+		}
+
+		for len(stack) > 1 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		key, rest, ok := splitYAMLKey(trimmed)
+		if !ok {
+			return nil, fmt.Errorf("line %d: %w: expected key: value", i+1, errUnsupportedSyntax)
+		}
+		fullKey := joinKey(stack[len(stack)-1].prefix, key)
+
+		if rest == "" {
+			// Nested mapping or sequence follows
+			stack = append(stack, yamlFrame{indent: indent, prefix: fullKey})
+			continue
+		}
+
+		value, err := yamlScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		result[fullKey] = value
+	}
+
+	return result, nil
+}
+
+// splitYAMLKey splits "key: value" into its key and value parts
+func splitYAMLKey(s string) (key, value string, ok bool) {
+	if strings.HasSuffix(s, ":") {
+		key, err := unquote(strings.TrimSuffix(s, ":"))
+		return key, "", err == nil
+	}
+	idx := strings.Index(s, ": ")
+	if idx <= 0 {
+		return "", "", false
+	}
+	key, err := unquote(strings.TrimSpace(s[:idx]))
+	return key, strings.TrimSpace(s[idx+2:]), err == nil
+}
+
+// yamlScalar returns the value of a single-line YAML scalar
+func yamlScalar(s string) (string, error) {
+	switch s[0] {
+	case '|', '>':
+		return "", fmt.Errorf("%w: block scalar", errUnsupportedSyntax)
+	case '&', '*':
+		return "", fmt.Errorf("%w: anchor or alias", errUnsupportedSyntax)
+	}
+	return unquote(s)
+}
+
+// flattenTOML flattens a TOML document made of tables, arrays of tables and
+// single-line key/value pairs. Multi-line strings are rejected with
+// errUnsupportedSyntax.
+func flattenTOML(data []byte) (map[string]string, error) {
+	result := make(map[string]string)
+	tableCounts := make(map[string]int)
+	prefix := ""
+
+	for i, rawLine := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(stripComment(rawLine))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]") {
+			name := strings.TrimSpace(line[2 : len(line)-2])
+			prefix = fmt.Sprintf("%s[%d]", name, tableCounts[name])
+			tableCounts[name]++
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			prefix = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("line %d: %w: expected key = value", i+1, errUnsupportedSyntax)
+		}
+		key, err := unquote(strings.TrimSpace(line[:idx]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		value := strings.TrimSpace(line[idx+1:])
+		if strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''") {
+			return nil, fmt.Errorf("line %d: %w: multi-line string", i+1, errUnsupportedSyntax)
+		}
+		// Arrays are kept as written for parseStringArray
+		if !strings.HasPrefix(value, "[") {
+			if value, err = unquote(value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+		}
+		result[joinKey(prefix, key)] = value
+	}
+
+	return result, nil
+}
+
+// stripComment removes a trailing # comment that is not inside quotes
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"' || c == '\'':
+			end := quotedEnd(line[i:])
+			if end < 0 {
+				return line
+			}
+			i += end - 1
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// quotedEnd returns the length of the quoted string at the start of s,
+// closing quote included, or -1 if it is not closed. Backslash escapes are
+// skipped in double-quoted strings only.
+func quotedEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// unquote returns the value of a quoted string: a double-quoted (basic)
+// string with its backslash escapes decoded and a single-quoted (literal)
+// string verbatim. Anything else is returned as is.
+func unquote(s string) (string, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return s, nil
+	}
+	if quotedEnd(s) != len(s) {
+		return "", fmt.Errorf("malformed string %s", s)
+	}
+	if s[0] == '\'' {
+		return s[1 : len(s)-1], nil
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid escape in string %s", s)
+	}
+	return value, nil
+}
+
+// joinKey joins a parent key path and a child key with a dot
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
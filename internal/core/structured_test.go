@@ -0,0 +1,170 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectStructuredFormat(t *testing.T) {
+	tests := map[string]string{
+		"config.json":      FormatJSON,
+		"deploy/app.YAML":  FormatYAML,
+		"values.yml":       FormatYAML,
+		"Cargo.toml":       FormatTOML,
+		".env":             "",
+		"secrets/cert.pem": "",
+	}
+
+	for path, want := range tests {
+		if got := DetectStructuredFormat(path); got != want {
+			t.Errorf("DetectStructuredFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestFlattenStructured(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   map[string]string
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			data:   `{"db": {"host": "localhost", "port": 5432}, "tags": ["a", "b"], "debug": null}`,
+			want: map[string]string{
+				"db.host": "localhost",
+				"db.port": "5432",
+				"tags[0]": "a",
+				"tags[1]": "b",
+				"debug":   "null",
+			},
+		},
+		{
+			name:   "yaml",
+			format: FormatYAML,
+			data: `# comment
+db:
+  host: "localhost" # inline comment
+  port: 5432
+tags:
+  - a
+  - 'b'
+debug: false
+`,
+			want: map[string]string{
+				"db.host": "localhost",
+				"db.port": "5432",
+				"tags[0]": "a",
+				"tags[1]": "b",
+				"debug":   "false",
+			},
+		},
+		{
+			name:   "toml",
+			format: FormatTOML,
+			data: `title = "app" # comment
+
+[db]
+host = "localhost"
+port = 5432
+
+[[servers]]
+name = "alpha"
+
+[[servers]]
+name = "beta"
+`,
+			want: map[string]string{
+				"title":           "app",
+				"db.host":         "localhost",
+				"db.port":         "5432",
+				"servers[0].name": "alpha",
+				"servers[1].name": "beta",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FlattenStructured(tt.format, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("FlattenStructured failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Got %d keys, want %d: %v", len(got), len(tt.want), got)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("Key %q = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestFlattenYAML_Unsupported(t *testing.T) {
+	data := []byte("cert: |\n  -----BEGIN CERTIFICATE-----\n")
+	if _, err := FlattenStructured(FormatYAML, data); err == nil {
+		t.Error("Expected error for block scalar")
+	}
+}
+
+func TestGenerateStructuredDiff_MasksValues(t *testing.T) {
+	oldData := []byte(`{"db": {"password": "old-secret"}, "debug": true}`)
+	newData := []byte(`{"db": {"password": "new-secret", "replica": "r1"}}`)
+
+	diff, err := GenerateStructuredDiff("app.json", FormatJSON, oldData, newData, false)
+	if err != nil {
+		t.Fatalf("GenerateStructuredDiff failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"3 key(s) changed",
+		"~ db.password: **** -> ****",
+		"+ db.replica = ****",
+		"- debug = ****",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Diff missing %q, got:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "secret") || strings.Contains(diff, "r1") {
+		t.Errorf("Diff should not reveal values, got:\n%s", diff)
+	}
+}
+
+func TestGenerateStructuredDiff_ShowValues(t *testing.T) {
+	oldData := []byte("db:\n  password: old-secret\n")
+	newData := []byte("db:\n  password: new-secret\n")
+
+	diff, err := GenerateStructuredDiff("app.yaml", FormatYAML, oldData, newData, true)
+	if err != nil {
+		t.Fatalf("GenerateStructuredDiff failed: %v", err)
+	}
+
+	if !strings.Contains(diff, "~ db.password: old-secret -> new-secret") {
+		t.Errorf("Diff should reveal values, got:\n%s", diff)
+	}
+}
+
+func TestGenerateUnifiedDiff_StructuredFallback(t *testing.T) {
+	// Key order change only: structured diff reports no key changes
+	diff, err := GenerateUnifiedDiff("app.json", []byte(`{"a": 1, "b": 2}`), []byte(`{"b": 2, "a": 1}`), DiffOptions{})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	if !strings.Contains(diff, "no key changes") {
+		t.Errorf("Expected formatting-only note, got:\n%s", diff)
+	}
+
+	// Invalid JSON falls back to a line diff
+	diff, err = GenerateUnifiedDiff("app.json", []byte("{broken\n"), []byte("{still broken\n"), DiffOptions{})
+	if err != nil {
+		t.Fatalf("GenerateUnifiedDiff failed: %v", err)
+	}
+	if !strings.Contains(diff, "@@") {
+		t.Errorf("Expected line diff fallback, got:\n%s", diff)
+	}
+}
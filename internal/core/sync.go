@@ -0,0 +1,249 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/illarion/lockenv/internal/remote"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+var (
+	ErrLocalChanges    = errors.New("local vault has changes that were not pushed")
+	ErrVaultIDMismatch = errors.New("remote vault is a different vault")
+	ErrStaleRemote     = errors.New("remote vault is older than the last synced version")
+)
+
+// SyncState records the remote version the vault was last synced with
+type SyncState struct {
+	Remote     string    `json:"remote"`               // Remote URL without credentials
+	ETag       string    `json:"etag"`                 // Remote version after the last push/pull
+	Modified   time.Time `json:"modified"`             // Vault modification time at the last push/pull
+	Generation uint64    `json:"generation,omitempty"` // Vault generation at the last push/pull
+}
+
+// getSyncState returns the sync state, or nil if the vault was never synced
+func getSyncState(db storage.ConfigStore) (*SyncState, error) {
+	state := &SyncState{}
+	if found, err := getRecord(db, recordSync, state); !found {
+		return nil, err
+	}
+	return state, nil
+}
+
+// inSync reports whether the vault is unchanged since the sync with rem
+// recorded in state. The generation catches changes made within the
+// resolution of the modification time.
+func inSync(state *SyncState, rem remote.Remote, generation uint64, modified time.Time) bool {
+	return state != nil && state.Remote == rem.String() &&
+		state.Generation == generation && state.Modified.Equal(modified)
+}
+
+// RemoteURL returns the remote the vault was last synced with, or "" if none
+func (l *LockEnv) RemoteURL() string {
+	if !l.exists() {
+		return ""
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return ""
+	}
+	defer db.Close()
+
+	state, err := getSyncState(db)
+	if err != nil || state == nil {
+		return ""
+	}
+	return state.Remote
+}
+
+// Push uploads the vault to rem (implements `lockenv push`). Returns false if
+// the remote already has the current version. Fails with remote.ErrConflict if
+// the remote changed since the last sync, unless force is set.
+func (l *LockEnv) Push(ctx context.Context, rem remote.Remote, force bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return false, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return false, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	// Pull compares vault IDs, so make sure the pushed vault has one
+	if _, err := db.GetOrCreateVaultID(); err != nil {
+		return false, fmt.Errorf("failed to get vault ID: %w", err)
+	}
+
+	modified, err := db.GetModified()
+	if err != nil {
+		return false, fmt.Errorf("failed to read modification time: %w", err)
+	}
+	generation, err := db.GetGeneration()
+	if err != nil {
+		return false, fmt.Errorf("failed to read generation: %w", err)
+	}
+	state, err := getSyncState(db)
+	if err != nil {
+		return false, err
+	}
+
+	var etag string
+	if state != nil && state.Remote == rem.String() {
+		if inSync(state, rem, generation, modified) && !force {
+			return false, nil
+		}
+		etag = state.ETag
+	}
+
+	var snapshot bytes.Buffer
+	if _, err := db.WriteTo(&snapshot); err != nil {
+		return false, fmt.Errorf("failed to snapshot vault: %w", err)
+	}
+
+	newETag, err := rem.Put(ctx, snapshot.Bytes(), etag, force)
+	if err != nil {
+		return false, err
+	}
+
+	if err := putRecord(db, recordSync, &SyncState{Remote: rem.String(), ETag: newETag, Modified: modified, Generation: generation}); err != nil {
+		return true, fmt.Errorf("failed to record sync state: %w", err)
+	}
+	return true, nil
+}
+
+// Pull replaces the vault with the one from rem (implements `lockenv pull`).
+// Returns false if the local vault already has the remote version. Unless
+// force is set, fails with ErrLocalChanges if the local vault changed since the
+// last sync, with ErrVaultIDMismatch if the remote is a different vault and
+// with ErrStaleRemote if the remote has a lower generation than the version
+// last synced, as happens when an old copy of the vault is pushed over it.
+// The local vault is backed up before it is replaced.
+func (l *LockEnv) Pull(ctx context.Context, rem remote.Remote, force bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	data, etag, err := rem.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Validate the download before touching the local vault
+	tmpPath := l.path + ".pull"
+	if err := os.WriteFile(tmpPath, data, FilePermSecure); err != nil {
+		return false, fmt.Errorf("failed to write downloaded vault: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	pulled, err := l.openAt(tmpPath)
+	if err != nil {
+		return false, fmt.Errorf("downloaded vault is invalid: %w", err)
+	}
+	initialized, err := pulled.IsInitialized()
+	remoteID, _ := pulled.GetVaultID()
+	remoteModified, _ := pulled.GetModified()
+	remoteGeneration, _ := pulled.GetGeneration()
+	pulled.Close()
+	if err != nil || !initialized {
+		return false, fmt.Errorf("downloaded vault is invalid")
+	}
+
+	// Snapshots are single files; a vault kept in the dir layout stays in it
+	layout := storage.LayoutFile
+	if l.exists() {
+		db, err := l.open()
+		if err != nil {
+			return false, fmt.Errorf("failed to open database: %w", err)
+		}
+		layout = storage.LayoutOf(db)
+
+		upToDate, err := l.checkPull(db, rem, etag, remoteID, remoteGeneration, force)
+		if err == nil && !upToDate {
+			var backup *BackupResult
+			if backup, err = l.backup(db, DefaultBackupDir, DefaultBackupKeep); err != nil {
+				err = fmt.Errorf("failed to back up vault: %w", err)
+			} else {
+				l.notice(FileEvent{Action: EventBackup, Path: backup.Path})
+			}
+		}
+		db.Close()
+		if err != nil || upToDate {
+			return false, err
+		}
+	}
+
+	// A vault held open with Begin would keep the replaced file
+	if err := l.reopen(); err != nil {
+		return false, fmt.Errorf("failed to close vault: %w", err)
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return false, fmt.Errorf("failed to replace vault: %w", err)
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return true, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := storage.ConvertLayout(db, layout); err != nil {
+		return true, fmt.Errorf("failed to restore the %s layout: %w", layout, err)
+	}
+	if err := putRecord(db, recordSync, &SyncState{Remote: rem.String(), ETag: etag, Modified: remoteModified, Generation: remoteGeneration}); err != nil {
+		return true, fmt.Errorf("failed to record sync state: %w", err)
+	}
+	return true, nil
+}
+
+// checkPull decides whether the local vault can be replaced by the remote one.
+// Returns true if the local vault already has the remote version.
+func (l *LockEnv) checkPull(db storage.Backend, rem remote.Remote, etag, remoteID string, remoteGeneration uint64, force bool) (bool, error) {
+	state, err := getSyncState(db)
+	if err != nil {
+		return false, err
+	}
+	if state != nil && state.Remote == rem.String() && etag != "" && state.ETag == etag && !force {
+		return true, nil
+	}
+	if force {
+		return false, nil
+	}
+
+	localID, err := db.GetOrCreateVaultID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get vault ID: %w", err)
+	}
+	if remoteID != "" && localID != remoteID {
+		return false, ErrVaultIDMismatch
+	}
+
+	modified, err := db.GetModified()
+	if err != nil {
+		return false, fmt.Errorf("failed to read modification time: %w", err)
+	}
+	generation, err := db.GetGeneration()
+	if err != nil {
+		return false, fmt.Errorf("failed to read generation: %w", err)
+	}
+	if !inSync(state, rem, generation, modified) {
+		if state == nil || state.Remote != rem.String() {
+			return false, ErrLocalChanges
+		}
+		return false, fmt.Errorf("%w (generation %d, last synced %d)", ErrLocalChanges, generation, state.Generation)
+	}
+	if remoteGeneration < state.Generation {
+		return false, fmt.Errorf("%w (generation %d, last synced %d)", ErrStaleRemote, remoteGeneration, state.Generation)
+	}
+	return false, nil
+}
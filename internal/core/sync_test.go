@@ -0,0 +1,204 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/remote"
+)
+
+// memRemote is an in-memory remote with ETag versioning
+type memRemote struct {
+	data    []byte
+	version int
+}
+
+func (m *memRemote) etag() string {
+	return fmt.Sprintf("v%d", m.version)
+}
+
+func (m *memRemote) Get(_ context.Context) ([]byte, string, error) {
+	if m.data == nil {
+		return nil, "", remote.ErrNotFound
+	}
+	return append([]byte(nil), m.data...), m.etag(), nil
+}
+
+func (m *memRemote) Put(_ context.Context, data []byte, etag string, force bool) (string, error) {
+	if !force && m.data != nil && etag != m.etag() {
+		return "", remote.ErrConflict
+	}
+	m.data = append([]byte(nil), data...)
+	m.version++
+	return m.etag(), nil
+}
+
+func (m *memRemote) String() string {
+	return "mem://vault"
+}
+
+func lockSyncTestFile(t *testing.T, lockenv *LockEnv, dir, name string, password []byte) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("SECRET="+name), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{path}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+}
+
+func TestPushPull_RoundTripAndConflicts(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	rem := &memRemote{}
+
+	dirA := t.TempDir()
+	a, err := New(dirA)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer a.Close()
+	if err := a.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	lockSyncTestFile(t, a, dirA, "a.env", password)
+
+	if pushed, err := a.Push(ctx, rem, false); err != nil || !pushed {
+		t.Fatalf("Push failed: pushed=%v err=%v", pushed, err)
+	}
+	if pushed, err := a.Push(ctx, rem, false); err != nil || pushed {
+		t.Errorf("Second push should be a no-op: pushed=%v err=%v", pushed, err)
+	}
+	if a.RemoteURL() != rem.String() {
+		t.Errorf("Expected remote %s to be recorded, got %q", rem, a.RemoteURL())
+	}
+
+	// Pull into an empty directory
+	dirB := t.TempDir()
+	b, err := New(dirB)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer b.Close()
+	if pulled, err := b.Pull(ctx, rem, false); err != nil || !pulled {
+		t.Fatalf("Pull failed: pulled=%v err=%v", pulled, err)
+	}
+	if pulled, err := b.Pull(ctx, rem, false); err != nil || pulled {
+		t.Errorf("Second pull should be a no-op: pulled=%v err=%v", pulled, err)
+	}
+	files, err := b.List(ctx)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected 1 file after pull, got %v (%v)", files, err)
+	}
+
+	// B pushes a change, then A changes concurrently
+	lockSyncTestFile(t, b, dirB, "b.env", password)
+	if _, err := b.Push(ctx, rem, false); err != nil {
+		t.Fatalf("Push from B failed: %v", err)
+	}
+	lockSyncTestFile(t, a, dirA, "c.env", password)
+
+	if _, err := a.Push(ctx, rem, false); !errors.Is(err, remote.ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if _, err := a.Pull(ctx, rem, false); !errors.Is(err, ErrLocalChanges) {
+		t.Errorf("Expected ErrLocalChanges, got %v", err)
+	}
+
+	if pulled, err := a.Pull(ctx, rem, true); err != nil || !pulled {
+		t.Fatalf("Forced pull failed: pulled=%v err=%v", pulled, err)
+	}
+	files, err = a.List(ctx)
+	if err != nil || len(files) != 2 {
+		t.Errorf("Expected B's 2 files after forced pull, got %v (%v)", files, err)
+	}
+	backups, err := ListBackups(filepath.Join(dirA, DefaultBackupDir))
+	if err != nil || len(backups) != 1 {
+		t.Errorf("Expected pull to back up the local vault, got %v (%v)", backups, err)
+	}
+}
+
+func TestPull_RefusesDifferentVault(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	rem := &memRemote{}
+
+	other, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer other.Close()
+	if err := other.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if _, err := other.Push(ctx, rem, false); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	local, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer local.Close()
+	if err := local.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if _, err := local.Pull(ctx, rem, false); !errors.Is(err, ErrVaultIDMismatch) {
+		t.Errorf("Expected ErrVaultIDMismatch, got %v", err)
+	}
+}
+
+func TestPull_RefusesStaleRemote(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	rem := &memRemote{}
+
+	dirA := t.TempDir()
+	a, err := New(dirA)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer a.Close()
+	if err := a.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	lockSyncTestFile(t, a, dirA, "a.env", password)
+	if _, err := a.Push(ctx, rem, false); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	old := append([]byte(nil), rem.data...)
+
+	lockSyncTestFile(t, a, dirA, "b.env", password)
+	if _, err := a.Push(ctx, rem, false); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	b, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer b.Close()
+	if _, err := b.Pull(ctx, rem, false); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	// An old copy of the vault is pushed over the remote
+	rem.data = old
+	rem.version++
+
+	if _, err := b.Pull(ctx, rem, false); !errors.Is(err, ErrStaleRemote) {
+		t.Errorf("Expected ErrStaleRemote, got %v", err)
+	}
+	if pulled, err := b.Pull(ctx, rem, true); err != nil || !pulled {
+		t.Errorf("Forced pull failed: pulled=%v err=%v", pulled, err)
+	}
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// UnlockTar writes the decrypted files matching patterns (all files if
+// empty) to w as a tar archive instead of the working tree (implements
+// `lockenv unlock --tar`). Entries carry the stored path and lock time, and
+// the attributes selected with Preserve: an owner-only mode by default, the
+// exact stored mode with PreserveMode, and also the recorded owner and
+// extended attributes with PreserveAll. Files in locked domains, never
+// locked files and files failing the integrity or signature check are left
+// out and reported in the result; nothing is printed, so w may be stdout.
+func (l *LockEnv) UnlockTar(ctx context.Context, password []byte, patterns []string, w io.Writer) (*UnlockResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	var signed map[string]string
+	if l.signers != nil {
+		if signed, err = l.signedHashes(db); err != nil {
+			return nil, err
+		}
+	}
+
+	files := metadata.Files
+	if len(patterns) > 0 {
+		files = filterFilesByPatterns(metadata.Files, patterns)
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files match the specified patterns")
+		}
+	}
+
+	result := &UnlockResult{
+		Extracted: []string{},
+		Skipped:   []string{},
+		Errors:    []string{},
+	}
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := l.fileEncryptor(&file, enc); err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s (domain %s is locked)", file.Path, file.Domain))
+			continue
+		}
+		if sealed, err := db.HasFileData(file.Path); err == nil && !sealed {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s (tracked, never locked)", file.Path))
+			continue
+		}
+		if signed != nil && signed[file.Path] != file.Hash {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: not covered by the manifest signature", file.Path))
+			continue
+		}
+		if err := l.writeTarEntry(ctx, tw, db, &file, enc); err != nil {
+			if _, ok := err.(tarWriteError); ok {
+				return nil, err
+			}
+			if errors.Is(err, ErrVaultNotOpened) {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s (vault %s is not opened)", file.Path, file.Ref.VaultID))
+				continue
+			}
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.Extracted = append(result.Extracted, file.Path)
+	}
+	result.MissingRequired = l.missingRequired(metadata.Files, result, false)
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return result, nil
+}
+
+// tarMode converts the stored mode bits to the Unix mode of a tar header
+func tarMode(mode os.FileMode) int64 {
+	m := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// tarWriteError is a failure to write the archive itself, which ends
+// UnlockTar rather than skipping one file
+type tarWriteError struct{ error }
+
+// writeTarEntry decrypts file and appends it to tw, wiping the plaintext
+// afterwards
+func (l *LockEnv) writeTarEntry(ctx context.Context, tw *tar.Writer, db storage.Backend, file *storage.FileEntry, enc *crypto.Encryptor) error {
+	plain, err := l.decryptEntry(ctx, db, file, enc)
+	if err != nil {
+		return err
+	}
+	defer plain.Close()
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     file.Path,
+		Mode:     int64(secureFileMode(file.Mode)),
+		Size:     int64(plain.Len()),
+		ModTime:  file.ModTime,
+		Format:   tar.FormatPAX,
+	}
+	if l.preserve >= PreserveMode {
+		header.Mode = tarMode(os.FileMode(file.Mode))
+	}
+	if l.preserve >= PreserveAll {
+		if file.Owner != nil {
+			header.Uid = file.Owner.UID
+			header.Gid = file.Owner.GID
+		}
+		for name, value := range file.Xattrs {
+			if header.PAXRecords == nil {
+				header.PAXRecords = make(map[string]string)
+			}
+			header.PAXRecords["SCHILY.xattr."+name] = string(value)
+		}
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return tarWriteError{fmt.Errorf("failed to write archive: %w", err)}
+	}
+	if _, err := tw.Write(plain.Borrow()); err != nil {
+		return tarWriteError{fmt.Errorf("failed to write archive: %w", err)}
+	}
+	return nil
+}
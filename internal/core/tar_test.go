@@ -0,0 +1,72 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnlockTar(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1", "config/prod.env": "P=1", "config/tls.key": "KEY"})
+	dir := filepath.Dir(lockenv.path)
+
+	var archive bytes.Buffer
+	result, err := lockenv.UnlockTar(ctx, password, []string{"config/*"}, &archive)
+	if err != nil {
+		t.Fatalf("UnlockTar failed: %v", err)
+	}
+	if len(result.Extracted) != 2 || len(result.Errors) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	contents := make(map[string]string)
+	tr := tar.NewReader(&archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid archive: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Mode != 0600 {
+			t.Errorf("%s has mode %o, want 600", header.Name, header.Mode)
+		}
+		contents[header.Name] = string(data)
+	}
+	if len(contents) != 2 || contents["config/prod.env"] != "P=1" || contents["config/tls.key"] != "KEY" {
+		t.Errorf("Unexpected archive contents: %v", contents)
+	}
+
+	// PreserveMode keeps group and other bits
+	lockenv.Preserve(PreserveMode)
+	archive.Reset()
+	if _, err := lockenv.UnlockTar(ctx, password, []string{".env"}, &archive); err != nil {
+		t.Fatalf("UnlockTar failed: %v", err)
+	}
+	if header, err := tar.NewReader(&archive).Next(); err != nil || header.Mode != 0644 {
+		t.Errorf("Expected the stored mode 644, got %v", err)
+	}
+
+	// The working tree is left alone
+	if _, err := os.Stat(filepath.Join(dir, "config", "prod.env")); !os.IsNotExist(err) {
+		t.Errorf("UnlockTar should not write files, got %v", err)
+	}
+
+	if _, err := lockenv.UnlockTar(ctx, password, []string{"nothing*"}, io.Discard); err == nil {
+		t.Error("Expected error when no files match")
+	}
+	if _, err := lockenv.UnlockTar(ctx, []byte("wrong"), nil, io.Discard); err == nil {
+		t.Error("Expected error for a wrong password")
+	}
+}
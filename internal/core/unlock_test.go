@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -33,10 +34,10 @@ func TestUnlockSmart_NoConflicts(t *testing.T) {
 	}
 
 	// Track and seal files (with removal)
-	if err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
-	if err := lockenv.FinalizeLock(context.Background(), password, true); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -98,10 +99,10 @@ func TestUnlockSmart_FilesIdentical(t *testing.T) {
 	}
 
 	// Track and seal files (keep originals)
-	if err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
-	if err := lockenv.FinalizeLock(context.Background(), password, false); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -145,10 +146,10 @@ func TestUnlockSmart_StrategyKeepLocal(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
-	if err := lockenv.FinalizeLock(context.Background(), password, false); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -204,10 +205,10 @@ func TestUnlockSmart_StrategyUseSealed(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
-	if err := lockenv.FinalizeLock(context.Background(), password, false); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -266,10 +267,10 @@ func TestUnlockSmart_StrategyAbort(t *testing.T) {
 		t.Fatalf("Failed to create test file 2: %v", err)
 	}
 
-	if err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
-	if err := lockenv.FinalizeLock(context.Background(), password, false); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -322,10 +323,10 @@ func TestUnlockSmart_MixedResults(t *testing.T) {
 	}
 
 	// Track and seal all files
-	if err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2, testFile3}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile1, testFile2, testFile3}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
-	if err := lockenv.FinalizeLock(context.Background(), password, false); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -396,10 +397,10 @@ func TestUnlockSmart_DirectoryCreation(t *testing.T) {
 	}
 
 	// Track and seal
-	if err := lockenv.LockFiles(context.Background(), []string{testFile}, password); err != nil {
+	if _, err := lockenv.LockFiles(context.Background(), []string{testFile}, password, false); err != nil {
 		t.Fatalf("Track failed: %v", err)
 	}
-	if err := lockenv.FinalizeLock(context.Background(), password, false); err != nil {
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
 		t.Fatalf("Seal failed: %v", err)
 	}
 
@@ -445,3 +446,112 @@ func TestUnlockSmart_HashMismatch(t *testing.T) {
 	// 4. Verify integrity check failure is reported in result.Errors
 	t.Skip("Hash mismatch test requires database manipulation - implement if needed")
 }
+
+func TestUnlock_PreserveMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+	password := []byte("test-password")
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name     string
+		preserve PreserveLevel
+		want     os.FileMode
+	}{
+		{name: "masked by default", preserve: PreserveNone, want: 0600},
+		{name: "exact with PreserveMode", preserve: PreserveMode, want: 0640},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lockenv := newMergeTestVault(t, password, map[string]string{"shared.env": "A=1"})
+			path := filepath.Join(filepath.Dir(lockenv.path), "shared.env")
+			// newMergeTestVault wrote the file 0644 and removed it; lock it
+			// again with group read only
+			if err := os.WriteFile(path, []byte("A=1"), 0640); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, 0640); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+				t.Fatalf("LockFiles failed: %v", err)
+			}
+			if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+				t.Fatalf("FinalizeLock failed: %v", err)
+			}
+
+			lockenv.Preserve(tt.preserve)
+			if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil); err != nil {
+				t.Fatalf("Unlock failed: %v", err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != tt.want {
+				t.Errorf("mode = %o, want %o", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlock_PreserveAll(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("extended attributes and owners are not supported on Windows")
+	}
+	password := []byte("test-password")
+	ctx := context.Background()
+
+	lockenv := newMergeTestVault(t, password, map[string]string{"cert.pem": "CERT"})
+	path := filepath.Join(filepath.Dir(lockenv.path), "cert.pem")
+	if err := os.WriteFile(path, []byte("CERT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeXattrs(path, map[string][]byte{"user.lockenv-test": []byte("v1")}); err != nil {
+		t.Skipf("extended attributes not supported here: %v", err)
+	}
+	if attrs, _ := readXattrs(path); attrs == nil {
+		t.Skip("extended attributes not supported here")
+	}
+	if _, err := lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+		t.Fatalf("LockFiles failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+		t.Fatalf("FinalizeLock failed: %v", err)
+	}
+
+	file, err := lockenv.Show(ctx, password, "cert.pem")
+	if err != nil {
+		t.Fatalf("Show failed: %v", err)
+	}
+	if got := string(file.Xattrs["user.lockenv-test"]); got != "v1" {
+		t.Errorf("recorded xattr = %q, want v1", got)
+	}
+	if root := os.Geteuid() == 0; root != (file.Owner != nil) {
+		t.Errorf("owner recorded = %v, want %v when running as root = %v", file.Owner != nil, root, root)
+	}
+
+	// Without --preserve-all the attributes are not restored
+	lockenv.Preserve(PreserveMode)
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if attrs, _ := readXattrs(path); attrs["user.lockenv-test"] != nil {
+		t.Error("xattr restored without PreserveAll")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	lockenv.Preserve(PreserveAll)
+	if _, err := lockenv.Unlock(ctx, password, StrategyUseVault, nil); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	attrs, err := readXattrs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(attrs["user.lockenv-test"]); got != "v1" {
+		t.Errorf("restored xattr = %q, want v1", got)
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// VaultInfoFields lists the descriptive fields a vault can carry, in display
+// order. They are stored unencrypted so anyone finding a .lockenv can see what
+// it protects and whom to ask for access.
+var VaultInfoFields = []string{"description", "owner", "contact"}
+
+// getVaultInfo returns the vault's descriptive fields, empty if none are set
+func getVaultInfo(db storage.ConfigStore) (map[string]string, error) {
+	info := make(map[string]string)
+	_, err := getRecord(db, recordInfo, &info)
+	return info, err
+}
+
+// SetVaultInfo sets a descriptive field of the vault (implements
+// `lockenv meta set`). An empty value removes the field.
+func (l *LockEnv) SetVaultInfo(password []byte, field, value string) error {
+	if !isVaultInfoField(field) {
+		return fmt.Errorf("unknown field %q (use %s)", field, strings.Join(VaultInfoFields, ", "))
+	}
+	if err := l.VerifyPassword(password); err != nil {
+		return err
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return openError(err)
+	}
+	defer db.Close()
+
+	info, err := getVaultInfo(db)
+	if err != nil {
+		return fmt.Errorf("failed to read vault info: %w", err)
+	}
+	if value = strings.TrimSpace(value); value == "" {
+		delete(info, field)
+	} else {
+		info[field] = value
+	}
+	if len(info) == 0 {
+		err = db.DeleteConfig(recordInfo)
+	} else {
+		err = putRecord(db, recordInfo, info)
+	}
+	if err != nil {
+		return err
+	}
+	return db.UpdateModified()
+}
+
+// GetVaultInfo returns the vault's descriptive fields (no password required)
+func (l *LockEnv) GetVaultInfo() (map[string]string, error) {
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	return getVaultInfo(db)
+}
+
+func isVaultInfoField(field string) bool {
+	for _, f := range VaultInfoFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVaultInfo(t *testing.T) {
+	password := []byte("test-password")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1"})
+
+	info, err := lockenv.GetVaultInfo()
+	if err != nil {
+		t.Fatalf("GetVaultInfo failed: %v", err)
+	}
+	if len(info) != 0 {
+		t.Errorf("new vault info = %v, want empty", info)
+	}
+
+	if err := lockenv.SetVaultInfo(password, "description", "  payments service secrets "); err != nil {
+		t.Fatalf("SetVaultInfo failed: %v", err)
+	}
+	if err := lockenv.SetVaultInfo(password, "owner", "sre"); err != nil {
+		t.Fatalf("SetVaultInfo failed: %v", err)
+	}
+	if err := lockenv.SetVaultInfo(password, "owner", ""); err != nil {
+		t.Fatalf("SetVaultInfo failed: %v", err)
+	}
+
+	info, err = lockenv.GetVaultInfo()
+	if err != nil {
+		t.Fatalf("GetVaultInfo failed: %v", err)
+	}
+	if len(info) != 1 || info["description"] != "payments service secrets" {
+		t.Errorf("info = %v, want only the description", info)
+	}
+
+	status, err := lockenv.Status(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Info["description"] != "payments service secrets" {
+		t.Errorf("status info = %v", status.Info)
+	}
+}
+
+func TestSetVaultInfo_Rejects(t *testing.T) {
+	password := []byte("test-password")
+	lockenv := newMergeTestVault(t, password, map[string]string{".env": "A=1"})
+
+	if err := lockenv.SetVaultInfo(password, "secret", "x"); err == nil {
+		t.Error("SetVaultInfo accepted an unknown field")
+	}
+	if err := lockenv.SetVaultInfo([]byte("wrong"), "owner", "x"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong password: error = %v, want ErrWrongPassword", err)
+	}
+}
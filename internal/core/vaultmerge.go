@@ -0,0 +1,377 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// ErrOtherWrongPassword is returned when the other vault does not open with the given password
+var ErrOtherWrongPassword = errors.New("wrong password for the other vault")
+
+// MergeResult contains the results of a vault merge
+type MergeResult struct {
+	Added   []string // Files only in the other vault
+	Updated []string // Conflicting files replaced by the other or the edited version
+	Skipped []string // Conflicting files that kept the local version
+	Errors  []string // Files with errors
+
+	// Events describes what happened to each file, in order, for the caller to show
+	Events EventLog
+
+	Generation      uint64 // Generation of this vault after the merge
+	OtherGeneration uint64 // Generation of the other vault
+	Diverged        bool   // Both copies of the vault changed from the same generation
+}
+
+// vaultState identifies a version of a vault without decrypting it
+type vaultState struct {
+	id         string
+	generation uint64
+	hashes     map[string]string
+}
+
+// readVaultState reads the vault ID, generation and manifest hashes of db
+func readVaultState(db storage.Backend) (*vaultState, error) {
+	// Vaults that were never pushed may have no ID
+	id, _ := db.GetVaultID()
+	generation, err := db.GetGeneration()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generation: %w", err)
+	}
+	hashes, err := manifestHashes(db)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultState{id: id, generation: generation, hashes: hashes}, nil
+}
+
+// diverged reports whether a and b are copies of one vault that were changed
+// independently, e.g. locked on two machines before either was committed.
+// Without a common ancestor this is only certain when both copies reached
+// the same generation with different contents.
+func (a *vaultState) diverged(b *vaultState) bool {
+	if a.id == "" || a.id != b.id || a.generation == 0 || a.generation != b.generation {
+		return false
+	}
+	return !maps.Equal(a.hashes, b.hashes)
+}
+
+// mergedFile is a file waiting to be written into the local vault
+type mergedFile struct {
+	entry storage.FileEntry
+	data  []byte
+}
+
+// MergeVault merges the files of the vault at otherPath into this vault
+// (implements `lockenv merge`). Files only in the other vault are added and
+// conflicting files are resolved with strategy, the other vault taking the
+// "vault" side. Files are never removed: without a common ancestor a deletion
+// on one side cannot be told apart from an addition on the other.
+// Files in an encryption domain are left out on both sides.
+// A nil otherPassword opens the other vault with the same password or key.
+// The merged vault gets a generation above both vaults, so it supersedes
+// either of them on the next push or pull.
+func (l *LockEnv) MergeVault(ctx context.Context, password []byte, otherPath string, otherPassword []byte, strategy MergeStrategy) (*MergeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+	if _, err := os.Stat(otherPath); err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", otherPath, err)
+	}
+	if same, _ := sameFile(l.path, otherPath); same {
+		return nil, fmt.Errorf("cannot merge a vault with itself")
+	}
+
+	var events EventLog
+	otherFiles, otherDirs, otherState, err := l.readOtherVault(ctx, password, otherPath, otherPassword, &events)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range otherFiles {
+			crypto.ClearBytes(f.data)
+		}
+	}()
+
+	db, err := l.open()
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Destroy()
+
+	localState, err := readVaultState(db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MergeResult{
+		Added:           []string{},
+		Updated:         []string{},
+		Skipped:         []string{},
+		Errors:          []string{},
+		Generation:      localState.generation,
+		OtherGeneration: otherState.generation,
+		Diverged:        localState.diverged(otherState),
+		Events:          events,
+	}
+
+	// Phase 1: resolve every file, collecting the ones to write
+	var pending []mergedFile
+	for _, other := range otherFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path := other.entry.Path
+
+		local := metadata.FindFile(path)
+		if local == nil {
+			pending = append(pending, other)
+			result.Added = append(result.Added, path)
+			continue
+		}
+		if local.Hash == other.entry.Hash {
+			continue
+		}
+		if local.Domain != "" {
+			result.skip(path, "in domain "+local.Domain)
+			continue
+		}
+		if local.Ref != nil || other.entry.Ref != nil {
+			result.skip(path, "reference to another vault")
+			continue
+		}
+
+		encryptedData, err := db.GetFileData(ctx, path)
+		if err != nil {
+			result.fail(path, fmt.Errorf("cannot read from storage: %w", err))
+			continue
+		}
+		localData, err := enc.For(crypto.PurposeBlobs).Decrypt(encryptedData)
+		if err != nil {
+			result.fail(path, fmt.Errorf("cannot decrypt: %w", err))
+			continue
+		}
+
+		conflictResult, err := HandleConflict(path, localData, other.data, strategy, l.mergeOptions())
+		if err != nil {
+			crypto.ClearBytes(localData)
+			result.fail("", err)
+			continue
+		}
+
+		switch conflictResult.Resolution {
+		case ResolutionKeepLocal, ResolutionSkip:
+			result.skip(path, "kept local version")
+		case ResolutionUseVault:
+			pending = append(pending, other)
+			result.Updated = append(result.Updated, path)
+		case ResolutionEditMerged:
+			hash := sha256.Sum256(conflictResult.MergedData)
+			pending = append(pending, mergedFile{
+				entry: storage.FileEntry{
+					Path:    path,
+					Size:    int64(len(conflictResult.MergedData)),
+					Mode:    local.Mode,
+					ModTime: time.Now(),
+					Hash:    hex.EncodeToString(hash[:]),
+				},
+				data: conflictResult.MergedData,
+			})
+			result.Updated = append(result.Updated, path)
+		case ResolutionKeepBoth:
+			copyPath := mergeCopyPath(metadata, path)
+			if copyPath == "" {
+				result.fail(path, fmt.Errorf("too many backup copies (max %d)", MaxVaultCopies))
+				break
+			}
+			entry := other.entry
+			entry.Path = copyPath
+			pending = append(pending, mergedFile{entry: entry, data: other.data})
+			result.Added = append(result.Added, copyPath)
+			result.skip(path, "kept local version")
+		}
+		crypto.ClearBytes(localData)
+	}
+
+	// Phase 2: encrypt all files before touching the database
+	encrypted := make([][]byte, len(pending))
+	for i, p := range pending {
+		encrypted[i], err = enc.For(crypto.PurposeBlobs).Encrypt(p.data)
+		crypto.ClearBytes(p.data)
+		if err != nil {
+			for _, e := range encrypted[:i] {
+				crypto.ClearBytes(e)
+			}
+			return nil, fmt.Errorf("failed to encrypt %s: %w", p.entry.Path, err)
+		}
+	}
+
+	// Phase 3: store files and metadata
+	for i, p := range pending {
+		if err := db.StoreFileData(ctx, p.entry.Path, encrypted[i]); err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", p.entry.Path, err)
+		}
+		if err := l.updateManifestEntry(db, p.entry.Path, p.entry.Size, p.entry.ModTime, p.entry.Hash); err != nil {
+			return nil, fmt.Errorf("failed to update manifest for %s: %w", p.entry.Path, err)
+		}
+		metadata.AddFile(p.entry)
+		crypto.ClearBytes(encrypted[i])
+		result.Events.add(EventMerged, p.entry.Path, "")
+	}
+
+	for _, dir := range otherDirs {
+		if err := addTrackedDir(db, dir); err != nil {
+			return nil, fmt.Errorf("failed to track directory %s: %w", dir, err)
+		}
+	}
+
+	if len(pending) > 0 {
+		if err := db.RaiseGeneration(otherState.generation); err != nil {
+			return nil, fmt.Errorf("failed to update generation: %w", err)
+		}
+		if err := l.saveMetadata(db, metadata, enc); err != nil {
+			return nil, err
+		}
+		if result.Generation, err = db.GetGeneration(); err != nil {
+			return nil, fmt.Errorf("failed to read generation: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// readOtherVault decrypts every file in the vault at otherPath, sorted by path,
+// and returns them with its tracked directories and state.
+// Files that fail their integrity check are recorded in events and skipped.
+func (l *LockEnv) readOtherVault(ctx context.Context, password []byte, otherPath string, otherPassword []byte, events *EventLog) ([]mergedFile, []string, *vaultState, error) {
+	otherDB, err := l.openAt(otherPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("cannot open %s: %w", otherPath, err)
+	}
+	defer otherDB.Close()
+
+	if initialized, err := otherDB.IsInitialized(); err != nil || !initialized {
+		return nil, nil, nil, fmt.Errorf("%s is not a lockenv vault", otherPath)
+	}
+	state, err := readVaultState(otherDB)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", otherPath, err)
+	}
+
+	other := &LockEnv{path: otherPath, notify: l.notify}
+	if otherPassword == nil {
+		// Diverged copies of one vault share its salt, so the same key opens both
+		otherPassword = password
+		if l.key != nil {
+			other.key = crypto.SecureCopy(l.key.Borrow())
+		}
+	}
+	defer other.key.Close()
+
+	metadata, enc, err := other.readMetadata(ctx, otherDB, otherPassword)
+	if err != nil {
+		if err == ErrWrongPassword || err == ErrPasswordRequired {
+			return nil, nil, nil, ErrOtherWrongPassword
+		}
+		return nil, nil, nil, err
+	}
+	defer enc.Destroy()
+
+	files := make([]mergedFile, 0, len(metadata.Files))
+	for _, entry := range metadata.Files {
+		if err := ctx.Err(); err != nil {
+			for _, f := range files {
+				crypto.ClearBytes(f.data)
+			}
+			return nil, nil, nil, err
+		}
+
+		if entry.Domain != "" {
+			events.addErr(EventWarning, entry.Path, fmt.Errorf("in domain %s of %s, not merged", entry.Domain, otherPath))
+			continue
+		}
+		// References are merged as references, with an empty placeholder blob
+		if entry.Ref != nil {
+			files = append(files, mergedFile{entry: entry})
+			continue
+		}
+		encryptedData, err := otherDB.GetFileData(ctx, entry.Path)
+		if err != nil {
+			events.addErr(EventWarning, entry.Path, fmt.Errorf("not stored in %s", otherPath))
+			continue
+		}
+		data, err := enc.For(crypto.PurposeBlobs).Decrypt(encryptedData)
+		if err != nil {
+			events.addErr(EventWarning, entry.Path, fmt.Errorf("cannot decrypt: %w", err))
+			continue
+		}
+		hash := sha256.Sum256(data)
+		if hex.EncodeToString(hash[:]) != entry.Hash {
+			crypto.ClearBytes(data)
+			events.addErr(EventWarning, entry.Path, fmt.Errorf("failed integrity check in %s", otherPath))
+			continue
+		}
+		files = append(files, mergedFile{entry: entry, data: data})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].entry.Path < files[j].entry.Path })
+
+	dirs, err := getTrackedDirs(otherDB)
+	if err != nil {
+		for _, f := range files {
+			crypto.ClearBytes(f.data)
+		}
+		return nil, nil, nil, fmt.Errorf("failed to read tracked directories: %w", err)
+	}
+
+	return files, dirs, state, nil
+}
+
+// mergeCopyPath returns a free .from-vault path in the vault for the other
+// version of path, or "" if all slots are taken
+func mergeCopyPath(metadata *storage.Metadata, path string) string {
+	copyPath := path + ".from-vault"
+	if metadata.FindFile(copyPath) == nil {
+		return copyPath
+	}
+	for i := 1; i < MaxVaultCopies; i++ {
+		copyPath = fmt.Sprintf("%s.from-vault.%d", path, i)
+		if metadata.FindFile(copyPath) == nil {
+			return copyPath
+		}
+	}
+	return ""
+}
+
+// sameFile reports whether two paths refer to the same file
+func sameFile(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(filepath.Clean(b))
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
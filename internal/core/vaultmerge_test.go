@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/illarion/lockenv/internal/storage"
+)
+
+// newMergeTestVault creates a vault in a temp dir holding the given files
+func newMergeTestVault(t *testing.T, password []byte, files map[string]string) *LockEnv {
+	t.Helper()
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	t.Cleanup(func() { lockenv.Close() })
+
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), paths, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	return lockenv
+}
+
+func vaultContents(t *testing.T, lockenv *LockEnv, password []byte) map[string]string {
+	t.Helper()
+	db, err := storage.Open(lockenv.path)
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	defer db.Close()
+
+	files, err := lockenv.decryptAllFiles(context.Background(), db, password, &EventLog{})
+	if err != nil {
+		t.Fatalf("Failed to decrypt vault: %v", err)
+	}
+	contents := make(map[string]string, len(files))
+	for path, data := range files {
+		contents[path] = string(data)
+	}
+	return contents
+}
+
+func TestMergeVault_DifferentPasswords(t *testing.T) {
+	ctx := context.Background()
+	ours := []byte("ours123")
+	theirs := []byte("theirs123")
+
+	local := newMergeTestVault(t, ours, map[string]string{"a.env": "A=1", "shared.env": "X=1"})
+	other := newMergeTestVault(t, theirs, map[string]string{"b.env": "B=1", "shared.env": "X=2"})
+
+	if _, err := local.MergeVault(ctx, ours, other.path, nil, StrategyKeepLocal); err != ErrOtherWrongPassword {
+		t.Fatalf("Expected ErrOtherWrongPassword, got %v", err)
+	}
+
+	result, err := local.MergeVault(ctx, ours, other.path, theirs, StrategyKeepLocal)
+	if err != nil {
+		t.Fatalf("MergeVault failed: %v", err)
+	}
+	if len(result.Added) != 1 || result.Added[0] != "b.env" {
+		t.Errorf("Expected b.env to be added, got %v", result.Added)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "shared.env" {
+		t.Errorf("Expected shared.env conflict to keep local, got %v", result.Skipped)
+	}
+
+	contents := vaultContents(t, local, ours)
+	if contents["a.env"] != "A=1" || contents["b.env"] != "B=1" || contents["shared.env"] != "X=1" {
+		t.Errorf("Unexpected vault contents after merge: %v", contents)
+	}
+
+	// Taking the other side replaces the conflicting file
+	result, err = local.MergeVault(ctx, ours, other.path, theirs, StrategyUseVault)
+	if err != nil {
+		t.Fatalf("MergeVault failed: %v", err)
+	}
+	if len(result.Updated) != 1 || len(result.Added) != 0 {
+		t.Errorf("Expected only shared.env to be updated, got %+v", result)
+	}
+	if got := vaultContents(t, local, ours)["shared.env"]; got != "X=2" {
+		t.Errorf("Expected other version of shared.env, got %q", got)
+	}
+}
+
+func TestMergeVault_KeepBoth(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+
+	local := newMergeTestVault(t, password, map[string]string{"shared.env": "X=1"})
+	other := newMergeTestVault(t, password, map[string]string{"shared.env": "X=2"})
+
+	// Same password opens both vaults
+	result, err := local.MergeVault(ctx, password, other.path, nil, StrategyKeepBoth)
+	if err != nil {
+		t.Fatalf("MergeVault failed: %v", err)
+	}
+	if len(result.Added) != 1 || result.Added[0] != "shared.env.from-vault" {
+		t.Errorf("Expected other version stored as copy, got %v", result.Added)
+	}
+
+	contents := vaultContents(t, local, password)
+	if contents["shared.env"] != "X=1" || contents["shared.env.from-vault"] != "X=2" {
+		t.Errorf("Unexpected vault contents after merge: %v", contents)
+	}
+
+	if _, err := local.MergeVault(ctx, password, local.path, nil, StrategyKeepBoth); err == nil {
+		t.Error("Expected merging a vault with itself to fail")
+	}
+}
+
+func TestMergeVault_Generations(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("test123")
+
+	local := newMergeTestVault(t, password, map[string]string{"a.env": "A=1"})
+	if _, err := local.GetOrCreateVaultID(); err != nil {
+		t.Fatalf("GetOrCreateVaultID failed: %v", err)
+	}
+
+	// A second checkout of the same vault, changed in the same way
+	data, err := os.ReadFile(local.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(otherDir, LockEnvFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(otherDir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer other.Close()
+	for _, v := range []struct {
+		lockenv *LockEnv
+		content string
+	}{{local, "B=local"}, {other, "B=other"}} {
+		path := filepath.Join(filepath.Dir(v.lockenv.path), "b.env")
+		if err := os.WriteFile(path, []byte(v.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.lockenv.LockFiles(ctx, []string{path}, password, false); err != nil {
+			t.Fatalf("LockFiles failed: %v", err)
+		}
+		if _, err := v.lockenv.FinalizeLock(ctx, password, true, nil); err != nil {
+			t.Fatalf("FinalizeLock failed: %v", err)
+		}
+	}
+
+	result, err := local.MergeVault(ctx, password, other.path, nil, StrategyKeepBoth)
+	if err != nil {
+		t.Fatalf("MergeVault failed: %v", err)
+	}
+	if !result.Diverged {
+		t.Error("Expected copies changed from the same generation to be reported as diverged")
+	}
+	if result.OtherGeneration == 0 || result.Generation <= result.OtherGeneration {
+		t.Errorf("Expected merged generation above %d, got %d", result.OtherGeneration, result.Generation)
+	}
+
+	// Merging again adds nothing and leaves the generation alone
+	again, err := local.MergeVault(ctx, password, other.path, nil, StrategyKeepLocal)
+	if err != nil {
+		t.Fatalf("MergeVault failed: %v", err)
+	}
+	if again.Diverged || again.Generation != result.Generation {
+		t.Errorf("Unexpected second merge: %+v", again)
+	}
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/glob"
+)
+
+// VerifyResult reports how the working tree or the vault compares to the manifest
+type VerifyResult struct {
+	Unchanged []string // Files matching the manifest hash
+	Drifted   []string // Files whose content differs from the manifest hash
+	Missing   []string // Tracked files not present (locally, or in the vault)
+	Extra     []string // Local files matching the patterns that are not tracked
+
+	// Events holds warnings about vault files that could not be read
+	Events EventLog
+}
+
+// OK reports whether nothing drifted and no extra files were found.
+// When strict is set, missing files also count as a failure.
+func (r *VerifyResult) OK(strict bool) bool {
+	if len(r.Drifted) > 0 || len(r.Extra) > 0 {
+		return false
+	}
+	return !strict || len(r.Missing) == 0
+}
+
+// VerifyPaths compares local files against the manifest hashes (implements `lockenv verify --paths`).
+// If patterns are given, only tracked files matching them are checked, and local
+// files matching them that are not tracked are reported as extra.
+// No password is required.
+func (l *LockEnv) VerifyPaths(ctx context.Context, patterns []string) (*VerifyResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	// Open database
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	entries, err := l.getManifestEntries(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	result := &VerifyResult{
+		Unchanged: []string{},
+		Drifted:   []string{},
+		Missing:   []string{},
+		Extra:     []string{},
+	}
+
+	repoRoot := filepath.Dir(l.path)
+	tracked := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Validate path to prevent path traversal from tampered manifest
+		validPath, err := l.validator.ValidateExistingPath(entry.Path)
+		if err != nil {
+			continue
+		}
+		tracked[validPath] = true
+
+		if len(patterns) > 0 && !matchesAnyPattern(validPath, patterns) {
+			continue
+		}
+
+		platformPath := filepath.Join(repoRoot, filepath.FromSlash(validPath))
+		content, err := os.ReadFile(platformPath)
+		if os.IsNotExist(err) {
+			result.Missing = append(result.Missing, validPath)
+			continue
+		}
+		if err != nil {
+			// Unreadable files can't be shown to match
+			result.Drifted = append(result.Drifted, validPath)
+			continue
+		}
+
+		hash := sha256.Sum256(content)
+		crypto.ClearBytes(content)
+
+		if hex.EncodeToString(hash[:]) != entry.Hash {
+			result.Drifted = append(result.Drifted, validPath)
+		} else {
+			result.Unchanged = append(result.Unchanged, validPath)
+		}
+	}
+
+	// Untracked local files matching the patterns
+	for _, pattern := range patterns {
+		absPattern := pattern
+		if !filepath.IsAbs(pattern) {
+			absPattern = filepath.Join(repoRoot, pattern)
+		}
+		matches, err := glob.Glob(absPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		for _, match := range matches {
+			info, err := os.Lstat(match)
+			if err != nil || !info.Mode().IsRegular() || filepath.Base(match) == LockEnvFile {
+				continue
+			}
+			relPath, err := l.normalizeToRelative(match)
+			if err != nil {
+				continue
+			}
+			relPath = filepath.ToSlash(relPath)
+			if !tracked[relPath] {
+				tracked[relPath] = true // Report once even if several patterns match
+				result.Extra = append(result.Extra, relPath)
+			}
+		}
+	}
+	sort.Strings(result.Extra)
+
+	return result, nil
+}
+
+// VerifyVault decrypts every stored file and checks it against the manifest hash (implements `lockenv verify`).
+// Drifted lists files whose decrypted content does not match; Missing lists
+// files whose blobs are absent or cannot be decrypted.
+func (l *LockEnv) VerifyVault(ctx context.Context, password []byte) (*VerifyResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Check if exists
+	if !l.exists() {
+		return nil, ErrNotInitialized
+	}
+
+	// Open database
+	db, err := l.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	entries, err := l.getManifestEntries(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	result := &VerifyResult{
+		Unchanged: []string{},
+		Drifted:   []string{},
+		Missing:   []string{},
+		Extra:     []string{},
+	}
+	files, err := l.decryptAllFiles(ctx, db, password, &result.Events)
+	if err != nil {
+		return nil, err
+	}
+	defer clearFileMap(files)
+
+	for _, entry := range entries {
+		data, ok := files[entry.Path]
+		if !ok {
+			result.Missing = append(result.Missing, entry.Path)
+			continue
+		}
+		hash := sha256.Sum256(data)
+		if hex.EncodeToString(hash[:]) != entry.Hash {
+			result.Drifted = append(result.Drifted, entry.Path)
+		} else {
+			result.Unchanged = append(result.Unchanged, entry.Path)
+		}
+	}
+
+	return result, nil
+}
+
+// matchesAnyPattern reports whether a tracked path matches any pattern (exact match or glob)
+func matchesAnyPattern(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		normalizedPattern := filepath.ToSlash(pattern)
+		if path == normalizedPattern {
+			return true
+		}
+		if glob.Match(normalizedPattern, path) {
+			return true
+		}
+	}
+	return false
+}
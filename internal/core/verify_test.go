@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyPaths_ReportsDrift(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	files := map[string]string{
+		"unchanged.env": "A=1",
+		"modified.env":  "B=1",
+		"missing.env":   "C=1",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	if _, err := lockenv.LockFiles(context.Background(), paths, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, false, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "modified.env"), []byte("B=2"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "missing.env")); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "extra.env"), []byte("D=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Without patterns, untracked files are not considered
+	result, err := lockenv.VerifyPaths(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("VerifyPaths failed: %v", err)
+	}
+	if len(result.Unchanged) != 1 || result.Unchanged[0] != "unchanged.env" {
+		t.Errorf("Expected unchanged.env unchanged, got %v", result.Unchanged)
+	}
+	if len(result.Drifted) != 1 || result.Drifted[0] != "modified.env" {
+		t.Errorf("Expected modified.env drifted, got %v", result.Drifted)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "missing.env" {
+		t.Errorf("Expected missing.env missing, got %v", result.Missing)
+	}
+	if len(result.Extra) != 0 {
+		t.Errorf("Expected no extra files, got %v", result.Extra)
+	}
+
+	// With patterns, untracked matches are reported and others are ignored
+	result, err = lockenv.VerifyPaths(context.Background(), []string{"*.env"})
+	if err != nil {
+		t.Fatalf("VerifyPaths failed: %v", err)
+	}
+	if len(result.Extra) != 1 || result.Extra[0] != "extra.env" {
+		t.Errorf("Expected extra.env untracked, got %v", result.Extra)
+	}
+
+	result, err = lockenv.VerifyPaths(context.Background(), []string{"unchanged.env"})
+	if err != nil {
+		t.Fatalf("VerifyPaths failed: %v", err)
+	}
+	if !result.OK(true) {
+		t.Errorf("Expected only unchanged.env to be checked, got %+v", result)
+	}
+}
+
+func TestVerifyResult_OK(t *testing.T) {
+	missingOnly := &VerifyResult{Missing: []string{"a.env"}}
+	if !missingOnly.OK(false) {
+		t.Error("Missing files should pass without strict")
+	}
+	if missingOnly.OK(true) {
+		t.Error("Missing files should fail with strict")
+	}
+
+	drifted := &VerifyResult{Drifted: []string{"a.env"}}
+	if drifted.OK(false) {
+		t.Error("Drifted files should fail")
+	}
+}
+
+func TestVerifyVault(t *testing.T) {
+	dir := t.TempDir()
+	lockenv, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create LockEnv: %v", err)
+	}
+	defer lockenv.Close()
+
+	password := []byte("test123")
+	if err := lockenv.Init(password); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(path, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := lockenv.LockFiles(context.Background(), []string{path}, password, false); err != nil {
+		t.Fatalf("Track failed: %v", err)
+	}
+	if _, err := lockenv.FinalizeLock(context.Background(), password, true, nil); err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	result, err := lockenv.VerifyVault(context.Background(), password)
+	if err != nil {
+		t.Fatalf("VerifyVault failed: %v", err)
+	}
+	if !result.OK(true) || len(result.Unchanged) != 1 {
+		t.Errorf("Expected vault to verify, got %+v", result)
+	}
+
+	if _, err := lockenv.VerifyVault(context.Background(), []byte("wrong")); err == nil {
+		t.Error("Expected error with wrong password")
+	}
+}
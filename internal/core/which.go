@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// KeyLocation is a place in the vault where a variable is defined
+type KeyLocation struct {
+	Path string
+	Line int
+}
+
+// Which reports which dotenv files in the vault define the variable name
+// (implements `lockenv which`). Files are decrypted in memory only; files in
+// a locked encryption domain or never locked are skipped, the returned
+// events tell which could not be searched.
+func (l *LockEnv) Which(ctx context.Context, password []byte, name string) ([]KeyLocation, EventLog, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if !l.exists() {
+		return nil, nil, ErrNotInitialized
+	}
+
+	db, err := l.open()
+	if err != nil {
+		return nil, nil, openError(err)
+	}
+	defer db.Close()
+
+	metadata, enc, err := l.readMetadata(ctx, db, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer enc.Destroy()
+
+	var locations []KeyLocation
+	var events EventLog
+	for _, file := range metadata.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if !IsDotenvFile(file.Path) {
+			continue
+		}
+		fileEnc, err := l.fileEncryptor(&file, enc)
+		if err != nil {
+			events.add(EventSkipped, file.Path, "domain "+file.Domain+" is locked")
+			continue
+		}
+		if sealed, err := db.HasFileData(file.Path); err == nil && !sealed {
+			continue
+		}
+
+		encryptedData, err := db.GetFileData(ctx, file.Path)
+		if err != nil {
+			events.addErr(EventWarning, file.Path, errors.New("not stored in vault"))
+			continue
+		}
+		plain, err := fileEnc.DecryptSecureContext(ctx, encryptedData)
+		if err != nil {
+			events.addErr(EventWarning, file.Path, fmt.Errorf("cannot decrypt: %w", err))
+			continue
+		}
+		for _, key := range DotenvKeys(plain.Borrow()) {
+			if key.Name == name {
+				locations = append(locations, KeyLocation{Path: file.Path, Line: key.Line})
+			}
+		}
+		plain.Close()
+	}
+
+	return locations, events, nil
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWhich(t *testing.T) {
+	ctx := context.Background()
+	pw := []byte("test123")
+	lockenv := newMergeTestVault(t, pw, map[string]string{
+		".env":        "DB_HOST=localhost\nAPI_KEY=abc\n",
+		".env.prod":   "# production\nAPI_KEY=xyz\n",
+		"config.json": `{"API_KEY": "json"}`,
+	})
+
+	locations, _, err := lockenv.Which(ctx, pw, "API_KEY")
+	if err != nil {
+		t.Fatalf("Which failed: %v", err)
+	}
+	want := map[KeyLocation]bool{
+		{Path: ".env", Line: 2}:      true,
+		{Path: ".env.prod", Line: 2}: true,
+	}
+	if len(locations) != len(want) {
+		t.Fatalf("Which() = %v, want %v", locations, want)
+	}
+	for _, loc := range locations {
+		if !want[loc] {
+			t.Errorf("unexpected location %v", loc)
+		}
+	}
+
+	locations, _, err = lockenv.Which(ctx, pw, "MISSING")
+	if err != nil {
+		t.Fatalf("Which failed: %v", err)
+	}
+	if len(locations) != 0 {
+		t.Errorf("expected no locations, got %v", locations)
+	}
+
+	if _, _, err := lockenv.Which(ctx, []byte("wrong"), "API_KEY"); err != ErrWrongPassword {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+}
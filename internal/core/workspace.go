@@ -0,0 +1,115 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// WorkspaceFile lists the vaults of a workspace, one directory per line,
+// relative to the directory holding it
+const WorkspaceFile = ".lockenv-workspace"
+
+// workspaceHeader starts every workspace file written by lockenv
+const workspaceHeader = "# lockenv workspace: vault directories, one per line (lockenv ws add/rm)\n"
+
+// ReadWorkspace returns the vault directories registered in the workspace
+// file in dir, as recorded. A missing file is an empty workspace.
+func ReadWorkspace(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, WorkspaceFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var vaults []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		vaults = append(vaults, line)
+	}
+	return vaults, nil
+}
+
+// WorkspaceVaultDir returns the directory of a vault recorded in the
+// workspace file in dir
+func WorkspaceVaultDir(dir, vault string) string {
+	if filepath.IsAbs(vault) {
+		return vault
+	}
+	return filepath.Join(dir, filepath.FromSlash(vault))
+}
+
+// AddWorkspaceVaults registers the vaults in vaultDirs in the workspace file
+// in dir (implements `lockenv ws add`) and returns the ones that were not
+// registered yet. Relative directories are resolved against dir and recorded
+// relative to it. Every directory must hold a vault.
+func AddWorkspaceVaults(dir string, vaultDirs []string) ([]string, error) {
+	vaults, err := ReadWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []string
+	for _, vaultDir := range vaultDirs {
+		vault := filepath.Clean(vaultDir)
+		if !filepath.IsAbs(vault) {
+			vault = filepath.ToSlash(vault)
+		}
+		if _, err := os.Stat(filepath.Join(WorkspaceVaultDir(dir, vault), LockEnvFile)); err != nil {
+			return nil, fmt.Errorf("%s: no vault found: %w", vaultDir, err)
+		}
+		if slices.Contains(vaults, vault) {
+			continue
+		}
+		vaults = append(vaults, vault)
+		added = append(added, vault)
+	}
+
+	if len(added) > 0 {
+		if err := writeWorkspace(dir, vaults); err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+// RemoveWorkspaceVaults drops the vaults in vaultDirs from the workspace
+// file in dir (implements `lockenv ws rm`). Every directory must be
+// registered; the vaults themselves are left alone.
+func RemoveWorkspaceVaults(dir string, vaultDirs []string) error {
+	vaults, err := ReadWorkspace(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, vaultDir := range vaultDirs {
+		vault := filepath.Clean(vaultDir)
+		if !filepath.IsAbs(vault) {
+			vault = filepath.ToSlash(vault)
+		}
+		i := slices.Index(vaults, vault)
+		if i < 0 {
+			return fmt.Errorf("%s: not in the workspace", vaultDir)
+		}
+		vaults = slices.Delete(vaults, i, i+1)
+	}
+	return writeWorkspace(dir, vaults)
+}
+
+// writeWorkspace replaces the workspace file in dir with vaults
+func writeWorkspace(dir string, vaults []string) error {
+	var b strings.Builder
+	b.WriteString(workspaceHeader)
+	for _, vault := range vaults {
+		b.WriteString(vault)
+		b.WriteString("\n")
+	}
+	return WriteFileAtomic(filepath.Join(dir, WorkspaceFile), []byte(b.String()), 0644)
+}
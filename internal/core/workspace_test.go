@@ -0,0 +1,60 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dev")
+	for _, vault := range []string{"dev", "service-a", "service-b", "empty"} {
+		if err := os.MkdirAll(filepath.Join(root, vault), 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+	}
+	for _, vault := range []string{"service-a", "service-b"} {
+		if err := os.WriteFile(filepath.Join(root, vault, LockEnvFile), nil, 0644); err != nil {
+			t.Fatalf("Failed to create test vault: %v", err)
+		}
+	}
+
+	if vaults, err := ReadWorkspace(dir); err != nil || len(vaults) != 0 {
+		t.Fatalf("Expected an empty workspace, got %v, %v", vaults, err)
+	}
+
+	added, err := AddWorkspaceVaults(dir, []string{"../service-a", "../service-b/", "../service-a"})
+	if err != nil {
+		t.Fatalf("AddWorkspaceVaults failed: %v", err)
+	}
+	if !slices.Equal(added, []string{"../service-a", "../service-b"}) {
+		t.Errorf("Expected both vaults added once, got %v", added)
+	}
+	if _, err := AddWorkspaceVaults(dir, []string{"../empty"}); err == nil || !strings.Contains(err.Error(), "no vault found") {
+		t.Errorf("Expected a directory without a vault to be refused, got %v", err)
+	}
+
+	vaults, err := ReadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ReadWorkspace failed: %v", err)
+	}
+	if !slices.Equal(vaults, []string{"../service-a", "../service-b"}) {
+		t.Errorf("Unexpected workspace: %v", vaults)
+	}
+	if got := WorkspaceVaultDir(dir, vaults[0]); got != filepath.Join(root, "service-a") {
+		t.Errorf("WorkspaceVaultDir = %s, want %s", got, filepath.Join(root, "service-a"))
+	}
+
+	if err := RemoveWorkspaceVaults(dir, []string{"../service-a"}); err != nil {
+		t.Fatalf("RemoveWorkspaceVaults failed: %v", err)
+	}
+	if err := RemoveWorkspaceVaults(dir, []string{"../service-a"}); err == nil {
+		t.Error("Expected removing an unregistered vault to fail")
+	}
+	if vaults, _ := ReadWorkspace(dir); !slices.Equal(vaults, []string{"../service-b"}) {
+		t.Errorf("Expected only service-b left, got %v", vaults)
+	}
+}
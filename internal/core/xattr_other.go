@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package core
+
+// readXattrs returns nil where extended attributes are not supported
+func readXattrs(_ string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+// writeXattrs is a no-op where extended attributes are not supported
+func writeXattrs(_ string, _ map[string][]byte) error {
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build linux || darwin
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattrs returns the extended attributes of a file, or nil if it has
+// none or the filesystem does not support them
+func readXattrs(path string) (map[string][]byte, error) {
+	size, err := unix.Listxattr(path, nil)
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	}
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	names := make([]byte, size)
+	size, err = unix.Listxattr(path, names)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, name := range strings.Split(string(names[:size]), "\x00") {
+		if name == "" {
+			continue
+		}
+		n, err := unix.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, n)
+		n, err = unix.Getxattr(path, name, value)
+		if err != nil {
+			return nil, err
+		}
+		attrs[name] = value[:n]
+	}
+	return attrs, nil
+}
+
+// writeXattrs sets the given extended attributes on a file, continuing past
+// failures (e.g. security.* names without privileges)
+func writeXattrs(path string, attrs map[string][]byte) error {
+	var errs []error
+	for name, value := range attrs {
+		if err := unix.Setxattr(path, name, value, 0); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -1,14 +1,19 @@
 package crypto
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/illarion/lockenv/internal/logging"
 	"golang.org/x/crypto/pbkdf2"
 )
 
@@ -23,6 +28,7 @@ const (
 var (
 	ErrInvalidCiphertext = errors.New("invalid ciphertext")
 	ErrAuthFailed        = errors.New("authentication failed")
+	ErrDestroyed         = errors.New("encryptor used after Destroy")
 )
 
 // KDF handles key derivation from passwords
@@ -46,25 +52,98 @@ func NewKDF() (*KDF, error) {
 
 // DeriveKey derives an encryption key from a password
 func (k *KDF) DeriveKey(password []byte) []byte {
+	start := time.Now()
 	key := pbkdf2.Key(password, k.Salt, k.Iterations, KeySize, sha256.New)
+	logging.Debug("derive key", "kdf", "PBKDF2-SHA256", "iterations", k.Iterations, "duration", time.Since(start))
 	return key
 }
 
+// kdfCheckInterval is how many PBKDF2 iterations DeriveKeyContext runs
+// between checks for cancellation
+const kdfCheckInterval = 10_000
+
+// DeriveKeyContext derives the same key as DeriveKey, returning ctx.Err()
+// if ctx is done before the derivation finishes, so a slow KDF can be
+// interrupted
+func (k *KDF) DeriveKeyContext(ctx context.Context, password []byte) ([]byte, error) {
+	start := time.Now()
+
+	// PBKDF2-HMAC-SHA256 (RFC 8018); KeySize is a single SHA-256 block
+	prf := hmac.New(sha256.New, password)
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], 1)
+	prf.Write(k.Salt)
+	prf.Write(index[:])
+	u := prf.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < k.Iterations; i++ {
+		if i%kdfCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				ClearBytes(u)
+				ClearBytes(key)
+				return nil, err
+			}
+		}
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		subtle.XORBytes(key, key, u)
+	}
+	ClearBytes(u)
+
+	logging.Debug("derive key", "kdf", "PBKDF2-SHA256", "iterations", k.Iterations, "duration", time.Since(start))
+	return key[:KeySize], nil
+}
+
+// MaxCalibratedIters caps CalibrateIterations, so a vault set up on a busy
+// machine stays practical to open elsewhere
+const MaxCalibratedIters = 20_000_000
+
+// CalibrateIterations returns the PBKDF2 iterations that take about target
+// to derive a key on this machine, at least DefaultIters
+func CalibrateIterations(target time.Duration) int {
+	const probe = 50_000
+	salt := make([]byte, SaltSize)
+	start := time.Now()
+	pbkdf2.Key([]byte("calibration"), salt, probe, KeySize, sha256.New)
+	elapsed := time.Since(start)
+	if elapsed <= 0 {
+		return DefaultIters
+	}
+	iters := float64(target) / float64(elapsed) * probe
+	logging.Debug("calibrate kdf", "target", target, "iterations", int(iters))
+	return int(min(max(iters, DefaultIters), MaxCalibratedIters))
+}
+
 // Encryptor provides authenticated encryption
 type Encryptor struct {
-	key []byte
+	key  *SecureBuffer // Copy of the key in locked memory
+	orig []byte        // Key passed to NewEncryptor, cleared by Destroy
+
+	subkeys   map[string]*Encryptor // Per-purpose encryptors, see NewSubkeyEncryptor
+	segmented bool                  // Encrypt and Decrypt use segmented ciphertexts
 }
 
-// NewEncryptor creates a new encryptor with the given key
+// NewEncryptor creates a new encryptor with the given key.
+// The key is copied into locked memory; Destroy clears both copies.
 func NewEncryptor(key []byte) *Encryptor {
 	return &Encryptor{
-		key: key,
+		key:  SecureCopy(key),
+		orig: key,
 	}
 }
 
-// Encrypt encrypts plaintext using AES-256-GCM
-func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
+// newGCM creates the AES-256-GCM cipher for the encryptor's key
+func (e *Encryptor) newGCM() (cipher.AEAD, error) {
+	if e.key.closed {
+		return nil, ErrDestroyed
+	}
+	return newGCMWithKey(e.key.Borrow())
+}
+
+// newGCMWithKey creates the AES-256-GCM cipher for key
+func newGCMWithKey(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
@@ -73,6 +152,31 @@ func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
+	return gcm, nil
+}
+
+// Encrypt encrypts plaintext using AES-256-GCM, as a segmented ciphertext
+// for a segmented encryptor
+func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	return e.EncryptContext(context.Background(), plaintext)
+}
+
+// EncryptContext encrypts like Encrypt. A segmented encryptor checks ctx
+// between segments, so encrypting a large file can be interrupted.
+func (e *Encryptor) EncryptContext(ctx context.Context, plaintext []byte) ([]byte, error) {
+	if e.segmented {
+		return e.encryptStream(ctx, plaintext, nil)
+	}
+	return e.EncryptWithAAD(plaintext, nil)
+}
+
+// EncryptWithAAD encrypts plaintext using AES-256-GCM, authenticating
+// additional data that must be supplied again on decryption
+func (e *Encryptor) EncryptWithAAD(plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := e.newGCM()
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate random nonce
 	nonce := make([]byte, NonceSize)
@@ -81,7 +185,7 @@ func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
 	}
 
 	// Encrypt and authenticate
-	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext := gcm.Seal(nil, nonce, plaintext, additionalData)
 
 	// Prepend nonce to ciphertext
 	result := make([]byte, NonceSize+len(ciphertext))
@@ -91,20 +195,55 @@ func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
 	return result, nil
 }
 
-// Decrypt decrypts ciphertext using AES-256-GCM
-func (e *Encryptor) Decrypt(ciphertext []byte) ([]byte, error) {
-	if len(ciphertext) < NonceSize+TagSize {
-		return nil, ErrInvalidCiphertext
+// EncryptDeterministic encrypts plaintext like Encrypt, but derives the
+// nonce from an HMAC of the plaintext instead of drawing it at random (a
+// synthetic IV, as in SIV mode). The same plaintext under the same key always
+// yields the same ciphertext, which reveals whether two plaintexts are equal
+// and nothing else. Decrypt reads the result like any other ciphertext. A
+// segmented encryptor derives the salt of its segment key the same way.
+func (e *Encryptor) EncryptDeterministic(plaintext []byte) ([]byte, error) {
+	return e.EncryptDeterministicContext(context.Background(), plaintext)
+}
+
+// EncryptDeterministicContext encrypts like EncryptDeterministic, checking
+// ctx between segments like EncryptContext
+func (e *Encryptor) EncryptDeterministicContext(ctx context.Context, plaintext []byte) ([]byte, error) {
+	// Synthetic nonce from a subkey that is never used for encryption
+	siv := e.keyedHash("lockenv-siv", plaintext)
+	if e.segmented {
+		return e.encryptStream(ctx, plaintext, siv[:streamSaltSize])
 	}
+	nonce := siv[:NonceSize]
 
-	block, err := aes.NewCipher(e.key)
+	gcm, err := e.newGCM()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	result := make([]byte, NonceSize, NonceSize+len(plaintext)+TagSize)
+	copy(result, nonce)
+	return gcm.Seal(result, nonce, plaintext, nil), nil
+}
+
+// Decrypt decrypts ciphertext using AES-256-GCM, as a segmented ciphertext
+// for a segmented encryptor
+func (e *Encryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	if e.segmented {
+		return e.decryptStreamBytes(context.Background(), ciphertext)
+	}
+	return e.DecryptWithAAD(ciphertext, nil)
+}
+
+// DecryptWithAAD decrypts ciphertext using AES-256-GCM, verifying
+// the additional data passed to EncryptWithAAD
+func (e *Encryptor) DecryptWithAAD(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < NonceSize+TagSize {
+		return nil, ErrInvalidCiphertext
+	}
+
+	gcm, err := e.newGCM()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	// Extract nonce
@@ -112,7 +251,7 @@ func (e *Encryptor) Decrypt(ciphertext []byte) ([]byte, error) {
 	ciphertext = ciphertext[NonceSize:]
 
 	// Decrypt and verify
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, ErrAuthFailed
 	}
@@ -120,9 +259,63 @@ func (e *Encryptor) Decrypt(ciphertext []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-// Destroy clears the encryptor's key from memory
+// DecryptSecure decrypts ciphertext like Decrypt, writing the plaintext into
+// a SecureBuffer. The caller must Close the returned buffer.
+func (e *Encryptor) DecryptSecure(ciphertext []byte) (*SecureBuffer, error) {
+	return e.DecryptSecureContext(context.Background(), ciphertext)
+}
+
+// DecryptSecureContext decrypts like DecryptSecure. A segmented encryptor
+// checks ctx between segments, so decrypting a large file can be
+// interrupted.
+func (e *Encryptor) DecryptSecureContext(ctx context.Context, ciphertext []byte) (*SecureBuffer, error) {
+	if e.segmented {
+		return e.decryptStreamSecure(ctx, ciphertext)
+	}
+	if len(ciphertext) < NonceSize+TagSize {
+		return nil, ErrInvalidCiphertext
+	}
+
+	gcm, err := e.newGCM()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := NewSecureBuffer(len(ciphertext) - NonceSize - TagSize)
+	if _, err := gcm.Open(plaintext.Borrow()[:0], ciphertext[:NonceSize], ciphertext[NonceSize:], nil); err != nil {
+		plaintext.Close()
+		return nil, ErrAuthFailed
+	}
+	return plaintext, nil
+}
+
+// MAC returns an HMAC-SHA256 of data under a subkey of the encryptor's key,
+// so the MAC key is never used for encryption
+func (e *Encryptor) MAC(data []byte) []byte {
+	return e.keyedHash("lockenv-mac", data)
+}
+
+// keyedHash returns an HMAC-SHA256 of data under the subkey of the
+// encryptor's key named by label
+func (e *Encryptor) keyedHash(label string, data []byte) []byte {
+	sub := hmac.New(sha256.New, e.key.Borrow())
+	sub.Write([]byte(label))
+	subKey := sub.Sum(nil)
+	defer ClearBytes(subKey)
+
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Destroy clears the encryptor's key from memory. Encrypting or decrypting
+// afterwards fails with ErrDestroyed.
 func (e *Encryptor) Destroy() {
-	ClearBytes(e.key)
+	for _, sub := range e.subkeys {
+		sub.Destroy()
+	}
+	e.key.Close()
+	ClearBytes(e.orig)
 }
 
 // ClearBytes securely clears a byte slice
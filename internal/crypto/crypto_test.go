@@ -0,0 +1,26 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDeriveKeyContext(t *testing.T) {
+	kdf := &KDF{Salt: bytes.Repeat([]byte{7}, SaltSize), Iterations: 3*kdfCheckInterval + 1}
+	want := kdf.DeriveKey([]byte("password"))
+	got, err := kdf.DeriveKeyContext(context.Background(), []byte("password"))
+	if err != nil {
+		t.Fatalf("DeriveKeyContext failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("DeriveKeyContext = %x, want %x", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := kdf.DeriveKeyContext(ctx, []byte("password")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@
 //   - 32-byte key derived from password via PBKDF2
 //   - 12-byte random nonce per encryption operation
 //   - Authenticated encryption prevents tampering
+//   - EncryptDeterministic derives the nonce from an HMAC of the plaintext
+//     instead, so equal plaintexts give equal ciphertexts
 //
 // Key derivation uses PBKDF2-HMAC-SHA256 with:
 //   - 32-byte random salt (stored unencrypted)
@@ -12,4 +14,9 @@
 // Memory safety:
 //   - Use ClearBytes() to zero sensitive data after use
 //   - Call Encryptor.Destroy() when done with encryption operations
+//   - Keys, passwords and decrypted file contents live in a SecureBuffer,
+//     which is mlocked and excluded from core dumps where the platform
+//     supports it, and wiped on Close
+//   - SetLeakHandler() lets tests detect SecureBuffers that were never closed
+//   - DisableCoreDumps() keeps crashes from writing secrets to disk
 package crypto
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// SecureBuffer owns secret bytes (keys, passwords, file contents) and wipes
+// them when closed. Buffers allocated by NewSecureBuffer or SecureCopy live in
+// memory that is locked against swapping and excluded from core dumps where
+// the platform allows it. Locking is best effort: if it fails (e.g.
+// RLIMIT_MEMLOCK is exhausted), the buffer falls back to ordinary memory and
+// still works. Locked memory is not managed by the garbage collector, so every
+// buffer must be released with Close.
+type SecureBuffer struct {
+	buf    []byte
+	mapped bool   // buf came from allocLocked and must be released with freeLocked
+	closed bool   // Close has been called
+	site   string // Allocation site, recorded while leak detection is enabled
+}
+
+// allocSecure and freeSecure allocate and release locked memory. Tests
+// replace them to exercise the fallback to ordinary memory.
+var (
+	allocSecure = allocLocked
+	freeSecure  = freeLocked
+)
+
+// leakHandler is called for every SecureBuffer the garbage collector reclaims
+// without it having been closed
+var leakHandler atomic.Pointer[func(site string)]
+
+// SetLeakHandler enables leak detection for SecureBuffers created from now on.
+// fn is called from a finalizer with the allocation site of any buffer that
+// becomes unreachable without being closed. Intended for tests; passing nil
+// disables detection.
+func SetLeakHandler(fn func(site string)) {
+	if fn == nil {
+		leakHandler.Store(nil)
+		return
+	}
+	leakHandler.Store(&fn)
+}
+
+// newSecureBuffer wraps buf and registers it for leak detection if enabled
+func newSecureBuffer(buf []byte, mapped bool) *SecureBuffer {
+	b := &SecureBuffer{buf: buf, mapped: mapped}
+	if leakHandler.Load() != nil {
+		if _, file, line, ok := runtime.Caller(2); ok {
+			b.site = fmt.Sprintf("%s:%d", file, line)
+		}
+		runtime.SetFinalizer(b, checkLeak)
+	}
+	return b
+}
+
+// checkLeak reports b to the leak handler if it was never closed
+func checkLeak(b *SecureBuffer) {
+	if b.closed {
+		return
+	}
+	if fn := leakHandler.Load(); fn != nil {
+		(*fn)(b.site)
+	}
+}
+
+// NewSecureBuffer allocates a zeroed buffer of size bytes in locked memory
+func NewSecureBuffer(size int) *SecureBuffer {
+	if size > 0 {
+		if buf, ok := allocSecure(size); ok {
+			return newSecureBuffer(buf, true)
+		}
+	}
+	return newSecureBuffer(make([]byte, size), false)
+}
+
+// SecureCopy returns a SecureBuffer in locked memory holding a copy of data.
+// data itself is left untouched.
+func SecureCopy(data []byte) *SecureBuffer {
+	if len(data) > 0 {
+		if buf, ok := allocSecure(len(data)); ok {
+			copy(buf, data)
+			return newSecureBuffer(buf, true)
+		}
+	}
+	return newSecureBuffer(append([]byte(nil), data...), false)
+}
+
+// AdoptSecure takes ownership of data, which is wiped in place on Close. Use
+// it for secrets that were already allocated elsewhere, such as the result of
+// os.ReadFile or an editor merge.
+func AdoptSecure(data []byte) *SecureBuffer {
+	return newSecureBuffer(data, false)
+}
+
+// Borrow returns the buffer's contents without copying. The slice is only
+// valid until Close and must not be retained past it.
+func (b *SecureBuffer) Borrow() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.buf
+}
+
+// Len returns the number of bytes held by the buffer
+func (b *SecureBuffer) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.buf)
+}
+
+// Close wipes the buffer and releases its memory. It is safe to call more
+// than once and on a nil buffer.
+func (b *SecureBuffer) Close() {
+	if b == nil || b.closed {
+		return
+	}
+	ClearBytes(b.buf)
+	if b.mapped {
+		freeSecure(b.buf)
+	}
+	b.buf = nil
+	b.closed = true
+}
+
+// DisableCoreDumps stops the process from writing core dumps, which could
+// contain keys or decrypted files. Returns an error if the platform supports
+// it but the call failed.
+func DisableCoreDumps() error {
+	return disableCoreDumps()
+}
@@ -0,0 +1,11 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package crypto
+
+// excludeFromDump is a no-op: there is no portable MADV_DONTDUMP here, and
+// disableCoreDumps already sets RLIMIT_CORE to zero
+func excludeFromDump(_ []byte) {}
+
+func setNotDumpable() error {
+	return nil
+}
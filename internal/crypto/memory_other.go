@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package crypto
+
+// allocLocked is unsupported here; SecureBuffer falls back to heap memory
+func allocLocked(_ int) ([]byte, bool) {
+	return nil, false
+}
+
+func freeLocked(_ []byte) {}
+
+func disableCoreDumps() error {
+	return nil
+}
@@ -0,0 +1,213 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// withoutLockedMemory makes allocations fall back to ordinary memory, as on
+// platforms without mmap or once mapping fails, for the rest of the test
+func withoutLockedMemory(t *testing.T) {
+	t.Helper()
+	alloc := allocSecure
+	allocSecure = func(int) ([]byte, bool) { return nil, false }
+	t.Cleanup(func() { allocSecure = alloc })
+}
+
+// recordFrees replaces locked memory with heap memory whose contents are
+// recorded when freed, so a test can check what Close leaves behind
+// without touching unmapped memory
+func recordFrees(t *testing.T) *[][]byte {
+	t.Helper()
+	var freed [][]byte
+	alloc, free := allocSecure, freeSecure
+	allocSecure = func(size int) ([]byte, bool) { return make([]byte, size), true }
+	freeSecure = func(buf []byte) { freed = append(freed, bytes.Clone(buf)) }
+	t.Cleanup(func() { allocSecure, freeSecure = alloc, free })
+	return &freed
+}
+
+func TestSecureBuffer_Locked(t *testing.T) {
+	data := []byte("secret")
+	buf := SecureCopy(data)
+	if string(buf.Borrow()) != "secret" || buf.Len() != len(data) {
+		t.Fatalf("SecureCopy = %q, want %q", buf.Borrow(), "secret")
+	}
+	buf.Borrow()[0] = 'S'
+	if string(data) != "secret" {
+		t.Errorf("SecureCopy aliases its input: %q", data)
+	}
+	buf.Close()
+	buf.Close() // Idempotent
+	if buf.Borrow() != nil || buf.Len() != 0 {
+		t.Errorf("Expected an empty buffer after Close, got %q", buf.Borrow())
+	}
+
+	zeroed := NewSecureBuffer(32)
+	defer zeroed.Close()
+	if !bytes.Equal(zeroed.Borrow(), make([]byte, 32)) {
+		t.Errorf("NewSecureBuffer = %x, want zeros", zeroed.Borrow())
+	}
+
+	var none *SecureBuffer
+	none.Close()
+	if none.Borrow() != nil || none.Len() != 0 {
+		t.Error("Expected a nil buffer to be empty")
+	}
+}
+
+func TestSecureBuffer_CloseWipesLocked(t *testing.T) {
+	freed := recordFrees(t)
+
+	buf := SecureCopy([]byte("secret"))
+	if !buf.mapped {
+		t.Fatal("Expected the buffer in locked memory")
+	}
+	buf.Close()
+	buf.Close()
+	if len(*freed) != 1 {
+		t.Fatalf("Expected the memory released once, got %d", len(*freed))
+	}
+	if !bytes.Equal((*freed)[0], make([]byte, len("secret"))) {
+		t.Errorf("Memory released without being wiped: %q", (*freed)[0])
+	}
+}
+
+func TestSecureBuffer_Fallback(t *testing.T) {
+	withoutLockedMemory(t)
+
+	buf := NewSecureBuffer(16)
+	if buf.mapped || buf.Len() != 16 || !bytes.Equal(buf.Borrow(), make([]byte, 16)) {
+		t.Fatalf("NewSecureBuffer = %x (mapped %v), want 16 zero bytes on the heap", buf.Borrow(), buf.mapped)
+	}
+	buf.Close()
+
+	data := []byte("secret")
+	copied := SecureCopy(data)
+	if copied.mapped || string(copied.Borrow()) != "secret" {
+		t.Fatalf("SecureCopy = %q (mapped %v), want %q on the heap", copied.Borrow(), copied.mapped, "secret")
+	}
+	held := copied.Borrow()
+	copied.Close()
+	if !bytes.Equal(held, make([]byte, len(data))) {
+		t.Errorf("Heap copy not wiped: %q", held)
+	}
+	if string(data) != "secret" {
+		t.Errorf("SecureCopy wiped its input: %q", data)
+	}
+
+	// Encryption works the same without locked memory
+	enc := NewEncryptor(make([]byte, KeySize))
+	defer enc.Destroy()
+	ciphertext, err := enc.Encrypt([]byte("A=1"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if plain, err := enc.Decrypt(ciphertext); err != nil || string(plain) != "A=1" {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestEncryptor_Destroy(t *testing.T) {
+	freed := recordFrees(t)
+
+	key := bytes.Repeat([]byte{7}, KeySize)
+	enc, err := NewSubkeyEncryptor(key)
+	if err != nil {
+		t.Fatalf("NewSubkeyEncryptor failed: %v", err)
+	}
+	blobs := enc.For(PurposeBlobs)
+	metadata := enc.For(PurposeMetadata)
+	sealed, err := metadata.Encrypt([]byte("A=1"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	segmented, err := blobs.Encrypt([]byte("A=1"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	enc.Destroy()
+	enc.Destroy() // Idempotent
+
+	if !bytes.Equal(key, make([]byte, KeySize)) {
+		t.Errorf("Key passed to NewSubkeyEncryptor not wiped: %x", key)
+	}
+	// The key and one subkey per purpose
+	if len(*freed) != 1+len(subkeyPurposes) {
+		t.Fatalf("Expected %d keys released, got %d", 1+len(subkeyPurposes), len(*freed))
+	}
+	for _, buf := range *freed {
+		if !bytes.Equal(buf, make([]byte, KeySize)) {
+			t.Errorf("Key released without being wiped: %x", buf)
+		}
+	}
+
+	if _, err := metadata.Encrypt([]byte("A=1")); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("Encrypt: expected ErrDestroyed, got %v", err)
+	}
+	if _, err := metadata.Decrypt(sealed); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("Decrypt: expected ErrDestroyed, got %v", err)
+	}
+	if _, err := metadata.DecryptSecure(sealed); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("DecryptSecure: expected ErrDestroyed, got %v", err)
+	}
+	if _, err := metadata.EncryptDeterministic([]byte("A=1")); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("EncryptDeterministic: expected ErrDestroyed, got %v", err)
+	}
+	if _, err := blobs.Encrypt([]byte("A=1")); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("segmented Encrypt: expected ErrDestroyed, got %v", err)
+	}
+	if _, err := blobs.Decrypt(segmented); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("segmented Decrypt: expected ErrDestroyed, got %v", err)
+	}
+	ctx := context.Background()
+	if _, err := blobs.NewEncryptReader(ctx, bytes.NewReader(nil)); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("NewEncryptReader: expected ErrDestroyed, got %v", err)
+	}
+	if _, err := blobs.NewDecryptReader(ctx, bytes.NewReader(segmented)); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("NewDecryptReader: expected ErrDestroyed, got %v", err)
+	}
+}
+
+func TestStreamReaders_ReadAfterClose(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	ctx := context.Background()
+	ciphertext, err := enc.Encrypt(bytes.Repeat([]byte{'z'}, 2*SegmentSize))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	er, err := enc.NewEncryptReader(ctx, bytes.NewReader(make([]byte, 2*SegmentSize)))
+	if err != nil {
+		t.Fatalf("NewEncryptReader failed: %v", err)
+	}
+	if _, err := io.ReadFull(er, make([]byte, streamSaltSize+1)); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	er.Close()
+	if _, err := er.Read(make([]byte, 1)); !errors.Is(err, errStreamClosed) {
+		t.Errorf("Encrypt reader: expected errStreamClosed, got %v", err)
+	}
+
+	dr, err := enc.NewDecryptReader(ctx, bytes.NewReader(ciphertext))
+	if err != nil {
+		t.Fatalf("NewDecryptReader failed: %v", err)
+	}
+	if _, err := dr.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	dr.Close()
+	if _, err := dr.Read(make([]byte, 1)); !errors.Is(err, errStreamClosed) {
+		t.Errorf("Decrypt reader: expected errStreamClosed, got %v", err)
+	}
+}
+
+func TestDisableCoreDumps(t *testing.T) {
+	if err := DisableCoreDumps(); err != nil {
+		t.Errorf("DisableCoreDumps failed: %v", err)
+	}
+}
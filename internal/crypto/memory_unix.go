@@ -0,0 +1,30 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package crypto
+
+import "golang.org/x/sys/unix"
+
+// allocLocked maps anonymous memory outside the Go heap, so the garbage
+// collector never copies it, and locks it into RAM
+func allocLocked(size int) ([]byte, bool) {
+	buf, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, false
+	}
+	// Best effort: unlocked memory outside the heap is still better than the heap
+	_ = unix.Mlock(buf)
+	excludeFromDump(buf)
+	return buf, true
+}
+
+func freeLocked(buf []byte) {
+	_ = unix.Munlock(buf)
+	_ = unix.Munmap(buf)
+}
+
+func disableCoreDumps() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{}); err != nil {
+		return err
+	}
+	return setNotDumpable()
+}
@@ -0,0 +1,182 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+)
+
+const sshWrapInfo = "lockenv-ssh-ed25519"
+
+var ErrUnsupportedKey = errors.New("only ssh-ed25519 keys are supported")
+
+// SSHRecipient is an ed25519 SSH public key that can unwrap the vault key
+type SSHRecipient struct {
+	PublicKey   ssh.PublicKey
+	Comment     string
+	Fingerprint string
+}
+
+// ParseSSHRecipient parses an authorized_keys formatted ssh-ed25519 public key
+func ParseSSHRecipient(line []byte) (*SSHRecipient, error) {
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH public key: %w", err)
+	}
+	if pub.Type() != ssh.KeyAlgoED25519 {
+		return nil, fmt.Errorf("%w (got %s)", ErrUnsupportedKey, pub.Type())
+	}
+
+	return &SSHRecipient{
+		PublicKey:   pub,
+		Comment:     comment,
+		Fingerprint: ssh.FingerprintSHA256(pub),
+	}, nil
+}
+
+// WrapKey encrypts the vault key to the recipient using an ephemeral X25519
+// exchange with the recipient's ed25519 key converted to Montgomery form.
+// Returns the ephemeral public key and the wrapped key.
+func (r *SSHRecipient) WrapKey(vaultKey []byte) (ephemeral, wrapped []byte, err error) {
+	recipientX, err := ed25519PublicToX25519(r.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ephemeralKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeralKey.ECDH(recipientX)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	defer ClearBytes(shared)
+
+	ephemeral = ephemeralKey.PublicKey().Bytes()
+	wrapKey, err := deriveWrapKey(shared, ephemeral, recipientX.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enc := NewEncryptor(wrapKey)
+	defer enc.Destroy()
+
+	wrapped, err = enc.Encrypt(vaultKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ephemeral, wrapped, nil
+}
+
+// UnwrapSSHKey decrypts a vault key wrapped by SSHRecipient.WrapKey using
+// the matching ed25519 private key.
+// The caller is responsible for calling ClearBytes on the returned key.
+func UnwrapSSHKey(privateKey ed25519.PrivateKey, ephemeral, wrapped []byte) ([]byte, error) {
+	scalar := ed25519PrivateToX25519(privateKey)
+	defer ClearBytes(scalar)
+
+	identity, err := ecdh.X25519().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	ephemeralKey, err := ecdh.X25519().NewPublicKey(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+
+	shared, err := identity.ECDH(ephemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	defer ClearBytes(shared)
+
+	wrapKey, err := deriveWrapKey(shared, ephemeral, identity.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	enc := NewEncryptor(wrapKey)
+	defer enc.Destroy()
+
+	return enc.Decrypt(wrapped)
+}
+
+// deriveWrapKey derives the key-wrapping key from an X25519 shared secret
+func deriveWrapKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	salt := make([]byte, 0, len(ephemeral)+len(recipient))
+	salt = append(salt, ephemeral...)
+	salt = append(salt, recipient...)
+
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(sshWrapInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive wrap key: %w", err)
+	}
+	return key, nil
+}
+
+// curve25519P is the field prime 2^255 - 19
+var curve25519P, _ = new(big.Int).SetString("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed", 16)
+
+// ed25519PublicToX25519 converts an ed25519 public key to its X25519 form
+// using the birational map u = (1 + y) / (1 - y) mod p
+func ed25519PublicToX25519(pub ssh.PublicKey) (*ecdh.PublicKey, error) {
+	cryptoPub, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	edPub, ok := cryptoPub.CryptoPublicKey().(ed25519.PublicKey)
+	if !ok || len(edPub) != ed25519.PublicKeySize {
+		return nil, ErrUnsupportedKey
+	}
+
+	// Decode little-endian y coordinate, dropping the sign bit of x
+	le := make([]byte, 32)
+	copy(le, edPub)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverse(le))
+
+	one := big.NewInt(1)
+	num := new(big.Int).Add(one, y)
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curve25519P)
+	if den.Sign() == 0 {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+	den.ModInverse(den, curve25519P)
+	u := num.Mul(num, den)
+	u.Mod(u, curve25519P)
+
+	out := make([]byte, 32)
+	u.FillBytes(out)
+	return ecdh.X25519().NewPublicKey(reverse(out))
+}
+
+// ed25519PrivateToX25519 converts an ed25519 private key to an X25519 scalar
+func ed25519PrivateToX25519(priv ed25519.PrivateKey) []byte {
+	h := sha512.Sum512(priv.Seed())
+	defer ClearBytes(h[:])
+	scalar := make([]byte, 32)
+	copy(scalar, h[:32])
+	return scalar
+}
+
+// reverse returns a reversed copy of b (for endianness conversion)
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[len(b)-1-i]
+	}
+	return out
+}
@@ -0,0 +1,408 @@
+package crypto
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Segmented ciphertexts split the plaintext into SegmentSize segments that
+// are sealed separately, as in the STREAM construction:
+//
+//	salt (32 bytes) || segment 0 || segment 1 || ... || last segment
+//
+// The segments are sealed under a key derived from the encryptor's key and
+// the salt with HKDF-SHA256, and segment i under the nonce 0 (7 bytes) || i
+// (4 bytes, big endian) || last, where last is 1 for the final segment and 0
+// otherwise. The salt is random for each ciphertext, so every ciphertext has
+// its own key: nonces never repeat under a key, however often a blob is
+// re-encrypted, and segments can't be reordered, dropped or truncated without
+// failing authentication.
+const (
+	SegmentSize    = 64 * 1024 // Plaintext bytes per segment
+	streamSaltSize = 32
+	streamKeyInfo  = "lockenv-segment-key-v1"
+)
+
+// cancelCheckSegments is how many segments are sealed or opened between
+// checks for cancellation (1 MiB of plaintext)
+const cancelCheckSegments = 16
+
+// maxSegments bounds the segment counter, which must not wrap around
+var maxSegments int64 = 1 << 32
+
+// ErrStreamTooLong is returned for plaintexts that need more segments than
+// the nonce counter can number
+var ErrStreamTooLong = errors.New("plaintext too long for a segmented ciphertext")
+
+// ErrNotSegmented is returned by NewEncryptReader and NewDecryptReader for
+// an encryptor that does not use segmented ciphertexts
+var ErrNotSegmented = errors.New("encryptor does not use segmented ciphertexts")
+
+// errStreamClosed is returned by reads after Close
+var errStreamClosed = errors.New("read after close")
+
+// streamNonce returns the nonce of segment i
+func streamNonce(i int, last bool) []byte {
+	nonce := make([]byte, NonceSize)
+	binary.BigEndian.PutUint32(nonce[NonceSize-5:], uint32(i))
+	if last {
+		nonce[NonceSize-1] = 1
+	}
+	return nonce
+}
+
+// newStreamSalt draws the salt of a new segmented ciphertext
+func newStreamSalt() ([]byte, error) {
+	salt := make([]byte, streamSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate stream salt: %w", err)
+	}
+	return salt, nil
+}
+
+// newStreamGCM creates the AES-256-GCM cipher for the segments of the
+// ciphertext with salt, under the key HKDF derives from the encryptor's key
+func (e *Encryptor) newStreamGCM(salt []byte) (cipher.AEAD, error) {
+	if e.key.closed {
+		return nil, ErrDestroyed
+	}
+	key, err := hkdf.Key(sha256.New, e.key.Borrow(), salt, streamKeyInfo, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive segment key: %w", err)
+	}
+	defer ClearBytes(key)
+	return newGCMWithKey(key)
+}
+
+// encryptStream seals plaintext as a segmented ciphertext. The salt is
+// drawn at random unless given, as EncryptDeterministic does. Returns
+// ctx.Err() if ctx is done before all segments are sealed.
+func (e *Encryptor) encryptStream(ctx context.Context, plaintext, salt []byte) ([]byte, error) {
+	segments := max(1, (len(plaintext)+SegmentSize-1)/SegmentSize)
+	if int64(segments) > maxSegments {
+		return nil, ErrStreamTooLong
+	}
+
+	if salt == nil {
+		var err error
+		if salt, err = newStreamSalt(); err != nil {
+			return nil, err
+		}
+	}
+	gcm, err := e.newStreamGCM(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, streamSaltSize, streamSaltSize+len(plaintext)+segments*TagSize)
+	copy(result, salt[:streamSaltSize])
+	for i := range segments {
+		if i%cancelCheckSegments == cancelCheckSegments-1 {
+			if err := ctx.Err(); err != nil {
+				ClearBytes(result)
+				return nil, err
+			}
+		}
+		end := min((i+1)*SegmentSize, len(plaintext))
+		nonce := streamNonce(i, i == segments-1)
+		result = gcm.Seal(result, nonce, plaintext[i*SegmentSize:end], nil)
+	}
+	return result, nil
+}
+
+// streamPlaintextSize returns the plaintext size and segment count of a
+// segmented ciphertext, checking that its length is well formed
+func streamPlaintextSize(ciphertext []byte) (size, segments int, err error) {
+	body := len(ciphertext) - streamSaltSize
+	if body < TagSize {
+		return 0, 0, ErrInvalidCiphertext
+	}
+	segments = (body + SegmentSize + TagSize - 1) / (SegmentSize + TagSize)
+	if int64(segments) > maxSegments {
+		return 0, 0, ErrInvalidCiphertext
+	}
+	// Only the last segment may be short, and it still carries a tag
+	if last := body - (segments-1)*(SegmentSize+TagSize); last < TagSize {
+		return 0, 0, ErrInvalidCiphertext
+	}
+	return body - segments*TagSize, segments, nil
+}
+
+// decryptStream opens a segmented ciphertext into dst, which must hold
+// exactly its plaintext. Returns ctx.Err() if ctx is done before all
+// segments are opened.
+func (e *Encryptor) decryptStream(ctx context.Context, ciphertext, dst []byte, segments int) error {
+	gcm, err := e.newStreamGCM(ciphertext[:streamSaltSize])
+	if err != nil {
+		return err
+	}
+	ciphertext = ciphertext[streamSaltSize:]
+	for i := range segments {
+		if i%cancelCheckSegments == cancelCheckSegments-1 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		end := min(SegmentSize+TagSize, len(ciphertext))
+		nonce := streamNonce(i, i == segments-1)
+		if _, err := gcm.Open(dst[:0], nonce, ciphertext[:end], nil); err != nil {
+			return ErrAuthFailed
+		}
+		dst = dst[end-TagSize:]
+		ciphertext = ciphertext[end:]
+	}
+	return nil
+}
+
+// decryptStreamBytes implements Decrypt for segmented ciphertexts
+func (e *Encryptor) decryptStreamBytes(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	size, segments, err := streamPlaintextSize(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, size)
+	if err := e.decryptStream(ctx, ciphertext, plaintext, segments); err != nil {
+		ClearBytes(plaintext)
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// decryptStreamSecure implements DecryptSecure for segmented ciphertexts
+func (e *Encryptor) decryptStreamSecure(ctx context.Context, ciphertext []byte) (*SecureBuffer, error) {
+	size, segments, err := streamPlaintextSize(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := NewSecureBuffer(size)
+	if err := e.decryptStream(ctx, ciphertext, plaintext.Borrow(), segments); err != nil {
+		plaintext.Close()
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// Segmented reports whether the encryptor uses segmented ciphertexts, which
+// NewEncryptReader and NewDecryptReader process one segment at a time
+func (e *Encryptor) Segmented() bool {
+	return e.segmented
+}
+
+// segmentSource reads a stream in units of a fixed size, reading one byte
+// ahead so the last unit is known as soon as it is read
+type segmentSource struct {
+	r     io.Reader
+	ahead byte // Byte read ahead, valid if carry is set
+	carry bool
+}
+
+// next reads the next unit of up to size bytes into buf, which must hold
+// size+1 bytes, and reports whether it is the last one
+func (s *segmentSource) next(buf []byte, size int) (n int, last bool, err error) {
+	start := 0
+	if s.carry {
+		buf[0] = s.ahead
+		start = 1
+	}
+	n, err = io.ReadFull(s.r, buf[start:size+1])
+	n += start
+	switch err {
+	case nil:
+		s.ahead, s.carry = buf[size], true
+		buf[size] = 0
+		return size, false, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		s.ahead, s.carry = 0, false
+		return n, true, nil
+	default:
+		return 0, false, err
+	}
+}
+
+// encryptReader seals a plaintext stream as a segmented ciphertext, one
+// segment per refill
+type encryptReader struct {
+	ctx    context.Context
+	gcm    cipher.AEAD
+	src    segmentSource
+	buf    *SecureBuffer // Plaintext segment plus the byte read ahead
+	sealed []byte        // Last sealed segment
+	out    []byte        // Ciphertext not yet returned
+	i      int           // Index of the next segment
+	done   bool          // The last segment was sealed
+	err    error
+}
+
+// NewEncryptReader returns a reader of the segmented ciphertext of
+// everything read from plaintext, as EncryptContext would produce it. Only
+// one segment of plaintext is held at a time, in locked memory. Reading
+// returns ctx.Err() once ctx is done. Close wipes the buffered plaintext; it
+// does not close plaintext.
+func (e *Encryptor) NewEncryptReader(ctx context.Context, plaintext io.Reader) (io.ReadCloser, error) {
+	if !e.segmented {
+		return nil, ErrNotSegmented
+	}
+	salt, err := newStreamSalt()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := e.newStreamGCM(salt)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptReader{
+		ctx: ctx,
+		gcm: gcm,
+		src: segmentSource{r: plaintext},
+		buf: NewSecureBuffer(SegmentSize + 1),
+		out: salt,
+	}, nil
+}
+
+// Read implements io.Reader
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.seal()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// seal reads and seals the next segment
+func (r *encryptReader) seal() error {
+	if r.i%cancelCheckSegments == cancelCheckSegments-1 {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if int64(r.i) >= maxSegments {
+		return ErrStreamTooLong
+	}
+	buf := r.buf.Borrow()
+	n, last, err := r.src.next(buf, SegmentSize)
+	if err != nil {
+		return err
+	}
+	r.sealed = r.gcm.Seal(r.sealed[:0], streamNonce(r.i, last), buf[:n], nil)
+	ClearBytes(buf[:n])
+	r.out = r.sealed
+	r.i++
+	r.done = last
+	return nil
+}
+
+// Close wipes the buffered plaintext
+func (r *encryptReader) Close() error {
+	r.buf.Close()
+	r.src.ahead = 0
+	r.out = nil
+	r.err = errStreamClosed
+	return nil
+}
+
+// decryptReader opens a segmented ciphertext stream, one segment per refill
+type decryptReader struct {
+	ctx   context.Context
+	gcm   cipher.AEAD
+	src   segmentSource
+	buf   *SecureBuffer // Sealed segment plus the byte read ahead, opened in place
+	plain []byte        // Plaintext not yet returned, within buf
+	i     int           // Index of the next segment
+	done  bool          // The last segment was opened
+	err   error
+}
+
+// NewDecryptReader returns a reader of the plaintext of the segmented
+// ciphertext read from ciphertext. Segments are opened as they are read and
+// only one is held at a time, in locked memory; plaintext is returned only
+// after its segment authenticated, and a truncated stream fails on the read
+// that reaches its end. Reading returns ctx.Err() once ctx is done. Close
+// wipes the buffered plaintext; it does not close ciphertext.
+func (e *Encryptor) NewDecryptReader(ctx context.Context, ciphertext io.Reader) (io.ReadCloser, error) {
+	if !e.segmented {
+		return nil, ErrNotSegmented
+	}
+	salt := make([]byte, streamSaltSize)
+	if _, err := io.ReadFull(ciphertext, salt); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrInvalidCiphertext
+		}
+		return nil, err
+	}
+	gcm, err := e.newStreamGCM(salt)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		ctx: ctx,
+		gcm: gcm,
+		src: segmentSource{r: ciphertext},
+		buf: NewSecureBuffer(SegmentSize + TagSize + 1),
+	}, nil
+}
+
+// Read implements io.Reader
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.open()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// open reads and opens the next segment
+func (r *decryptReader) open() error {
+	if r.i%cancelCheckSegments == cancelCheckSegments-1 {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if int64(r.i) >= maxSegments {
+		return ErrInvalidCiphertext
+	}
+	buf := r.buf.Borrow()
+	n, last, err := r.src.next(buf, SegmentSize+TagSize)
+	if err != nil {
+		return err
+	}
+	if n < TagSize {
+		return ErrInvalidCiphertext
+	}
+	plain, err := r.gcm.Open(buf[:0], streamNonce(r.i, last), buf[:n], nil)
+	if err != nil {
+		return ErrAuthFailed
+	}
+	r.plain = plain
+	r.i++
+	r.done = last
+	return nil
+}
+
+// Close wipes the buffered plaintext
+func (r *decryptReader) Close() error {
+	r.buf.Close()
+	r.plain = nil
+	r.err = errStreamClosed
+	return nil
+}
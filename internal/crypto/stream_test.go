@@ -0,0 +1,323 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// newBlobEncryptor returns a segmented encryptor under a random key
+func newBlobEncryptor(t *testing.T) *Encryptor {
+	t.Helper()
+	key, err := GenerateRandom(KeySize)
+	if err != nil {
+		t.Fatalf("GenerateRandom failed: %v", err)
+	}
+	enc, err := NewSubkeyEncryptor(key)
+	if err != nil {
+		t.Fatalf("NewSubkeyEncryptor failed: %v", err)
+	}
+	t.Cleanup(enc.Destroy)
+	return enc.For(PurposeBlobs)
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	for _, size := range []int{0, 1, SegmentSize - 1, SegmentSize, SegmentSize + 1, 3*SegmentSize + 5} {
+		plaintext := bytes.Repeat([]byte{'x'}, size)
+		ciphertext, err := enc.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("size %d: Encrypt failed: %v", size, err)
+		}
+		segments := max(1, (size+SegmentSize-1)/SegmentSize)
+		if want := streamSaltSize + size + segments*TagSize; len(ciphertext) != want {
+			t.Errorf("size %d: ciphertext is %d bytes, want %d", size, len(ciphertext), want)
+		}
+
+		got, err := enc.Decrypt(ciphertext)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: Decrypt = %d bytes, %v", size, len(got), err)
+		}
+		secure, err := enc.DecryptSecure(ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: DecryptSecure failed: %v", size, err)
+		}
+		if !bytes.Equal(secure.Borrow(), plaintext) {
+			t.Errorf("size %d: DecryptSecure returned different plaintext", size)
+		}
+		secure.Close()
+	}
+}
+
+func TestStream_Cancel(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	plaintext := make([]byte, 2*cancelCheckSegments*SegmentSize)
+	ciphertext, err := enc.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := enc.EncryptContext(ctx, plaintext); !errors.Is(err, context.Canceled) {
+		t.Errorf("EncryptContext: expected context.Canceled, got %v", err)
+	}
+	if _, err := enc.EncryptDeterministicContext(ctx, plaintext); !errors.Is(err, context.Canceled) {
+		t.Errorf("EncryptDeterministicContext: expected context.Canceled, got %v", err)
+	}
+	if _, err := enc.DecryptSecureContext(ctx, ciphertext); !errors.Is(err, context.Canceled) {
+		t.Errorf("DecryptSecureContext: expected context.Canceled, got %v", err)
+	}
+
+	// Shorter than the check interval, it completes anyway
+	small, err := enc.Encrypt([]byte("A=1"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	plain, err := enc.DecryptSecureContext(ctx, small)
+	if err != nil {
+		t.Fatalf("DecryptSecureContext failed: %v", err)
+	}
+	defer plain.Close()
+	if string(plain.Borrow()) != "A=1" {
+		t.Errorf("Expected A=1, got %q", plain.Borrow())
+	}
+}
+
+func TestStream_NoKeyReuse(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	plaintext := bytes.Repeat([]byte("KEY=value\n"), 3*SegmentSize/10)
+
+	// Re-encrypting the same content, as a relock or key rotation does, must
+	// seal the segments under a fresh key, since their nonces are the same
+	// for every ciphertext
+	salts := make(map[string]bool)
+	segments := make(map[string]bool)
+	for range 20 {
+		ciphertext, err := enc.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		salt := string(ciphertext[:streamSaltSize])
+		if salts[salt] {
+			t.Fatalf("Salt %x reused", salt)
+		}
+		salts[salt] = true
+
+		first := string(ciphertext[streamSaltSize : streamSaltSize+SegmentSize+TagSize])
+		if segments[first] {
+			t.Fatal("Expected the first segment to differ between ciphertexts")
+		}
+		segments[first] = true
+	}
+
+	// The salt is bound to the segments: swapping it in from another
+	// ciphertext fails authentication
+	one, _ := enc.Encrypt(plaintext)
+	two, _ := enc.Encrypt(plaintext)
+	copy(one, two[:streamSaltSize])
+	if _, err := enc.Decrypt(one); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for a swapped salt, got %v", err)
+	}
+}
+
+func TestStream_DeterministicSalt(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	plaintext := bytes.Repeat([]byte{'a'}, 2*SegmentSize)
+
+	first, err := enc.EncryptDeterministic(plaintext)
+	if err != nil {
+		t.Fatalf("EncryptDeterministic failed: %v", err)
+	}
+	second, err := enc.EncryptDeterministic(plaintext)
+	if err != nil {
+		t.Fatalf("EncryptDeterministic failed: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("Expected equal plaintexts to yield equal ciphertexts")
+	}
+
+	changed := bytes.Clone(plaintext)
+	changed[len(changed)-1] = 'b'
+	other, err := enc.EncryptDeterministic(changed)
+	if err != nil {
+		t.Fatalf("EncryptDeterministic failed: %v", err)
+	}
+	if bytes.Equal(first[:streamSaltSize], other[:streamSaltSize]) {
+		t.Error("Expected different plaintexts to use different salts")
+	}
+	if got, err := enc.Decrypt(other); err != nil || !bytes.Equal(got, changed) {
+		t.Errorf("Decrypt failed: %v", err)
+	}
+}
+
+func TestStream_Tampering(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	ciphertext, err := enc.Encrypt(bytes.Repeat([]byte{'z'}, 3*SegmentSize))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	full := SegmentSize + TagSize
+	segment := func(i int) []byte {
+		start := streamSaltSize + i*full
+		return ciphertext[start : start+full]
+	}
+	salt := ciphertext[:streamSaltSize]
+
+	tests := map[string][]byte{
+		"swapped":   bytes.Join([][]byte{salt, segment(1), segment(0), segment(2)}, nil),
+		"truncated": bytes.Join([][]byte{salt, segment(0), segment(1)}, nil),
+		"extended":  bytes.Join([][]byte{salt, segment(0), segment(1), segment(2), segment(2)}, nil),
+		"short":     ciphertext[:streamSaltSize+TagSize-1],
+	}
+	for name, tampered := range tests {
+		if _, err := enc.Decrypt(tampered); err == nil {
+			t.Errorf("%s: expected Decrypt to fail", name)
+		}
+		if _, err := enc.DecryptSecure(tampered); err == nil {
+			t.Errorf("%s: expected DecryptSecure to fail", name)
+		}
+	}
+}
+
+func TestStream_SegmentBound(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	ciphertext, err := enc.Encrypt(make([]byte, 3*SegmentSize))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	saved := maxSegments
+	maxSegments = 2
+	defer func() { maxSegments = saved }()
+
+	if _, err := enc.Encrypt(make([]byte, 2*SegmentSize)); err != nil {
+		t.Errorf("Expected 2 segments to be allowed, got %v", err)
+	}
+	if _, err := enc.Encrypt(make([]byte, 2*SegmentSize+1)); !errors.Is(err, ErrStreamTooLong) {
+		t.Errorf("Expected ErrStreamTooLong, got %v", err)
+	}
+	if _, err := enc.Decrypt(ciphertext); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Expected ErrInvalidCiphertext, got %v", err)
+	}
+}
+
+// countingReader records the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestStreamReaders_RoundTrip(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	ctx := context.Background()
+	for _, size := range []int{0, 1, SegmentSize - 1, SegmentSize, SegmentSize + 1, 3*SegmentSize + 5} {
+		plaintext := make([]byte, size)
+		for i := range plaintext {
+			plaintext[i] = byte(i)
+		}
+
+		er, err := enc.NewEncryptReader(ctx, bytes.NewReader(plaintext))
+		if err != nil {
+			t.Fatalf("size %d: NewEncryptReader failed: %v", size, err)
+		}
+		ciphertext, err := io.ReadAll(er)
+		er.Close()
+		if err != nil {
+			t.Fatalf("size %d: reading ciphertext failed: %v", size, err)
+		}
+		// The stream is the same format Decrypt reads
+		if got, err := enc.Decrypt(ciphertext); err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: Decrypt of streamed ciphertext = %d bytes, %v", size, len(got), err)
+		}
+
+		whole, err := enc.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("size %d: Encrypt failed: %v", size, err)
+		}
+		dr, err := enc.NewDecryptReader(ctx, bytes.NewReader(whole))
+		if err != nil {
+			t.Fatalf("size %d: NewDecryptReader failed: %v", size, err)
+		}
+		got, err := io.ReadAll(dr)
+		dr.Close()
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: streamed Decrypt = %d bytes, %v", size, len(got), err)
+		}
+	}
+}
+
+func TestStreamReaders_SegmentAtATime(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	ctx := context.Background()
+	ciphertext, err := enc.Encrypt(make([]byte, 8*SegmentSize))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	// The first plaintext arrives after one segment and the byte after it
+	src := &countingReader{r: bytes.NewReader(ciphertext)}
+	dr, err := enc.NewDecryptReader(ctx, src)
+	if err != nil {
+		t.Fatalf("NewDecryptReader failed: %v", err)
+	}
+	defer dr.Close()
+	if _, err := dr.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if limit := streamSaltSize + SegmentSize + TagSize + 1; src.n > limit {
+		t.Errorf("Read %d bytes of ciphertext before the first plaintext, want at most %d", src.n, limit)
+	}
+
+	plain := &countingReader{r: bytes.NewReader(make([]byte, 8*SegmentSize))}
+	er, err := enc.NewEncryptReader(ctx, plain)
+	if err != nil {
+		t.Fatalf("NewEncryptReader failed: %v", err)
+	}
+	defer er.Close()
+	if _, err := io.ReadFull(er, make([]byte, streamSaltSize+1)); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if plain.n > SegmentSize+1 {
+		t.Errorf("Read %d bytes of plaintext before the first segment, want at most %d", plain.n, SegmentSize+1)
+	}
+}
+
+func TestStreamReaders_Tampering(t *testing.T) {
+	enc := newBlobEncryptor(t)
+	ctx := context.Background()
+	ciphertext, err := enc.Encrypt(bytes.Repeat([]byte{'z'}, 3*SegmentSize))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	full := SegmentSize + TagSize
+
+	// Dropping the last segment passes the first two, then fails at the end
+	dr, err := enc.NewDecryptReader(ctx, bytes.NewReader(ciphertext[:streamSaltSize+2*full]))
+	if err != nil {
+		t.Fatalf("NewDecryptReader failed: %v", err)
+	}
+	got, err := io.ReadAll(dr)
+	dr.Close()
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Expected ErrAuthFailed for a truncated stream, got %v", err)
+	}
+	if len(got) != SegmentSize {
+		t.Errorf("Expected only the first authenticated segment, got %d bytes", len(got))
+	}
+
+	if _, err := enc.NewDecryptReader(ctx, bytes.NewReader(ciphertext[:streamSaltSize-1])); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Expected ErrInvalidCiphertext for a short stream, got %v", err)
+	}
+	if _, err := NewEncryptor(make([]byte, KeySize)).NewDecryptReader(ctx, bytes.NewReader(ciphertext)); !errors.Is(err, ErrNotSegmented) {
+		t.Errorf("Expected ErrNotSegmented, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package crypto
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Purposes of the subkeys derived from a vault's data key. The names are the
+// HKDF info strings, so changing one makes existing vaults unreadable.
+const (
+	PurposeMetadata = "lockenv-metadata-v1"  // Password check and file metadata
+	PurposeBlobs    = "lockenv-blobs-v1"     // File contents, in segmented ciphertexts
+	PurposeIndexMAC = "lockenv-index-mac-v1" // MAC over the plaintext index
+)
+
+// subkeyPurposes lists the subkeys NewSubkeyEncryptor derives
+var subkeyPurposes = []string{PurposeMetadata, PurposeBlobs, PurposeIndexMAC}
+
+// DeriveSubkey derives the KeySize subkey of master for purpose with
+// HKDF-SHA256
+func DeriveSubkey(master []byte, purpose string) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, master, nil, purpose, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive %s subkey: %w", purpose, err)
+	}
+	return key, nil
+}
+
+// NewSubkeyEncryptor creates an encryptor for key that is never used
+// directly: For returns an encryptor under the subkey of each purpose. The
+// blob encryptor writes segmented ciphertexts. Destroy clears the subkeys
+// with the key.
+func NewSubkeyEncryptor(key []byte) (*Encryptor, error) {
+	e := NewEncryptor(key)
+	e.subkeys = make(map[string]*Encryptor, len(subkeyPurposes))
+	for _, purpose := range subkeyPurposes {
+		subkey, err := DeriveSubkey(e.key.Borrow(), purpose)
+		if err != nil {
+			e.Destroy()
+			return nil, err
+		}
+		e.subkeys[purpose] = NewEncryptor(subkey)
+	}
+	e.subkeys[PurposeBlobs].segmented = true
+	return e, nil
+}
+
+// For returns the encryptor to use for purpose: the subkey's encryptor for
+// one from NewSubkeyEncryptor, otherwise e itself
+func (e *Encryptor) For(purpose string) *Encryptor {
+	if sub := e.subkeys[purpose]; sub != nil {
+		return sub
+	}
+	return e
+}
@@ -0,0 +1,191 @@
+package detect
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// MaxScanSize is the largest file whose contents are examined
+const MaxScanSize = 1 << 20
+
+// secretNames are base name patterns of files that commonly hold secrets
+var secretNames = []string{
+	".env", ".env.*", "*.env",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+	"credentials", "credentials.json", "*service-account*.json",
+	"secrets.json", "secrets.yaml", "secrets.yml", "secrets.toml",
+	".npmrc", ".pypirc", ".netrc", ".pgpass", "*.tfvars",
+}
+
+// exampleNames match templates and public keys that secretNames would
+// otherwise report, e.g. .env.example or id_rsa.pub
+var exampleNames = []string{"*.example", "*.sample", "*.template", "*.dist", "*.pub"}
+
+// SkipDirs hold installed dependencies or tool state rather than a
+// project's own secrets; scans do not descend into them
+var SkipDirs = []string{".git", "node_modules", "vendor", ".venv", "venv", "__pycache__", ".terraform"}
+
+// configExts are the extensions of files whose contents are examined
+var configExts = []string{"", ".env", ".json", ".yaml", ".yml", ".toml", ".ini", ".cfg", ".conf", ".properties", ".xml", ".txt", ".tfvars"}
+
+// tokenFormats are credentials recognizable by their format
+var tokenFormats = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |ENCRYPTED |PGP )?PRIVATE KEY(?: BLOCK)?-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Stripe key", regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{24,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"OpenAI key", regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20}T3BlbkFJ[A-Za-z0-9_-]{20,}`)},
+	{"npm token", regexp.MustCompile(`\bnpm_[A-Za-z0-9]{36}\b`)},
+	{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+// assignment matches KEY=value, key: value and "key": value, capturing the
+// key and the value without quotes
+var assignment = regexp.MustCompile(`^\s*(?:export\s+)?["']?([A-Za-z0-9_.-]+)["']?\s*[:=]\s*["']?([^"'\s,#]+)`)
+
+// secretKey matches key names that usually hold secrets
+var secretKey = regexp.MustCompile(`(?i)(password|passwd|passphrase|pwd|secret|token|api[_-]?key|private[_-]?key|credential|authorization)`)
+
+// digestKey matches key names that hold checksums, which are random-looking
+// but public, e.g. "integrity" in package-lock.json
+var digestKey = regexp.MustCompile(`(?i)(integrity|checksum|digest|hash|sha\d*)$`)
+
+// Entropy thresholds in bits per character. Hex digests of 32+ characters
+// reach about 3.7, random base64 about 5.
+const (
+	secretKeyEntropy = 3.0 // For values of keys named like secrets, at least 8 characters
+	anyKeyEntropy    = 4.2 // For values of any key, at least 20 characters
+)
+
+// Name reports whether a file name (base name or slash path) suggests a
+// secret, returning the pattern it matches
+func Name(name string) (string, bool) {
+	base := path.Base(name)
+	if matchAny(exampleNames, base) != "" {
+		return "", false
+	}
+	pattern := matchAny(secretNames, base)
+	return pattern, pattern != ""
+}
+
+// Examined reports whether Content applies to the file name: configuration
+// files, not source code or documentation
+func Examined(name string) bool {
+	base := path.Base(name)
+	ext := strings.ToLower(path.Ext(base))
+	if strings.HasPrefix(base, ".") && strings.Count(base, ".") == 1 {
+		ext = "" // Dotfiles such as .npmrc
+	}
+	for _, e := range configExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Content reports whether data holds something that looks like a secret,
+// with a reason naming it and its line, e.g. "AWS access key on line 3".
+// Binary data is not examined.
+func Content(data []byte) (string, bool) {
+	if len(data) > MaxScanSize {
+		data = data[:MaxScanSize]
+	}
+	if bytes.IndexByte(data, 0) != -1 {
+		return "", false
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		for _, format := range tokenFormats {
+			if format.re.MatchString(line) {
+				return fmt.Sprintf("%s on line %d", format.name, i+1), true
+			}
+		}
+	}
+	for i, line := range lines {
+		m := assignment.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		key, value := m[1], m[2]
+		if placeholder(value) || digestKey.MatchString(key) {
+			continue
+		}
+		entropy := Entropy(value)
+		if secretKey.MatchString(key) && len(value) >= 8 && entropy >= secretKeyEntropy ||
+			len(value) >= 20 && entropy >= anyKeyEntropy {
+			return fmt.Sprintf("high-entropy value for %s on line %d", key, i+1), true
+		}
+	}
+	return "", false
+}
+
+// placeholder reports whether a value is a reference or template rather
+// than a secret: ${VAR}, {{ var }}, <your-token>, changeme, xxxx
+func placeholder(value string) bool {
+	lower := strings.ToLower(value)
+	if strings.ContainsAny(value, "${}<>") || strings.Contains(lower, "changeme") || strings.Contains(lower, "example") {
+		return true
+	}
+	return strings.Trim(lower, "x*.-_") == ""
+}
+
+// Entropy returns the Shannon entropy of s in bits per character
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// File reports whether a file looks like a secret by its name, or by its
+// contents if it is a configuration file, with the reason shown to the
+// user. read is only called when the contents are needed and may return at
+// most MaxScanSize bytes.
+func File(name string, read func() ([]byte, error)) (string, bool, error) {
+	if pattern, ok := Name(name); ok {
+		return "name matches " + pattern, true, nil
+	}
+	if !Examined(name) {
+		return "", false, nil
+	}
+	data, err := read()
+	if err != nil {
+		return "", false, err
+	}
+	reason, ok := Content(data)
+	return reason, ok, nil
+}
+
+// matchAny returns the first of patterns that name matches, or ""
+func matchAny(patterns []string, name string) string {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern
+		}
+	}
+	return ""
+}
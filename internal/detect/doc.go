@@ -0,0 +1,19 @@
+// Package detect decides whether a file looks like it holds secrets, for
+// lockenv init --import-existing, lockenv scan and the status hint about
+// untracked secrets.
+//
+// Three heuristics are applied, cheapest first:
+//   - file names that commonly hold secrets (.env, *.pem, id_rsa,
+//     credentials.json, ...), except templates and public keys such as
+//     .env.example or id_rsa.pub
+//   - known token formats: private key blocks and the key prefixes of AWS,
+//     GitHub, GitLab, Slack, Stripe, Google and others, plus JWTs
+//   - high-entropy values assigned to keys in KEY=value, key: value and
+//     "key": value lines, with a lower threshold for keys named like
+//     secrets (password, token, api_key, ...)
+//
+// Contents are only examined in configuration-like files (no extension,
+// .json, .yaml, .toml, .ini, .properties, ...) up to MaxScanSize, so source
+// code and documentation with example tokens are not reported. The tests in
+// testdata/ hold a corpus of files that must and must not be reported.
+package detect
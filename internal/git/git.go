@@ -1,8 +1,10 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -24,6 +26,71 @@ func IsGitRepo(workDir string) bool {
 	return err == nil
 }
 
+// TopLevel returns the root directory of the working tree workDir is in
+func TopLevel(workDir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = workDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %s", workDir)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GitDir returns the absolute path of the git directory of the working tree
+// workDir is in. Linked worktrees each have their own.
+func GitDir(workDir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = workDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %s", workDir)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// HooksDir returns the directory git runs hooks from for the repository
+// workDir is in, honoring core.hooksPath
+func HooksDir(workDir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = workDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %s", workDir)
+	}
+
+	dir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workDir, dir)
+	}
+	return dir, nil
+}
+
+// Changed reports whether any of paths differs between the revisions from
+// and to. The paths are relative to workDir.
+func Changed(workDir, from, to string, paths ...string) (bool, error) {
+	if strings.HasPrefix(from, "-") || strings.HasPrefix(to, "-") {
+		return false, fmt.Errorf("invalid revision: %s..%s", from, to)
+	}
+
+	args := append([]string{"diff", "--quiet", from, to, "--"}, paths...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = workDir
+	err := cmd.Run()
+	if err == nil {
+		return false, nil
+	}
+
+	// git diff --quiet exits with 1 if there are differences
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, fmt.Errorf("git diff %s %s: %w", from, to, err)
+}
+
 // IsTracked checks if a file is tracked by git
 func IsTracked(workDir, path string) bool {
 	cmd := exec.Command("git", "ls-files", "--", path)
@@ -47,6 +114,29 @@ func IsIgnored(workDir, path string) bool {
 	return err == nil
 }
 
+// ShowFile returns the contents of a file at the given git revision.
+// The path is relative to workDir.
+func ShowFile(workDir, rev, path string) ([]byte, error) {
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision: %s", rev)
+	}
+
+	cmd := exec.Command("git", "show", rev+":./"+path)
+	cmd.Dir = workDir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git show %s:%s: %s", rev, path, msg)
+	}
+
+	return output, nil
+}
+
 // CheckGitIntegration checks git integration status for lockenv
 func CheckGitIntegration(workDir string, trackedFiles []string) (*GitStatus, error) {
 	status := &GitStatus{}
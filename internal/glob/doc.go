@@ -0,0 +1,12 @@
+// Package glob extends filepath.Glob with ** and brace expansion for
+// lock, unlock and rm patterns.
+//
+// Syntax:
+//   - * matches any characters except /
+//   - ? matches a single character except /
+//   - [abc], [a-z] and [!abc] match character classes
+//   - ** matches any number of directories, including none
+//     (config/**/*.env matches config/a.env and config/x/y/a.env)
+//   - {a,b} expands to each alternative ({dev,prod}.env)
+//   - \ escapes the next character
+package glob
@@ -0,0 +1,230 @@
+package glob
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// metaChars are the characters that make a path segment a pattern
+const metaChars = `*?[{\`
+
+// HasMeta reports whether a pattern contains any glob syntax
+func HasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, metaChars)
+}
+
+// Match reports whether a slash-separated path matches the pattern.
+// Malformed patterns never match.
+func Match(pattern, name string) bool {
+	for _, alt := range ExpandBraces(filepath.ToSlash(pattern)) {
+		re, err := regexp.Compile("^" + Translate(alt) + "$")
+		if err != nil {
+			continue
+		}
+		if re.MatchString(filepath.ToSlash(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Glob returns the names of all files matching the pattern, like
+// filepath.Glob but with ** and brace support. Patterns containing **
+// match files only. Results are sorted.
+func Glob(pattern string) ([]string, error) {
+	var matches []string
+	seen := make(map[string]bool)
+
+	for _, alt := range ExpandBraces(pattern) {
+		var found []string
+		var err error
+		if strings.Contains(alt, "**") {
+			found, err = globStar(alt)
+		} else {
+			found, err = filepath.Glob(alt)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range found {
+			if !seen[m] {
+				seen[m] = true
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// globStar walks the tree below the pattern's fixed prefix and matches every
+// file. Directories are not returned, so "secrets/**" lists the files inside.
+// .git directories are not descended into.
+func globStar(pattern string) ([]string, error) {
+	slashPattern := filepath.ToSlash(pattern)
+	re, err := regexp.Compile("^" + Translate(slashPattern) + "$")
+	if err != nil {
+		return nil, filepath.ErrBadPattern
+	}
+
+	base := filepath.FromSlash(staticPrefix(slashPattern))
+	if _, err := os.Stat(base); err != nil {
+		return nil, nil
+	}
+
+	var matches []string
+	err = filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped, like filepath.Glob
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && path != base {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if re.MatchString(filepath.ToSlash(path)) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// staticPrefix returns the leading directories of a slash-separated pattern
+// that contain no glob syntax
+func staticPrefix(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	var fixed []string
+	for _, seg := range segments[:len(segments)-1] {
+		if HasMeta(seg) {
+			break
+		}
+		fixed = append(fixed, seg)
+	}
+	prefix := strings.Join(fixed, "/")
+	if prefix == "" {
+		if strings.HasPrefix(pattern, "/") {
+			return "/"
+		}
+		return "."
+	}
+	return prefix
+}
+
+// ExpandBraces expands {a,b} alternatives, including nested ones.
+// Braces without a top-level comma are kept literally.
+func ExpandBraces(pattern string) []string {
+	start, end, parts := findBraces(pattern)
+	if start < 0 {
+		return []string{pattern}
+	}
+
+	var result []string
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	for _, part := range parts {
+		result = append(result, ExpandBraces(prefix+part+suffix)...)
+	}
+	return result
+}
+
+// findBraces locates the first brace group with a top-level comma and
+// returns its bounds and alternatives; start is -1 if there is none
+func findBraces(pattern string) (start, end int, parts []string) {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			end, parts := splitBraceGroup(pattern, i)
+			if end < 0 {
+				// Unbalanced brace: treat the rest literally
+				return -1, -1, nil
+			}
+			if len(parts) > 1 {
+				return i, end, parts
+			}
+			// No comma: literal braces, but nested groups may still expand
+		}
+	}
+	return -1, -1, nil
+}
+
+// splitBraceGroup returns the index of the brace closing the group opened at
+// open and the group's top-level alternatives, or -1 if it is unbalanced
+func splitBraceGroup(pattern string, open int) (int, []string) {
+	depth := 0
+	last := open + 1
+	var parts []string
+	for j := open; j < len(pattern); j++ {
+		switch pattern[j] {
+		case '\\':
+			j++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j, append(parts, pattern[last:j])
+			}
+		case ',':
+			if depth == 1 {
+				parts = append(parts, pattern[last:j])
+				last = j + 1
+			}
+		}
+	}
+	return -1, nil
+}
+
+// Translate converts a slash-separated glob (without braces) into a regular
+// expression body. ** matches across directories; * and ? do not.
+func Translate(pattern string) string {
+	var re strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					// "**/" matches zero or more directories
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
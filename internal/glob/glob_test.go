@@ -0,0 +1,121 @@
+package glob
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"a.env", []string{"a.env"}},
+		{"{dev,prod}.env", []string{"dev.env", "prod.env"}},
+		{"config/{a,b}/{x,y}", []string{"config/a/x", "config/a/y", "config/b/x", "config/b/y"}},
+		{"{a,{b,c}}.key", []string{"a.key", "b.key", "c.key"}},
+		{"{literal}.env", []string{"{literal}.env"}},
+		{"unbalanced{a,b", []string{"unbalanced{a,b"}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+	}
+
+	for _, tt := range tests {
+		if got := ExpandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandBraces(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.env", "a.env", true},
+		{"*.env", "dir/a.env", false},
+		{"config/**/*.secret", "config/a.secret", true},
+		{"config/**/*.secret", "config/x/y/a.secret", true},
+		{"config/**/*.secret", "other/a.secret", false},
+		{"**/*.key", "a.key", true},
+		{"**/*.key", "deep/dir/a.key", true},
+		{"secrets/**", "secrets/a/b", true},
+		{"{dev,prod}.env", "prod.env", true},
+		{"{dev,prod}.env", "test.env", false},
+		{"file?.txt", "file1.txt", true},
+		{"[ab].env", "b.env", true},
+		{"[!ab].env", "b.env", false},
+		{".env", ".env", true},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"config/a.secret",
+		"config/x/b.secret",
+		"config/x/y/c.secret",
+		"config/x/plain.txt",
+		"dev.env",
+		"prod.env",
+		".git/objects/x.secret",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	rel := func(paths []string) []string {
+		var result []string
+		for _, p := range paths {
+			r, _ := filepath.Rel(dir, p)
+			result = append(result, filepath.ToSlash(r))
+		}
+		return result
+	}
+
+	matches, err := Glob(filepath.Join(dir, "config", "**", "*.secret"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	want := []string{"config/a.secret", "config/x/b.secret", "config/x/y/c.secret"}
+	if got := rel(matches); !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob(**) = %v, want %v", got, want)
+	}
+
+	matches, err = Glob(filepath.Join(dir, "**", "*.secret"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if got := rel(matches); !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob should skip .git, got %v", got)
+	}
+
+	matches, err = Glob(filepath.Join(dir, "{dev,prod,test}.env"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if got, want := rel(matches), []string{"dev.env", "prod.env"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob(braces) = %v, want %v", got, want)
+	}
+
+	matches, err = Glob(filepath.Join(dir, "missing", "**", "*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("Expected no matches under missing dir, got %v", matches)
+	}
+}
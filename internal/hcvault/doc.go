@@ -0,0 +1,11 @@
+// Package hcvault reads secrets from a HashiCorp Vault KV engine.
+//
+// Only a single field of a single secret is read, using the same environment
+// as the vault CLI:
+//   - VAULT_ADDR for the server address, unless configured explicitly
+//   - VAULT_TOKEN, falling back to the token helper file ~/.vault-token
+//   - VAULT_NAMESPACE for Vault Enterprise namespaces
+//
+// Both KV v1 and KV v2 responses are understood; for KV v2 the path must
+// include the data/ segment (secret/data/myapp), as in the HTTP API.
+package hcvault
@@ -0,0 +1,127 @@
+package hcvault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNoAddress  = errors.New("HashiCorp Vault address not set (configure address or set VAULT_ADDR)")
+	ErrNoToken    = errors.New("HashiCorp Vault token not set (set VAULT_TOKEN or run 'vault login')")
+	ErrNoField    = errors.New("field not found in HashiCorp Vault secret")
+	ErrNotAString = errors.New("HashiCorp Vault secret field is not a string")
+)
+
+// Client reads secrets from one HashiCorp Vault server
+type Client struct {
+	Address   string
+	Token     string
+	Namespace string
+	HTTP      *http.Client
+}
+
+// NewClient returns a client configured from the arguments, falling back to
+// VAULT_ADDR, VAULT_TOKEN (or ~/.vault-token) and VAULT_NAMESPACE
+func NewClient(address, namespace string) (*Client, error) {
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, ErrNoAddress
+	}
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		token = readTokenFile()
+	}
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	return &Client{
+		Address:   strings.TrimRight(address, "/"),
+		Token:     token,
+		Namespace: namespace,
+		HTTP:      http.DefaultClient,
+	}, nil
+}
+
+// readTokenFile returns the token stored by 'vault login', or ""
+func readTokenFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ReadField returns one field of the secret at path.
+// The caller is responsible for clearing the returned value.
+func (c *Client) ReadField(ctx context.Context, path, field string) ([]byte, error) {
+	url := c.Address + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach HashiCorp Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("secret %s not found in HashiCorp Vault", path)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("permission denied reading %s from HashiCorp Vault (check VAULT_TOKEN)", path)
+	default:
+		return nil, fmt.Errorf("failed to read %s from HashiCorp Vault: %s", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid response from HashiCorp Vault: %w", err)
+	}
+
+	fields := body.Data
+	// KV v2 nests the secret under data.data next to data.metadata
+	if nested, ok := body.Data["data"]; ok {
+		if _, hasMeta := body.Data["metadata"]; hasMeta {
+			fields = nil
+			if err := json.Unmarshal(nested, &fields); err != nil {
+				return nil, fmt.Errorf("invalid response from HashiCorp Vault: %w", err)
+			}
+		}
+	}
+
+	raw, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoField, field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, ErrNotAString
+	}
+	return []byte(value), nil
+}
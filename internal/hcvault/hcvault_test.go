@@ -0,0 +1,81 @@
+package hcvault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "tok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/app" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestReadField_KVv2(t *testing.T) {
+	srv := newTestServer(t, `{"data":{"data":{"password":"s3cret"},"metadata":{"version":3}}}`)
+	t.Setenv("VAULT_TOKEN", "tok")
+
+	client, err := NewClient(srv.URL, "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	value, err := client.ReadField(context.Background(), "secret/data/app", "password")
+	if err != nil {
+		t.Fatalf("ReadField failed: %v", err)
+	}
+	if string(value) != "s3cret" {
+		t.Errorf("Expected s3cret, got %q", value)
+	}
+
+	if _, err := client.ReadField(context.Background(), "secret/data/app", "missing"); !errors.Is(err, ErrNoField) {
+		t.Errorf("Expected ErrNoField, got %v", err)
+	}
+	if _, err := client.ReadField(context.Background(), "secret/data/other", "password"); err == nil {
+		t.Error("Expected error for missing secret")
+	}
+}
+
+func TestReadField_KVv1(t *testing.T) {
+	// A v1 secret may itself have a field called "data"
+	srv := newTestServer(t, `{"data":{"data":"x","password":"v1pass"}}`)
+	t.Setenv("VAULT_TOKEN", "tok")
+
+	client, err := NewClient(srv.URL, "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	value, err := client.ReadField(context.Background(), "secret/data/app", "password")
+	if err != nil {
+		t.Fatalf("ReadField failed: %v", err)
+	}
+	if string(value) != "v1pass" {
+		t.Errorf("Expected v1pass, got %q", value)
+	}
+}
+
+func TestNewClient_RequiresAddressAndToken(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := NewClient("", ""); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Expected ErrNoAddress, got %v", err)
+	}
+	if _, err := NewClient("https://vault.example.com", ""); !errors.Is(err, ErrNoToken) {
+		t.Errorf("Expected ErrNoToken, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package ignore implements .lockenvignore matching for directory tracking.
+//
+// Patterns use gitignore syntax:
+//   - Blank lines and lines starting with # are ignored
+//   - A leading ! re-includes a previously excluded path
+//   - A trailing / matches directories only
+//   - Patterns containing a / are anchored to the repository root;
+//     other patterns match a file or directory name at any depth
+//   - * and ? do not cross directory boundaries; ** matches any number
+//     of directories
+//
+// A file inside an ignored directory is always ignored, as in git.
+package ignore
@@ -0,0 +1,112 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/glob"
+)
+
+// FileName is the name of the ignore file at the repository root
+const FileName = ".lockenvignore"
+
+// Matcher decides whether repository paths are ignored
+type Matcher struct {
+	rules []rule
+}
+
+// rule is a single compiled ignore pattern
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Load reads the ignore file from the repository root.
+// A missing file yields a matcher that ignores nothing.
+func Load(repoRoot string) (*Matcher, error) {
+	data, err := os.ReadFile(filepath.Join(repoRoot, FileName))
+	if os.IsNotExist(err) {
+		return &Matcher{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data), nil
+}
+
+// Parse compiles ignore patterns, one per line
+func Parse(data []byte) *Matcher {
+	m := &Matcher{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r rule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// Escaped leading ! or #
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		prefix := "^(?:.*/)?"
+		if anchored {
+			prefix = "^"
+		}
+		re, err := regexp.Compile(prefix + glob.Translate(line) + "$")
+		if err != nil {
+			// Skip malformed patterns like git does
+			continue
+		}
+		r.re = re
+		m.rules = append(m.rules, r)
+	}
+	return m
+}
+
+// Match reports whether a slash-separated path relative to the repository
+// root is ignored. isDir tells whether the path itself is a directory.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	path = strings.Trim(filepath.ToSlash(path), "/")
+
+	// Anything under an ignored directory is ignored
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && m.matchOne(path[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(path, isDir)
+}
+
+// matchOne applies the rules to a single path; the last matching rule wins
+func (m *Matcher) matchOne(path string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(path) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,70 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	m := Parse([]byte(`# comment
+*.bak
+/build/
+logs/
+secrets/**/*.tmp
+!keep.bak
+docs/*.md
+`))
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.bak", false, true},
+		{"deep/dir/a.bak", false, true},
+		{"keep.bak", false, false},
+		{"build", true, true},
+		{"build/out.env", false, true},
+		{"sub/build/out.env", false, false},
+		{"logs", false, false},
+		{"app/logs/today.log", false, true},
+		{"secrets/x.tmp", false, true},
+		{"secrets/a/b/x.tmp", false, true},
+		{"secrets/a/b/x.env", false, false},
+		{"docs/readme.md", false, true},
+		{"docs/sub/readme.md", false, false},
+		{"app.env", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if m.Match("anything", false) {
+		t.Error("Empty matcher should not ignore anything")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !m.Match("secrets/x.tmp", false) {
+		t.Error("Expected *.tmp to be ignored")
+	}
+}
@@ -1,28 +1,88 @@
 package keyring
 
 import (
+	"errors"
+	"time"
+
 	"github.com/zalando/go-keyring"
 )
 
-const serviceName = "lockenv"
+const (
+	serviceName  = "lockenv"
+	expirySuffix = ":expires"
+)
+
+// ErrExpired is returned when a stored password has outlived its TTL
+var ErrExpired = errors.New("keyring password expired")
 
-// SavePassword stores a password in the OS keyring
+// SavePassword stores a password in the OS keyring.
+// An existing expiry set by SavePasswordWithTTL is left unchanged.
 func SavePassword(vaultID string, password string) error {
 	return keyring.Set(serviceName, vaultID, password)
 }
 
-// GetPassword retrieves a password from the OS keyring
+// SavePasswordWithTTL stores a password in the OS keyring that is invalidated
+// after ttl. A ttl of zero stores the password without expiry. The expiry is
+// written first, so a password is never left stored without the TTL it was
+// saved with.
+func SavePasswordWithTTL(vaultID string, password string, ttl time.Duration) error {
+	if ttl <= 0 {
+		if err := keyring.Set(serviceName, vaultID, password); err != nil {
+			return err
+		}
+		if err := keyring.Delete(serviceName, vaultID+expirySuffix); err != nil && err != keyring.ErrNotFound {
+			return err
+		}
+		return nil
+	}
+
+	expires := time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	if err := keyring.Set(serviceName, vaultID+expirySuffix, expires); err != nil {
+		return err
+	}
+	if err := keyring.Set(serviceName, vaultID, password); err != nil {
+		_ = keyring.Delete(serviceName, vaultID+expirySuffix)
+		return err
+	}
+	return nil
+}
+
+// GetPassword retrieves a password from the OS keyring.
+// Expired passwords are removed and ErrExpired is returned.
 func GetPassword(vaultID string) (string, error) {
+	if expires, ok := GetExpiry(vaultID); ok && time.Now().After(expires) {
+		_ = DeletePassword(vaultID)
+		return "", ErrExpired
+	}
 	return keyring.Get(serviceName, vaultID)
 }
 
-// DeletePassword removes a password from the OS keyring
+// GetExpiry returns the time the stored password expires, if a TTL was set.
+// An expiry that cannot be read or parsed is reported as long past, so the
+// password it guards counts as expired.
+func GetExpiry(vaultID string) (time.Time, bool) {
+	value, err := keyring.Get(serviceName, vaultID+expirySuffix)
+	if err == keyring.ErrNotFound {
+		return time.Time{}, false
+	}
+	if err != nil {
+		return time.Time{}, true
+	}
+	expires, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, true
+	}
+	return expires, true
+}
+
+// DeletePassword removes a password (and its expiry) from the OS keyring
 func DeletePassword(vaultID string) error {
+	_ = keyring.Delete(serviceName, vaultID+expirySuffix)
 	return keyring.Delete(serviceName, vaultID)
 }
 
-// HasPassword checks if a password is stored in the keyring
+// HasPassword checks if a non-expired password is stored in the keyring
 func HasPassword(vaultID string) bool {
-	_, err := keyring.Get(serviceName, vaultID)
+	_, err := GetPassword(vaultID)
 	return err == nil
 }
@@ -0,0 +1,90 @@
+package keyring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestSavePasswordWithTTL_NotExpired(t *testing.T) {
+	keyring.MockInit()
+
+	if err := SavePasswordWithTTL("vault1", "secret", time.Hour); err != nil {
+		t.Fatalf("SavePasswordWithTTL failed: %v", err)
+	}
+
+	password, err := GetPassword("vault1")
+	if err != nil {
+		t.Fatalf("GetPassword failed: %v", err)
+	}
+	if password != "secret" {
+		t.Errorf("Expected 'secret', got %q", password)
+	}
+
+	expires, ok := GetExpiry("vault1")
+	if !ok {
+		t.Fatal("Expected expiry to be set")
+	}
+	if time.Until(expires) <= 0 || time.Until(expires) > time.Hour {
+		t.Errorf("Unexpected expiry %v", expires)
+	}
+}
+
+func TestGetPassword_ExpiredIsRemoved(t *testing.T) {
+	keyring.MockInit()
+
+	if err := SavePassword("vault2", "secret"); err != nil {
+		t.Fatalf("SavePassword failed: %v", err)
+	}
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	if err := keyring.Set(serviceName, "vault2"+expirySuffix, past); err != nil {
+		t.Fatalf("Failed to set expiry: %v", err)
+	}
+
+	if _, err := GetPassword("vault2"); err != ErrExpired {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+	if HasPassword("vault2") {
+		t.Error("Expired password should have been removed")
+	}
+	if _, ok := GetExpiry("vault2"); ok {
+		t.Error("Expiry entry should have been removed")
+	}
+}
+
+func TestSavePasswordWithTTL_ZeroClearsExpiry(t *testing.T) {
+	keyring.MockInit()
+
+	if err := SavePasswordWithTTL("vault3", "secret", time.Hour); err != nil {
+		t.Fatalf("SavePasswordWithTTL failed: %v", err)
+	}
+	if err := SavePasswordWithTTL("vault3", "secret", 0); err != nil {
+		t.Fatalf("SavePasswordWithTTL failed: %v", err)
+	}
+
+	if _, ok := GetExpiry("vault3"); ok {
+		t.Error("Expiry should be cleared when saving without TTL")
+	}
+	if !HasPassword("vault3") {
+		t.Error("Password should still be stored")
+	}
+}
+
+func TestGetPassword_UnreadableExpiryIsExpired(t *testing.T) {
+	keyring.MockInit()
+
+	if err := SavePasswordWithTTL("vault4", "secret", time.Hour); err != nil {
+		t.Fatalf("SavePasswordWithTTL failed: %v", err)
+	}
+	if err := keyring.Set(serviceName, "vault4"+expirySuffix, "soon"); err != nil {
+		t.Fatalf("Failed to set expiry: %v", err)
+	}
+
+	if _, err := GetPassword("vault4"); err != ErrExpired {
+		t.Errorf("Expected ErrExpired for an unparsable expiry, got %v", err)
+	}
+	if _, err := keyring.Get(serviceName, "vault4"); err != keyring.ErrNotFound {
+		t.Errorf("Password guarded by an unparsable expiry should have been removed, got %v", err)
+	}
+}
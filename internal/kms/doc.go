@@ -0,0 +1,11 @@
+// Package kms wraps and unwraps the vault key with a cloud KMS key.
+//
+// Keys are identified the same way the cloud CLIs identify them:
+//   - AWS: a key ARN, arn:aws:kms:<region>:<account>:key/<id> (or alias/<name>)
+//   - GCP: a key resource name, projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>
+//
+// The calls go through the installed aws and gcloud CLIs, so any credentials
+// they pick up (environment, profiles, instance or workload identity) work
+// without lockenv linking the cloud SDKs. The vault key is passed on stdin,
+// never on the command line.
+package kms
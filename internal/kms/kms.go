@@ -0,0 +1,129 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+// Providers, stored as the recipient type
+const (
+	ProviderAWS = "aws-kms"
+	ProviderGCP = "gcp-kms"
+)
+
+// ErrUnsupportedKey is returned for key IDs that are neither AWS ARNs nor GCP key names
+var ErrUnsupportedKey = errors.New("unsupported KMS key (use an arn:aws:kms:... ARN or a projects/.../cryptoKeys/... name)")
+
+// Key is a cloud KMS key that wraps the vault key
+type Key struct {
+	Provider string
+	ID       string
+	region   string // AWS only, taken from the ARN
+}
+
+// runCommand runs a CLI with stdin and returns its stdout; replaced in tests
+var runCommand = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// lookPath reports whether a CLI is installed; replaced in tests
+var lookPath = func(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// Parse returns the key for an AWS key ARN or a GCP key resource name
+func Parse(id string) (*Key, error) {
+	id = strings.TrimSpace(id)
+	switch {
+	case strings.HasPrefix(id, "arn:aws:kms:"), strings.HasPrefix(id, "arn:aws-"):
+		parts := strings.SplitN(id, ":", 6)
+		if len(parts) != 6 || parts[2] != "kms" || parts[3] == "" || parts[5] == "" {
+			return nil, fmt.Errorf("invalid AWS KMS key ARN: %s", id)
+		}
+		return &Key{Provider: ProviderAWS, ID: id, region: parts[3]}, nil
+	case strings.HasPrefix(id, "projects/"):
+		parts := strings.Split(id, "/")
+		if len(parts) != 8 || parts[2] != "locations" || parts[4] != "keyRings" || parts[6] != "cryptoKeys" {
+			return nil, fmt.Errorf("invalid GCP KMS key name: %s", id)
+		}
+		return &Key{Provider: ProviderGCP, ID: id}, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// cli returns the command line tool used for the key's provider
+func (k *Key) cli() string {
+	if k.Provider == ProviderGCP {
+		return "gcloud"
+	}
+	return "aws"
+}
+
+// Available reports whether the provider's CLI is installed
+func (k *Key) Available() bool {
+	return lookPath(k.cli())
+}
+
+// Wrap encrypts the vault key with the KMS key
+func (k *Key) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+	if k.Provider == ProviderGCP {
+		return runCommand(ctx, key, "gcloud", "kms", "encrypt",
+			"--key", k.ID, "--plaintext-file", "-", "--ciphertext-file", "-")
+	}
+
+	out, err := runCommand(ctx, key, "aws", "kms", "encrypt",
+		"--key-id", k.ID, "--region", k.region, "--plaintext", "fileb:///dev/stdin",
+		"--output", "text", "--query", "CiphertextBlob")
+	if err != nil {
+		return nil, err
+	}
+	return decodeBase64(out)
+}
+
+// Unwrap decrypts a vault key wrapped by Wrap.
+// The caller is responsible for clearing the returned key.
+func (k *Key) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if k.Provider == ProviderGCP {
+		return runCommand(ctx, wrapped, "gcloud", "kms", "decrypt",
+			"--key", k.ID, "--ciphertext-file", "-", "--plaintext-file", "-")
+	}
+
+	out, err := runCommand(ctx, wrapped, "aws", "kms", "decrypt",
+		"--key-id", k.ID, "--region", k.region, "--ciphertext-blob", "fileb:///dev/stdin",
+		"--output", "text", "--query", "Plaintext")
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(out)
+	return decodeBase64(out)
+}
+
+// decodeBase64 decodes the base64 text printed by the aws CLI
+func decodeBase64(out []byte) ([]byte, error) {
+	text := bytes.TrimSpace(out)
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(data, text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output from aws CLI: %w", err)
+	}
+	return data[:n], nil
+}
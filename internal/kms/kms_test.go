@@ -0,0 +1,81 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	key, err := Parse("arn:aws:kms:eu-west-1:111122223333:key/1234abcd")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if key.Provider != ProviderAWS || key.region != "eu-west-1" {
+		t.Errorf("Unexpected AWS key: %+v", key)
+	}
+
+	key, err = Parse("projects/p/locations/global/keyRings/r/cryptoKeys/k")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if key.Provider != ProviderGCP {
+		t.Errorf("Unexpected GCP key: %+v", key)
+	}
+
+	for _, id := range []string{
+		"arn:aws:s3:::bucket",
+		"arn:aws:kms::111122223333:key/x",
+		"projects/p/locations/global/keyRings/r",
+		"alias/lockenv",
+	} {
+		if _, err := Parse(id); err == nil {
+			t.Errorf("Expected error for %s", id)
+		}
+	}
+}
+
+func TestAWSWrapUnwrap(t *testing.T) {
+	var calls [][]string
+	orig := runCommand
+	defer func() { runCommand = orig }()
+	// Fake aws CLI: "encrypts" by reversing stdin and prints base64 text
+	runCommand = func(_ context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		out := make([]byte, len(stdin))
+		for i, b := range stdin {
+			out[len(stdin)-1-i] = b
+		}
+		return []byte(base64.StdEncoding.EncodeToString(out) + "\n"), nil
+	}
+
+	key, err := Parse("arn:aws:kms:us-east-1:111122223333:key/abcd")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	vaultKey := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := key.Wrap(context.Background(), vaultKey)
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+	unwrapped, err := key.Unwrap(context.Background(), wrapped)
+	if err != nil {
+		t.Fatalf("Unwrap failed: %v", err)
+	}
+	if !bytes.Equal(unwrapped, vaultKey) {
+		t.Errorf("Round trip mismatch: %q", unwrapped)
+	}
+
+	// The key never appears on the command line
+	for _, call := range calls {
+		if strings.Contains(strings.Join(call, " "), string(vaultKey)) {
+			t.Errorf("Vault key passed as argument: %v", call)
+		}
+	}
+	if len(calls) != 2 || calls[0][2] != "encrypt" || calls[1][2] != "decrypt" {
+		t.Errorf("Unexpected CLI calls: %v", calls)
+	}
+}
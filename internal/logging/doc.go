@@ -0,0 +1,14 @@
+// Package logging provides leveled diagnostics written to stderr, so bug
+// reports can include a trace of what lockenv did.
+//
+// Logging is quiet by default. --verbose enables Info messages (files
+// locked, unlocked and skipped) and --debug adds Debug traces (vault opens,
+// transaction commits, key derivation timing, conflict decisions).
+//
+// --log-file appends the same messages as JSON lines to a file, at Info
+// level (Debug with --debug), together with the command run and how it
+// ended, for scripts that keep stdout clean with --quiet.
+//
+// Messages never include passwords, keys or file contents: only paths,
+// sizes, counts and timings.
+package logging
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
+
+// logger is the process-wide logger; it discards everything until Configure
+// is called
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	Reset()
+}
+
+// Reset discards all messages again, as before Configure
+func Reset() {
+	logger.Store(slog.New(slog.DiscardHandler))
+}
+
+// Configure sends messages at level and above to w
+func Configure(w io.Writer, level slog.Level) {
+	logger.Store(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// AddJSON also writes messages at level and above to w as JSON lines, on
+// top of any output set up by Configure (for --log-file)
+func AddJSON(w io.Writer, level slog.Level) {
+	json := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	logger.Store(slog.New(multiHandler{logger.Load().Handler(), json}))
+}
+
+// multiHandler passes each record to every handler that accepts its level
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range m {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// Enabled reports whether messages at level are written
+func Enabled(level slog.Level) bool {
+	return logger.Load().Enabled(context.Background(), level)
+}
+
+// Debug logs a trace message, shown with --debug
+func Debug(msg string, args ...any) {
+	logger.Load().Debug(msg, args...)
+}
+
+// Info logs a progress message, shown with --verbose or --debug
+func Info(msg string, args ...any) {
+	logger.Load().Info(msg, args...)
+}
+
+// Warn logs a problem that does not stop the operation
+func Warn(msg string, args ...any) {
+	logger.Load().Warn(msg, args...)
+}
+
+// Error logs a failure that ends the command
+func Error(msg string, args ...any) {
+	logger.Load().Error(msg, args...)
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDefaultIsQuiet(t *testing.T) {
+	if Enabled(slog.LevelDebug) || Enabled(slog.LevelInfo) || Enabled(slog.LevelWarn) {
+		t.Error("logging should be disabled until Configure is called")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer Reset()
+
+	var buf bytes.Buffer
+	Configure(&buf, slog.LevelInfo)
+	Debug("hidden", "path", ".env")
+	Info("unlocked file", "path", ".env", "size", 12)
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("debug message logged at info level: %q", out)
+	}
+	if !strings.Contains(out, "msg=\"unlocked file\" path=.env size=12") {
+		t.Errorf("unexpected output: %q", out)
+	}
+
+	buf.Reset()
+	Configure(&buf, slog.LevelDebug)
+	Debug("tx commit", "op", "SetSalt")
+	if !strings.Contains(buf.String(), "level=DEBUG msg=\"tx commit\" op=SetSalt") {
+		t.Errorf("unexpected debug output: %q", buf.String())
+	}
+}
+
+func TestAddJSON(t *testing.T) {
+	defer Reset()
+
+	var text, json bytes.Buffer
+	Configure(&text, slog.LevelWarn)
+	AddJSON(&json, slog.LevelInfo)
+	Info("locked file", "path", ".env")
+	Error("command failed", "error", "wrong password")
+
+	if strings.Contains(text.String(), "locked file") || !strings.Contains(text.String(), "command failed") {
+		t.Errorf("unexpected text output: %q", text.String())
+	}
+	lines := strings.Split(strings.TrimSpace(json.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %q", json.String())
+	}
+	if !strings.Contains(lines[0], `"level":"INFO","msg":"locked file","path":".env"`) {
+		t.Errorf("unexpected JSON line: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], `"level":"ERROR","msg":"command failed","error":"wrong password"`) {
+		t.Errorf("unexpected JSON line: %q", lines[1])
+	}
+}
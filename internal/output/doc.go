@@ -0,0 +1,14 @@
+// Package output colors terminal output for status, diff and conflict
+// prompts.
+//
+// Colors are used only when the stream is a terminal, and are disabled by
+// the NO_COLOR environment variable (https://no-color.org), TERM=dumb or the
+// --no-color flag (Disable). Force enables them for other streams as well:
+//   - green: unchanged files and added lines
+//   - yellow: modified files, changed keys and warnings
+//   - red: errors and removed lines
+//   - cyan: hunk headers
+//
+// Diffs are highlighted by syntax: in KEY=value, key: value and "key": value
+// lines the key is bold, and comments are dimmed.
+package output
@@ -0,0 +1,191 @@
+package output
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+// ANSI SGR sequences
+const (
+	reset  = "\x1b[0m"
+	bold   = "1"
+	dim    = "2"
+	red    = "31"
+	green  = "32"
+	yellow = "33"
+	cyan   = "36"
+	plain  = "39" // default foreground
+)
+
+// disabled is set by Disable (--no-color), forced by Force (color = "always")
+var disabled, forced atomic.Bool
+
+// Disable turns colors off for the rest of the process
+func Disable() {
+	disabled.Store(true)
+}
+
+// Force colors streams that are not terminals too, e.g. output piped to a
+// pager. Disable, NO_COLOR and TERM=dumb still turn colors off.
+func Force() {
+	forced.Store(true)
+}
+
+// Palette colors text written to one stream. The zero Palette writes plain
+// text.
+type Palette struct {
+	enabled bool
+}
+
+// Stdout returns the palette for standard output
+func Stdout() Palette {
+	return For(os.Stdout)
+}
+
+// Stderr returns the palette for standard error
+func Stderr() Palette {
+	return For(os.Stderr)
+}
+
+// For returns the palette for f, with colors enabled only if f is a terminal
+// (or colors are forced) and colors are not disabled
+func For(f *os.File) Palette {
+	return Palette{enabled: colorAllowed() && (forced.Load() || term.IsTerminal(int(f.Fd())))}
+}
+
+// Colored returns a palette that always colors, for tests and callers that
+// detect the terminal themselves
+func Colored() Palette {
+	return Palette{enabled: true}
+}
+
+// colorAllowed reports whether the environment and flags permit colors
+func colorAllowed() bool {
+	if disabled.Load() {
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return os.Getenv("TERM") != "dumb"
+}
+
+// Enabled reports whether the palette emits colors
+func (p Palette) Enabled() bool {
+	return p.enabled
+}
+
+// paint wraps s in the given SGR attributes
+func (p Palette) paint(s string, attrs ...string) string {
+	if !p.enabled || s == "" {
+		return s
+	}
+	return "\x1b[" + strings.Join(attrs, ";") + "m" + s + reset
+}
+
+// Red colors s red
+func (p Palette) Red(s string) string { return p.paint(s, red) }
+
+// Green colors s green
+func (p Palette) Green(s string) string { return p.paint(s, green) }
+
+// Yellow colors s yellow
+func (p Palette) Yellow(s string) string { return p.paint(s, yellow) }
+
+// Cyan colors s cyan
+func (p Palette) Cyan(s string) string { return p.paint(s, cyan) }
+
+// Bold makes s bold
+func (p Palette) Bold(s string) string { return p.paint(s, bold) }
+
+// Dim makes s faint
+func (p Palette) Dim(s string) string { return p.paint(s, dim) }
+
+// Status colors text by file status: green for unchanged, yellow for
+// modified and vault-only, red for errors. Other statuses keep the default
+// color but get the same escape sequences, so columns aligned with
+// text/tabwriter stay aligned.
+func (p Palette) Status(status, text string) string {
+	switch status {
+	case "unchanged":
+		return p.Green(text)
+	case "modified", "vault only":
+		return p.Yellow(text)
+	case "error":
+		return p.Red(text)
+	}
+	return p.paint(text, plain)
+}
+
+// assignmentPattern splits KEY=value, key: value and "key": value lines
+// (after an optional export) into indent+key, separator and value
+var assignmentPattern = regexp.MustCompile(`^(\s*(?:export\s+)?(?:"[^"]*"|[A-Za-z_][A-Za-z0-9_.\-]*))(\s*[=:]\s*)(.*)$`)
+
+// Diff colors a unified or key-level diff produced by lockenv diff: file
+// headers bold, hunk headers cyan, removed lines red, added lines green and
+// changed keys yellow. Keys in assignments are bold and comments dimmed.
+func (p Palette) Diff(diff string) string {
+	if !p.enabled {
+		return diff
+	}
+
+	lines := strings.SplitAfter(diff, "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		text := strings.TrimSuffix(line, "\n")
+		newline := line[len(text):]
+		switch {
+		case strings.HasPrefix(text, "--- ") || strings.HasPrefix(text, "+++ "):
+			b.WriteString(p.Bold(text))
+		case strings.HasPrefix(text, "@@"):
+			b.WriteString(p.Cyan(text))
+		case strings.HasPrefix(text, "-"):
+			b.WriteString(p.diffLine(text, red))
+		case strings.HasPrefix(text, "+"):
+			b.WriteString(p.diffLine(text, green))
+		case strings.HasPrefix(text, "~"):
+			b.WriteString(p.diffLine(text, yellow))
+		case strings.HasPrefix(text, "(") || strings.HasPrefix(text, "Binary files"):
+			b.WriteString(p.Dim(text))
+		default:
+			b.WriteString(p.diffLine(text, ""))
+		}
+		b.WriteString(newline)
+	}
+	return b.String()
+}
+
+// diffLine colors one diff line in color (plain if empty), highlighting the
+// key of an assignment and dimming comments
+func (p Palette) diffLine(text, color string) string {
+	if text == "" {
+		return text
+	}
+	marker, body := text[:1], text[1:]
+
+	attrs := func(extra ...string) []string {
+		if color == "" {
+			return extra
+		}
+		return append([]string{color}, extra...)
+	}
+	whole := func(s string, extra ...string) string {
+		if a := attrs(extra...); len(a) > 0 {
+			return p.paint(s, a...)
+		}
+		return s
+	}
+
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, ";") {
+		return whole(marker) + whole(body, dim)
+	}
+	if m := assignmentPattern.FindStringSubmatch(body); m != nil {
+		return whole(marker) + whole(m[1], bold) + whole(m[2]+m[3])
+	}
+	return whole(text)
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPalette_Plain(t *testing.T) {
+	var p Palette
+	if got := p.Red("error"); got != "error" {
+		t.Errorf("Red() = %q, want plain text", got)
+	}
+	diff := "--- a/.env\n+++ b/.env\n@@ -1,1 +1,1 @@\n-A=1\n+A=2\n"
+	if got := p.Diff(diff); got != diff {
+		t.Errorf("Diff() = %q, want unchanged", got)
+	}
+}
+
+func TestPalette_Status(t *testing.T) {
+	p := Colored()
+	tests := []struct {
+		status, want string
+	}{
+		{"unchanged", "\x1b[32m.\x1b[0m"},
+		{"modified", "\x1b[33m.\x1b[0m"},
+		{"vault only", "\x1b[33m.\x1b[0m"},
+		{"error", "\x1b[31m.\x1b[0m"},
+		{"tracked, never locked", "\x1b[39m.\x1b[0m"},
+	}
+	for _, tt := range tests {
+		got := p.Status(tt.status, ".")
+		if got != tt.want {
+			t.Errorf("Status(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+		// Every status adds the same number of bytes, keeping tables aligned
+		if len(got) != len(tests[0].want) {
+			t.Errorf("Status(%q) has %d bytes, want %d", tt.status, len(got), len(tests[0].want))
+		}
+	}
+}
+
+func TestPalette_Diff(t *testing.T) {
+	p := Colored()
+	diff := "--- a/.env\n+++ b/.env\n@@ -1,2 +1,2 @@\n # database\n-DB_PASS=old\n+DB_PASS=new\n"
+	got := p.Diff(diff)
+
+	for _, want := range []string{
+		"\x1b[1m--- a/.env\x1b[0m\n",
+		"\x1b[36m@@ -1,2 +1,2 @@\x1b[0m\n",
+		" \x1b[2m# database\x1b[0m\n",
+		"\x1b[31m-\x1b[0m\x1b[31;1mDB_PASS\x1b[0m\x1b[31m=old\x1b[0m\n",
+		"\x1b[32m+\x1b[0m\x1b[32;1mDB_PASS\x1b[0m\x1b[32m=new\x1b[0m\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Diff() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestPalette_DiffStructured(t *testing.T) {
+	p := Colored()
+	got := p.Diff("(json: 1 key(s) changed)\n~ db.port: **** -> ****\n")
+	if !strings.HasPrefix(got, "\x1b[2m(json") {
+		t.Errorf("summary line not dimmed: %q", got)
+	}
+	if !strings.Contains(got, "\x1b[33;1m db.port\x1b[0m") {
+		t.Errorf("changed key not highlighted: %q", got)
+	}
+}
+
+func TestColorAllowed(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	t.Setenv("NO_COLOR", "")
+	if !colorAllowed() {
+		t.Error("colors should be allowed by default")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if colorAllowed() {
+		t.Error("NO_COLOR should disable colors")
+	}
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "dumb")
+	if colorAllowed() {
+		t.Error("TERM=dumb should disable colors")
+	}
+}
+
+func TestFor_NotTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if For(f).Enabled() {
+		t.Error("colors enabled for a regular file")
+	}
+}
+
+func TestDisable(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	t.Setenv("NO_COLOR", "")
+	Disable()
+	defer disabled.Store(false)
+	if colorAllowed() {
+		t.Error("Disable should turn colors off")
+	}
+}
+
+func TestForce(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	t.Setenv("NO_COLOR", "")
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	Force()
+	defer forced.Store(false)
+	if !For(f).Enabled() {
+		t.Error("Force should color a regular file")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if For(f).Enabled() {
+		t.Error("NO_COLOR should win over Force")
+	}
+}
@@ -0,0 +1,19 @@
+// Package remote syncs the encrypted vault file with a remote location.
+//
+// Remotes are addressed by URL:
+//   - https:// and http:// use plain GET and PUT requests
+//   - webdavs:// and webdav:// are the same over WebDAV servers
+//   - s3://bucket/key stores an S3 object, signed with the AWS credentials
+//     from the environment
+//   - gs://bucket/object stores a GCS object through the XML API, with an
+//     OAuth access token
+//
+// Uploads are conditional on the remote's ETag (If-Match, or If-None-Match
+// for the first push; the object generation on GCS), so concurrent pushes
+// from two machines are detected instead of silently overwriting each
+// other. Credentials come from the URL userinfo (basic auth) or
+// LOCKENV_REMOTE_TOKEN (bearer token), and are only sent over plain http
+// with LOCKENV_REMOTE_INSECURE=1.
+//
+// Only the already-encrypted .lockenv file is transferred.
+package remote
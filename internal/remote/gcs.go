@@ -0,0 +1,34 @@
+package remote
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// gcsEndpoint serves the GCS XML API, which stores objects with plain GET and
+// PUT requests
+var gcsEndpoint = "https://storage.googleapis.com"
+
+// newGCS returns the remote for gs://bucket/object. It authenticates with
+// an OAuth access token from LOCKENV_REMOTE_TOKEN or GOOGLE_OAUTH_ACCESS_TOKEN,
+// e.g. from `gcloud auth print-access-token`, and conditions uploads on the
+// object generation.
+func newGCS(u *url.URL) (*httpRemote, error) {
+	name := strings.TrimPrefix(u.Path, "/")
+	if name == "" {
+		return nil, fmt.Errorf("invalid remote URL: gs://%s has no object name", u.Host)
+	}
+	token := firstEnv("LOCKENV_REMOTE_TOKEN", "GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("gs:// remotes need an access token in LOCKENV_REMOTE_TOKEN or GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+
+	object := &url.URL{Path: "/" + u.Host + "/" + name}
+	return &httpRemote{
+		url:         gcsEndpoint + object.EscapedPath(),
+		name:        "gs://" + u.Host + "/" + name,
+		token:       token,
+		generations: true,
+	}, nil
+}
@@ -0,0 +1,244 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var (
+	ErrNotFound = errors.New("remote vault not found")
+	ErrConflict = errors.New("remote vault changed since last sync")
+	ErrInsecure = errors.New("refusing to send credentials over plain http (use https, or set LOCKENV_REMOTE_INSECURE=1)")
+)
+
+// Remote stores a single vault file
+type Remote interface {
+	// Get downloads the vault and returns it with its ETag
+	Get(ctx context.Context) ([]byte, string, error)
+	// Put uploads the vault and returns the new ETag. Unless force is set, the
+	// upload fails with ErrConflict if the remote no longer has version etag,
+	// or if etag is empty and the remote already exists.
+	Put(ctx context.Context, data []byte, etag string, force bool) (string, error)
+	// String returns the remote URL without credentials
+	String() string
+}
+
+// New returns the remote for a URL
+func New(rawURL string) (Remote, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote URL: %w", err)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid remote URL: missing host")
+	}
+
+	var r *httpRemote
+	switch u.Scheme {
+	case "https", "http":
+		r = &httpRemote{}
+	case "webdavs":
+		u.Scheme = "https"
+		r = &httpRemote{}
+	case "webdav":
+		u.Scheme = "http"
+		r = &httpRemote{}
+	case "s3":
+		if r, err = newS3(u); err != nil {
+			return nil, err
+		}
+	case "gs":
+		if r, err = newGCS(u); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported remote scheme %q (use https://, http://, webdavs://, webdav://, s3:// or gs://)", u.Scheme)
+	}
+
+	r.client = http.DefaultClient
+	if r.token == "" && r.sign == nil {
+		r.token = os.Getenv("LOCKENV_REMOTE_TOKEN")
+	}
+	if u.User != nil {
+		r.user = u.User.Username()
+		r.password, r.hasPassword = u.User.Password()
+		u.User = nil
+	}
+	if r.url == "" {
+		r.url = u.String()
+	}
+	if r.name == "" {
+		r.name = r.url
+	}
+
+	hasCredentials := r.token != "" || r.user != "" || r.hasPassword || r.sign != nil
+	if hasCredentials && strings.HasPrefix(r.url, "http://") && os.Getenv("LOCKENV_REMOTE_INSECURE") != "1" {
+		return nil, ErrInsecure
+	}
+	return r, nil
+}
+
+// httpRemote stores the vault at a URL using GET and conditional PUT. S3 and
+// GCS objects are stored the same way through their HTTP APIs.
+type httpRemote struct {
+	client      *http.Client
+	url         string // Object URL requests go to
+	name        string // URL shown to the user, e.g. s3://bucket/key
+	user        string
+	password    string
+	hasPassword bool
+	token       string
+
+	// sign authenticates a request in place of a token or basic auth, e.g.
+	// with an S3 signature
+	sign func(req *http.Request, body []byte)
+	// generations makes conditions use the GCS object generation instead of
+	// the ETag, which GCS does not accept as a PUT precondition
+	generations bool
+}
+
+func (r *httpRemote) String() string {
+	return r.name
+}
+
+func (r *httpRemote) newRequest(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.url, reader)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case r.sign != nil:
+	case r.token != "":
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	case r.user != "" || r.hasPassword:
+		req.SetBasicAuth(r.user, r.password)
+	}
+	return req, nil
+}
+
+// do authenticates and sends req, whose body is body
+func (r *httpRemote) do(req *http.Request, body []byte) (*http.Response, error) {
+	if r.sign != nil {
+		r.sign(req, body)
+	}
+	return r.client.Do(req)
+}
+
+// version returns the version of the vault reported in resp
+func (r *httpRemote) version(resp *http.Response) string {
+	if r.generations {
+		return resp.Header.Get("x-goog-generation")
+	}
+	return resp.Header.Get("ETag")
+}
+
+// condition makes req fail unless the remote still has version etag, or has
+// no vault yet if etag is empty
+func (r *httpRemote) condition(req *http.Request, etag string) {
+	switch {
+	case r.generations && etag == "":
+		req.Header.Set("x-goog-if-generation-match", "0")
+	case r.generations:
+		req.Header.Set("x-goog-if-generation-match", etag)
+	case etag != "":
+		req.Header.Set("If-Match", etag)
+	default:
+		req.Header.Set("If-None-Match", "*")
+	}
+}
+
+func (r *httpRemote) Get(ctx context.Context) ([]byte, string, error) {
+	req, err := r.newRequest(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := r.do(req, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", statusError("download", resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download vault: %w", err)
+	}
+	return data, r.version(resp), nil
+}
+
+func (r *httpRemote) Put(ctx context.Context, data []byte, etag string, force bool) (string, error) {
+	req, err := r.newRequest(ctx, http.MethodPut, data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if !force {
+		r.condition(req, etag)
+	}
+
+	resp, err := r.do(req, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload vault: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		// S3 answers 409 to a conditional write racing another one
+		return "", ErrConflict
+	default:
+		return "", statusError("upload", resp)
+	}
+
+	if newETag := r.version(resp); newETag != "" {
+		return newETag, nil
+	}
+	// Some servers only report the ETag on reads
+	return r.head(ctx)
+}
+
+// head returns the current ETag of the remote vault
+func (r *httpRemote) head(ctx context.Context) (string, error) {
+	req, err := r.newRequest(ctx, http.MethodHead, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.do(req, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to query vault: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError("query", resp)
+	}
+	return r.version(resp), nil
+}
+
+// statusError describes an unexpected HTTP response
+func statusError(op string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("failed to %s vault: %s (check credentials or LOCKENV_REMOTE_TOKEN)", op, resp.Status)
+	}
+	return fmt.Errorf("failed to %s vault: %s", op, strings.TrimSpace(resp.Status))
+}
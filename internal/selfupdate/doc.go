@@ -0,0 +1,12 @@
+// Package selfupdate replaces the running lockenv binary with the latest
+// GitHub release.
+//
+// An update is only installed after verification:
+//   - The release's checksums file must carry a valid minisign signature
+//     from the release key built into the binary (PublicKey)
+//   - The downloaded archive must match its SHA-256 in that file
+//
+// The new binary is written next to the running one and renamed over it, so
+// an interrupted update leaves the old binary in place. Builds without a
+// release key, such as go install builds, refuse to update.
+package selfupdate
@@ -0,0 +1,79 @@
+package selfupdate
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrBadSignature is returned when a minisign signature does not verify
+var ErrBadSignature = errors.New("invalid signature")
+
+// minisignKey is a minisign Ed25519 public key
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// parsePublicKey accepts a minisign public key, either the base64 line alone
+// or the whole .pub file with its untrusted comment
+func parsePublicKey(s string) (*minisignKey, error) {
+	var line string
+	for _, l := range strings.Split(strings.TrimSpace(s), "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(data) != 2+8+ed25519.PublicKeySize || string(data[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid minisign public key")
+	}
+	return &minisignKey{id: data[2:10], key: data[10:]}, nil
+}
+
+// verify checks a minisign signature file over data, including the global
+// signature that covers the trusted comment
+func (k *minisignKey) verify(data, sigFile []byte) error {
+	lines := strings.Split(strings.ReplaceAll(string(sigFile), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("malformed minisign signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("malformed minisign signature")
+	}
+	if !bytes.Equal(sig[2:10], k.id) {
+		return fmt.Errorf("signed with an unknown key: %w", ErrBadSignature)
+	}
+
+	// "ED" signatures are over the BLAKE2b-512 hash of the data
+	message := data
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		sum := blake2b.Sum512(data)
+		message = sum[:]
+	default:
+		return fmt.Errorf("unsupported minisign algorithm %q", sig[:2])
+	}
+	if !ed25519.Verify(k.key, message, sig[10:]) {
+		return ErrBadSignature
+	}
+
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("malformed minisign signature")
+	}
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	signed := append(append([]byte(nil), sig[10:]...), comment...)
+	if !ed25519.Verify(k.key, signed, global) {
+		return fmt.Errorf("trusted comment: %w", ErrBadSignature)
+	}
+	return nil
+}
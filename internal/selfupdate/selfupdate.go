@@ -0,0 +1,323 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// PublicKey is the minisign key that signs release checksums. It is set at
+// release build time; builds without it cannot update themselves.
+var PublicKey = ""
+
+// Repo is the GitHub repository releases are fetched from
+const Repo = "illarion/lockenv"
+
+// maxDownload caps the size of any downloaded file
+const maxDownload = 256 << 20
+
+var (
+	ErrNoPublicKey = errors.New("this build has no release signing key; update with your package manager or download a release")
+	ErrNoAsset     = errors.New("release has no archive for this platform")
+	ErrChecksum    = errors.New("archive checksum mismatch")
+)
+
+// Release is a published lockenv release
+type Release struct {
+	Version string            // Version without the leading v
+	Assets  map[string]string // Download URL by asset name
+}
+
+// Updater fetches and installs releases
+type Updater struct {
+	APIURL    string // GitHub API base URL
+	Repo      string
+	PublicKey string // Minisign public key of the release signer
+	GOOS      string
+	GOARCH    string
+	HTTP      *http.Client
+}
+
+// New returns an updater for the official releases of this platform
+func New() *Updater {
+	return &Updater{
+		APIURL:    "https://api.github.com",
+		Repo:      Repo,
+		PublicKey: PublicKey,
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		HTTP:      http.DefaultClient,
+	}
+}
+
+// Latest returns the latest published release
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	data, err := u.get(ctx, fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimRight(u.APIURL, "/"), u.Repo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	var resp struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("invalid release response: %w", err)
+	}
+	if resp.TagName == "" {
+		return nil, fmt.Errorf("invalid release response: missing tag")
+	}
+
+	release := &Release{
+		Version: strings.TrimPrefix(resp.TagName, "v"),
+		Assets:  make(map[string]string, len(resp.Assets)),
+	}
+	for _, a := range resp.Assets {
+		release.Assets[a.Name] = a.URL
+	}
+	return release, nil
+}
+
+// archiveName returns the release archive for this platform, as named by
+// the goreleaser configuration
+func (u *Updater) archiveName(version string) string {
+	ext := ".tar.gz"
+	if u.GOOS == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("lockenv_%s_%s_%s%s", version, u.GOOS, u.GOARCH, ext)
+}
+
+// Download fetches the release archive for this platform, verifies it
+// against the signed checksums and returns the lockenv binary inside
+func (u *Updater) Download(ctx context.Context, release *Release) ([]byte, error) {
+	if u.PublicKey == "" {
+		return nil, ErrNoPublicKey
+	}
+	key, err := parsePublicKey(u.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := u.archiveName(release.Version)
+	checksumsName := fmt.Sprintf("lockenv_%s_checksums.txt", release.Version)
+	archiveURL, ok := release.Assets[archive]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", archive, ErrNoAsset)
+	}
+	checksumsURL, ok := release.Assets[checksumsName]
+	if !ok {
+		return nil, fmt.Errorf("release has no %s", checksumsName)
+	}
+	sigURL, ok := release.Assets[checksumsName+".minisig"]
+	if !ok {
+		return nil, fmt.Errorf("release checksums are not signed")
+	}
+
+	checksums, err := u.get(ctx, checksumsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checksums: %w", err)
+	}
+	sig, err := u.get(ctx, sigURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download signature: %w", err)
+	}
+	if err := key.verify(checksums, sig); err != nil {
+		return nil, fmt.Errorf("%s: %w", checksumsName, err)
+	}
+
+	want, err := findChecksum(checksums, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := u.get(ctx, archiveURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", archive, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != want {
+		return nil, fmt.Errorf("%s: %w", archive, ErrChecksum)
+	}
+
+	binary := "lockenv"
+	if u.GOOS == "windows" {
+		binary += ".exe"
+	}
+	if strings.HasSuffix(archive, ".zip") {
+		return extractZip(data, binary)
+	}
+	return extractTarGz(data, binary)
+}
+
+// get downloads url, failing on non-2xx responses
+func (u *Updater) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return readLimited(resp.Body)
+}
+
+// readLimited reads r, failing if it exceeds maxDownload
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownload {
+		return nil, fmt.Errorf("download exceeds %d MB", maxDownload>>20)
+	}
+	return data, nil
+}
+
+// findChecksum returns the SHA-256 of name from a sha256sum-style file
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+// extractTarGz returns the file called name from a .tar.gz archive
+func extractTarGz(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == name {
+			return readLimited(tr)
+		}
+	}
+	return nil, fmt.Errorf("archive does not contain %s", name)
+}
+
+// extractZip returns the file called name from a .zip archive
+func extractZip(data []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if !f.FileInfo().Mode().IsRegular() || path.Base(f.Name) != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		defer rc.Close()
+		return readLimited(rc)
+	}
+	return nil, fmt.Errorf("archive does not contain %s", name)
+}
+
+// Install atomically replaces the executable at exe with binary, keeping
+// its permissions
+func Install(exe string, binary []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".update-*")
+	if err != nil {
+		return fmt.Errorf("cannot write next to %s: %w", exe, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	_, err = tmp.Write(binary)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, info.Mode().Perm())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+
+	// Windows cannot replace a running executable, but it can rename it
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", exe, err)
+		}
+	}
+	if err := os.Rename(tmpPath, exe); err != nil {
+		if runtime.GOOS == "windows" {
+			_ = os.Rename(exe+".old", exe) // rollback
+		}
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	return nil
+}
+
+// Newer reports whether version a is newer than b. Versions are compared
+// numerically by dot-separated component; "dev" is older than any release.
+func Newer(a, b string) bool {
+	if b == "dev" || b == "" {
+		return a != "dev" && a != ""
+	}
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(strings.SplitN(as[i], "-", 2)[0])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(strings.SplitN(bs[i], "-", 2)[0])
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// testSigner produces minisign keys and signatures
+type testSigner struct {
+	id   []byte
+	priv ed25519.PrivateKey
+	pub  string
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	id := []byte("KEYID123")
+	key := append(append([]byte("Ed"), id...), pub...)
+	return &testSigner{
+		id:   id,
+		priv: priv,
+		pub:  "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(key) + "\n",
+	}
+}
+
+func (s *testSigner) sign(data []byte) []byte {
+	hash := blake2b.Sum512(data)
+	sig := ed25519.Sign(s.priv, hash[:])
+	comment := "timestamp:1700000000\tfile:checksums.txt"
+	global := ed25519.Sign(s.priv, append(append([]byte(nil), sig...), comment...))
+	line := append(append([]byte("ED"), s.id...), sig...)
+	return fmt.Appendf(nil, "untrusted comment: signature\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(line), comment, base64.StdEncoding.EncodeToString(global))
+}
+
+func TestMinisignVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	key, err := parsePublicKey(signer.pub)
+	if err != nil {
+		t.Fatalf("parsePublicKey failed: %v", err)
+	}
+
+	data := []byte("abc  lockenv_1.0.0_linux_amd64.tar.gz\n")
+	sig := signer.sign(data)
+	if err := key.verify(data, sig); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := key.verify(append(data, 'x'), sig); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for modified data, got %v", err)
+	}
+
+	forged := bytes.Replace(sig, []byte("timestamp:1700000000"), []byte("timestamp:1800000000"), 1)
+	if err := key.verify(data, forged); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for modified trusted comment, got %v", err)
+	}
+
+	other, _ := parsePublicKey(newTestSigner(t).pub)
+	if err := other.verify(data, sig); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for another key, got %v", err)
+	}
+}
+
+// newTestRelease serves a release of version with a lockenv binary
+// containing binary, signed by signer
+func newTestRelease(t *testing.T, signer *testSigner, version string, binary []byte) *httptest.Server {
+	t.Helper()
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "lockenv", Mode: 0755, Size: int64(len(binary)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	tw.Write(binary)
+	tw.Close()
+	gz.Close()
+
+	archiveName := fmt.Sprintf("lockenv_%s_linux_amd64.tar.gz", version)
+	checksumsName := fmt.Sprintf("lockenv_%s_checksums.txt", version)
+	sum := sha256.Sum256(archive.Bytes())
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  " + archiveName + "\n")
+
+	files := map[string][]byte{
+		archiveName:                archive.Bytes(),
+		checksumsName:              checksums,
+		checksumsName + ".minisig": signer.sign(checksums),
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/repos/illarion/lockenv/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		type asset struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		}
+		resp := struct {
+			TagName string  `json:"tag_name"`
+			Assets  []asset `json:"assets"`
+		}{TagName: "v" + version}
+		for name := range files {
+			resp.Assets = append(resp.Assets, asset{Name: name, URL: srv.URL + "/download/" + name})
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[filepath.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	return srv
+}
+
+func newTestUpdater(srv *httptest.Server, publicKey string) *Updater {
+	return &Updater{
+		APIURL:    srv.URL,
+		Repo:      Repo,
+		PublicKey: publicKey,
+		GOOS:      "linux",
+		GOARCH:    "amd64",
+		HTTP:      srv.Client(),
+	}
+}
+
+func TestDownload(t *testing.T) {
+	ctx := context.Background()
+	signer := newTestSigner(t)
+	srv := newTestRelease(t, signer, "1.2.0", []byte("new binary"))
+
+	updater := newTestUpdater(srv, signer.pub)
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	if release.Version != "1.2.0" {
+		t.Errorf("Expected version 1.2.0, got %s", release.Version)
+	}
+
+	binary, err := updater.Download(ctx, release)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if string(binary) != "new binary" {
+		t.Errorf("Unexpected binary: %q", binary)
+	}
+
+	// Checksums signed by another key are rejected
+	updater.PublicKey = newTestSigner(t).pub
+	if _, err := updater.Download(ctx, release); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+
+	updater.PublicKey = ""
+	if _, err := updater.Download(ctx, release); err != ErrNoPublicKey {
+		t.Errorf("Expected ErrNoPublicKey, got %v", err)
+	}
+
+	updater.PublicKey = signer.pub
+	updater.GOARCH = "riscv64"
+	if _, err := updater.Download(ctx, release); !errors.Is(err, ErrNoAsset) {
+		t.Errorf("Expected ErrNoAsset, got %v", err)
+	}
+}
+
+func TestDownload_ChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	signer := newTestSigner(t)
+	srv := newTestRelease(t, signer, "1.2.0", []byte("new binary"))
+	updater := newTestUpdater(srv, signer.pub)
+
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	// Point the archive at the checksums file, which has a different hash
+	release.Assets["lockenv_1.2.0_linux_amd64.tar.gz"] = release.Assets["lockenv_1.2.0_checksums.txt"]
+	if _, err := updater.Download(ctx, release); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "lockenv")
+	if err := os.WriteFile(exe, []byte("old"), 0750); err != nil {
+		t.Fatalf("Failed to write executable: %v", err)
+	}
+
+	if err := Install(exe, []byte("new")); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil || string(data) != "new" {
+		t.Errorf("Expected new binary, got %q (%v)", data, err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, _ := os.Stat(exe); info.Mode().Perm() != 0750 {
+			t.Errorf("Expected mode 0750, got %o", info.Mode().Perm())
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Dir(exe))
+	if len(entries) != 1 && runtime.GOOS != "windows" {
+		t.Errorf("Expected temporary files to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.0", "1.1.9", true},
+		{"1.10.0", "1.9.0", true},
+		{"1.2.0", "1.2.0", false},
+		{"1.2.0", "v1.2.0", false},
+		{"1.2", "1.2.1", false},
+		{"2.0.0-rc1", "1.9.0", true},
+		{"1.0.0", "dev", true},
+		{"dev", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// Package session provides short-lived caching of derived vault keys.
+//
+// A session stores the vault key (not the password) under XDG_RUNTIME_DIR,
+// wrapped with AES-256-GCM using a machine-local key:
+//   - A random 32-byte secret kept in the same runtime directory (0600)
+//   - Derived with HKDF together with the machine ID, so tokens are useless
+//     on other hosts
+//   - Expiry time bound to the ciphertext as additional authenticated data
+//
+// XDG_RUNTIME_DIR is a per-user tmpfs cleared on logout, so sessions never
+// outlive the login session. When it is unset, a per-user directory in the
+// system temp directory is used instead. Either way the directory must be
+// owned by the user with mode 0700 and not be a symlink, and files in it
+// are created exclusively, never through an existing file or symlink.
+package session
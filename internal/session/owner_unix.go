@@ -0,0 +1,23 @@
+//go:build unix
+
+package session
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// noFollow makes opening a symlink fail
+const noFollow = syscall.O_NOFOLLOW
+
+// checkOwner requires info to be owned by the current user with mode 0700
+func checkOwner(info os.FileInfo) error {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("is owned by uid %d", st.Uid)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		return fmt.Errorf("has mode %04o, want 0700", perm)
+	}
+	return nil
+}
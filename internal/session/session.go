@@ -0,0 +1,249 @@
+package session
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/illarion/lockenv/internal/crypto"
+)
+
+const (
+	dirName     = "lockenv"
+	secretFile  = "session.key"
+	tokenSuffix = ".session"
+
+	// wrapKeyInfo is the HKDF info of the key wrapping session tokens
+	wrapKeyInfo = "lockenv-session-wrap-v1"
+)
+
+var (
+	ErrNoSession   = errors.New("no active session")
+	ErrExpired     = errors.New("session expired")
+	ErrInsecureDir = errors.New("insecure session directory")
+)
+
+// token is the on-disk format of a session
+type token struct {
+	Expires time.Time `json:"expires"`
+	Key     []byte    `json:"key"` // Vault key wrapped with the machine-local key
+}
+
+// Dir returns the directory where session tokens are stored
+func Dir() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, dirName)
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", dirName, os.Getuid()))
+}
+
+// Start stores the vault key for vaultID, valid for ttl
+func Start(vaultID string, key []byte, ttl time.Duration) (time.Time, error) {
+	if vaultID == "" {
+		return time.Time{}, fmt.Errorf("vault ID is required")
+	}
+
+	dir := Dir()
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return time.Time{}, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	if err := checkDir(dir); err != nil {
+		return time.Time{}, err
+	}
+
+	wrapKey, err := machineKey(dir, true)
+	if err != nil {
+		return time.Time{}, err
+	}
+	enc := crypto.NewEncryptor(wrapKey)
+	defer enc.Destroy()
+
+	expires := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	wrapped, err := enc.EncryptWithAAD(key, expiryAAD(vaultID, expires))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to wrap key: %w", err)
+	}
+
+	data, err := json.Marshal(token{Expires: expires, Key: wrapped})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	path := tokenPath(dir, vaultID)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return time.Time{}, fmt.Errorf("failed to replace session: %w", err)
+	}
+	if err := writeNew(path, data); err != nil {
+		return time.Time{}, fmt.Errorf("failed to write session: %w", err)
+	}
+
+	return expires, nil
+}
+
+// Load returns the cached vault key for vaultID.
+// Expired or unreadable sessions are removed.
+// The caller is responsible for calling crypto.ClearBytes on the returned key.
+func Load(vaultID string) ([]byte, error) {
+	if vaultID == "" {
+		return nil, ErrNoSession
+	}
+
+	dir := Dir()
+	if err := checkDir(dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSession
+		}
+		return nil, err
+	}
+	path := tokenPath(dir, vaultID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+
+	var t token
+	if err := json.Unmarshal(data, &t); err != nil {
+		_ = os.Remove(path)
+		return nil, ErrNoSession
+	}
+
+	if time.Now().After(t.Expires) {
+		_ = os.Remove(path)
+		return nil, ErrExpired
+	}
+
+	wrapKey, err := machineKey(dir, false)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, ErrNoSession
+	}
+	enc := crypto.NewEncryptor(wrapKey)
+	defer enc.Destroy()
+
+	key, err := enc.DecryptWithAAD(t.Key, expiryAAD(vaultID, t.Expires))
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, ErrNoSession
+	}
+
+	return key, nil
+}
+
+// Status returns the expiry time of the session for vaultID
+func Status(vaultID string) (time.Time, error) {
+	data, err := os.ReadFile(tokenPath(Dir(), vaultID))
+	if err != nil {
+		return time.Time{}, ErrNoSession
+	}
+
+	var t token
+	if err := json.Unmarshal(data, &t); err != nil {
+		return time.Time{}, ErrNoSession
+	}
+	if time.Now().After(t.Expires) {
+		return t.Expires, ErrExpired
+	}
+	return t.Expires, nil
+}
+
+// End removes the session for vaultID
+func End(vaultID string) error {
+	err := os.Remove(tokenPath(Dir(), vaultID))
+	if os.IsNotExist(err) {
+		return ErrNoSession
+	}
+	return err
+}
+
+// tokenPath returns the token file path for a vault
+func tokenPath(dir, vaultID string) string {
+	// Vault IDs are hex, but never let a tampered ID escape the directory
+	return filepath.Join(dir, filepath.Base(filepath.Clean(vaultID))+tokenSuffix)
+}
+
+// expiryAAD binds the vault ID and expiry to the wrapped key,
+// so editing the token's expiry invalidates it
+func expiryAAD(vaultID string, expires time.Time) []byte {
+	aad := make([]byte, 8, 8+len(vaultID))
+	binary.BigEndian.PutUint64(aad, uint64(expires.Unix()))
+	return append(aad, vaultID...)
+}
+
+// machineKey derives the wrapping key from the per-user session secret and
+// the machine ID with HKDF-SHA256. If create is true, a missing secret is
+// generated.
+func machineKey(dir string, create bool) ([]byte, error) {
+	path := filepath.Join(dir, secretFile)
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		if !create || !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read session secret: %w", err)
+		}
+		secret, err = crypto.GenerateRandom(crypto.KeySize)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeNew(path, secret); err != nil {
+			crypto.ClearBytes(secret)
+			return nil, fmt.Errorf("failed to write session secret: %w", err)
+		}
+	}
+	defer crypto.ClearBytes(secret)
+
+	key, err := hkdf.Key(sha256.New, secret, []byte(machineID()), wrapKeyInfo, crypto.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive session key: %w", err)
+	}
+	return key, nil
+}
+
+// checkDir checks that the session directory is a directory, not a
+// symlink, owned by the current user and closed to everyone else. In the
+// shared temp directory, another user could otherwise create it first and
+// plant a secret of their own.
+func checkDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrInsecureDir, dir)
+	}
+	if err := checkOwner(info); err != nil {
+		return fmt.Errorf("%w: %s %v", ErrInsecureDir, dir, err)
+	}
+	return nil
+}
+
+// writeNew writes data to the new file path with mode 0600, failing if path
+// exists, as a file or a symlink
+func writeNew(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|noFollow, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// machineID returns a stable identifier for this host, or "" if unavailable
+func machineID() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStartLoadEnd(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	if _, err := Start("abc123", key, time.Hour); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	loaded, err := Load("abc123")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !bytes.Equal(loaded, key) {
+		t.Error("Loaded key does not match stored key")
+	}
+
+	if _, err := Status("abc123"); err != nil {
+		t.Errorf("Status failed: %v", err)
+	}
+
+	if err := End("abc123"); err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+	if _, err := Load("abc123"); err != ErrNoSession {
+		t.Errorf("Expected ErrNoSession after End, got %v", err)
+	}
+}
+
+func TestLoad_Expired(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	key := bytes.Repeat([]byte{0x01}, 32)
+	if _, err := Start("vault", key, -time.Minute); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if _, err := Load("vault"); err != ErrExpired {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(Dir(), "vault"+tokenSuffix)); !os.IsNotExist(err) {
+		t.Error("Expired session should have been removed")
+	}
+}
+
+func TestLoad_TamperedExpiryRejected(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	key := bytes.Repeat([]byte{0x07}, 32)
+	if _, err := Start("vault", key, time.Minute); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// Extend the expiry without re-wrapping the key
+	path := filepath.Join(Dir(), "vault"+tokenSuffix)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read token: %v", err)
+	}
+	var tok token
+	if err := json.Unmarshal(data, &tok); err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	tok.Expires = tok.Expires.Add(24 * time.Hour)
+	data, _ = json.Marshal(tok)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+
+	if _, err := Load("vault"); err != ErrNoSession {
+		t.Errorf("Expected ErrNoSession for tampered token, got %v", err)
+	}
+}
+
+func TestStart_RefusesInsecureDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes carry no ownership on Windows")
+	}
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	// A directory others can write to may hold a secret they planted
+	if err := os.Mkdir(Dir(), 0700); err != nil {
+		t.Fatalf("Failed to create session directory: %v", err)
+	}
+	if err := os.Chmod(Dir(), 0777); err != nil {
+		t.Fatalf("Failed to chmod session directory: %v", err)
+	}
+	if _, err := Start("vault", key, time.Hour); !errors.Is(err, ErrInsecureDir) {
+		t.Errorf("Expected ErrInsecureDir for a 0777 directory, got %v", err)
+	}
+	if _, err := Load("vault"); !errors.Is(err, ErrInsecureDir) {
+		t.Errorf("Expected ErrInsecureDir from Load, got %v", err)
+	}
+
+	// A symlink to a directory elsewhere is not followed
+	if err := os.Remove(Dir()); err != nil {
+		t.Fatalf("Failed to remove session directory: %v", err)
+	}
+	if err := os.Symlink(t.TempDir(), Dir()); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if _, err := Start("vault", key, time.Hour); !errors.Is(err, ErrInsecureDir) {
+		t.Errorf("Expected ErrInsecureDir for a symlink, got %v", err)
+	}
+}
+
+func TestStart_DoesNotFollowPlantedSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if err := os.Mkdir(Dir(), 0700); err != nil {
+		t.Fatalf("Failed to create session directory: %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "stolen.key")
+	if err := os.Symlink(target, filepath.Join(Dir(), secretFile)); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	if _, err := Start("vault", bytes.Repeat([]byte{0x42}, 32), time.Hour); err == nil {
+		t.Error("Expected Start to fail on a symlinked secret")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("The secret was written through the symlink")
+	}
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfigStore keeps the vault's unencrypted settings and state. The format,
+// KDF parameters, identity, timestamps and generation have their own
+// methods since the backend maintains them. The records commands keep
+// between runs are opaque values under a key, encoded by core.
+type ConfigStore interface {
+	GetFormatVersion() (int, error)
+	SetFormatVersion(version int) error
+	SetSalt(salt []byte) error
+	GetSalt() ([]byte, error)
+	SetIterations(iterations uint32) error
+	GetIterations() (uint32, error)
+	UpdateModified() error
+	GetGeneration() (uint64, error)
+	RaiseGeneration(min uint64) error
+	GetModified() (time.Time, error)
+	GetCreated() (time.Time, error)
+	SetCreated(created time.Time) error
+	GetVaultID() (string, error)
+	GetOrCreateVaultID() (string, error)
+
+	GetConfig(key string) ([]byte, error)
+	PutConfig(key string, value []byte) error
+	DeleteConfig(key string) error
+	ListConfig() ([]string, error)
+}
+
+// ManifestStore keeps the unencrypted file index that ls and status read
+// without a password
+type ManifestStore interface {
+	UpdateManifest(path string, size int64, modTime time.Time, hash string) error
+	RemoveFromManifest(path string) error
+	GetManifest() ([]ManifestEntry, error)
+	GetManifestEntry(path string) (*ManifestEntry, error)
+	ReplaceManifest(entries []ManifestEntry) error
+	GetTrackedFiles() ([]string, error)
+}
+
+// BlobStore keeps the encrypted contents of files, by path
+type BlobStore interface {
+	StoreFileData(ctx context.Context, path string, encryptedData []byte) error
+	WriteFileData(ctx context.Context, path string, r io.Reader) error
+	ReadFileData(ctx context.Context, path string, fn func(piece []byte) error) error
+	GetFileData(ctx context.Context, path string) ([]byte, error)
+	HasFileData(path string) (bool, error)
+	GetBlobPaths() ([]string, error)
+	RemoveFile(path string) error
+}
+
+// PrivateStore keeps the encrypted metadata records and the key material
+// of recipients and encryption domains
+type PrivateStore interface {
+	StoreMetadataBytes(key string, encryptedData []byte) error
+	GetMetadataBytes(key string) ([]byte, error)
+
+	StoreRecipient(r Recipient) error
+	GetRecipients() ([]Recipient, error)
+	RemoveRecipient(fingerprint string) error
+	StoreDomain(d Domain) error
+	GetDomain(name string) (*Domain, error)
+	GetDomains() ([]Domain, error)
+	RemoveDomain(name string) error
+}
+
+// Backend is the vault storage core works against. Storage, the bbolt
+// database in .lockenv, is the default implementation; other backends are
+// added with Register. Helpers that only need part of a vault take one of
+// the smaller interfaces it is made of.
+type Backend interface {
+	ConfigStore
+	ManifestStore
+	BlobStore
+	PrivateStore
+
+	Initialize() error
+	IsInitialized() (bool, error)
+	Close() error
+
+	// ApplyMigration writes the blobs and private records of m and its
+	// format version atomically
+	ApplyMigration(ctx context.Context, m *Migration) error
+
+	// Whole-vault operations
+	SpaceStats() (*SpaceStats, error)
+	WriteTo(w io.Writer) (int64, error)
+	Compact() error
+}
+
+// Layouts is implemented by backends that can keep file contents outside
+// the vault file. Storage does; backends without it only have LayoutFile.
+type Layouts interface {
+	Layout() string
+	SetLayout(layout string) error
+}
+
+var (
+	_ Backend = (*Storage)(nil)
+	_ Layouts = (*Storage)(nil)
+)
+
+// LayoutOf returns the layout of b, LayoutFile for backends without Layouts
+func LayoutOf(b Backend) string {
+	if l, ok := b.(Layouts); ok {
+		return l.Layout()
+	}
+	return LayoutFile
+}
+
+// ConvertLayout converts b to layout. Backends without Layouts accept only
+// LayoutFile.
+func ConvertLayout(b Backend, layout string) error {
+	if l, ok := b.(Layouts); ok {
+		return l.SetLayout(layout)
+	}
+	if layout != LayoutFile {
+		return fmt.Errorf("storage backend only supports the %s layout", LayoutFile)
+	}
+	return nil
+}
+
+// Opener opens or creates the vault at path
+type Opener func(path string) (Backend, error)
+
+// DefaultBackend is the backend used unless another one is selected
+const DefaultBackend = "bbolt"
+
+// backend is a registered backend
+type backend struct {
+	open   Opener
+	exists func(path string) bool // Nil for backends that store the vault in a file at path
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]backend{
+		DefaultBackend: {open: func(path string) (Backend, error) {
+			s, err := Open(path)
+			if err != nil {
+				return nil, err
+			}
+			return s, nil
+		}},
+		MemoryBackend: {open: openMemory, exists: memoryExists},
+	}
+)
+
+// Register makes a backend available to OpenBackend under name, replacing
+// any backend registered under the same name. The backend is expected to
+// keep the vault in a file at the path it opens.
+func Register(name string, open Opener) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = backend{open: open}
+}
+
+// Backends returns the names of all registered backends, sorted
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenBackend opens the vault at path with the named backend, or with
+// DefaultBackend if name is empty
+func OpenBackend(name, path string) (Backend, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+	backendsMu.RLock()
+	b, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+	return b.open(path)
+}
+
+// Exists reports whether the named backend, or DefaultBackend if name is
+// empty, has a vault at path
+func Exists(name, path string) bool {
+	if name == "" {
+		name = DefaultBackend
+	}
+	backendsMu.RLock()
+	b := backends[name]
+	backendsMu.RUnlock()
+	if b.exists != nil {
+		return b.exists(path)
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOpenBackend(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := OpenBackend("", filepath.Join(dir, "default.lockenv"))
+	if err != nil {
+		t.Fatalf("OpenBackend with the default backend failed: %v", err)
+	}
+	if _, ok := db.(*Storage); !ok {
+		t.Errorf("Default backend is %T, want *Storage", db)
+	}
+	db.Close()
+
+	var opened []string
+	Register("test-recorder", func(path string) (Backend, error) {
+		opened = append(opened, path)
+		return Open(path)
+	})
+	if !slices.Contains(Backends(), "test-recorder") {
+		t.Errorf("Backends() = %v, missing the registered backend", Backends())
+	}
+	path := filepath.Join(dir, "recorded.lockenv")
+	db, err = OpenBackend("test-recorder", path)
+	if err != nil {
+		t.Fatalf("OpenBackend failed: %v", err)
+	}
+	db.Close()
+	if len(opened) != 1 || opened[0] != path {
+		t.Errorf("Registered opener saw %v", opened)
+	}
+
+	if _, err := OpenBackend("no-such-backend", path); err == nil || !strings.Contains(err.Error(), DefaultBackend) {
+		t.Errorf("Expected an error listing the available backends, got %v", err)
+	}
+
+	// A failed open must not yield a non-nil Backend holding a nil *Storage
+	if db, err := OpenBackend("", dir); err == nil || db != nil {
+		t.Errorf("Expected a nil Backend and an error for a directory, got %v, %v", db, err)
+	}
+}
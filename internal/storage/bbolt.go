@@ -1,23 +1,38 @@
 package storage
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/illarion/lockenv/internal/logging"
 	bolt "go.etcd.io/bbolt"
 )
 
 // Bucket names
 var (
-	ConfigBucket  = []byte("config")  // KDF params (salt, iterations), timestamps - unencrypted
-	IndexBucket   = []byte("index")   // Public file list for ls/status - unencrypted
-	BlobsBucket   = []byte("blobs")   // Encrypted file contents
-	PrivateBucket = []byte("private") // Encrypted checksum + file details
+	ConfigBucket     = []byte("config")     // KDF params (salt, iterations), timestamps - unencrypted
+	IndexBucket      = []byte("index")      // Public file list for ls/status - unencrypted
+	BlobsBucket      = []byte("blobs")      // Encrypted file contents
+	PrivateBucket    = []byte("private")    // Encrypted checksum + file details
+	RecipientsBucket = []byte("recipients") // Vault key wrapped for each SSH or KMS recipient
+	DomainsBucket    = []byte("domains")    // KDF params and password check for each encryption domain
+	CacheBucket      = []byte("cache")      // Hashes of local files written by older releases, dropped on the next change
+	ChunksBucket     = []byte("chunks")     // Encrypted contents over ChunkSize, in chunks
 )
 
 // Config keys
@@ -25,24 +40,147 @@ var (
 	ConfigVersion  = []byte("version")
 	ConfigCreated  = []byte("created")
 	ConfigModified = []byte("modified")
+	ConfigGen      = []byte("generation") // Incremented by every change to the vault
 	ConfigSalt     = []byte("salt")
 	ConfigIters    = []byte("iterations")
 	ConfigVaultID  = []byte("vault_id")
+	ConfigLayout   = []byte("layout") // LayoutDir if file blobs live in ObjectsDir
+
+	ConfigMinReader        = []byte("min_reader")         // Oldest format a reader must support
+	ConfigMinReaderRelease = []byte("min_reader_release") // lockenv release that wrote the format
+)
+
+// Vault format versions, stored under ConfigVersion
+const (
+	FormatV1      = 1        // Files and metadata encrypted with the password-derived key
+	FormatV2      = 2        // Envelope data key, chunked blobs and an authenticated index
+	FormatV3      = 3        // HKDF subkeys of the data key per purpose and segmented blob encryption
+	CurrentFormat = FormatV3 // Format of new vaults and the target of lockenv migrate
 )
 
+// Release is the lockenv release of this binary, recorded in vaults it
+// writes so older binaries can name the release to upgrade to
+var Release = "dev"
+
+// ErrVaultTooNew is returned by Open for a vault in a format this binary
+// cannot read
+var ErrVaultTooNew = errors.New("vault format not supported")
+
+// VersionError describes a vault that requires a newer lockenv
+type VersionError struct {
+	Format  int    // Oldest format a reader must support
+	Release string // Release that wrote the vault, if known
+}
+
+func (e *VersionError) Error() string {
+	if e.Release != "" && e.Release != "dev" {
+		return fmt.Sprintf("vault requires lockenv >= %s (format v%d, this lockenv reads up to v%d)", e.Release, e.Format, CurrentFormat)
+	}
+	return fmt.Sprintf("vault requires a newer lockenv (format v%d, this lockenv reads up to v%d)", e.Format, CurrentFormat)
+}
+
+func (e *VersionError) Is(target error) bool {
+	return target == ErrVaultTooNew
+}
+
 // Storage provides BBolt-based storage for lockenv
 type Storage struct {
-	db *bolt.DB
+	db      *bolt.DB
+	objects string // ObjectsDir next to the database in the dir layout, empty otherwise
 }
 
-// Open opens or creates a lockenv database
+// Open opens or creates a lockenv database. Vaults that require a newer
+// reader are refused with a *VersionError.
 func Open(path string) (*Storage, error) {
+	start := time.Now()
 	db, err := bolt.Open(path, 0600, nil)
 	if err != nil {
+		logging.Debug("open vault failed", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	return &Storage{db: db}, nil
+	if err := checkReader(db); err != nil {
+		db.Close()
+		logging.Debug("open vault failed", "path", path, "error", err)
+		return nil, err
+	}
+	layout, err := readLayout(db)
+	if err != nil {
+		db.Close()
+		logging.Debug("open vault failed", "path", path, "error", err)
+		return nil, err
+	}
+
+	s := &Storage{db: db}
+	if layout == LayoutDir {
+		s.objects = filepath.Join(filepath.Dir(path), ObjectsDir)
+	}
+	logging.Debug("open vault", "path", path, "layout", layout, "duration", time.Since(start))
+	return s, nil
+}
+
+// checkReader fails if the vault requires a newer format than this binary
+// supports. Vaults written before min_reader existed are gated on their
+// format version.
+func checkReader(db *bolt.DB) error {
+	return db.View(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if config == nil {
+			return nil
+		}
+		data := config.Get(ConfigMinReader)
+		if data == nil {
+			data = config.Get(ConfigVersion)
+		}
+		if data == nil {
+			return nil
+		}
+		format, err := strconv.Atoi(string(data))
+		if err != nil {
+			return fmt.Errorf("invalid format version %q", data)
+		}
+		if format > CurrentFormat {
+			return &VersionError{Format: format, Release: string(config.Get(ConfigMinReaderRelease))}
+		}
+		return nil
+	})
+}
+
+// putFormat records the format version and the oldest format a reader
+// must support to open the vault
+func putFormat(tx *bolt.Tx, format int) error {
+	config := tx.Bucket(ConfigBucket)
+	value := []byte(strconv.Itoa(format))
+	if err := config.Put(ConfigVersion, value); err != nil {
+		return err
+	}
+	if err := config.Put(ConfigMinReader, value); err != nil {
+		return err
+	}
+	return config.Put(ConfigMinReaderRelease, []byte(Release))
+}
+
+// update runs fn in a read-write transaction, tracing the commit under
+// --debug with the name of the calling method
+func (s *Storage) update(fn func(tx *bolt.Tx) error) error {
+	if !logging.Enabled(slog.LevelDebug) {
+		return s.db.Update(fn)
+	}
+
+	op := "unknown"
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		if f := runtime.FuncForPC(pc); f != nil {
+			op = f.Name()[strings.LastIndex(f.Name(), ".")+1:]
+		}
+	}
+	start := time.Now()
+	err := s.db.Update(fn)
+	if err != nil {
+		logging.Debug("tx rollback", "op", op, "duration", time.Since(start), "error", err)
+	} else {
+		logging.Debug("tx commit", "op", op, "duration", time.Since(start))
+	}
+	return err
 }
 
 // Close closes the database
@@ -52,7 +190,7 @@ func (s *Storage) Close() error {
 
 // Initialize creates the bucket structure for a new lockenv
 func (s *Storage) Initialize() error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		// Create all buckets
 		for _, bucket := range [][]byte{ConfigBucket, IndexBucket, BlobsBucket, PrivateBucket} {
 			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
@@ -61,10 +199,10 @@ func (s *Storage) Initialize() error {
 		}
 
 		// Set version
-		config := tx.Bucket(ConfigBucket)
-		if err := config.Put(ConfigVersion, []byte("1")); err != nil {
+		if err := putFormat(tx, CurrentFormat); err != nil {
 			return err
 		}
+		config := tx.Bucket(ConfigBucket)
 
 		// Set creation time
 		now := time.Now()
@@ -93,9 +231,38 @@ func (s *Storage) IsInitialized() (bool, error) {
 	return initialized, err
 }
 
+// GetFormatVersion returns the vault format version
+func (s *Storage) GetFormatVersion() (int, error) {
+	var version int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if config == nil {
+			return fmt.Errorf("config bucket not found")
+		}
+		data := config.Get(ConfigVersion)
+		if data == nil {
+			return fmt.Errorf("version not found")
+		}
+		v, err := strconv.Atoi(string(data))
+		if err != nil {
+			return fmt.Errorf("invalid format version %q", data)
+		}
+		version = v
+		return nil
+	})
+	return version, err
+}
+
+// SetFormatVersion stores the vault format version
+func (s *Storage) SetFormatVersion(version int) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return putFormat(tx, version)
+	})
+}
+
 // SetSalt stores the KDF salt
 func (s *Storage) SetSalt(salt []byte) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		config := tx.Bucket(ConfigBucket)
 		return config.Put(ConfigSalt, salt)
 	})
@@ -122,7 +289,7 @@ func (s *Storage) GetSalt() ([]byte, error) {
 
 // SetIterations stores the KDF iterations
 func (s *Storage) SetIterations(iterations uint32) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		config := tx.Bucket(ConfigBucket)
 		iters := make([]byte, 4)
 		binary.BigEndian.PutUint32(iters, iterations)
@@ -148,13 +315,63 @@ func (s *Storage) GetIterations() (uint32, error) {
 	return iterations, err
 }
 
-// UpdateModified updates the last modified timestamp
+// touch records a change to the vault: it updates the modification time and
+// increments the generation
+func touch(tx *bolt.Tx) error {
+	// The hash cache describes one working tree and has no place in the
+	// committed vault
+	if tx.Bucket(CacheBucket) != nil {
+		if err := tx.DeleteBucket(CacheBucket); err != nil {
+			return err
+		}
+	}
+	config := tx.Bucket(ConfigBucket)
+	modified, _ := time.Now().MarshalBinary()
+	if err := config.Put(ConfigModified, modified); err != nil {
+		return err
+	}
+	return config.Put(ConfigGen, binary.BigEndian.AppendUint64(nil, getGeneration(config)+1))
+}
+
+// getGeneration reads the generation, which is 0 for vaults written before
+// generations were recorded
+func getGeneration(config *bolt.Bucket) uint64 {
+	data := config.Get(ConfigGen)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// UpdateModified updates the last modified timestamp and increments the
+// generation. Operations call it once, after all their writes.
 func (s *Storage) UpdateModified() error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(touch)
+}
+
+// GetGeneration returns the number of changes made to the vault
+func (s *Storage) GetGeneration() (uint64, error) {
+	var generation uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
 		config := tx.Bucket(ConfigBucket)
-		now := time.Now()
-		modified, _ := now.MarshalBinary()
-		return config.Put(ConfigModified, modified)
+		if config == nil {
+			return fmt.Errorf("config bucket not found")
+		}
+		generation = getGeneration(config)
+		return nil
+	})
+	return generation, err
+}
+
+// RaiseGeneration sets the generation to at least min, so that the next
+// change puts the vault ahead of a vault merged into it
+func (s *Storage) RaiseGeneration(min uint64) error {
+	return s.update(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if getGeneration(config) >= min {
+			return nil
+		}
+		return config.Put(ConfigGen, binary.BigEndian.AppendUint64(nil, min))
 	})
 }
 
@@ -175,6 +392,34 @@ func (s *Storage) GetModified() (time.Time, error) {
 	return modified, err
 }
 
+// GetCreated retrieves the creation timestamp
+func (s *Storage) GetCreated() (time.Time, error) {
+	var created time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if config == nil {
+			return fmt.Errorf("config bucket not found")
+		}
+		data := config.Get(ConfigCreated)
+		if data == nil {
+			return fmt.Errorf("created time not found")
+		}
+		return created.UnmarshalBinary(data)
+	})
+	return created, err
+}
+
+// SetCreated replaces the creation timestamp
+func (s *Storage) SetCreated(created time.Time) error {
+	return s.update(func(tx *bolt.Tx) error {
+		data, err := created.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(ConfigBucket).Put(ConfigCreated, data)
+	})
+}
+
 // GetVaultID retrieves the vault ID from config bucket
 func (s *Storage) GetVaultID() (string, error) {
 	var vaultID string
@@ -208,7 +453,7 @@ func (s *Storage) GetOrCreateVaultID() (string, error) {
 	vaultID = hex.EncodeToString(b)
 
 	// Store it
-	err = s.db.Update(func(tx *bolt.Tx) error {
+	err = s.update(func(tx *bolt.Tx) error {
 		config := tx.Bucket(ConfigBucket)
 		return config.Put(ConfigVaultID, []byte(vaultID))
 	})
@@ -229,7 +474,7 @@ type ManifestEntry struct {
 
 // UpdateManifest updates a file entry in the manifest
 func (s *Storage) UpdateManifest(path string, size int64, modTime time.Time, hash string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		manifest := tx.Bucket(IndexBucket)
 		entry := ManifestEntry{
 			Path:    path,
@@ -247,7 +492,7 @@ func (s *Storage) UpdateManifest(path string, size int64, modTime time.Time, has
 
 // RemoveFromManifest removes a file from the manifest
 func (s *Storage) RemoveFromManifest(path string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		manifest := tx.Bucket(IndexBucket)
 		return manifest.Delete([]byte(path))
 	})
@@ -273,6 +518,32 @@ func (s *Storage) GetManifest() ([]ManifestEntry, error) {
 	return entries, err
 }
 
+// ReplaceManifest replaces the whole manifest with entries. The index
+// bucket is recreated, so entries that no longer parse are dropped too.
+func (s *Storage) ReplaceManifest(entries []ManifestEntry) error {
+	return s.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(IndexBucket) != nil {
+			if err := tx.DeleteBucket(IndexBucket); err != nil {
+				return err
+			}
+		}
+		manifest, err := tx.CreateBucket(IndexBucket)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := manifest.Put([]byte(entry.Path), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // GetManifestEntry returns a single manifest entry
 func (s *Storage) GetManifestEntry(path string) (*ManifestEntry, error) {
 	var entry *ManifestEntry
@@ -291,44 +562,281 @@ func (s *Storage) GetManifestEntry(path string) (*ManifestEntry, error) {
 	return entry, err
 }
 
-// StoreFileData stores encrypted file data
-func (s *Storage) StoreFileData(path string, encryptedData []byte) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+// ChunkSize is the largest encrypted blob stored as a single value. Larger
+// blobs are split into ChunkSize records in ChunksBucket, so bbolt never
+// needs long runs of contiguous overflow pages and freed space is reused.
+const ChunkSize = 64 * 1024
+
+// chunkKey returns the key of chunk n of path in ChunksBucket. The index is
+// fixed-width so chunks sort in order and a path's chunks can't be confused
+// with those of a file below it (path/0 and so on).
+func chunkKey(path string, n int) []byte {
+	return fmt.Appendf(nil, "%s/%08d", path, n)
+}
+
+// isChunkOf reports whether key is a chunk of path
+func isChunkOf(key []byte, path string) bool {
+	rest, ok := bytes.CutPrefix(key, []byte(path+"/"))
+	if !ok || len(rest) != 8 {
+		return false
+	}
+	for _, c := range rest {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteFileData removes the blob and all chunks of path
+func deleteFileData(tx *bolt.Tx, path string) error {
+	if err := tx.Bucket(BlobsBucket).Delete([]byte(path)); err != nil {
+		return err
+	}
+	chunks := tx.Bucket(ChunksBucket)
+	if chunks == nil {
+		return nil
+	}
+	c := chunks.Cursor()
+	for k, _ := c.Seek(chunkKey(path, 0)); k != nil && isChunkOf(k, path); k, _ = c.Next() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putFileData replaces the stored data of path, splitting data over
+// ChunkSize into chunks
+func putFileData(ctx context.Context, tx *bolt.Tx, path string, encryptedData []byte) error {
+	if err := deleteFileData(tx, path); err != nil {
+		return err
+	}
+	if len(encryptedData) <= ChunkSize {
+		return tx.Bucket(BlobsBucket).Put([]byte(path), encryptedData)
+	}
+
+	// Vaults created before chunked storage lack the bucket
+	chunks, err := tx.CreateBucketIfNotExists(ChunksBucket)
+	if err != nil {
+		return err
+	}
+	for n := 0; len(encryptedData) > 0; n++ {
+		// Cancelling rolls the whole transaction back
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		size := min(ChunkSize, len(encryptedData))
+		if err := chunks.Put(chunkKey(path, n), encryptedData[:size]); err != nil {
+			return err
+		}
+		encryptedData = encryptedData[size:]
+	}
+	return nil
+}
+
+// StoreFileData stores encrypted file data, replacing any previous data for
+// path. Data over ChunkSize is split into chunks; in the dir layout it is
+// written to an object in ObjectsDir instead. Nothing is stored if ctx is
+// done before all chunks are written.
+func (s *Storage) StoreFileData(ctx context.Context, path string, encryptedData []byte) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return s.putBlob(ctx, tx, path, encryptedData)
+	})
+}
+
+// WriteFileData stores the encrypted data read from r like StoreFileData,
+// reading it one chunk at a time, so callers can encrypt as they go instead
+// of holding the whole ciphertext. In the dir layout the object is written
+// as r is read; inside the database, bbolt keeps the written chunks until
+// the transaction commits. Nothing is stored if reading r fails or ctx is
+// done before r is read to the end.
+func (s *Storage) WriteFileData(ctx context.Context, path string, r io.Reader) error {
+	return s.update(func(tx *bolt.Tx) error {
+		if err := deleteFileData(tx, path); err != nil {
+			return err
+		}
+		if s.objects != "" {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return s.writeObjectFrom(path, r)
+		}
+		return writeFileData(ctx, tx, path, r)
+	})
+}
+
+// writeFileData stores the data read from r as path, as a single blob if
+// it fits in ChunkSize and in chunks otherwise
+func writeFileData(ctx context.Context, tx *bolt.Tx, path string, r io.Reader) error {
+	// One byte past a chunk tells whether the data fits in a single blob.
+	// bbolt needs every value until the transaction ends, so each chunk
+	// gets a buffer of its own.
+	chunk := make([]byte, ChunkSize+1)
+	n, err := io.ReadFull(r, chunk)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return tx.Bucket(BlobsBucket).Put([]byte(path), chunk[:n])
+	}
+	if err != nil {
+		return err
+	}
+
+	// Vaults created before chunked storage lack the bucket
+	chunks, err := tx.CreateBucketIfNotExists(ChunksBucket)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		// Cancelling rolls the whole transaction back
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := chunks.Put(chunkKey(path, i), chunk[:ChunkSize]); err != nil {
+			return err
+		}
+		ahead := chunk[ChunkSize]
+		chunk = make([]byte, ChunkSize+1)
+		chunk[0] = ahead
+		n, err := io.ReadFull(r, chunk[1:])
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return chunks.Put(chunkKey(path, i+1), chunk[:1+n])
+		default:
+			return err
+		}
+	}
+}
+
+// ReadFileData calls fn with each piece of the encrypted data of path in
+// order: the whole blob, or one chunk at a time. The slices are only valid
+// during the call. Returns an error if no data is stored for path, and
+// ctx.Err() if ctx is done before all chunks are read.
+func (s *Storage) ReadFileData(ctx context.Context, path string, fn func(piece []byte) error) error {
+	if s.objects != "" {
+		data, err := s.readObject(path)
+		if err != nil {
+			return err
+		}
+		return fn(data)
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
 		blobs := tx.Bucket(BlobsBucket)
-		return blobs.Put([]byte(path), encryptedData)
+		if blobs == nil {
+			return fmt.Errorf("blobs bucket not found")
+		}
+		if data := blobs.Get([]byte(path)); data != nil {
+			return fn(data)
+		}
+
+		chunks := tx.Bucket(ChunksBucket)
+		if chunks == nil {
+			return fmt.Errorf("file not found")
+		}
+		c := chunks.Cursor()
+		k, v := c.Seek(chunkKey(path, 0))
+		if k == nil || !isChunkOf(k, path) {
+			return fmt.Errorf("file not found")
+		}
+		for ; k != nil && isChunkOf(k, path); k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
 
 // GetFileData retrieves encrypted file data
-func (s *Storage) GetFileData(path string) ([]byte, error) {
+func (s *Storage) GetFileData(ctx context.Context, path string) ([]byte, error) {
 	var data []byte
+	err := s.ReadFileData(ctx, path, func(piece []byte) error {
+		// Copy, since the slice is only valid during the transaction
+		data = append(data, piece...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// HasFileData reports whether encrypted data is stored for path. Files added
+// with track have no data until they are first locked.
+func (s *Storage) HasFileData(path string) (bool, error) {
+	if s.objects != "" {
+		_, err := os.Stat(filepath.Join(s.objects, ObjectName(path)))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	var found bool
 	err := s.db.View(func(tx *bolt.Tx) error {
 		blobs := tx.Bucket(BlobsBucket)
 		if blobs == nil {
 			return fmt.Errorf("blobs bucket not found")
 		}
-		data = blobs.Get([]byte(path))
-		if data == nil {
-			return fmt.Errorf("file not found")
+		if blobs.Get([]byte(path)) != nil {
+			found = true
+			return nil
+		}
+		if chunks := tx.Bucket(ChunksBucket); chunks != nil {
+			found = chunks.Get(chunkKey(path, 0)) != nil
 		}
-		// Make a copy since the slice is only valid during the transaction
-		data = append([]byte(nil), data...)
 		return nil
 	})
-	return data, err
+	return found, err
 }
 
 // RemoveFile removes a file from storage
 func (s *Storage) RemoveFile(path string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		blobs := tx.Bucket(BlobsBucket)
-		return blobs.Delete([]byte(path))
+	return s.update(func(tx *bolt.Tx) error {
+		return s.deleteBlob(tx, path)
+	})
+}
+
+// Migration holds the re-encrypted records that move a vault to a new format
+type Migration struct {
+	Format  int               // Format version to record
+	Files   map[string][]byte // Encrypted file data by path
+	Private map[string][]byte // Records for the private bucket, by key; nil removes one
+}
+
+// ApplyMigration writes all records of m and the new format version in a
+// single transaction, so the vault is either fully migrated or unchanged
+func (s *Storage) ApplyMigration(ctx context.Context, m *Migration) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for path, data := range m.Files {
+			if err := s.putBlob(ctx, tx, path, data); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		private := tx.Bucket(PrivateBucket)
+		for key, data := range m.Private {
+			if data == nil {
+				if err := private.Delete([]byte(key)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := private.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		if err := putFormat(tx, m.Format); err != nil {
+			return err
+		}
+		return touch(tx)
 	})
 }
 
 // StoreMetadataBytes stores encrypted metadata bytes
 func (s *Storage) StoreMetadataBytes(key string, encryptedData []byte) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(tx *bolt.Tx) error {
 		private := tx.Bucket(PrivateBucket)
 		return private.Put([]byte(key), encryptedData)
 	})
@@ -369,65 +877,420 @@ func (s *Storage) GetTrackedFiles() ([]string, error) {
 	return files, err
 }
 
+// GetConfig returns the config record under key, or nil if there is none.
+// Records other than the ones Storage maintains itself are encoded by the
+// caller.
+func (s *Storage) GetConfig(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if config == nil {
+			return fmt.Errorf("config bucket not found")
+		}
+		if data := config.Get([]byte(key)); data != nil {
+			// Make a copy since the slice is only valid during the transaction
+			value = append([]byte{}, data...)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// PutConfig stores value as the config record under key
+func (s *Storage) PutConfig(key string, value []byte) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(ConfigBucket).Put([]byte(key), value)
+	})
+}
+
+// DeleteConfig removes the config record under key, if there is one
+func (s *Storage) DeleteConfig(key string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(ConfigBucket).Delete([]byte(key))
+	})
+}
+
+// ListConfig returns the keys of all config records in order
+func (s *Storage) ListConfig() ([]string, error) {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if config == nil {
+			return fmt.Errorf("config bucket not found")
+		}
+		return config.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// Recipient is a public key or cloud KMS key that can unwrap the vault key
+type Recipient struct {
+	Type        string `json:"type"`            // Key type: "ssh-ed25519", "aws-kms" or "gcp-kms"
+	PublicKey   string `json:"publicKey"`       // authorized_keys formatted public key
+	Comment     string `json:"comment"`         // Key comment (usually user@host)
+	Fingerprint string `json:"fingerprint"`     // SHA256 fingerprint (KMS: key ID), used as the bucket key
+	Ephemeral   []byte `json:"ephemeral"`       // Ephemeral public key for the key exchange
+	WrappedKey  []byte `json:"wrappedKey"`      // Vault key encrypted to the recipient
+	KeyID       string `json:"keyId,omitempty"` // KMS key ARN or resource name (KMS recipients only)
+}
+
+// StoreRecipient adds or replaces a recipient
+func (s *Storage) StoreRecipient(r Recipient) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Vaults created before recipient support lack the bucket
+		bucket, err := tx.CreateBucketIfNotExists(RecipientsBucket)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(r.Fingerprint), data)
+	})
+}
+
+// GetRecipients returns all recipients
+func (s *Storage) GetRecipients() ([]Recipient, error) {
+	var recipients []Recipient
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(RecipientsBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var r Recipient
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			recipients = append(recipients, r)
+			return nil
+		})
+	})
+	return recipients, err
+}
+
+// RemoveRecipient removes a recipient by fingerprint
+func (s *Storage) RemoveRecipient(fingerprint string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(RecipientsBucket)
+		if bucket == nil || bucket.Get([]byte(fingerprint)) == nil {
+			return fmt.Errorf("recipient not found")
+		}
+		return bucket.Delete([]byte(fingerprint))
+	})
+}
+
+// Domain is a separate encryption domain with its own password
+type Domain struct {
+	Name       string `json:"name"`
+	Salt       []byte `json:"salt"`
+	Iterations uint32 `json:"iterations"`
+	Check      []byte `json:"check"` // Password check encrypted with the domain key
+}
+
+// StoreDomain adds or replaces an encryption domain
+func (s *Storage) StoreDomain(d Domain) error {
+	return s.update(func(tx *bolt.Tx) error {
+		// Vaults created before domain support lack the bucket
+		bucket, err := tx.CreateBucketIfNotExists(DomainsBucket)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(d.Name), data)
+	})
+}
+
+// GetDomain returns the named domain, or nil if it does not exist
+func (s *Storage) GetDomain(name string) (*Domain, error) {
+	var domain *Domain
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(DomainsBucket)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(name))
+		if data == nil {
+			return nil
+		}
+		domain = &Domain{}
+		return json.Unmarshal(data, domain)
+	})
+	return domain, err
+}
+
+// GetDomains returns all encryption domains sorted by name
+func (s *Storage) GetDomains() ([]Domain, error) {
+	var domains []Domain
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(DomainsBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var d Domain
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			domains = append(domains, d)
+			return nil
+		})
+	})
+	return domains, err
+}
+
+// RemoveDomain removes an encryption domain by name
+func (s *Storage) RemoveDomain(name string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(DomainsBucket)
+		if bucket == nil || bucket.Get([]byte(name)) == nil {
+			return fmt.Errorf("domain not found")
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+// SpaceStats describes how space is used in the database file
+type SpaceStats struct {
+	FileSize  int64            // Size of the database file
+	PageSize  int              // Database page size
+	FreePages int              // Free and pending pages, reclaimable by Compact
+	BlobSizes map[string]int64 // Encrypted size of each stored file
+}
+
+// SpaceStats reports database space usage and per-file encrypted sizes
+func (s *Storage) SpaceStats() (*SpaceStats, error) {
+	dbStats := s.db.Stats()
+	stats := &SpaceStats{
+		PageSize:  s.db.Info().PageSize,
+		FreePages: dbStats.FreePageN + dbStats.PendingPageN,
+		BlobSizes: make(map[string]int64),
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stats.FileSize = tx.Size()
+		blobs := tx.Bucket(BlobsBucket)
+		if blobs == nil {
+			return nil
+		}
+		err := blobs.ForEach(func(k, v []byte) error {
+			stats.BlobSizes[string(k)] = int64(len(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		chunks := tx.Bucket(ChunksBucket)
+		if chunks == nil {
+			return nil
+		}
+		return chunks.ForEach(func(k, v []byte) error {
+			path := string(k[:bytes.LastIndexByte(k, '/')])
+			stats.BlobSizes[path] += int64(len(v))
+			return nil
+		})
+	})
+	if err != nil || s.objects == "" {
+		return stats, err
+	}
+
+	paths, err := s.objectPaths()
+	for _, path := range paths {
+		info, err := os.Stat(filepath.Join(s.objects, ObjectName(path)))
+		if err != nil {
+			return nil, err
+		}
+		stats.BlobSizes[path] = info.Size()
+	}
+	return stats, err
+}
+
+// WriteTo writes a consistent snapshot of the database to w. In the dir
+// layout the objects are folded back in, so the snapshot is a self-contained
+// single-file vault.
+func (s *Storage) WriteTo(w io.Writer) (int64, error) {
+	if s.objects != "" {
+		return s.writeInlined(w)
+	}
+	var n int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// compactTxMaxSize bounds the size of each copy transaction during Compact
+const compactTxMaxSize = 64 * 1024
+
 // Compact creates a compacted copy of the database, removing unused space.
 // This is useful after deleting files to reclaim disk space.
+// The copy keeps the original file permissions and is checked for integrity and
+// identical contents before it atomically replaces the original.
 func (s *Storage) Compact() error {
 	srcPath := s.db.Path()
 	tmpPath := srcPath + ".compact"
 
-	// Create new database
-	dst, err := bolt.Open(tmpPath, 0600, nil)
+	info, err := os.Stat(srcPath)
 	if err != nil {
-		return fmt.Errorf("failed to create compact database: %w", err)
+		return fmt.Errorf("failed to stat database: %w", err)
 	}
+	mode := info.Mode().Perm()
 
-	// Copy all buckets
-	err = s.db.View(func(srcTx *bolt.Tx) error {
-		return dst.Update(func(dstTx *bolt.Tx) error {
-			return srcTx.ForEach(func(name []byte, srcBucket *bolt.Bucket) error {
-				dstBucket, err := dstTx.CreateBucketIfNotExists(name)
-				if err != nil {
-					return err
-				}
-				return srcBucket.ForEach(func(k, v []byte) error {
-					return dstBucket.Put(k, v)
-				})
-			})
-		})
-	})
+	// Remove leftovers from an interrupted compact
+	_ = os.Remove(tmpPath)
 
+	// Create new database
+	dst, err := bolt.Open(tmpPath, mode, nil)
 	if err != nil {
+		return fmt.Errorf("failed to create compact database: %w", err)
+	}
+
+	if err := bolt.Compact(dst, s.db, compactTxMaxSize); err != nil {
 		dst.Close()
 		os.Remove(tmpPath)
 		return fmt.Errorf("failed to copy data: %w", err)
 	}
 
+	if err := verifyCompacted(s.db, dst); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
 	if err := dst.Close(); err != nil {
 		os.Remove(tmpPath)
 		return fmt.Errorf("failed to close compact database: %w", err)
 	}
 
+	// The umask may have narrowed the mode on create
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	// From here on the database is closed; every return reopens it, the
+	// original or the compacted one, so the Storage stays usable
 	if err := s.db.Close(); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("failed to close source database: %w", err)
+		return s.reopen(srcPath, mode, fmt.Errorf("failed to close source database: %w", err))
 	}
 
 	// Atomic replace
 	backupPath := srcPath + ".backup"
 	if err := os.Rename(srcPath, backupPath); err != nil {
-		return fmt.Errorf("failed to backup original: %w", err)
+		os.Remove(tmpPath)
+		return s.reopen(srcPath, mode, fmt.Errorf("failed to backup original: %w", err))
 	}
 	if err := os.Rename(tmpPath, srcPath); err != nil {
-		_ = os.Rename(backupPath, srcPath) // rollback
-		return fmt.Errorf("failed to replace database: %w", err)
+		err = fmt.Errorf("failed to replace database: %w", err)
+		if rollbackErr := os.Rename(backupPath, srcPath); rollbackErr != nil {
+			// The original is still in place under its backup name
+			return s.reopen(backupPath, mode, errors.Join(err, fmt.Errorf("failed to restore original: %w", rollbackErr)))
+		}
+		os.Remove(tmpPath)
+		return s.reopen(srcPath, mode, err)
 	}
 	_ = os.Remove(backupPath)
 
-	// Reopen database
-	s.db, err = bolt.Open(srcPath, 0600, nil)
+	// Make the renames durable
+	if err := syncDir(filepath.Dir(srcPath)); err != nil {
+		return s.reopen(srcPath, mode, fmt.Errorf("failed to sync directory: %w", err))
+	}
+
+	return s.reopen(srcPath, mode, nil)
+}
+
+// reopen opens the database at path with mode in place of the closed one
+// and returns err, joined with the error of opening if that fails
+func (s *Storage) reopen(path string, mode os.FileMode, err error) error {
+	db, openErr := bolt.Open(path, mode, nil)
+	if openErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to reopen database: %w", openErr))
+	}
+	s.db = db
+	return err
+}
+
+// verifyCompacted checks the compacted database for structural errors and
+// compares a checksum of its contents with the source
+func verifyCompacted(src, dst *bolt.DB) error {
+	err := dst.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to reopen database: %w", err)
+		return fmt.Errorf("compacted database failed integrity check: %w", err)
 	}
 
+	srcSum, err := contentChecksum(src)
+	if err != nil {
+		return fmt.Errorf("failed to checksum database: %w", err)
+	}
+	dstSum, err := contentChecksum(dst)
+	if err != nil {
+		return fmt.Errorf("failed to checksum compacted database: %w", err)
+	}
+	if !bytes.Equal(srcSum, dstSum) {
+		return fmt.Errorf("compacted database contents differ from the original")
+	}
 	return nil
 }
+
+// contentChecksum hashes every bucket name, key and value in the database
+func contentChecksum(db *bolt.DB) ([]byte, error) {
+	h := sha256.New()
+	write := func(b []byte) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+
+	var walk func(b *bolt.Bucket) error
+	walk = func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			write(k)
+			if v == nil {
+				// Nested bucket
+				return walk(b.Bucket(k))
+			}
+			write(v)
+			return nil
+		})
+	}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			write(name)
+			return walk(b)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// syncDir flushes directory entries (such as a rename) to disk.
+// Windows does not support syncing directories, so it is a no-op there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
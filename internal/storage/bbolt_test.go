@@ -1,9 +1,21 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
+
+	"github.com/illarion/lockenv/internal/logging"
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestOpenAndInitialize(t *testing.T) {
@@ -32,6 +44,54 @@ func TestOpenAndInitialize(t *testing.T) {
 	}
 }
 
+func TestOpen_RefusesNewerFormat(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.lockenv")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	saved := Release
+	Release = "9.1.0"
+	err = db.SetFormatVersion(CurrentFormat + 1)
+	Release = saved
+	if err != nil {
+		t.Fatalf("Failed to set format version: %v", err)
+	}
+	db.Close()
+
+	_, err = Open(dbPath)
+	if !errors.Is(err, ErrVaultTooNew) {
+		t.Fatalf("Expected ErrVaultTooNew, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "requires lockenv >= 9.1.0") {
+		t.Errorf("Expected required release in error, got %q", err)
+	}
+
+	// Vaults written before min_reader are gated on their format version
+	raw, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open raw database: %v", err)
+	}
+	err = raw.Update(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if err := config.Delete(ConfigMinReader); err != nil {
+			return err
+		}
+		return config.Delete(ConfigMinReaderRelease)
+	})
+	raw.Close()
+	if err != nil {
+		t.Fatalf("Failed to remove min_reader: %v", err)
+	}
+	if _, err := Open(dbPath); !errors.Is(err, ErrVaultTooNew) {
+		t.Errorf("Expected ErrVaultTooNew without min_reader, got %v", err)
+	}
+}
+
 func TestSaltAndIterations(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.lockenv")
@@ -157,12 +217,12 @@ func TestFileStorage(t *testing.T) {
 
 	// Store file data
 	data := []byte("encrypted file content")
-	if err := db.StoreFileData("secret.txt", data); err != nil {
+	if err := db.StoreFileData(context.Background(), "secret.txt", data); err != nil {
 		t.Fatalf("Failed to store file data: %v", err)
 	}
 
 	// Get file data
-	retrieved, err := db.GetFileData("secret.txt")
+	retrieved, err := db.GetFileData(context.Background(), "secret.txt")
 	if err != nil {
 		t.Fatalf("Failed to get file: %v", err)
 	}
@@ -177,12 +237,289 @@ func TestFileStorage(t *testing.T) {
 	}
 
 	// Check removed
-	_, err = db.GetFileData("secret.txt")
+	_, err = db.GetFileData(context.Background(), "secret.txt")
 	if err == nil {
 		t.Error("Expected error for removed file")
 	}
 }
 
+func TestFileStorage_Chunked(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	big := make([]byte, 3*ChunkSize+5)
+	for i := range big {
+		big[i] = byte(i % 251)
+	}
+	if err := db.StoreFileData(context.Background(), "data/model.bin", big); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	// A file whose path looks like a chunk key of another file
+	if err := db.StoreFileData(context.Background(), "data/model.bin/00000000", []byte("small")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+
+	retrieved, err := db.GetFileData(context.Background(), "data/model.bin")
+	if err != nil {
+		t.Fatalf("Failed to get file: %v", err)
+	}
+	if !bytes.Equal(retrieved, big) {
+		t.Errorf("Chunked data mismatch: got %d bytes, want %d", len(retrieved), len(big))
+	}
+
+	var pieces int
+	if err := db.ReadFileData(context.Background(), "data/model.bin", func(piece []byte) error {
+		pieces++
+		if len(piece) > ChunkSize {
+			t.Errorf("piece of %d bytes exceeds ChunkSize", len(piece))
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadFileData failed: %v", err)
+	}
+	if pieces != 4 {
+		t.Errorf("ReadFileData returned %d pieces, want 4", pieces)
+	}
+
+	if found, err := db.HasFileData("data/model.bin"); err != nil || !found {
+		t.Errorf("HasFileData = %v, %v; want true", found, err)
+	}
+	stats, err := db.SpaceStats()
+	if err != nil {
+		t.Fatalf("SpaceStats failed: %v", err)
+	}
+	if stats.BlobSizes["data/model.bin"] != int64(len(big)) || stats.BlobSizes["data/model.bin/00000000"] != 5 {
+		t.Errorf("BlobSizes = %v", stats.BlobSizes)
+	}
+
+	// Shrinking the file below ChunkSize drops its chunks
+	if err := db.StoreFileData(context.Background(), "data/model.bin", []byte("tiny")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	if retrieved, _ := db.GetFileData(context.Background(), "data/model.bin"); string(retrieved) != "tiny" {
+		t.Errorf("after shrinking got %q, want tiny", retrieved)
+	}
+	if stats, _ := db.SpaceStats(); stats.BlobSizes["data/model.bin"] != 4 {
+		t.Errorf("chunks left after shrinking: %v", stats.BlobSizes)
+	}
+	if err := db.StoreFileData(context.Background(), "data/model.bin", big); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+
+	if err := db.RemoveFile("data/model.bin"); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if found, _ := db.HasFileData("data/model.bin"); found {
+		t.Error("chunks left after RemoveFile")
+	}
+	if retrieved, err := db.GetFileData(context.Background(), "data/model.bin/00000000"); err != nil || string(retrieved) != "small" {
+		t.Errorf("neighbouring file = %q, %v; want small", retrieved, err)
+	}
+	stats, _ = db.SpaceStats()
+	if _, ok := stats.BlobSizes["data/model.bin"]; ok {
+		t.Errorf("removed file still in BlobSizes: %v", stats.BlobSizes)
+	}
+}
+
+func TestFileStorage_Cancel(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "data/model.bin", []byte("old")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+
+	// Cancelling between chunks rolls the write back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	big := make([]byte, 3*ChunkSize+5)
+	if err := db.StoreFileData(ctx, "data/model.bin", big); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if retrieved, err := db.GetFileData(context.Background(), "data/model.bin"); err != nil || string(retrieved) != "old" {
+		t.Errorf("Expected the old data kept, got %q, %v", retrieved, err)
+	}
+
+	if err := db.StoreFileData(context.Background(), "data/model.bin", big); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	if _, err := db.GetFileData(ctx, "data/model.bin"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled reading chunks, got %v", err)
+	}
+}
+
+func TestWriteFileData(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, size := range []int{0, 5, ChunkSize, ChunkSize + 1, 3*ChunkSize + 5} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		if err := db.WriteFileData(ctx, "model.bin", bytes.NewReader(data)); err != nil {
+			t.Fatalf("size %d: WriteFileData failed: %v", size, err)
+		}
+		// Stored the same way StoreFileData stores it
+		var pieces int
+		if err := db.ReadFileData(ctx, "model.bin", func(piece []byte) error {
+			pieces++
+			return nil
+		}); err != nil {
+			t.Fatalf("size %d: ReadFileData failed: %v", size, err)
+		}
+		if want := max(1, (size+ChunkSize-1)/ChunkSize); pieces != want {
+			t.Errorf("size %d: stored in %d pieces, want %d", size, pieces, want)
+		}
+		if retrieved, err := db.GetFileData(ctx, "model.bin"); err != nil || !bytes.Equal(retrieved, data) {
+			t.Errorf("size %d: got %d bytes, %v", size, len(retrieved), err)
+		}
+	}
+
+	// A failing reader leaves the previous data in place
+	if err := db.StoreFileData(ctx, "model.bin", []byte("old")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	failing := io.MultiReader(bytes.NewReader(make([]byte, 2*ChunkSize)), iotest.ErrReader(errors.New("read failed")))
+	if err := db.WriteFileData(ctx, "model.bin", failing); err == nil {
+		t.Fatal("Expected WriteFileData to fail")
+	}
+	if retrieved, err := db.GetFileData(ctx, "model.bin"); err != nil || string(retrieved) != "old" {
+		t.Errorf("Expected the old data kept, got %q, %v", retrieved, err)
+	}
+}
+
+func TestApplyMigration(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := db.SetFormatVersion(FormatV1); err != nil {
+		t.Fatalf("Failed to set format version: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "a.env", []byte("old")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	if err := db.StoreMetadataBytes("stale", []byte("old")); err != nil {
+		t.Fatalf("Failed to store private record: %v", err)
+	}
+
+	big := bytes.Repeat([]byte("x"), ChunkSize+1)
+	err = db.ApplyMigration(context.Background(), &Migration{
+		Format:  FormatV2,
+		Files:   map[string][]byte{"a.env": big},
+		Private: map[string][]byte{"datakey": []byte("wrapped"), "stale": nil},
+	})
+	if err != nil {
+		t.Fatalf("ApplyMigration failed: %v", err)
+	}
+
+	format, err := db.GetFormatVersion()
+	if err != nil || format != FormatV2 {
+		t.Errorf("Expected format v2, got %d (%v)", format, err)
+	}
+	if data, err := db.GetFileData(context.Background(), "a.env"); err != nil || !bytes.Equal(data, big) {
+		t.Errorf("File data not replaced: %d bytes (%v)", len(data), err)
+	}
+	if data, err := db.GetMetadataBytes("datakey"); err != nil || string(data) != "wrapped" {
+		t.Errorf("Private record not stored: %q (%v)", data, err)
+	}
+	if _, err := db.GetMetadataBytes("stale"); err == nil {
+		t.Error("Expected nil private record to be removed")
+	}
+}
+
+func TestUpdateModified_DropsHashCache(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	// Older releases cached hashes of local files in the vault
+	err = db.update(func(tx *bolt.Tx) error {
+		cache, err := tx.CreateBucket(CacheBucket)
+		if err != nil {
+			return err
+		}
+		return cache.Put([]byte(".env"), []byte(`{"hash":"h"}`))
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache bucket: %v", err)
+	}
+	if err := db.UpdateModified(); err != nil {
+		t.Fatalf("UpdateModified failed: %v", err)
+	}
+	db.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(CacheBucket) != nil {
+			t.Error("Expected the cache bucket dropped by the next change")
+		}
+		return nil
+	})
+}
+
+func TestGeneration(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	if gen, err := db.GetGeneration(); err != nil || gen != 0 {
+		t.Fatalf("Expected generation 0 for a new vault, got %d (%v)", gen, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.UpdateModified(); err != nil {
+			t.Fatalf("UpdateModified failed: %v", err)
+		}
+	}
+	if gen, err := db.GetGeneration(); err != nil || gen != 2 {
+		t.Errorf("Expected generation 2, got %d (%v)", gen, err)
+	}
+
+	if err := db.RaiseGeneration(10); err != nil {
+		t.Fatalf("RaiseGeneration failed: %v", err)
+	}
+	if err := db.RaiseGeneration(5); err != nil {
+		t.Fatalf("RaiseGeneration failed: %v", err)
+	}
+	if gen, err := db.GetGeneration(); err != nil || gen != 10 {
+		t.Errorf("Expected generation 10, got %d (%v)", gen, err)
+	}
+}
+
 func TestMetadataStorage(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.lockenv")
@@ -214,6 +551,187 @@ func TestMetadataStorage(t *testing.T) {
 	}
 }
 
+func TestConfigRecords(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	if value, err := db.GetConfig("stash"); err != nil || value != nil {
+		t.Fatalf("GetConfig(stash) = %q, %v, want none", value, err)
+	}
+	if err := db.PutConfig("stash", []byte(`{"files":["a.env"]}`)); err != nil {
+		t.Fatalf("PutConfig failed: %v", err)
+	}
+	if value, err := db.GetConfig("stash"); err != nil || string(value) != `{"files":["a.env"]}` {
+		t.Errorf("GetConfig(stash) = %q, %v", value, err)
+	}
+	keys, err := db.ListConfig()
+	if err != nil {
+		t.Fatalf("ListConfig failed: %v", err)
+	}
+	if !slices.Contains(keys, "stash") || !slices.Contains(keys, string(ConfigVersion)) || !slices.IsSorted(keys) {
+		t.Errorf("ListConfig() = %v", keys)
+	}
+
+	if err := db.DeleteConfig("stash"); err != nil {
+		t.Fatalf("DeleteConfig failed: %v", err)
+	}
+	if value, _ := db.GetConfig("stash"); value != nil {
+		t.Errorf("Expected the record removed, got %q", value)
+	}
+	if err := db.DeleteConfig("stash"); err != nil {
+		t.Errorf("Deleting a missing record failed: %v", err)
+	}
+}
+
+func TestSpaceStats(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.lockenv")
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	if err := db.StoreFileData(context.Background(), "a.txt", make([]byte, 100)); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "b.txt", make([]byte, 20000)); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	// Deleting the large blob leaves free pages behind
+	if err := db.RemoveFile("b.txt"); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	stats, err := db.SpaceStats()
+	if err != nil {
+		t.Fatalf("Failed to get space stats: %v", err)
+	}
+
+	if stats.PageSize <= 0 {
+		t.Errorf("Expected positive page size, got %d", stats.PageSize)
+	}
+	if stats.FileSize <= 0 {
+		t.Errorf("Expected positive file size, got %d", stats.FileSize)
+	}
+	if stats.FreePages == 0 {
+		t.Error("Expected free pages after removing a blob")
+	}
+	if len(stats.BlobSizes) != 1 || stats.BlobSizes["a.txt"] != 100 {
+		t.Errorf("Expected only a.txt with 100 bytes, got %v", stats.BlobSizes)
+	}
+}
+
+func TestCompact_PreservesContentsAndPermissions(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.lockenv")
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "keep.txt", []byte("keep")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "drop.txt", make([]byte, 20000)); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+	if err := db.RemoveFile("drop.txt"); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if err := os.Chmod(dbPath, 0640); err != nil {
+		t.Fatalf("Failed to chmod database: %v", err)
+	}
+
+	before, err := contentChecksum(db.db)
+	if err != nil {
+		t.Fatalf("Failed to checksum database: %v", err)
+	}
+
+	if err := db.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	after, err := contentChecksum(db.db)
+	if err != nil {
+		t.Fatalf("Failed to checksum compacted database: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Error("Compact changed database contents")
+	}
+
+	data, err := db.GetFileData(context.Background(), "keep.txt")
+	if err != nil || string(data) != "keep" {
+		t.Errorf("Expected keep.txt to survive compact, got %q, %v", data, err)
+	}
+
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to stat database: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected permissions 0640, got %o", info.Mode().Perm())
+	}
+	for _, leftover := range []string{dbPath + ".compact", dbPath + ".backup"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", leftover)
+		}
+	}
+}
+
+func TestCompact_ReopensOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.lockenv")
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "keep.txt", []byte("keep")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+
+	// A non-empty directory in the way makes moving the original aside fail
+	if err := os.MkdirAll(filepath.Join(dbPath+".backup", "x"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Compact(); err == nil {
+		t.Fatal("Expected Compact to fail")
+	}
+
+	// The original is open again and nothing is left behind
+	if data, err := db.GetFileData(context.Background(), "keep.txt"); err != nil || string(data) != "keep" {
+		t.Errorf("Database unusable after a failed compact: %q, %v", data, err)
+	}
+	if err := db.StoreFileData(context.Background(), "new.txt", []byte("new")); err != nil {
+		t.Errorf("Database not writable after a failed compact: %v", err)
+	}
+	if _, err := os.Stat(dbPath + ".compact"); !os.IsNotExist(err) {
+		t.Error("Expected the compacted copy to be removed")
+	}
+}
+
 func TestPersistence(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.lockenv")
@@ -234,7 +752,7 @@ func TestPersistence(t *testing.T) {
 		t.Fatalf("Failed to set salt: %v", err)
 	}
 
-	if err := db.StoreFileData("test.txt", []byte("data")); err != nil {
+	if err := db.StoreFileData(context.Background(), "test.txt", []byte("data")); err != nil {
 		t.Fatalf("Failed to store file data: %v", err)
 	}
 
@@ -254,7 +772,7 @@ func TestPersistence(t *testing.T) {
 	}
 
 	// Check data persisted
-	data, err := db2.GetFileData("test.txt")
+	data, err := db2.GetFileData(context.Background(), "test.txt")
 	if err != nil {
 		t.Fatalf("Failed to get file: %v", err)
 	}
@@ -263,3 +781,73 @@ func TestPersistence(t *testing.T) {
 		t.Error("File data not persisted correctly")
 	}
 }
+
+func TestDebugTracesTransactions(t *testing.T) {
+	var buf bytes.Buffer
+	logging.Configure(&buf, slog.LevelDebug)
+	defer logging.Reset()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "secret.txt", []byte("s3cret")); err != nil {
+		t.Fatalf("Failed to store file data: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{`msg="open vault"`, `msg="tx commit" op=StoreFileData`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("debug log missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Error("debug log contains file contents")
+	}
+}
+
+func TestReplaceManifest(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.lockenv"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+	if err := db.UpdateManifest("old.env", 1, time.Now(), "old"); err != nil {
+		t.Fatalf("UpdateManifest failed: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "a.env", bytes.Repeat([]byte("x"), ChunkSize+1)); err != nil {
+		t.Fatalf("StoreFileData failed: %v", err)
+	}
+	if err := db.StoreFileData(context.Background(), "b.env", []byte("b")); err != nil {
+		t.Fatalf("StoreFileData failed: %v", err)
+	}
+
+	if err := db.ReplaceManifest([]ManifestEntry{{Path: "a.env", Size: 2, Hash: "aa"}}); err != nil {
+		t.Fatalf("ReplaceManifest failed: %v", err)
+	}
+	entries, err := db.GetManifest()
+	if err != nil || len(entries) != 1 || entries[0].Path != "a.env" || entries[0].Hash != "aa" {
+		t.Errorf("GetManifest = %+v, %v", entries, err)
+	}
+
+	// Chunked and whole blobs are both listed, once each
+	paths, err := db.GetBlobPaths()
+	if err != nil || len(paths) != 2 || paths[0] != "a.env" || paths[1] != "b.env" {
+		t.Errorf("GetBlobPaths = %v, %v", paths, err)
+	}
+
+	created := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	if err := db.SetCreated(created); err != nil {
+		t.Fatalf("SetCreated failed: %v", err)
+	}
+	if got, err := db.GetCreated(); err != nil || !got.Equal(created) {
+		t.Errorf("GetCreated = %v, %v", got, err)
+	}
+}
@@ -1,13 +1,43 @@
 // Package storage provides the BBolt database interface for lockenv.
 //
-// Database structure uses four buckets:
-//   - config: KDF parameters (salt, iterations), timestamps (unencrypted)
+// Core works against the Backend interface, made of ConfigStore,
+// ManifestStore, BlobStore and PrivateStore so helpers can take only the part
+// of a vault they use. Storage, the bbolt database, is registered as
+// DefaultBackend; other implementations are added with Register and selected
+// by name with OpenBackend. Layouts are not part of Backend: Storage
+// implements the optional Layouts interface, and LayoutOf and ConvertLayout
+// treat any other backend as having only LayoutFile.
+//
+// Only bbolt and Memory ship with lockenv. A database backend such as SQLite
+// would implement Backend and Register itself from its own package, keeping
+// its driver out of this module.
+//
+// Memory, registered as MemoryBackend, keeps the same buckets in maps, one
+// vault per path for the life of the process. It lets tests and library users
+// work without a vault file, and its WriteTo emits a regular .lockenv.
+//
+// Database structure uses the following buckets:
+//   - config: Format version, KDF parameters (salt, iterations), timestamps, generation, tracked directories, stash, relock and sync state (unencrypted)
 //   - index: File paths, sizes, modification times (unencrypted, for ls/status)
-//   - blobs: Encrypted file contents
-//   - private: Encrypted checksums and detailed file metadata
+//   - blobs: Encrypted file contents up to ChunkSize
+//   - chunks: Larger encrypted contents split into ChunkSize records keyed path/00000000, path/00000001, ... (optional)
+//   - private: Password check and detailed file metadata (encrypted); from
+//     format v2 also the wrapped data key and the index MAC. Format v3 checks
+//     the password with an HMAC instead of an encrypted checksum.
+//   - recipients: Vault key wrapped for SSH public keys or KMS keys (optional)
+//   - domains: KDF parameters and password checks for encryption domains (optional)
+//
+// Releases before the hash cache moved to the user cache directory also kept
+// a cache bucket of local file hashes; it is deleted on the next change.
+//
+// In the dir layout (config key layout = "dir") the blobs and chunks buckets
+// stay empty and each file's encrypted contents live in their own object in
+// .lockenv.d/ next to the database, named after the %-escaped path, so git
+// sees a change to one file as a change to one object.
 //
 // The unencrypted index bucket enables lockenv ls and lockenv status
 // to work without requiring a password, improving UX for common operations.
+// From format v2 on, its contents are authenticated by the index MAC.
 //
 // BBolt provides ACID transactions, file locking, and corruption detection.
 package storage
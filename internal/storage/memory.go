@@ -0,0 +1,722 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MemoryBackend is the name of the in-memory backend
+const MemoryBackend = "memory"
+
+// Memory is a Backend that keeps the vault in memory. It holds the same
+// buckets and records as Storage, so WriteTo produces a regular single-file
+// vault. Opening the memory backend by path returns the same Memory for the
+// same path until DiscardMemory, so a vault survives being closed and
+// reopened within the process; NewMemory returns one of its own.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+var _ Backend = (*Memory)(nil)
+
+// NewMemory returns an empty in-memory vault
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]map[string][]byte)}
+}
+
+var (
+	memoryMu     sync.Mutex
+	memoryVaults = make(map[string]*Memory)
+)
+
+// openMemory returns the in-memory vault at path, creating it if needed
+func openMemory(path string) (Backend, error) {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	m, ok := memoryVaults[path]
+	if !ok {
+		m = NewMemory()
+		memoryVaults[path] = m
+	}
+	return m, nil
+}
+
+// memoryExists reports whether the memory backend has a vault at path
+func memoryExists(path string) bool {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	_, ok := memoryVaults[path]
+	return ok
+}
+
+// DiscardMemory drops the in-memory vault at path
+func DiscardMemory(path string) {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	delete(memoryVaults, path)
+}
+
+// get returns a copy of the value of key in bucket, or nil if there is none
+func (m *Memory) get(bucket, key []byte) []byte {
+	value, ok := m.buckets[string(bucket)][string(key)]
+	if !ok {
+		return nil
+	}
+	return append([]byte{}, value...)
+}
+
+// put stores a copy of value under key in bucket, creating the bucket
+func (m *Memory) put(bucket, key, value []byte) {
+	b := m.buckets[string(bucket)]
+	if b == nil {
+		b = make(map[string][]byte)
+		m.buckets[string(bucket)] = b
+	}
+	b[string(key)] = append([]byte{}, value...)
+}
+
+// del removes key from bucket
+func (m *Memory) del(bucket, key []byte) {
+	delete(m.buckets[string(bucket)], string(key))
+}
+
+// keys returns the keys of bucket in order, as bbolt iterates them
+func (m *Memory) keys(bucket []byte) []string {
+	b := m.buckets[string(bucket)]
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// config returns the config bucket, or an error if the vault has none
+func (m *Memory) config() (map[string][]byte, error) {
+	config := m.buckets[string(ConfigBucket)]
+	if config == nil {
+		return nil, fmt.Errorf("config bucket not found")
+	}
+	return config, nil
+}
+
+// putJSON stores v encoded as JSON under key in bucket
+func (m *Memory) putJSON(bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.put(bucket, key, data)
+	return nil
+}
+
+// putFormat records the format version and the oldest format a reader
+// must support
+func (m *Memory) putFormat(format int) {
+	value := []byte(strconv.Itoa(format))
+	m.put(ConfigBucket, ConfigVersion, value)
+	m.put(ConfigBucket, ConfigMinReader, value)
+	m.put(ConfigBucket, ConfigMinReaderRelease, []byte(Release))
+}
+
+// generation reads the generation, 0 if none is recorded
+func (m *Memory) generation() uint64 {
+	data := m.buckets[string(ConfigBucket)][string(ConfigGen)]
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// touch updates the modification time and increments the generation
+func (m *Memory) touch() {
+	delete(m.buckets, string(CacheBucket))
+	modified, _ := time.Now().MarshalBinary()
+	m.put(ConfigBucket, ConfigModified, modified)
+	m.put(ConfigBucket, ConfigGen, binary.BigEndian.AppendUint64(nil, m.generation()+1))
+}
+
+// Close does nothing: the vault stays in memory until it is discarded
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Initialize creates the bucket structure for a new lockenv
+func (m *Memory) Initialize() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, bucket := range [][]byte{ConfigBucket, IndexBucket, BlobsBucket, PrivateBucket} {
+		if m.buckets[string(bucket)] == nil {
+			m.buckets[string(bucket)] = make(map[string][]byte)
+		}
+	}
+	m.putFormat(CurrentFormat)
+	created, _ := time.Now().MarshalBinary()
+	m.put(ConfigBucket, ConfigCreated, created)
+	m.put(ConfigBucket, ConfigModified, created)
+	return nil
+}
+
+// IsInitialized checks if the vault has been initialized
+func (m *Memory) IsInitialized() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(ConfigBucket, ConfigVersion) != nil, nil
+}
+
+// GetFormatVersion returns the vault format version
+func (m *Memory) GetFormatVersion() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	config, err := m.config()
+	if err != nil {
+		return 0, err
+	}
+	data, ok := config[string(ConfigVersion)]
+	if !ok {
+		return 0, fmt.Errorf("version not found")
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid format version %q", data)
+	}
+	return version, nil
+}
+
+// SetFormatVersion stores the vault format version
+func (m *Memory) SetFormatVersion(version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putFormat(version)
+	return nil
+}
+
+// SetSalt stores the KDF salt
+func (m *Memory) SetSalt(salt []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(ConfigBucket, ConfigSalt, salt)
+	return nil
+}
+
+// GetSalt retrieves the KDF salt
+func (m *Memory) GetSalt() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.config(); err != nil {
+		return nil, err
+	}
+	salt := m.get(ConfigBucket, ConfigSalt)
+	if salt == nil {
+		return nil, fmt.Errorf("salt not found")
+	}
+	return salt, nil
+}
+
+// SetIterations stores the KDF iterations
+func (m *Memory) SetIterations(iterations uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(ConfigBucket, ConfigIters, binary.BigEndian.AppendUint32(nil, iterations))
+	return nil
+}
+
+// GetIterations retrieves the KDF iterations
+func (m *Memory) GetIterations() (uint32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.config(); err != nil {
+		return 0, err
+	}
+	iters := m.get(ConfigBucket, ConfigIters)
+	if len(iters) != 4 {
+		return 0, fmt.Errorf("iterations not found")
+	}
+	return binary.BigEndian.Uint32(iters), nil
+}
+
+// UpdateModified updates the last modified timestamp and increments the
+// generation
+func (m *Memory) UpdateModified() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.touch()
+	return nil
+}
+
+// GetGeneration returns the number of changes made to the vault
+func (m *Memory) GetGeneration() (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.config(); err != nil {
+		return 0, err
+	}
+	return m.generation(), nil
+}
+
+// RaiseGeneration sets the generation to at least min
+func (m *Memory) RaiseGeneration(min uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generation() < min {
+		m.put(ConfigBucket, ConfigGen, binary.BigEndian.AppendUint64(nil, min))
+	}
+	return nil
+}
+
+// getTime decodes the timestamp under key, named what in errors
+func (m *Memory) getTime(key []byte, what string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var t time.Time
+	if _, err := m.config(); err != nil {
+		return t, err
+	}
+	data := m.get(ConfigBucket, key)
+	if data == nil {
+		return t, fmt.Errorf("%s time not found", what)
+	}
+	return t, t.UnmarshalBinary(data)
+}
+
+// GetModified retrieves the last modified timestamp
+func (m *Memory) GetModified() (time.Time, error) {
+	return m.getTime(ConfigModified, "modified")
+}
+
+// GetCreated retrieves the creation timestamp
+func (m *Memory) GetCreated() (time.Time, error) {
+	return m.getTime(ConfigCreated, "created")
+}
+
+// SetCreated replaces the creation timestamp
+func (m *Memory) SetCreated(created time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := created.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	m.put(ConfigBucket, ConfigCreated, data)
+	return nil
+}
+
+// GetVaultID retrieves the vault ID
+func (m *Memory) GetVaultID() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.config(); err != nil {
+		return "", err
+	}
+	data := m.get(ConfigBucket, ConfigVaultID)
+	if data == nil {
+		return "", fmt.Errorf("vault_id not found")
+	}
+	return string(data), nil
+}
+
+// GetOrCreateVaultID retrieves the existing vault ID or generates a new one
+func (m *Memory) GetOrCreateVaultID() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if data := m.get(ConfigBucket, ConfigVaultID); data != nil {
+		return string(data), nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate vault ID: %w", err)
+	}
+	vaultID := hex.EncodeToString(b)
+	m.put(ConfigBucket, ConfigVaultID, []byte(vaultID))
+	return vaultID, nil
+}
+
+// UpdateManifest updates a file entry in the manifest
+func (m *Memory) UpdateManifest(path string, size int64, modTime time.Time, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.putJSON(IndexBucket, []byte(path), ManifestEntry{Path: path, Size: size, ModTime: modTime, Hash: hash})
+}
+
+// RemoveFromManifest removes a file from the manifest
+func (m *Memory) RemoveFromManifest(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.del(IndexBucket, []byte(path))
+	return nil
+}
+
+// GetManifest returns all entries in the manifest, sorted by path
+func (m *Memory) GetManifest() ([]ManifestEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[string(IndexBucket)] == nil {
+		return nil, fmt.Errorf("index bucket not found")
+	}
+	var entries []ManifestEntry
+	for _, path := range m.keys(IndexBucket) {
+		var entry ManifestEntry
+		if err := json.Unmarshal(m.buckets[string(IndexBucket)][path], &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ReplaceManifest replaces the whole manifest with entries
+func (m *Memory) ReplaceManifest(entries []ManifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buckets[string(IndexBucket)] = make(map[string][]byte)
+	for _, entry := range entries {
+		if err := m.putJSON(IndexBucket, []byte(entry.Path), entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetManifestEntry returns a single manifest entry, or nil if path is not
+// in the manifest
+func (m *Memory) GetManifestEntry(path string) (*ManifestEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[string(IndexBucket)] == nil {
+		return nil, fmt.Errorf("index bucket not found")
+	}
+	data := m.get(IndexBucket, []byte(path))
+	if data == nil {
+		return nil, nil
+	}
+	entry := &ManifestEntry{}
+	return entry, json.Unmarshal(data, entry)
+}
+
+// GetTrackedFiles returns all tracked file paths from the manifest
+func (m *Memory) GetTrackedFiles() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[string(IndexBucket)] == nil {
+		return nil, nil
+	}
+	return m.keys(IndexBucket), nil
+}
+
+// StoreFileData stores encrypted file data, replacing any previous data for
+// path. Blobs are kept whole; WriteTo chunks them as Storage would.
+func (m *Memory) StoreFileData(ctx context.Context, path string, encryptedData []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(BlobsBucket, []byte(path), encryptedData)
+	return nil
+}
+
+// WriteFileData stores the encrypted data read from r like StoreFileData
+func (m *Memory) WriteFileData(ctx context.Context, path string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return m.StoreFileData(ctx, path, data)
+}
+
+// ReadFileData calls fn once with the encrypted data of path
+func (m *Memory) ReadFileData(ctx context.Context, path string, fn func(piece []byte) error) error {
+	data, err := m.GetFileData(ctx, path)
+	if err != nil {
+		return err
+	}
+	return fn(data)
+}
+
+// GetFileData retrieves encrypted file data
+func (m *Memory) GetFileData(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[string(BlobsBucket)] == nil {
+		return nil, fmt.Errorf("blobs bucket not found")
+	}
+	data := m.get(BlobsBucket, []byte(path))
+	if data == nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	return data, nil
+}
+
+// HasFileData reports whether encrypted data is stored for path
+func (m *Memory) HasFileData(path string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[string(BlobsBucket)] == nil {
+		return false, fmt.Errorf("blobs bucket not found")
+	}
+	_, ok := m.buckets[string(BlobsBucket)][path]
+	return ok, nil
+}
+
+// GetBlobPaths returns the paths with stored file data, sorted
+func (m *Memory) GetBlobPaths() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keys(BlobsBucket), nil
+}
+
+// RemoveFile removes a file's encrypted data
+func (m *Memory) RemoveFile(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.del(BlobsBucket, []byte(path))
+	return nil
+}
+
+// StoreMetadataBytes stores encrypted metadata bytes
+func (m *Memory) StoreMetadataBytes(key string, encryptedData []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(PrivateBucket, []byte(key), encryptedData)
+	return nil
+}
+
+// GetMetadataBytes retrieves encrypted metadata bytes
+func (m *Memory) GetMetadataBytes(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[string(PrivateBucket)] == nil {
+		return nil, fmt.Errorf("private bucket not found")
+	}
+	data := m.get(PrivateBucket, []byte(key))
+	if data == nil {
+		return nil, fmt.Errorf("metadata not found")
+	}
+	return data, nil
+}
+
+// ApplyMigration writes all records of m and the new format version at once
+func (m *Memory) ApplyMigration(ctx context.Context, migration *Migration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for path, data := range migration.Files {
+		m.put(BlobsBucket, []byte(path), data)
+	}
+	for key, data := range migration.Private {
+		if data == nil {
+			m.del(PrivateBucket, []byte(key))
+			continue
+		}
+		m.put(PrivateBucket, []byte(key), data)
+	}
+	m.putFormat(migration.Format)
+	m.touch()
+	return nil
+}
+
+// GetConfig returns the config record under key, or nil if there is none
+func (m *Memory) GetConfig(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.config(); err != nil {
+		return nil, err
+	}
+	return m.get(ConfigBucket, []byte(key)), nil
+}
+
+// PutConfig stores value as the config record under key
+func (m *Memory) PutConfig(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(ConfigBucket, []byte(key), value)
+	return nil
+}
+
+// DeleteConfig removes the config record under key, if there is one
+func (m *Memory) DeleteConfig(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.del(ConfigBucket, []byte(key))
+	return nil
+}
+
+// ListConfig returns the keys of all config records in order
+func (m *Memory) ListConfig() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.config(); err != nil {
+		return nil, err
+	}
+	return m.keys(ConfigBucket), nil
+}
+
+// StoreRecipient adds or replaces a recipient
+func (m *Memory) StoreRecipient(r Recipient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.putJSON(RecipientsBucket, []byte(r.Fingerprint), r)
+}
+
+// GetRecipients returns all recipients, sorted by fingerprint
+func (m *Memory) GetRecipients() ([]Recipient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var recipients []Recipient
+	for _, key := range m.keys(RecipientsBucket) {
+		var r Recipient
+		if err := json.Unmarshal(m.buckets[string(RecipientsBucket)][key], &r); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// RemoveRecipient removes a recipient by fingerprint
+func (m *Memory) RemoveRecipient(fingerprint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.get(RecipientsBucket, []byte(fingerprint)) == nil {
+		return fmt.Errorf("recipient not found")
+	}
+	m.del(RecipientsBucket, []byte(fingerprint))
+	return nil
+}
+
+// StoreDomain adds or replaces an encryption domain
+func (m *Memory) StoreDomain(d Domain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.putJSON(DomainsBucket, []byte(d.Name), d)
+}
+
+// GetDomain returns the named domain, or nil if it does not exist
+func (m *Memory) GetDomain(name string) (*Domain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data := m.get(DomainsBucket, []byte(name))
+	if data == nil {
+		return nil, nil
+	}
+	domain := &Domain{}
+	return domain, json.Unmarshal(data, domain)
+}
+
+// GetDomains returns all encryption domains sorted by name
+func (m *Memory) GetDomains() ([]Domain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var domains []Domain
+	for _, key := range m.keys(DomainsBucket) {
+		var d Domain
+		if err := json.Unmarshal(m.buckets[string(DomainsBucket)][key], &d); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
+
+// RemoveDomain removes an encryption domain by name
+func (m *Memory) RemoveDomain(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.get(DomainsBucket, []byte(name)) == nil {
+		return fmt.Errorf("domain not found")
+	}
+	m.del(DomainsBucket, []byte(name))
+	return nil
+}
+
+// SpaceStats reports the size of all records and per-file encrypted sizes.
+// Memory has no pages, so PageSize and FreePages are 0.
+func (m *Memory) SpaceStats() (*SpaceStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := &SpaceStats{BlobSizes: make(map[string]int64)}
+	for name, bucket := range m.buckets {
+		for k, v := range bucket {
+			stats.FileSize += int64(len(k) + len(v))
+			if name == string(BlobsBucket) {
+				stats.BlobSizes[k] = int64(len(v))
+			}
+		}
+	}
+	return stats, nil
+}
+
+// WriteTo writes the vault to w as a regular single-file vault, which
+// Storage and every other lockenv can open. The database is built in a
+// temporary file, removed afterwards.
+func (m *Memory) WriteTo(w io.Writer) (int64, error) {
+	tmp, err := os.CreateTemp("", "lockenv-memory-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	db, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer db.Close()
+
+	m.mu.Lock()
+	err = db.Update(func(tx *bolt.Tx) error {
+		for name, bucket := range m.buckets {
+			b, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+			for k, v := range bucket {
+				if name == string(BlobsBucket) {
+					err = putFileData(context.Background(), tx, k, v)
+				} else {
+					err = b.Put([]byte(k), v)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	m.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err = db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// Compact does nothing: memory holds no unused space
+func (m *Memory) Compact() error {
+	return nil
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	if initialized, _ := m.IsInitialized(); initialized {
+		t.Fatal("New memory vault should not be initialized")
+	}
+	if _, err := m.GetSalt(); err == nil {
+		t.Error("Expected error reading the salt of an uninitialized vault")
+	}
+	if err := m.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if version, err := m.GetFormatVersion(); err != nil || version != CurrentFormat {
+		t.Errorf("GetFormatVersion() = %d, %v", version, err)
+	}
+
+	salt := []byte("salt-salt-salt-salt")
+	if err := m.SetSalt(salt); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := m.GetSalt()
+	got[0] = 'X'
+	if again, _ := m.GetSalt(); !bytes.Equal(again, salt) {
+		t.Error("GetSalt should return a copy")
+	}
+	if err := m.SetIterations(1000); err != nil {
+		t.Fatal(err)
+	}
+	if iters, err := m.GetIterations(); err != nil || iters != 1000 {
+		t.Errorf("GetIterations() = %d, %v", iters, err)
+	}
+
+	if err := m.UpdateManifest("b.env", 3, time.Now(), "hb"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateManifest("a.env", 5, time.Now(), "ha"); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := m.GetTrackedFiles(); len(files) != 2 || files[0] != "a.env" {
+		t.Errorf("GetTrackedFiles() = %v, want sorted paths", files)
+	}
+	if err := m.RemoveFromManifest("b.env"); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := m.GetManifestEntry("b.env"); entry != nil {
+		t.Error("RemoveFromManifest should drop the entry")
+	}
+
+	large := bytes.Repeat([]byte("x"), ChunkSize*2+10)
+	if err := m.StoreFileData(context.Background(), "a.env", large); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := m.HasFileData("missing.env"); found {
+		t.Error("HasFileData found data for an unknown path")
+	}
+	if _, err := m.GetFileData(context.Background(), "missing.env"); err == nil {
+		t.Error("Expected error reading data of an unknown path")
+	}
+	if err := m.StoreMetadataBytes("metadata", []byte("encrypted-metadata")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PutConfig("dirs", []byte(`["config"]`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteConfig("other"); err != nil {
+		t.Errorf("DeleteConfig(other) failed: %v", err)
+	}
+	if keys, err := m.ListConfig(); err != nil || !slices.Contains(keys, "dirs") {
+		t.Errorf("ListConfig() = %v, %v", keys, err)
+	}
+	if err := m.RemoveDomain("none"); err == nil {
+		t.Error("Expected error removing an unknown domain")
+	}
+
+	before, _ := m.GetGeneration()
+	if err := m.UpdateModified(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := m.GetGeneration(); after != before+1 {
+		t.Errorf("UpdateModified should bump the generation, got %d after %d", after, before)
+	}
+	// Memory has no objects directory, so only the file layout
+	if layout := LayoutOf(m); layout != LayoutFile {
+		t.Errorf("LayoutOf = %s, want %s", layout, LayoutFile)
+	}
+	if err := ConvertLayout(m, LayoutFile); err != nil {
+		t.Errorf("ConvertLayout(file) failed: %v", err)
+	}
+	if err := ConvertLayout(m, LayoutDir); err == nil {
+		t.Error("Expected error for the dir layout")
+	}
+
+	// The snapshot is a regular vault
+	var snapshot bytes.Buffer
+	if _, err := m.WriteTo(&snapshot); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), ".lockenv")
+	if err := os.WriteFile(path, snapshot.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer db.Close()
+	if data, err := db.GetFileData(context.Background(), "a.env"); err != nil || !bytes.Equal(data, large) {
+		t.Errorf("Snapshot is missing file data: %v", err)
+	}
+	if data, err := db.GetMetadataBytes("metadata"); err != nil || string(data) != "encrypted-metadata" {
+		t.Errorf("Snapshot metadata = %q, %v", data, err)
+	}
+	if dirs, _ := db.GetConfig("dirs"); string(dirs) != `["config"]` {
+		t.Errorf("Snapshot tracked dirs = %s", dirs)
+	}
+	if gen, _ := db.GetGeneration(); gen != before+1 {
+		t.Errorf("Snapshot generation = %d, want %d", gen, before+1)
+	}
+}
+
+func TestMemoryBackendByPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".lockenv")
+	t.Cleanup(func() { DiscardMemory(path) })
+	if Exists(MemoryBackend, path) {
+		t.Fatal("Memory vault should not exist before it is opened")
+	}
+	db, err := OpenBackend(MemoryBackend, path)
+	if err != nil {
+		t.Fatalf("OpenBackend failed: %v", err)
+	}
+	if err := db.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = OpenBackend(MemoryBackend, path)
+	if err != nil {
+		t.Fatalf("OpenBackend failed: %v", err)
+	}
+	if initialized, _ := db.IsInitialized(); !initialized {
+		t.Error("Memory vault should survive Close")
+	}
+	if !Exists(MemoryBackend, path) || Exists(DefaultBackend, path) {
+		t.Error("Expected the vault to exist only in memory")
+	}
+	DiscardMemory(path)
+	if Exists(MemoryBackend, path) {
+		t.Error("DiscardMemory should drop the vault")
+	}
+}
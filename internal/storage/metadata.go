@@ -15,11 +15,54 @@ type Metadata struct {
 
 // FileEntry represents a file entry in the metadata
 type FileEntry struct {
-	Path    string    `json:"path"`
-	Size    int64     `json:"size"`
-	Mode    uint32    `json:"mode"`
-	ModTime time.Time `json:"modTime"`
-	Hash    string    `json:"hash"`
+	Path     string            `json:"path"`
+	Size     int64             `json:"size"`
+	Mode     uint32            `json:"mode"`
+	ModTime  time.Time         `json:"modTime"`
+	Hash     string            `json:"hash"`
+	Domain   string            `json:"domain,omitempty"`   // Encryption domain, empty for the vault password
+	Note     string            `json:"note,omitempty"`     // Annotation set with lockenv note
+	History  []LockEvent       `json:"history,omitempty"`  // Recent locks of new content, oldest first
+	Owner    *Ownership        `json:"owner,omitempty"`    // Recorded only when locking as root
+	Xattrs   map[string][]byte `json:"xattrs,omitempty"`   // Extended attributes at lock time
+	Ref      *Reference        `json:"ref,omitempty"`      // Content lives in another vault
+	Format   string            `json:"format,omitempty"`   // Detected at lock time: dotenv, json, yaml or toml
+	Required bool              `json:"required,omitempty"` // unlock --strict fails if the file is not restored
+}
+
+// Reference points a file entry at a file in another vault, so a secret
+// shared across repositories is stored once
+type Reference struct {
+	VaultID  string `json:"vaultId"`  // ID of the vault holding the content
+	Path     string `json:"path"`     // Path of the file in that vault
+	Location string `json:"location"` // Project directory of that vault when linked, relative to this one
+}
+
+// Ownership is the numeric owner of a file
+type Ownership struct {
+	UID int `json:"uid"`
+	GID int `json:"gid"`
+}
+
+// LockEvent records when new content of a file was locked
+type LockEvent struct {
+	Time time.Time `json:"time"`
+	Hash string    `json:"hash"`
+}
+
+// MaxLockHistory is the number of lock events kept per file
+const MaxLockHistory = 20
+
+// RecordLock adds a lock event to the file's history, unless the content is
+// the same as at the previous lock
+func (f *FileEntry) RecordLock(hash string, at time.Time) {
+	if n := len(f.History); n > 0 && f.History[n-1].Hash == hash {
+		return
+	}
+	f.History = append(f.History, LockEvent{Time: at, Hash: hash})
+	if len(f.History) > MaxLockHistory {
+		f.History = f.History[len(f.History)-MaxLockHistory:]
+	}
 }
 
 // NewMetadata creates a new metadata structure
@@ -41,6 +84,30 @@ func (m *Metadata) AddFile(entry FileEntry) {
 	// Update existing entry if present
 	for i := range m.Files {
 		if m.Files[i].Path == entry.Path {
+			// Relocking keeps the file in its encryption domain, its note, its
+			// lock history, its recorded owner and attributes, its format and
+			// whether it is required
+			if entry.Domain == "" {
+				entry.Domain = m.Files[i].Domain
+			}
+			if entry.Note == "" {
+				entry.Note = m.Files[i].Note
+			}
+			if entry.History == nil {
+				entry.History = m.Files[i].History
+			}
+			if entry.Owner == nil {
+				entry.Owner = m.Files[i].Owner
+			}
+			if entry.Xattrs == nil {
+				entry.Xattrs = m.Files[i].Xattrs
+			}
+			if entry.Format == "" {
+				entry.Format = m.Files[i].Format
+			}
+			if !entry.Required {
+				entry.Required = m.Files[i].Required
+			}
 			m.Files[i] = entry
 			m.Modified = time.Now()
 			return
@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Vault layouts, stored under ConfigLayout
+const (
+	LayoutFile = "file" // File blobs inside the database
+	LayoutDir  = "dir"  // File blobs as separate objects in ObjectsDir
+)
+
+// ObjectsDir is the directory next to the database that holds one encrypted
+// object per file in the dir layout
+const ObjectsDir = ".lockenv.d"
+
+// objectSuffix ends every object name, so temporary files never pass for one
+const objectSuffix = ".enc"
+
+// ObjectName returns the name of the object holding path in ObjectsDir.
+// Bytes other than letters, digits, '.', '-' and '_' are %-escaped, so nested
+// paths map to flat, portable names such as config%2Fprod.env.enc.
+func ObjectName(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-' || c == '_' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String() + objectSuffix
+}
+
+// objectPath reverses ObjectName, returning false for names that are not objects
+func objectPath(name string) (string, bool) {
+	escaped, ok := strings.CutSuffix(name, objectSuffix)
+	if !ok {
+		return "", false
+	}
+	path, err := url.PathUnescape(escaped)
+	return path, err == nil && path != ""
+}
+
+// readLayout returns the layout recorded in the vault, LayoutFile if none is
+func readLayout(db *bolt.DB) (string, error) {
+	layout := LayoutFile
+	err := db.View(func(tx *bolt.Tx) error {
+		config := tx.Bucket(ConfigBucket)
+		if config == nil {
+			return nil
+		}
+		if data := config.Get(ConfigLayout); data != nil {
+			layout = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if layout != LayoutFile && layout != LayoutDir {
+		return "", fmt.Errorf("unknown vault layout %q", layout)
+	}
+	return layout, nil
+}
+
+// Layout returns the vault layout, LayoutFile or LayoutDir
+func (s *Storage) Layout() string {
+	if s.objects != "" {
+		return LayoutDir
+	}
+	return LayoutFile
+}
+
+// putBlob replaces the stored data of path where the layout keeps it
+func (s *Storage) putBlob(ctx context.Context, tx *bolt.Tx, path string, encryptedData []byte) error {
+	if s.objects == "" {
+		return putFileData(ctx, tx, path, encryptedData)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := deleteFileData(tx, path); err != nil {
+		return err
+	}
+	return s.writeObject(path, encryptedData)
+}
+
+// deleteBlob removes the stored data of path in either layout
+func (s *Storage) deleteBlob(tx *bolt.Tx, path string) error {
+	if err := deleteFileData(tx, path); err != nil {
+		return err
+	}
+	if s.objects == "" {
+		return nil
+	}
+	return s.removeObject(path)
+}
+
+// writeObject atomically replaces the object of path with data
+func (s *Storage) writeObject(path string, data []byte) error {
+	return s.writeObjectFrom(path, bytes.NewReader(data))
+}
+
+// writeObjectFrom atomically replaces the object of path with everything
+// read from r
+func (s *Storage) writeObjectFrom(path string, r io.Reader) error {
+	if err := os.MkdirAll(s.objects, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", ObjectsDir, err)
+	}
+	tmp, err := os.CreateTemp(s.objects, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(s.objects, ObjectName(path)))
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return nil
+}
+
+// readObject returns the object of path
+func (s *Storage) readObject(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.objects, ObjectName(path)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file not found")
+	}
+	return data, err
+}
+
+// removeObject removes the object of path if there is one
+func (s *Storage) removeObject(path string) error {
+	err := os.Remove(filepath.Join(s.objects, ObjectName(path)))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove object: %w", err)
+	}
+	return nil
+}
+
+// objectPaths returns the vault paths of all objects in ObjectsDir
+func (s *Storage) objectPaths() ([]string, error) {
+	entries, err := os.ReadDir(s.objects)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if path, ok := objectPath(entry.Name()); ok && entry.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// GetBlobPaths returns the paths with stored file data, in the database or
+// in ObjectsDir, sorted
+func (s *Storage) GetBlobPaths() ([]string, error) {
+	if s.objects == "" {
+		return s.blobPaths()
+	}
+	paths, err := s.objectPaths()
+	sort.Strings(paths)
+	return paths, err
+}
+
+// blobPaths returns the paths with data stored inside the database
+func (s *Storage) blobPaths() ([]string, error) {
+	seen := make(map[string]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if blobs := tx.Bucket(BlobsBucket); blobs != nil {
+			if err := blobs.ForEach(func(k, _ []byte) error {
+				seen[string(k)] = true
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		if chunks := tx.Bucket(ChunksBucket); chunks != nil {
+			return chunks.ForEach(func(k, _ []byte) error {
+				seen[string(k[:bytes.LastIndexByte(k, '/')])] = true
+				return nil
+			})
+		}
+		return nil
+	})
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, err
+}
+
+// SetLayout converts the vault to layout, moving the file blobs between the
+// database and ObjectsDir. Objects are written before the database drops its
+// copies and removed only after it has taken them back, so an interrupted
+// conversion leaves a readable vault. Converting to the dir layout also
+// removes objects of files the vault no longer holds.
+func (s *Storage) SetLayout(layout string) error {
+	switch layout {
+	case LayoutDir:
+		if s.objects != "" {
+			return nil
+		}
+		return s.toDirLayout(filepath.Join(filepath.Dir(s.db.Path()), ObjectsDir))
+	case LayoutFile:
+		if s.objects == "" {
+			return nil
+		}
+		return s.toFileLayout()
+	default:
+		return fmt.Errorf("unknown layout %q (use %s or %s)", layout, LayoutFile, LayoutDir)
+	}
+}
+
+// toDirLayout moves the blobs in the database to objects in dir
+func (s *Storage) toDirLayout(dir string) error {
+	paths, err := s.blobPaths()
+	if err != nil {
+		return err
+	}
+	target := &Storage{db: s.db, objects: dir}
+	keep := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		data, err := s.GetFileData(context.Background(), path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := target.writeObject(path, data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		keep[path] = true
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", ObjectsDir, err)
+	}
+
+	existing, err := target.objectPaths()
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		if !keep[path] {
+			if err := target.removeObject(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	err = s.update(func(tx *bolt.Tx) error {
+		for _, path := range paths {
+			if err := deleteFileData(tx, path); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(ConfigBucket).Put(ConfigLayout, []byte(LayoutDir))
+	})
+	if err != nil {
+		return err
+	}
+	s.objects = dir
+	return nil
+}
+
+// toFileLayout moves the objects back into the database and removes them
+func (s *Storage) toFileLayout() error {
+	paths, err := s.objectPaths()
+	if err != nil {
+		return err
+	}
+	err = s.update(func(tx *bolt.Tx) error {
+		for _, path := range paths {
+			data, err := s.readObject(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if err := putFileData(context.Background(), tx, path, data); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return tx.Bucket(ConfigBucket).Delete(ConfigLayout)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := s.removeObject(path); err != nil {
+			return err
+		}
+	}
+	// Leave the directory if anything else was put there
+	os.Remove(s.objects)
+	s.objects = ""
+	return nil
+}
+
+// writeInlined writes a snapshot of a dir layout vault with the objects
+// folded back into the database, so the copy stands on its own
+func (s *Storage) writeInlined(w io.Writer) (int64, error) {
+	tmp, err := os.CreateTemp("", "lockenv-snapshot-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	err = s.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(tmp)
+		return err
+	})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Bolt directly: Open would find the objects next to the snapshot
+	db, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	paths, err := s.objectPaths()
+	if err == nil {
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, path := range paths {
+				data, err := s.readObject(path)
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				if err := putFileData(context.Background(), tx, path, data); err != nil {
+					return err
+				}
+			}
+			return tx.Bucket(ConfigBucket).Delete(ConfigLayout)
+		})
+	}
+	var n int64
+	if err == nil {
+		err = db.View(func(tx *bolt.Tx) error {
+			var err error
+			n, err = tx.WriteTo(w)
+			return err
+		})
+	}
+	db.Close()
+	return n, err
+}
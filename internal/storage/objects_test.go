@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestObjectName(t *testing.T) {
+	for path, want := range map[string]string{
+		".env":              ".env.enc",
+		"config/prod.env":   "config%2Fprod.env.enc",
+		"keys/tls key.pem":  "keys%2Ftls%20key.pem.enc",
+		"a:b/ü.txt":         "a%3Ab%2F%C3%BC.txt.enc",
+		"deep/er/x_y-1.env": "deep%2Fer%2Fx_y-1.env.enc",
+	} {
+		name := ObjectName(path)
+		if name != want {
+			t.Errorf("ObjectName(%q) = %q, want %q", path, name, want)
+		}
+		if back, ok := objectPath(name); !ok || back != path {
+			t.Errorf("objectPath(%q) = %q, %v, want %q", name, back, ok, path)
+		}
+	}
+	for _, name := range []string{".tmp-123", "notes.txt", ".enc"} {
+		if path, ok := objectPath(name); ok {
+			t.Errorf("objectPath(%q) = %q, expected no object", name, path)
+		}
+	}
+}
+
+func TestSetLayout(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, ".lockenv")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { db.Close() }()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize: %v", err)
+	}
+
+	small := []byte("encrypted-small")
+	large := bytes.Repeat([]byte("x"), ChunkSize*2+10)
+	if err := db.StoreFileData(context.Background(), "a.env", small); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.StoreFileData(context.Background(), "config/big.bin", large); err != nil {
+		t.Fatal(err)
+	}
+	// Left over from an earlier dir layout, not part of the vault
+	objects := filepath.Join(dir, ObjectsDir)
+	if err := os.MkdirAll(objects, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(objects, ObjectName("gone.env")), []byte("stale"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expectData := func(path string, want []byte) {
+		t.Helper()
+		data, err := db.GetFileData(context.Background(), path)
+		if err != nil {
+			t.Fatalf("GetFileData(%s) failed: %v", path, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("GetFileData(%s) returned %d bytes, want %d", path, len(data), len(want))
+		}
+	}
+
+	if err := db.SetLayout(LayoutDir); err != nil {
+		t.Fatalf("SetLayout(dir) failed: %v", err)
+	}
+	if db.Layout() != LayoutDir {
+		t.Errorf("Layout() = %s, want dir", db.Layout())
+	}
+	if paths, _ := db.blobPaths(); len(paths) != 0 {
+		t.Errorf("Expected no blobs left in the database, got %v", paths)
+	}
+	if paths, _ := db.objectPaths(); len(paths) != 2 {
+		t.Errorf("Expected 2 objects, got %v", paths)
+	}
+	expectData("a.env", small)
+	expectData("config/big.bin", large)
+
+	// The layout survives reopening and new data goes to objects
+	db.Close()
+	if db, err = Open(dbPath); err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	if db.Layout() != LayoutDir {
+		t.Fatalf("Layout() after reopen = %s, want dir", db.Layout())
+	}
+	if err := db.StoreFileData(context.Background(), "b.env", []byte("encrypted-b")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(objects, "b.env.enc")); err != nil || string(data) != "encrypted-b" {
+		t.Errorf("Expected object for b.env, got %q, %v", data, err)
+	}
+	if found, err := db.HasFileData("b.env"); err != nil || !found {
+		t.Errorf("HasFileData(b.env) = %v, %v", found, err)
+	}
+	if err := db.RemoveFile("b.env"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := db.HasFileData("b.env"); found {
+		t.Error("Object should be removed with the file")
+	}
+	stats, err := db.SpaceStats()
+	if err != nil {
+		t.Fatalf("SpaceStats failed: %v", err)
+	}
+	if stats.BlobSizes["config/big.bin"] != int64(len(large)) {
+		t.Errorf("Unexpected blob sizes: %v", stats.BlobSizes)
+	}
+
+	// Snapshots are self-contained single-file vaults
+	var snapshot bytes.Buffer
+	if _, err := db.WriteTo(&snapshot); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	copyPath := filepath.Join(t.TempDir(), ".lockenv")
+	if err := os.WriteFile(copyPath, snapshot.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := Open(copyPath)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	if snap.Layout() != LayoutFile {
+		t.Errorf("Snapshot layout = %s, want file", snap.Layout())
+	}
+	if data, err := snap.GetFileData(context.Background(), "config/big.bin"); err != nil || !bytes.Equal(data, large) {
+		t.Errorf("Snapshot is missing file data: %v", err)
+	}
+	snap.Close()
+
+	if err := db.SetLayout(LayoutFile); err != nil {
+		t.Fatalf("SetLayout(file) failed: %v", err)
+	}
+	if db.Layout() != LayoutFile {
+		t.Errorf("Layout() = %s, want file", db.Layout())
+	}
+	if _, err := os.Stat(objects); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", ObjectsDir, err)
+	}
+	expectData("a.env", small)
+	expectData("config/big.bin", large)
+
+	if err := db.SetLayout("tree"); err == nil {
+		t.Error("Expected error for unknown layout")
+	}
+}
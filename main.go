@@ -4,45 +4,233 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/illarion/lockenv/cmd"
+	"github.com/illarion/lockenv/internal/config"
+	"github.com/illarion/lockenv/internal/core"
+	"github.com/illarion/lockenv/internal/crypto"
+	"github.com/illarion/lockenv/internal/logging"
+	"github.com/illarion/lockenv/internal/output"
+	"github.com/illarion/lockenv/internal/storage"
 )
 
+// version is set by goreleaser at build time
+var version = "dev"
+
+// cwdPrefix is the directory lockenv was started in, relative to the vault
+// directory it changed to, or "" if started in the vault directory
+var cwdPrefix string
+
+// enterVaultDir changes to the vault directory dir, recording the directory
+// lockenv was started in for fromCwd
+func enterVaultDir(dir string) error {
+	start, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	vaultDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	prefix, err := filepath.Rel(vaultDir, start)
+	if err != nil {
+		return err
+	}
+	if prefix != "." {
+		cwdPrefix = prefix
+	}
+	return nil
+}
+
+// fromCwd rewrites paths and patterns given relative to the directory
+// lockenv was started in to be relative to the vault directory, like git
+// does with paths given in a subdirectory. Absolute paths and "-" for
+// stdin or stdout are kept.
+func fromCwd(paths ...string) []string {
+	if cwdPrefix == "" {
+		return paths
+	}
+	rewritten := make([]string, len(paths))
+	for i, path := range paths {
+		rewritten[i] = fromCwdPath(path)
+	}
+	return rewritten
+}
+
+// fromCwdPath is fromCwd for a single path; "" is kept for unset flags
+func fromCwdPath(path string) string {
+	if cwdPrefix == "" || path == "" || path == "-" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.ToSlash(filepath.Join(cwdPrefix, path))
+}
+
+// flagPath is fromCwdPath for the value of the path flag name in fs. A
+// default is relative to the vault directory and kept; only a path given on
+// the command line is rewritten.
+func flagPath(fs *flag.FlagSet, name, value string) string {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	if !set {
+		return value
+	}
+	return fromCwdPath(value)
+}
+
 func main() {
+	// Vaults record the release that wrote them for older binaries to report
+	storage.Release = version
+
+	// Keep keys and decrypted secrets out of crash dumps
+	_ = crypto.DisableCoreDumps()
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	args, quiet := parseGlobalFlags(os.Args[1:])
+	if dir := config.VaultDir(); dir != "" {
+		if err := enterVaultDir(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: LOCKENV_VAULT: %s\n", err)
+			os.Exit(1)
+		}
+	} else if len(args) > 0 && args[0] != "init" {
+		// Run from a subdirectory of a vault like git does, with paths
+		// given relative to the subdirectory
+		if dir := core.FindVaultDir("."); dir != "." {
+			if err := enterVaultDir(dir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	cmd.ApplyColor()
+	os.Args = append(os.Args[:1], args...)
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
 	}
+	if quiet && !stdoutCommands[os.Args[1]] {
+		silenceStdout()
+	}
+	start := time.Now()
+	logging.Info("command started", "command", os.Args[1], "version", version)
 
 	switch os.Args[1] {
 	case "init":
 		runInit(ctx, os.Args[2:])
 	case "lock":
 		runLock(ctx, os.Args[2:])
+	case "track":
+		runTrack(ctx, os.Args[2:])
 	case "unlock":
 		runUnlock(ctx, os.Args[2:])
+	case "deploy":
+		runDeploy(ctx, os.Args[2:])
 	case "rm":
 		runRm(ctx, os.Args[2:])
 	case "ls":
 		runLs(ctx, os.Args[2:])
+	case "which":
+		runWhich(ctx, os.Args[2:])
+	case "scan":
+		runScan(ctx, os.Args[2:])
+	case "env":
+		runEnv(ctx, os.Args[2:])
+	case "render":
+		runRender(ctx, os.Args[2:])
+	case "inject":
+		runInject(ctx, os.Args[2:])
+	case "redact":
+		runRedact(ctx, os.Args[2:])
+	case "run":
+		runRun(ctx, os.Args[2:])
+	case "note":
+		runNote(ctx, os.Args[2:])
+	case "require":
+		runRequire(ctx, os.Args[2:])
+	case "show":
+		runShow(ctx, os.Args[2:])
+	case "ci":
+		runCI(ctx, os.Args[2:])
 	case "passwd":
 		runPasswd(ctx, os.Args[2:])
+	case "recover":
+		runRecover(ctx, os.Args[2:])
 	case "diff":
 		runDiff(ctx, os.Args[2:])
+	case "merge":
+		runMerge(ctx, os.Args[2:])
+	case "link":
+		runLink(ctx, os.Args[2:])
+	case "ws":
+		runWorkspace(ctx, os.Args[2:])
 	case "status":
 		runStatus(ctx, os.Args[2:])
 	case "compact":
 		runCompact(ctx, os.Args[2:])
+	case "stats":
+		runStats(ctx, os.Args[2:])
+	case "backup":
+		runBackup(ctx, os.Args[2:])
+	case "migrate":
+		runMigrate(ctx, os.Args[2:])
+	case "push":
+		runPush(ctx, os.Args[2:])
+	case "pull":
+		runPull(ctx, os.Args[2:])
+	case "clean":
+		runClean(ctx, os.Args[2:])
+	case "shred":
+		runShred(ctx, os.Args[2:])
+	case "verify":
+		runVerify(ctx, os.Args[2:])
+	case "check-parity":
+		runCheckParity(ctx, os.Args[2:])
+	case "validate":
+		runValidate(ctx, os.Args[2:])
+	case "fsck":
+		runFsck(ctx, os.Args[2:])
+	case "reindex":
+		cmd.Reindex(ctx)
+	case "split":
+		runSplit(ctx, os.Args[2:])
+	case "stash":
+		runStash(ctx, os.Args[2:])
+	case "guard":
+		runGuard(ctx, os.Args[2:])
 	case "completion":
 		runCompletion(ctx, os.Args[2:])
+	case "shell-hook":
+		runShellHook(ctx, os.Args[2:])
+	case "git-hooks":
+		runGitHooks(ctx, os.Args[2:])
+	case "selfupdate":
+		runSelfUpdate(ctx, os.Args[2:])
+	case "__complete":
+		runComplete(ctx, os.Args[2:])
 	case "keyring":
 		runKeyring(ctx, os.Args[2:])
+	case "session":
+		runSession(ctx, os.Args[2:])
+	case "recipient":
+		runRecipient(ctx, os.Args[2:])
+	case "domain":
+		runDomain(ctx, os.Args[2:])
+	case "meta":
+		runMeta(ctx, os.Args[2:])
 	case "help", "-h", "--help":
 		if len(os.Args) <= 2 {
 			printUsage()
@@ -54,37 +242,207 @@ func main() {
 		printUsage()
 		os.Exit(1)
 	}
+	logging.Info("command finished", "command", os.Args[1], "duration", time.Since(start))
+}
+
+// stdoutCommands print their result to stdout, so --quiet leaves it alone
+var stdoutCommands = map[string]bool{
+	"env": true, "render": true, "inject": true, "redact": true, "run": true, "ci": true,
+	"show": true, "diff": true, "which": true, "scan": true, "completion": true, "shell-hook": true,
+	"__complete": true, "help": true, "-h": true, "--help": true,
+}
+
+// silenceStdout discards everything written to stdout for --quiet, including
+// the output of hooks. Errors still go to stderr.
+func silenceStdout() {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	os.Stdout = devNull
+}
+
+// parseGlobalFlags applies flags accepted by every command (--no-color,
+// --verbose, --debug, --log-file) and returns the remaining arguments and
+// whether --quiet was given. Arguments after "--" are left alone. The
+// LOCKENV_VERBOSE, LOCKENV_DEBUG, LOCKENV_QUIET and LOCKENV_LOG_FILE
+// variables set the defaults.
+func parseGlobalFlags(args []string) ([]string, bool) {
+	rest := make([]string, 0, len(args))
+	level := slog.LevelWarn + 1 // off
+	if config.EnvBool("LOCKENV_DEBUG") {
+		level = slog.LevelDebug
+	} else if config.EnvBool("LOCKENV_VERBOSE") {
+		level = slog.LevelInfo
+	}
+	quiet := config.EnvBool("LOCKENV_QUIET")
+	logFile := os.Getenv("LOCKENV_LOG_FILE")
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		switch {
+		case arg == "--no-color" || arg == "-no-color":
+			output.Disable()
+		case arg == "--verbose" || arg == "-verbose":
+			level = min(level, slog.LevelInfo)
+		case arg == "--debug" || arg == "-debug":
+			level = slog.LevelDebug
+		case arg == "--quiet" || arg == "-quiet":
+			quiet = true
+		case arg == "--log-file" || arg == "-log-file":
+			if i+1 == len(args) {
+				fmt.Fprintln(os.Stderr, "Error: --log-file requires a path")
+				os.Exit(1)
+			}
+			i++
+			logFile = args[i]
+		case strings.HasPrefix(arg, "--log-file="):
+			logFile = strings.TrimPrefix(arg, "--log-file=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if level <= slog.LevelInfo {
+		logging.Configure(os.Stderr, level)
+	}
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot open log file: %s\n", err)
+			os.Exit(1)
+		}
+		logging.AddJSON(f, min(level, slog.LevelInfo))
+	}
+	return rest, quiet
 }
 
-func runInit(_ context.Context, args []string) {
+func runInit(ctx context.Context, args []string) {
 	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	layout := fs.String("layout", storage.LayoutFile, "Vault layout: file or dir")
+	importExisting := fs.Bool("import-existing", false, "Propose files that look like secrets and lock the selected ones")
+	recoveryKey := fs.Bool("recovery-key", false, "Print a one-time recovery key that can reset a lost password")
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	checkLayout(*layout)
 
-	cmd.Init()
+	cmd.Init(ctx, *layout, *recoveryKey, *importExisting)
+}
+
+// checkLayout exits with an error unless layout names a vault layout
+func checkLayout(layout string) {
+	if layout != storage.LayoutFile && layout != storage.LayoutDir {
+		fmt.Fprintf(os.Stderr, "Error: invalid --layout %q (use %s or %s)\n", layout, storage.LayoutFile, storage.LayoutDir)
+		os.Exit(1)
+	}
 }
 
 func runLock(ctx context.Context, args []string) {
 	fs := flag.NewFlagSet("lock", flag.ExitOnError)
 	removeShort := fs.Bool("r", false, "Remove original files after locking")
 	removeLong := fs.Bool("remove", false, "Remove original files after locking")
-	force := fs.Bool("force", false, "Lock without confirmation")
-	if err := fs.Parse(args); err != nil {
+	force := fs.Bool("force", false, "Lock without confirmation and accept large binary files")
+	recursive := fs.Bool("recursive", false, "Track directories with all files inside")
+	fs.BoolVar(recursive, "R", false, "Track directories with all files inside")
+	domain := fs.String("domain", "", "Encrypt the files with this domain's password")
+	noContent := fs.Bool("no-content", false, "Track files without encrypting them yet (same as 'lockenv track')")
+	allowLarge := fs.Bool("allow-large", false, "Lock files larger than max_file_size")
+	sign := fs.Bool("sign", false, "Sign the manifest with the SSH identity after locking")
+	patch := fs.Bool("patch", false, "Pick the hunks of each file to lock")
+	fs.BoolVar(patch, "p", false, "Pick the hunks of each file to lock")
+	showSecrets := fs.Bool("show-secrets", false, "Show values in the hunks offered by --patch")
+	stdin := fs.Bool("stdin", false, "Read the contents of the file named by --as from stdin")
+	as := fs.String("as", "", "Vault path to store the contents read with --stdin under")
+	strict := fs.Bool("strict", false, "Fail instead of warn when a dotenv file has syntax issues")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	files = fromCwd(files...)
+	*as = fromCwdPath(*as)
 
 	remove := *removeShort || *removeLong
 
+	if *stdin || *as != "" {
+		if !*stdin || *as == "" || len(files) > 0 || remove || *recursive || *noContent || *patch {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv lock --stdin --as <path> [--domain <name>] [--allow-large] [--sign]")
+			os.Exit(1)
+		}
+		cmd.LockStdin(ctx, *as, *domain, *allowLarge, *sign)
+		return
+	}
+
+	if *noContent {
+		if len(files) == 0 || remove || *domain != "" {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv lock --no-content [-R|--recursive] <file> [file...]")
+			os.Exit(1)
+		}
+		cmd.Track(ctx, files, *recursive, *allowLarge)
+		return
+	}
+
+	if *patch {
+		if len(files) == 0 || remove || *recursive || *domain != "" {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv lock -p|--patch [--show-secrets] <file> [file...]")
+			os.Exit(1)
+		}
+		cmd.LockPatch(ctx, files, *showSecrets, *sign)
+		return
+	}
+
 	// If file arguments provided, lock those specific files
-	if len(fs.Args()) > 0 {
-		cmd.Lock(ctx, fs.Args(), remove)
+	if len(files) > 0 {
+		cmd.Lock(ctx, files, remove, *recursive, *domain, *allowLarge, *force, *sign, *strict)
 		return
 	}
 	// Otherwise lock all tracked modified files
-	cmd.LockAll(ctx, remove, *force)
+	cmd.LockAll(ctx, remove, *force, *domain, *allowLarge, *sign, *strict)
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments (e.g. "lock secrets/ --recursive") and returns the positionals
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		// Parse stops after "--"; everything following it is positional
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	return positional, nil
+}
+
+func runTrack(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("track", flag.ExitOnError)
+	recursive := fs.Bool("recursive", false, "Track directories with all files inside")
+	fs.BoolVar(recursive, "R", false, "Track directories with all files inside")
+	allowLarge := fs.Bool("allow-large", false, "Track files larger than max_file_size")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	files = fromCwd(files...)
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv track [-R|--recursive] [--allow-large] <file> [file...]")
+		os.Exit(1)
+	}
+
+	cmd.Track(ctx, files, *recursive, *allowLarge)
 }
 
 func runUnlock(ctx context.Context, args []string) {
@@ -92,12 +450,88 @@ func runUnlock(ctx context.Context, args []string) {
 	force := fs.Bool("force", false, "Overwrite local files without asking")
 	keepLocal := fs.Bool("keep-local", false, "Skip all conflicts, keep local versions")
 	keepBoth := fs.Bool("keep-both", false, "Keep both local and vault versions")
+	all := fs.Bool("all", false, "Unlock all files without offering a checklist")
+	relockAfter := fs.Duration("for", 0, "Lock the unlocked files again after this duration (e.g. 2h)")
+	domain := fs.String("domain", "", "Also unlock files in this domain")
+	preserveMode := fs.Bool("preserve-mode", false, "Restore the exact stored mode, including group and other bits")
+	preserveAll := fs.Bool("preserve-all", false, "Restore the stored mode, owner and extended attributes")
+	tarDest := fs.String("tar", "", "Write the files as a tar archive to this file, or - for stdout")
+	strict := fs.Bool("strict", false, "Exit with status 1 if a required file is not restored")
+	requireSig, signers := signatureFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	if *relockAfter < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --for must not be negative")
+		os.Exit(1)
+	}
+
+	preserve := core.PreserveNone
+	switch {
+	case *preserveAll:
+		preserve = core.PreserveAll
+	case *preserveMode:
+		preserve = core.PreserveMode
+	}
+
+	if *tarDest != "" {
+		if *force || *keepLocal || *keepBoth || *relockAfter != 0 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv unlock --tar <file|-> [--domain <name>] [--preserve-mode|--preserve-all] [--require-signature] [--strict] [pattern...]")
+			os.Exit(1)
+		}
+		cmd.UnlockTar(ctx, fromCwdPath(*tarDest), fromCwd(fs.Args()...), *domain, preserve, signersFile(fs, *requireSig, *signers), *strict)
+		return
+	}
+
+	cmd.Unlock(ctx, fromCwd(fs.Args()...), *force, *keepLocal, *keepBoth, *all, *relockAfter, *domain, preserve, signersFile(fs, *requireSig, *signers), *strict)
+}
+
+// signatureFlags registers --require-signature and --signers
+func signatureFlags(fs *flag.FlagSet) (*bool, *string) {
+	require := fs.Bool("require-signature", false, "Fail unless the manifest is signed by a trusted key")
+	signers := fs.String("signers", core.SignersFile, "File with the trusted signing keys")
+	return require, signers
+}
+
+// signersFile returns the trusted keys file if a signature is required, or ""
+func signersFile(fs *flag.FlagSet, require bool, signers string) string {
+	if !require {
+		return ""
+	}
+	return flagPath(fs, "signers", signers)
+}
+
+func runDeploy(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("deploy", flag.ExitOnError)
+	files := fs.String("files", "", "Deploy only the files matching this pattern")
+	domain := fs.String("domain", "", "Also deploy files in this domain")
+	preserveMode := fs.Bool("preserve-mode", false, "Keep the exact stored mode, including group and other bits")
+	preserveAll := fs.Bool("preserve-all", false, "Keep the stored mode, owner and extended attributes")
+	requireSig, signers := signatureFlags(fs)
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rest) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv deploy [user@]host:<dir> [--files <pattern>] [--domain <name>] [--preserve-mode|--preserve-all] [--require-signature] [pattern...]")
+		os.Exit(1)
+	}
 
-	cmd.Unlock(ctx, fs.Args(), *force, *keepLocal, *keepBoth)
+	patterns := fromCwd(rest[1:]...)
+	if *files != "" {
+		patterns = append(patterns, fromCwdPath(*files))
+	}
+	preserve := core.PreserveNone
+	switch {
+	case *preserveAll:
+		preserve = core.PreserveAll
+	case *preserveMode:
+		preserve = core.PreserveMode
+	}
+
+	cmd.Deploy(ctx, rest[0], patterns, *domain, preserve, signersFile(fs, *requireSig, *signers))
 }
 
 func runRm(ctx context.Context, args []string) {
@@ -107,47 +541,272 @@ func runRm(ctx context.Context, args []string) {
 		os.Exit(1)
 	}
 
-	cmd.Remove(ctx, fs.Args())
+	cmd.Remove(ctx, fromCwd(fs.Args()...))
 }
 
 func runLs(ctx context.Context, args []string) {
 	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	opts := statusFlags(fs)
+	patterns, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	opts.Patterns = fromCwd(patterns...)
+
+	cmd.Status(ctx, *opts)
+}
+
+func runEnv(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("env", flag.ExitOnError)
+	profile := fs.String("profile", "", "Overlay .env.<profile> or <profile>.env on .env")
+	format := fs.String("format", core.EnvFormatSh, "Output format: sh, fish, powershell, dotenv or json")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Env(ctx, *profile, *format, fromCwd(files...))
+}
+
+func runRender(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	output := fs.String("o", "", "Write the result to this file instead of stdout")
+	fs.StringVar(output, "output", "", "Write the result to this file instead of stdout")
+	profile := fs.String("profile", "", "Overlay .env.<profile> or <profile>.env on .env")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rest) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv render <template> [file...] [-o <output>] [--profile <name>]")
+		os.Exit(1)
+	}
+
+	cmd.Render(ctx, fromCwdPath(rest[0]), fromCwdPath(*output), *profile, fromCwd(rest[1:]...))
+}
+
+func runInject(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("inject", flag.ExitOnError)
+	toStdout := fs.Bool("stdout", false, "Print the result instead of rewriting the file")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv inject <file> [--stdout]")
+		os.Exit(1)
+	}
+
+	cmd.Inject(ctx, fromCwdPath(rest[0]), *toStdout)
+}
+
+func runRedact(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("redact", flag.ExitOnError)
+	toStdout := fs.Bool("stdout", false, "Print the result instead of rewriting the file")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rest) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv redact <file> [vault-file...] [--stdout]")
+		os.Exit(1)
+	}
+
+	cmd.Redact(ctx, fromCwdPath(rest[0]), *toStdout, fromCwd(rest[1:]...))
+}
+
+// runComplete handles the hidden __complete command used by the shell
+// completion scripts: lockenv __complete <files|profiles|domains> [--shell <shell>] [prefix]
+func runComplete(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("__complete", flag.ExitOnError)
+	shell := fs.String("shell", "", "Quote candidates for this shell")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil || len(rest) == 0 {
+		return
+	}
+
+	prefix := ""
+	if len(rest) > 1 {
+		prefix = rest[1]
+	}
+	cmd.Complete(ctx, rest[0], *shell, prefix)
+}
+
+func runWhich(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("which", flag.ExitOnError)
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv which <KEY>")
+		os.Exit(1)
+	}
+
+	cmd.Which(ctx, fs.Arg(0))
+}
+
+func runScan(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	includeIgnored := fs.Bool("include-ignored", false, "Also list files marked as not secret")
+	ignorePaths := fs.Bool("ignore", false, "Mark the given paths or globs as not secret")
+	unignore := fs.Bool("unignore", false, "Remove the not-secret mark from the given paths or globs")
+	listIgnored := fs.Bool("list-ignored", false, "Print the paths and globs marked as not secret")
+	patterns, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
 
-	cmd.Status(ctx)
+	switch {
+	case *ignorePaths || *unignore:
+		if len(patterns) == 0 || *ignorePaths && *unignore || *includeIgnored || *listIgnored {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv scan --ignore|--unignore <path|glob> [path|glob...]")
+			os.Exit(1)
+		}
+		cmd.ScanIgnore(fromCwd(patterns...), *unignore)
+	case *listIgnored:
+		if len(patterns) > 0 || *includeIgnored {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv scan --list-ignored")
+			os.Exit(1)
+		}
+		cmd.ScanIgnored()
+	default:
+		if len(patterns) > 0 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv scan [--include-ignored]")
+			os.Exit(1)
+		}
+		cmd.Scan(ctx, *includeIgnored)
+	}
 }
 
-func runPasswd(_ context.Context, args []string) {
+func runPasswd(ctx context.Context, args []string) {
 	fs := flag.NewFlagSet("passwd", flag.ExitOnError)
+	rotateSaltOnly := fs.Bool("rotate-salt-only", false, "Keep the password and renew the salt and iterations")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Passwd(ctx, *rotateSaltOnly)
+}
+
+func runRecover(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	key := fs.String("key", "", "Recovery key printed by 'init --recovery-key' (prompted for if omitted)")
+	newKey := fs.Bool("new-key", false, "Create a new recovery key with the current password")
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	if fs.NArg() != 0 || *newKey && *key != "" {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv recover [--key <recovery-key>] | --new-key")
+		os.Exit(1)
+	}
 
-	cmd.Passwd()
+	if *newKey {
+		cmd.RecoverNewKey()
+		return
+	}
+	cmd.Recover(ctx, *key)
 }
 
 func runDiff(ctx context.Context, args []string) {
 	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	rev := fs.String("rev", "", "Compare the vault at this git revision with the current vault")
+	hexdump := fs.Int("hexdump", 0, "Hexdump up to N bytes from the first difference in binary files")
+	showSecrets := fs.Bool("show-secrets", false, "Show secret values instead of masking them")
+	fs.BoolVar(showSecrets, "show-values", false, "Alias for --show-secrets")
+	stat := fs.Bool("stat", false, "Show a summary of changed lines per file instead of the diff")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if (len(files) != 0 && len(files) != 2) || (len(files) == 2 && *rev != "") {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv diff [--rev <revision>] [--stat] [--hexdump <n>] [--show-secrets] [<file-a> <file-b>]")
+		os.Exit(1)
+	}
+
+	cmd.Diff(ctx, fromCwd(files...), *rev, *hexdump, *showSecrets, *stat)
+}
+
+func runMerge(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	force := fs.Bool("force", false, "Use the other vault's version for all conflicts")
+	keepLocal := fs.Bool("keep-local", false, "Keep this vault's version for all conflicts")
+	keepBoth := fs.Bool("keep-both", false, "Keep both versions, storing the other as .from-vault")
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv merge <other-vault> [--force | --keep-local | --keep-both]")
+		os.Exit(1)
+	}
+
+	cmd.Merge(ctx, fromCwdPath(fs.Arg(0)), *force, *keepLocal, *keepBoth)
+}
+
+func runLink(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("link", flag.ExitOnError)
+	as := fs.String("as", "", "Path of the reference in this vault (default: the file's path)")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rest) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv link <vault-dir> <file> [--as <path>]")
+		os.Exit(1)
+	}
 
-	cmd.Diff(ctx)
+	cmd.Link(ctx, fromCwdPath(rest[0]), rest[1], fromCwdPath(*as))
 }
 
 func runStatus(ctx context.Context, args []string) {
 	fs := flag.NewFlagSet("status", flag.ExitOnError)
-	if err := fs.Parse(args); err != nil {
+	prompt := fs.Bool("prompt", false, "Print a short indicator of unlocked files for shell prompts")
+	all := fs.Bool("all", false, "Summarize every vault under the git working tree or current directory")
+	opts := statusFlags(fs)
+	fs.BoolVar(&opts.ExitCode, "exit-code", false, "Exit with status 1 unless every file is unchanged")
+	patterns, err := parseInterspersed(fs, args)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	opts.Patterns = fromCwd(patterns...)
+
+	if *prompt {
+		if opts.ExitCode {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv status --prompt")
+			os.Exit(1)
+		}
+		cmd.StatusPrompt(ctx)
+		return
+	}
+	if *all {
+		cmd.StatusAll(ctx, opts.Full, opts.ExitCode)
+		return
+	}
+	cmd.Status(ctx, *opts)
+}
 
-	cmd.Status(ctx)
+// statusFlags registers the listing flags shared by ls and status
+func statusFlags(fs *flag.FlagSet) *cmd.StatusOptions {
+	opts := &cmd.StatusOptions{}
+	fs.BoolVar(&opts.Full, "full", false, "Hash every file instead of comparing size and modification time")
+	fs.StringVar(&opts.Filter, "filter", "", "Only list modified, unchanged, vault-only or never-locked files")
+	fs.StringVar(&opts.Sort, "sort", "name", "Sort files by name, size or mtime")
+	fs.StringVar(&opts.Columns, "columns", "", "Comma-separated extra columns: size, mtime, mode, hash, note")
+	fs.BoolVar(&opts.Long, "long", false, "Show size, mtime, mode and note columns")
+	fs.BoolVar(&opts.Long, "l", false, "Show size, mtime, mode and note columns")
+	return opts
 }
 
 func runCompact(ctx context.Context, args []string) {
@@ -160,152 +819,1208 @@ func runCompact(ctx context.Context, args []string) {
 	cmd.Compact(ctx)
 }
 
-func runCompletion(_ context.Context, args []string) {
-	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: lockenv completion <bash|zsh|fish|powershell>")
+func runStats(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
-	cmd.Completion(args[0])
+
+	cmd.Stats(ctx)
 }
 
-func runKeyring(_ context.Context, args []string) {
-	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: lockenv keyring <save|delete|status>")
+func runBackup(_ context.Context, args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := fs.String("dir", core.DefaultBackupDir, "Directory to store backups in")
+	keep := fs.Int("keep", core.DefaultBackupKeep, "Number of backups to keep (0 keeps all)")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if *keep < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --keep must not be negative")
 		os.Exit(1)
 	}
 
-	switch args[0] {
-	case "save":
-		cmd.KeyringSave()
-	case "delete":
-		cmd.KeyringDelete()
-	case "status":
-		cmd.KeyringStatus()
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown keyring subcommand: %s\n", args[0])
-		fmt.Fprintln(os.Stderr, "Usage: lockenv keyring <save|delete|status>")
+	cmd.Backup(flagPath(fs, "dir", *dir), *keep)
+}
+
+func runMigrate(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	layout := fs.String("layout", "", "Convert the vault to this layout: file or dir")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
+
+	if *layout != "" {
+		checkLayout(*layout)
+		cmd.MigrateLayout(ctx, *layout)
+		return
+	}
+	cmd.Migrate(ctx)
 }
 
-func printUsage() {
-	fmt.Println("lockenv - Simple, CLI-friendly secret storage")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Println("  lockenv <command> [arguments]")
-	fmt.Println()
-	fmt.Println("Commands:")
-	fmt.Println("  init        Create a .lockenv vault in current directory")
-	fmt.Println("  lock        Encrypt and store files in the vault")
-	fmt.Println("  unlock      Decrypt and restore files from the vault")
-	fmt.Println("  rm          Remove files from the vault")
-	fmt.Println("  ls, status  Show comprehensive vault status")
-	fmt.Println("  passwd      Change vault password")
-	fmt.Println("  diff        Compare vault contents with local files")
-	fmt.Println("  compact     Compact vault to reclaim disk space")
-	fmt.Println("  keyring     Manage password in OS keyring")
-	fmt.Println("  completion  Generate shell completions")
-	fmt.Println("  help        Show help for a command")
-	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("  lockenv init                    # Create new vault")
-	fmt.Println("  lockenv lock .env --rm          # Lock .env and remove original")
-	fmt.Println("  lockenv unlock                  # Unlock all files")
-	fmt.Println("  lockenv status                  # Check vault status")
-	fmt.Println("  lockenv keyring save            # Save password to OS keyring")
-	fmt.Println()
-	fmt.Println("Use 'lockenv help <command>' for more information about a command.")
+func runPush(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	remoteURL := fs.String("remote", "", "Remote URL (default: last synced remote or LOCKENV_REMOTE)")
+	force := fs.Bool("force", false, "Overwrite the remote vault even if it changed")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Push(ctx, *remoteURL, *force)
 }
 
-func printCommandHelp(command string) {
-	switch command {
-	case "init":
-		fmt.Println("lockenv init")
-		fmt.Println()
-		fmt.Println("Creates a .lockenv vault file in the current directory.")
-		fmt.Println("Prompts for a password that will be used for encryption.")
-		fmt.Println("The password is not stored anywhere - you must remember it.")
+func runPull(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("pull", flag.ExitOnError)
+	remoteURL := fs.String("remote", "", "Remote URL (default: last synced remote or LOCKENV_REMOTE)")
+	force := fs.Bool("force", false, "Replace the local vault even if it has unpushed changes")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Pull(ctx, *remoteURL, *force)
+}
+
+func runClean(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	force := fs.Bool("force", false, "Remove files even if they differ from the vault")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Clean(ctx, *force)
+}
+
+func runShred(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("shred", flag.ExitOnError)
+	force := fs.Bool("force", false, "Shred files even if they differ from the vault")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Shred(ctx, *force)
+}
+
+func runVerify(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	paths := fs.Bool("paths", false, "Compare local files with the manifest without a password")
+	strict := fs.Bool("strict", false, "Also fail if tracked files are missing")
+	requireSig, signers := signatureFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if fs.NArg() > 0 && !*paths {
+		fmt.Fprintln(os.Stderr, "Error: file patterns require --paths")
+		os.Exit(1)
+	}
+
+	cmd.Verify(ctx, *paths, fromCwd(fs.Args()...), *strict, signersFile(fs, *requireSig, *signers))
+}
+
+func runCheckParity(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("check-parity", flag.ExitOnError)
+	local := fs.Bool("local", false, "Compare the working-tree files without a password")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(files) < 2 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv check-parity <file> <file> [file...] [--local]")
+		os.Exit(1)
+	}
+
+	cmd.CheckParity(ctx, fromCwd(files...), *local)
+}
+
+func runValidate(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	local := fs.Bool("local", false, "Check the working-tree files without a password")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Validate(ctx, fromCwd(files...), *local)
+}
+
+func runSplit(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	files := fs.String("files", "", "Copy the files matching this pattern")
+	output := fs.String("output", "", "Path of the new vault")
+	newPassword := fs.Bool("new-password", false, "Encrypt the new vault with a different password")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	patterns := fromCwd(rest...)
+	if *files != "" {
+		patterns = append(patterns, fromCwdPath(*files))
+	}
+	if len(patterns) == 0 || *output == "" {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv split --files <pattern> --output <file> [--new-password] [pattern...]")
+		os.Exit(1)
+	}
+
+	cmd.Split(ctx, patterns, fromCwdPath(*output), *newPassword)
+}
+
+func runFsck(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "Fix the issues found")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Fsck(ctx, *repair)
+}
+
+func runStash(ctx context.Context, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs := flag.NewFlagSet("stash", flag.ExitOnError)
+		snapshot := fs.Bool("snapshot", false, "Copy local edits into an encrypted snapshot, leaving files and vault as they are")
+		if err := fs.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		if fs.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv stash [--snapshot]")
+			os.Exit(1)
+		}
+		if *snapshot {
+			cmd.StashSnapshot(ctx)
+			return
+		}
+		cmd.Stash(ctx)
+		return
+	}
+
+	switch args[0] {
+	case "pop":
+		fs := flag.NewFlagSet("stash pop", flag.ExitOnError)
+		force := fs.Bool("force", false, "Overwrite local files without asking")
+		snapshot := fs.Bool("snapshot", false, "Restore the snapshot taken with --snapshot")
+		if err := fs.Parse(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		if *snapshot {
+			cmd.PopSnapshot(ctx, *force)
+			return
+		}
+		cmd.StashPop(ctx, *force)
+	case "show":
+		cmd.StashShow()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown stash subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv stash [pop|show]")
+		os.Exit(1)
+	}
+}
+
+func runGuard(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("guard", flag.ExitOnError)
+	interval := fs.Duration("interval", 30*time.Second, "How often to check the relock timer")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --interval must be positive")
+		os.Exit(1)
+	}
+
+	cmd.Guard(ctx, *interval)
+}
+
+func runCompletion(_ context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv completion <bash|zsh|fish|powershell>")
+		os.Exit(1)
+	}
+	cmd.Completion(args[0])
+}
+
+func runShellHook(_ context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv shell-hook <bash|zsh|fish>")
+		os.Exit(1)
+	}
+	cmd.ShellHook(args[0])
+}
+
+func runGitHooks(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv git-hooks <install|uninstall>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "install", "run":
+		fs := flag.NewFlagSet("git-hooks "+args[0], flag.ExitOnError)
+		autoUnlock := fs.Bool("auto-unlock", false, "Unlock changed vaults while a session is active")
+		stash := fs.Bool("stash", false, "Snapshot local edits before rebases and automatic unlocks")
+		if err := fs.Parse(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		if args[0] == "install" {
+			cmd.GitHooksInstall(*autoUnlock, *stash)
+			return
+		}
+		// Called by the installed hooks with the hook name and git's arguments
+		if fs.NArg() < 1 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv git-hooks run [--auto-unlock] [--stash] <hook> [args...]")
+			os.Exit(1)
+		}
+		cmd.GitHookRun(ctx, fs.Arg(0), fs.Args()[1:], *autoUnlock, *stash)
+	case "uninstall":
+		cmd.GitHooksUninstall()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown git-hooks subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv git-hooks <install|uninstall>")
+		os.Exit(1)
+	}
+}
+
+func runSelfUpdate(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("selfupdate", flag.ExitOnError)
+	check := fs.Bool("check", false, "Only report whether a newer release is available")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.SelfUpdate(ctx, version, *check)
+}
+
+func runKeyring(_ context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv keyring <save|delete|status>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "save":
+		fs := flag.NewFlagSet("keyring save", flag.ExitOnError)
+		ttl := fs.Duration("ttl", 0, "Invalidate the stored password after this duration (e.g. 8h)")
+		if err := fs.Parse(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		if *ttl < 0 {
+			fmt.Fprintln(os.Stderr, "Error: --ttl must not be negative")
+			os.Exit(1)
+		}
+		cmd.KeyringSave(*ttl)
+	case "delete":
+		cmd.KeyringDelete()
+	case "status":
+		cmd.KeyringStatus()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown keyring subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv keyring <save|delete|status>")
+		os.Exit(1)
+	}
+}
+
+func runSession(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv session <start|end|status>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "start":
+		fs := flag.NewFlagSet("session start", flag.ExitOnError)
+		ttl := fs.Duration("ttl", 15*time.Minute, "How long the session stays valid")
+		if err := fs.Parse(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		if *ttl <= 0 {
+			fmt.Fprintln(os.Stderr, "Error: --ttl must be positive")
+			os.Exit(1)
+		}
+		cmd.SessionStart(ctx, *ttl)
+	case "end":
+		cmd.SessionEnd()
+	case "status":
+		cmd.SessionStatus()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown session subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv session <start|end|status>")
+		os.Exit(1)
+	}
+}
+
+func runWorkspace(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv ws <add|rm|list|unlock>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add", "rm":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Usage: lockenv ws %s <vault-dir> [vault-dir...]\n", args[0])
+			os.Exit(1)
+		}
+		if args[0] == "add" {
+			cmd.WorkspaceAdd(fromCwd(args[1:]...))
+		} else {
+			cmd.WorkspaceRemove(fromCwd(args[1:]...))
+		}
+	case "list":
+		cmd.WorkspaceList()
+	case "unlock":
+		fs := flag.NewFlagSet("ws unlock", flag.ExitOnError)
+		force := fs.Bool("force", false, "Overwrite local files without asking")
+		keepLocal := fs.Bool("keep-local", false, "Skip all conflicts, keep local versions")
+		keepBoth := fs.Bool("keep-both", false, "Keep both local and vault versions")
+		if err := fs.Parse(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		cmd.WorkspaceUnlock(ctx, *force, *keepLocal, *keepBoth)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown ws subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv ws <add|rm|list|unlock>")
+		os.Exit(1)
+	}
+}
+
+func runRecipient(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv recipient <add-ssh|add-kms|list|rm>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add-ssh":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv recipient add-ssh <public-key-file>")
+			os.Exit(1)
+		}
+		cmd.RecipientAddSSH(fromCwdPath(args[1]))
+	case "add-kms":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv recipient add-kms <key-arn|key-name>")
+			os.Exit(1)
+		}
+		cmd.RecipientAddKMS(ctx, args[1])
+	case "list", "ls":
+		cmd.RecipientList()
+	case "rm":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv recipient rm <fingerprint|comment>")
+			os.Exit(1)
+		}
+		cmd.RecipientRemove(args[1])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown recipient subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv recipient <add-ssh|add-kms|list|rm>")
+		os.Exit(1)
+	}
+}
+
+func runShow(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	content := fs.Bool("content", false, "Also print the decrypted content")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv show <file> [--content]")
+		os.Exit(1)
+	}
+
+	cmd.Show(ctx, fromCwdPath(rest[0]), *content)
+}
+
+func runNote(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("note", flag.ExitOnError)
+	clear := fs.Bool("clear", false, "Remove the note")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rest) == 0 || (*clear && len(rest) > 1) {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv note <file> [text...] [--clear]")
+		os.Exit(1)
+	}
+
+	rest[0] = fromCwdPath(rest[0])
+	switch {
+	case *clear:
+		cmd.NoteSet(ctx, rest[0], "")
+	case len(rest) == 1:
+		cmd.NoteShow(ctx, rest[0])
+	default:
+		cmd.NoteSet(ctx, rest[0], strings.Join(rest[1:], " "))
+	}
+}
+
+func runRequire(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("require", flag.ExitOnError)
+	optional := fs.Bool("optional", false, "Mark the files optional again")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	if *optional && len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv require [--optional] [file...]")
+		os.Exit(1)
+	}
+
+	cmd.Require(ctx, fromCwd(files...), *optional)
+}
+
+func runRun(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	profile := fs.String("profile", "", "Overlay .env.<profile> or <profile>.env on .env")
+	redact := fs.Bool("redact", false, "Replace secret values in the command's output with ***")
+
+	// Everything after "--" is the command, so its own flags are left alone
+	split := slices.Index(args, "--")
+	if split < 0 || split == len(args)-1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv run [file...] [--profile <name>] [--redact] -- <command> [args...]")
+		os.Exit(1)
+	}
+	files, err := parseInterspersed(fs, args[:split])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	cmd.Run(ctx, *profile, fromCwd(files...), *redact, args[split+1:])
+}
+
+func runCI(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv ci <export-github|export-gitlab>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "export-github":
+		fs := flag.NewFlagSet("ci export-github", flag.ExitOnError)
+		profile := fs.String("profile", "", "Overlay .env.<profile> or <profile>.env on .env")
+		rest, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		cmd.CIExportGitHub(ctx, *profile, fromCwd(rest...))
+	case "export-gitlab":
+		fs := flag.NewFlagSet("ci export-gitlab", flag.ExitOnError)
+		output := fs.String("o", "", "Write the report to this file instead of stdout")
+		fs.StringVar(output, "output", "", "Write the report to this file instead of stdout")
+		profile := fs.String("profile", "", "Overlay .env.<profile> or <profile>.env on .env")
+		rest, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		cmd.CIExportGitLab(ctx, fromCwdPath(*output), *profile, fromCwd(rest...))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown ci subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv ci <export-github|export-gitlab>")
+		os.Exit(1)
+	}
+}
+
+func runDomain(_ context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: lockenv domain <add|list|rm>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv domain add <name>")
+			os.Exit(1)
+		}
+		cmd.DomainAdd(args[1])
+	case "list", "ls":
+		cmd.DomainList()
+	case "rm":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv domain rm <name>")
+			os.Exit(1)
+		}
+		cmd.DomainRemove(args[1])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown domain subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv domain <add|list|rm>")
+		os.Exit(1)
+	}
+}
+
+func runMeta(_ context.Context, args []string) {
+	if len(args) == 0 {
+		cmd.MetaShow()
+		return
+	}
+
+	switch args[0] {
+	case "show":
+		cmd.MetaShow()
+	case "set":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv meta set <description|owner|contact> <value>")
+			os.Exit(1)
+		}
+		cmd.MetaSet(args[1], strings.Join(args[2:], " "))
+	case "unset":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: lockenv meta unset <description|owner|contact>")
+			os.Exit(1)
+		}
+		cmd.MetaSet(args[1], "")
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown meta subcommand: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: lockenv meta [show|set|unset]")
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Println("lockenv - Simple, CLI-friendly secret storage")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  lockenv <command> [arguments]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  init        Create a .lockenv vault in current directory")
+	fmt.Println("  lock        Encrypt and store files in the vault")
+	fmt.Println("  track       Add files to the vault without encrypting them yet")
+	fmt.Println("  unlock      Decrypt and restore files from the vault")
+	fmt.Println("  deploy      Unlock files onto a remote host over SSH")
+	fmt.Println("  rm          Remove files from the vault")
+	fmt.Println("  ls, status  Show comprehensive vault status")
+	fmt.Println("  which       Find the dotenv files that define a variable")
+	fmt.Println("  scan        List untracked files that look like secrets")
+	fmt.Println("  env         Print vault variables as shell export statements")
+	fmt.Println("  render      Render a Go template with vault variables")
+	fmt.Println("  inject      Replace {{lockenv:FILE:KEY}} placeholders with vault values")
+	fmt.Println("  redact      Replace vault values in a file with placeholders")
+	fmt.Println("  show        Show details of a file in the vault")
+	fmt.Println("  note        Annotate a file in the vault")
+	fmt.Println("  require     Mark files that unlock --strict must restore")
+	fmt.Println("  run         Run a command with vault variables in its environment")
+	fmt.Println("  ci          Export vault variables to GitHub Actions or GitLab CI")
+	fmt.Println("  passwd      Change vault password")
+	fmt.Println("  recover     Reset a lost password with the recovery key")
+	fmt.Println("  diff        Compare vault contents with local files")
+	fmt.Println("  merge       Merge another vault's files into this vault")
+	fmt.Println("  link        Reference a file stored in another vault")
+	fmt.Println("  ws          Unlock several vaults in one command")
+	fmt.Println("  compact     Compact vault to reclaim disk space")
+	fmt.Println("  stats       Show how space in the vault is used")
+	fmt.Println("  backup      Save a timestamped copy of the vault")
+	fmt.Println("  migrate     Upgrade the vault to the current format")
+	fmt.Println("  push        Upload the vault to a remote")
+	fmt.Println("  pull        Download the vault from a remote")
+	fmt.Println("  clean       Remove unlocked plaintext files that match the vault")
+	fmt.Println("  shred       Overwrite and remove unlocked plaintext files")
+	fmt.Println("  verify      Check vault integrity or local drift from the vault")
+	fmt.Println("  check-parity  Report variables missing from some environment files")
+	fmt.Println("  validate    Check secret files against .lockenv.schema.json")
+	fmt.Println("  fsck        Check the vault structure, --repair to fix it")
+	fmt.Println("  reindex     Rebuild the plaintext index from the encrypted vault")
+	fmt.Println("  split       Copy matching files into a new standalone vault")
+	fmt.Println("  stash       Lock and remove all plaintext secrets, restore with 'stash pop'")
+	fmt.Println("  guard       Relock files when the 'unlock --for' timer expires")
+	fmt.Println("  keyring     Manage password in OS keyring")
+	fmt.Println("  session     Cache the vault key for the current login session")
+	fmt.Println("  recipient   Manage SSH and KMS keys that can unlock the vault")
+	fmt.Println("  domain      Manage encryption domains with separate passwords")
+	fmt.Println("  meta        Describe the vault: description, owner, contact")
+	fmt.Println("  completion  Generate shell completions")
+	fmt.Println("  shell-hook  Show unlocked secrets in the shell prompt")
+	fmt.Println("  git-hooks   Remind to unlock after git checkouts and merges")
+	fmt.Println("  selfupdate  Update lockenv to the latest release")
+	fmt.Println("  help        Show help for a command")
+	fmt.Println()
+	fmt.Println("Global flags:")
+	fmt.Println("  --no-color         Disable colored output (also set by NO_COLOR)")
+	fmt.Println("  --verbose          Log each file locked, unlocked or skipped to stderr")
+	fmt.Println("  --debug            Also trace vault access, key derivation and conflicts")
+	fmt.Println("  --quiet            Print nothing but errors (except commands whose output is the result)")
+	fmt.Println("  --log-file <path>  Append a JSON log of the operation to a file")
+	fmt.Println()
+	fmt.Println("Global flags and config settings can also be set with LOCKENV_* environment")
+	fmt.Println("variables, e.g. LOCKENV_QUIET=1, LOCKENV_FORCE=1 or LOCKENV_VAULT=<dir>.")
+	fmt.Println()
+	fmt.Println("Like git, lockenv uses the nearest .lockenv in the current directory or its")
+	fmt.Println("parents; paths are relative to the directory of that .lockenv.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  lockenv init                    # Create new vault")
+	fmt.Println("  lockenv lock .env --rm          # Lock .env and remove original")
+	fmt.Println("  lockenv unlock                  # Unlock all files")
+	fmt.Println("  lockenv status                  # Check vault status")
+	fmt.Println("  lockenv keyring save            # Save password to OS keyring")
+	fmt.Println()
+	fmt.Println("Use 'lockenv help <command>' for more information about a command.")
+}
+
+func printCommandHelp(command string) {
+	switch command {
+	case "init":
+		fmt.Println("lockenv init [--layout file|dir] [--recovery-key] [--import-existing]")
+		fmt.Println()
+		fmt.Println("Creates a .lockenv vault file in the current directory.")
+		fmt.Println("Prompts for a password that will be used for encryption.")
+		fmt.Println("The password is not stored anywhere - you must remember it.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --layout dir   Keep each file's encrypted contents in its own object")
+		fmt.Println("                 under .lockenv.d/, so git diffs and merges are per file")
+		fmt.Println("                 (default: file, everything in .lockenv)")
+		fmt.Println("  --import-existing")
+		fmt.Println("                 Find files that look like secrets, as 'lockenv scan' does,")
+		fmt.Println("                 offer them in a checklist and lock the selected ones")
+		fmt.Println("  --recovery-key Print a one-time recovery key that can reset the password")
+		fmt.Println("                 with 'lockenv recover' if it is lost")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv init                     # Create new vault")
+		fmt.Println("  lockenv init --layout dir        # Create a vault with per-file objects")
+		fmt.Println("  lockenv init --import-existing   # Create a vault and lock existing secrets")
+		fmt.Println("  lockenv init --recovery-key      # Create a vault and print a recovery key")
+	case "lock":
+		fmt.Println("lockenv lock [--force] [-r|--remove] [-R|--recursive] [--domain <name>] [--no-content] [--allow-large] [--sign] [--strict] [<file> [file...]]")
+		fmt.Println("lockenv lock -p|--patch [--show-secrets] [--sign] <file> [file...]")
+		fmt.Println("lockenv lock --stdin --as <path> [--domain <name>] [--allow-large] [--sign]")
+		fmt.Println()
+		fmt.Println("Encrypts and stores files in the vault.")
+		fmt.Println("When file arguments are given, only those files are re-encrypted.")
+		fmt.Println("When run without file arguments, locks all tracked files that have been modified;")
+		fmt.Println("in a terminal, a checklist of those files (all selected) lets you pick them.")
+		fmt.Println("Space toggles a file, a toggles all, enter confirms and q cancels.")
+		fmt.Println("Uses content hash comparison to detect changes.")
+		fmt.Println("Supports glob patterns for multiple files, including ** for any number")
+		fmt.Println("of directories and {a,b} alternatives. Quote patterns to keep the shell")
+		fmt.Println("from expanding them.")
+		fmt.Println()
+		fmt.Println("With --recursive, directories are tracked with all files inside, skipping")
+		fmt.Println("paths matched by .lockenvignore (gitignore syntax). Later runs of")
+		fmt.Println("'lockenv lock' add new files in those directories and flag deleted ones.")
+		fmt.Println()
+		fmt.Println("With --domain, the files are moved into that encryption domain and need")
+		fmt.Println("the domain's password to unlock. Without file arguments, --domain only")
+		fmt.Println("allows relocking modified files of that domain.")
+		fmt.Println()
+		fmt.Println("Files larger than 100 MB are refused, so a stray archive does not bloat")
+		fmt.Println("the committed vault. Set max_file_size in .lockenv.toml (e.g. \"500MB\",")
+		fmt.Println("0 for no limit) or pass --allow-large to lock them anyway. Binary files")
+		fmt.Println("over 1 MB are skipped with a warning unless --force is given: every change")
+		fmt.Println("adds a full copy to git history, so external storage suits them better.")
+		fmt.Println()
+		fmt.Println("Dotenv files (.env, .env.*, *.env) are checked for unterminated quotes,")
+		fmt.Println("duplicate keys and invalid variable names. Issues are printed as warnings")
+		fmt.Println("and the file is locked anyway; with --strict, nothing is locked until they")
+		fmt.Println("are fixed. The detected format (dotenv, json, yaml or toml) is recorded.")
+		fmt.Println()
+		fmt.Println("With --sign, the manifest (path, size and content hash of every file) is")
+		fmt.Println("signed with the SSH key at LOCKENV_SSH_IDENTITY (default ~/.ssh/id_ed25519).")
+		fmt.Println("'unlock --require-signature' and 'verify --require-signature' then detect")
+		fmt.Println("a vault rewritten by someone who knows the password but not the key.")
+		fmt.Println()
+		fmt.Println("With -p (--patch), the changes between the vault and the working copy are")
+		fmt.Println("shown hunk by hunk, like 'git add -p', and only the hunks you accept are")
+		fmt.Println("locked. The working copy is not changed, so the rest stays local only.")
+		fmt.Println("Values are masked unless --show-secrets is given. Answers: y lock the hunk,")
+		fmt.Println("n skip it, a lock it and all later hunks, d or q skip all later hunks.")
+		fmt.Println()
+		fmt.Println("With --stdin, the contents are read from stdin and stored as the vault file")
+		fmt.Println("named by --as, so a generated secret never exists as a file in the working")
+		fmt.Println("tree. The password then has to come from LOCKENV_PASSWORD, the keyring or")
+		fmt.Println("another source that does not prompt, unless stdin is a terminal.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  -r, --remove    Remove original files after locking")
+		fmt.Println("  -R, --recursive Track directories and all files inside them")
+		fmt.Println("  --force         Lock without confirmation and accept binary files over 1 MB")
+		fmt.Println("  --domain <name> Encrypt with the password of an encryption domain")
+		fmt.Println("  --no-content    Track the files without encrypting them (same as 'lockenv track')")
+		fmt.Println("  --allow-large   Lock files larger than max_file_size")
+		fmt.Println("  --sign          Sign the manifest with the SSH identity (ed25519)")
+		fmt.Println("  --strict        Fail instead of warn on dotenv syntax issues")
+		fmt.Println("  -p, --patch     Pick the hunks of each file to lock")
+		fmt.Println("  --show-secrets  Show values in the hunks offered by --patch")
+		fmt.Println("  --stdin         Read the contents from stdin (requires --as)")
+		fmt.Println("  --as <path>     Vault path for the contents read with --stdin")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv lock                     # Lock all modified tracked files")
+		fmt.Println("  lockenv lock --force             # Lock all modified files without asking")
+		fmt.Println("  lockenv lock .env                # Lock specific .env file")
+		fmt.Println("  lockenv lock .env --remove       # Lock and remove original")
+		fmt.Println("  lockenv lock -p .env             # Lock only some changes to .env")
+		fmt.Println("  lockenv lock \"config/*.secret\"   # Lock multiple files with glob")
+		fmt.Println("  lockenv lock \"config/**/*.key\"   # Lock .key files at any depth")
+		fmt.Println("  lockenv lock \"{dev,prod}.env\"    # Lock dev.env and prod.env")
+		fmt.Println("  lockenv lock -R secrets/         # Track a whole directory")
+		fmt.Println("  lockenv lock --domain prod prod.env # Lock into the prod domain")
+		fmt.Println("  openssl rand -hex 32 | lockenv lock --stdin --as config/token # Lock generated output")
+		fmt.Println("  lockenv lock --sign              # Lock and sign the manifest")
+	case "track":
+		fmt.Println("lockenv track [-R|--recursive] [--allow-large] <file> [file...]")
+		fmt.Println()
+		fmt.Println("Adds files to the vault's file list without encrypting their content.")
+		fmt.Println("Status shows them as 'tracked, never locked' until the next 'lockenv lock'")
+		fmt.Println("encrypts them, so you can stage files first and seal them together.")
+		fmt.Println("Unlock skips files that were never locked.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  -R, --recursive Track directories and all files inside them")
+		fmt.Println("  --allow-large   Track files larger than max_file_size")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv track .env               # Stage .env")
+		fmt.Println("  lockenv lock                     # Encrypt all staged and modified files")
+	case "unlock":
+		fmt.Println("lockenv unlock [--force|--keep-local|--keep-both] [--all] [--for <duration>] [--domain <name>] [--preserve-mode|--preserve-all] [--require-signature [--signers <file>]] [--strict] [<file> [file...]]")
+		fmt.Println("lockenv unlock --tar <file|-> [--domain <name>] [--preserve-mode|--preserve-all] [--require-signature [--signers <file>]] [--strict] [<file> [file...]]")
+		fmt.Println()
+		fmt.Println("Decrypts and restores files from the vault.")
+		fmt.Println("When run without file arguments, unlocks all files; in a terminal, a")
+		fmt.Println("checklist of the vault's files (all selected) lets you pick them first.")
+		fmt.Println("Supports glob patterns (including ** and {a,b}) for specific files.")
+		fmt.Println("Smart conflict resolution for files that exist locally.")
+		fmt.Println("Files in an encryption domain are skipped unless --domain names it.")
+		fmt.Println("New files are owner-only (group and other bits are dropped) unless")
+		fmt.Println("--preserve-mode is given. --preserve-all also restores the owner")
+		fmt.Println("(recorded when locking as root) and extended attributes.")
+		fmt.Println("--require-signature refuses to unlock unless the manifest is signed by a")
+		fmt.Println("key in .lockenv-signers, and skips files that differ from the signed manifest.")
+		fmt.Println("--tar writes the files as a tar archive to a file, or to stdout with -,")
+		fmt.Println("instead of the working tree; prompts and the summary then go to stderr.")
+		fmt.Println("Entries are owner-only unless --preserve-mode or --preserve-all is given.")
+		fmt.Println("Required files (see 'lockenv require') that are not restored, because they")
+		fmt.Println("were not selected, could not be decrypted or were skipped, are reported;")
+		fmt.Println("--strict then exits with status 1 so a deployment stops there.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --force        Overwrite local files without asking")
+		fmt.Println("  --keep-local   Skip all conflicts, keep local versions")
+		fmt.Println("  --keep-both    Keep both versions (save vault as .from-vault)")
+		fmt.Println("  --all          Unlock all files without the checklist")
+		fmt.Println("  --for <dur>    Lock the files again after this duration (enforced by 'lockenv guard')")
+		fmt.Println("  --domain <name> Also unlock files in this encryption domain")
+		fmt.Println("  --preserve-mode Restore the exact mode recorded at lock, including group/other bits")
+		fmt.Println("  --preserve-all  Also restore owner (uid/gid) and extended attributes")
+		fmt.Println("  --require-signature Require a manifest signature from a trusted key")
+		fmt.Println("  --signers <file> Trusted signing keys, authorized_keys format (default .lockenv-signers)")
+		fmt.Println("  --tar <file|-> Write a tar archive of the files instead of unlocking them")
+		fmt.Println("  --strict       Exit with status 1 if a required file is not restored")
+		fmt.Println()
+		fmt.Println("Interactive mode (default):")
+		fmt.Println("  - Skips unchanged files")
+		fmt.Println("  - For conflicts, offers:")
+		fmt.Println("    [l] Keep local version")
+		fmt.Println("    [v] Use vault version (overwrite local)")
+		fmt.Println("    [e] Edit merged (opens in $EDITOR, text files only)")
+		fmt.Println("    [b] Keep both (save vault as .from-vault)")
+		fmt.Println("    [x] Skip this file")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv unlock                   # Pick files to unlock (all in scripts)")
+		fmt.Println("  lockenv unlock --all             # Unlock all files without asking")
+		fmt.Println("  lockenv unlock .env              # Unlock specific file")
+		fmt.Println("  lockenv unlock \"*.env\"           # Unlock files matching pattern")
+		fmt.Println("  lockenv unlock --force           # Overwrite all")
+		fmt.Println("  lockenv unlock --keep-both       # Keep both for all conflicts")
+		fmt.Println("  lockenv unlock --for 2h          # Unlock for two hours")
+		fmt.Println("  lockenv unlock --domain prod     # Also unlock prod domain files")
+		fmt.Println("  lockenv unlock --preserve-mode   # Keep 0640 etc. for service accounts")
+		fmt.Println("  sudo lockenv unlock --preserve-all  # Restore system config files faithfully")
+		fmt.Println("  lockenv unlock --tar - | tar -x -C /run/secrets  # Unpack into a tmpfs")
+	case "deploy":
+		fmt.Println("lockenv deploy [user@]host:<dir> [--files <pattern>] [--domain <name>] [--preserve-mode|--preserve-all] [--require-signature [--signers <file>]] [pattern...]")
+		fmt.Println()
+		fmt.Println("Unlocks files straight onto a remote host. The decrypted files are streamed")
+		fmt.Println("as a tar archive to the system ssh, which unpacks them into <dir> on the host")
+		fmt.Println("with umask 077, so plaintext is never written locally. Your SSH config, agent")
+		fmt.Println("and known hosts apply. A relative <dir> starts at the remote home directory.")
+		fmt.Println("Without patterns, all files are deployed. Files are owner-only unless")
+		fmt.Println("--preserve-mode or --preserve-all is given; the owner is kept only when the")
+		fmt.Println("remote user is root.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --files <pattern> Deploy only files matching the pattern (globs, ** and {a,b})")
+		fmt.Println("  --domain <name>   Also deploy files in this encryption domain")
+		fmt.Println("  --preserve-mode   Keep the exact mode recorded at lock, including group/other bits")
+		fmt.Println("  --preserve-all    Also keep owner (uid/gid) and extended attributes")
+		fmt.Println("  --require-signature Require a manifest signature from a trusted key")
+		fmt.Println("  --signers <file>  Trusted signing keys (default .lockenv-signers)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv deploy deploy@web1:/etc/app/ --files \"*.pem\"")
+		fmt.Println("  lockenv deploy web1:/run/app .env config/prod.env")
+		fmt.Println("  lockenv deploy root@web1:/etc/ssl/private --files \"tls/*\" --preserve-all")
+	case "rm":
+		fmt.Println("lockenv rm <file> [file...]")
+		fmt.Println()
+		fmt.Println("Removes files from the vault.")
+		fmt.Println("Supports glob patterns (including ** and {a,b}) for multiple files.")
+		fmt.Println("Removing a directory tracked with 'lock --recursive' untracks it and all its files.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv rm .env")
+		fmt.Println("  lockenv rm \"config/*.secret\"")
+		fmt.Println("  lockenv rm secrets/")
+	case "ls":
+		fmt.Println("lockenv ls [pattern...] [--full] [--filter <status>] [--sort <key>] [--columns <list>] [-l|--long]")
+		fmt.Println()
+		fmt.Println("Alias for 'lockenv status'. Shows comprehensive vault status.")
+		fmt.Println("See 'lockenv help status' for the flags.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv ls 'config/**'")
+		fmt.Println("  lockenv ls --filter modified")
+		fmt.Println("  lockenv ls --sort size --columns size,mtime")
+		fmt.Println("  lockenv ls --long")
+	case "env":
+		fmt.Println("lockenv env [file...] [--profile <name>] [--format sh|fish|powershell|dotenv|json]")
+		fmt.Println()
+		fmt.Println("Decrypts dotenv files from the vault in memory and prints their variables")
+		fmt.Println("as statements for the given shell, quoted so that special characters and")
+		fmt.Println("multiline values survive. Nothing is written to disk.")
+		fmt.Println()
+		fmt.Println("By default the root .env is used. With --profile, .env.<name> and")
+		fmt.Println("<name>.env are layered on top, overriding variables from .env. Files given")
+		fmt.Println("as arguments are used instead, later files overriding earlier ones.")
+		fmt.Println()
+		fmt.Println("Prompts and warnings go to stderr, so the output can be passed to eval.")
+		fmt.Println("Variables whose names sh or fish cannot represent are skipped with a warning.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --profile <name>   Overlay .env.<name> or <name>.env on .env")
+		fmt.Println("  --format <format>  sh (default), fish, powershell, dotenv or json")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  eval \"$(lockenv env)\"")
+		fmt.Println("  eval \"$(lockenv env --profile prod)\"")
+		fmt.Println("  lockenv env --format fish | source")
+		fmt.Println("  lockenv env --format powershell | Invoke-Expression")
+		fmt.Println("  lockenv env config/app.env --format json > /dev/shm/app.json")
+	case "render":
+		fmt.Println("lockenv render <template> [file...] [-o <output>] [--profile <name>]")
+		fmt.Println()
+		fmt.Println("Executes a Go text/template with the variables from the vault's dotenv")
+		fmt.Println("files as data, so generated config files never need their secrets")
+		fmt.Println("committed. Variables are selected as for 'lockenv env': .env overlaid")
+		fmt.Println("with --profile, or the vault files given after the template.")
+		fmt.Println()
+		fmt.Println("In the template, {{.NAME}} inserts a variable, {{env \"my.key\"}} reads names")
+		fmt.Println("that are not identifiers, and {{json .NAME}} quotes a value as a JSON string.")
+		fmt.Println("Undefined variables are an error. Output files are written with 0600")
+		fmt.Println("permissions.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  -o, --output <file>  Write to this file instead of stdout")
+		fmt.Println("  --profile <name>     Overlay .env.<name> or <name>.env on .env")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv render config.yaml.tmpl -o config.yaml")
+		fmt.Println("  lockenv render app.json.tmpl --profile prod > /dev/shm/app.json")
+		fmt.Println("  lockenv render nginx.conf.tmpl secrets/tls.env -o nginx.conf")
+	case "inject":
+		fmt.Println("lockenv inject <file> [--stdout]")
+		fmt.Println()
+		fmt.Println("Replaces {{lockenv:FILE:KEY}} placeholders in a committed file with the")
+		fmt.Println("value of KEY from the dotenv file FILE in the vault, so mostly-public")
+		fmt.Println("config can embed secrets. The file is rewritten in place with 0600")
+		fmt.Println("permissions; 'lockenv redact' puts the placeholders back before committing.")
+		fmt.Println("A placeholder naming an unknown file or variable is an error.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --stdout  Print the result instead of rewriting the file")
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  lockenv init                     # Create new vault")
-	case "lock":
-		fmt.Println("lockenv lock [--force] [-r|--remove] [<file> [file...]]")
+		fmt.Println("  lockenv inject config/app.yaml")
+		fmt.Println("  lockenv inject docker-compose.yml --stdout | docker compose -f - up")
+	case "redact":
+		fmt.Println("lockenv redact <file> [vault-file...] [--stdout]")
 		fmt.Println()
-		fmt.Println("Encrypts and stores files in the vault.")
-		fmt.Println("When run without file arguments, locks all tracked files that have been modified.")
-		fmt.Println("Uses content hash comparison to detect changes.")
-		fmt.Println("Supports glob patterns for multiple files.")
+		fmt.Println("Reverses 'lockenv inject': values from the vault's dotenv files found in")
+		fmt.Println("the file are replaced with their {{lockenv:FILE:KEY}} placeholders. By")
+		fmt.Println("default every dotenv file in the vault is used; vault files given after")
+		fmt.Println("the file limit the lookup. Longer values are matched first, and values")
+		fmt.Println("shorter than 4 characters are left alone. The file keeps its permissions.")
 		fmt.Println()
 		fmt.Println("Flags:")
-		fmt.Println("  -r, --remove    Remove original files after locking")
-		fmt.Println("  --force         Lock without confirmation (when no files specified)")
+		fmt.Println("  --stdout  Print the result instead of rewriting the file")
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  lockenv lock                     # Lock all modified tracked files")
-		fmt.Println("  lockenv lock --force             # Lock all modified files without asking")
-		fmt.Println("  lockenv lock .env                # Lock specific .env file")
-		fmt.Println("  lockenv lock .env --remove       # Lock and remove original")
-		fmt.Println("  lockenv lock \"config/*.secret\"   # Lock multiple files with glob")
-	case "unlock":
-		fmt.Println("lockenv unlock [--force|--keep-local|--keep-both] [<file> [file...]]")
+		fmt.Println("  lockenv redact config/app.yaml")
+		fmt.Println("  lockenv redact config/app.yaml .env.prod")
+	case "show":
+		fmt.Println("lockenv show <file> [--content]")
 		fmt.Println()
-		fmt.Println("Decrypts and restores files from the vault.")
-		fmt.Println("When run without file arguments, unlocks all files.")
-		fmt.Println("Supports glob patterns for specific files.")
-		fmt.Println("Smart conflict resolution for files that exist locally.")
+		fmt.Println("Prints the details of a file in the vault: status, size, permissions,")
+		fmt.Println("modification times, hash, encryption domain, note and the times new")
+		fmt.Println("content was locked (the last 20). The content stays encrypted unless")
+		fmt.Println("--content is given.")
 		fmt.Println()
 		fmt.Println("Flags:")
-		fmt.Println("  --force        Overwrite local files without asking")
-		fmt.Println("  --keep-local   Skip all conflicts, keep local versions")
-		fmt.Println("  --keep-both    Keep both versions (save vault as .from-vault)")
+		fmt.Println("  --content  Also print the decrypted content")
 		fmt.Println()
-		fmt.Println("Interactive mode (default):")
-		fmt.Println("  - Skips unchanged files")
-		fmt.Println("  - For conflicts, offers:")
-		fmt.Println("    [l] Keep local version")
-		fmt.Println("    [v] Use vault version (overwrite local)")
-		fmt.Println("    [e] Edit merged (opens in $EDITOR, text files only)")
-		fmt.Println("    [b] Keep both (save vault as .from-vault)")
-		fmt.Println("    [x] Skip this file")
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv show .env")
+		fmt.Println("  lockenv show config/app.json --content")
+	case "note":
+		fmt.Println("lockenv note <file> [text...] [--clear]")
+		fmt.Println()
+		fmt.Println("Attaches a note to a file in the vault, documenting why the secret exists,")
+		fmt.Println("who owns it or when to rotate it. Notes are encrypted with the file details")
+		fmt.Println("and kept when the file is relocked. Without text, prints the file's note.")
+		fmt.Println("Notes are shown by 'lockenv ls --long'.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --clear   Remove the note")
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  lockenv unlock                   # Unlock all files")
-		fmt.Println("  lockenv unlock .env              # Unlock specific file")
-		fmt.Println("  lockenv unlock \"*.env\"           # Unlock files matching pattern")
-		fmt.Println("  lockenv unlock --force           # Overwrite all")
-		fmt.Println("  lockenv unlock --keep-both       # Keep both for all conflicts")
-	case "rm":
-		fmt.Println("lockenv rm <file> [file...]")
+		fmt.Println("  lockenv note .env \"rotate quarterly; owned by SRE\"")
+		fmt.Println("  lockenv note .env")
+		fmt.Println("  lockenv note .env --clear")
+	case "require":
+		fmt.Println("lockenv require [--optional] [file...]")
 		fmt.Println()
-		fmt.Println("Removes files from the vault.")
-		fmt.Println("Supports glob patterns for multiple files.")
+		fmt.Println("Marks files in the vault as required. 'lockenv unlock' warns when a")
+		fmt.Println("required file is not restored, because it was not selected, could not be")
+		fmt.Println("decrypted or was skipped, and 'unlock --strict' exits with status 1, so a")
+		fmt.Println("deployment never proceeds with only part of its secrets. A file the working")
+		fmt.Println("tree already holds unchanged counts as restored. The mark is encrypted with")
+		fmt.Println("the file details and kept when the file is relocked. Without files, lists")
+		fmt.Println("the required files.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --optional   Mark the files optional again")
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  lockenv rm .env")
-		fmt.Println("  lockenv rm \"config/*.secret\"")
-	case "ls":
-		fmt.Println("lockenv ls")
+		fmt.Println("  lockenv require .env config/prod.key")
+		fmt.Println("  lockenv require")
+		fmt.Println("  lockenv require --optional config/prod.key")
+		fmt.Println("  lockenv unlock --all --strict")
+	case "run":
+		fmt.Println("lockenv run [file...] [--profile <name>] [--redact] -- <command> [args...]")
 		fmt.Println()
-		fmt.Println("Alias for 'lockenv status'. Shows comprehensive vault status.")
+		fmt.Println("Runs a command with the variables from the vault's dotenv files added to")
+		fmt.Println("its environment, without writing them to disk. Variables are selected as")
+		fmt.Println("for 'lockenv env': .env overlaid with --profile, or the vault files given")
+		fmt.Println("before --. lockenv exits with the command's exit status.")
+		fmt.Println()
+		fmt.Println("With --redact, occurrences of the values in the command's stdout and")
+		fmt.Println("stderr are replaced with ***, so secrets don't leak into CI logs. Values")
+		fmt.Println("shorter than 4 characters are not redacted.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --profile <name>  Overlay .env.<name> or <name>.env on .env")
+		fmt.Println("  --redact          Replace secret values in the command's output with ***")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv run -- npm start")
+		fmt.Println("  lockenv run --profile prod --redact -- ./deploy.sh")
+		fmt.Println("  lockenv run config/app.env -- env")
+	case "ci":
+		fmt.Println("lockenv ci <subcommand> [file...] [--profile <name>]")
+		fmt.Println()
+		fmt.Println("Exports the variables from the vault's dotenv files to a CI system, so")
+		fmt.Println("the vault can serve as the pipeline's secret source. Variables are")
+		fmt.Println("selected as for 'lockenv env': .env overlaid with --profile, or the vault")
+		fmt.Println("files given as arguments.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  export-github            Append variables to $GITHUB_ENV for later steps")
+		fmt.Println("                           and mask their values with ::add-mask::")
+		fmt.Println("  export-gitlab [-o file]  Write a dotenv report for artifacts:reports:dotenv")
+		fmt.Println()
+		fmt.Println("GitLab dotenv reports cannot hold multiline values or names with dots or")
+		fmt.Println("dashes; such variables are skipped with a warning. GitLab does not mask")
+		fmt.Println("report values in job logs.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv ci export-github --profile prod")
+		fmt.Println("  lockenv ci export-gitlab -o deploy.env")
+	case "which":
+		fmt.Println("lockenv which <KEY>")
+		fmt.Println()
+		fmt.Println("Reports which dotenv files in the vault define the variable KEY, as")
+		fmt.Println("path:line. Files are decrypted in memory; nothing is written to disk.")
+		fmt.Println("Dotenv files are .env, .env.<suffix> and <name>.env. Exits with status 1")
+		fmt.Println("if no file defines KEY.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv which DATABASE_URL")
+	case "scan":
+		fmt.Println("lockenv scan [--include-ignored]")
+		fmt.Println("lockenv scan --ignore|--unignore <path|glob> [path|glob...]")
+		fmt.Println("lockenv scan --list-ignored")
+		fmt.Println()
+		fmt.Println("Lists files in the project that look like secrets but are not in the")
+		fmt.Println("vault, with the reason each was reported. Files are judged by name (.env,")
+		fmt.Println("*.pem, id_rsa, credentials.json, ...) and, for configuration files (.json,")
+		fmt.Println(".yaml, .toml, .ini, ...), by contents: known token formats such as AWS,")
+		fmt.Println("GitHub or Slack keys and private key blocks, and high-entropy values.")
+		fmt.Println("Examples (.env.example) and public keys (*.pub) are not reported.")
+		fmt.Println()
+		fmt.Println("Dependency directories (node_modules, vendor, .venv, ...) and paths in")
+		fmt.Println(".lockenvignore are skipped. No password is required. Exits with status 1")
+		fmt.Println("if any file is reported.")
+		fmt.Println()
+		fmt.Println("Files you know are not secret, such as test fixtures, can be marked so")
+		fmt.Println("scan and the status hint stop reporting them. Marks made with --ignore are")
+		fmt.Println("kept in the vault and shared with it; scan.ignore in .lockenv.toml adds more.")
+		fmt.Println("Paths and globs (supporting ** and {a,b}) are relative to the project root;")
+		fmt.Println("a directory covers everything inside it.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --include-ignored  Also list files marked as not secret, with the mark")
+		fmt.Println("  --ignore           Mark the given paths or globs as not secret (asks for")
+		fmt.Println("                     the password)")
+		fmt.Println("  --unignore         Remove marks made with --ignore")
+		fmt.Println("  --list-ignored     Print the marked paths and globs")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv scan")
+		fmt.Println("  lockenv scan || echo 'lock or ignore the files above'")
+		fmt.Println("  lockenv scan --ignore testdata/ 'docs/**/*.pem'")
+		fmt.Println("  lockenv scan --include-ignored")
 	case "passwd":
-		fmt.Println("lockenv passwd")
+		fmt.Println("lockenv passwd [--rotate-salt-only]")
 		fmt.Println()
 		fmt.Println("Changes the vault password.")
 		fmt.Println("Requires both the current and new passwords.")
-		fmt.Println("Re-encrypts all files with the new password.")
+		fmt.Println("Format v2 vaults only re-wrap their data key; older vaults re-encrypt")
+		fmt.Println("all files with the new password.")
+		fmt.Println("The vault is backed up to .lockenv-backups first (see 'lockenv backup').")
 		fmt.Println()
-		fmt.Println("Example:")
+		fmt.Println("Options:")
+		fmt.Println("  --rotate-salt-only  Keep the password; derive the key again with a new salt")
+		fmt.Println("                      and the iterations from kdf_target_time or the current")
+		fmt.Println("                      default, never fewer than before. Use it after raising")
+		fmt.Println("                      kdf_target_time or upgrading lockenv")
+		fmt.Println()
+		fmt.Println("Examples:")
 		fmt.Println("  lockenv passwd")
+		fmt.Println("  LOCKENV_KDF_TARGET_TIME=1s lockenv passwd --rotate-salt-only")
+	case "recover":
+		fmt.Println("lockenv recover [--key <recovery-key>]")
+		fmt.Println("lockenv recover --new-key")
+		fmt.Println()
+		fmt.Println("Sets a new password on a vault whose password is lost, using the recovery")
+		fmt.Println("key printed by 'lockenv init --recovery-key' or 'lockenv recover --new-key'.")
+		fmt.Println("The recovery key wraps the vault's data key, so no file is re-encrypted;")
+		fmt.Println("SSH and KMS recipients keep access. The key is prompted for if --key is")
+		fmt.Println("omitted, which keeps it out of the shell history. Each recovery key works")
+		fmt.Println("once: a new one replaces it and is printed. The vault is backed up to")
+		fmt.Println(".lockenv-backups first. Format v2 vaults only (see 'lockenv migrate').")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --key <key>  The recovery key, e.g. ABCD-EFGH-... (case and dashes are ignored)")
+		fmt.Println("  --new-key    Create a recovery key with the current password, replacing")
+		fmt.Println("               any previous one")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv recover")
+		fmt.Println("  lockenv recover --new-key")
 	case "diff":
-		fmt.Println("lockenv diff")
+		fmt.Println("lockenv diff [--rev <revision>] [--stat] [--hexdump <n>] [--show-secrets] [<file-a> <file-b>]")
 		fmt.Println()
 		fmt.Println("Compares vault contents with local files.")
 		fmt.Println("Shows which files have been modified locally.")
 		fmt.Println()
-		fmt.Println("Example:")
-		fmt.Println("  lockenv diff")
+		fmt.Println("With --rev, extracts .lockenv from the given git revision and compares")
+		fmt.Println("its secrets with the current vault instead of the working tree.")
+		fmt.Println()
+		fmt.Println("With two files, compares those vault files with each other, e.g. two")
+		fmt.Println("environments, listing first the keys defined in only one of them (dotenv")
+		fmt.Println("variables or JSON, YAML and TOML key paths) to spot drift.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --rev <revision>   Compare the vault at a git revision with the current vault")
+		fmt.Println("  --stat             Show changed line counts per file instead of the diff")
+		fmt.Println("  --hexdump <n>      Hexdump up to n bytes from the first difference in binary files")
+		fmt.Println("  --show-secrets     Show secret values instead of masking them (alias: --show-values)")
+		fmt.Println()
+		fmt.Println("Values are masked with **** by default; keys and context lines are kept.")
+		fmt.Println("Binary files are summarized with old/new sizes, SHA-256 hashes and detected type.")
+		fmt.Println("JSON, YAML and TOML files are compared key by key.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv diff                     # Vault vs working tree")
+		fmt.Println("  lockenv diff --rev HEAD~3        # How secrets changed in the last 3 commits")
+		fmt.Println("  lockenv diff .env.staging .env.prod  # Drift between two environments")
+		fmt.Println("  lockenv diff --stat              # Which files changed and by how much")
+		fmt.Println("  lockenv diff --hexdump 64        # Show binary changes byte by byte")
+		fmt.Println("  lockenv diff --show-secrets      # Show actual old/new values")
+	case "merge":
+		fmt.Println("lockenv merge <other-vault> [--force | --keep-local | --keep-both]")
+		fmt.Println()
+		fmt.Println("Decrypts another .lockenv file and merges its files into this vault,")
+		fmt.Println("e.g. after two branches both changed the vault. Files only in the other")
+		fmt.Println("vault are added. Files that differ are resolved like unlock conflicts,")
+		fmt.Println("with the other vault as the vault side. Files are never removed.")
+		fmt.Println()
+		fmt.Println("The other vault is opened with the same password first; if that fails,")
+		fmt.Println("you are prompted for its password. Run 'lockenv unlock' afterwards to")
+		fmt.Println("update local files.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --force        Use the other vault's version for all conflicts")
+		fmt.Println("  --keep-local   Keep this vault's version for all conflicts")
+		fmt.Println("  --keep-both    Keep both, storing the other version as <file>.from-vault")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  git show MERGE_HEAD:.lockenv > /tmp/theirs.lockenv")
+		fmt.Println("  git checkout --ours .lockenv")
+		fmt.Println("  lockenv merge /tmp/theirs.lockenv")
+		fmt.Println("  lockenv merge ../other-checkout/.lockenv --keep-local")
+	case "link":
+		fmt.Println("lockenv link <vault-dir> <file> [--as <path>]")
+		fmt.Println()
+		fmt.Println("Adds a reference to <file> of the vault in the project directory")
+		fmt.Println("<vault-dir>, so secrets shared by many repositories are stored once.")
+		fmt.Println("The content stays in that vault: unlock reads it from there with that")
+		fmt.Println("vault's password, keyring entry or session, and picks up changes made")
+		fmt.Println("there. Lock leaves references alone. Requires this vault's password.")
+		fmt.Println()
+		fmt.Println("The vault is found by its ID in the [vaults] table of .lockenv.toml or")
+		fmt.Println("the user config, falling back to where it was when linked:")
+		fmt.Println()
+		fmt.Println("  [vaults]")
+		fmt.Println("  3f9a2c1e... = \"../org-secrets\"")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --as <path>   Path of the reference in this vault (default: <file>)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv link ../org-secrets shared/sentry.env")
+		fmt.Println("  lockenv link ../org-secrets shared/db.env --as config/db.env")
 	case "status":
-		fmt.Println("lockenv status")
+		fmt.Println("lockenv status [pattern...] [--full] [--filter <status>] [--sort <key>] [--columns <list>] [-l|--long] [--prompt] [--all] [--exit-code]")
 		fmt.Println()
 		fmt.Println("Shows comprehensive vault status including:")
 		fmt.Println("  - File count and total size")
@@ -313,10 +2028,138 @@ func printCommandHelp(command string) {
 		fmt.Println("  - File states (locked, modified, unchanged)")
 		fmt.Println("  - Detailed file list with status icons")
 		fmt.Println()
+		fmt.Println("Does not require a password, except to show notes. Files whose size and modification time")
+		fmt.Println("match the vault are reported unchanged without being read; files with")
+		fmt.Println("a different modification time are hashed.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --full             Hash every file, even if size and modification time match")
+		fmt.Println("  --filter <status>  Only list modified, unchanged, vault-only or never-locked files")
+		fmt.Println("  --sort <key>       Sort files by name (default), size (largest first) or mtime (newest first)")
+		fmt.Println("  --columns <list>   Show extra columns: size, mtime, mode, hash, note (comma-separated)")
+		fmt.Println("  -l, --long         Show the size, mtime, mode and note columns")
+		fmt.Println("  --prompt           Print only an indicator like \"🔓3\" (used by 'lockenv shell-hook')")
+		fmt.Println("  --all              Summarize every vault under the git working tree (or the current")
+		fmt.Println("                     directory outside git) with totals over all of them")
+		fmt.Println("  --exit-code        Exit with status 1 unless every file is unchanged")
+		fmt.Println()
+		fmt.Println("The size, mtime and hash columns show what was recorded when the file was")
+		fmt.Println("last locked; mode shows the permissions of the working-tree copy. Notes")
+		fmt.Println("set with 'lockenv note' are encrypted, so the note column asks for the password.")
+		fmt.Println()
+		fmt.Println("Patterns restrict the file list to matching paths; globs support ** and {a,b}.")
+		fmt.Println("Quote them so the shell does not expand them against the working tree.")
+		fmt.Println()
+		fmt.Println("With --exit-code, status exits with 0 only if every matching file is")
+		fmt.Println("unchanged, and 1 if any is modified, vault only (missing from the working")
+		fmt.Println("tree) or tracked but never locked. --filter does not change the exit code.")
+		fmt.Println("This lets a git pre-push hook gate on it without parsing the output.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv status")
+		fmt.Println("  lockenv status 'config/**' '*.pem'")
+		fmt.Println("  lockenv status --filter vault-only")
+		fmt.Println("  lockenv status --all")
+		fmt.Println("  lockenv status --exit-code > /dev/null || echo 'lock your changes first'")
+		fmt.Println("  lockenv ls --sort mtime --columns mtime,mode,hash")
+	case "stats":
+		fmt.Println("lockenv stats")
+		fmt.Println()
+		fmt.Println("Shows how space in the vault is used:")
+		fmt.Println("  - Database file size, page size and free pages")
+		fmt.Println("  - Plaintext and encrypted size of each file, largest first")
+		fmt.Println("  - Encryption overhead (files are not compressed)")
+		fmt.Println("  - When the vault was created and last locked")
+		fmt.Println()
+		fmt.Println("The vault stores only the latest version of each file; history is")
+		fmt.Println("kept by git. Free pages are reclaimed by 'lockenv compact'.")
+		fmt.Println()
 		fmt.Println("Does not require a password.")
 		fmt.Println()
 		fmt.Println("Example:")
-		fmt.Println("  lockenv status")
+		fmt.Println("  lockenv stats")
+	case "backup":
+		fmt.Println("lockenv backup [--dir <path>] [--keep <n>]")
+		fmt.Println()
+		fmt.Println("Saves a consistent, timestamped copy of the .lockenv file and removes")
+		fmt.Println("the oldest backups beyond the retention limit. Backups stay encrypted.")
+		fmt.Println()
+		fmt.Println("A backup is also taken automatically before 'lockenv passwd' and")
+		fmt.Println("'lockenv migrate'.")
+		fmt.Println()
+		fmt.Println("Does not require a password.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --dir <path>   Directory to store backups in (default .lockenv-backups)")
+		fmt.Println("  --keep <n>     Number of backups to keep, 0 keeps all (default 5)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv backup                     # Back up into .lockenv-backups")
+		fmt.Println("  lockenv backup --dir ~/vaults --keep 10")
+	case "migrate":
+		fmt.Println("lockenv migrate")
+		fmt.Println("lockenv migrate --layout file|dir")
+		fmt.Println()
+		fmt.Println("Upgrades a vault created by an older lockenv to the current format (v2):")
+		fmt.Println("  - Files are re-encrypted under a random data key, which is wrapped by")
+		fmt.Println("    the password-derived key, so 'lockenv passwd' no longer rewrites them")
+		fmt.Println("  - Blobs over 64 KiB are stored as chunks")
+		fmt.Println("  - The plaintext file index is authenticated with an HMAC")
+		fmt.Println()
+		fmt.Println("The vault is backed up to .lockenv-backups first and rewritten in a")
+		fmt.Println("single transaction, so an interrupted migration leaves it unchanged.")
+		fmt.Println("'lockenv status' shows the format version. New vaults use v2.")
+		fmt.Println()
+		fmt.Println("With --layout, converts the vault between the single-file layout and")
+		fmt.Println("the dir layout, which keeps each file's encrypted contents in its own")
+		fmt.Println("object under .lockenv.d/. The blobs are moved as they are, so no")
+		fmt.Println("password is needed. Commit .lockenv.d/ together with .lockenv.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv migrate")
+		fmt.Println("  lockenv migrate --layout dir")
+	case "push":
+		fmt.Println("lockenv push [--remote <url>] [--force]")
+		fmt.Println()
+		fmt.Println("Uploads the .lockenv file to a remote over HTTP(S), WebDAV, S3 or GCS. The vault")
+		fmt.Println("stays encrypted. The upload is refused if the remote changed since the")
+		fmt.Println("last push or pull, so concurrent edits are not lost.")
+		fmt.Println()
+		fmt.Println("The remote defaults to the one last synced with, then LOCKENV_REMOTE.")
+		fmt.Println("Set LOCKENV_REMOTE_TOKEN for bearer authentication, or put user:password")
+		fmt.Println("in the URL for basic authentication. s3:// uses AWS_ACCESS_KEY_ID,")
+		fmt.Println("AWS_SECRET_ACCESS_KEY and AWS_REGION; gs:// an access token from")
+		fmt.Println("GOOGLE_OAUTH_ACCESS_TOKEN. Credentials are not sent over plain http://")
+		fmt.Println("unless LOCKENV_REMOTE_INSECURE=1.")
+		fmt.Println()
+		fmt.Println("Does not require a password.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --remote <url>   Remote URL (https://, http://, webdavs://, webdav://, s3://, gs://)")
+		fmt.Println("  --force          Overwrite the remote vault even if it changed")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv push --remote webdavs://dav.example.com/team/app.lockenv")
+		fmt.Println("  lockenv push                       # Push to the last synced remote")
+	case "pull":
+		fmt.Println("lockenv pull [--remote <url>] [--force]")
+		fmt.Println()
+		fmt.Println("Replaces the .lockenv file with the one stored on a remote. The local")
+		fmt.Println("vault is backed up to .lockenv-backups first. The pull is refused if")
+		fmt.Println("the local vault changed since the last sync, belongs to a different")
+		fmt.Println("vault, or has a lower generation than the version last synced (an old")
+		fmt.Println("copy was pushed over it). Run 'lockenv unlock' afterwards to update")
+		fmt.Println("local files.")
+		fmt.Println()
+		fmt.Println("Does not require a password.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --remote <url>   Remote URL (https://, http://, webdavs://, webdav://, s3://, gs://)")
+		fmt.Println("  --force          Replace the local vault even if it has unpushed changes")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv pull --remote https://vault.example.com/app.lockenv")
+		fmt.Println("  lockenv pull && lockenv unlock")
 	case "compact":
 		fmt.Println("lockenv compact")
 		fmt.Println()
@@ -328,6 +2171,162 @@ func printCommandHelp(command string) {
 		fmt.Println()
 		fmt.Println("Example:")
 		fmt.Println("  lockenv compact")
+	case "clean":
+		fmt.Println("lockenv clean [--force]")
+		fmt.Println()
+		fmt.Println("Removes plaintext copies of tracked files from the working tree.")
+		fmt.Println("Only files whose content matches the vault are removed, so no")
+		fmt.Println("unlocked changes are lost. Modified files are skipped unless --force is given.")
+		fmt.Println()
+		fmt.Println("Does not require a password.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --force    Remove files even if they differ from the vault")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv clean                    # Remove unchanged plaintext files")
+		fmt.Println("  lockenv clean --force            # Remove all plaintext files")
+	case "shred":
+		fmt.Println("lockenv shred [--force]")
+		fmt.Println()
+		fmt.Println("Like 'lockenv clean', but overwrites file contents with random data")
+		fmt.Println("before deleting them. Useful for tidying workstations and CI runners.")
+		fmt.Println()
+		fmt.Println("Note: journaling and copy-on-write filesystems (and SSDs) may keep")
+		fmt.Println("old copies of the data; shredding is best-effort.")
+		fmt.Println()
+		fmt.Println("Does not require a password.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --force    Shred files even if they differ from the vault")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv shred                    # Shred unchanged plaintext files")
+		fmt.Println("  lockenv shred --force            # Shred all plaintext files")
+	case "verify":
+		fmt.Println("lockenv verify [--require-signature [--signers <file>]] [--paths [--strict] [pattern...]]")
+		fmt.Println()
+		fmt.Println("Without flags, decrypts every file in the vault and checks it against")
+		fmt.Println("the recorded content hash. Requires a password.")
+		fmt.Println()
+		fmt.Println("With --paths, compares local files against the vault's recorded hashes")
+		fmt.Println("without a password, reporting modified files, files missing locally, and")
+		fmt.Println("untracked files matching the given patterns.")
+		fmt.Println()
+		fmt.Println("Exits with status 1 if any file is modified or untracked, so it can be")
+		fmt.Println("used in pre-commit or pre-push hooks.")
+		fmt.Println()
+		fmt.Println("With --require-signature, the manifest must also be signed ('lock --sign')")
+		fmt.Println("by a key listed in .lockenv-signers. Together with the content check this")
+		fmt.Println("detects a vault rewritten by someone who knows the password.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --paths    Check the working tree instead of the vault (no password)")
+		fmt.Println("  --strict   Also fail if tracked files are missing locally")
+		fmt.Println("  --require-signature  Fail unless the manifest is signed by a trusted key")
+		fmt.Println("  --signers <file>     Trusted signing keys (default .lockenv-signers)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv verify                      # Check vault integrity")
+		fmt.Println("  lockenv verify --paths              # Check for unlocked changes")
+		fmt.Println("  lockenv verify --paths '*.env'      # Also report untracked .env files")
+		fmt.Println("  lockenv verify --paths --strict     # Fail if any file is not unlocked")
+		fmt.Println("  lockenv verify --require-signature  # Also check the maintainer signature")
+	case "check-parity":
+		fmt.Println("lockenv check-parity <file> <file> [file...] [--local]")
+		fmt.Println()
+		fmt.Println("Compares the variables defined in environment files of the vault, e.g.")
+		fmt.Println(".env.dev and .env.prod, and reports each variable defined in some of")
+		fmt.Println("them but missing from others. Values are never compared or shown.")
+		fmt.Println("Dotenv files are compared by variable, JSON, YAML and TOML files by key")
+		fmt.Println("path. Requires a password, unless --local is given.")
+		fmt.Println()
+		fmt.Println("Exits with status 1 if any variable is missing, so it can catch config")
+		fmt.Println("that works locally but is missing in production in CI.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --local    Compare the working-tree copies instead of the vault (no password)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv check-parity .env.dev .env.prod")
+		fmt.Println("  lockenv check-parity .env.dev .env.staging .env.prod")
+		fmt.Println("  lockenv check-parity --local .env .env.example")
+	case "validate":
+		fmt.Println("lockenv validate [--local] [file...]")
+		fmt.Println()
+		fmt.Println("Checks the files of the vault against the committed schema in")
+		fmt.Println(".lockenv.schema.json, so missing or malformed secrets are caught before")
+		fmt.Println("deploy. The schema lists, by vault path or glob pattern, the keys that must")
+		fmt.Println("be set and the format or pattern their values must match:")
+		fmt.Println()
+		fmt.Println("  {\"files\": {\".env.*\": {")
+		fmt.Println("    \"required\": [\"DATABASE_URL\", \"STRIPE_KEY\"],")
+		fmt.Println("    \"keys\": {\"DATABASE_URL\": {\"format\": \"url\"},")
+		fmt.Println("             \"STRIPE_KEY\": {\"pattern\": \"sk_live_.*\"}}}}}")
+		fmt.Println()
+		fmt.Println("Formats: int, port, bool, url, email, uuid, hex and base64. A pattern must")
+		fmt.Println("match the whole value. Dotenv files are checked by variable, JSON, YAML")
+		fmt.Println("and TOML files by key path (database.host). Values are never shown.")
+		fmt.Println()
+		fmt.Println("Without file arguments, every vault file matched by the schema is checked,")
+		fmt.Println("and files the schema names exactly must exist. Requires a password, unless")
+		fmt.Println("--local is given. Exits with status 1 if anything violates the schema.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --local    Check the working-tree copies instead of the vault (no password)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv validate")
+		fmt.Println("  lockenv validate .env.prod")
+		fmt.Println("  lockenv validate --local")
+	case "fsck":
+		fmt.Println("lockenv fsck [--repair]")
+		fmt.Println()
+		fmt.Println("Checks the vault structure beyond file contents: the plaintext index")
+		fmt.Println("against the encrypted metadata, stored data of files no longer in the")
+		fmt.Println("vault, the vault timestamps and the index MAC. Requires a password.")
+		fmt.Println()
+		fmt.Println("Exits with status 1 if issues are found. With --repair, backs up the")
+		fmt.Println("vault, rebuilds the index from the metadata, drops orphaned data, fixes")
+		fmt.Println("the timestamps and rewrites the vault into a fresh database.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --repair   Fix the issues found")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv fsck             # Report structural issues")
+		fmt.Println("  lockenv fsck --repair    # Back up the vault and fix them")
+	case "reindex":
+		fmt.Println("lockenv reindex")
+		fmt.Println()
+		fmt.Println("Regenerates every index entry (path, size, modification time and hash)")
+		fmt.Println("from the encrypted metadata and the decrypted file contents. Use it when")
+		fmt.Println("the plaintext index was deleted or mangled, e.g. by a bad merge.")
+		fmt.Println("Requires a password.")
+		fmt.Println()
+		fmt.Println("Files whose contents differ from the metadata are reported as corrected.")
+		fmt.Println("Files that cannot be decrypted, e.g. in a locked domain, are indexed from")
+		fmt.Println("the metadata alone.")
+	case "split":
+		fmt.Println("lockenv split --files <pattern> --output <file> [--new-password] [pattern...]")
+		fmt.Println()
+		fmt.Println("Creates a new vault holding only the files that match the patterns, e.g.")
+		fmt.Println("to hand a subset of secrets to a contractor or another repository. The")
+		fmt.Println("new vault gets its own salt and data key; this vault is not changed.")
+		fmt.Println("Files in an encryption domain are left out. Requires a password.")
+		fmt.Println()
+		fmt.Println("The new vault uses the same password unless --new-password is given.")
+		fmt.Println("When the vault was opened without a password (session, SSH key or KMS),")
+		fmt.Println("the new vault's password is always prompted for.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --files <pattern>  Copy the files matching this pattern")
+		fmt.Println("  --output <file>    Path of the new vault, must not exist")
+		fmt.Println("  --new-password     Encrypt the new vault with a different password")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv split --files 'payments/*' --output payments.lockenv")
+		fmt.Println("  lockenv split --files 'payments/*' --output payments.lockenv --new-password")
 	case "completion":
 		fmt.Println("lockenv completion <bash|zsh|fish>")
 		fmt.Println()
@@ -342,8 +2341,82 @@ func printCommandHelp(command string) {
 		fmt.Println()
 		fmt.Println("  # Fish - add to ~/.config/fish/config.fish")
 		fmt.Println("  lockenv completion fish | source")
+	case "shell-hook":
+		fmt.Println("lockenv shell-hook <bash|zsh|fish>")
+		fmt.Println()
+		fmt.Println("Outputs a snippet that prefixes the prompt with an indicator such as")
+		fmt.Println("\"🔓3\" when tracked secrets in the current directory are unlocked")
+		fmt.Println("(present as plaintext, modified or not).")
+		fmt.Println()
+		fmt.Println("The indicator comes from 'lockenv status --prompt', which only stats")
+		fmt.Println("files and never asks for a password.")
+		fmt.Println()
+		fmt.Println("Setup:")
+		fmt.Println("  # Bash - add to ~/.bashrc")
+		fmt.Println("  eval \"$(lockenv shell-hook bash)\"")
+		fmt.Println()
+		fmt.Println("  # Zsh - add to ~/.zshrc")
+		fmt.Println("  eval \"$(lockenv shell-hook zsh)\"")
+		fmt.Println()
+		fmt.Println("  # Fish - add to ~/.config/fish/config.fish")
+		fmt.Println("  lockenv shell-hook fish | source")
+	case "git-hooks":
+		fmt.Println("lockenv git-hooks <install [--auto-unlock] [--stash]|uninstall>")
+		fmt.Println()
+		fmt.Println("Installs post-checkout and post-merge hooks in the git repository. After")
+		fmt.Println("a branch switch, merge or pull that changed a committed vault, they print")
+		fmt.Println("a reminder to run 'lockenv unlock', so you don't keep running against")
+		fmt.Println("stale plaintext secrets. Every vault in the working tree is checked.")
+		fmt.Println()
+		fmt.Println("With --auto-unlock, a changed vault is unlocked right away while a")
+		fmt.Println("'lockenv session' is active for it. Git hooks have no terminal to ask on,")
+		fmt.Println("so local files that differ from the vault are kept and the vault version")
+		fmt.Println("is written next to them as <file>.from-vault, like 'unlock --keep-both'.")
+		fmt.Println("Without a session, the reminder is printed.")
+		fmt.Println()
+		fmt.Println("With --stash, a pre-rebase hook is installed too, and the local edits of")
+		fmt.Println("every vault with an active session - files modified since they were")
+		fmt.Println("locked, or never locked - are copied into an encrypted snapshot before a")
+		fmt.Println("rebase and before an automatic unlock. Restore them with")
+		fmt.Println("'lockenv stash pop --snapshot'. Git has no hook before a checkout or")
+		fmt.Println("merge, so lock or snapshot edits yourself before switching branches.")
+		fmt.Println()
+		fmt.Println("Hooks of other tools are never overwritten; install reports them instead.")
+		fmt.Println("core.hooksPath is honored.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  install     Install the hooks (again, to change --auto-unlock or --stash)")
+		fmt.Println("  uninstall   Remove the hooks lockenv installed")
+		fmt.Println()
+		fmt.Println("Flags (install):")
+		fmt.Println("  --auto-unlock   Unlock changed vaults while a session is active")
+		fmt.Println("  --stash         Snapshot local edits before rebases and automatic unlocks")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv git-hooks install")
+		fmt.Println("  lockenv git-hooks install --auto-unlock")
+		fmt.Println("  lockenv git-hooks install --auto-unlock --stash")
+		fmt.Println("  lockenv git-hooks uninstall")
+	case "selfupdate":
+		fmt.Println("lockenv selfupdate [--check]")
+		fmt.Println()
+		fmt.Println("Replaces the lockenv binary with the latest GitHub release. The release")
+		fmt.Println("checksums must carry a valid minisign signature from the release key")
+		fmt.Println("built into lockenv, and the downloaded archive must match its checksum.")
+		fmt.Println("The new binary is renamed over the old one, so an interrupted update")
+		fmt.Println("leaves the current binary in place.")
+		fmt.Println()
+		fmt.Println("Builds without a release key (go install, source builds) refuse to")
+		fmt.Println("update. Packaged installs should be updated with the package manager.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --check   Only report whether a newer release is available")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv selfupdate --check")
+		fmt.Println("  sudo lockenv selfupdate        # Binary in a root-owned directory")
 	case "keyring":
-		fmt.Println("lockenv keyring <save|delete|status>")
+		fmt.Println("lockenv keyring <save [--ttl <duration>]|delete|status>")
 		fmt.Println()
 		fmt.Println("Manages password storage in the OS keyring (GNOME Keyring, KDE Wallet, macOS Keychain).")
 		fmt.Println("When a password is stored in the keyring, you won't need to enter it for each command.")
@@ -353,13 +2426,179 @@ func printCommandHelp(command string) {
 		fmt.Println("  delete    Remove password from keyring")
 		fmt.Println("  status    Check if password is stored in keyring")
 		fmt.Println()
+		fmt.Println("Flags (save):")
+		fmt.Println("  --ttl <duration>   Invalidate the stored password after this duration (e.g. 30m, 8h)")
+		fmt.Println()
 		fmt.Println("The keyring password is tied to your vault, so moving .lockenv to")
 		fmt.Println("a different location will still use the same stored password.")
 		fmt.Println()
 		fmt.Println("Examples:")
 		fmt.Println("  lockenv keyring save      # Save password for easy access")
+		fmt.Println("  lockenv keyring save --ttl 8h  # Save password for one working day")
 		fmt.Println("  lockenv keyring status    # Check if password is stored")
 		fmt.Println("  lockenv keyring delete    # Remove password from keyring")
+	case "stash":
+		fmt.Println("lockenv stash [--snapshot] [pop [--force] [--snapshot]|show]")
+		fmt.Println()
+		fmt.Println("Locks all unlocked secrets and removes the plaintext copies, remembering")
+		fmt.Println("which files were removed. Use it before a screen share or before handing")
+		fmt.Println("the machine to someone else, then restore the files with 'stash pop'.")
+		fmt.Println()
+		fmt.Println("With --snapshot, the files with local edits - modified since they were")
+		fmt.Println("locked, or never locked - are copied into an encrypted snapshot in the git")
+		fmt.Println("directory instead. Neither the files nor the vault change, so it is safe")
+		fmt.Println("to run before a checkout or rebase that may overwrite them; the snapshot")
+		fmt.Println("is never committed. 'git-hooks install --stash' takes one automatically.")
+		fmt.Println()
+		fmt.Println("Only one stash and one snapshot can exist at a time.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  (none)    Lock and remove all unlocked files (prompts for password)")
+		fmt.Println("  pop       Restore the stashed files and clear the stash")
+		fmt.Println("  show      List the stashed and snapshotted files (no password required)")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --snapshot   Snapshot local edits (stash), restore the snapshot (pop)")
+		fmt.Println("  --force      Overwrite local files that were recreated or changed (pop)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv stash              # Hide secrets before a screen share")
+		fmt.Println("  lockenv stash pop          # Bring them back")
+		fmt.Println("  lockenv stash --snapshot   # Keep local edits safe across a checkout")
+		fmt.Println("  lockenv stash pop --snapshot")
+	case "guard":
+		fmt.Println("lockenv guard [--interval <duration>]")
+		fmt.Println()
+		fmt.Println("Waits for the relock timer set by 'lockenv unlock --for' and then locks")
+		fmt.Println("the unlocked files again and removes the plaintext copies. Local changes")
+		fmt.Println("are encrypted into the vault first, so nothing is lost. Files are also")
+		fmt.Println("relocked right away when the machine resumes from suspend or, at the")
+		fmt.Println("next check, when the screen is locked. Screen locks are detected on")
+		fmt.Println("Linux through the systemd-logind LockedHint, which GNOME, KDE and most")
+		fmt.Println("lockers set, and on macOS; elsewhere only the timer and suspend count.")
+		fmt.Println()
+		fmt.Println("Prompts for the password once at start and keeps only the derived key")
+		fmt.Println("in memory. Run it in the background or in a spare terminal.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --interval <duration>   How often to check the timer (default 30s)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv unlock --for 2h          # Unlock for two hours")
+		fmt.Println("  lockenv guard                    # Relock them when time is up")
+	case "session":
+		fmt.Println("lockenv session <start [--ttl <duration>]|end|status>")
+		fmt.Println()
+		fmt.Println("Caches the derived vault key so successive commands don't prompt for")
+		fmt.Println("the password. Useful on systems without an OS keyring.")
+		fmt.Println()
+		fmt.Println("The key is wrapped with a machine-local key and stored under")
+		fmt.Println("$XDG_RUNTIME_DIR, which is cleared on logout. The password itself")
+		fmt.Println("is never stored.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  start     Derive the key and start a session (prompts for password)")
+		fmt.Println("  end       End the session and remove the cached key")
+		fmt.Println("  status    Check if a session is active")
+		fmt.Println()
+		fmt.Println("Flags (start):")
+		fmt.Println("  --ttl <duration>   How long the session stays valid (default 15m)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv session start            # Start a 15 minute session")
+		fmt.Println("  lockenv session start --ttl 1h   # Start a one hour session")
+		fmt.Println("  lockenv session end              # End the session")
+	case "ws":
+		fmt.Println("lockenv ws <add <vault-dir>...|rm <vault-dir>...|list|unlock [--force|--keep-local|--keep-both]>")
+		fmt.Println()
+		fmt.Println("Groups the vaults of several projects, e.g. microservices checked out")
+		fmt.Println("side by side, into a workspace and unlocks them all in one command.")
+		fmt.Println()
+		fmt.Printf("The vault directories are listed in %s in the current directory\n", core.WorkspaceFile)
+		fmt.Println("(the vault root inside a vault), relative to it. 'ws unlock' asks for the")
+		fmt.Println("password of each distinct vault once; copies of the same vault share it.")
+		fmt.Println("Keyring entries, sessions and LOCKENV_PASSWORD are used as by unlock.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  add <dir>...   Register vault directories")
+		fmt.Println("  rm <dir>...    Unregister vault directories (the vaults are untouched)")
+		fmt.Println("  list           List the registered vaults")
+		fmt.Println("  unlock         Unlock all files of every registered vault")
+		fmt.Println()
+		fmt.Println("Flags (unlock):")
+		fmt.Println("  --force        Overwrite local files without asking")
+		fmt.Println("  --keep-local   Skip all conflicts, keep local versions")
+		fmt.Println("  --keep-both    Keep both local and vault versions")
+		fmt.Println()
+		fmt.Println("Exits with status 1 if any vault could not be unlocked.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv ws add ../service-a ../service-b")
+		fmt.Println("  lockenv ws unlock")
+	case "recipient":
+		fmt.Println("lockenv recipient <add-ssh <public-key-file>|add-kms <key>|list|rm <fingerprint|comment>>")
+		fmt.Println()
+		fmt.Println("Manages team recipients: SSH ed25519 public keys and cloud KMS keys that")
+		fmt.Println("can unlock the vault without the password. The vault key is wrapped for")
+		fmt.Println("each recipient.")
+		fmt.Println()
+		fmt.Println("When a vault has recipients, commands first try the private key from")
+		fmt.Println("LOCKENV_SSH_IDENTITY (default ~/.ssh/id_ed25519), then KMS keys through")
+		fmt.Println("the aws or gcloud CLI, before asking for the password. Set LOCKENV_KMS=0")
+		fmt.Println("to skip KMS recipients.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  add-ssh   Add an ssh-ed25519 public key (requires password)")
+		fmt.Println("  add-kms   Add an AWS KMS key ARN or GCP KMS key name (requires password)")
+		fmt.Println("  list      List recipients")
+		fmt.Println("  rm        Remove a recipient (requires password)")
+		fmt.Println()
+		fmt.Println("Removing a recipient does not revoke a key they may already hold.")
+		fmt.Println("Run 'lockenv passwd' afterwards to rotate the vault key.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv recipient add-ssh ~/.ssh/id_ed25519.pub")
+		fmt.Println("  lockenv recipient add-kms arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab")
+		fmt.Println("  lockenv recipient add-kms projects/my-proj/locations/global/keyRings/ci/cryptoKeys/lockenv")
+		fmt.Println("  lockenv recipient list")
+		fmt.Println("  lockenv recipient rm alice@laptop")
+	case "domain":
+		fmt.Println("lockenv domain <add <name>|list|rm <name>>")
+		fmt.Println()
+		fmt.Println("Manages encryption domains. Files locked with 'lockenv lock --domain <name>'")
+		fmt.Println("are encrypted with the domain's own password, so the vault password alone")
+		fmt.Println("cannot decrypt them. Use 'lockenv unlock --domain <name>' to unlock them.")
+		fmt.Println()
+		fmt.Println("The domain password is prompted for, or read from LOCKENV_DOMAIN_PASSWORD.")
+		fmt.Println("'lockenv passwd' does not change domain passwords.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  add       Create a domain with a new password (requires password)")
+		fmt.Println("  list      List domains")
+		fmt.Println("  rm        Remove a domain without files (requires password)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv domain add prod")
+		fmt.Println("  lockenv lock --domain prod prod.env")
+		fmt.Println("  lockenv unlock --domain prod")
+		fmt.Println("  lockenv domain list")
+	case "meta":
+		fmt.Println("lockenv meta [show|set <field> <value>|unset <field>]")
+		fmt.Println()
+		fmt.Println("Records what the vault protects and whom to ask for access. The fields")
+		fmt.Println("are description, owner and contact. They are stored unencrypted, shown by")
+		fmt.Println("'lockenv status' and readable without the password, so never put secrets")
+		fmt.Println("in them.")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  show      Print the fields (default)")
+		fmt.Println("  set       Set a field (requires password)")
+		fmt.Println("  unset     Remove a field (requires password)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  lockenv meta set description \"payments service secrets\"")
+		fmt.Println("  lockenv meta set owner payments-team")
+		fmt.Println("  lockenv meta set contact \"#payments-oncall on Slack\"")
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		printUsage()
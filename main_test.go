@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the lockenv command instead of the tests when the test
+// binary is started by runLockenv
+func TestMain(m *testing.M) {
+	if os.Getenv("LOCKENV_TEST_MAIN") == "1" {
+		os.Args = append([]string{"lockenv"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runLockenv runs lockenv with args in dir, failing the test unless it
+// succeeds, and returns its combined output
+func runLockenv(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	home := t.TempDir()
+	c := exec.Command(os.Args[0], args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(),
+		"LOCKENV_TEST_MAIN=1",
+		"LOCKENV_PASSWORD=test123",
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+home,
+		"XDG_CACHE_HOME="+home,
+	)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Fatalf("lockenv %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func TestSubdirectory_RelativePaths(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, ".env"), []byte("A=1\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	runLockenv(t, root, "init")
+	runLockenv(t, sub, "lock", ".env")
+
+	out := runLockenv(t, root, "ls")
+	if !strings.Contains(out, "sub/.env") {
+		t.Errorf("Expected sub/.env in the vault, got:\n%s", out)
+	}
+
+	// Patterns are relative to the subdirectory as well
+	if err := os.Remove(filepath.Join(sub, ".env")); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	runLockenv(t, sub, "unlock", "--force", "*.env")
+	if content, err := os.ReadFile(filepath.Join(sub, ".env")); err != nil || string(content) != "A=1\n" {
+		t.Errorf("Expected sub/.env unlocked, got %q, %v", content, err)
+	}
+
+	// So are path flags given on the command line
+	runLockenv(t, sub, "backup", "--dir", "backups")
+	if entries, err := os.ReadDir(filepath.Join(sub, "backups")); err != nil || len(entries) == 0 {
+		t.Errorf("Expected a backup in sub/backups, got %v, %v", entries, err)
+	}
+}